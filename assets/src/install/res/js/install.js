@@ -394,7 +394,7 @@ class InstallForm extends React.Component {
                     <div style={stepperStyles.contentScroller}>
                         <h3>Database Configuration</h3>
                         Pydio requires at least one SQL storage for configuration and data indexation.
-                        Configure here the connection to your MySQL/MariaDB server. Please make sure that your database is running <b>MySQL version 5.6 or higher</b>.
+                        Configure here the connection to your MySQL/MariaDB server (<b>MySQL version 5.6 or higher</b>) or to your PostgreSQL server.
                         {dbCheckError &&
                         <div style={{color: '#E53935', paddingTop: 10, fontWeight: 500}}>{dbCheckError}</div>
                         }
@@ -403,9 +403,10 @@ class InstallForm extends React.Component {
                                 <MenuItem value="tcp" primaryText="TCP" />
                                 <MenuItem value="socket" primaryText="Socket" />
                                 <MenuItem value="manual" primaryText="Manual" />
+                                <MenuItem value="postgres" primaryText="PostgreSQL" />
                             </Field>
 
-                            {dbConnectionType === "tcp" && (
+                            {(dbConnectionType === "tcp" || dbConnectionType === "postgres") && (
                                 <div style={flexContainer}>
                                     <div style={{display:'flex'}}>
                                         <div style={{flex: 1, marginRight: 2}}><Field name="dbTCPHostname" component={renderTextField} floatingLabel="Host Name" label="Server where mysql is running" /></div>
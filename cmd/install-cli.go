@@ -214,10 +214,11 @@ func promptDB(c *install.InstallConfig) error {
 
 	connType := p.Select{
 		Label: "Database Connection Type",
-		Items: []string{"TCP", "Socket", "Manual"},
+		Items: []string{"TCP", "Socket", "Manual", "PostgreSQL"},
 	}
 	dbTcpHost := p.Prompt{Label: "Database Hostname", Validate: notEmpty, Default: "localhost"}
 	dbTcpPort := p.Prompt{Label: "Database Port", Validate: validPortNumber, Default: "3306"}
+	dbPgPort := p.Prompt{Label: "Database Port", Validate: validPortNumber, Default: "5432"}
 
 	dbName := p.Prompt{Label: "Database Name", Validate: notEmpty, Default: "cells"}
 	dbUser := p.Prompt{Label: "Database User", Validate: notEmpty}
@@ -246,6 +247,14 @@ func promptDB(c *install.InstallConfig) error {
 			if c.DbSocketFile, e = dbSocketFile.Run(); e != nil {
 				return e
 			}
+		} else if uConnIdx == 3 {
+			c.DbConnectionType = "postgres"
+			if c.DbTCPHostname, e = dbTcpHost.Run(); e != nil {
+				return e
+			}
+			if c.DbTCPPort, e = dbPgPort.Run(); e != nil {
+				return e
+			}
 		}
 		var name, user, pass string
 		if name, e = dbName.Run(); e != nil {
@@ -257,7 +266,7 @@ func promptDB(c *install.InstallConfig) error {
 		if pass, e = dbPass.Run(); e != nil {
 			return e
 		}
-		if uConnIdx == 0 {
+		if uConnIdx == 0 || uConnIdx == 3 {
 			c.DbTCPName = name
 			c.DbTCPUser = user
 			c.DbTCPPassword = pass
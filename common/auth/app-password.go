@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-micro/metadata"
+	"github.com/pborman/uuid"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/crypto"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
+)
+
+const (
+	// AppPasswordKeyPrefix prefixes the ID of the user keys storing application passwords.
+	AppPasswordKeyPrefix = "app-password."
+)
+
+var (
+	ErrInvalidAppPassword = errors.New("invalid application password")
+)
+
+// CreateAppPassword generates a new application password for a user, typically to mount WebDAV on a given
+// device without using the account password. It is stored in the user keys store as a random key sealed
+// with the password itself. The generated password is returned in clear and cannot be retrieved afterward.
+func CreateAppPassword(ctx context.Context, login string, label string) (*encryption.Key, string, error) {
+	id := strings.Replace(uuid.New(), "-", "", -1)[0:12]
+	secret, err := crypto.RandomBytes(24)
+	if err != nil {
+		return nil, "", err
+	}
+	password := id + "-" + base64.RawURLEncoding.EncodeToString(secret)
+	content, err := crypto.RandomBytes(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key := &encryption.Key{
+		Owner:        login,
+		ID:           AppPasswordKeyPrefix + id,
+		Label:        label,
+		Content:      base64.StdEncoding.EncodeToString(content),
+		CreationDate: int32(time.Now().Unix()),
+	}
+	if _, err := userKeyStoreClient().AddKey(ctx, &encryption.AddKeyRequest{Key: proto.Clone(key).(*encryption.Key), StrPassword: password}); err != nil {
+		return nil, "", err
+	}
+	key.Content = ""
+	return key, password, nil
+}
+
+// ListAppPasswords lists the application passwords of a user, without their content.
+func ListAppPasswords(ctx context.Context, login string) ([]*encryption.Key, error) {
+	rsp, err := userKeyStoreClient().ListUserKeys(ctx, &encryption.ListUserKeysRequest{Owner: login})
+	if err != nil {
+		return nil, err
+	}
+	var keys []*encryption.Key
+	for _, k := range rsp.Keys {
+		if strings.HasPrefix(k.ID, AppPasswordKeyPrefix) {
+			k.Content = ""
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// DeleteAppPassword revokes an application password of a user.
+func DeleteAppPassword(ctx context.Context, login string, keyID string) error {
+	if !strings.HasPrefix(keyID, AppPasswordKeyPrefix) {
+		keyID = AppPasswordKeyPrefix + keyID
+	}
+	_, err := userKeyStoreClient().DeleteUserKey(ctx, &encryption.DeleteUserKeyRequest{Owner: login, KeyID: keyID})
+	return err
+}
+
+// CheckAppPassword verifies that the password is a valid application password for this user.
+// The key ID is found in the password prefix, and the password is valid if it opens the stored key.
+func CheckAppPassword(ctx context.Context, login string, password string) bool {
+	parts := strings.SplitN(password, "-", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+	rsp, err := userKeyStoreClient().GetKey(ctx, &encryption.GetKeyRequest{
+		Owner:       login,
+		KeyID:       AppPasswordKeyPrefix + parts[0],
+		StrPassword: password,
+	})
+	return err == nil && rsp.Key != nil
+}
+
+// AppPasswordCredentials checks an application password and builds the claims of the corresponding user.
+func AppPasswordCredentials(ctx context.Context, login string, password string) (context.Context, claim.Claims, error) {
+	claims := claim.Claims{}
+	if !CheckAppPassword(ctx, login, password) {
+		return ctx, claims, ErrInvalidAppPassword
+	}
+	user, err := utils.SearchUniqueUser(ctx, login, "")
+	if err != nil {
+		return ctx, claims, err
+	}
+	claims = ClaimsFromUser(user)
+	return WithClaims(ctx, claims), claims, nil
+}
+
+// ClaimsFromUser builds claims similar to the ones issued by the pydio connector for this user.
+func ClaimsFromUser(user *idm.User) claim.Claims {
+	var roles []string
+	for _, role := range user.Roles {
+		roles = append(roles, role.Uuid)
+	}
+	profile, ok := user.Attributes["profile"]
+	if !ok {
+		profile = "standard"
+	}
+	subject, _ := proto.Marshal(&claim.IDTokenSubject{UserId: user.Uuid, ConnId: "pydio"})
+	return claim.Claims{
+		Subject:     base64.RawURLEncoding.EncodeToString(subject),
+		Name:        user.Login,
+		Email:       user.Attributes["email"],
+		Verified:    true,
+		Profile:     profile,
+		Roles:       strings.Join(roles, ","),
+		DisplayName: user.Attributes["displayName"],
+		GroupPath:   user.GetGroupPath(),
+		AuthSource:  "pydio",
+	}
+}
+
+// WithClaims stores the claims in context and in the metadata passed to other services.
+func WithClaims(ctx context.Context, claims claim.Claims) context.Context {
+	ctx = context.WithValue(ctx, claim.ContextKey, claims)
+	md := make(map[string]string)
+	if existing, ok := metadata.FromContext(ctx); ok {
+		for k, v := range existing {
+			md[k] = v
+		}
+	}
+	md[common.PYDIO_CONTEXT_USER_KEY] = claims.Name
+	jsonClaims, _ := json.Marshal(claims)
+	md[claim.MetadataContextKey] = string(jsonClaims)
+	return metadata.NewContext(ctx, md)
+}
+
+func userKeyStoreClient() encryption.UserKeyStoreClient {
+	return encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+}
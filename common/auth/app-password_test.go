@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-micro/metadata"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/proto/idm"
+)
+
+func TestClaimsFromUser(t *testing.T) {
+
+	Convey("Test claims built for an app password user", t, func() {
+
+		user := &idm.User{
+			Uuid:       "user-uuid",
+			Login:      "john",
+			GroupPath:  "/team",
+			Attributes: map[string]string{"email": "john@example.com", "displayName": "John"},
+			Roles:      []*idm.Role{{Uuid: "ROOT_GROUP"}, {Uuid: "user-uuid"}},
+		}
+		claims := ClaimsFromUser(user)
+		So(claims.Name, ShouldEqual, "john")
+		So(claims.Email, ShouldEqual, "john@example.com")
+		So(claims.DisplayName, ShouldEqual, "John")
+		So(claims.Profile, ShouldEqual, "standard")
+		So(claims.Roles, ShouldEqual, "ROOT_GROUP,user-uuid")
+		So(claims.Verified, ShouldBeTrue)
+
+		data, e := base64.RawURLEncoding.DecodeString(claims.Subject)
+		So(e, ShouldBeNil)
+		var subject claim.IDTokenSubject
+		So(proto.Unmarshal(data, &subject), ShouldBeNil)
+		So(subject.UserId, ShouldEqual, "user-uuid")
+		So(subject.ConnId, ShouldEqual, "pydio")
+
+		ctx := WithClaims(context.Background(), claims)
+		So(ctx.Value(claim.ContextKey), ShouldResemble, claims)
+		md, ok := metadata.FromContext(ctx)
+		So(ok, ShouldBeTrue)
+		So(md[common.PYDIO_CONTEXT_USER_KEY], ShouldEqual, "john")
+
+	})
+
+	Convey("Test malformed app passwords are rejected without lookup", t, func() {
+
+		So(CheckAppPassword(context.Background(), "john", "nodash"), ShouldBeFalse)
+		So(CheckAppPassword(context.Background(), "john", "-secret"), ShouldBeFalse)
+
+	})
+}
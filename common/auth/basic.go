@@ -23,6 +23,8 @@ package auth
 import (
 	"context"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/micro/go-micro/metadata"
@@ -44,20 +46,55 @@ type validBasicUser struct {
 }
 
 type BasicAuthenticator struct {
-	TTL   time.Duration
-	Realm string
-	cache map[string]*validBasicUser
+	TTL     time.Duration
+	Realm   string
+	cache   map[string]*validBasicUser
+	cacheMu sync.Mutex
 }
 
+// cached returns the claims of a user recently authenticated with the same password, if any.
+func (b *BasicAuthenticator) cached(user, pass string) (claim.Claims, bool) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	valid, ok := b.cache[user]
+	if !ok || time.Now().Sub(valid.Connexion) > time.Duration(time.Minute*10) || valid.Hash != pass {
+		return claim.Claims{}, false
+	}
+	valid.Connexion = time.Now()
+	return valid.Claims, true
+}
+
+func (b *BasicAuthenticator) store(user, pass string, claims claim.Claims) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	b.cache[user] = &validBasicUser{
+		Hash:      pass,
+		Connexion: time.Now(),
+		Claims:    claims,
+	}
+}
+
+// Wrap authenticates requests either with a JWT passed as Bearer token (e.g. for users whose account comes
+// from an external identity provider), or with Basic credentials. Basic credentials may be the user
+// password or one of their application passwords. Application passwords are checked on each request
+// so that a revoked password is refused immediately.
 func (b *BasicAuthenticator) Wrap(handler http.Handler) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		if user, pass, ok := r.BasicAuth(); ok {
+		if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+
+			jwtHelper := DefaultJWTVerifier()
+			if newCtx, _, err := jwtHelper.Verify(r.Context(), strings.TrimPrefix(bearer, "Bearer ")); err == nil {
+				handler.ServeHTTP(w, r.WithContext(newCtx))
+				return
+			}
+
+		} else if user, pass, ok := r.BasicAuth(); ok {
 
 			ctx := r.Context()
 
-			if valid, vOk := b.cache[user]; vOk && time.Now().Sub(valid.Connexion) <= time.Duration(time.Minute*10) && valid.Hash == pass {
+			if claims, cOk := b.cached(user, pass); cOk {
 
 				md := map[string]string{}
 				if meta, ok := metadata.FromContext(ctx); ok {
@@ -65,26 +102,24 @@ func (b *BasicAuthenticator) Wrap(handler http.Handler) http.HandlerFunc {
 						md[k] = v
 					}
 				}
-				md[common.PYDIO_CONTEXT_USER_KEY] = valid.Claims.Name
+				md[common.PYDIO_CONTEXT_USER_KEY] = claims.Name
 				ctx = metadata.NewContext(ctx, md)
 
-				r = r.WithContext(context.WithValue(ctx, claim.ContextKey, valid.Claims))
+				r = r.WithContext(context.WithValue(ctx, claim.ContextKey, claims))
 
-				valid.Connexion = time.Now()
 				handler.ServeHTTP(w, r)
 				return
 			}
 
 			jwtHelper := DefaultJWTVerifier()
-			newCtx, claims, err := jwtHelper.PasswordCredentialsToken(ctx, user, pass)
-			if err == nil {
-				r = r.WithContext(newCtx)
-				b.cache[user] = &validBasicUser{
-					Hash:      pass,
-					Connexion: time.Now(),
-					Claims:    claims,
-				}
-				handler.ServeHTTP(w, r)
+			if newCtx, claims, err := jwtHelper.PasswordCredentialsToken(ctx, user, pass); err == nil {
+				b.store(user, pass, claims)
+				handler.ServeHTTP(w, r.WithContext(newCtx))
+				return
+			}
+			if newCtx, _, err := AppPasswordCredentials(ctx, user, pass); err == nil {
+				handler.ServeHTTP(w, r.WithContext(newCtx))
+				return
 			}
 		}
 
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/auth/claim"
+)
+
+func TestBasicAuthenticatorCache(t *testing.T) {
+
+	Convey("Test cached basic credentials", t, func() {
+		b := NewBasicAuthenticator("realm", time.Minute)
+		_, ok := b.cached("user", "pass")
+		So(ok, ShouldBeFalse)
+
+		b.store("user", "pass", claim.Claims{Name: "user"})
+		claims, ok := b.cached("user", "pass")
+		So(ok, ShouldBeTrue)
+		So(claims.Name, ShouldEqual, "user")
+		_, ok = b.cached("user", "other")
+		So(ok, ShouldBeFalse)
+
+		b.cache["user"].Connexion = time.Now().Add(-time.Hour)
+		_, ok = b.cached("user", "pass")
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test concurrent access to the cache", t, func() {
+		b := NewBasicAuthenticator("realm", time.Minute)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.store("user", "pass", claim.Claims{Name: "user"})
+				b.cached("user", "pass")
+			}()
+		}
+		wg.Wait()
+		_, ok := b.cached("user", "pass")
+		So(ok, ShouldBeTrue)
+	})
+
+}
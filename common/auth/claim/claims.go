@@ -31,6 +31,11 @@ import (
 const (
 	ContextKey         = "pydio-claims"
 	MetadataContextKey = "x-pydio-claims"
+
+	// Authentication Method Reference values (RFC 8176)
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	AuthMethodMFA      = "mfa"
 )
 
 type IDTokenSubject struct {
@@ -53,9 +58,22 @@ type Claims struct {
 	Verified    bool      `json:"email_verified"`
 	Roles       string    `json:"roles"`
 	Expiry      time.Time `json:"expiry"`
+	IssuedAt    int64     `json:"iat,omitempty"`
 	AuthSource  string    `json:"authSource"`
 	DisplayName string    `json:"displayName"`
 	GroupPath   string    `json:"groupPath"`
+	AuthMethods []string  `json:"amr,omitempty"`
+}
+
+// HasSecondFactor checks if the authentication methods (amr) listed in the claims
+// include a second factor.
+func (c *Claims) HasSecondFactor() bool {
+	for _, m := range c.AuthMethods {
+		if m == AuthMethodMFA || m == AuthMethodOTP {
+			return true
+		}
+	}
+	return false
 }
 
 // Decode Subject field of the claims
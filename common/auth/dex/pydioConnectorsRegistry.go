@@ -37,6 +37,10 @@ var (
 
 func init() {
 	RegisterDexPydioConnector("pydio-api", func() PydioConnectorConfig { return new(ApiConfig) })
+	for name := range externalConnectors {
+		connectorType := name
+		RegisterDexPydioConnector(connectorType, func() PydioConnectorConfig { return NewExternalConfig(connectorType) })
+	}
 }
 
 func RegisterDexPydioConnector(name string, configProvider func() PydioConnectorConfig) {
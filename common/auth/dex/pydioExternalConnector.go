@@ -0,0 +1,491 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/dex/connector"
+	"github.com/coreos/dex/connector/github"
+	"github.com/coreos/dex/connector/gitlab"
+	"github.com/coreos/dex/connector/ldap"
+	"github.com/coreos/dex/connector/oidc"
+	"github.com/coreos/dex/connector/saml"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/micro/go-micro/errors"
+	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/common/utils"
+)
+
+const (
+	// ExternalConnectorTypePrefix prefixes the dex connector types opening an external connector
+	// with provisioning, as opposed to the raw upstream connectors.
+	ExternalConnectorTypePrefix = "pydio-"
+
+	LoginAttributeUsername = "Username"
+	LoginAttributeEmail    = "Email"
+	LoginAttributeUserID   = "UserID"
+
+	// AuthSourceAttribute is the user attribute holding the name of the external connector that
+	// provisions the user. Existing users are only updated by the connector they are linked to.
+	AuthSourceAttribute = "AuthSource"
+)
+
+// externalConnectors lists the upstream dex connectors that can be used as Pydio connectors,
+// along with the identity attribute used by default as the Cells login.
+var externalConnectors = map[string]struct {
+	config         func() PydioConnectorConfig
+	loginAttribute string
+	redirect       bool
+}{
+	"ldap":   {func() PydioConnectorConfig { return new(ldap.Config) }, LoginAttributeUsername, false},
+	"oidc":   {func() PydioConnectorConfig { return new(oidc.Config) }, LoginAttributeEmail, true},
+	"saml":   {func() PydioConnectorConfig { return new(saml.Config) }, LoginAttributeEmail, true},
+	"github": {func() PydioConnectorConfig { return new(github.Config) }, LoginAttributeEmail, true},
+	"gitlab": {func() PydioConnectorConfig { return new(gitlab.Config) }, LoginAttributeEmail, true},
+}
+
+// IsRedirectConnector tells whether a connector type authenticates users by redirecting them
+// to an external provider, instead of checking a login and password.
+func IsRedirectConnector(connectorType string) bool {
+	ext, ok := externalConnectors[strings.TrimPrefix(connectorType, ExternalConnectorTypePrefix)]
+	return ok && ext.redirect
+}
+
+// ProvisioningConfig defines how the identities returned by an external connector are
+// mapped to Cells users, which are created or updated at each login.
+type ProvisioningConfig struct {
+	// LoginAttribute is the identity attribute used as Cells login: Username, Email or UserID.
+	LoginAttribute string
+	// GroupPath is where new users are created, unless a mapping rule targets GroupPath.
+	GroupPath string
+	// Profile is given to new users, standard by default.
+	Profile string
+	// MappingRules read an identity attribute (UserID, Username, Email, DisplayName or Groups)
+	// and write it to the user Roles, GroupPath or to any user attribute.
+	MappingRules []auth.MappingRule
+}
+
+// ExternalConfig wraps the configuration of an upstream dex connector. The upstream
+// configuration and the "provisioning" key are read from the same JSON object.
+type ExternalConfig struct {
+	connectorType string
+	upstream      PydioConnectorConfig
+	// AuthSource is the name of the sub-connector in the pydio wrapper configuration.
+	AuthSource   string
+	Provisioning ProvisioningConfig
+}
+
+// NewExternalConfig creates an empty configuration for an upstream connector type.
+func NewExternalConfig(connectorType string) *ExternalConfig {
+	ext := externalConnectors[connectorType]
+	return &ExternalConfig{
+		connectorType: connectorType,
+		upstream:      ext.config(),
+		Provisioning: ProvisioningConfig{
+			LoginAttribute: ext.loginAttribute,
+			GroupPath:      "/",
+			Profile:        common.PYDIO_PROFILE_STANDARD,
+		},
+	}
+}
+
+func (c *ExternalConfig) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, c.upstream); err != nil {
+		return err
+	}
+	var p struct {
+		AuthSource   *string             `json:"authSource"`
+		Provisioning *ProvisioningConfig `json:"provisioning"`
+	}
+	p.AuthSource = &c.AuthSource
+	p.Provisioning = &c.Provisioning
+	return json.Unmarshal(b, &p)
+}
+
+func (c *ExternalConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(c.upstream)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if c.AuthSource != "" {
+		m["authSource"] = c.AuthSource
+	}
+	m["provisioning"] = c.Provisioning
+	return json.Marshal(m)
+}
+
+// Open opens the upstream connector and wraps it to provision Cells users.
+func (c *ExternalConfig) Open(logger logrus.FieldLogger) (connector.Connector, error) {
+	conn, err := c.upstream.Open(logger)
+	if err != nil {
+		return nil, err
+	}
+	p := &provisioner{config: *c, upstream: conn, logger: logger}
+	switch conn := conn.(type) {
+	case connector.PasswordConnector:
+		return &externalPasswordConnector{provisioner: p, conn: conn}, nil
+	case connector.CallbackConnector:
+		return &externalCallbackConnector{provisioner: p, conn: conn}, nil
+	case connector.SAMLConnector:
+		return &externalSAMLConnector{provisioner: p, conn: conn}, nil
+	}
+	return nil, fmt.Errorf("unsupported connector type %q", c.connectorType)
+}
+
+type externalPasswordConnector struct {
+	*provisioner
+	conn connector.PasswordConnector
+}
+
+func (e *externalPasswordConnector) Login(ctx context.Context, s connector.Scopes, username, password string) (connector.Identity, bool, error) {
+	ident, ok, err := e.conn.Login(ctx, e.scopes(s), username, password)
+	if err != nil || !ok {
+		return ident, ok, err
+	}
+	ident, err = e.provision(ctx, ident)
+	if err != nil {
+		return connector.Identity{}, false, err
+	}
+	return ident, true, nil
+}
+
+type externalCallbackConnector struct {
+	*provisioner
+	conn connector.CallbackConnector
+}
+
+func (e *externalCallbackConnector) LoginURL(s connector.Scopes, callbackURL, state string) (string, error) {
+	return e.conn.LoginURL(e.scopes(s), callbackURL, state)
+}
+
+func (e *externalCallbackConnector) HandleCallback(s connector.Scopes, r *http.Request) (connector.Identity, error) {
+	ident, err := e.conn.HandleCallback(e.scopes(s), r)
+	if err != nil {
+		return ident, err
+	}
+	return e.provision(r.Context(), ident)
+}
+
+type externalSAMLConnector struct {
+	*provisioner
+	conn connector.SAMLConnector
+}
+
+func (e *externalSAMLConnector) POSTData(s connector.Scopes, requestID string) (string, string, error) {
+	return e.conn.POSTData(e.scopes(s), requestID)
+}
+
+func (e *externalSAMLConnector) HandlePOST(s connector.Scopes, samlResponse, inResponseTo string) (connector.Identity, error) {
+	ident, err := e.conn.HandlePOST(e.scopes(s), samlResponse, inResponseTo)
+	if err != nil {
+		return ident, err
+	}
+	return e.provision(context.Background(), ident)
+}
+
+var (
+	_ connector.PasswordConnector = (*externalPasswordConnector)(nil)
+	_ connector.RefreshConnector  = (*externalPasswordConnector)(nil)
+	_ connector.CallbackConnector = (*externalCallbackConnector)(nil)
+	_ connector.RefreshConnector  = (*externalCallbackConnector)(nil)
+	_ connector.SAMLConnector     = (*externalSAMLConnector)(nil)
+	_ connector.RefreshConnector  = (*externalSAMLConnector)(nil)
+)
+
+// provisioner creates or updates the Cells user matching an identity returned by the upstream connector.
+type provisioner struct {
+	config   ExternalConfig
+	upstream connector.Connector
+	logger   logrus.FieldLogger
+}
+
+// Refresh lets the upstream connector check that the identity is still valid, then reloads the Cells user.
+// Mapping rules are only applied at login, as upstream connectors do not always return the same attributes.
+func (p *provisioner) Refresh(ctx context.Context, s connector.Scopes, ident connector.Identity) (connector.Identity, error) {
+	if rc, ok := p.upstream.(connector.RefreshConnector); ok {
+		refreshed, err := rc.Refresh(ctx, p.scopes(s), ident)
+		if err != nil {
+			return ident, err
+		}
+		ident.ConnectorData = refreshed.ConnectorData
+	}
+	user, err := utils.SearchUniqueUser(ctx, ident.Username, "")
+	if err != nil {
+		return ident, err
+	}
+	return p.identity(ctx, user, ident.ConnectorData)
+}
+
+// scopes makes sure groups are requested when a mapping rule reads them.
+func (p *provisioner) scopes(s connector.Scopes) connector.Scopes {
+	for _, rule := range p.config.Provisioning.MappingRules {
+		if rule.LeftAttribute == "Groups" {
+			s.Groups = true
+		}
+	}
+	return s
+}
+
+// provision finds or creates the Cells user and updates its roles and attributes from the identity.
+// An existing user is only updated if it was created by this connector, or linked to it by setting
+// its AuthSourceAttribute: a user of another source with the same login is never taken over.
+func (p *provisioner) provision(ctx context.Context, ident connector.Identity) (connector.Identity, error) {
+	if p.config.Provisioning.LoginAttribute == LoginAttributeEmail && !ident.EmailVerified {
+		return connector.Identity{}, fmt.Errorf("%s connector returned an unverified email, it cannot be used as login", p.config.connectorType)
+	}
+	target := p.mapIdentity(ident)
+	if target.Login == "" {
+		return connector.Identity{}, fmt.Errorf("%s connector returned an identity without %s", p.config.connectorType, p.config.Provisioning.LoginAttribute)
+	}
+
+	existing, err := utils.SearchUniqueUser(ctx, target.Login, "")
+	if err != nil {
+		if errors.Parse(err.Error()).Code != http.StatusNotFound {
+			return connector.Identity{}, err
+		}
+		existing = nil
+	}
+	if existing != nil && !p.linked(existing) {
+		log.Logger(ctx).Error("external identity matches a user that is not linked to this connector", zap.String(common.KEY_USERNAME, target.Login), zap.String(common.KEY_CONNECTOR, p.config.connectorType))
+		return connector.Identity{}, fmt.Errorf("user %s already exists and is not linked to this connector", target.Login)
+	}
+	if err := p.ensureRoles(ctx, target.Roles); err != nil {
+		return connector.Identity{}, err
+	}
+
+	userClient := idm.NewUserServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER, defaults.NewClient())
+	if existing == nil {
+		target.Password = uuid.New() + uuid.New()
+		resp, err := userClient.CreateUser(ctx, &idm.CreateUserRequest{User: target})
+		if err != nil {
+			return connector.Identity{}, err
+		}
+		roleClient := idm.NewRoleServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ROLE, defaults.NewClient())
+		if _, err := roleClient.CreateRole(ctx, &idm.CreateRoleRequest{Role: &idm.Role{
+			Uuid:     resp.User.Uuid,
+			Label:    "User " + target.Login,
+			UserRole: true,
+		}}); err != nil {
+			return connector.Identity{}, err
+		}
+		log.Logger(ctx).Info("provisioned user from external connector", zap.String(common.KEY_USERNAME, target.Login), zap.String(common.KEY_CONNECTOR, p.config.connectorType))
+	} else if p.merge(existing, target) {
+		if _, err := userClient.CreateUser(ctx, &idm.CreateUserRequest{User: existing}); err != nil {
+			return connector.Identity{}, err
+		}
+	}
+
+	user, err := utils.SearchUniqueUser(ctx, target.Login, "")
+	if err != nil {
+		return connector.Identity{}, err
+	}
+	return p.identity(ctx, user, ident.ConnectorData)
+}
+
+// linked tells whether an existing user is managed by this connector.
+func (p *provisioner) linked(user *idm.User) bool {
+	return p.config.AuthSource != "" && user.Attributes[AuthSourceAttribute] == p.config.AuthSource
+}
+
+// identity checks the login policies and converts the Cells user to an identity.
+func (p *provisioner) identity(ctx context.Context, user *idm.User, connectorData []byte) (connector.Identity, error) {
+	if !checkConnectionPolicy(ctx, user) {
+		return connector.Identity{}, fmt.Errorf("user %s is not authorized to log in", user.Login)
+	}
+	ident := ConvertUserApiToIdentity(user, p.config.connectorType)
+	ident.ConnectorData = connectorData
+	return ident, nil
+}
+
+// mapIdentity computes the Cells user expected for this identity by applying the mapping rules.
+func (p *provisioner) mapIdentity(ident connector.Identity) *idm.User {
+	conf := p.config.Provisioning
+	user := &idm.User{
+		GroupPath:  conf.GroupPath,
+		Attributes: map[string]string{"profile": conf.Profile},
+	}
+	switch conf.LoginAttribute {
+	case LoginAttributeEmail:
+		user.Login = ident.Email
+	case LoginAttributeUserID:
+		user.Login = ident.UserID
+	default:
+		user.Login = ident.Username
+	}
+	if user.Attributes["profile"] == "" {
+		user.Attributes["profile"] = common.PYDIO_PROFILE_STANDARD
+	}
+	if ident.DisplayName != "" {
+		user.Attributes["displayName"] = ident.DisplayName
+	} else if ident.Username != "" && ident.Username != user.Login {
+		user.Attributes["displayName"] = ident.Username
+	}
+	if ident.Email != "" && ident.EmailVerified {
+		user.Attributes["email"] = ident.Email
+	}
+
+	for _, rule := range conf.MappingRules {
+		var values []string
+		switch rule.LeftAttribute {
+		case "UserID":
+			values = []string{ident.UserID}
+		case "Username":
+			values = []string{ident.Username}
+		case "Email":
+			values = []string{ident.Email}
+		case "DisplayName":
+			values = []string{ident.DisplayName}
+		case "Groups":
+			values = ident.Groups
+		}
+		values = rule.Apply(values)
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+		switch rule.RightAttribute {
+		case "Roles":
+			for _, v := range values {
+				user.Roles = append(user.Roles, &idm.Role{Uuid: v, Label: v})
+			}
+		case "GroupPath":
+			user.GroupPath = "/" + strings.Trim(values[0], "/")
+		default:
+			user.Attributes[rule.RightAttribute] = strings.Join(values, ",")
+		}
+	}
+	if p.config.AuthSource != "" {
+		user.Attributes[AuthSourceAttribute] = p.config.AuthSource
+	} else {
+		delete(user.Attributes, AuthSourceAttribute)
+	}
+	return user
+}
+
+// merge applies the mapped roles and attributes on an existing user and tells whether it changed.
+// The group path and profile are only set at creation. Roles starting with the prefix of a rule
+// are managed by the connector: they are removed when they are not mapped anymore.
+func (p *provisioner) merge(existing *idm.User, target *idm.User) (changed bool) {
+	if existing.Attributes == nil {
+		existing.Attributes = make(map[string]string)
+	}
+	for k, v := range target.Attributes {
+		if k == "profile" || k == AuthSourceAttribute {
+			continue
+		}
+		if existing.Attributes[k] != v {
+			existing.Attributes[k] = v
+			changed = true
+		}
+	}
+
+	var prefixes []string
+	for _, rule := range p.config.Provisioning.MappingRules {
+		if rule.RightAttribute == "Roles" && rule.RolePrefix != "" {
+			prefixes = append(prefixes, rule.RolePrefix)
+		}
+	}
+	mapped := make(map[string]bool)
+	for _, r := range target.Roles {
+		mapped[r.Uuid] = true
+	}
+	var roles []*idm.Role
+	for _, r := range existing.Roles {
+		if len(r.AutoApplies) > 0 {
+			// Added by the user service from the profile, not stored on the user
+			continue
+		}
+		managed := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.Uuid, prefix) {
+				managed = true
+			}
+		}
+		if managed && !mapped[r.Uuid] {
+			changed = true
+			continue
+		}
+		delete(mapped, r.Uuid)
+		roles = append(roles, r)
+	}
+	for _, r := range target.Roles {
+		if mapped[r.Uuid] {
+			roles = append(roles, r)
+			changed = true
+		}
+	}
+	existing.Roles = roles
+	return
+}
+
+// ensureRoles creates the mapped roles that do not exist yet.
+func (p *provisioner) ensureRoles(ctx context.Context, roles []*idm.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	var uuids []string
+	for _, r := range roles {
+		uuids = append(uuids, r.Uuid)
+	}
+	roleClient := idm.NewRoleServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ROLE, defaults.NewClient())
+	q, _ := ptypes.MarshalAny(&idm.RoleSingleQuery{Uuid: uuids})
+	stream, err := roleClient.SearchRole(ctx, &idm.SearchRoleRequest{Query: &service.Query{SubQueries: []*any.Any{q}}})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	found := make(map[string]bool)
+	for {
+		resp, e := stream.Recv()
+		if e != nil {
+			break
+		}
+		found[resp.GetRole().GetUuid()] = true
+	}
+	for _, r := range roles {
+		if found[r.Uuid] {
+			continue
+		}
+		if _, err := roleClient.CreateRole(ctx, &idm.CreateRoleRequest{Role: &idm.Role{Uuid: r.Uuid, Label: r.Label}}); err != nil {
+			return err
+		}
+		found[r.Uuid] = true
+	}
+	return nil
+}
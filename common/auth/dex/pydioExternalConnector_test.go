@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dex
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/coreos/dex/connector"
+	"github.com/coreos/dex/connector/ldap"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/auth"
+	"github.com/pydio/cells/common/proto/idm"
+)
+
+func TestExternalConfig(t *testing.T) {
+
+	Convey("Test external connector config", t, func() {
+
+		rawData := []byte(`{"host":"ldap.example.com:389","userSearch":{"baseDN":"ou=people,dc=example,dc=com","username":"uid"},"provisioning":{"GroupPath":"/ldap","MappingRules":[{"LeftAttribute":"Groups","RightAttribute":"Roles","RolePrefix":"ldap_"}]}}`)
+
+		c := NewExternalConfig("ldap")
+		So(json.Unmarshal(rawData, c), ShouldBeNil)
+		So(c.upstream.(*ldap.Config).Host, ShouldEqual, "ldap.example.com:389")
+		So(c.Provisioning.GroupPath, ShouldEqual, "/ldap")
+		So(c.Provisioning.LoginAttribute, ShouldEqual, LoginAttributeUsername)
+		So(c.Provisioning.MappingRules, ShouldHaveLength, 1)
+
+		data, err := json.Marshal(c)
+		So(err, ShouldBeNil)
+		c2 := NewExternalConfig("ldap")
+		So(json.Unmarshal(data, c2), ShouldBeNil)
+		So(c2.upstream.(*ldap.Config).UserSearch.BaseDN, ShouldEqual, "ou=people,dc=example,dc=com")
+		So(c2.Provisioning, ShouldResemble, c.Provisioning)
+
+		So(IsRedirectConnector("oidc"), ShouldBeTrue)
+		So(IsRedirectConnector("pydio-saml"), ShouldBeTrue)
+		So(IsRedirectConnector("ldap"), ShouldBeFalse)
+		So(IsRedirectConnector("pydio-api"), ShouldBeFalse)
+	})
+
+	Convey("Test redirect connectors config carries the sub-connector name", t, func() {
+
+		wrapper := &WrapperConfig{Connectors: []ConnectorConfig{
+			{Type: "ldap", Name: "directory", Config: json.RawMessage(`{"host":"ldap.example.com:389"}`)},
+			{Type: "oidc", Name: "corporate", Config: json.RawMessage(`{"issuer":"https://accounts.example.com"}`)},
+		}}
+		externals := wrapper.ExternalConnectors()
+		So(externals, ShouldHaveLength, 1)
+		c := NewExternalConfig("oidc")
+		So(json.Unmarshal(externals[0].Config, c), ShouldBeNil)
+		So(c.AuthSource, ShouldEqual, "corporate")
+		So(c.Provisioning.LoginAttribute, ShouldEqual, LoginAttributeEmail)
+	})
+
+}
+
+func TestProvisionerMapping(t *testing.T) {
+
+	c := NewExternalConfig("oidc")
+	c.Provisioning.MappingRules = []auth.MappingRule{
+		{LeftAttribute: "Groups", RightAttribute: "Roles", RolePrefix: "ext_", RuleString: "preg:^staff"},
+		{LeftAttribute: "Groups", RightAttribute: "GroupPath", RuleString: "staff-paris"},
+		{LeftAttribute: "Username", RightAttribute: "company"},
+	}
+	c.AuthSource = "corporate"
+	p := &provisioner{config: *c}
+	ident := connector.Identity{
+		UserID:        "12345",
+		Username:      "Jane Doe",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Groups:        []string{"cn=staff-paris,ou=groups,dc=example,dc=com", "staff-all", "visitors"},
+	}
+
+	Convey("Test identity mapping", t, func() {
+
+		So(p.scopes(connector.Scopes{}).Groups, ShouldBeTrue)
+
+		user := p.mapIdentity(ident)
+		So(user.Login, ShouldEqual, "jane@example.com")
+		So(user.GroupPath, ShouldEqual, "/staff-paris")
+		So(user.Attributes["displayName"], ShouldEqual, "Jane Doe")
+		So(user.Attributes["email"], ShouldEqual, "jane@example.com")
+		So(user.Attributes["company"], ShouldEqual, "Jane Doe")
+		So(user.Attributes["profile"], ShouldEqual, "standard")
+		So(user.Attributes[AuthSourceAttribute], ShouldEqual, "corporate")
+		So(user.Roles, ShouldHaveLength, 2)
+		So(user.Roles[0].Uuid, ShouldEqual, "ext_staff-paris")
+		So(user.Roles[1].Uuid, ShouldEqual, "ext_staff-all")
+
+	})
+
+	Convey("Test merge on existing user", t, func() {
+
+		existing := &idm.User{
+			Login: "jane@example.com",
+			Attributes: map[string]string{
+				"profile":     "admin",
+				"displayName": "Jane Doe",
+				"email":       "jane@example.com",
+				"company":     "Jane Doe",
+			},
+			Roles: []*idm.Role{
+				{Uuid: "ROOT_GROUP", AutoApplies: []string{"standard"}},
+				{Uuid: "manual-role"},
+				{Uuid: "ext_staff-all"},
+				{Uuid: "ext_former-team"},
+			},
+		}
+		So(p.merge(existing, p.mapIdentity(ident)), ShouldBeTrue)
+		So(existing.Attributes["profile"], ShouldEqual, "admin")
+		var uuids []string
+		for _, r := range existing.Roles {
+			uuids = append(uuids, r.Uuid)
+		}
+		So(uuids, ShouldResemble, []string{"manual-role", "ext_staff-all", "ext_staff-paris"})
+
+		So(p.merge(existing, p.mapIdentity(ident)), ShouldBeFalse)
+		So(existing.Attributes, ShouldNotContainKey, AuthSourceAttribute)
+
+	})
+
+	Convey("Test existing users are only linked to their own connector", t, func() {
+
+		So(p.linked(&idm.User{Login: "jane@example.com", Attributes: map[string]string{AuthSourceAttribute: "corporate"}}), ShouldBeTrue)
+		So(p.linked(&idm.User{Login: "jane@example.com", Attributes: map[string]string{AuthSourceAttribute: "partners"}}), ShouldBeFalse)
+		So(p.linked(&idm.User{Login: "jane@example.com"}), ShouldBeFalse)
+
+		unnamed := &provisioner{config: *NewExternalConfig("oidc")}
+		So(unnamed.linked(&idm.User{Login: "jane@example.com", Attributes: map[string]string{}}), ShouldBeFalse)
+
+	})
+
+	Convey("Test unverified emails are not provisioned", t, func() {
+
+		unverified := ident
+		unverified.EmailVerified = false
+		_, err := p.provision(context.Background(), unverified)
+		So(err, ShouldNotBeNil)
+		So(p.mapIdentity(unverified).Attributes, ShouldNotContainKey, "email")
+
+	})
+
+}
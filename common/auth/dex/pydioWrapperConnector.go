@@ -90,7 +90,8 @@ func (p *pydioWrapperConnector) Login(ctx context.Context, s connector.Scopes, u
 
 	for _, pydioConnector := range listConnector {
 
-		if _, ok, err := pydioConnector.Connector.Login(ctx, s, username, password); !ok || err != nil {
+		ident, ok, err := pydioConnector.Connector.Login(ctx, s, username, password)
+		if !ok || err != nil {
 			log.Logger(ctx).Debug("Login request failed on sub-connector", zap.String(common.KEY_USERNAME, username), zap.String(common.KEY_CONNECTOR, pydioConnector.Name), zap.Error(err))
 			continue
 		}
@@ -98,7 +99,11 @@ func (p *pydioWrapperConnector) Login(ctx context.Context, s connector.Scopes, u
 		log.Logger(ctx).Debug("Login request success on sub-connector", zap.String(common.KEY_USERNAME, username), zap.String(common.KEY_CONNECTOR, pydioConnector.Name))
 		log.Auditer(ctx).Info(fmt.Sprintf("User %s logged in via %s sub-connector", username, pydioConnector.Name), log.GetAuditId(common.AUDIT_LOGIN_SUCCEED), zap.String(common.KEY_USERNAME, username), zap.String(common.KEY_CONNECTOR, pydioConnector.Name))
 
-		return p.IdentityFromUserName(ctx, connector.Identity{Username: username, AuthSource: pydioConnector.Name})
+		// External connectors may provision the user under another login
+		if ident.Username == "" {
+			ident.Username = username
+		}
+		return p.IdentityFromUserName(ctx, connector.Identity{Username: ident.Username, AuthSource: pydioConnector.Name})
 	}
 
 	log.Auditer(ctx).Error("Login attempt failed for "+username, log.GetAuditId(common.AUDIT_LOGIN_FAILED), zap.String(common.KEY_USERNAME, username))
@@ -148,6 +153,10 @@ func (p *pydioWrapperConnector) IdentityFromUserName(ctx context.Context, input
 // CheckConnectionPolicyForUser retrieves all subjects linked to current context and user.
 // It then checks all relevant policies. If one has deny, it returns false.
 func (p *pydioWrapperConnector) CheckConnectionPolicyForUser(ctx context.Context, user *idm.User) bool {
+	return checkConnectionPolicy(ctx, user)
+}
+
+func checkConnectionPolicy(ctx context.Context, user *idm.User) bool {
 
 	cli := idm.NewPolicyEngineServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_POLICY, defaults.NewClient())
 	policyContext := make(map[string]string)
@@ -222,25 +231,60 @@ func (p *pydioWrapperConnector) getConnectorList(logger logrus.FieldLogger) (con
 	sort.Sort(byID(p.Connectors))
 	// end sort
 	for _, connConfig := range p.Connectors {
+		if IsRedirectConnector(connConfig.Type) {
+			// Exposed as separate connectors, see ExternalConnectors
+			continue
+		}
 		connConnector, er := createConnector(logger, connConfig.Type, connConfig)
 		if er != nil {
 			logger.Errorf(er.Error())
+			continue
+		}
+		passwordConnector, ok := connConnector.(interface {
+			connector.Connector
+			connector.PasswordConnector
+			connector.RefreshConnector
+		})
+		if !ok {
+			logger.Errorf("connector %d - %s does not support password login", connConfig.ID, connConfig.Name)
+			continue
 		}
 		connConnectorFull := ConnectorList{
-			Type: connConfig.Type,
-			Name: connConfig.Name,
-			ID:   connConfig.ID,
-			Connector: connConnector.(interface {
-				connector.Connector
-				connector.PasswordConnector
-				connector.RefreshConnector
-			}),
+			Type:      connConfig.Type,
+			Name:      connConfig.Name,
+			ID:        connConfig.ID,
+			Connector: passwordConnector,
 		}
 		connectorList = append(connectorList, connConnectorFull)
 	}
 	return connectorList, nil
 }
 
+// ExternalConnectors lists the sub-connectors that redirect users to an external provider.
+// They cannot be used by the password login of the wrapper, so they are declared to dex as
+// separate connectors, with a type prefixed by ExternalConnectorTypePrefix. The name of the
+// sub-connector is passed in their config, to link the provisioned users to it.
+func (c *WrapperConfig) ExternalConnectors() (configs []ConnectorConfig) {
+	for _, connConfig := range c.Connectors {
+		if IsRedirectConnector(connConfig.Type) {
+			m := make(map[string]interface{})
+			if connConfig.Config != nil {
+				if err := json.Unmarshal(connConfig.Config, &m); err != nil {
+					continue
+				}
+			}
+			m["authSource"] = connConfig.Name
+			data, err := json.Marshal(m)
+			if err != nil {
+				continue
+			}
+			connConfig.Config = data
+			configs = append(configs, connConfig)
+		}
+	}
+	return
+}
+
 type ConnectorList struct {
 	Type      string `json:"type"`
 	Name      string `json:"name"`
@@ -282,6 +326,9 @@ func createConnector(logger logrus.FieldLogger, connectorType string, connectorC
 				return c, fmt.Errorf("parse connector config: %v", err)
 			}
 		}
+		if ext, ok := connConfig.(*ExternalConfig); ok {
+			ext.AuthSource = connectorConfig.Name
+		}
 
 		c, err := connConfig.Open(logger)
 		if err != nil {
@@ -171,3 +171,16 @@ func (m MappingRule) FilterList(list []string, strs []string) []string {
 	}
 	return strs
 }
+
+// Apply runs the values read from the LeftAttribute through the rule: values are trimmed,
+// distinguished names are converted to names, then they are filtered by the RuleString
+// (a preg: expression or a comma-separated list) and prefixed with the RolePrefix.
+func (m MappingRule) Apply(values []string) []string {
+	values = m.ConvertDNtoName(m.SanitizeValues(values))
+	if strings.HasPrefix(m.RuleString, "preg:") {
+		values = m.FilterPreg(m.RuleString, values)
+	} else if m.RuleString != "" {
+		values = m.FilterList(m.SanitizeValues(strings.Split(m.RuleString, ",")), values)
+	}
+	return m.AddPrefix(m.RolePrefix, values)
+}
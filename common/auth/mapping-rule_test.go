@@ -119,6 +119,23 @@ func TestMappingRule_FilterPreg(t *testing.T) {
 	}
 }
 
+func TestMappingRule_Apply(t *testing.T) {
+	m := getMappingRuleConfig()
+	m.RuleString = "preg:^teac*"
+	m.RolePrefix = "ldap_"
+	values := m.Apply([]string{"cn=teachers,ou=groups,dc=vpydio,dc=fr", " students", "teaching "})
+	if !testEq([]string{"ldap_teachers", "ldap_teaching"}, values) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	m.RuleString = "students, staff"
+	m.RolePrefix = ""
+	values = m.Apply([]string{"cn=teachers,ou=groups,dc=vpydio,dc=fr", " students", "staff"})
+	if !testEq([]string{"students", "staff"}, values) {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
 func TestMappingRule_IsDnFormat(t *testing.T) {
 	m := getMappingRuleConfig()
 	DN := "cn=test,cn=abc,dc=com,dc=test"
@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/totp"
+	"github.com/pydio/cells/common/crypto"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
+)
+
+const (
+	// SecondFactorKeyID is the ID of the user key storing the TOTP enrollment of a user.
+	SecondFactorKeyID = "second-factor.totp"
+	// SecondFactorPolicyAction is checked against the "oidc" resource: if a policy allows it
+	// for one of the user subjects, the user must log in with a second factor.
+	SecondFactorPolicyAction = "require_second_factor"
+	// SecondFactorIssuer is displayed by authenticator applications.
+	SecondFactorIssuer = "Pydio Cells"
+
+	recoveryCodesCount = 10
+	// SecondFactorMaxFailures is the number of invalid codes after which the second factor of a
+	// user is locked, and after which a pending login request is invalidated.
+	SecondFactorMaxFailures = 5
+	secondFactorLockTime    = 15 * time.Minute
+)
+
+var (
+	ErrSecondFactorNotEnrolled = errors.New("second factor is not enrolled")
+	ErrSecondFactorEnrolled    = errors.New("second factor is already enrolled")
+	ErrInvalidSecondFactor     = errors.New("invalid second factor code")
+	ErrSecondFactorLocked      = errors.New("too many invalid second factor codes, try again later")
+)
+
+// SecondFactor is the TOTP enrollment of a user. It is stored in the user keys store, sealed with
+// the master password, as the secret must be readable by the server.
+type SecondFactor struct {
+	Secret        string   `json:"secret"`
+	Confirmed     bool     `json:"confirmed"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+	LastStep      int64    `json:"lastStep,omitempty"`
+	Failures      int      `json:"failures,omitempty"`
+	LockedUntil   int64    `json:"lockedUntil,omitempty"`
+}
+
+// locked tells whether too many invalid codes were recently sent for this user.
+func (sf *SecondFactor) locked(now time.Time) bool {
+	return sf.LockedUntil > now.Unix()
+}
+
+// failed counts an invalid code, and locks the second factor once SecondFactorMaxFailures is reached.
+func (sf *SecondFactor) failed(now time.Time) {
+	sf.Failures++
+	if sf.Failures >= SecondFactorMaxFailures {
+		sf.Failures = 0
+		sf.LockedUntil = now.Add(secondFactorLockTime).Unix()
+	}
+}
+
+// LoadSecondFactor finds the second factor enrollment of a user. It returns nil if there is none.
+func LoadSecondFactor(ctx context.Context, login string) (*SecondFactor, error) {
+	password, err := secondFactorPassword()
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := userKeyStoreClient().GetKey(ctx, &encryption.GetKeyRequest{
+		Owner:       login,
+		KeyID:       SecondFactorKeyID,
+		StrPassword: password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Key == nil {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(rsp.Key.Content)
+	if err != nil {
+		return nil, err
+	}
+	sf := &SecondFactor{}
+	if err := json.Unmarshal(data, sf); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// EnrollSecondFactor generates a new TOTP secret for a user. The enrollment must then be confirmed
+// with a first valid code. It returns the secret and the URI to be imported in an authenticator application.
+func EnrollSecondFactor(ctx context.Context, login string) (string, string, error) {
+	existing, err := LoadSecondFactor(ctx, login)
+	if err != nil {
+		return "", "", err
+	}
+	if existing != nil && existing.Confirmed {
+		return "", "", ErrSecondFactorEnrolled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := storeSecondFactor(ctx, login, &SecondFactor{Secret: secret}); err != nil {
+		return "", "", err
+	}
+	return secret, totp.KeyURI(SecondFactorIssuer, login, secret), nil
+}
+
+// ConfirmSecondFactor activates a pending enrollment if the code is valid. It returns the
+// recovery codes in clear: they are only stored hashed and cannot be retrieved afterward.
+func ConfirmSecondFactor(ctx context.Context, login string, code string) ([]string, error) {
+	sf, err := LoadSecondFactor(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if sf == nil {
+		return nil, ErrSecondFactorNotEnrolled
+	}
+	if sf.Confirmed {
+		return nil, ErrSecondFactorEnrolled
+	}
+	now := time.Now()
+	if sf.locked(now) {
+		return nil, ErrSecondFactorLocked
+	}
+	step, ok := totp.Validate(sf.Secret, code, now)
+	if !ok {
+		return nil, invalidSecondFactor(ctx, login, sf, now)
+	}
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+	sf.Confirmed = true
+	sf.LastStep = step
+	sf.Failures, sf.LockedUntil = 0, 0
+	sf.RecoveryCodes = nil
+	for _, c := range codes {
+		sf.RecoveryCodes = append(sf.RecoveryCodes, totp.HashRecoveryCode(c))
+	}
+	if err := storeSecondFactor(ctx, login, sf); err != nil {
+		return nil, err
+	}
+	log.Auditer(ctx).Info(fmt.Sprintf("User %s enrolled a second authentication factor", login), zap.String(common.KEY_USERNAME, login))
+	return codes, nil
+}
+
+// VerifySecondFactor checks a TOTP code, or else a recovery code, for a user with a confirmed enrollment.
+// A TOTP code cannot be used twice, and a recovery code is removed once used. After SecondFactorMaxFailures
+// invalid codes, ErrSecondFactorLocked is returned for a while, whatever the code.
+func VerifySecondFactor(ctx context.Context, login string, code string) error {
+	sf, err := LoadSecondFactor(ctx, login)
+	if err != nil {
+		return err
+	}
+	if sf == nil || !sf.Confirmed {
+		return ErrSecondFactorNotEnrolled
+	}
+	now := time.Now()
+	if sf.locked(now) {
+		return ErrSecondFactorLocked
+	}
+	if step, ok := totp.Validate(sf.Secret, code, now); ok {
+		if step <= sf.LastStep {
+			return invalidSecondFactor(ctx, login, sf, now)
+		}
+		sf.LastStep = step
+		sf.Failures, sf.LockedUntil = 0, 0
+		return storeSecondFactor(ctx, login, sf)
+	}
+	if remaining, ok := totp.ConsumeRecoveryCode(sf.RecoveryCodes, code); ok {
+		sf.RecoveryCodes = remaining
+		sf.Failures, sf.LockedUntil = 0, 0
+		if err := storeSecondFactor(ctx, login, sf); err != nil {
+			return err
+		}
+		log.Auditer(ctx).Info(fmt.Sprintf("User %s used a recovery code, %d left", login, len(remaining)), zap.String(common.KEY_USERNAME, login))
+		return nil
+	}
+	return invalidSecondFactor(ctx, login, sf, now)
+}
+
+// invalidSecondFactor stores the failure of a user and returns the error to send back.
+func invalidSecondFactor(ctx context.Context, login string, sf *SecondFactor, now time.Time) error {
+	sf.failed(now)
+	if err := storeSecondFactor(ctx, login, sf); err != nil {
+		return err
+	}
+	if sf.locked(now) {
+		log.Auditer(ctx).Error(fmt.Sprintf("Second authentication factor of user %s locked after %d invalid codes", login, SecondFactorMaxFailures), zap.String(common.KEY_USERNAME, login))
+		return ErrSecondFactorLocked
+	}
+	return ErrInvalidSecondFactor
+}
+
+// DisableSecondFactor removes the second factor enrollment of a user.
+func DisableSecondFactor(ctx context.Context, login string) error {
+	if _, err := userKeyStoreClient().DeleteUserKey(ctx, &encryption.DeleteUserKeyRequest{Owner: login, KeyID: SecondFactorKeyID}); err != nil {
+		return err
+	}
+	log.Auditer(ctx).Info(fmt.Sprintf("Second authentication factor removed for user %s", login), zap.String(common.KEY_USERNAME, login))
+	return nil
+}
+
+// SecondFactorRequired checks the policies to know whether this user must log in with a second factor.
+func SecondFactorRequired(ctx context.Context, user *idm.User) bool {
+	cli := idm.NewPolicyEngineServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_POLICY, defaults.NewClient())
+	policyContext := make(map[string]string)
+	utils.PolicyContextFromMetadata(policyContext, ctx)
+	resp, err := cli.IsAllowed(ctx, &idm.PolicyEngineRequest{
+		Subjects: utils.PolicyRequestSubjectsFromUser(user),
+		Resource: "oidc",
+		Action:   SecondFactorPolicyAction,
+		Context:  policyContext,
+	})
+	if err != nil {
+		log.Logger(ctx).Error("cannot check second factor policy", zap.String(common.KEY_USERNAME, user.Login), zap.Error(err))
+		return false
+	}
+	return resp.Allowed
+}
+
+func storeSecondFactor(ctx context.Context, login string, sf *SecondFactor) error {
+	password, err := secondFactorPassword()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sf)
+	if err != nil {
+		return err
+	}
+	_, err = userKeyStoreClient().AddKey(ctx, &encryption.AddKeyRequest{
+		Key: &encryption.Key{
+			Owner:        login,
+			ID:           SecondFactorKeyID,
+			Label:        "Second authentication factor",
+			Content:      base64.StdEncoding.EncodeToString(data),
+			CreationDate: int32(time.Now().Unix()),
+		},
+		StrPassword: password,
+	})
+	return err
+}
+
+func secondFactorPassword() (string, error) {
+	b, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, true)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
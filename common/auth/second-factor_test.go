@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package auth
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSecondFactorLock(t *testing.T) {
+
+	Convey("Second factor is locked after too many failures", t, func() {
+		now := time.Now()
+		sf := &SecondFactor{Confirmed: true}
+		for i := 0; i < SecondFactorMaxFailures-1; i++ {
+			sf.failed(now)
+		}
+		So(sf.locked(now), ShouldBeFalse)
+		So(sf.Failures, ShouldEqual, SecondFactorMaxFailures-1)
+
+		sf.failed(now)
+		So(sf.locked(now), ShouldBeTrue)
+		So(sf.Failures, ShouldEqual, 0)
+		So(sf.locked(now.Add(secondFactorLockTime+time.Second)), ShouldBeFalse)
+	})
+}
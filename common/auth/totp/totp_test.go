@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCode(t *testing.T) {
+
+	// Test vectors from RFC 6238, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	Convey("Test RFC 6238 vectors", t, func() {
+
+		for _, c := range []struct {
+			time int64
+			code string
+		}{
+			{time: 59, code: "287082"},
+			{time: 1111111109, code: "081804"},
+			{time: 1234567890, code: "005924"},
+			{time: 2000000000, code: "279037"},
+		} {
+			code, err := Code(secret, Step(time.Unix(c.time, 0)))
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, c.code)
+		}
+	})
+
+	Convey("Test validation window", t, func() {
+
+		now := time.Unix(1234567890, 0)
+		step, ok := Validate(secret, "005924", now)
+		So(ok, ShouldBeTrue)
+		So(step, ShouldEqual, Step(now))
+
+		_, ok = Validate(secret, "005924", now.Add(Period*time.Second))
+		So(ok, ShouldBeTrue)
+		_, ok = Validate(secret, "005924", now.Add(3*Period*time.Second))
+		So(ok, ShouldBeFalse)
+		_, ok = Validate(secret, "123", now)
+		So(ok, ShouldBeFalse)
+		_, ok = Validate("not base32!", "005924", now)
+		So(ok, ShouldBeFalse)
+
+	})
+
+	Convey("Test generated secret and URI", t, func() {
+
+		s, err := GenerateSecret()
+		So(err, ShouldBeNil)
+		So(s, ShouldHaveLength, 32)
+		code, err := Code(s, Step(time.Now()))
+		So(err, ShouldBeNil)
+		_, ok := Validate(s, code, time.Now())
+		So(ok, ShouldBeTrue)
+
+		uri := KeyURI("Pydio Cells", "admin", s)
+		So(strings.HasPrefix(uri, "otpauth://totp/Pydio%20Cells:admin?"), ShouldBeTrue)
+		So(uri, ShouldContainSubstring, "secret="+s)
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	Convey("Test recovery codes are consumed once", t, func() {
+
+		codes, err := GenerateRecoveryCodes(3)
+		So(err, ShouldBeNil)
+		So(codes, ShouldHaveLength, 3)
+		var hashes []string
+		for _, c := range codes {
+			So(c, ShouldHaveLength, 11)
+			hashes = append(hashes, HashRecoveryCode(c))
+		}
+
+		remaining, ok := ConsumeRecoveryCode(hashes, strings.ToUpper(codes[1]))
+		So(ok, ShouldBeTrue)
+		So(remaining, ShouldHaveLength, 2)
+		So(hashes, ShouldHaveLength, 3)
+
+		_, ok = ConsumeRecoveryCode(remaining, codes[1])
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package chunks provides a content-addressed store for file versions. Contents are cut into
+// content-defined chunks, that are stored once whatever the number of versions or files
+// referencing them, and each version is described by a manifest listing its chunks.
+package chunks
+
+import (
+	"io"
+	"math/bits"
+)
+
+// Params define the sizes of the chunks produced by a Chunker.
+type Params struct {
+	Min int
+	Avg int
+	Max int
+}
+
+// DefaultParams produces chunks of 1MB on average, between 256KB and 4MB.
+var DefaultParams = Params{
+	Min: 256 * 1024,
+	Avg: 1024 * 1024,
+	Max: 4 * 1024 * 1024,
+}
+
+// gear is the table of random values used by the rolling hash. It is generated from a fixed
+// seed, as boundaries must stay identical across processes to deduplicate chunks.
+var gear [256]uint64
+
+func init() {
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range gear {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// Chunker cuts a stream into content-defined chunks, using a gear-based rolling hash with
+// normalized chunking: a stricter mask is used before the average size, and a looser one after.
+// An insertion or a deletion in a file thus only changes the chunks around the modification.
+type Chunker struct {
+	reader io.Reader
+	params Params
+	maskS  uint64
+	maskL  uint64
+	buf    []byte
+	start  int
+	end    int
+	eof    bool
+}
+
+// NewChunker creates a Chunker reading from reader.
+func NewChunker(reader io.Reader, params Params) *Chunker {
+	b := bits.Len(uint(params.Avg)) - 1
+	return &Chunker{
+		reader: reader,
+		params: params,
+		maskS:  mask(b + 1),
+		maskL:  mask(b - 1),
+		buf:    make([]byte, params.Max),
+	}
+}
+
+// mask returns a mask with n bits set, taken from the high bits of the hash that depend on a wider window.
+func mask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return ((uint64(1) << uint(n)) - 1) << uint(64-n)
+}
+
+// Next returns the next chunk, or io.EOF when the stream is exhausted. The returned slice is only
+// valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.end == c.start {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// fill makes sure that the buffer contains at least Max bytes, unless the end of stream is reached.
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.params.Max {
+		return nil
+	}
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	for c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut finds the length of the next chunk at the beginning of data.
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.params.Min {
+		return n
+	}
+	if n > c.params.Max {
+		n = c.params.Max
+	}
+	normal := c.params.Avg
+	if normal > n {
+		normal = n
+	}
+	var h uint64
+	i := c.params.Min
+	for ; i < normal; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
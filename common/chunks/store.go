@@ -0,0 +1,347 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package chunks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pydio/minio-go"
+)
+
+const (
+	// ChunksPrefix is the prefix of chunk objects, followed by the sha256 of their content.
+	ChunksPrefix = "chunks/"
+	// ManifestsPrefix is the prefix of manifest objects, followed by the name of the version.
+	ManifestsPrefix = "manifests/"
+	// collectBatch is the number of chunks removed by Collect between two checks of the manifests.
+	collectBatch = 100
+)
+
+var (
+	bucketLocks     = make(map[string]*sync.RWMutex)
+	bucketLocksLock sync.Mutex
+)
+
+// ObjectClient is the subset of the minio Core API used by the Store.
+type ObjectClient interface {
+	PutObject(bucket, object string, data io.Reader, size int64, md5Sum, sha256Sum []byte, metadata map[string]string) (minio.ObjectInfo, error)
+	GetObject(bucket, object string, opts minio.GetObjectOptions) (io.ReadCloser, minio.ObjectInfo, error)
+	StatObject(bucket, object string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	RemoveObject(bucket, object string) error
+	ListObjects(bucket, prefix, marker, delimiter string, maxKeys int) (minio.ListBucketResult, error)
+}
+
+// Chunk is a reference to a stored chunk.
+type Chunk struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// Manifest lists the chunks composing a version.
+type Manifest struct {
+	Size   int64    `json:"size"`
+	Chunks []*Chunk `json:"chunks"`
+}
+
+// Store saves versions as manifests and deduplicated chunks in a bucket.
+type Store struct {
+	Client ObjectClient
+	Bucket string
+	Params Params
+}
+
+// NewStore creates a Store with the default chunking parameters.
+func NewStore(client ObjectClient, bucket string) *Store {
+	return &Store{Client: client, Bucket: bucket, Params: DefaultParams}
+}
+
+// Write cuts the content of reader into chunks, uploads the chunks that are not already stored and
+// saves the manifest under the given name. It returns the manifest and the number of bytes actually written.
+func (s *Store) Write(name string, reader io.Reader) (*Manifest, int64, error) {
+	// Chunks found in the store must not be collected before the manifest referencing them is saved
+	lock := s.lock()
+	lock.RLock()
+	defer lock.RUnlock()
+	manifest := &Manifest{}
+	var stored int64
+	chunker := NewChunker(reader, s.Params)
+	for {
+		data, err := chunker.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, stored, err
+		}
+		sum := sha256.Sum256(data)
+		chunk := &Chunk{Hash: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+		if exists, e := s.exists(ChunksPrefix + chunk.Hash); e != nil {
+			return nil, stored, e
+		} else if !exists {
+			if _, e := s.Client.PutObject(s.Bucket, ChunksPrefix+chunk.Hash, bytes.NewReader(data), chunk.Size, nil, sum[:], nil); e != nil {
+				return nil, stored, e
+			}
+			stored += chunk.Size
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		manifest.Size += chunk.Size
+	}
+	data, _ := json.Marshal(manifest)
+	if _, err := s.Client.PutObject(s.Bucket, ManifestsPrefix+name, bytes.NewReader(data), int64(len(data)), nil, nil, nil); err != nil {
+		return nil, stored, err
+	}
+	stored += int64(len(data))
+	return manifest, stored, nil
+}
+
+// Manifest loads the manifest saved under a name, or returns nil if there is none.
+func (s *Store) Manifest(name string) (*Manifest, error) {
+	reader, _, err := s.Client.GetObject(s.Bucket, ManifestsPrefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Delete removes a manifest. Chunks are removed later on by Collect, as they may be shared.
+func (s *Store) Delete(name string) error {
+	return s.Client.RemoveObject(s.Bucket, ManifestsPrefix+name)
+}
+
+// Open reassembles the content described by a manifest, starting at offset. A negative
+// or zero length reads until the end.
+func (s *Store) Open(manifest *Manifest, offset int64, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	end := manifest.Size
+	if length > 0 && offset+length < end {
+		end = offset + length
+	}
+	return &reader{store: s, chunks: manifest.Chunks, offset: offset, end: end}, nil
+}
+
+// Collect removes the chunks that are not referenced by any manifest anymore. Chunks modified after
+// the given date are kept, as they may belong to versions that are being written. Writes to the same
+// bucket wait until the collection is done.
+func (s *Store) Collect(before time.Time) (removed []string, err error) {
+	lock := s.lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	candidates := make(map[string]bool)
+	if err := s.list(ChunksPrefix, func(info minio.ObjectInfo) error {
+		if info.LastModified.Before(before) {
+			candidates[strings.TrimPrefix(info.Key, ChunksPrefix)] = true
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]string)
+	if err := s.unmark(candidates, seen); err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for hash := range candidates {
+		hashes = append(hashes, hash)
+	}
+	for i, hash := range hashes {
+		// Manifests may be saved by other processes meanwhile: before each batch of deletions,
+		// read the new ones again and keep the chunks they reference
+		if i%collectBatch == 0 {
+			if e := s.unmark(candidates, seen); e != nil {
+				return removed, e
+			}
+		}
+		if !candidates[hash] {
+			continue
+		}
+		if e := s.Client.RemoveObject(s.Bucket, ChunksPrefix+hash); e != nil {
+			return removed, e
+		}
+		removed = append(removed, hash)
+	}
+	return removed, nil
+}
+
+// unmark removes from candidates the chunks referenced by the manifests, skipping the manifests
+// already read with the same ETag. Read manifests are recorded in seen.
+func (s *Store) unmark(candidates map[string]bool, seen map[string]string) error {
+	return s.list(ManifestsPrefix, func(info minio.ObjectInfo) error {
+		if etag, ok := seen[info.Key]; ok && etag == info.ETag {
+			return nil
+		}
+		seen[info.Key] = info.ETag
+		m, e := s.Manifest(strings.TrimPrefix(info.Key, ManifestsPrefix))
+		if e != nil {
+			return e
+		}
+		if m != nil {
+			for _, c := range m.Chunks {
+				delete(candidates, c.Hash)
+			}
+		}
+		return nil
+	})
+}
+
+// lock returns the lock shared by all the Stores of a bucket. Writes hold it for reading and
+// Collect holds it for writing, so that no chunk is removed while a manifest reusing it is written.
+func (s *Store) lock() *sync.RWMutex {
+	bucketLocksLock.Lock()
+	defer bucketLocksLock.Unlock()
+	l, ok := bucketLocks[s.Bucket]
+	if !ok {
+		l = &sync.RWMutex{}
+		bucketLocks[s.Bucket] = l
+	}
+	return l
+}
+
+func (s *Store) list(prefix string, callback func(info minio.ObjectInfo) error) error {
+	marker := ""
+	for {
+		result, err := s.Client.ListObjects(s.Bucket, prefix, marker, "", 1000)
+		if err != nil {
+			return err
+		}
+		for _, info := range result.Contents {
+			if e := callback(info); e != nil {
+				return e
+			}
+		}
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			return nil
+		}
+		marker = result.NextMarker
+		if marker == "" {
+			marker = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+}
+
+func (s *Store) exists(object string) (bool, error) {
+	if _, err := s.Client.StatObject(s.Bucket, object, minio.StatObjectOptions{}); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func isNotFound(err error) bool {
+	r := minio.ToErrorResponse(err)
+	return r.Code == "NoSuchKey"
+}
+
+// reader lazily opens the chunks covering the requested range.
+type reader struct {
+	store   *Store
+	chunks  []*Chunk
+	offset  int64
+	end     int64
+	pos     int64
+	index   int
+	current io.ReadCloser
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for {
+		if r.offset >= r.end {
+			return 0, io.EOF
+		}
+		if r.current == nil {
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+		}
+		if max := r.end - r.offset; int64(len(p)) > max {
+			p = p[:max]
+		}
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// next opens the chunk containing the current offset, with a range if the offset is inside the chunk.
+func (r *reader) next() error {
+	for ; r.index < len(r.chunks); r.index++ {
+		c := r.chunks[r.index]
+		if r.offset < r.pos+c.Size {
+			opts := minio.GetObjectOptions{}
+			if r.offset > r.pos {
+				if err := opts.SetRange(r.offset-r.pos, c.Size-1); err != nil {
+					return err
+				}
+			}
+			reader, _, err := r.store.Client.GetObject(r.store.Bucket, ChunksPrefix+c.Hash, opts)
+			if err != nil {
+				return err
+			}
+			r.current = reader
+			r.pos += c.Size
+			r.index++
+			return nil
+		}
+		r.pos += c.Size
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func (r *reader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package chunks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pydio/minio-go"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var _ ObjectClient = (*minio.Core)(nil)
+
+var testParams = Params{Min: 512, Avg: 2048, Max: 8192}
+
+// memoryClient is an in-memory ObjectClient.
+type memoryClient struct {
+	sync.Mutex
+	objects map[string][]byte
+	mtimes  map[string]time.Time
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{objects: make(map[string][]byte), mtimes: make(map[string]time.Time)}
+}
+
+func (m *memoryClient) PutObject(bucket, object string, data io.Reader, size int64, md5Sum, sha256Sum []byte, metadata map[string]string) (minio.ObjectInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	b, _ := ioutil.ReadAll(data)
+	m.objects[object] = b
+	m.mtimes[object] = time.Now()
+	return minio.ObjectInfo{Key: object, Size: int64(len(b))}, nil
+}
+
+func (m *memoryClient) GetObject(bucket, object string, opts minio.GetObjectOptions) (io.ReadCloser, minio.ObjectInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	b, ok := m.objects[object]
+	if !ok {
+		return nil, minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey"}
+	}
+	if r := opts.Header().Get("Range"); r != "" {
+		var start, end int64
+		fmt.Sscanf(r, "bytes=%d-%d", &start, &end)
+		b = b[start : end+1]
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), minio.ObjectInfo{Key: object, Size: int64(len(b))}, nil
+}
+
+func (m *memoryClient) StatObject(bucket, object string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	b, ok := m.objects[object]
+	if !ok {
+		return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey"}
+	}
+	return minio.ObjectInfo{Key: object, Size: int64(len(b))}, nil
+}
+
+func (m *memoryClient) RemoveObject(bucket, object string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.objects, object)
+	return nil
+}
+
+func (m *memoryClient) ListObjects(bucket, prefix, marker, delimiter string, maxKeys int) (result minio.ListBucketResult, err error) {
+	m.Lock()
+	defer m.Unlock()
+	var keys []string
+	for k := range m.objects {
+		if strings.HasPrefix(k, prefix) && k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	// Use small pages to exercise pagination
+	if len(keys) > 2 {
+		keys = keys[:2]
+		result.IsTruncated = true
+	}
+	for _, k := range keys {
+		result.Contents = append(result.Contents, minio.ObjectInfo{Key: k, Size: int64(len(m.objects[k])), LastModified: m.mtimes[k], ETag: fmt.Sprintf("%d", m.mtimes[k].UnixNano())})
+	}
+	return
+}
+
+// collectingClient starts a collection in parallel once a chunk was found, and
+// leaves it some time to run.
+type collectingClient struct {
+	*memoryClient
+	collect func()
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func (c *collectingClient) StatObject(bucket, object string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	info, err := c.memoryClient.StatObject(bucket, object, opts)
+	if c.collect != nil && err == nil && strings.HasPrefix(object, ChunksPrefix) {
+		c.once.Do(func() {
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				c.collect()
+			}()
+			time.Sleep(100 * time.Millisecond)
+		})
+	}
+	return info, err
+}
+
+func (m *memoryClient) count(prefix string) (c int) {
+	m.Lock()
+	defer m.Unlock()
+	for k := range m.objects {
+		if strings.HasPrefix(k, prefix) {
+			c++
+		}
+	}
+	return
+}
+
+func randomData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunkSizes(data []byte) (sizes []int) {
+	chunker := NewChunker(bytes.NewReader(data), testParams)
+	for {
+		c, err := chunker.Next()
+		if err != nil {
+			return
+		}
+		sizes = append(sizes, len(c))
+	}
+}
+
+func TestChunker(t *testing.T) {
+
+	Convey("Cut data in content-defined chunks", t, func() {
+		data := randomData(200*1024, 1)
+		sizes := chunkSizes(data)
+		var total int
+		for i, s := range sizes {
+			total += s
+			So(s, ShouldBeLessThanOrEqualTo, testParams.Max)
+			if i < len(sizes)-1 {
+				So(s, ShouldBeGreaterThan, testParams.Min)
+			}
+		}
+		So(total, ShouldEqual, len(data))
+		So(len(sizes), ShouldBeGreaterThan, 200*1024/testParams.Max)
+		So(chunkSizes(data), ShouldResemble, sizes)
+
+		So(chunkSizes(nil), ShouldBeEmpty)
+		So(chunkSizes([]byte("small")), ShouldResemble, []int{5})
+	})
+
+	Convey("Insertions only change neighbouring chunks", t, func() {
+		data := randomData(200*1024, 2)
+		modified := append(append(append([]byte{}, data[:100*1024]...), []byte("inserted bytes")...), data[100*1024:]...)
+		before := chunkSizes(data)
+		after := chunkSizes(modified)
+		common := 0
+		for i := 0; i < len(before) && i < len(after) && before[i] == after[i]; i++ {
+			common++
+		}
+		for i := 1; i <= len(before) && i <= len(after) && before[len(before)-i] == after[len(after)-i]; i++ {
+			common++
+		}
+		So(common, ShouldBeGreaterThanOrEqualTo, len(before)-3)
+	})
+}
+
+func TestStore(t *testing.T) {
+
+	Convey("Store versions with deduplicated chunks", t, func() {
+		client := newMemoryClient()
+		store := &Store{Client: client, Bucket: "versions", Params: testParams}
+
+		v1 := randomData(100*1024, 3)
+		m1, stored1, err := store.Write("node__v1", bytes.NewReader(v1))
+		So(err, ShouldBeNil)
+		So(m1.Size, ShouldEqual, len(v1))
+		So(stored1, ShouldBeGreaterThan, len(v1))
+		chunksV1 := client.count(ChunksPrefix)
+
+		// Same content is not stored twice
+		_, stored, err := store.Write("other__v1", bytes.NewReader(v1))
+		So(err, ShouldBeNil)
+		So(stored, ShouldBeLessThan, len(v1)/10)
+		So(client.count(ChunksPrefix), ShouldEqual, chunksV1)
+
+		// Small modification only stores a few chunks
+		v2 := append([]byte{}, v1...)
+		copy(v2[50*1024:], []byte("modified"))
+		_, stored2, err := store.Write("node__v2", bytes.NewReader(v2))
+		So(err, ShouldBeNil)
+		So(stored2, ShouldBeLessThan, 3*testParams.Max+len(v1)/10)
+
+		m2, err := store.Manifest("node__v2")
+		So(err, ShouldBeNil)
+		reader, err := store.Open(m2, 0, -1)
+		So(err, ShouldBeNil)
+		read, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		So(bytes.Equal(read, v2), ShouldBeTrue)
+
+		// Range reads across chunk boundaries
+		reader, _ = store.Open(m2, 49*1024, 3000)
+		read, _ = ioutil.ReadAll(reader)
+		reader.Close()
+		So(bytes.Equal(read, v2[49*1024:49*1024+3000]), ShouldBeTrue)
+		reader, _ = store.Open(m2, int64(len(v2)-10), 100)
+		read, _ = ioutil.ReadAll(reader)
+		So(bytes.Equal(read, v2[len(v2)-10:]), ShouldBeTrue)
+
+		missing, err := store.Manifest("node__unknown")
+		So(err, ShouldBeNil)
+		So(missing, ShouldBeNil)
+	})
+
+	Convey("Collect unreferenced chunks", t, func() {
+		client := newMemoryClient()
+		store := &Store{Client: client, Bucket: "versions", Params: testParams}
+		v1 := randomData(50*1024, 4)
+		v2 := randomData(50*1024, 5)
+		store.Write("node__v1", bytes.NewReader(v1))
+		store.Write("node__v2", bytes.NewReader(v2))
+		store.Write("copy__v1", bytes.NewReader(v1))
+		total := client.count(ChunksPrefix)
+
+		// Recent chunks are kept
+		So(store.Delete("node__v2"), ShouldBeNil)
+		removed, err := store.Collect(time.Now().Add(-time.Hour))
+		So(err, ShouldBeNil)
+		So(removed, ShouldBeEmpty)
+
+		removed, err = store.Collect(time.Now().Add(time.Second))
+		So(err, ShouldBeNil)
+		So(removed, ShouldNotBeEmpty)
+		So(client.count(ChunksPrefix), ShouldEqual, total-len(removed))
+
+		// Shared chunks are kept as long as one manifest references them
+		So(store.Delete("node__v1"), ShouldBeNil)
+		removed, _ = store.Collect(time.Now().Add(time.Second))
+		So(removed, ShouldBeEmpty)
+		m, _ := store.Manifest("copy__v1")
+		reader, _ := store.Open(m, 0, -1)
+		read, _ := ioutil.ReadAll(reader)
+		So(bytes.Equal(read, v1), ShouldBeTrue)
+
+		So(store.Delete("copy__v1"), ShouldBeNil)
+		store.Collect(time.Now().Add(time.Second))
+		So(client.count(ChunksPrefix), ShouldEqual, 0)
+	})
+
+	Convey("Collect while a version reusing old chunks is written", t, func() {
+		client := &collectingClient{memoryClient: newMemoryClient()}
+		store := &Store{Client: client, Bucket: "concurrent", Params: testParams}
+		v1 := randomData(50*1024, 6)
+		store.Write("node__v1", bytes.NewReader(v1))
+		So(store.Delete("node__v1"), ShouldBeNil)
+
+		// Every chunk is old enough to be collected: a collection starts as soon as
+		// the write finds the first existing chunk
+		client.collect = func() { store.Collect(time.Now().Add(time.Hour)) }
+		_, _, err := store.Write("copy__v1", bytes.NewReader(v1))
+		So(err, ShouldBeNil)
+		client.wg.Wait()
+
+		m, _ := store.Manifest("copy__v1")
+		So(m, ShouldNotBeNil)
+		reader, _ := store.Open(m, 0, -1)
+		read, err := ioutil.ReadAll(reader)
+		So(err, ShouldBeNil)
+		So(bytes.Equal(read, v1), ShouldBeTrue)
+	})
+}
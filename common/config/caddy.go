@@ -56,6 +56,8 @@ type CaddyTemplateConf struct {
 	WOPI *url.URL
 	// Collabora definition from plugins
 	Collabora *url.URL
+	// Federated sharing endpoint
+	OCM *url.URL
 
 	// FPM connection, either an URL or a socket file path
 	Fpm string
@@ -99,6 +101,18 @@ var (
 		without /dav/
 	}
 
+	{{if .OCM}}
+	proxy /ocm/ {{.OCM.Host}} {
+		transparent
+	}
+	proxy /.well-known/ocm {{.OCM.Host}} {
+		transparent
+	}
+	proxy /ocm-provider {{.OCM.Host}} {
+		transparent
+	}
+	{{end}}
+
 	{{if .Collabora}}
 	proxy /wopi/ {{.WOPI.Host}} {
 		transparent
@@ -141,6 +155,9 @@ var (
 		if {path} not_starts_with "/plug/"
 		if {path} not_starts_with "/dav/"
 		if {path} not_starts_with "/wopi/"
+		if {path} not_starts_with "/ocm/"
+		if {path} not_starts_with "/.well-known/ocm"
+		if {path} not_starts_with "/ocm-provider"
 		if {path} not_starts_with "/loleaflet/"
 		if {path} not_starts_with "/hosting/discovery"
 		if {path} not_starts_with "/lool/"
@@ -247,6 +264,12 @@ func LoadCaddyConf() (*CaddyTemplateConf, error) {
 		return c, e
 	}
 
+	if p, e := internalUrlFromConfig("ocm", []string{"services", "pydio.rest.gateway.ocm", "port"}, servicesHost, tls); e == nil {
+		c.OCM = p
+	} else {
+		c.OCM = nil
+	}
+
 	if p, e := internalUrlFromConfig("collabora", []string{"frontend", "plugin", "editor.libreoffice", "LIBREOFFICE_WEBSOCKET_PORT"}, servicesHost, tls); e == nil {
 		c.Collabora = p
 	} else {
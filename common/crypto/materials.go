@@ -31,7 +31,19 @@ import (
 	"go.uber.org/zap"
 )
 
-// AESGCMMaterials ...
+const (
+	// nonceSize is the size of the random nonce used to seal each block
+	nonceSize = 12
+	// tagSize is the size of the authentication tag appended to each sealed block
+	tagSize = 16
+)
+
+// AESGCMMaterials encrypts and decrypts data by blocks of plain data using AES GCM.
+//
+// Each encrypted block is stored as its nonce followed by the sealed data, so that any block can be
+// decrypted independently and byte ranges can be read without downloading the whole object.
+// Objects written by previous versions store all nonces in the encryption parameters instead:
+// they are detected by a non-empty Nonce parameter and can still be decrypted.
 type AESGCMMaterials struct {
 	mode         int
 	reader       io.Reader
@@ -46,17 +58,27 @@ type AESGCMMaterials struct {
 	nonceBuffer      *bytes.Buffer
 	blockCount       int
 	totalRead        int64
+	legacy           bool
+
+	// Plain range restriction for decryption
+	firstBlock int64
+	rangeSkip  int64
+	skip       int64
+	limit      int64
+	written    int64
 }
 
 // NewAESGCMMaterials creates an encryption materials that use AES GCM
 func NewAESGCMMaterials(key []byte, t *encryption.Params) *AESGCMMaterials {
 	m := new(AESGCMMaterials)
 	m.encryptionKey = key
-	if t != nil {
+	m.limit = -1
+	if t != nil && t.BlockSize > 0 {
 		m.initialBlockSize = t.BlockSize
 		m.blockSize = t.BlockSize
 		m.nonceBuffer = bytes.NewBuffer(t.Nonce)
 		m.nonceBytes = t.Nonce
+		m.legacy = len(t.Nonce) > 0
 	} else {
 		m.initialBlockSize = 4096
 		m.blockSize = 4096
@@ -65,6 +87,31 @@ func NewAESGCMMaterials(key []byte, t *encryption.Params) *AESGCMMaterials {
 	return m
 }
 
+// SetPlainRange restricts decryption to @length bytes of plain data starting at @offset, a negative length meaning
+// up to the end of data. It returns the range of encrypted data that must be passed to SetupDecryptMode.
+// A negative encrypted length means that data must be read up to the end of the object.
+func (m *AESGCMMaterials) SetPlainRange(offset int64, length int64) (encOffset int64, encLength int64) {
+	plainBlock := int64(m.initialBlockSize)
+	encBlock := plainBlock + tagSize
+	if !m.legacy {
+		encBlock += nonceSize
+	}
+
+	m.firstBlock = offset / plainBlock
+	m.rangeSkip = offset % plainBlock
+	m.limit = length
+	encOffset = m.firstBlock * encBlock
+	encLength = -1
+	if length >= 0 {
+		lastBlock := (offset + length - 1) / plainBlock
+		if length == 0 {
+			lastBlock = m.firstBlock
+		}
+		encLength = (lastBlock - m.firstBlock + 1) * encBlock
+	}
+	return
+}
+
 // Close closes the underlying stream
 func (m *AESGCMMaterials) Close() error {
 	closer, ok := m.reader.(io.Closer)
@@ -103,6 +150,10 @@ func (m *AESGCMMaterials) GetDesc() (desc string) {
 // SetupEncryptMode set underlying read function in encrypt mode
 func (m *AESGCMMaterials) SetupEncryptMode(stream io.Reader) error {
 	m.bufferedRead = bytes.NewBuffer([]byte{})
+	// Data is always written with nonces inside the blocks
+	m.legacy = false
+	m.nonceBytes = nil
+	m.nonceBuffer = bytes.NewBuffer([]byte{})
 	m.reader = stream
 	m.blockSizeFixed = false
 	m.blockSize = m.initialBlockSize
@@ -117,15 +168,22 @@ func (m *AESGCMMaterials) SetupEncryptMode(stream io.Reader) error {
 func (m *AESGCMMaterials) SetupDecryptMode(stream io.Reader, iv string, key string) error {
 	m.bufferedRead = bytes.NewBuffer([]byte{})
 	m.blockSizeFixed = true
-	m.blockSize = m.initialBlockSize + 16
+	m.blockSize = m.initialBlockSize + tagSize
+	if !m.legacy {
+		m.blockSize += nonceSize
+	}
 
 	if m.nonceBytes == nil {
 		//Rewind buffer
 		m.nonceBytes = m.nonceBuffer.Bytes()
-		m.nonceBuffer = bytes.NewBuffer(m.nonceBytes)
+	}
+	if start := m.firstBlock * nonceSize; m.legacy && start < int64(len(m.nonceBytes)) {
+		m.nonceBuffer = bytes.NewBuffer(m.nonceBytes[start:])
 	} else {
 		m.nonceBuffer = bytes.NewBuffer(m.nonceBytes)
 	}
+	m.skip = m.rangeSkip
+	m.written = 0
 
 	m.reader = stream
 	m.eof = false
@@ -137,9 +195,13 @@ func (m *AESGCMMaterials) SetupDecryptMode(stream io.Reader, iv string, key stri
 
 // GetEncryptedParameters returns the additional parameters that are generated for encryption
 func (m *AESGCMMaterials) GetEncryptedParameters() *encryption.Params {
+	var nonce []byte
+	if m.legacy {
+		nonce = m.nonceBuffer.Bytes()
+	}
 	return &encryption.Params{
-		Nonce:     m.nonceBuffer.Bytes(),
-		BlockSize: m.blockSize,
+		Nonce:     nonce,
+		BlockSize: m.initialBlockSize,
 	}
 }
 
@@ -179,8 +241,7 @@ func (m *AESGCMMaterials) encryptRead(b []byte) (int, error) {
 				return n, err
 			}
 			cursor = 0
-			m.nonceBuffer.Write(sealed[:12])
-			m.bufferedRead.Write(sealed[12:])
+			m.bufferedRead.Write(sealed)
 		}
 	}
 	return totalSet, nil
@@ -216,20 +277,49 @@ func (m *AESGCMMaterials) decryptRead(b []byte) (int, error) {
 		cursor += n
 
 		if cursor != 0 && (cursor == int(m.blockSize) || m.eof) {
-			nonce := make([]byte, 12)
-			nl, err := m.nonceBuffer.Read(nonce)
-			if err != nil || nl < 12 {
-				return 0, errors.New("Read nonce failed")
+			nonce := make([]byte, nonceSize)
+			sealed := buff[:cursor]
+			if m.legacy {
+				nl, err := m.nonceBuffer.Read(nonce)
+				if err != nil || nl < nonceSize {
+					return 0, errors.New("Read nonce failed")
+				}
+			} else {
+				if cursor < nonceSize {
+					return 0, errors.New("Read nonce failed")
+				}
+				copy(nonce, sealed[:nonceSize])
+				sealed = sealed[nonceSize:]
 			}
 
-			opened, err := Open(m.encryptionKey, nonce, buff[:cursor])
+			opened, err := Open(m.encryptionKey, nonce, sealed)
 			if err != nil {
 				log.Logger(context.Background()).Error("failed to decrypt block", zap.Int("Block Num", m.blockCount), zap.Int32("Block size", m.blockSize), zap.Int64("Total Read", m.totalRead), zap.Error(err))
 				return 0, err
 			}
 			cursor = 0
-			m.bufferedRead.Write(opened)
+			m.bufferedRead.Write(m.restrictToRange(opened))
 		}
 	}
 	return totalSet, nil
 }
+
+// restrictToRange trims a decrypted block to the plain range set by SetPlainRange
+func (m *AESGCMMaterials) restrictToRange(opened []byte) []byte {
+	if m.skip > 0 {
+		if m.skip >= int64(len(opened)) {
+			m.skip -= int64(len(opened))
+			return nil
+		}
+		opened = opened[m.skip:]
+		m.skip = 0
+	}
+	if m.limit >= 0 {
+		if remaining := m.limit - m.written; remaining < int64(len(opened)) {
+			opened = opened[:remaining]
+			m.eof = true
+		}
+	}
+	m.written += int64(len(opened))
+	return opened
+}
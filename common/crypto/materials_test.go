@@ -21,6 +21,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
@@ -31,6 +32,7 @@ import (
 	"testing"
 
 	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/proto/encryption"
 	"github.com/smartystreets/goconvey/convey"
 )
 
@@ -137,6 +139,72 @@ func TestMaterials(t *testing.T) {
 	})
 }
 
+func TestMaterialsRange(t *testing.T) {
+
+	key := KeyFromPassword([]byte("password"), 32)
+	plain := make([]byte, 3*4096+1000)
+	rand.Read(plain)
+
+	convey.Convey("Decrypt ranges of block-encrypted data", t, func() {
+		materials := NewAESGCMMaterials(key, nil)
+		materials.SetupEncryptMode(bytes.NewReader(plain))
+		encrypted, err := ioutil.ReadAll(materials)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(encrypted), convey.ShouldEqual, len(plain)+4*(12+16))
+
+		params := materials.GetEncryptedParameters()
+		convey.So(params.Nonce, convey.ShouldBeEmpty)
+		convey.So(params.BlockSize, convey.ShouldEqual, 4096)
+
+		for _, r := range [][2]int64{{0, 10}, {5000, 100}, {4000, 200}, {4096, 4096}, {12000, -1}, {0, int64(len(plain))}} {
+			reader := NewAESGCMMaterials(key, params)
+			encOffset, encLength := reader.SetPlainRange(r[0], r[1])
+			encEnd := int64(len(encrypted))
+			if encLength >= 0 && encOffset+encLength < encEnd {
+				encEnd = encOffset + encLength
+			}
+			reader.SetupDecryptMode(bytes.NewReader(encrypted[encOffset:encEnd]), "", "")
+			data, err := ioutil.ReadAll(reader)
+			convey.So(err, convey.ShouldBeNil)
+			expected := plain[r[0]:]
+			if r[1] >= 0 {
+				expected = plain[r[0] : r[0]+r[1]]
+			}
+			convey.So(bytes.Equal(data, expected), convey.ShouldBeTrue)
+		}
+	})
+
+	convey.Convey("Decrypt data encrypted with nonces stored in parameters", t, func() {
+		var nonces, encrypted []byte
+		for i := 0; i < len(plain); i += 4096 {
+			end := i + 4096
+			if end > len(plain) {
+				end = len(plain)
+			}
+			sealed, err := Seal(key, plain[i:end])
+			convey.So(err, convey.ShouldBeNil)
+			nonces = append(nonces, sealed[:12]...)
+			encrypted = append(encrypted, sealed[12:]...)
+		}
+		params := &encryption.Params{Nonce: nonces, BlockSize: 4096}
+
+		reader := NewAESGCMMaterials(key, params)
+		reader.SetupDecryptMode(bytes.NewReader(encrypted), "", "")
+		data, err := ioutil.ReadAll(reader)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(bytes.Equal(data, plain), convey.ShouldBeTrue)
+
+		reader = NewAESGCMMaterials(key, params)
+		encOffset, encLength := reader.SetPlainRange(9000, 500)
+		convey.So(encOffset, convey.ShouldEqual, 2*(4096+16))
+		convey.So(encLength, convey.ShouldEqual, 4096+16)
+		reader.SetupDecryptMode(bytes.NewReader(encrypted[encOffset:encOffset+encLength]), "", "")
+		data, err = ioutil.ReadAll(reader)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(bytes.Equal(data, plain[9000:9500]), convey.ShouldBeTrue)
+	})
+}
+
 func hash_file_md5(filePath string) (string, error) {
 	//Initialize variable returnMD5String now in case an error has to be returned
 	var returnMD5String string
@@ -42,6 +42,8 @@ func NewConn(d string, dsn string) (Conn, error) {
 		drv = new(mysql)
 	case "sqlite3":
 		drv = new(sqlite)
+	case "postgres":
+		drv = new(postgres)
 	case "boltdb":
 		drv = new(boltdb)
 	default:
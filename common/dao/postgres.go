@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dao
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type postgres struct {
+	conn *sql.DB
+}
+
+func (p *postgres) Open(dsn string) (Conn, error) {
+
+	db, err := getSqlConnection("postgres", dsn)
+	if err == nil {
+		p.conn = db
+		return db, nil
+	}
+
+	// Try to create the database if it does not exist yet (invalid_catalog_name)
+	if pqErr, ok := err.(*pq.Error); !ok || pqErr.Code != "3D000" {
+		return nil, err
+	}
+	pgUrl, e := url.Parse(dsn)
+	if e != nil || (pgUrl.Scheme != "postgres" && pgUrl.Scheme != "postgresql") {
+		return nil, err
+	}
+	dbName := strings.TrimPrefix(pgUrl.Path, "/")
+	pgUrl.Path = "/postgres"
+	rootDSN := pgUrl.String()
+
+	if db, err = getSqlConnection("postgres", rootDSN); err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(fmt.Sprintf("create database %s", pq.QuoteIdentifier(dbName))); err != nil {
+		return nil, err
+	}
+	db.Close()
+
+	if db, err = getSqlConnection("postgres", dsn); err != nil {
+		return nil, err
+	}
+
+	p.conn = db
+
+	return db, nil
+}
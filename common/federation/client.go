@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client sends shares and notifications to remote servers.
+type Client struct {
+	HTTPClient *http.Client
+}
+
+// NewClient creates a Client with a default http client.
+func NewClient() *Client {
+	return &Client{HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Discover loads the discovery document of a server.
+func (c *Client) Discover(ctx context.Context, server string) (*Discovery, error) {
+	var d *Discovery
+	var err error
+	for _, p := range []string{WellKnownPath, DiscoveryPath} {
+		if d, err = c.discover(ctx, strings.TrimSuffix(server, "/")+p); err == nil {
+			return d, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *Client) discover(ctx context.Context, u string) (*Discovery, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery on %s returned status %d", u, resp.StatusCode)
+	}
+	d := &Discovery{}
+	if err := json.NewDecoder(resp.Body).Decode(d); err != nil {
+		return nil, err
+	}
+	if !d.Enabled || d.EndPoint == "" {
+		return nil, fmt.Errorf("federated sharing is not enabled on %s", u)
+	}
+	return d, nil
+}
+
+// SendShare notifies the server of a federated address that a resource is shared with its user.
+func (c *Client) SendShare(ctx context.Context, address string, share *Share) error {
+	user, server, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	d, err := c.Discover(ctx, server)
+	if err != nil {
+		return err
+	}
+	share.ShareWith = user
+	if share.ShareType == "" {
+		share.ShareType = ShareTypeUser
+	}
+	if share.ResourceType == "" {
+		share.ResourceType = ResourceTypeFile
+	}
+	return c.post(ctx, d.EndPoint+SharesPath, share)
+}
+
+// SendNotification sends a notification about a share to the server of a federated address.
+func (c *Client) SendNotification(ctx context.Context, address string, notification *Notification) error {
+	_, server, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	d, err := c.Discover(ctx, server)
+	if err != nil {
+		return err
+	}
+	if notification.ResourceType == "" {
+		notification.ResourceType = ResourceTypeFile
+	}
+	return c.post(ctx, d.EndPoint+NotificationsPath, notification)
+}
+
+func (c *Client) post(ctx context.Context, u string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned status %d: %s", u, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package federation
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/><D:getetag/></D:prop></D:propfind>`
+
+// DAVClient accesses a remote shared resource through WebDAV, authenticated
+// with the share provider ID and secret.
+type DAVClient struct {
+	URI        string
+	Login      string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// NewDAVClient creates a DAVClient for the given root URI.
+func NewDAVClient(uri, login, password string) *DAVClient {
+	return &DAVClient{
+		URI:        strings.TrimSuffix(uri, "/"),
+		Login:      login,
+		Password:   password,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+type multiStatus struct {
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href     string        `xml:"href"`
+	PropStat []davPropStat `xml:"propstat"`
+}
+
+type davPropStat struct {
+	Status string  `xml:"status"`
+	Prop   davProp `xml:"prop"`
+}
+
+type davProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"collection"`
+	} `xml:"resourcetype"`
+	ContentLength int64  `xml:"getcontentlength"`
+	LastModified  string `xml:"getlastmodified"`
+	ETag          string `xml:"getetag"`
+}
+
+// Stat returns the node at the given path, relative to the share root.
+func (d *DAVClient) Stat(ctx context.Context, p string) (*tree.Node, error) {
+	nodes, err := d.propfind(ctx, p, "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.NotFound(common.SERVICE_GATEWAY_OCM, "Cannot find %s", p)
+	}
+	return nodes[0], nil
+}
+
+// List returns the direct children of the collection at the given path.
+func (d *DAVClient) List(ctx context.Context, p string) ([]*tree.Node, error) {
+	nodes, err := d.propfind(ctx, p, "1")
+	if err != nil {
+		return nil, err
+	}
+	self := strings.Trim(p, "/")
+	var children []*tree.Node
+	for _, n := range nodes {
+		if n.Path != self {
+			children = append(children, n)
+		}
+	}
+	return children, nil
+}
+
+// Get reads the content of a file, starting at offset. A negative length reads to the end.
+func (d *DAVClient) Get(ctx context.Context, p string, offset int64, length int64) (io.ReadCloser, error) {
+	req, err := d.request(ctx, http.MethodGet, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 || length >= 0 {
+		if length >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkStatus(resp, p); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put writes the content of a file and returns the number of bytes sent.
+func (d *DAVClient) Put(ctx context.Context, p string, reader io.Reader, size int64) (int64, error) {
+	counter := &countingReader{Reader: reader}
+	req, err := d.request(ctx, http.MethodPut, p, counter)
+	if err != nil {
+		return 0, err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if err := d.do(req, p); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// Mkcol creates a collection.
+func (d *DAVClient) Mkcol(ctx context.Context, p string) error {
+	req, err := d.request(ctx, "MKCOL", p, nil)
+	if err != nil {
+		return err
+	}
+	return d.do(req, p)
+}
+
+// Delete removes a file or a collection.
+func (d *DAVClient) Delete(ctx context.Context, p string) error {
+	req, err := d.request(ctx, http.MethodDelete, p, nil)
+	if err != nil {
+		return err
+	}
+	return d.do(req, p)
+}
+
+// Move renames a file or a collection inside the share.
+func (d *DAVClient) Move(ctx context.Context, from, to string) error {
+	return d.transfer(ctx, "MOVE", from, to)
+}
+
+// Copy duplicates a file or a collection inside the share.
+func (d *DAVClient) Copy(ctx context.Context, from, to string) error {
+	return d.transfer(ctx, "COPY", from, to)
+}
+
+func (d *DAVClient) transfer(ctx context.Context, method, from, to string) error {
+	req, err := d.request(ctx, method, from, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", d.url(to))
+	req.Header.Set("Overwrite", "T")
+	return d.do(req, from)
+}
+
+func (d *DAVClient) propfind(ctx context.Context, p string, depth string) ([]*tree.Node, error) {
+	req, err := d.request(ctx, "PROPFIND", p, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := d.checkStatus(resp, p); err != nil {
+		return nil, err
+	}
+	ms := &multiStatus{}
+	if err := xml.NewDecoder(resp.Body).Decode(ms); err != nil {
+		return nil, err
+	}
+	root, _ := url.Parse(d.URI)
+	var nodes []*tree.Node
+	for _, r := range ms.Responses {
+		n, ok := d.responseToNode(root.Path, r)
+		if ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (d *DAVClient) responseToNode(rootPath string, r davResponse) (*tree.Node, bool) {
+	href := r.Href
+	if u, e := url.Parse(href); e == nil {
+		href = u.Path
+	}
+	rel := strings.Trim(strings.TrimPrefix(href, strings.TrimSuffix(rootPath, "/")), "/")
+	for _, ps := range r.PropStat {
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+		n := &tree.Node{
+			Path: rel,
+			Type: tree.NodeType_LEAF,
+			Etag: strings.Trim(ps.Prop.ETag, "\""),
+		}
+		if ps.Prop.ResourceType.Collection != nil {
+			n.Type = tree.NodeType_COLLECTION
+		} else {
+			n.Size = ps.Prop.ContentLength
+		}
+		if t, e := http.ParseTime(ps.Prop.LastModified); e == nil {
+			n.MTime = t.Unix()
+		}
+		return n, true
+	}
+	return nil, false
+}
+
+func (d *DAVClient) url(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return d.URI + "/"
+	}
+	escaped := (&url.URL{Path: path.Join("/", p)}).EscapedPath()
+	return d.URI + escaped
+}
+
+func (d *DAVClient) request(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, d.url(p), body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(d.Login, d.Password)
+	return req.WithContext(ctx), nil
+}
+
+func (d *DAVClient) do(req *http.Request, p string) error {
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return d.checkStatus(resp, p)
+}
+
+func (d *DAVClient) checkStatus(resp *http.Response, p string) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	// Drain and close body on errors
+	msg, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return errors.NotFound(common.SERVICE_GATEWAY_OCM, "Cannot find %s on remote server", p)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.Forbidden(common.SERVICE_GATEWAY_OCM, "Access to %s refused by remote server", p)
+	default:
+		return errors.New(common.SERVICE_GATEWAY_OCM, fmt.Sprintf("remote server returned status %d on %s: %s", resp.StatusCode, p, strings.TrimSpace(string(msg))), int32(resp.StatusCode))
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package federation
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/pydio/cells/common/auth/claim"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newProvider starts a WebDAV server standing for the instance that owns the shared data.
+func newProvider(login, password string) *httptest.Server {
+	dav := &webdav.Handler{
+		Prefix:     "/dav/shared-cell",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != login || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+}
+
+// newRecipient starts a Receiver standing for the instance of the remote user, where only john exists.
+func newRecipient(store Store, trusted ...string) (*Receiver, *httptest.Server) {
+	receiver := &Receiver{
+		Store:    store,
+		Provider: "recipient",
+		UserExists: func(ctx context.Context, login string) (bool, error) {
+			return login == "john", nil
+		},
+		TrustedServers: trusted,
+	}
+	server := httptest.NewServer(receiver)
+	receiver.EndPoint = server.URL + "/ocm"
+	return receiver, server
+}
+
+// asUser calls the receiver on behalf of an authenticated local user.
+func asUser(receiver *Receiver, login, method, p string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/ocm"+p, nil)
+	req = req.WithContext(context.WithValue(req.Context(), claim.ContextKey, claim.Claims{Name: login}))
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, req)
+	return w
+}
+
+func TestParseAddress(t *testing.T) {
+	Convey("Parse federated addresses", t, func() {
+		user, server, err := ParseAddress("john@example.com@cells.example.com")
+		So(err, ShouldBeNil)
+		So(user, ShouldEqual, "john@example.com")
+		So(server, ShouldEqual, "https://cells.example.com")
+
+		user, server, err = ParseAddress("john@http://localhost:8080/")
+		So(err, ShouldBeNil)
+		So(user, ShouldEqual, "john")
+		So(server, ShouldEqual, "http://localhost:8080")
+
+		_, _, err = ParseAddress("john")
+		So(err, ShouldNotBeNil)
+		So(IsAddress("john"), ShouldBeFalse)
+	})
+}
+
+func TestFederatedShare(t *testing.T) {
+	ctx := context.Background()
+	provider := newProvider("provider-id", "secret")
+	defer provider.Close()
+	store := NewMemoryStore()
+	receiver, recipient := newRecipient(store, provider.URL)
+	defer recipient.Close()
+
+	Convey("Send a share to a remote server and access it", t, func() {
+		client := NewClient()
+		d, err := client.Discover(ctx, recipient.URL)
+		So(err, ShouldBeNil)
+		So(d.EndPoint, ShouldEqual, recipient.URL+"/ocm")
+
+		share := &Share{
+			Name:       "Shared Cell",
+			ProviderID: "provider-id",
+			Owner:      "admin@" + provider.URL,
+			Sender:     "admin@" + provider.URL,
+			Protocol: Protocol{Name: ProtocolWebDAV, WebDAV: &WebDAVOptions{
+				URI:          provider.URL + "/dav/shared-cell",
+				SharedSecret: "secret",
+				Permissions:  []string{PermissionRead, PermissionWrite},
+			}},
+		}
+		So(client.SendShare(ctx, "john@"+recipient.URL, share), ShouldBeNil)
+
+		shares, err := store.ListShares(ctx, "john")
+		So(err, ShouldBeNil)
+		So(shares, ShouldHaveLength, 1)
+		received := shares[0]
+		So(received.Writable(), ShouldBeTrue)
+		So(received.Slug, ShouldStartWith, "shared-cell-")
+		So(received.Accepted, ShouldBeFalse)
+
+		// The recipient lists and accepts the pending share, other users cannot
+		w := asUser(receiver, "john", http.MethodGet, ReceivedPath)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldContainSubstring, received.ID)
+		So(w.Body.String(), ShouldNotContainSubstring, "secret")
+		So(asUser(receiver, "jane", http.MethodPost, ReceivedPath+"/"+received.ID+"/accept").Code, ShouldEqual, http.StatusNotFound)
+		So(asUser(receiver, "john", http.MethodPost, ReceivedPath+"/"+received.ID+"/accept").Code, ShouldEqual, http.StatusOK)
+		received, _ = store.GetShare(ctx, received.ID)
+		So(received.Accepted, ShouldBeTrue)
+
+		// Sending again updates the same share and keeps it accepted
+		So(client.SendShare(ctx, "john@"+recipient.URL, share), ShouldBeNil)
+		shares, _ = store.ListShares(ctx, "john")
+		So(shares, ShouldHaveLength, 1)
+		So(shares[0].ID, ShouldEqual, received.ID)
+		So(shares[0].Accepted, ShouldBeTrue)
+
+		dav := received.DAV()
+		So(dav.Mkcol(ctx, "folder"), ShouldBeNil)
+		n, err := dav.Put(ctx, "folder/file.txt", bytes.NewBufferString("hello world"), 11)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 11)
+
+		children, err := dav.List(ctx, "folder")
+		So(err, ShouldBeNil)
+		So(children, ShouldHaveLength, 1)
+		So(children[0].Path, ShouldEqual, "folder/file.txt")
+		So(children[0].Size, ShouldEqual, 11)
+		So(children[0].IsLeaf(), ShouldBeTrue)
+
+		reader, err := dav.Get(ctx, "folder/file.txt", 6, -1)
+		So(err, ShouldBeNil)
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		So(string(data), ShouldEqual, "world")
+
+		So(dav.Move(ctx, "folder/file.txt", "folder/moved.txt"), ShouldBeNil)
+		_, err = dav.Stat(ctx, "folder/file.txt")
+		So(err, ShouldNotBeNil)
+		stat, err := dav.Stat(ctx, "folder/moved.txt")
+		So(err, ShouldBeNil)
+		So(stat.Size, ShouldEqual, 11)
+
+		So(dav.Delete(ctx, "folder"), ShouldBeNil)
+		root, err := dav.List(ctx, "")
+		So(err, ShouldBeNil)
+		So(root, ShouldHaveLength, 0)
+
+		// Wrong secret is refused
+		_, err = NewDAVClient(received.URI, received.ProviderID, "wrong").List(ctx, "")
+		So(err, ShouldNotBeNil)
+
+		// Unsharing with a wrong secret fails, with the right one removes the share
+		So(client.SendNotification(ctx, "john@"+recipient.URL, &Notification{
+			NotificationType: NotificationUnshare,
+			ProviderID:       "provider-id",
+			Notification:     map[string]string{"sharedSecret": "wrong"},
+		}), ShouldNotBeNil)
+		So(client.SendNotification(ctx, "john@"+recipient.URL, &Notification{
+			NotificationType: NotificationUnshare,
+			ProviderID:       "provider-id",
+			Notification:     map[string]string{"sharedSecret": "secret"},
+		}), ShouldBeNil)
+		shares, _ = store.ListShares(ctx, "john")
+		So(shares, ShouldHaveLength, 0)
+	})
+
+	Convey("Reject invalid shares", t, func() {
+		err := NewClient().SendShare(ctx, "john@"+recipient.URL, &Share{Name: "Invalid"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Reject shares pointing to untrusted servers or that cannot be accessed", t, func() {
+		other := newProvider("provider-id", "secret")
+		defer other.Close()
+		for _, uri := range []string{other.URL + "/dav/shared-cell", "file:///etc/passwd", "http://provider-id:secret@" + provider.URL[len("http://"):] + "/dav/shared-cell"} {
+			err := NewClient().SendShare(ctx, "john@"+recipient.URL, &Share{
+				Name:       "Untrusted",
+				ProviderID: "provider-id",
+				Protocol:   Protocol{Name: ProtocolWebDAV, WebDAV: &WebDAVOptions{URI: uri, SharedSecret: "secret"}},
+			})
+			So(err, ShouldNotBeNil)
+		}
+		err := NewClient().SendShare(ctx, "john@"+recipient.URL, &Share{
+			Name:       "Spoofed",
+			ProviderID: "provider-id",
+			Protocol:   Protocol{Name: ProtocolWebDAV, WebDAV: &WebDAVOptions{URI: provider.URL + "/dav/shared-cell", SharedSecret: "guessed"}},
+		})
+		So(err, ShouldNotBeNil)
+		shares, _ := store.ListShares(ctx, "john")
+		So(shares, ShouldHaveLength, 0)
+	})
+
+	Convey("Answer the same for unknown users, and let recipients decline shares", t, func() {
+		share := &Share{
+			Name:       "Shared Cell",
+			ProviderID: "provider-id",
+			Protocol:   Protocol{Name: ProtocolWebDAV, WebDAV: &WebDAVOptions{URI: provider.URL + "/dav/shared-cell", SharedSecret: "secret"}},
+		}
+		So(NewClient().SendShare(ctx, "ghost@"+recipient.URL, share), ShouldBeNil)
+		shares, _ := store.ListShares(ctx, "ghost")
+		So(shares, ShouldHaveLength, 0)
+
+		So(NewClient().SendShare(ctx, "john@"+recipient.URL, share), ShouldBeNil)
+		shares, _ = store.ListShares(ctx, "john")
+		So(shares, ShouldHaveLength, 1)
+		So(asUser(receiver, "john", http.MethodDelete, ReceivedPath+"/"+shares[0].ID).Code, ShouldEqual, http.StatusOK)
+		shares, _ = store.ListShares(ctx, "john")
+		So(shares, ShouldHaveLength, 0)
+	})
+}
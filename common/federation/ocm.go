@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package federation implements an OCM-style (Open Cloud Mesh) protocol to share Cells with users of remote servers.
+//
+// The sending server creates a hidden local user for each remote recipient and notifies the remote server
+// with the WebDAV endpoint of the Cell and the credentials of this user. The receiving server stores the share
+// and mounts it as a virtual workspace, proxying reads and writes to the sender over authenticated WebDAV.
+package federation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// APIVersion is the version of the protocol advertised in discovery.
+	APIVersion = "1.0-proposal1"
+	// DiscoveryPath is the path where servers expose their discovery document.
+	DiscoveryPath = "/ocm-provider/"
+	// WellKnownPath is the alternate discovery path.
+	WellKnownPath = "/.well-known/ocm"
+
+	SharesPath        = "/shares"
+	NotificationsPath = "/notifications"
+	// ReceivedPath is where local users list, accept and decline the shares they received.
+	ReceivedPath = "/received"
+
+	ShareTypeUser       = "user"
+	ResourceTypeFile    = "file"
+	ProtocolWebDAV      = "webdav"
+	PermissionRead      = "read"
+	PermissionWrite     = "write"
+	NotificationUnshare = "SHARE_UNSHARED"
+)
+
+// Discovery describes the protocol capabilities of a server.
+type Discovery struct {
+	Enabled       bool            `json:"enabled"`
+	APIVersion    string          `json:"apiVersion"`
+	EndPoint      string          `json:"endPoint"`
+	Provider      string          `json:"provider"`
+	ResourceTypes []*ResourceType `json:"resourceTypes"`
+}
+
+// ResourceType lists the share types and protocols supported for a kind of resource.
+type ResourceType struct {
+	Name       string            `json:"name"`
+	ShareTypes []string          `json:"shareTypes"`
+	Protocols  map[string]string `json:"protocols"`
+}
+
+// Share is sent by the owner server to notify a remote server of a new share.
+type Share struct {
+	ShareWith         string   `json:"shareWith"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description,omitempty"`
+	ProviderID        string   `json:"providerId"`
+	Owner             string   `json:"owner"`
+	Sender            string   `json:"sender"`
+	OwnerDisplayName  string   `json:"ownerDisplayName,omitempty"`
+	SenderDisplayName string   `json:"senderDisplayName,omitempty"`
+	ShareType         string   `json:"shareType"`
+	ResourceType      string   `json:"resourceType"`
+	Protocol          Protocol `json:"protocol"`
+}
+
+// Protocol describes how the remote server can access the shared resource.
+type Protocol struct {
+	Name   string         `json:"name"`
+	WebDAV *WebDAVOptions `json:"webdav,omitempty"`
+}
+
+// WebDAVOptions gives the WebDAV endpoint of a share. The remote server authenticates with
+// the ProviderID of the share as login and the SharedSecret as password.
+type WebDAVOptions struct {
+	URI          string   `json:"uri"`
+	SharedSecret string   `json:"sharedSecret"`
+	Permissions  []string `json:"permissions"`
+}
+
+// Notification is sent by the owner server to notify a remote server of changes on a share.
+type Notification struct {
+	NotificationType string            `json:"notificationType"`
+	ResourceType     string            `json:"resourceType"`
+	ProviderID       string            `json:"providerId"`
+	Notification     map[string]string `json:"notification,omitempty"`
+}
+
+// Validate checks that a received share can be used.
+func (s *Share) Validate() error {
+	if s.ShareWith == "" || s.ProviderID == "" {
+		return fmt.Errorf("missing shareWith or providerId")
+	}
+	if s.ShareType != ShareTypeUser || s.ResourceType != ResourceTypeFile {
+		return fmt.Errorf("unsupported share type %s or resource type %s", s.ShareType, s.ResourceType)
+	}
+	if s.Protocol.WebDAV == nil || s.Protocol.WebDAV.URI == "" || s.Protocol.WebDAV.SharedSecret == "" {
+		return fmt.Errorf("missing webdav protocol options")
+	}
+	if _, err := ServerOrigin(s.Protocol.WebDAV.URI); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ServerOrigin returns the scheme and host of an http(s) URL, refusing other schemes
+// and URLs carrying credentials.
+func ServerOrigin(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("invalid server url %s", address)
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host), nil
+}
+
+// ParseAddress splits a federated address of the form user@server into the user and the server URL.
+// Server defaults to https if no scheme is given.
+func ParseAddress(address string) (user string, server string, err error) {
+	i := strings.LastIndex(address, "@")
+	if i <= 0 || i == len(address)-1 {
+		return "", "", fmt.Errorf("invalid federated address %s", address)
+	}
+	user, server = address[:i], strings.TrimSuffix(address[i+1:], "/")
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		server = "https://" + server
+	}
+	if _, err := url.Parse(server); err != nil {
+		return "", "", err
+	}
+	return user, server, nil
+}
+
+// IsAddress checks if a string looks like a federated address.
+func IsAddress(address string) bool {
+	_, _, err := ParseAddress(address)
+	return err == nil
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/log"
+)
+
+// verifyTimeout bounds the request checking a received share against the sending server.
+const verifyTimeout = 30 * time.Second
+
+// Receiver is the http.Handler serving the protocol endpoints: discovery, reception of
+// new shares and of notifications. It also lets local users list, accept and decline the
+// shares they received. It can be mounted under any prefix.
+type Receiver struct {
+	// Store persists received shares.
+	Store Store
+	// EndPoint is the public URL where the Receiver is mounted.
+	EndPoint string
+	// Provider is the name of this server, as advertised in discovery.
+	Provider string
+	// UserExists checks that a share recipient is a known local user.
+	UserExists func(ctx context.Context, login string) (bool, error)
+	// TrustedServers lists the servers allowed to share with local users, as scheme://host[:port].
+	// Shares pointing to any other server are refused.
+	TrustedServers []string
+}
+
+// receivedShare is the view of a ReceivedShare returned to its recipient, without the shared secret.
+type receivedShare struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner"`
+	Sender      string `json:"sender"`
+	Writable    bool   `json:"writable"`
+	Accepted    bool   `json:"accepted"`
+	Created     int64  `json:"created"`
+}
+
+// Discovery returns the discovery document of this server.
+func (r *Receiver) Discovery() *Discovery {
+	return &Discovery{
+		Enabled:    true,
+		APIVersion: APIVersion,
+		EndPoint:   strings.TrimSuffix(r.EndPoint, "/"),
+		Provider:   r.Provider,
+		ResourceTypes: []*ResourceType{{
+			Name:       ResourceTypeFile,
+			ShareTypes: []string{ShareTypeUser},
+			Protocols:  map[string]string{ProtocolWebDAV: "/dav/"},
+		}},
+	}
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := strings.TrimSuffix(req.URL.Path, "/")
+	switch {
+	case req.Method == http.MethodGet && (strings.HasSuffix(p, strings.TrimSuffix(DiscoveryPath, "/")) || strings.HasSuffix(p, WellKnownPath)):
+		writeJSON(w, http.StatusOK, r.Discovery())
+	case req.Method == http.MethodPost && strings.HasSuffix(p, SharesPath):
+		r.receiveShare(w, req)
+	case req.Method == http.MethodPost && strings.HasSuffix(p, NotificationsPath):
+		r.receiveNotification(w, req)
+	case req.Method == http.MethodGet && strings.HasSuffix(p, ReceivedPath):
+		r.listReceived(w, req)
+	case req.Method == http.MethodPost && strings.Contains(p, ReceivedPath+"/") && strings.HasSuffix(p, "/accept"):
+		r.acceptReceived(w, req, strings.TrimSuffix(p[strings.LastIndex(p, ReceivedPath+"/")+len(ReceivedPath)+1:], "/accept"))
+	case req.Method == http.MethodDelete && strings.Contains(p, ReceivedPath+"/"):
+		r.declineReceived(w, req, p[strings.LastIndex(p, ReceivedPath+"/")+len(ReceivedPath)+1:])
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// receiveShare stores a share offered by a trusted server, pending until the recipient accepts it.
+// The answer does not depend on the recipient being a local user, so that logins cannot be discovered.
+func (r *Receiver) receiveShare(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	share := &Share{}
+	if err := json.NewDecoder(req.Body).Decode(share); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := share.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	received := NewReceivedShare(share)
+	if !r.trusted(received.URI) {
+		log.Logger(ctx).Warn("Refusing federated share from untrusted server", zap.String("uri", received.URI))
+		writeError(w, http.StatusForbidden, "Share refused")
+		return
+	}
+	if err := r.verify(ctx, received); err != nil {
+		log.Logger(ctx).Warn("Refusing federated share that cannot be accessed", zap.String("uri", received.URI), zap.Error(err))
+		writeError(w, http.StatusForbidden, "Share refused")
+		return
+	}
+	if r.UserExists != nil {
+		if ok, err := r.UserExists(ctx, share.ShareWith); err != nil {
+			writeError(w, http.StatusInternalServerError, "Cannot store share")
+			return
+		} else if !ok {
+			log.Logger(ctx).Debug("Ignoring federated share for unknown user", zap.String("shareWith", share.ShareWith))
+			writeJSON(w, http.StatusCreated, map[string]string{})
+			return
+		}
+	}
+	// A share sent again by the same provider updates the existing one
+	if existing, err := r.Store.FindShare(ctx, share.ProviderID, share.Protocol.WebDAV.SharedSecret); err != nil {
+		writeError(w, http.StatusInternalServerError, "Cannot store share")
+		return
+	} else if existing != nil {
+		received.ID = existing.ID
+		received.Slug = existing.Slug
+		received.Created = existing.Created
+		received.Accepted = existing.Accepted && existing.ShareWith == received.ShareWith && existing.URI == received.URI
+	}
+	if err := r.Store.PutShare(ctx, received); err != nil {
+		writeError(w, http.StatusInternalServerError, "Cannot store share")
+		return
+	}
+	log.Logger(ctx).Info("Received federated share", zap.String("owner", share.Owner), zap.String("shareWith", share.ShareWith), zap.String("uri", received.URI))
+	writeJSON(w, http.StatusCreated, map[string]string{})
+}
+
+// trusted checks that a share URI points to one of the TrustedServers.
+func (r *Receiver) trusted(uri string) bool {
+	origin, err := ServerOrigin(uri)
+	if err != nil {
+		return false
+	}
+	for _, s := range r.TrustedServers {
+		if !strings.Contains(s, "://") {
+			s = "https://" + s
+		}
+		if o, e := ServerOrigin(s); e == nil && o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// verify authenticates on the shared resource with the received credentials, proving
+// that the share was really issued by the server it points to.
+func (r *Receiver) verify(ctx context.Context, share *ReceivedShare) error {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	_, err := share.DAV().Stat(ctx, "")
+	return err
+}
+
+func (r *Receiver) receiveNotification(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	n := &Notification{}
+	if err := json.NewDecoder(req.Body).Decode(n); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if n.NotificationType != NotificationUnshare {
+		writeError(w, http.StatusNotImplemented, "Unsupported notification "+n.NotificationType)
+		return
+	}
+	share, err := r.Store.FindShare(ctx, n.ProviderID, n.Notification["sharedSecret"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if share == nil {
+		writeError(w, http.StatusForbidden, "Unknown share or invalid secret")
+		return
+	}
+	if err := r.Store.DeleteShare(ctx, share.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{})
+}
+
+// listReceived lists the shares received by the current user, pending or accepted.
+func (r *Receiver) listReceived(w http.ResponseWriter, req *http.Request) {
+	login, ok := currentUser(req.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	shares, err := r.Store.ListShares(req.Context(), login)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	views := []*receivedShare{}
+	for _, s := range shares {
+		views = append(views, &receivedShare{
+			ID:          s.ID,
+			Name:        s.Name,
+			Description: s.Description,
+			Owner:       s.Owner,
+			Sender:      s.Sender,
+			Writable:    s.Writable(),
+			Accepted:    s.Accepted,
+			Created:     s.Created,
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// acceptReceived marks a share of the current user as accepted, so that it gets mounted.
+func (r *Receiver) acceptReceived(w http.ResponseWriter, req *http.Request, id string) {
+	share, ok := r.ownShare(w, req, id)
+	if !ok {
+		return
+	}
+	share.Accepted = true
+	if err := r.Store.PutShare(req.Context(), share); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// declineReceived removes a share of the current user.
+func (r *Receiver) declineReceived(w http.ResponseWriter, req *http.Request, id string) {
+	share, ok := r.ownShare(w, req, id)
+	if !ok {
+		return
+	}
+	if err := r.Store.DeleteShare(req.Context(), share.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// ownShare loads a share received by the current user, writing the error response if it cannot.
+func (r *Receiver) ownShare(w http.ResponseWriter, req *http.Request, id string) (*ReceivedShare, bool) {
+	login, ok := currentUser(req.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	share, err := r.Store.GetShare(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if share == nil || share.ShareWith != login {
+		writeError(w, http.StatusNotFound, "Share not found")
+		return nil, false
+	}
+	return share, true
+}
+
+// currentUser reads the login of the authenticated user from the context.
+func currentUser(ctx context.Context) (string, bool) {
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		return "", false
+	}
+	return claims.Name, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/micro/go-micro/errors"
+	"github.com/pborman/uuid"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/service/defaults"
+)
+
+const receivedStoreID = "ocm-received"
+
+// ReceivedShare is a share offered by a remote server to a local user.
+type ReceivedShare struct {
+	ID           string   `json:"ID"`
+	Slug         string   `json:"SLUG"`
+	ShareWith    string   `json:"SHARE_WITH"`
+	Name         string   `json:"NAME"`
+	Description  string   `json:"DESCRIPTION"`
+	Owner        string   `json:"OWNER"`
+	Sender       string   `json:"SENDER"`
+	ProviderID   string   `json:"PROVIDER_ID"`
+	URI          string   `json:"URI"`
+	SharedSecret string   `json:"SHARED_SECRET"`
+	Permissions  []string `json:"PERMISSIONS"`
+	Created      int64    `json:"CREATED"`
+	// Accepted is set once the recipient accepted the share; pending shares are not mounted.
+	Accepted bool `json:"ACCEPTED"`
+}
+
+// NewReceivedShare creates a ReceivedShare from a validated Share.
+func NewReceivedShare(share *Share) *ReceivedShare {
+	id := strings.Replace(uuid.NewUUID().String(), "-", "", -1)
+	return &ReceivedShare{
+		ID:           id,
+		Slug:         slug.Make(share.Name) + "-" + id[0:8],
+		ShareWith:    share.ShareWith,
+		Name:         share.Name,
+		Description:  share.Description,
+		Owner:        share.Owner,
+		Sender:       share.Sender,
+		ProviderID:   share.ProviderID,
+		URI:          share.Protocol.WebDAV.URI,
+		SharedSecret: share.Protocol.WebDAV.SharedSecret,
+		Permissions:  share.Protocol.WebDAV.Permissions,
+		Created:      time.Now().Unix(),
+	}
+}
+
+// Writable checks if the remote server granted write permission.
+func (r *ReceivedShare) Writable() bool {
+	for _, p := range r.Permissions {
+		if p == PermissionWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// DAV returns a client to access the shared resource.
+func (r *ReceivedShare) DAV() *DAVClient {
+	return NewDAVClient(r.URI, r.ProviderID, r.SharedSecret)
+}
+
+// Store persists the shares received from remote servers.
+type Store interface {
+	// PutShare creates or updates a share.
+	PutShare(ctx context.Context, share *ReceivedShare) error
+	// ListShares lists the shares offered to a local user.
+	ListShares(ctx context.Context, shareWith string) ([]*ReceivedShare, error)
+	// GetShare loads a share by its ID, returning nil if it does not exist.
+	GetShare(ctx context.Context, id string) (*ReceivedShare, error)
+	// FindShare finds a share by its provider ID, checking the shared secret.
+	FindShare(ctx context.Context, providerID string, sharedSecret string) (*ReceivedShare, error)
+	// DeleteShare removes a share.
+	DeleteShare(ctx context.Context, id string) error
+}
+
+// MemoryStore is an in-memory implementation of the Store.
+type MemoryStore struct {
+	sync.Mutex
+	shares map[string]*ReceivedShare
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{shares: make(map[string]*ReceivedShare)}
+}
+
+func (m *MemoryStore) PutShare(ctx context.Context, share *ReceivedShare) error {
+	m.Lock()
+	defer m.Unlock()
+	m.shares[share.ID] = share
+	return nil
+}
+
+func (m *MemoryStore) ListShares(ctx context.Context, shareWith string) (shares []*ReceivedShare, err error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.shares {
+		if s.ShareWith == shareWith {
+			shares = append(shares, s)
+		}
+	}
+	return
+}
+
+func (m *MemoryStore) GetShare(ctx context.Context, id string) (*ReceivedShare, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.shares[id], nil
+}
+
+func (m *MemoryStore) FindShare(ctx context.Context, providerID string, sharedSecret string) (*ReceivedShare, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.shares {
+		if s.ProviderID == providerID && s.SharedSecret == sharedSecret {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) DeleteShare(ctx context.Context, id string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.shares, id)
+	return nil
+}
+
+// DocStore stores received shares in the docstore service.
+type DocStore struct{}
+
+// NewDocStore creates a Store backed by the docstore service.
+func NewDocStore() *DocStore {
+	return &DocStore{}
+}
+
+func (d *DocStore) client() docstore.DocStoreClient {
+	return docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+}
+
+func (d *DocStore) PutShare(ctx context.Context, share *ReceivedShare) error {
+	data, err := json.Marshal(share)
+	if err != nil {
+		return err
+	}
+	meta, _ := json.Marshal(map[string]string{"SHARE_WITH": share.ShareWith, "PROVIDER_ID": share.ProviderID})
+	_, err = d.client().PutDocument(ctx, &docstore.PutDocumentRequest{
+		StoreID:    receivedStoreID,
+		DocumentID: share.ID,
+		Document:   &docstore.Document{ID: share.ID, Data: string(data), IndexableMeta: string(meta)},
+	})
+	return err
+}
+
+func (d *DocStore) ListShares(ctx context.Context, shareWith string) ([]*ReceivedShare, error) {
+	return d.search(ctx, "+SHARE_WITH:\""+shareWith+"\"")
+}
+
+func (d *DocStore) GetShare(ctx context.Context, id string) (*ReceivedShare, error) {
+	resp, err := d.client().GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: receivedStoreID, DocumentID: id})
+	if err != nil {
+		if errors.Parse(err.Error()).Code == 404 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if resp.Document == nil {
+		return nil, nil
+	}
+	share := &ReceivedShare{}
+	if err := json.Unmarshal([]byte(resp.Document.Data), share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (d *DocStore) FindShare(ctx context.Context, providerID string, sharedSecret string) (*ReceivedShare, error) {
+	shares, err := d.search(ctx, "+PROVIDER_ID:\""+providerID+"\"")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range shares {
+		if s.SharedSecret == sharedSecret {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *DocStore) DeleteShare(ctx context.Context, id string) error {
+	_, err := d.client().DeleteDocuments(ctx, &docstore.DeleteDocumentsRequest{StoreID: receivedStoreID, DocumentID: id})
+	return err
+}
+
+func (d *DocStore) search(ctx context.Context, query string) (shares []*ReceivedShare, err error) {
+	streamer, err := d.client().ListDocuments(ctx, &docstore.ListDocumentsRequest{StoreID: receivedStoreID, Query: &docstore.DocumentQuery{
+		MetaQuery: query,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+	for {
+		resp, e := streamer.Recv()
+		if e != nil {
+			break
+		}
+		if resp.Document == nil {
+			continue
+		}
+		share := &ReceivedShare{}
+		if e := json.Unmarshal([]byte(resp.Document.Data), share); e == nil {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
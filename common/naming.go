@@ -69,6 +69,7 @@ const (
 	SERVICE_SEARCH   = "search"
 	SERVICE_CHANGES  = "changes"
 	SERVICE_SYNC     = "sync"
+	SERVICE_QUOTA    = "quota"
 
 	SERVICE_ACTIVITY   = "activity"
 	SERVICE_MAILER     = "mailer"
@@ -96,6 +97,7 @@ const (
 	SERVICE_GATEWAY_DATA  = SERVICE_GATEWAY + ".data"
 	SERVICE_GATEWAY_DAV   = SERVICE_GATEWAY + ".dav"
 	SERVICE_GATEWAY_WOPI  = SERVICE_GATEWAY + ".wopi"
+	SERVICE_GATEWAY_OCM   = SERVICE_GATEWAY + ".ocm"
 	SERVICE_MICRO_API     = "micro.api"
 
 	SERVICE_GRPC_NAMESPACE_ = "pydio.grpc."
@@ -114,9 +116,14 @@ const (
 	TOPIC_CHAT_EVENT       = "topic.pydio.chat.event"
 	TOPIC_DATASOURCE_EVENT = "topic.pydio.datasource.event"
 
+	IDM_EVENT_ATTRIBUTE_PASSWORD_CHANGED = "PasswordChanged"
+
 	META_NAMESPACE_DATASOURCE_NAME        = "pydio:meta-data-source-name"
 	META_NAMESPACE_DATASOURCE_PATH        = "pydio:meta-data-source-path"
 	META_NAMESPACE_NODE_TEST_LOCAL_FOLDER = "pydio:test:local-folder-storage"
+	META_NAMESPACE_DAV_PROPERTIES         = "pydio:meta-dav-properties"
+	META_NAMESPACE_RECYCLE_RESTORE        = "pydio:meta-recycle-restore"
+	META_NAMESPACE_RECYCLE_TIME           = "pydio:meta-recycle-time"
 
 	PYDIO_THUMBSTORE_NAMESPACE        = "pydio-thumbstore"
 	PYDIO_DOCSTORE_BINARIES_NAMESPACE = "pydio-binaries"
@@ -127,6 +134,7 @@ const (
 	PYDIO_S3ANON_USERNAME       = "pydio.anon.user"
 	PYDIO_S3ANON_PROFILE        = "anon"
 	PYDIO_SYNC_HIDDEN_FILE_META = ".pydio"
+	PYDIO_RECYCLE_BIN_NAME      = "recycle_bin"
 
 	PYDIO_PROFILE_ADMIN    = "admin"
 	PYDIO_PROFILE_STANDARD = "standard"
@@ -142,6 +142,10 @@ type PutDocumentRequest struct {
 	StoreID    string    `protobuf:"bytes,1,opt,name=StoreID" json:"StoreID,omitempty"`
 	DocumentID string    `protobuf:"bytes,2,opt,name=DocumentID" json:"DocumentID,omitempty"`
 	Document   *Document `protobuf:"bytes,3,opt,name=Document" json:"Document,omitempty"`
+	// If set, the document is only stored if its current Data is equal to IfMatchData,
+	// an empty IfMatchData meaning that the document must not exist yet
+	IfMatch     bool   `protobuf:"varint,4,opt,name=IfMatch" json:"IfMatch,omitempty"`
+	IfMatchData string `protobuf:"bytes,5,opt,name=IfMatchData" json:"IfMatchData,omitempty"`
 }
 
 func (m *PutDocumentRequest) Reset()                    { *m = PutDocumentRequest{} }
@@ -170,6 +174,20 @@ func (m *PutDocumentRequest) GetDocument() *Document {
 	return nil
 }
 
+func (m *PutDocumentRequest) GetIfMatch() bool {
+	if m != nil {
+		return m.IfMatch
+	}
+	return false
+}
+
+func (m *PutDocumentRequest) GetIfMatchData() string {
+	if m != nil {
+		return m.IfMatchData
+	}
+	return ""
+}
+
 type PutDocumentResponse struct {
 	Document *Document `protobuf:"bytes,1,opt,name=Document" json:"Document,omitempty"`
 }
@@ -16,6 +16,10 @@ It has these top-level messages:
 	AddKeyResponse
 	GetKeyRequest
 	GetKeyResponse
+	ListUserKeysRequest
+	ListUserKeysResponse
+	DeleteUserKeyRequest
+	DeleteUserKeyResponse
 	AdminListKeysRequest
 	AdminListKeysResponse
 	AdminDeleteKeyRequest
@@ -74,6 +78,8 @@ var _ server.Option
 type UserKeyStoreClient interface {
 	AddKey(ctx context.Context, in *AddKeyRequest, opts ...client.CallOption) (*AddKeyResponse, error)
 	GetKey(ctx context.Context, in *GetKeyRequest, opts ...client.CallOption) (*GetKeyResponse, error)
+	ListUserKeys(ctx context.Context, in *ListUserKeysRequest, opts ...client.CallOption) (*ListUserKeysResponse, error)
+	DeleteUserKey(ctx context.Context, in *DeleteUserKeyRequest, opts ...client.CallOption) (*DeleteUserKeyResponse, error)
 	AdminListKeys(ctx context.Context, in *AdminListKeysRequest, opts ...client.CallOption) (*AdminListKeysResponse, error)
 	AdminCreateKey(ctx context.Context, in *AdminCreateKeyRequest, opts ...client.CallOption) (*AdminCreateKeyResponse, error)
 	AdminDeleteKey(ctx context.Context, in *AdminDeleteKeyRequest, opts ...client.CallOption) (*AdminDeleteKeyResponse, error)
@@ -119,6 +125,26 @@ func (c *userKeyStoreClient) GetKey(ctx context.Context, in *GetKeyRequest, opts
 	return out, nil
 }
 
+func (c *userKeyStoreClient) ListUserKeys(ctx context.Context, in *ListUserKeysRequest, opts ...client.CallOption) (*ListUserKeysResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "UserKeyStore.ListUserKeys", in)
+	out := new(ListUserKeysResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userKeyStoreClient) DeleteUserKey(ctx context.Context, in *DeleteUserKeyRequest, opts ...client.CallOption) (*DeleteUserKeyResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "UserKeyStore.DeleteUserKey", in)
+	out := new(DeleteUserKeyResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userKeyStoreClient) AdminListKeys(ctx context.Context, in *AdminListKeysRequest, opts ...client.CallOption) (*AdminListKeysResponse, error) {
 	req := c.c.NewRequest(c.serviceName, "UserKeyStore.AdminListKeys", in)
 	out := new(AdminListKeysResponse)
@@ -174,6 +200,8 @@ func (c *userKeyStoreClient) AdminImportKey(ctx context.Context, in *AdminImport
 type UserKeyStoreHandler interface {
 	AddKey(context.Context, *AddKeyRequest, *AddKeyResponse) error
 	GetKey(context.Context, *GetKeyRequest, *GetKeyResponse) error
+	ListUserKeys(context.Context, *ListUserKeysRequest, *ListUserKeysResponse) error
+	DeleteUserKey(context.Context, *DeleteUserKeyRequest, *DeleteUserKeyResponse) error
 	AdminListKeys(context.Context, *AdminListKeysRequest, *AdminListKeysResponse) error
 	AdminCreateKey(context.Context, *AdminCreateKeyRequest, *AdminCreateKeyResponse) error
 	AdminDeleteKey(context.Context, *AdminDeleteKeyRequest, *AdminDeleteKeyResponse) error
@@ -197,6 +225,14 @@ func (h *UserKeyStore) GetKey(ctx context.Context, in *GetKeyRequest, out *GetKe
 	return h.UserKeyStoreHandler.GetKey(ctx, in, out)
 }
 
+func (h *UserKeyStore) ListUserKeys(ctx context.Context, in *ListUserKeysRequest, out *ListUserKeysResponse) error {
+	return h.UserKeyStoreHandler.ListUserKeys(ctx, in, out)
+}
+
+func (h *UserKeyStore) DeleteUserKey(ctx context.Context, in *DeleteUserKeyRequest, out *DeleteUserKeyResponse) error {
+	return h.UserKeyStoreHandler.DeleteUserKey(ctx, in, out)
+}
+
 func (h *UserKeyStore) AdminListKeys(ctx context.Context, in *AdminListKeysRequest, out *AdminListKeysResponse) error {
 	return h.UserKeyStoreHandler.AdminListKeys(ctx, in, out)
 }
@@ -16,6 +16,10 @@ It has these top-level messages:
 	AddKeyResponse
 	GetKeyRequest
 	GetKeyResponse
+	ListUserKeysRequest
+	ListUserKeysResponse
+	DeleteUserKeyRequest
+	DeleteUserKeyResponse
 	AdminListKeysRequest
 	AdminListKeysResponse
 	AdminDeleteKeyRequest
@@ -189,6 +193,8 @@ func (m *Key) GetInfo() *KeyInfo {
 type AddKeyRequest struct {
 	Key         *Key   `protobuf:"bytes,1,opt,name=Key" json:"Key,omitempty"`
 	StrPassword string `protobuf:"bytes,2,opt,name=StrPassword" json:"StrPassword,omitempty"`
+	// CreateOnly fails instead of replacing a key that already exists with this ID
+	CreateOnly bool `protobuf:"varint,3,opt,name=CreateOnly" json:"CreateOnly,omitempty"`
 }
 
 func (m *AddKeyRequest) Reset()                    { *m = AddKeyRequest{} }
@@ -210,6 +216,13 @@ func (m *AddKeyRequest) GetStrPassword() string {
 	return ""
 }
 
+func (m *AddKeyRequest) GetCreateOnly() bool {
+	if m != nil {
+		return m.CreateOnly
+	}
+	return false
+}
+
 type AddKeyResponse struct {
 	Success bool `protobuf:"varint,1,opt,name=Success" json:"Success,omitempty"`
 }
@@ -274,6 +287,74 @@ func (m *GetKeyResponse) GetKey() *Key {
 	return nil
 }
 
+type ListUserKeysRequest struct {
+	Owner string `protobuf:"bytes,1,opt,name=Owner" json:"Owner,omitempty"`
+}
+
+func (m *ListUserKeysRequest) Reset()         { *m = ListUserKeysRequest{} }
+func (m *ListUserKeysRequest) String() string { return proto.CompactTextString(m) }
+func (*ListUserKeysRequest) ProtoMessage()    {}
+
+func (m *ListUserKeysRequest) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+type ListUserKeysResponse struct {
+	Keys []*Key `protobuf:"bytes,1,rep,name=Keys" json:"Keys,omitempty"`
+}
+
+func (m *ListUserKeysResponse) Reset()         { *m = ListUserKeysResponse{} }
+func (m *ListUserKeysResponse) String() string { return proto.CompactTextString(m) }
+func (*ListUserKeysResponse) ProtoMessage()    {}
+
+func (m *ListUserKeysResponse) GetKeys() []*Key {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type DeleteUserKeyRequest struct {
+	Owner string `protobuf:"bytes,1,opt,name=Owner" json:"Owner,omitempty"`
+	KeyID string `protobuf:"bytes,2,opt,name=KeyID" json:"KeyID,omitempty"`
+}
+
+func (m *DeleteUserKeyRequest) Reset()         { *m = DeleteUserKeyRequest{} }
+func (m *DeleteUserKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteUserKeyRequest) ProtoMessage()    {}
+
+func (m *DeleteUserKeyRequest) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *DeleteUserKeyRequest) GetKeyID() string {
+	if m != nil {
+		return m.KeyID
+	}
+	return ""
+}
+
+type DeleteUserKeyResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=Success" json:"Success,omitempty"`
+}
+
+func (m *DeleteUserKeyResponse) Reset()         { *m = DeleteUserKeyResponse{} }
+func (m *DeleteUserKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteUserKeyResponse) ProtoMessage()    {}
+
+func (m *DeleteUserKeyResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
 type AdminListKeysRequest struct {
 }
 
@@ -612,6 +693,7 @@ func (*SetNodeParamsResponse) Descriptor() ([]byte, []int) { return fileDescript
 
 type GetNodeKeyRequest struct {
 	NodeId string `protobuf:"bytes,1,opt,name=NodeId" json:"NodeId,omitempty"`
+	// UserId is left empty to get the key of the user owning the node content
 	UserId string `protobuf:"bytes,2,opt,name=UserId" json:"UserId,omitempty"`
 }
 
@@ -779,6 +861,10 @@ func init() {
 	proto.RegisterType((*AddKeyResponse)(nil), "encryption.AddKeyResponse")
 	proto.RegisterType((*GetKeyRequest)(nil), "encryption.GetKeyRequest")
 	proto.RegisterType((*GetKeyResponse)(nil), "encryption.GetKeyResponse")
+	proto.RegisterType((*ListUserKeysRequest)(nil), "encryption.ListUserKeysRequest")
+	proto.RegisterType((*ListUserKeysResponse)(nil), "encryption.ListUserKeysResponse")
+	proto.RegisterType((*DeleteUserKeyRequest)(nil), "encryption.DeleteUserKeyRequest")
+	proto.RegisterType((*DeleteUserKeyResponse)(nil), "encryption.DeleteUserKeyResponse")
 	proto.RegisterType((*AdminListKeysRequest)(nil), "encryption.AdminListKeysRequest")
 	proto.RegisterType((*AdminListKeysResponse)(nil), "encryption.AdminListKeysResponse")
 	proto.RegisterType((*AdminDeleteKeyRequest)(nil), "encryption.AdminDeleteKeyRequest")
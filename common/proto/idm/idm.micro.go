@@ -1333,6 +1333,7 @@ type PolicyEngineServiceClient interface {
 	StorePolicyGroup(ctx context.Context, in *StorePolicyGroupRequest, opts ...client.CallOption) (*StorePolicyGroupResponse, error)
 	ListPolicyGroups(ctx context.Context, in *ListPolicyGroupsRequest, opts ...client.CallOption) (*ListPolicyGroupsResponse, error)
 	DeletePolicyGroup(ctx context.Context, in *DeletePolicyGroupRequest, opts ...client.CallOption) (*DeletePolicyGroupResponse, error)
+	ExplainPolicy(ctx context.Context, in *PolicyExplainRequest, opts ...client.CallOption) (*PolicyExplainResponse, error)
 }
 
 type policyEngineServiceClient struct {
@@ -1393,6 +1394,16 @@ func (c *policyEngineServiceClient) DeletePolicyGroup(ctx context.Context, in *D
 	return out, nil
 }
 
+func (c *policyEngineServiceClient) ExplainPolicy(ctx context.Context, in *PolicyExplainRequest, opts ...client.CallOption) (*PolicyExplainResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "PolicyEngineService.ExplainPolicy", in)
+	out := new(PolicyExplainResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for PolicyEngineService service
 
 type PolicyEngineServiceHandler interface {
@@ -1400,6 +1411,7 @@ type PolicyEngineServiceHandler interface {
 	StorePolicyGroup(context.Context, *StorePolicyGroupRequest, *StorePolicyGroupResponse) error
 	ListPolicyGroups(context.Context, *ListPolicyGroupsRequest, *ListPolicyGroupsResponse) error
 	DeletePolicyGroup(context.Context, *DeletePolicyGroupRequest, *DeletePolicyGroupResponse) error
+	ExplainPolicy(context.Context, *PolicyExplainRequest, *PolicyExplainResponse) error
 }
 
 func RegisterPolicyEngineServiceHandler(s server.Server, hdlr PolicyEngineServiceHandler, opts ...server.HandlerOption) {
@@ -1425,3 +1437,7 @@ func (h *PolicyEngineService) ListPolicyGroups(ctx context.Context, in *ListPoli
 func (h *PolicyEngineService) DeletePolicyGroup(ctx context.Context, in *DeletePolicyGroupRequest, out *DeletePolicyGroupResponse) error {
 	return h.PolicyEngineServiceHandler.DeletePolicyGroup(ctx, in, out)
 }
+
+func (h *PolicyEngineService) ExplainPolicy(ctx context.Context, in *PolicyExplainRequest, out *PolicyExplainResponse) error {
+	return h.PolicyEngineServiceHandler.ExplainPolicy(ctx, in, out)
+}
@@ -86,6 +86,10 @@ It has these top-level messages:
 	DeletePolicyGroupResponse
 	ListPolicyGroupsRequest
 	ListPolicyGroupsResponse
+	PolicyExplainRequest
+	PolicyConditionEvaluation
+	PolicyExplanation
+	PolicyExplainResponse
 */
 package idm
 
@@ -1128,6 +1132,9 @@ func (m *WorkspaceSingleQuery) GetNot() bool {
 // *****************************************************************************
 type CreateACLRequest struct {
 	ACL *ACL `protobuf:"bytes,1,opt,name=ACL" json:"ACL,omitempty"`
+	// When set, an existing ACL with the same action, role, workspace and node is updated,
+	// only if its current value is still ExpectedValue
+	ExpectedValue string `protobuf:"bytes,2,opt,name=ExpectedValue" json:"ExpectedValue,omitempty"`
 }
 
 func (m *CreateACLRequest) Reset()                    { *m = CreateACLRequest{} }
@@ -1142,6 +1149,13 @@ func (m *CreateACLRequest) GetACL() *ACL {
 	return nil
 }
 
+func (m *CreateACLRequest) GetExpectedValue() string {
+	if m != nil {
+		return m.ExpectedValue
+	}
+	return ""
+}
+
 type CreateACLResponse struct {
 	ACL *ACL `protobuf:"bytes,1,opt,name=ACL" json:"ACL,omitempty"`
 }
@@ -1252,6 +1266,9 @@ type ACL struct {
 	RoleID      string     `protobuf:"bytes,3,opt,name=RoleID" json:"RoleID,omitempty"`
 	WorkspaceID string     `protobuf:"bytes,4,opt,name=WorkspaceID" json:"WorkspaceID,omitempty"`
 	NodeID      string     `protobuf:"bytes,5,opt,name=NodeID" json:"NodeID,omitempty"`
+	// Validity window of the ACL as unix timestamps, 0 meaning no limit
+	NotBefore int32 `protobuf:"varint,6,opt,name=NotBefore" json:"NotBefore,omitempty"`
+	ExpiresAt int32 `protobuf:"varint,7,opt,name=ExpiresAt" json:"ExpiresAt,omitempty"`
 }
 
 func (m *ACL) Reset()                    { *m = ACL{} }
@@ -1294,12 +1311,31 @@ func (m *ACL) GetNodeID() string {
 	return ""
 }
 
+func (m *ACL) GetNotBefore() int32 {
+	if m != nil {
+		return m.NotBefore
+	}
+	return 0
+}
+
+func (m *ACL) GetExpiresAt() int32 {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return 0
+}
+
 type ACLSingleQuery struct {
 	Actions      []*ACLAction `protobuf:"bytes,1,rep,name=Actions" json:"Actions,omitempty"`
 	RoleIDs      []string     `protobuf:"bytes,2,rep,name=RoleIDs" json:"RoleIDs,omitempty"`
 	WorkspaceIDs []string     `protobuf:"bytes,3,rep,name=WorkspaceIDs" json:"WorkspaceIDs,omitempty"`
 	NodeIDs      []string     `protobuf:"bytes,4,rep,name=NodeIDs" json:"NodeIDs,omitempty"`
-	Not          bool         `protobuf:"varint,5,opt,name=not" json:"not,omitempty"`
+	// Exclude the roles, workspaces, nodes and action values instead of matching them
+	Not bool `protobuf:"varint,5,opt,name=not" json:"not,omitempty"`
+	// Also find ACLs that are not yet or no longer valid
+	IncludeInactive bool `protobuf:"varint,6,opt,name=IncludeInactive" json:"IncludeInactive,omitempty"`
+	// Find ACLs that expired before this unix timestamp
+	ExpiredBefore int32 `protobuf:"varint,7,opt,name=ExpiredBefore" json:"ExpiredBefore,omitempty"`
 }
 
 func (m *ACLSingleQuery) Reset()                    { *m = ACLSingleQuery{} }
@@ -1342,6 +1378,20 @@ func (m *ACLSingleQuery) GetNot() bool {
 	return false
 }
 
+func (m *ACLSingleQuery) GetIncludeInactive() bool {
+	if m != nil {
+		return m.IncludeInactive
+	}
+	return false
+}
+
+func (m *ACLSingleQuery) GetExpiredBefore() int32 {
+	if m != nil {
+		return m.ExpiredBefore
+	}
+	return 0
+}
+
 // Piece of metadata attached to a node
 type UserMeta struct {
 	Uuid                    string                    `protobuf:"bytes,1,opt,name=Uuid" json:"Uuid,omitempty"`
@@ -2023,6 +2073,158 @@ func (m *ListPolicyGroupsResponse) GetTotal() int32 {
 	return 0
 }
 
+type PolicyExplainRequest struct {
+	// Request to evaluate
+	Request *PolicyEngineRequest `protobuf:"bytes,1,opt,name=Request" json:"Request,omitempty"`
+	// Optional group evaluated in place of the stored group with the same Uuid, without saving it
+	DryRunGroup *PolicyGroup `protobuf:"bytes,2,opt,name=DryRunGroup" json:"DryRunGroup,omitempty"`
+}
+
+func (m *PolicyExplainRequest) Reset()         { *m = PolicyExplainRequest{} }
+func (m *PolicyExplainRequest) String() string { return proto.CompactTextString(m) }
+func (*PolicyExplainRequest) ProtoMessage()    {}
+
+func (m *PolicyExplainRequest) GetRequest() *PolicyEngineRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *PolicyExplainRequest) GetDryRunGroup() *PolicyGroup {
+	if m != nil {
+		return m.DryRunGroup
+	}
+	return nil
+}
+
+type PolicyConditionEvaluation struct {
+	Key       string `protobuf:"bytes,1,opt,name=Key" json:"Key,omitempty"`
+	Type      string `protobuf:"bytes,2,opt,name=Type" json:"Type,omitempty"`
+	Value     string `protobuf:"bytes,3,opt,name=Value" json:"Value,omitempty"`
+	Fulfilled bool   `protobuf:"varint,4,opt,name=Fulfilled" json:"Fulfilled,omitempty"`
+}
+
+func (m *PolicyConditionEvaluation) Reset()         { *m = PolicyConditionEvaluation{} }
+func (m *PolicyConditionEvaluation) String() string { return proto.CompactTextString(m) }
+func (*PolicyConditionEvaluation) ProtoMessage()    {}
+
+func (m *PolicyConditionEvaluation) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *PolicyConditionEvaluation) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *PolicyConditionEvaluation) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *PolicyConditionEvaluation) GetFulfilled() bool {
+	if m != nil {
+		return m.Fulfilled
+	}
+	return false
+}
+
+type PolicyExplanation struct {
+	GroupUuid  string                       `protobuf:"bytes,1,opt,name=GroupUuid" json:"GroupUuid,omitempty"`
+	GroupName  string                       `protobuf:"bytes,2,opt,name=GroupName" json:"GroupName,omitempty"`
+	Subject    string                       `protobuf:"bytes,3,opt,name=Subject" json:"Subject,omitempty"`
+	Policy     *Policy                      `protobuf:"bytes,4,opt,name=Policy" json:"Policy,omitempty"`
+	Conditions []*PolicyConditionEvaluation `protobuf:"bytes,5,rep,name=Conditions" json:"Conditions,omitempty"`
+	// True if all conditions are fulfilled and the policy takes part in the decision
+	Applied bool `protobuf:"varint,6,opt,name=Applied" json:"Applied,omitempty"`
+}
+
+func (m *PolicyExplanation) Reset()         { *m = PolicyExplanation{} }
+func (m *PolicyExplanation) String() string { return proto.CompactTextString(m) }
+func (*PolicyExplanation) ProtoMessage()    {}
+
+func (m *PolicyExplanation) GetGroupUuid() string {
+	if m != nil {
+		return m.GroupUuid
+	}
+	return ""
+}
+
+func (m *PolicyExplanation) GetGroupName() string {
+	if m != nil {
+		return m.GroupName
+	}
+	return ""
+}
+
+func (m *PolicyExplanation) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *PolicyExplanation) GetPolicy() *Policy {
+	if m != nil {
+		return m.Policy
+	}
+	return nil
+}
+
+func (m *PolicyExplanation) GetConditions() []*PolicyConditionEvaluation {
+	if m != nil {
+		return m.Conditions
+	}
+	return nil
+}
+
+func (m *PolicyExplanation) GetApplied() bool {
+	if m != nil {
+		return m.Applied
+	}
+	return false
+}
+
+type PolicyExplainResponse struct {
+	Decision     *PolicyEngineResponse `protobuf:"bytes,1,opt,name=Decision" json:"Decision,omitempty"`
+	Explanations []*PolicyExplanation  `protobuf:"bytes,2,rep,name=Explanations" json:"Explanations,omitempty"`
+	// Context after enrichment with the user attributes
+	Context map[string]string `protobuf:"bytes,3,rep,name=Context" json:"Context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PolicyExplainResponse) Reset()         { *m = PolicyExplainResponse{} }
+func (m *PolicyExplainResponse) String() string { return proto.CompactTextString(m) }
+func (*PolicyExplainResponse) ProtoMessage()    {}
+
+func (m *PolicyExplainResponse) GetDecision() *PolicyEngineResponse {
+	if m != nil {
+		return m.Decision
+	}
+	return nil
+}
+
+func (m *PolicyExplainResponse) GetExplanations() []*PolicyExplanation {
+	if m != nil {
+		return m.Explanations
+	}
+	return nil
+}
+
+func (m *PolicyExplainResponse) GetContext() map[string]string {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*CreateRoleRequest)(nil), "idm.CreateRoleRequest")
 	proto.RegisterType((*CreateRoleResponse)(nil), "idm.CreateRoleResponse")
@@ -2082,6 +2284,10 @@ func init() {
 	proto.RegisterType((*DeletePolicyGroupResponse)(nil), "idm.DeletePolicyGroupResponse")
 	proto.RegisterType((*ListPolicyGroupsRequest)(nil), "idm.ListPolicyGroupsRequest")
 	proto.RegisterType((*ListPolicyGroupsResponse)(nil), "idm.ListPolicyGroupsResponse")
+	proto.RegisterType((*PolicyExplainRequest)(nil), "idm.PolicyExplainRequest")
+	proto.RegisterType((*PolicyConditionEvaluation)(nil), "idm.PolicyConditionEvaluation")
+	proto.RegisterType((*PolicyExplanation)(nil), "idm.PolicyExplanation")
+	proto.RegisterType((*PolicyExplainResponse)(nil), "idm.PolicyExplainResponse")
 	proto.RegisterEnum("idm.NodeType", NodeType_name, NodeType_value)
 	proto.RegisterEnum("idm.WorkspaceScope", WorkspaceScope_name, WorkspaceScope_value)
 	proto.RegisterEnum("idm.ChangeEventType", ChangeEventType_name, ChangeEventType_value)
@@ -87,7 +87,7 @@ func (n *NodesSelector) Select(cl client.Client, ctx context.Context, objects ch
 			if rE != nil {
 				break
 			}
-			if resp == nil {
+			if resp == nil || resp.Node == nil {
 				continue
 			}
 			objects <- resp.Node
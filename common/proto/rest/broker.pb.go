@@ -57,6 +57,15 @@ It has these top-level messages:
 	DocstoreCollection
 	ChangeRequest
 	ChangeCollection
+	UserUsage
+	UserUsageRequest
+	ListUserUsagesRequest
+	UserUsageCollection
+	ListNodeVersionsRequest
+	NodeVersionsCollection
+	RestoreVersionRequest
+	RestoreVersionResponse
+	UpdateVersionRequest
 	FrontLogMessage
 	FrontLogResponse
 	SettingsMenuRequest
@@ -85,6 +94,18 @@ It has these top-level messages:
 	UserBookmarksRequest
 	RevokeRequest
 	RevokeResponse
+	CreateAppPasswordRequest
+	ListAppPasswordsRequest
+	AppPassword
+	AppPasswordCollection
+	RevokeAppPasswordRequest
+	SecondFactorStatusRequest
+	SecondFactorStatus
+	EnrollSecondFactorRequest
+	SecondFactorEnrollment
+	ConfirmSecondFactorRequest
+	SecondFactorRecoveryCodes
+	DisableSecondFactorRequest
 	ResetPasswordTokenRequest
 	ResetPasswordTokenResponse
 	ResetPasswordRequest
@@ -94,6 +115,7 @@ It has these top-level messages:
 	UserJobsCollection
 	CellAcl
 	Cell
+	CellRemoteUser
 	ShareLinkTargetUser
 	ShareLink
 	PutCellRequest
@@ -15,8 +15,9 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type SearchResults struct {
-	Results []*tree.Node `protobuf:"bytes,1,rep,name=Results" json:"Results,omitempty"`
-	Total   int32        `protobuf:"varint,2,opt,name=Total" json:"Total,omitempty"`
+	Results []*tree.Node        `protobuf:"bytes,1,rep,name=Results" json:"Results,omitempty"`
+	Total   int32               `protobuf:"varint,2,opt,name=Total" json:"Total,omitempty"`
+	Facets  []*tree.SearchFacet `protobuf:"bytes,3,rep,name=Facets" json:"Facets,omitempty"`
 }
 
 func (m *SearchResults) Reset()                    { *m = SearchResults{} }
@@ -38,6 +39,13 @@ func (m *SearchResults) GetTotal() int32 {
 	return 0
 }
 
+func (m *SearchResults) GetFacets() []*tree.SearchFacet {
+	if m != nil {
+		return m.Facets
+	}
+	return nil
+}
+
 type Metadata struct {
 	Namespace string `protobuf:"bytes,1,opt,name=Namespace" json:"Namespace,omitempty"`
 	JsonMeta  string `protobuf:"bytes,2,opt,name=JsonMeta" json:"JsonMeta,omitempty"`
@@ -342,6 +350,206 @@ func (m *ChangeCollection) GetLastSeqId() int64 {
 	return 0
 }
 
+// Storage used by a user, with the quota applying to them
+type UserUsage struct {
+	Login string `protobuf:"bytes,1,opt,name=Login" json:"Login,omitempty"`
+	Bytes int64  `protobuf:"varint,2,opt,name=Bytes" json:"Bytes,omitempty"`
+	Files int64  `protobuf:"varint,3,opt,name=Files" json:"Files,omitempty"`
+	Quota int64  `protobuf:"varint,4,opt,name=Quota" json:"Quota,omitempty"`
+}
+
+func (m *UserUsage) Reset()         { *m = UserUsage{} }
+func (m *UserUsage) String() string { return proto.CompactTextString(m) }
+func (*UserUsage) ProtoMessage()    {}
+
+func (m *UserUsage) GetLogin() string {
+	if m != nil {
+		return m.Login
+	}
+	return ""
+}
+
+func (m *UserUsage) GetBytes() int64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *UserUsage) GetFiles() int64 {
+	if m != nil {
+		return m.Files
+	}
+	return 0
+}
+
+func (m *UserUsage) GetQuota() int64 {
+	if m != nil {
+		return m.Quota
+	}
+	return 0
+}
+
+type UserUsageRequest struct {
+}
+
+func (m *UserUsageRequest) Reset()         { *m = UserUsageRequest{} }
+func (m *UserUsageRequest) String() string { return proto.CompactTextString(m) }
+func (*UserUsageRequest) ProtoMessage()    {}
+
+type ListUserUsagesRequest struct {
+	Limit  int32 `protobuf:"varint,1,opt,name=Limit" json:"Limit,omitempty"`
+	Offset int32 `protobuf:"varint,2,opt,name=Offset" json:"Offset,omitempty"`
+}
+
+func (m *ListUserUsagesRequest) Reset()         { *m = ListUserUsagesRequest{} }
+func (m *ListUserUsagesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListUserUsagesRequest) ProtoMessage()    {}
+
+func (m *ListUserUsagesRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *ListUserUsagesRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type UserUsageCollection struct {
+	Usages []*UserUsage `protobuf:"bytes,1,rep,name=Usages" json:"Usages,omitempty"`
+}
+
+func (m *UserUsageCollection) Reset()         { *m = UserUsageCollection{} }
+func (m *UserUsageCollection) String() string { return proto.CompactTextString(m) }
+func (*UserUsageCollection) ProtoMessage()    {}
+
+func (m *UserUsageCollection) GetUsages() []*UserUsage {
+	if m != nil {
+		return m.Usages
+	}
+	return nil
+}
+
+type ListNodeVersionsRequest struct {
+	NodePath string `protobuf:"bytes,1,opt,name=NodePath" json:"NodePath,omitempty"`
+}
+
+func (m *ListNodeVersionsRequest) Reset()         { *m = ListNodeVersionsRequest{} }
+func (m *ListNodeVersionsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListNodeVersionsRequest) ProtoMessage()    {}
+
+func (m *ListNodeVersionsRequest) GetNodePath() string {
+	if m != nil {
+		return m.NodePath
+	}
+	return ""
+}
+
+type NodeVersionsCollection struct {
+	Versions []*tree.ChangeLog `protobuf:"bytes,1,rep,name=Versions" json:"Versions,omitempty"`
+}
+
+func (m *NodeVersionsCollection) Reset()         { *m = NodeVersionsCollection{} }
+func (m *NodeVersionsCollection) String() string { return proto.CompactTextString(m) }
+func (*NodeVersionsCollection) ProtoMessage()    {}
+
+func (m *NodeVersionsCollection) GetVersions() []*tree.ChangeLog {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+type RestoreVersionRequest struct {
+	NodePath  string `protobuf:"bytes,1,opt,name=NodePath" json:"NodePath,omitempty"`
+	VersionId string `protobuf:"bytes,2,opt,name=VersionId" json:"VersionId,omitempty"`
+}
+
+func (m *RestoreVersionRequest) Reset()         { *m = RestoreVersionRequest{} }
+func (m *RestoreVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionRequest) ProtoMessage()    {}
+
+func (m *RestoreVersionRequest) GetNodePath() string {
+	if m != nil {
+		return m.NodePath
+	}
+	return ""
+}
+
+func (m *RestoreVersionRequest) GetVersionId() string {
+	if m != nil {
+		return m.VersionId
+	}
+	return ""
+}
+
+type RestoreVersionResponse struct {
+	Node *tree.Node `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
+}
+
+func (m *RestoreVersionResponse) Reset()         { *m = RestoreVersionResponse{} }
+func (m *RestoreVersionResponse) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionResponse) ProtoMessage()    {}
+
+func (m *RestoreVersionResponse) GetNode() *tree.Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+type UpdateVersionRequest struct {
+	NodePath    string `protobuf:"bytes,1,opt,name=NodePath" json:"NodePath,omitempty"`
+	VersionId   string `protobuf:"bytes,2,opt,name=VersionId" json:"VersionId,omitempty"`
+	Label       string `protobuf:"bytes,3,opt,name=Label" json:"Label,omitempty"`
+	Pinned      bool   `protobuf:"varint,4,opt,name=Pinned" json:"Pinned,omitempty"`
+	Description string `protobuf:"bytes,5,opt,name=Description" json:"Description,omitempty"`
+}
+
+func (m *UpdateVersionRequest) Reset()         { *m = UpdateVersionRequest{} }
+func (m *UpdateVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateVersionRequest) ProtoMessage()    {}
+
+func (m *UpdateVersionRequest) GetNodePath() string {
+	if m != nil {
+		return m.NodePath
+	}
+	return ""
+}
+
+func (m *UpdateVersionRequest) GetVersionId() string {
+	if m != nil {
+		return m.VersionId
+	}
+	return ""
+}
+
+func (m *UpdateVersionRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *UpdateVersionRequest) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *UpdateVersionRequest) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*SearchResults)(nil), "rest.SearchResults")
 	proto.RegisterType((*Metadata)(nil), "rest.Metadata")
@@ -356,6 +564,15 @@ func init() {
 	proto.RegisterType((*DocstoreCollection)(nil), "rest.DocstoreCollection")
 	proto.RegisterType((*ChangeRequest)(nil), "rest.ChangeRequest")
 	proto.RegisterType((*ChangeCollection)(nil), "rest.ChangeCollection")
+	proto.RegisterType((*UserUsage)(nil), "rest.UserUsage")
+	proto.RegisterType((*UserUsageRequest)(nil), "rest.UserUsageRequest")
+	proto.RegisterType((*ListUserUsagesRequest)(nil), "rest.ListUserUsagesRequest")
+	proto.RegisterType((*UserUsageCollection)(nil), "rest.UserUsageCollection")
+	proto.RegisterType((*ListNodeVersionsRequest)(nil), "rest.ListNodeVersionsRequest")
+	proto.RegisterType((*NodeVersionsCollection)(nil), "rest.NodeVersionsCollection")
+	proto.RegisterType((*RestoreVersionRequest)(nil), "rest.RestoreVersionRequest")
+	proto.RegisterType((*RestoreVersionResponse)(nil), "rest.RestoreVersionResponse")
+	proto.RegisterType((*UpdateVersionRequest)(nil), "rest.UpdateVersionRequest")
 }
 
 func init() { proto.RegisterFile("data.proto", fileDescriptor3) }
@@ -522,6 +522,230 @@ func (m *RevokeResponse) GetMessage() string {
 	return ""
 }
 
+// Create a new application password for the current user
+type CreateAppPasswordRequest struct {
+	Label string `protobuf:"bytes,1,opt,name=Label" json:"Label,omitempty"`
+}
+
+func (m *CreateAppPasswordRequest) Reset()         { *m = CreateAppPasswordRequest{} }
+func (m *CreateAppPasswordRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateAppPasswordRequest) ProtoMessage()    {}
+
+func (m *CreateAppPasswordRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+// List application passwords of the current user
+type ListAppPasswordsRequest struct {
+}
+
+func (m *ListAppPasswordsRequest) Reset()         { *m = ListAppPasswordsRequest{} }
+func (m *ListAppPasswordsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListAppPasswordsRequest) ProtoMessage()    {}
+
+// Application password, the Password is only sent back at creation time
+type AppPassword struct {
+	Id           string `protobuf:"bytes,1,opt,name=Id" json:"Id,omitempty"`
+	Label        string `protobuf:"bytes,2,opt,name=Label" json:"Label,omitempty"`
+	CreationDate int32  `protobuf:"varint,3,opt,name=CreationDate" json:"CreationDate,omitempty"`
+	Password     string `protobuf:"bytes,4,opt,name=Password" json:"Password,omitempty"`
+}
+
+func (m *AppPassword) Reset()         { *m = AppPassword{} }
+func (m *AppPassword) String() string { return proto.CompactTextString(m) }
+func (*AppPassword) ProtoMessage()    {}
+
+func (m *AppPassword) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *AppPassword) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *AppPassword) GetCreationDate() int32 {
+	if m != nil {
+		return m.CreationDate
+	}
+	return 0
+}
+
+func (m *AppPassword) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+// Collection of application passwords
+type AppPasswordCollection struct {
+	AppPasswords []*AppPassword `protobuf:"bytes,1,rep,name=AppPasswords" json:"AppPasswords,omitempty"`
+}
+
+func (m *AppPasswordCollection) Reset()         { *m = AppPasswordCollection{} }
+func (m *AppPasswordCollection) String() string { return proto.CompactTextString(m) }
+func (*AppPasswordCollection) ProtoMessage()    {}
+
+func (m *AppPasswordCollection) GetAppPasswords() []*AppPassword {
+	if m != nil {
+		return m.AppPasswords
+	}
+	return nil
+}
+
+// Revoke an application password by its Id
+type RevokeAppPasswordRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=Id" json:"Id,omitempty"`
+}
+
+func (m *RevokeAppPasswordRequest) Reset()         { *m = RevokeAppPasswordRequest{} }
+func (m *RevokeAppPasswordRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeAppPasswordRequest) ProtoMessage()    {}
+
+func (m *RevokeAppPasswordRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// Get the second factor status of the current user
+type SecondFactorStatusRequest struct {
+}
+
+func (m *SecondFactorStatusRequest) Reset()         { *m = SecondFactorStatusRequest{} }
+func (m *SecondFactorStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*SecondFactorStatusRequest) ProtoMessage()    {}
+
+// Second factor status of a user
+type SecondFactorStatus struct {
+	Enrolled          bool  `protobuf:"varint,1,opt,name=Enrolled" json:"Enrolled,omitempty"`
+	Required          bool  `protobuf:"varint,2,opt,name=Required" json:"Required,omitempty"`
+	RecoveryCodesLeft int32 `protobuf:"varint,3,opt,name=RecoveryCodesLeft" json:"RecoveryCodesLeft,omitempty"`
+}
+
+func (m *SecondFactorStatus) Reset()         { *m = SecondFactorStatus{} }
+func (m *SecondFactorStatus) String() string { return proto.CompactTextString(m) }
+func (*SecondFactorStatus) ProtoMessage()    {}
+
+func (m *SecondFactorStatus) GetEnrolled() bool {
+	if m != nil {
+		return m.Enrolled
+	}
+	return false
+}
+
+func (m *SecondFactorStatus) GetRequired() bool {
+	if m != nil {
+		return m.Required
+	}
+	return false
+}
+
+func (m *SecondFactorStatus) GetRecoveryCodesLeft() int32 {
+	if m != nil {
+		return m.RecoveryCodesLeft
+	}
+	return 0
+}
+
+// Start a second factor enrollment for the current user
+type EnrollSecondFactorRequest struct {
+}
+
+func (m *EnrollSecondFactorRequest) Reset()         { *m = EnrollSecondFactorRequest{} }
+func (m *EnrollSecondFactorRequest) String() string { return proto.CompactTextString(m) }
+func (*EnrollSecondFactorRequest) ProtoMessage()    {}
+
+// Secret to add to an authenticator application, and the corresponding otpauth URI
+type SecondFactorEnrollment struct {
+	Secret string `protobuf:"bytes,1,opt,name=Secret" json:"Secret,omitempty"`
+	KeyURI string `protobuf:"bytes,2,opt,name=KeyURI" json:"KeyURI,omitempty"`
+}
+
+func (m *SecondFactorEnrollment) Reset()         { *m = SecondFactorEnrollment{} }
+func (m *SecondFactorEnrollment) String() string { return proto.CompactTextString(m) }
+func (*SecondFactorEnrollment) ProtoMessage()    {}
+
+func (m *SecondFactorEnrollment) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+func (m *SecondFactorEnrollment) GetKeyURI() string {
+	if m != nil {
+		return m.KeyURI
+	}
+	return ""
+}
+
+// Confirm an enrollment with a first code
+type ConfirmSecondFactorRequest struct {
+	Code string `protobuf:"bytes,1,opt,name=Code" json:"Code,omitempty"`
+}
+
+func (m *ConfirmSecondFactorRequest) Reset()         { *m = ConfirmSecondFactorRequest{} }
+func (m *ConfirmSecondFactorRequest) String() string { return proto.CompactTextString(m) }
+func (*ConfirmSecondFactorRequest) ProtoMessage()    {}
+
+func (m *ConfirmSecondFactorRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+// One-time recovery codes, only sent back once
+type SecondFactorRecoveryCodes struct {
+	RecoveryCodes []string `protobuf:"bytes,1,rep,name=RecoveryCodes" json:"RecoveryCodes,omitempty"`
+}
+
+func (m *SecondFactorRecoveryCodes) Reset()         { *m = SecondFactorRecoveryCodes{} }
+func (m *SecondFactorRecoveryCodes) String() string { return proto.CompactTextString(m) }
+func (*SecondFactorRecoveryCodes) ProtoMessage()    {}
+
+func (m *SecondFactorRecoveryCodes) GetRecoveryCodes() []string {
+	if m != nil {
+		return m.RecoveryCodes
+	}
+	return nil
+}
+
+// Remove the second factor of the current user with a valid code, or of any user for admins
+type DisableSecondFactorRequest struct {
+	Code  string `protobuf:"bytes,1,opt,name=Code" json:"Code,omitempty"`
+	Login string `protobuf:"bytes,2,opt,name=Login" json:"Login,omitempty"`
+}
+
+func (m *DisableSecondFactorRequest) Reset()         { *m = DisableSecondFactorRequest{} }
+func (m *DisableSecondFactorRequest) String() string { return proto.CompactTextString(m) }
+func (*DisableSecondFactorRequest) ProtoMessage()    {}
+
+func (m *DisableSecondFactorRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *DisableSecondFactorRequest) GetLogin() string {
+	if m != nil {
+		return m.Login
+	}
+	return ""
+}
+
 type ResetPasswordTokenRequest struct {
 	UserLogin string `protobuf:"bytes,1,opt,name=UserLogin" json:"UserLogin,omitempty"`
 }
@@ -634,6 +858,18 @@ func init() {
 	proto.RegisterType((*UserBookmarksRequest)(nil), "rest.UserBookmarksRequest")
 	proto.RegisterType((*RevokeRequest)(nil), "rest.RevokeRequest")
 	proto.RegisterType((*RevokeResponse)(nil), "rest.RevokeResponse")
+	proto.RegisterType((*CreateAppPasswordRequest)(nil), "rest.CreateAppPasswordRequest")
+	proto.RegisterType((*ListAppPasswordsRequest)(nil), "rest.ListAppPasswordsRequest")
+	proto.RegisterType((*AppPassword)(nil), "rest.AppPassword")
+	proto.RegisterType((*AppPasswordCollection)(nil), "rest.AppPasswordCollection")
+	proto.RegisterType((*RevokeAppPasswordRequest)(nil), "rest.RevokeAppPasswordRequest")
+	proto.RegisterType((*SecondFactorStatusRequest)(nil), "rest.SecondFactorStatusRequest")
+	proto.RegisterType((*SecondFactorStatus)(nil), "rest.SecondFactorStatus")
+	proto.RegisterType((*EnrollSecondFactorRequest)(nil), "rest.EnrollSecondFactorRequest")
+	proto.RegisterType((*SecondFactorEnrollment)(nil), "rest.SecondFactorEnrollment")
+	proto.RegisterType((*ConfirmSecondFactorRequest)(nil), "rest.ConfirmSecondFactorRequest")
+	proto.RegisterType((*SecondFactorRecoveryCodes)(nil), "rest.SecondFactorRecoveryCodes")
+	proto.RegisterType((*DisableSecondFactorRequest)(nil), "rest.DisableSecondFactorRequest")
 	proto.RegisterType((*ResetPasswordTokenRequest)(nil), "rest.ResetPasswordTokenRequest")
 	proto.RegisterType((*ResetPasswordTokenResponse)(nil), "rest.ResetPasswordTokenResponse")
 	proto.RegisterType((*ResetPasswordRequest)(nil), "rest.ResetPasswordRequest")
@@ -43,6 +43,30 @@ func (x ShareLinkAccessType) String() string {
 }
 func (ShareLinkAccessType) EnumDescriptor() ([]byte, []int) { return fileDescriptor9, []int{0} }
 
+// Subfolder created for each visitor of an upload-only link
+type ShareLinkDropFolder int32
+
+const (
+	ShareLinkDropFolder_NoFolder  ShareLinkDropFolder = 0
+	ShareLinkDropFolder_Uploader  ShareLinkDropFolder = 1
+	ShareLinkDropFolder_Timestamp ShareLinkDropFolder = 2
+)
+
+var ShareLinkDropFolder_name = map[int32]string{
+	0: "NoFolder",
+	1: "Uploader",
+	2: "Timestamp",
+}
+var ShareLinkDropFolder_value = map[string]int32{
+	"NoFolder":  0,
+	"Uploader":  1,
+	"Timestamp": 2,
+}
+
+func (x ShareLinkDropFolder) String() string {
+	return proto.EnumName(ShareLinkDropFolder_name, int32(x))
+}
+
 type ListSharedResourcesRequest_ListShareType int32
 
 const (
@@ -135,6 +159,7 @@ type Cell struct {
 	ACLs                    map[string]*CellAcl       `protobuf:"bytes,5,rep,name=ACLs" json:"ACLs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Policies                []*service.ResourcePolicy `protobuf:"bytes,6,rep,name=Policies" json:"Policies,omitempty"`
 	PoliciesContextEditable bool                      `protobuf:"varint,7,opt,name=PoliciesContextEditable" json:"PoliciesContextEditable,omitempty"`
+	RemoteUsers             []*CellRemoteUser         `protobuf:"bytes,8,rep,name=RemoteUsers" json:"RemoteUsers,omitempty"`
 }
 
 func (m *Cell) Reset()                    { *m = Cell{} }
@@ -191,6 +216,45 @@ func (m *Cell) GetPoliciesContextEditable() bool {
 	return false
 }
 
+func (m *Cell) GetRemoteUsers() []*CellRemoteUser {
+	if m != nil {
+		return m.RemoteUsers
+	}
+	return nil
+}
+
+// User of a remote server invited to a Cell, identified by a federated address user@server
+type CellRemoteUser struct {
+	Address    string           `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Actions    []*idm.ACLAction `protobuf:"bytes,2,rep,name=Actions" json:"Actions,omitempty"`
+	ProviderId string           `protobuf:"bytes,3,opt,name=ProviderId" json:"ProviderId,omitempty"`
+}
+
+func (m *CellRemoteUser) Reset()         { *m = CellRemoteUser{} }
+func (m *CellRemoteUser) String() string { return proto.CompactTextString(m) }
+func (*CellRemoteUser) ProtoMessage()    {}
+
+func (m *CellRemoteUser) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *CellRemoteUser) GetActions() []*idm.ACLAction {
+	if m != nil {
+		return m.Actions
+	}
+	return nil
+}
+
+func (m *CellRemoteUser) GetProviderId() string {
+	if m != nil {
+		return m.ProviderId
+	}
+	return ""
+}
+
 type ShareLinkTargetUser struct {
 	Display       string `protobuf:"bytes,1,opt,name=Display" json:"Display,omitempty"`
 	DownloadCount int32  `protobuf:"varint,2,opt,name=DownloadCount" json:"DownloadCount,omitempty"`
@@ -236,6 +300,7 @@ type ShareLink struct {
 	Permissions             []ShareLinkAccessType           `protobuf:"varint,17,rep,packed,name=Permissions,enum=rest.ShareLinkAccessType" json:"Permissions,omitempty"`
 	Policies                []*service.ResourcePolicy       `protobuf:"bytes,18,rep,name=Policies" json:"Policies,omitempty"`
 	PoliciesContextEditable bool                            `protobuf:"varint,19,opt,name=PoliciesContextEditable" json:"PoliciesContextEditable,omitempty"`
+	DropFolder              ShareLinkDropFolder             `protobuf:"varint,20,opt,name=DropFolder,enum=rest.ShareLinkDropFolder" json:"DropFolder,omitempty"`
 }
 
 func (m *ShareLink) Reset()                    { *m = ShareLink{} }
@@ -376,6 +441,13 @@ func (m *ShareLink) GetPoliciesContextEditable() bool {
 	return false
 }
 
+func (m *ShareLink) GetDropFolder() ShareLinkDropFolder {
+	if m != nil {
+		return m.DropFolder
+	}
+	return ShareLinkDropFolder_NoFolder
+}
+
 type PutCellRequest struct {
 	Room            *Cell `protobuf:"bytes,1,opt,name=Room" json:"Room,omitempty"`
 	CreateEmptyRoot bool  `protobuf:"varint,2,opt,name=CreateEmptyRoot" json:"CreateEmptyRoot,omitempty"`
@@ -681,6 +753,7 @@ func (m *ListSharedResourcesResponse_SharedResource) GetCells() []*Cell {
 func init() {
 	proto.RegisterType((*CellAcl)(nil), "rest.CellAcl")
 	proto.RegisterType((*Cell)(nil), "rest.Cell")
+	proto.RegisterType((*CellRemoteUser)(nil), "rest.CellRemoteUser")
 	proto.RegisterType((*ShareLinkTargetUser)(nil), "rest.ShareLinkTargetUser")
 	proto.RegisterType((*ShareLink)(nil), "rest.ShareLink")
 	proto.RegisterType((*PutCellRequest)(nil), "rest.PutCellRequest")
@@ -695,6 +768,7 @@ func init() {
 	proto.RegisterType((*ListSharedResourcesResponse)(nil), "rest.ListSharedResourcesResponse")
 	proto.RegisterType((*ListSharedResourcesResponse_SharedResource)(nil), "rest.ListSharedResourcesResponse.SharedResource")
 	proto.RegisterEnum("rest.ShareLinkAccessType", ShareLinkAccessType_name, ShareLinkAccessType_value)
+	proto.RegisterEnum("rest.ShareLinkDropFolder", ShareLinkDropFolder_name, ShareLinkDropFolder_value)
 	proto.RegisterEnum("rest.ListSharedResourcesRequest_ListShareType", ListSharedResourcesRequest_ListShareType_name, ListSharedResourcesRequest_ListShareType_value)
 }
 
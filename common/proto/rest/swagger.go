@@ -233,6 +233,73 @@ var SwaggerJson = `{
         ]
       }
     },
+    "/auth/token/app-passwords": {
+      "get": {
+        "summary": "List application passwords of the current user",
+        "operationId": "ListAppPasswords",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restAppPasswordCollection"
+            }
+          }
+        },
+        "tags": [
+          "TokenService"
+        ]
+      },
+      "post": {
+        "summary": "Generate an application password for the current user",
+        "operationId": "CreateAppPassword",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restAppPassword"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restCreateAppPasswordRequest"
+            }
+          }
+        ],
+        "tags": [
+          "TokenService"
+        ]
+      }
+    },
+    "/auth/token/app-passwords/{Id}": {
+      "delete": {
+        "summary": "Revoke an application password of the current user",
+        "operationId": "RevokeAppPassword",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restRevokeResponse"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "Id",
+            "in": "path",
+            "required": true,
+            "type": "string"
+          }
+        ],
+        "tags": [
+          "TokenService"
+        ]
+      }
+    },
     "/auth/token/revoke": {
       "post": {
         "summary": "Revoke a JWT token",
@@ -260,6 +327,102 @@ var SwaggerJson = `{
         ]
       }
     },
+    "/auth/token/second-factor": {
+      "get": {
+        "summary": "Get the second factor status of the current user",
+        "operationId": "GetSecondFactor",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restSecondFactorStatus"
+            }
+          }
+        },
+        "tags": [
+          "TokenService"
+        ]
+      },
+      "post": {
+        "summary": "Start a TOTP enrollment for the current user",
+        "operationId": "EnrollSecondFactor",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restSecondFactorEnrollment"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restEnrollSecondFactorRequest"
+            }
+          }
+        ],
+        "tags": [
+          "TokenService"
+        ]
+      }
+    },
+    "/auth/token/second-factor/confirm": {
+      "post": {
+        "summary": "Confirm the TOTP enrollment with a first code and receive recovery codes",
+        "operationId": "ConfirmSecondFactor",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restSecondFactorRecoveryCodes"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restConfirmSecondFactorRequest"
+            }
+          }
+        ],
+        "tags": [
+          "TokenService"
+        ]
+      }
+    },
+    "/auth/token/second-factor/disable": {
+      "post": {
+        "summary": "Remove the second factor of the current user, or of another user for admins",
+        "operationId": "DisableSecondFactor",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restRevokeResponse"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restDisableSecondFactorRequest"
+            }
+          }
+        ],
+        "tags": [
+          "TokenService"
+        ]
+      }
+    },
     "/changes/{SeqID}": {
       "post": {
         "summary": "Get Changes",
@@ -1914,6 +2077,33 @@ var SwaggerJson = `{
         ]
       }
     },
+    "/policy/explain": {
+      "post": {
+        "summary": "Explain how the security policies evaluate a request, optionally with a modified policy group",
+        "operationId": "ExplainPolicy",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/idmPolicyExplainResponse"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/idmPolicyExplainRequest"
+            }
+          }
+        ],
+        "tags": [
+          "PolicyService"
+        ]
+      }
+    },
     "/policy/{Uuid}": {
       "delete": {
         "summary": "Delete a security policy",
@@ -1939,6 +2129,56 @@ var SwaggerJson = `{
         ]
       }
     },
+    "/quota/usage": {
+      "get": {
+        "summary": "Get the storage usage and quota of the current user",
+        "operationId": "GetUserUsage",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restUserUsage"
+            }
+          }
+        },
+        "tags": [
+          "QuotaService"
+        ]
+      }
+    },
+    "/quota/usage/top": {
+      "get": {
+        "summary": "List the users having the largest storage usage",
+        "operationId": "ListUserUsages",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restUserUsageCollection"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "Limit",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int32"
+          },
+          {
+            "name": "Offset",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int32"
+          }
+        ],
+        "tags": [
+          "QuotaService"
+        ]
+      }
+    },
     "/role": {
       "post": {
         "summary": "Search Roles",
@@ -2773,71 +3013,87 @@ var SwaggerJson = `{
         ]
       }
     },
-    "/workspace": {
+    "/versions/list/{NodePath}": {
       "post": {
-        "summary": "Search workspaces on certain keys",
-        "operationId": "SearchWorkspaces",
+        "summary": "List the versions of a file",
+        "operationId": "ListVersions",
         "responses": {
           "200": {
             "description": "",
             "schema": {
-              "$ref": "#/definitions/restWorkspaceCollection"
+              "$ref": "#/definitions/restNodeVersionsCollection"
             }
           }
         },
         "parameters": [
+          {
+            "name": "NodePath",
+            "in": "path",
+            "required": true,
+            "type": "string"
+          },
           {
             "name": "body",
             "in": "body",
             "required": true,
             "schema": {
-              "$ref": "#/definitions/restSearchWorkspaceRequest"
+              "$ref": "#/definitions/restListNodeVersionsRequest"
             }
           }
         ],
         "tags": [
-          "WorkspaceService"
+          "VersionService"
         ]
       }
     },
-    "/workspace/{Slug}": {
-      "delete": {
-        "summary": "Delete an existing workspace",
-        "operationId": "DeleteWorkspace",
+    "/versions/restore/{NodePath}": {
+      "post": {
+        "summary": "Restore a version: its content is written to the file, which creates a new version on top",
+        "operationId": "RestoreVersion",
         "responses": {
           "200": {
             "description": "",
             "schema": {
-              "$ref": "#/definitions/restDeleteResponse"
+              "$ref": "#/definitions/restRestoreVersionResponse"
             }
           }
         },
         "parameters": [
           {
-            "name": "Slug",
+            "name": "NodePath",
             "in": "path",
             "required": true,
             "type": "string"
+          },
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restRestoreVersionRequest"
+            }
           }
         ],
         "tags": [
-          "WorkspaceService"
+          "VersionService"
         ]
-      },
-      "put": {
-        "summary": "Create or update a workspace",
-        "operationId": "PutWorkspace",
+      }
+    },
+    "/versions/update/{NodePath}": {
+      "post": {
+        "summary": "Update the label and description of a version, and whether it is pinned to never be pruned",
+        "operationId": "UpdateVersion",
         "responses": {
           "200": {
             "description": "",
             "schema": {
-              "$ref": "#/definitions/idmWorkspace"
+              "$ref": "#/definitions/treeChangeLog"
             }
           }
         },
         "parameters": [
           {
-            "name": "Slug",
+            "name": "NodePath",
             "in": "path",
             "required": true,
             "type": "string"
@@ -2847,37 +3103,120 @@ var SwaggerJson = `{
             "in": "body",
             "required": true,
             "schema": {
-              "$ref": "#/definitions/idmWorkspace"
+              "$ref": "#/definitions/restUpdateVersionRequest"
             }
           }
         ],
         "tags": [
-          "WorkspaceService"
+          "VersionService"
         ]
       }
-    }
-  },
-  "definitions": {
-    "ListLogRequestLogFormat": {
-      "type": "string",
-      "enum": [
-        "JSON",
-        "CSV",
-        "XLSX"
-      ],
-      "default": "JSON",
-      "title": "Output Format"
-    },
-    "ListSharedResourcesRequestListShareType": {
-      "type": "string",
-      "enum": [
-        "ANY",
-        "LINKS",
-        "CELLS"
-      ],
-      "default": "ANY"
     },
-    "ListSharedResourcesResponseSharedResource": {
+    "/workspace": {
+      "post": {
+        "summary": "Search workspaces on certain keys",
+        "operationId": "SearchWorkspaces",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restWorkspaceCollection"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/restSearchWorkspaceRequest"
+            }
+          }
+        ],
+        "tags": [
+          "WorkspaceService"
+        ]
+      }
+    },
+    "/workspace/{Slug}": {
+      "delete": {
+        "summary": "Delete an existing workspace",
+        "operationId": "DeleteWorkspace",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/restDeleteResponse"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "Slug",
+            "in": "path",
+            "required": true,
+            "type": "string"
+          }
+        ],
+        "tags": [
+          "WorkspaceService"
+        ]
+      },
+      "put": {
+        "summary": "Create or update a workspace",
+        "operationId": "PutWorkspace",
+        "responses": {
+          "200": {
+            "description": "",
+            "schema": {
+              "$ref": "#/definitions/idmWorkspace"
+            }
+          }
+        },
+        "parameters": [
+          {
+            "name": "Slug",
+            "in": "path",
+            "required": true,
+            "type": "string"
+          },
+          {
+            "name": "body",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/idmWorkspace"
+            }
+          }
+        ],
+        "tags": [
+          "WorkspaceService"
+        ]
+      }
+    }
+  },
+  "definitions": {
+    "ListLogRequestLogFormat": {
+      "type": "string",
+      "enum": [
+        "JSON",
+        "CSV",
+        "XLSX"
+      ],
+      "default": "JSON",
+      "title": "Output Format"
+    },
+    "ListSharedResourcesRequestListShareType": {
+      "type": "string",
+      "enum": [
+        "ANY",
+        "LINKS",
+        "CELLS"
+      ],
+      "default": "ANY"
+    },
+    "ListSharedResourcesResponseSharedResource": {
       "type": "object",
       "properties": {
         "Node": {
@@ -3856,6 +4195,15 @@ var SwaggerJson = `{
         },
         "NodeID": {
           "type": "string"
+        },
+        "NotBefore": {
+          "type": "integer",
+          "format": "int32",
+          "title": "Validity window of the ACL as unix timestamps, 0 meaning no limit"
+        },
+        "ExpiresAt": {
+          "type": "integer",
+          "format": "int32"
         }
       }
     },
@@ -3900,6 +4248,16 @@ var SwaggerJson = `{
         "not": {
           "type": "boolean",
           "format": "boolean"
+        },
+        "IncludeInactive": {
+          "type": "boolean",
+          "format": "boolean",
+          "title": "Also find ACLs that are not yet or no longer valid"
+        },
+        "ExpiredBefore": {
+          "type": "integer",
+          "format": "int32",
+          "title": "Find ACLs that expired before this unix timestamp"
         }
       }
     },
@@ -3979,6 +4337,24 @@ var SwaggerJson = `{
         }
       }
     },
+    "idmPolicyConditionEvaluation": {
+      "type": "object",
+      "properties": {
+        "Key": {
+          "type": "string"
+        },
+        "Type": {
+          "type": "string"
+        },
+        "Value": {
+          "type": "string"
+        },
+        "Fulfilled": {
+          "type": "boolean",
+          "format": "boolean"
+        }
+      }
+    },
     "idmPolicyEffect": {
       "type": "string",
       "enum": [
@@ -3988,6 +4364,108 @@ var SwaggerJson = `{
       ],
       "default": "unknown"
     },
+    "idmPolicyEngineRequest": {
+      "type": "object",
+      "properties": {
+        "Resource": {
+          "type": "string"
+        },
+        "Action": {
+          "type": "string"
+        },
+        "Subjects": {
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "Context": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        }
+      }
+    },
+    "idmPolicyEngineResponse": {
+      "type": "object",
+      "properties": {
+        "Allowed": {
+          "type": "boolean",
+          "format": "boolean"
+        },
+        "ExplicitDeny": {
+          "type": "boolean",
+          "format": "boolean"
+        },
+        "DefaultDeny": {
+          "type": "boolean",
+          "format": "boolean"
+        }
+      }
+    },
+    "idmPolicyExplainRequest": {
+      "type": "object",
+      "properties": {
+        "Request": {
+          "$ref": "#/definitions/idmPolicyEngineRequest",
+          "title": "Request to evaluate"
+        },
+        "DryRunGroup": {
+          "$ref": "#/definitions/idmPolicyGroup",
+          "title": "Optional group evaluated in place of the stored group with the same Uuid, without saving it"
+        }
+      }
+    },
+    "idmPolicyExplainResponse": {
+      "type": "object",
+      "properties": {
+        "Decision": {
+          "$ref": "#/definitions/idmPolicyEngineResponse"
+        },
+        "Explanations": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/idmPolicyExplanation"
+          }
+        },
+        "Context": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          },
+          "title": "Context after enrichment with the user attributes"
+        }
+      }
+    },
+    "idmPolicyExplanation": {
+      "type": "object",
+      "properties": {
+        "GroupUuid": {
+          "type": "string"
+        },
+        "GroupName": {
+          "type": "string"
+        },
+        "Subject": {
+          "type": "string"
+        },
+        "Policy": {
+          "$ref": "#/definitions/idmPolicy"
+        },
+        "Conditions": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/idmPolicyConditionEvaluation"
+          }
+        },
+        "Applied": {
+          "type": "boolean",
+          "format": "boolean",
+          "title": "True if all conditions are fulfilled and the policy takes part in the decision"
+        }
+      }
+    },
     "idmPolicyGroup": {
       "type": "object",
       "properties": {
@@ -5419,6 +5897,37 @@ var SwaggerJson = `{
       },
       "title": "Response for search request"
     },
+    "restAppPassword": {
+      "type": "object",
+      "properties": {
+        "Id": {
+          "type": "string"
+        },
+        "Label": {
+          "type": "string"
+        },
+        "CreationDate": {
+          "type": "integer",
+          "format": "int32"
+        },
+        "Password": {
+          "type": "string"
+        }
+      },
+      "title": "Application password, the Password is only sent back at creation time"
+    },
+    "restAppPasswordCollection": {
+      "type": "object",
+      "properties": {
+        "AppPasswords": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/restAppPassword"
+          }
+        }
+      },
+      "title": "Collection of application passwords"
+    },
     "restBindResponse": {
       "type": "object",
       "properties": {
@@ -5473,6 +5982,12 @@ var SwaggerJson = `{
         "PoliciesContextEditable": {
           "type": "boolean",
           "format": "boolean"
+        },
+        "RemoteUsers": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/restCellRemoteUser"
+          }
         }
       },
       "title": "Model for representing a shared room"
@@ -5505,6 +6020,24 @@ var SwaggerJson = `{
       },
       "title": "Group collected acls by subjects"
     },
+    "restCellRemoteUser": {
+      "type": "object",
+      "properties": {
+        "Address": {
+          "type": "string"
+        },
+        "Actions": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/idmACLAction"
+          }
+        },
+        "ProviderId": {
+          "type": "string"
+        }
+      },
+      "title": "User of a remote server invited to a Cell, identified by a federated address user@server"
+    },
     "restChangeCollection": {
       "type": "object",
       "properties": {
@@ -5552,6 +6085,15 @@ var SwaggerJson = `{
       },
       "title": "Configuration message. Data is an Json representation of any value"
     },
+    "restConfirmSecondFactorRequest": {
+      "type": "object",
+      "properties": {
+        "Code": {
+          "type": "string"
+        }
+      },
+      "title": "Confirm an enrollment with a first code"
+    },
     "restControlServiceRequest": {
       "type": "object",
       "properties": {
@@ -5566,6 +6108,15 @@ var SwaggerJson = `{
         }
       }
     },
+    "restCreateAppPasswordRequest": {
+      "type": "object",
+      "properties": {
+        "Label": {
+          "type": "string"
+        }
+      },
+      "title": "Create a new application password for the current user"
+    },
     "restDataSourceCollection": {
       "type": "object",
       "properties": {
@@ -5632,6 +6183,18 @@ var SwaggerJson = `{
         }
       }
     },
+    "restDisableSecondFactorRequest": {
+      "type": "object",
+      "properties": {
+        "Code": {
+          "type": "string"
+        },
+        "Login": {
+          "type": "string"
+        }
+      },
+      "title": "Remove the second factor of the current user with a valid code, or of any user for admins"
+    },
     "restDiscoveryResponse": {
       "type": "object",
       "properties": {
@@ -5674,6 +6237,10 @@ var SwaggerJson = `{
         }
       }
     },
+    "restEnrollSecondFactorRequest": {
+      "type": "object",
+      "title": "Start a second factor enrollment for the current user"
+    },
     "restExternalDirectoryCollection": {
       "type": "object",
       "properties": {
@@ -5799,6 +6366,14 @@ var SwaggerJson = `{
         }
       }
     },
+    "restListNodeVersionsRequest": {
+      "type": "object",
+      "properties": {
+        "NodePath": {
+          "type": "string"
+        }
+      }
+    },
     "restListPeerFoldersRequest": {
       "type": "object",
       "properties": {
@@ -5935,6 +6510,17 @@ var SwaggerJson = `{
         }
       }
     },
+    "restNodeVersionsCollection": {
+      "type": "object",
+      "properties": {
+        "Versions": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeChangeLog"
+          }
+        }
+      }
+    },
     "restNodesCollection": {
       "type": "object",
       "properties": {
@@ -6052,6 +6638,25 @@ var SwaggerJson = `{
       },
       "title": "Generic Query for limiting results based on resource permissions"
     },
+    "restRestoreVersionRequest": {
+      "type": "object",
+      "properties": {
+        "NodePath": {
+          "type": "string"
+        },
+        "VersionId": {
+          "type": "string"
+        }
+      }
+    },
+    "restRestoreVersionResponse": {
+      "type": "object",
+      "properties": {
+        "Node": {
+          "$ref": "#/definitions/treeNode"
+        }
+      }
+    },
     "restRevokeRequest": {
       "type": "object",
       "properties": {
@@ -6129,6 +6734,12 @@ var SwaggerJson = `{
         "Total": {
           "type": "integer",
           "format": "int32"
+        },
+        "Facets": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchFacet"
+          }
         }
       }
     },
@@ -6234,6 +6845,48 @@ var SwaggerJson = `{
       },
       "title": "Rest request for searching workspaces"
     },
+    "restSecondFactorEnrollment": {
+      "type": "object",
+      "properties": {
+        "Secret": {
+          "type": "string"
+        },
+        "KeyURI": {
+          "type": "string"
+        }
+      },
+      "title": "Secret to add to an authenticator application, and the corresponding otpauth URI"
+    },
+    "restSecondFactorRecoveryCodes": {
+      "type": "object",
+      "properties": {
+        "RecoveryCodes": {
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        }
+      },
+      "title": "One-time recovery codes, only sent back once"
+    },
+    "restSecondFactorStatus": {
+      "type": "object",
+      "properties": {
+        "Enrolled": {
+          "type": "boolean",
+          "format": "boolean"
+        },
+        "Required": {
+          "type": "boolean",
+          "format": "boolean"
+        },
+        "RecoveryCodesLeft": {
+          "type": "integer",
+          "format": "int32"
+        }
+      },
+      "title": "Second factor status of a user"
+    },
     "restServiceCollection": {
       "type": "object",
       "properties": {
@@ -6398,6 +7051,9 @@ var SwaggerJson = `{
         "PoliciesContextEditable": {
           "type": "boolean",
           "format": "boolean"
+        },
+        "DropFolder": {
+          "$ref": "#/definitions/restShareLinkDropFolder"
         }
       },
       "title": "Model for representing a public link"
@@ -6413,6 +7069,16 @@ var SwaggerJson = `{
       "default": "NoAccess",
       "title": "Known values for link permissions"
     },
+    "restShareLinkDropFolder": {
+      "type": "string",
+      "enum": [
+        "NoFolder",
+        "Uploader",
+        "Timestamp"
+      ],
+      "default": "NoFolder",
+      "title": "Subfolder created for each visitor of an upload-only link"
+    },
     "restShareLinkTargetUser": {
       "type": "object",
       "properties": {
@@ -6454,6 +7120,27 @@ var SwaggerJson = `{
       },
       "title": "Collection of serialized aggregated result of time range request \nwith a cursor to ease navigation implementation"
     },
+    "restUpdateVersionRequest": {
+      "type": "object",
+      "properties": {
+        "NodePath": {
+          "type": "string"
+        },
+        "VersionId": {
+          "type": "string"
+        },
+        "Label": {
+          "type": "string"
+        },
+        "Pinned": {
+          "type": "boolean",
+          "format": "boolean"
+        },
+        "Description": {
+          "type": "string"
+        }
+      }
+    },
     "restUserBookmarksRequest": {
       "type": "object"
     },
@@ -6528,6 +7215,38 @@ var SwaggerJson = `{
         }
       }
     },
+    "restUserUsage": {
+      "type": "object",
+      "properties": {
+        "Login": {
+          "type": "string"
+        },
+        "Bytes": {
+          "type": "string",
+          "format": "int64"
+        },
+        "Files": {
+          "type": "string",
+          "format": "int64"
+        },
+        "Quota": {
+          "type": "string",
+          "format": "int64"
+        }
+      },
+      "title": "Storage used by a user, with the quota applying to them"
+    },
+    "restUserUsageCollection": {
+      "type": "object",
+      "properties": {
+        "Usages": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/restUserUsage"
+          }
+        }
+      }
+    },
     "restUsersCollection": {
       "type": "object",
       "properties": {
@@ -6709,6 +7428,28 @@ var SwaggerJson = `{
         "Event": {
           "$ref": "#/definitions/treeNodeChangeEvent",
           "title": "Event that triggered this change"
+        },
+        "StoredSize": {
+          "type": "string",
+          "format": "int64",
+          "title": "Bytes actually written to the versions store, after deduplication"
+        },
+        "Label": {
+          "type": "string",
+          "title": "User-defined label"
+        },
+        "Pinned": {
+          "type": "boolean",
+          "format": "boolean",
+          "title": "Pinned versions are never removed by pruning"
+        },
+        "Chunks": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "string",
+            "format": "int64"
+          },
+          "title": "Sizes of the chunks composing the version, by hash, for deduplicated versions"
         }
       }
     },
@@ -6910,6 +7651,13 @@ var SwaggerJson = `{
         "GeoQuery": {
           "$ref": "#/definitions/treeGeoQuery",
           "title": "Search geographically"
+        },
+        "ExcludedPathPrefix": {
+          "type": "array",
+          "items": {
+            "type": "string"
+          },
+          "title": "Exclude given subtrees, e.g. the folders that the user cannot read"
         }
       },
       "title": "Search Queries"
@@ -6939,6 +7687,97 @@ var SwaggerJson = `{
         }
       }
     },
+    "treeSearchFacet": {
+      "type": "object",
+      "properties": {
+        "Name": {
+          "type": "string"
+        },
+        "Field": {
+          "type": "string"
+        },
+        "Total": {
+          "type": "integer",
+          "format": "int32",
+          "title": "Number of documents having a value for this field"
+        },
+        "Values": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchFacetValue"
+          }
+        }
+      }
+    },
+    "treeSearchFacetRange": {
+      "type": "object",
+      "properties": {
+        "Label": {
+          "type": "string"
+        },
+        "Min": {
+          "type": "number",
+          "format": "double"
+        },
+        "Max": {
+          "type": "number",
+          "format": "double"
+        },
+        "Start": {
+          "type": "string",
+          "format": "int64"
+        },
+        "End": {
+          "type": "string",
+          "format": "int64"
+        }
+      },
+      "description": "Range for a facet, zero values are unbounded. Min/Max are used for\nnumeric ranges and Start/End (unix timestamps) for date ranges."
+    },
+    "treeSearchFacetRequest": {
+      "type": "object",
+      "properties": {
+        "Name": {
+          "type": "string",
+          "title": "Name of the facet in the results"
+        },
+        "Field": {
+          "type": "string",
+          "title": "Indexed field, e.g. Extension, Size, ModifTime or Meta.name"
+        },
+        "Size": {
+          "type": "integer",
+          "format": "int32",
+          "title": "Maximum number of terms"
+        },
+        "NumericRanges": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchFacetRange"
+          }
+        },
+        "DateRanges": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchFacetRange"
+          }
+        }
+      },
+      "description": "Request a facet on a given field: terms are counted by default,\nor values are grouped by numeric or date ranges if provided."
+    },
+    "treeSearchFacetValue": {
+      "type": "object",
+      "properties": {
+        "Term": {
+          "type": "string",
+          "title": "Term or range label"
+        },
+        "Count": {
+          "type": "integer",
+          "format": "int32"
+        }
+      }
+    },
     "treeSearchRequest": {
       "type": "object",
       "properties": {
@@ -6964,6 +7803,38 @@ var SwaggerJson = `{
         "Facet": {
           "type": "string",
           "title": "Facet search"
+        },
+        "Facets": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchFacetRequest"
+          },
+          "title": "Facets to compute on the whole result set"
+        },
+        "SortFields": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/treeSearchSortField"
+          },
+          "title": "Sort results, by score if empty"
+        },
+        "Highlight": {
+          "type": "boolean",
+          "format": "boolean",
+          "title": "Return highlighted fragments of the text content"
+        }
+      }
+    },
+    "treeSearchSortField": {
+      "type": "object",
+      "properties": {
+        "Field": {
+          "type": "string",
+          "title": "Indexed field, e.g. Basename, Size, ModifTime, or \"score\""
+        },
+        "Descending": {
+          "type": "boolean",
+          "format": "boolean"
         }
       }
     },
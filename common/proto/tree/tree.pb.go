@@ -30,6 +30,11 @@ It has these top-level messages:
 	WatchNodeResponse
 	SearchRequest
 	SearchResponse
+	SearchFacetRequest
+	SearchFacetRange
+	SearchFacet
+	SearchFacetValue
+	SearchSortField
 	CreateVersionRequest
 	CreateVersionResponse
 	ListVersionsRequest
@@ -40,6 +45,10 @@ It has these top-level messages:
 	StoreVersionResponse
 	PruneVersionsRequest
 	PruneVersionsResponse
+	UpdateVersionRequest
+	UpdateVersionResponse
+	RestoreVersionRequest
+	RestoreVersionResponse
 	VersioningPolicy
 	VersioningKeepPeriod
 	Node
@@ -681,6 +690,12 @@ type SearchRequest struct {
 	Details bool `protobuf:"varint,4,opt,name=Details" json:"Details,omitempty"`
 	// Facet search
 	Facet string `protobuf:"bytes,5,opt,name=Facet" json:"Facet,omitempty"`
+	// Facets to compute on the whole result set
+	Facets []*SearchFacetRequest `protobuf:"bytes,6,rep,name=Facets" json:"Facets,omitempty"`
+	// Sort results, by score if empty
+	SortFields []*SearchSortField `protobuf:"bytes,7,rep,name=SortFields" json:"SortFields,omitempty"`
+	// Return highlighted fragments of the text content
+	Highlight bool `protobuf:"varint,8,opt,name=Highlight" json:"Highlight,omitempty"`
 }
 
 func (m *SearchRequest) Reset()                    { *m = SearchRequest{} }
@@ -723,8 +738,33 @@ func (m *SearchRequest) GetFacet() string {
 	return ""
 }
 
+func (m *SearchRequest) GetFacets() []*SearchFacetRequest {
+	if m != nil {
+		return m.Facets
+	}
+	return nil
+}
+
+func (m *SearchRequest) GetSortFields() []*SearchSortField {
+	if m != nil {
+		return m.SortFields
+	}
+	return nil
+}
+
+func (m *SearchRequest) GetHighlight() bool {
+	if m != nil {
+		return m.Highlight
+	}
+	return false
+}
+
 type SearchResponse struct {
 	Node *Node `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
+	// Highlighted fragments of the text content for this node
+	Highlights []string `protobuf:"bytes,2,rep,name=Highlights" json:"Highlights,omitempty"`
+	// Facets results, sent in a last response without Node
+	Facets []*SearchFacet `protobuf:"bytes,3,rep,name=Facets" json:"Facets,omitempty"`
 }
 
 func (m *SearchResponse) Reset()                    { *m = SearchResponse{} }
@@ -739,6 +779,209 @@ func (m *SearchResponse) GetNode() *Node {
 	return nil
 }
 
+func (m *SearchResponse) GetHighlights() []string {
+	if m != nil {
+		return m.Highlights
+	}
+	return nil
+}
+
+func (m *SearchResponse) GetFacets() []*SearchFacet {
+	if m != nil {
+		return m.Facets
+	}
+	return nil
+}
+
+// Request a facet on a given field: terms are counted by default,
+// or values are grouped by numeric or date ranges if provided.
+type SearchFacetRequest struct {
+	// Name of the facet in the results
+	Name string `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
+	// Indexed field, e.g. Extension, Size, ModifTime or Meta.name
+	Field string `protobuf:"bytes,2,opt,name=Field" json:"Field,omitempty"`
+	// Maximum number of terms
+	Size          int32               `protobuf:"varint,3,opt,name=Size" json:"Size,omitempty"`
+	NumericRanges []*SearchFacetRange `protobuf:"bytes,4,rep,name=NumericRanges" json:"NumericRanges,omitempty"`
+	DateRanges    []*SearchFacetRange `protobuf:"bytes,5,rep,name=DateRanges" json:"DateRanges,omitempty"`
+}
+
+func (m *SearchFacetRequest) Reset()         { *m = SearchFacetRequest{} }
+func (m *SearchFacetRequest) String() string { return proto.CompactTextString(m) }
+func (*SearchFacetRequest) ProtoMessage()    {}
+
+func (m *SearchFacetRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SearchFacetRequest) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *SearchFacetRequest) GetSize() int32 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *SearchFacetRequest) GetNumericRanges() []*SearchFacetRange {
+	if m != nil {
+		return m.NumericRanges
+	}
+	return nil
+}
+
+func (m *SearchFacetRequest) GetDateRanges() []*SearchFacetRange {
+	if m != nil {
+		return m.DateRanges
+	}
+	return nil
+}
+
+// Range for a facet, zero values are unbounded. Min/Max are used for
+// numeric ranges and Start/End (unix timestamps) for date ranges.
+type SearchFacetRange struct {
+	Label string  `protobuf:"bytes,1,opt,name=Label" json:"Label,omitempty"`
+	Min   float64 `protobuf:"fixed64,2,opt,name=Min" json:"Min,omitempty"`
+	Max   float64 `protobuf:"fixed64,3,opt,name=Max" json:"Max,omitempty"`
+	Start int64   `protobuf:"varint,4,opt,name=Start" json:"Start,omitempty"`
+	End   int64   `protobuf:"varint,5,opt,name=End" json:"End,omitempty"`
+}
+
+func (m *SearchFacetRange) Reset()         { *m = SearchFacetRange{} }
+func (m *SearchFacetRange) String() string { return proto.CompactTextString(m) }
+func (*SearchFacetRange) ProtoMessage()    {}
+
+func (m *SearchFacetRange) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *SearchFacetRange) GetMin() float64 {
+	if m != nil {
+		return m.Min
+	}
+	return 0
+}
+
+func (m *SearchFacetRange) GetMax() float64 {
+	if m != nil {
+		return m.Max
+	}
+	return 0
+}
+
+func (m *SearchFacetRange) GetStart() int64 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *SearchFacetRange) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+type SearchFacet struct {
+	Name  string `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
+	Field string `protobuf:"bytes,2,opt,name=Field" json:"Field,omitempty"`
+	// Number of documents having a value for this field
+	Total  int32               `protobuf:"varint,3,opt,name=Total" json:"Total,omitempty"`
+	Values []*SearchFacetValue `protobuf:"bytes,4,rep,name=Values" json:"Values,omitempty"`
+}
+
+func (m *SearchFacet) Reset()         { *m = SearchFacet{} }
+func (m *SearchFacet) String() string { return proto.CompactTextString(m) }
+func (*SearchFacet) ProtoMessage()    {}
+
+func (m *SearchFacet) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SearchFacet) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *SearchFacet) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *SearchFacet) GetValues() []*SearchFacetValue {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type SearchFacetValue struct {
+	// Term or range label
+	Term  string `protobuf:"bytes,1,opt,name=Term" json:"Term,omitempty"`
+	Count int32  `protobuf:"varint,2,opt,name=Count" json:"Count,omitempty"`
+}
+
+func (m *SearchFacetValue) Reset()         { *m = SearchFacetValue{} }
+func (m *SearchFacetValue) String() string { return proto.CompactTextString(m) }
+func (*SearchFacetValue) ProtoMessage()    {}
+
+func (m *SearchFacetValue) GetTerm() string {
+	if m != nil {
+		return m.Term
+	}
+	return ""
+}
+
+func (m *SearchFacetValue) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type SearchSortField struct {
+	// Indexed field, e.g. Basename, Size, ModifTime, or "score"
+	Field      string `protobuf:"bytes,1,opt,name=Field" json:"Field,omitempty"`
+	Descending bool   `protobuf:"varint,2,opt,name=Descending" json:"Descending,omitempty"`
+}
+
+func (m *SearchSortField) Reset()         { *m = SearchSortField{} }
+func (m *SearchSortField) String() string { return proto.CompactTextString(m) }
+func (*SearchSortField) ProtoMessage()    {}
+
+func (m *SearchSortField) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *SearchSortField) GetDescending() bool {
+	if m != nil {
+		return m.Descending
+	}
+	return false
+}
+
 type CreateVersionRequest struct {
 	Node         *Node            `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
 	TriggerEvent *NodeChangeEvent `protobuf:"bytes,2,opt,name=TriggerEvent" json:"TriggerEvent,omitempty"`
@@ -939,6 +1182,114 @@ func (m *PruneVersionsResponse) GetDeletedVersions() []string {
 	return nil
 }
 
+type UpdateVersionRequest struct {
+	Node        *Node  `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
+	VersionId   string `protobuf:"bytes,2,opt,name=VersionId" json:"VersionId,omitempty"`
+	Label       string `protobuf:"bytes,3,opt,name=Label" json:"Label,omitempty"`
+	Pinned      bool   `protobuf:"varint,4,opt,name=Pinned" json:"Pinned,omitempty"`
+	Description string `protobuf:"bytes,5,opt,name=Description" json:"Description,omitempty"`
+}
+
+func (m *UpdateVersionRequest) Reset()         { *m = UpdateVersionRequest{} }
+func (m *UpdateVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateVersionRequest) ProtoMessage()    {}
+
+func (m *UpdateVersionRequest) GetNode() *Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *UpdateVersionRequest) GetVersionId() string {
+	if m != nil {
+		return m.VersionId
+	}
+	return ""
+}
+
+func (m *UpdateVersionRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *UpdateVersionRequest) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *UpdateVersionRequest) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+type UpdateVersionResponse struct {
+	Version *ChangeLog `protobuf:"bytes,1,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *UpdateVersionResponse) Reset()         { *m = UpdateVersionResponse{} }
+func (m *UpdateVersionResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateVersionResponse) ProtoMessage()    {}
+
+func (m *UpdateVersionResponse) GetVersion() *ChangeLog {
+	if m != nil {
+		return m.Version
+	}
+	return nil
+}
+
+type RestoreVersionRequest struct {
+	Node      *Node  `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
+	VersionId string `protobuf:"bytes,2,opt,name=VersionId" json:"VersionId,omitempty"`
+}
+
+func (m *RestoreVersionRequest) Reset()         { *m = RestoreVersionRequest{} }
+func (m *RestoreVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionRequest) ProtoMessage()    {}
+
+func (m *RestoreVersionRequest) GetNode() *Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *RestoreVersionRequest) GetVersionId() string {
+	if m != nil {
+		return m.VersionId
+	}
+	return ""
+}
+
+type RestoreVersionResponse struct {
+	Node    *Node      `protobuf:"bytes,1,opt,name=Node" json:"Node,omitempty"`
+	Version *ChangeLog `protobuf:"bytes,2,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *RestoreVersionResponse) Reset()         { *m = RestoreVersionResponse{} }
+func (m *RestoreVersionResponse) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionResponse) ProtoMessage()    {}
+
+func (m *RestoreVersionResponse) GetNode() *Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *RestoreVersionResponse) GetVersion() *ChangeLog {
+	if m != nil {
+		return m.Version
+	}
+	return nil
+}
+
 type VersioningPolicy struct {
 	Uuid                     string                  `protobuf:"bytes,1,opt,name=Uuid" json:"Uuid,omitempty"`
 	Name                     string                  `protobuf:"bytes,2,opt,name=Name" json:"Name,omitempty"`
@@ -1195,6 +1546,14 @@ type ChangeLog struct {
 	OwnerUuid string `protobuf:"bytes,6,opt,name=OwnerUuid" json:"OwnerUuid,omitempty"`
 	// Event that triggered this change
 	Event *NodeChangeEvent `protobuf:"bytes,7,opt,name=Event" json:"Event,omitempty"`
+	// Bytes actually written to the versions store, after deduplication
+	StoredSize int64 `protobuf:"varint,8,opt,name=StoredSize" json:"StoredSize,omitempty"`
+	// User-defined label
+	Label string `protobuf:"bytes,9,opt,name=Label" json:"Label,omitempty"`
+	// Pinned versions are never removed by pruning
+	Pinned bool `protobuf:"varint,10,opt,name=Pinned" json:"Pinned,omitempty"`
+	// Sizes of the chunks composing the version, by hash, for deduplicated versions
+	Chunks map[string]int64 `protobuf:"bytes,11,rep,name=Chunks" json:"Chunks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
 }
 
 func (m *ChangeLog) Reset()                    { *m = ChangeLog{} }
@@ -1251,6 +1610,34 @@ func (m *ChangeLog) GetEvent() *NodeChangeEvent {
 	return nil
 }
 
+func (m *ChangeLog) GetStoredSize() int64 {
+	if m != nil {
+		return m.StoredSize
+	}
+	return 0
+}
+
+func (m *ChangeLog) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *ChangeLog) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *ChangeLog) GetChunks() map[string]int64 {
+	if m != nil {
+		return m.Chunks
+	}
+	return nil
+}
+
 // Search Queries
 type Query struct {
 	// Limit to a given subtree
@@ -1273,6 +1660,8 @@ type Query struct {
 	Extension string `protobuf:"bytes,10,opt,name=Extension" json:"Extension,omitempty"`
 	// Search geographically
 	GeoQuery *GeoQuery `protobuf:"bytes,11,opt,name=GeoQuery" json:"GeoQuery,omitempty"`
+	// Exclude given subtrees, e.g. the folders that the user cannot read
+	ExcludedPathPrefix []string `protobuf:"bytes,12,rep,name=ExcludedPathPrefix" json:"ExcludedPathPrefix,omitempty"`
 }
 
 func (m *Query) Reset()                    { *m = Query{} }
@@ -1357,6 +1746,13 @@ func (m *Query) GetGeoQuery() *GeoQuery {
 	return nil
 }
 
+func (m *Query) GetExcludedPathPrefix() []string {
+	if m != nil {
+		return m.ExcludedPathPrefix
+	}
+	return nil
+}
+
 type GeoQuery struct {
 	// Either use a center point and a distance
 	Center *GeoPoint `protobuf:"bytes,1,opt,name=Center" json:"Center,omitempty"`
@@ -1708,6 +2104,11 @@ func init() {
 	proto.RegisterType((*WatchNodeResponse)(nil), "tree.WatchNodeResponse")
 	proto.RegisterType((*SearchRequest)(nil), "tree.SearchRequest")
 	proto.RegisterType((*SearchResponse)(nil), "tree.SearchResponse")
+	proto.RegisterType((*SearchFacetRequest)(nil), "tree.SearchFacetRequest")
+	proto.RegisterType((*SearchFacetRange)(nil), "tree.SearchFacetRange")
+	proto.RegisterType((*SearchFacet)(nil), "tree.SearchFacet")
+	proto.RegisterType((*SearchFacetValue)(nil), "tree.SearchFacetValue")
+	proto.RegisterType((*SearchSortField)(nil), "tree.SearchSortField")
 	proto.RegisterType((*CreateVersionRequest)(nil), "tree.CreateVersionRequest")
 	proto.RegisterType((*CreateVersionResponse)(nil), "tree.CreateVersionResponse")
 	proto.RegisterType((*ListVersionsRequest)(nil), "tree.ListVersionsRequest")
@@ -1718,6 +2119,10 @@ func init() {
 	proto.RegisterType((*StoreVersionResponse)(nil), "tree.StoreVersionResponse")
 	proto.RegisterType((*PruneVersionsRequest)(nil), "tree.PruneVersionsRequest")
 	proto.RegisterType((*PruneVersionsResponse)(nil), "tree.PruneVersionsResponse")
+	proto.RegisterType((*UpdateVersionRequest)(nil), "tree.UpdateVersionRequest")
+	proto.RegisterType((*UpdateVersionResponse)(nil), "tree.UpdateVersionResponse")
+	proto.RegisterType((*RestoreVersionRequest)(nil), "tree.RestoreVersionRequest")
+	proto.RegisterType((*RestoreVersionResponse)(nil), "tree.RestoreVersionResponse")
 	proto.RegisterType((*VersioningPolicy)(nil), "tree.VersioningPolicy")
 	proto.RegisterType((*VersioningKeepPeriod)(nil), "tree.VersioningKeepPeriod")
 	proto.RegisterType((*Node)(nil), "tree.Node")
@@ -2782,6 +3187,8 @@ type NodeVersionerClient interface {
 	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...client.CallOption) (NodeVersioner_ListVersionsClient, error)
 	HeadVersion(ctx context.Context, in *HeadVersionRequest, opts ...client.CallOption) (*HeadVersionResponse, error)
 	PruneVersions(ctx context.Context, in *PruneVersionsRequest, opts ...client.CallOption) (*PruneVersionsResponse, error)
+	UpdateVersion(ctx context.Context, in *UpdateVersionRequest, opts ...client.CallOption) (*UpdateVersionResponse, error)
+	RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...client.CallOption) (*RestoreVersionResponse, error)
 }
 
 type nodeVersionerClient struct {
@@ -2886,6 +3293,26 @@ func (c *nodeVersionerClient) PruneVersions(ctx context.Context, in *PruneVersio
 	return out, nil
 }
 
+func (c *nodeVersionerClient) UpdateVersion(ctx context.Context, in *UpdateVersionRequest, opts ...client.CallOption) (*UpdateVersionResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "NodeVersioner.UpdateVersion", in)
+	out := new(UpdateVersionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeVersionerClient) RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...client.CallOption) (*RestoreVersionResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "NodeVersioner.RestoreVersion", in)
+	out := new(RestoreVersionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for NodeVersioner service
 
 type NodeVersionerHandler interface {
@@ -2894,6 +3321,8 @@ type NodeVersionerHandler interface {
 	ListVersions(context.Context, *ListVersionsRequest, NodeVersioner_ListVersionsStream) error
 	HeadVersion(context.Context, *HeadVersionRequest, *HeadVersionResponse) error
 	PruneVersions(context.Context, *PruneVersionsRequest, *PruneVersionsResponse) error
+	UpdateVersion(context.Context, *UpdateVersionRequest, *UpdateVersionResponse) error
+	RestoreVersion(context.Context, *RestoreVersionRequest, *RestoreVersionResponse) error
 }
 
 func RegisterNodeVersionerHandler(s server.Server, hdlr NodeVersionerHandler, opts ...server.HandlerOption) {
@@ -2955,6 +3384,14 @@ func (h *NodeVersioner) PruneVersions(ctx context.Context, in *PruneVersionsRequ
 	return h.NodeVersionerHandler.PruneVersions(ctx, in, out)
 }
 
+func (h *NodeVersioner) UpdateVersion(ctx context.Context, in *UpdateVersionRequest, out *UpdateVersionResponse) error {
+	return h.NodeVersionerHandler.UpdateVersion(ctx, in, out)
+}
+
+func (h *NodeVersioner) RestoreVersion(ctx context.Context, in *RestoreVersionRequest, out *RestoreVersionResponse) error {
+	return h.NodeVersionerHandler.RestoreVersion(ctx, in, out)
+}
+
 // Client API for FileKeyManager service
 
 type FileKeyManagerClient interface {
@@ -22,11 +22,15 @@ package servicecontext
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/micro/go-micro/metadata"
+	"github.com/pborman/uuid"
+
+	"github.com/pydio/cells/common/config"
 )
 
 const (
@@ -38,8 +42,13 @@ const (
 	HttpMetaUserAgent      = "UserAgent"
 	HttpMetaContentType    = "ContentType"
 	HttpMetaCoookiesString = "CookiesString"
+	HttpMetaVisitorId      = "VisitorId"
 	ClientTime             = "ClientTime"
 	ServerTime             = "ServerTime"
+
+	// VisitorCookieName is a browser session cookie holding a random identifier, used to tell
+	// apart the visitors sharing the same account, like the hidden user of a public link.
+	VisitorCookieName = "pydio_visitor"
 )
 
 // Try to extract as much HTTP metadata as possible and store it in context metadata
@@ -56,8 +65,8 @@ func HttpRequestInfoToMetadata(ctx context.Context, req *http.Request) context.C
 	}
 	meta[HttpMetaExtracted] = HttpMetaExtracted
 
-	if req.RemoteAddr != "" {
-		meta[HttpMetaRemoteAddress] = req.RemoteAddr
+	if addr := ClientAddress(req, TrustedProxies()); addr != "" {
+		meta[HttpMetaRemoteAddress] = addr
 	}
 
 	// TODO add client time and locale via JS on the client side and retrieve it here
@@ -66,14 +75,6 @@ func HttpRequestInfoToMetadata(ctx context.Context, req *http.Request) context.C
 	meta[ServerTime] = t.Format(layout)
 	meta[ClientTime] = t.Format(layout)
 
-	if h, ok := req.Header["X-Forwarded-For"]; ok {
-		forwarded := strings.Join(h, "")
-		meta[HttpMetaRemoteAddress] = forwarded
-	}
-	// Override RemoteAddr if set by the php frontend
-	if h, ok := req.Header["X-Pydio-Front-Client"]; ok {
-		meta[HttpMetaRemoteAddress] = strings.Join(h, "")
-	}
 	if h, ok := req.Header["User-Agent"]; ok {
 		meta[HttpMetaUserAgent] = strings.Join(h, "")
 	}
@@ -104,14 +105,118 @@ func HttpRequestInfoToMetadata(ctx context.Context, req *http.Request) context.C
 		}
 		meta[HttpMetaCoookiesString] = strings.Join(cString, "//")
 	}
+	if id := visitorId(req); id != "" {
+		meta[HttpMetaVisitorId] = id
+	}
 
 	return metadata.NewContext(ctx, meta)
 }
 
-// Extract data from request and put it in context Metadata field
+// Extract data from request and put it in context Metadata field. A visitor cookie is
+// set if the request does not have one yet.
 func HttpMetaExtractorWrapper(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if visitorId(r) == "" {
+			cookie := &http.Cookie{
+				Name:     VisitorCookieName,
+				Value:    uuid.New(),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+				SameSite: http.SameSiteLaxMode,
+			}
+			http.SetCookie(w, cookie)
+			r.AddCookie(cookie)
+		}
 		r = r.WithContext(HttpRequestInfoToMetadata(r.Context(), r))
 		h.ServeHTTP(w, r)
 	})
 }
+
+// visitorId reads the visitor cookie of the request. Values that were not generated
+// by HttpMetaExtractorWrapper are ignored.
+func visitorId(req *http.Request) string {
+	for _, c := range req.Cookies() {
+		if c.Name == VisitorCookieName && uuid.Parse(c.Value) != nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// TrustedProxies loads the addresses of the reverse proxies allowed to set the X-Forwarded-For and
+// X-Pydio-Front-Client headers: loopback addresses, plus the IPs or CIDR ranges listed in the
+// "trustedProxies" value of the "defaults" configuration.
+func TrustedProxies() []*net.IPNet {
+	_, v4, _ := net.ParseCIDR("127.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("::1/128")
+	trusted := []*net.IPNet{v4, v6}
+	for _, p := range config.Get("defaults", "trustedProxies").StringSlice([]string{}) {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		if _, r, e := net.ParseCIDR(p); e == nil {
+			trusted = append(trusted, r)
+		}
+	}
+	return trusted
+}
+
+// ClientAddress finds the address of the client who sent the request. Forwarding headers are only
+// used if the request comes from a trusted proxy, in which case the rightmost address of the
+// X-Forwarded-For chain which is not a trusted proxy is the client, as the leftmost entries can be
+// set to anything by the client itself.
+func ClientAddress(req *http.Request, trustedProxies []*net.IPNet) string {
+	peer := req.RemoteAddr
+	if !isTrustedProxy(peer, trustedProxies) {
+		return peer
+	}
+	// Set by the php frontend
+	if h := req.Header.Get("X-Pydio-Front-Client"); h != "" {
+		return h
+	}
+	var hops []string
+	for _, h := range req.Header["X-Forwarded-For"] {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hostOnly(hop)) == nil {
+			// Malformed chain, do not trust anything before this hop
+			return peer
+		}
+		peer = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return peer
+}
+
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(hostOnly(addr))
+	if ip == nil {
+		return false
+	}
+	for _, r := range trustedProxies {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOnly strips the port and brackets from an address.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return strings.Trim(addr, "[]")
+}
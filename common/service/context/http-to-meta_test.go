@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package servicecontext
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/metadata"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientAddress(t *testing.T) {
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	trusted := []*net.IPNet{loopback, proxies}
+
+	request := func(remote string, headers map[string]string) *http.Request {
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		req.RemoteAddr = remote
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	Convey("Direct clients cannot spoof their address", t, func() {
+		req := request("203.0.113.7:5000", map[string]string{"X-Forwarded-For": "192.168.0.1", "X-Pydio-Front-Client": "192.168.0.2"})
+		So(ClientAddress(req, trusted), ShouldEqual, "203.0.113.7:5000")
+	})
+
+	Convey("Spoofed entries before the proxies chain are ignored", t, func() {
+		req := request("127.0.0.1:5000", map[string]string{"X-Forwarded-For": "192.168.0.1, 203.0.113.7, 10.0.0.2"})
+		So(ClientAddress(req, trusted), ShouldEqual, "203.0.113.7")
+	})
+
+	Convey("Multiple headers are read as one chain", t, func() {
+		req := request("10.0.0.3:5000", nil)
+		req.Header.Add("X-Forwarded-For", "192.168.0.1")
+		req.Header.Add("X-Forwarded-For", "203.0.113.8")
+		So(ClientAddress(req, trusted), ShouldEqual, "203.0.113.8")
+	})
+
+	Convey("Malformed chains are not trusted", t, func() {
+		req := request("127.0.0.1:5000", map[string]string{"X-Forwarded-For": "192.168.0.1, not-an-ip"})
+		So(ClientAddress(req, trusted), ShouldEqual, "127.0.0.1:5000")
+	})
+
+	Convey("Front client header is accepted from a trusted proxy", t, func() {
+		req := request("127.0.0.1:5000", map[string]string{"X-Pydio-Front-Client": "203.0.113.9"})
+		So(ClientAddress(req, trusted), ShouldEqual, "203.0.113.9")
+		So(ClientAddress(request("127.0.0.1:5000", nil), trusted), ShouldEqual, "127.0.0.1:5000")
+	})
+}
+
+func TestVisitorCookie(t *testing.T) {
+
+	Convey("A visitor cookie is set once and sent in metadata", t, func() {
+		var visitor string
+		h := HttpMetaExtractorWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			meta, _ := metadata.FromContext(r.Context())
+			visitor = meta[HttpMetaVisitorId]
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		cookies := w.Result().Cookies()
+		So(cookies, ShouldHaveLength, 1)
+		So(cookies[0].Name, ShouldEqual, VisitorCookieName)
+		So(cookies[0].HttpOnly, ShouldBeTrue)
+		So(visitor, ShouldEqual, cookies[0].Value)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		So(w.Result().Cookies(), ShouldBeEmpty)
+		So(visitor, ShouldEqual, cookies[0].Value)
+
+		req = httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: VisitorCookieName, Value: "forged"})
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		So(w.Result().Cookies(), ShouldHaveLength, 1)
+		So(visitor, ShouldEqual, w.Result().Cookies()[0].Value)
+	})
+}
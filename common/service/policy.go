@@ -69,6 +69,7 @@ func PolicyHttpWrapper(h http.Handler) http.Handler {
 		}
 
 		utils.PolicyContextFromMetadata(policyRequestContext, c)
+		utils.PolicyContextFromClaims(policyRequestContext, c)
 		if len(policyRequestContext) > 0 {
 			request.Context = policyRequestContext
 		}
@@ -214,6 +214,8 @@ func NewService(opts ...ServiceOption) Service {
 				d = s.Options().DAO(sql.NewDAO(driver, dsn, prefix))
 			case "sqlite3":
 				d = s.Options().DAO(sql.NewDAO(driver, dsn, prefix))
+			case "postgres":
+				d = s.Options().DAO(sql.NewDAO(driver, dsn, prefix))
 			case "boltdb":
 				d = s.Options().DAO(boltdb.NewDAO(driver, dsn, prefix))
 			default:
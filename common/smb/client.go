@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"net"
+	"os"
+	"sync"
+)
+
+// Client keeps a share mounted, and connects again to the server when the connection is lost
+// (server restart, network failure, expired session).
+type Client struct {
+	Dialer    *Dialer
+	Addr      string
+	ShareName string
+
+	mu      sync.Mutex
+	session *Session
+	share   *Share
+}
+
+// NewClient creates a Client for the given server and share. User can be passed in the
+// "DOMAIN\user" form. The connection is only established on first use, or by calling Connect.
+func NewClient(addr, shareName, login, password string) *Client {
+	user, domain := ParseUser(login)
+	return &Client{
+		Dialer:    &Dialer{User: user, Password: password, Domain: domain},
+		Addr:      addr,
+		ShareName: shareName,
+	}
+}
+
+// Connect establishes the session and mounts the share if they are not already available.
+func (c *Client) Connect() error {
+	_, e := c.Share()
+	return e
+}
+
+// Share returns the currently mounted share, connecting to the server if required.
+func (c *Client) Share() (*Share, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.share != nil {
+		return c.share, nil
+	}
+	session, e := c.Dialer.Dial(c.Addr)
+	if e != nil {
+		return nil, e
+	}
+	share, e := session.Mount(c.ShareName)
+	if e != nil {
+		session.Logoff()
+		return nil, e
+	}
+	c.session = session
+	c.share = share
+	return share, nil
+}
+
+// Do runs fn on the mounted share. If fn fails because the connection was lost, the share is
+// mounted again and fn is retried once.
+func (c *Client) Do(fn func(share *Share) error) error {
+	share, e := c.Share()
+	if e != nil {
+		return e
+	}
+	e = fn(share)
+	if e == nil || !share.Lost(e) {
+		return e
+	}
+	c.release(share)
+	if share, e2 := c.Share(); e2 == nil {
+		return fn(share)
+	}
+	return e
+}
+
+// Close unmounts the share and closes the session.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.share == nil {
+		return nil
+	}
+	c.share.Umount()
+	e := c.session.Logoff()
+	c.share = nil
+	c.session = nil
+	return e
+}
+
+// release drops a lost share, unless it was already replaced by another call.
+func (c *Client) release(share *Share) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.share != share {
+		return
+	}
+	c.session.c.nc.Close()
+	c.share = nil
+	c.session = nil
+}
+
+// Lost tells whether err means that the share cannot be used anymore and must be mounted again.
+func (s *Share) Lost(err error) bool {
+	s.c.Lock()
+	broken := s.c.broken
+	s.c.Unlock()
+	if broken != nil {
+		return true
+	}
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+	switch e := err.(type) {
+	case Status:
+		return e == StatusUserSessionDeleted || e == StatusNetworkNameDeleted || e == StatusNetworkSessionExpired
+	case net.Error:
+		return true
+	}
+	return false
+}
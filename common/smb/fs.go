@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Fs wraps a Share as an afero.Fs
+type Fs struct {
+	Share *Share
+	// Client is used instead of Share when set, so that lost connections are established again
+	Client *Client
+}
+
+// NewFs creates an afero.Fs backed by the given share.
+func NewFs(share *Share) afero.Fs {
+	return &Fs{Share: share}
+}
+
+// NewClientFs creates an afero.Fs backed by the share of the given client, that
+// reconnects to the server when required.
+func NewClientFs(client *Client) afero.Fs {
+	return &Fs{Client: client}
+}
+
+// do runs fn on the share, through the client if there is one.
+func (f *Fs) do(fn func(share *Share) error) error {
+	if f.Client != nil {
+		return f.Client.Do(fn)
+	}
+	return fn(f.Share)
+}
+
+// Name implements afero.Fs
+func (f *Fs) Name() string {
+	return "SmbFs"
+}
+
+// Create implements afero.Fs
+func (f *Fs) Create(name string) (afero.File, error) {
+	var file *File
+	if err := f.do(func(share *Share) (e error) {
+		file, e = share.Create(name)
+		return
+	}); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Mkdir implements afero.Fs
+func (f *Fs) Mkdir(name string, perm os.FileMode) error {
+	return f.do(func(share *Share) error {
+		return share.Mkdir(name, perm)
+	})
+}
+
+// MkdirAll implements afero.Fs
+func (f *Fs) MkdirAll(path string, perm os.FileMode) error {
+	return f.do(func(share *Share) error {
+		return share.MkdirAll(path, perm)
+	})
+}
+
+// Open implements afero.Fs
+func (f *Fs) Open(name string) (afero.File, error) {
+	var file *File
+	if err := f.do(func(share *Share) (e error) {
+		file, e = share.Open(name)
+		return
+	}); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenFile implements afero.Fs
+func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	var file *File
+	if err := f.do(func(share *Share) (e error) {
+		file, e = share.OpenFile(name, flag, perm)
+		return
+	}); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Remove implements afero.Fs
+func (f *Fs) Remove(name string) error {
+	return f.do(func(share *Share) error {
+		return share.Remove(name)
+	})
+}
+
+// RemoveAll implements afero.Fs
+func (f *Fs) RemoveAll(path string) error {
+	return f.do(func(share *Share) error {
+		return share.RemoveAll(path)
+	})
+}
+
+// Rename implements afero.Fs
+func (f *Fs) Rename(oldname, newname string) error {
+	return f.do(func(share *Share) error {
+		return share.Rename(oldname, newname)
+	})
+}
+
+// Stat implements afero.Fs
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := f.do(func(share *Share) (e error) {
+		info, e = share.Stat(name)
+		return
+	})
+	return info, err
+}
+
+// Chmod is not supported by SMB shares and is silently ignored.
+func (f *Fs) Chmod(name string, mode os.FileMode) error {
+	_, err := f.Stat(name)
+	return err
+}
+
+// Chtimes implements afero.Fs
+func (f *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return f.do(func(share *Share) error {
+		return share.Chtimes(name, atime, mtime)
+	})
+}
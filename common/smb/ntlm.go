@@ -0,0 +1,363 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// NTLMSSP negotiate flags
+const (
+	ntlmNegotiateUnicode          uint32 = 0x00000001
+	ntlmRequestTarget             uint32 = 0x00000004
+	ntlmNegotiateSign             uint32 = 0x00000010
+	ntlmNegotiateNTLM             uint32 = 0x00000200
+	ntlmNegotiateAlwaysSign       uint32 = 0x00008000
+	ntlmTargetTypeDomain          uint32 = 0x00010000
+	ntlmNegotiateExtendedSecurity uint32 = 0x00080000
+	ntlmNegotiateTargetInfo       uint32 = 0x00800000
+	ntlmNegotiateVersion          uint32 = 0x02000000
+	ntlmNegotiate128              uint32 = 0x20000000
+	ntlmNegotiate56               uint32 = 0x80000000
+)
+
+// AV_PAIR identifiers
+const (
+	avEOL            uint16 = 0
+	avNbComputerName uint16 = 1
+	avNbDomainName   uint16 = 2
+	avFlags          uint16 = 6
+	avTimestamp      uint16 = 7
+)
+
+const (
+	ntlmNegotiateType    uint32 = 1
+	ntlmChallengeType    uint32 = 2
+	ntlmAuthenticateType uint32 = 3
+)
+
+var (
+	ntlmSignature = []byte("NTLMSSP\x00")
+	// Windows 10 / NTLM revision 15, purely informative
+	ntlmVersion = []byte{10, 0, 0x63, 0x45, 0, 0, 0, 15}
+
+	errNTLMAuth = errors.New("smb: NTLM authentication failed")
+)
+
+const ntlmDefaultFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateSign | ntlmNegotiateNTLM |
+	ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSecurity | ntlmNegotiateTargetInfo | ntlmNegotiateVersion |
+	ntlmNegotiate128 | ntlmNegotiate56
+
+// ntlmClient performs the client side of an NTLMv2 authentication.
+type ntlmClient struct {
+	user        string
+	password    string
+	domain      string
+	workstation string
+
+	negotiate  []byte
+	sessionKey []byte
+}
+
+func (c *ntlmClient) negotiateMessage() []byte {
+	e := &encoder{}
+	e.bytes(ntlmSignature)
+	e.u32(ntlmNegotiateType)
+	e.u32(ntlmDefaultFlags)
+	e.zero(16) // Domain and Workstation fields are left empty
+	e.bytes(ntlmVersion)
+	c.negotiate = e.b
+	return c.negotiate
+}
+
+func (c *ntlmClient) authenticateMessage(challenge []byte) ([]byte, error) {
+	flags, serverChallenge, targetInfo, err := parseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	pairs := parseAvPairs(targetInfo)
+	timestamp, hasTimestamp := pairs[avTimestamp]
+	if !hasTimestamp {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, toFiletime(time.Now()))
+	}
+	clientChallenge := make([]byte, 8)
+	if _, e := rand.Read(clientChallenge); e != nil {
+		return nil, e
+	}
+
+	// Send back the server AV pairs, flagging the presence of a MIC when required
+	info := &encoder{}
+	for _, p := range splitAvPairs(targetInfo) {
+		if p.id == avEOL || p.id == avFlags {
+			continue
+		}
+		info.u16(p.id)
+		info.u16(uint16(len(p.value)))
+		info.bytes(p.value)
+	}
+	if hasTimestamp {
+		info.u16(avFlags)
+		info.u16(4)
+		info.u32(0x2)
+	}
+	info.u16(avEOL)
+	info.u16(0)
+
+	ntowf := ntowfv2(c.user, c.password, c.domain)
+	temp := &encoder{}
+	temp.bytes([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.bytes(timestamp)
+	temp.bytes(clientChallenge)
+	temp.zero(4)
+	temp.bytes(info.b)
+	temp.zero(4)
+	ntProof := hmacMD5(ntowf, serverChallenge, temp.b)
+	ntResponse := append(ntProof, temp.b...)
+	var lmResponse []byte
+	if hasTimestamp {
+		lmResponse = make([]byte, 24)
+	} else {
+		lmResponse = append(hmacMD5(ntowf, serverChallenge, clientChallenge), clientChallenge...)
+	}
+	c.sessionKey = hmacMD5(ntowf, ntProof)
+
+	domain := encodeUTF16(c.domain)
+	user := encodeUTF16(c.user)
+	workstation := encodeUTF16(c.workstation)
+
+	const fixed = 88 // fixed part, including Version and MIC
+	msg := make([]byte, fixed)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], ntlmAuthenticateType)
+	offset := fixed
+	for i, field := range [][]byte{lmResponse, ntResponse, domain, user, workstation, nil} {
+		putNTLMField(msg[12+i*8:], field, offset)
+		msg = append(msg, field...)
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&ntlmDefaultFlags)
+	copy(msg[64:], ntlmVersion)
+	if hasTimestamp {
+		mic := hmacMD5(c.sessionKey, c.negotiate, challenge, msg)
+		copy(msg[72:88], mic)
+	}
+	return msg, nil
+}
+
+// ntlmServer performs the server side of an NTLMv2 authentication for a single account.
+type ntlmServer struct {
+	user     string
+	password string
+	domain   string
+
+	serverChallenge []byte
+	sessionKey      []byte
+	authenticated   string
+}
+
+func (s *ntlmServer) challengeMessage(negotiate []byte) ([]byte, error) {
+	if len(negotiate) < 16 || !bytes.Equal(negotiate[:8], ntlmSignature) || binary.LittleEndian.Uint32(negotiate[8:]) != ntlmNegotiateType {
+		return nil, errors.New("smb: invalid NTLM negotiate message")
+	}
+	clientFlags := binary.LittleEndian.Uint32(negotiate[12:])
+	s.serverChallenge = make([]byte, 8)
+	if _, e := rand.Read(s.serverChallenge); e != nil {
+		return nil, e
+	}
+	target := encodeUTF16(strings.ToUpper(s.domain))
+	info := &encoder{}
+	for _, p := range []struct {
+		id    uint16
+		value []byte
+	}{
+		{avNbDomainName, target},
+		{avNbComputerName, encodeUTF16("CELLS")},
+	} {
+		info.u16(p.id)
+		info.u16(uint16(len(p.value)))
+		info.bytes(p.value)
+	}
+	info.u16(avTimestamp)
+	info.u16(8)
+	info.u64(toFiletime(time.Now()))
+	info.u16(avEOL)
+	info.u16(0)
+
+	const fixed = 56
+	msg := make([]byte, fixed)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], ntlmChallengeType)
+	putNTLMField(msg[12:], target, fixed)
+	binary.LittleEndian.PutUint32(msg[20:], (clientFlags&ntlmDefaultFlags)|ntlmTargetTypeDomain|ntlmNegotiateTargetInfo)
+	copy(msg[24:32], s.serverChallenge)
+	putNTLMField(msg[40:], info.b, fixed+len(target))
+	copy(msg[48:], ntlmVersion)
+	msg = append(msg, target...)
+	msg = append(msg, info.b...)
+	return msg, nil
+}
+
+func (s *ntlmServer) authenticate(msg []byte) error {
+	if len(msg) < 64 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != ntlmAuthenticateType {
+		return errors.New("smb: invalid NTLM authenticate message")
+	}
+	ntResponse, e1 := readNTLMField(msg, 20)
+	domain, e2 := readNTLMField(msg, 28)
+	user, e3 := readNTLMField(msg, 36)
+	if e1 != nil || e2 != nil || e3 != nil || len(ntResponse) < 16+28 {
+		return errNTLMAuth
+	}
+	userName := decodeUTF16(user)
+	if !strings.EqualFold(userName, s.user) {
+		return errNTLMAuth
+	}
+	ntowf := ntowfv2(userName, s.password, decodeUTF16(domain))
+	ntProof := hmacMD5(ntowf, s.serverChallenge, ntResponse[16:])
+	if !hmac.Equal(ntProof, ntResponse[:16]) {
+		return errNTLMAuth
+	}
+	s.sessionKey = hmacMD5(ntowf, ntProof)
+	s.authenticated = userName
+	return nil
+}
+
+func parseChallenge(msg []byte) (flags uint32, serverChallenge []byte, targetInfo []byte, err error) {
+	if len(msg) < 48 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != ntlmChallengeType {
+		return 0, nil, nil, errors.New("smb: invalid NTLM challenge message")
+	}
+	flags = binary.LittleEndian.Uint32(msg[20:])
+	serverChallenge = msg[24:32]
+	targetInfo, err = readNTLMField(msg, 40)
+	return
+}
+
+func putNTLMField(b []byte, data []byte, offset int) {
+	binary.LittleEndian.PutUint16(b[0:], uint16(len(data)))
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(b[4:], uint32(offset))
+}
+
+func readNTLMField(msg []byte, at int) ([]byte, error) {
+	if at+8 > len(msg) {
+		return nil, errors.New("smb: truncated NTLM message")
+	}
+	l := int(binary.LittleEndian.Uint16(msg[at:]))
+	off := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	return slice(msg, off, l)
+}
+
+type avPair struct {
+	id    uint16
+	value []byte
+}
+
+func splitAvPairs(info []byte) (pairs []avPair) {
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		l := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+l > len(info) {
+			break
+		}
+		pairs = append(pairs, avPair{id: id, value: info[4 : 4+l]})
+		info = info[4+l:]
+	}
+	return
+}
+
+func parseAvPairs(info []byte) map[uint16][]byte {
+	m := make(map[uint16][]byte)
+	for _, p := range splitAvPairs(info) {
+		m[p.id] = p.value
+	}
+	return m
+}
+
+func ntowfv2(user, password, domain string) []byte {
+	return hmacMD5(md4(encodeUTF16(password)), encodeUTF16(strings.ToUpper(user)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// md4 computes the RFC 1320 digest, which is still required to derive the NT hash.
+func md4(data []byte) []byte {
+	s := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	var l [8]byte
+	binary.LittleEndian.PutUint64(l[:], uint64(len(data))*8)
+	msg = append(msg, l[:]...)
+
+	f := func(x, y, z uint32) uint32 { return (x & y) | (^x & z) }
+	g := func(x, y, z uint32) uint32 { return (x & y) | (x & z) | (y & z) }
+	h := func(x, y, z uint32) uint32 { return x ^ y ^ z }
+	var x [16]uint32
+	for chunk := 0; chunk < len(msg); chunk += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[chunk+i*4:])
+		}
+		a, b, c, d := s[0], s[1], s[2], s[3]
+		for _, i := range []int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+f(b, c, d)+x[i], 3)
+			d = bits.RotateLeft32(d+f(a, b, c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+f(d, a, b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+f(c, d, a)+x[i+3], 19)
+		}
+		for _, i := range []int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+g(b, c, d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+g(a, b, c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+g(d, a, b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+g(c, d, a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range []int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+h(b, c, d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+h(a, b, c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+h(d, a, b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+h(c, d, a)+x[i+12]+0x6ed9eba1, 15)
+		}
+		s[0] += a
+		s[1] += b
+		s[2] += c
+		s[3] += d
+	}
+	out := make([]byte, 16)
+	for i, v := range s {
+		binary.LittleEndian.PutUint32(out[i*4:], v)
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	protocolID = "\xfeSMB"
+	headerSize = 64
+
+	// Maximum frame size accepted on the wire, a bit more than the largest read/write chunk.
+	maxFrameSize = 1 << 24
+)
+
+// SMB2 commands used by this package
+const (
+	cmdNegotiate      uint16 = 0x00
+	cmdSessionSetup   uint16 = 0x01
+	cmdLogoff         uint16 = 0x02
+	cmdTreeConnect    uint16 = 0x03
+	cmdTreeDisconnect uint16 = 0x04
+	cmdCreate         uint16 = 0x05
+	cmdClose          uint16 = 0x06
+	cmdFlush          uint16 = 0x07
+	cmdRead           uint16 = 0x08
+	cmdWrite          uint16 = 0x09
+	cmdQueryDirectory uint16 = 0x0E
+	cmdQueryInfo      uint16 = 0x10
+	cmdSetInfo        uint16 = 0x11
+)
+
+// Header flags
+const (
+	flagResponse uint32 = 0x1
+	flagAsync    uint32 = 0x2
+	flagSigned   uint32 = 0x8
+)
+
+// Dialects
+const (
+	dialect202 uint16 = 0x0202
+	dialect210 uint16 = 0x0210
+)
+
+// Security modes and capabilities
+const (
+	securitySigningEnabled  uint16 = 0x1
+	securitySigningRequired uint16 = 0x2
+)
+
+// Create dispositions
+const (
+	fileSupersede   uint32 = 0
+	fileOpen        uint32 = 1
+	fileCreate      uint32 = 2
+	fileOpenIf      uint32 = 3
+	fileOverwrite   uint32 = 4
+	fileOverwriteIf uint32 = 5
+)
+
+// Create options
+const (
+	fileDirectoryFile    uint32 = 0x1
+	fileNonDirectoryFile uint32 = 0x40
+	fileDeleteOnClose    uint32 = 0x1000
+)
+
+// Access masks
+const (
+	accessReadData        uint32 = 0x1
+	accessWriteData       uint32 = 0x2
+	accessAppendData      uint32 = 0x4
+	accessReadEA          uint32 = 0x8
+	accessWriteEA         uint32 = 0x10
+	accessReadAttributes  uint32 = 0x80
+	accessWriteAttributes uint32 = 0x100
+	accessDelete          uint32 = 0x10000
+	accessReadControl     uint32 = 0x20000
+	accessSynchronize     uint32 = 0x100000
+)
+
+// Share access
+const (
+	shareRead   uint32 = 0x1
+	shareWrite  uint32 = 0x2
+	shareDelete uint32 = 0x4
+	shareAll           = shareRead | shareWrite | shareDelete
+)
+
+// File attributes
+const (
+	attrReadonly  uint32 = 0x1
+	attrDirectory uint32 = 0x10
+	attrNormal    uint32 = 0x80
+)
+
+// Info types and classes used by QUERY_INFO / SET_INFO / QUERY_DIRECTORY
+const (
+	infoFile uint8 = 0x1
+
+	classFileDirectoryInformation   uint8 = 0x01
+	classFileBasicInformation       uint8 = 0x04
+	classFileRenameInformation      uint8 = 0x0A
+	classFileDispositionInformation uint8 = 0x0D
+	classFileEndOfFileInformation   uint8 = 0x14
+	classFileNetworkOpenInformation uint8 = 0x22
+
+	queryDirectoryRestartScans uint8 = 0x1
+)
+
+// header is the 64 bytes SMB2 sync/async packet header.
+type header struct {
+	CreditCharge uint16
+	Status       uint32
+	Command      uint16
+	Credits      uint16
+	Flags        uint32
+	NextCommand  uint32
+	MessageID    uint64
+	AsyncID      uint64
+	TreeID       uint32
+	SessionID    uint64
+	Signature    [16]byte
+}
+
+func (h *header) encode(b []byte) {
+	le := binary.LittleEndian
+	copy(b[0:4], protocolID)
+	le.PutUint16(b[4:], headerSize)
+	le.PutUint16(b[6:], h.CreditCharge)
+	le.PutUint32(b[8:], h.Status)
+	le.PutUint16(b[12:], h.Command)
+	le.PutUint16(b[14:], h.Credits)
+	le.PutUint32(b[16:], h.Flags)
+	le.PutUint32(b[20:], h.NextCommand)
+	le.PutUint64(b[24:], h.MessageID)
+	if h.Flags&flagAsync != 0 {
+		le.PutUint64(b[32:], h.AsyncID)
+	} else {
+		le.PutUint32(b[32:], 0)
+		le.PutUint32(b[36:], h.TreeID)
+	}
+	le.PutUint64(b[40:], h.SessionID)
+	copy(b[48:64], h.Signature[:])
+}
+
+func decodeHeader(b []byte) (*header, error) {
+	if len(b) < headerSize || string(b[0:4]) != protocolID {
+		return nil, errors.New("smb: invalid SMB2 packet")
+	}
+	le := binary.LittleEndian
+	h := &header{
+		CreditCharge: le.Uint16(b[6:]),
+		Status:       le.Uint32(b[8:]),
+		Command:      le.Uint16(b[12:]),
+		Credits:      le.Uint16(b[14:]),
+		Flags:        le.Uint32(b[16:]),
+		NextCommand:  le.Uint32(b[20:]),
+		MessageID:    le.Uint64(b[24:]),
+		SessionID:    le.Uint64(b[40:]),
+	}
+	if h.Flags&flagAsync != 0 {
+		h.AsyncID = le.Uint64(b[32:])
+	} else {
+		h.TreeID = le.Uint32(b[36:])
+	}
+	copy(h.Signature[:], b[48:64])
+	return h, nil
+}
+
+// writeFrame sends a message prefixed by its direct-TCP transport header.
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg))&0xFFFFFF)
+	copy(frame[4:], msg)
+	_, e := w.Write(frame)
+	return e
+}
+
+// readFrame reads a full message from the direct-TCP transport.
+func readFrame(r io.Reader) ([]byte, error) {
+	var l [4]byte
+	if _, e := io.ReadFull(r, l[:]); e != nil {
+		return nil, e
+	}
+	size := binary.BigEndian.Uint32(l[:]) & 0xFFFFFF
+	if l[0] != 0 || size > maxFrameSize {
+		return nil, fmt.Errorf("smb: invalid frame size %d", size)
+	}
+	msg := make([]byte, size)
+	if _, e := io.ReadFull(r, msg); e != nil {
+		return nil, e
+	}
+	return msg, nil
+}
+
+// encoder appends little-endian values to a byte slice.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) u8(v uint8) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) u16(v uint16) {
+	e.b = append(e.b, byte(v), byte(v>>8))
+}
+
+func (e *encoder) u32(v uint32) {
+	e.b = append(e.b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (e *encoder) u64(v uint64) {
+	e.u32(uint32(v))
+	e.u32(uint32(v >> 32))
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) zero(n int) {
+	e.b = append(e.b, make([]byte, n)...)
+}
+
+// align pads the buffer with zeros up to a multiple of n.
+func (e *encoder) align(n int) {
+	if r := len(e.b) % n; r != 0 {
+		e.zero(n - r)
+	}
+}
+
+// decoder reads little-endian values, remembering the first out-of-bounds error.
+type decoder struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || d.off+n > len(d.b) {
+		d.err = errors.New("smb: truncated packet")
+		return make([]byte, n)
+	}
+	s := d.b[d.off : d.off+n]
+	d.off += n
+	return s
+}
+
+func (d *decoder) u8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) u16() uint16 {
+	return binary.LittleEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) u32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) u64() uint64 {
+	return binary.LittleEndian.Uint64(d.next(8))
+}
+
+func (d *decoder) skip(n int) {
+	d.next(n)
+}
+
+// slice returns a copy of msg[off:off+length], checking bounds.
+func slice(msg []byte, off, length int) ([]byte, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	if off < 0 || length < 0 || off+length > len(msg) {
+		return nil, errors.New("smb: buffer out of bounds")
+	}
+	s := make([]byte, length)
+	copy(s, msg[off:off+length])
+	return s, nil
+}
+
+// encodeUTF16 converts a string to UTF-16LE bytes.
+func encodeUTF16(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, len(codes)*2)
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+// decodeUTF16 converts UTF-16LE bytes to a string.
+func decodeUTF16(b []byte) string {
+	codes := make([]uint16, len(b)/2)
+	for i := range codes {
+		codes[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(codes))
+}
+
+// Windows FILETIME is the number of 100ns intervals since January 1, 1601.
+const filetimeEpochDelta = 116444736000000000
+
+func toFiletime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano()/100 + filetimeEpochDelta)
+}
+
+func fromFiletime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, (int64(ft)-filetimeEpochDelta)*100)
+}
@@ -0,0 +1,745 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Server is a minimal SMB2 server exposing an afero.Fs as a single share, for a single account.
+// It implements just enough of the protocol to serve this package's client, and is meant to be
+// used as an in-process stand-in for a Windows or Samba file server in tests and demos.
+type Server struct {
+	Fs       afero.Fs
+	Share    string
+	User     string
+	Password string
+	Domain   string
+	// RequireSigning makes the server require (and produce) signed messages
+	RequireSigning bool
+
+	mu       sync.Mutex
+	sessions uint64
+	conns    map[net.Conn]struct{}
+}
+
+// Serve accepts connections on the listener until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(nc)
+	}
+}
+
+type serverHandle struct {
+	name          string
+	file          afero.File
+	isDir         bool
+	deleteOnClose bool
+
+	dirEntries []os.FileInfo
+	dirPos     int
+	dirListed  bool
+}
+
+type serverConn struct {
+	srv           *Server
+	nc            net.Conn
+	auth          *ntlmServer
+	sessionID     uint64
+	signingKey    []byte
+	authenticated bool
+	trees         map[uint32]bool
+	nextTree      uint32
+	handles       map[uint64]*serverHandle
+	nextHandle    uint64
+}
+
+// Disconnect closes all established connections, as a server restart would do.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	s.mu.Lock()
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[nc] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+	c := &serverConn{
+		srv:     s,
+		nc:      nc,
+		trees:   make(map[uint32]bool),
+		handles: make(map[uint64]*serverHandle),
+	}
+	defer func() {
+		for _, h := range c.handles {
+			h.file.Close()
+		}
+	}()
+	for {
+		msg, err := readFrame(nc)
+		if err != nil {
+			return
+		}
+		req, err := decodeHeader(msg)
+		if err != nil {
+			return
+		}
+		status, body := c.handle(req, msg)
+		resp := &header{
+			Status:    uint32(status),
+			Command:   req.Command,
+			Credits:   req.Credits,
+			Flags:     flagResponse,
+			MessageID: req.MessageID,
+			TreeID:    req.TreeID,
+			SessionID: c.sessionID,
+		}
+		if resp.Credits == 0 {
+			resp.Credits = 1
+		}
+		if req.Command == cmdTreeConnect && status == StatusSuccess {
+			resp.TreeID = c.nextTree
+		}
+		if body == nil {
+			body = errorResponse()
+		}
+		out := make([]byte, headerSize+len(body))
+		copy(out[headerSize:], body)
+		if s.RequireSigning && c.authenticated {
+			resp.Flags |= flagSigned
+			resp.encode(out)
+			sign(c.signingKey, out)
+		} else {
+			resp.encode(out)
+		}
+		if writeFrame(nc, out) != nil {
+			return
+		}
+	}
+}
+
+func errorResponse() []byte {
+	e := &encoder{}
+	e.u16(9)
+	e.u16(0)
+	e.u32(0)
+	e.u8(0)
+	return e.b
+}
+
+func (c *serverConn) handle(req *header, msg []byte) (Status, []byte) {
+	body := msg[headerSize:]
+	switch req.Command {
+	case cmdNegotiate:
+		return c.negotiate(body)
+	case cmdSessionSetup:
+		return c.sessionSetup(msg)
+	}
+	if !c.authenticated || req.SessionID != c.sessionID {
+		return StatusUserSessionDeleted, nil
+	}
+	if c.srv.RequireSigning && (req.Flags&flagSigned == 0 || !verify(c.signingKey, msg)) {
+		return StatusAccessDenied, nil
+	}
+	switch req.Command {
+	case cmdLogoff:
+		c.authenticated = false
+		return StatusSuccess, []byte{4, 0, 0, 0}
+	case cmdTreeConnect:
+		return c.treeConnect(msg)
+	}
+	if !c.trees[req.TreeID] {
+		return StatusNetworkNameDeleted, nil
+	}
+	switch req.Command {
+	case cmdTreeDisconnect:
+		delete(c.trees, req.TreeID)
+		return StatusSuccess, []byte{4, 0, 0, 0}
+	case cmdCreate:
+		return c.create(msg)
+	case cmdClose:
+		return c.close(body)
+	case cmdFlush:
+		h, ok := c.handles[le.Uint64(body[16:])]
+		if !ok {
+			return StatusFileClosed, nil
+		}
+		if !h.isDir {
+			h.file.Sync()
+		}
+		return StatusSuccess, []byte{4, 0, 0, 0}
+	case cmdRead:
+		return c.read(body)
+	case cmdWrite:
+		return c.write(msg)
+	case cmdQueryDirectory:
+		return c.queryDirectory(msg)
+	case cmdQueryInfo:
+		return c.queryInfo(body)
+	case cmdSetInfo:
+		return c.setInfo(msg)
+	}
+	return StatusNotSupported, nil
+}
+
+func (c *serverConn) negotiate(body []byte) (Status, []byte) {
+	d := &decoder{b: body}
+	d.skip(2)
+	count := int(d.u16())
+	d.skip(2 + 2 + 4 + 16 + 8)
+	var dialect uint16
+	for i := 0; i < count; i++ {
+		if v := d.u16(); (v == dialect202 || v == dialect210) && v > dialect {
+			dialect = v
+		}
+	}
+	if d.err != nil || dialect == 0 {
+		return StatusNotSupported, nil
+	}
+	token, err := encodeNegTokenInit(nil)
+	if err != nil {
+		return StatusInternalError, nil
+	}
+	mode := securitySigningEnabled
+	if c.srv.RequireSigning {
+		mode |= securitySigningRequired
+	}
+	guid := make([]byte, 16)
+	rand.Read(guid)
+	e := &encoder{}
+	e.u16(65)
+	e.u16(mode)
+	e.u16(dialect)
+	e.u16(0)
+	e.bytes(guid)
+	e.u32(0)
+	e.u32(maxChunkSize)
+	e.u32(maxChunkSize)
+	e.u32(maxChunkSize)
+	e.u64(toFiletime(time.Now()))
+	e.u64(0)
+	e.u16(headerSize + 64)
+	e.u16(uint16(len(token)))
+	e.u32(0)
+	e.bytes(token)
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) sessionSetup(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 12}
+	off, l := int(d.u16()), int(d.u16())
+	raw, err := slice(msg, off, l)
+	if d.err != nil || err != nil {
+		return StatusInvalidParameter, nil
+	}
+	token, err := decodeSPNEGO(raw)
+	if err != nil || len(token) < 12 {
+		return StatusLogonFailure, nil
+	}
+	var status Status
+	var respToken []byte
+	switch le.Uint32(token[8:]) {
+	case ntlmNegotiateType:
+		c.auth = &ntlmServer{user: c.srv.User, password: c.srv.Password, domain: c.srv.Domain}
+		challenge, err := c.auth.challengeMessage(token)
+		if err != nil {
+			return StatusLogonFailure, nil
+		}
+		c.srv.mu.Lock()
+		c.srv.sessions++
+		c.sessionID = c.srv.sessions
+		c.srv.mu.Unlock()
+		if respToken, err = encodeNegTokenResp(negStateAcceptIncomplete, challenge); err != nil {
+			return StatusInternalError, nil
+		}
+		status = StatusMoreProcessingRequired
+	case ntlmAuthenticateType:
+		if c.auth == nil || c.auth.authenticate(token) != nil {
+			return StatusLogonFailure, nil
+		}
+		if respToken, err = encodeNegTokenResp(negStateAcceptCompleted, nil); err != nil {
+			return StatusInternalError, nil
+		}
+		c.authenticated = true
+		c.signingKey = c.auth.sessionKey
+		status = StatusSuccess
+	default:
+		return StatusLogonFailure, nil
+	}
+	e := &encoder{}
+	e.u16(9)
+	e.u16(0)
+	e.u16(headerSize + 8)
+	e.u16(uint16(len(respToken)))
+	e.bytes(respToken)
+	return status, e.b
+}
+
+func (c *serverConn) treeConnect(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 4}
+	off, l := int(d.u16()), int(d.u16())
+	raw, err := slice(msg, off, l)
+	if d.err != nil || err != nil {
+		return StatusInvalidParameter, nil
+	}
+	name := decodeUTF16(raw)
+	if i := strings.LastIndex(name, "\\"); i > -1 {
+		name = name[i+1:]
+	}
+	if !strings.EqualFold(name, c.srv.Share) {
+		return StatusBadNetworkName, nil
+	}
+	c.nextTree++
+	c.trees[c.nextTree] = true
+	e := &encoder{}
+	e.u16(16)
+	e.u8(1) // Disk
+	e.u8(0)
+	e.u32(0)
+	e.u32(0)
+	e.u32(0x001F01FF)
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) create(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 24}
+	access := d.u32()
+	d.skip(4 + 4)
+	disposition := d.u32()
+	options := d.u32()
+	off, l := int(d.u16()), int(d.u16())
+	raw, err := slice(msg, off, l)
+	if d.err != nil || err != nil {
+		return StatusInvalidParameter, nil
+	}
+	fs := c.srv.Fs
+	name := "/" + sharePath(decodeUTF16(raw), "/")
+	st, statErr := fs.Stat(name)
+	exists := statErr == nil
+
+	if exists {
+		switch {
+		case disposition == fileCreate:
+			return StatusObjectNameCollision, nil
+		case options&fileDirectoryFile != 0 && !st.IsDir():
+			return StatusNotADirectory, nil
+		case options&fileNonDirectoryFile != 0 && st.IsDir():
+			return StatusFileIsADirectory, nil
+		}
+	} else {
+		if disposition == fileOpen || disposition == fileOverwrite {
+			if _, e := fs.Stat(path.Dir(name)); e != nil {
+				return StatusObjectPathNotFound, nil
+			}
+			return StatusObjectNameNotFound, nil
+		}
+		if parent, e := fs.Stat(path.Dir(name)); e != nil || !parent.IsDir() {
+			return StatusObjectPathNotFound, nil
+		}
+	}
+
+	var action uint32 = 1 // FILE_OPENED
+	isDir := exists && st.IsDir() || !exists && options&fileDirectoryFile != 0
+	flag := os.O_RDONLY
+	if isDir && !exists {
+		action = 2 // FILE_CREATED
+		if e := fs.Mkdir(name, 0755); e != nil {
+			return statusFromError(e), nil
+		}
+	} else if !isDir {
+		if access&(accessWriteData|accessAppendData) != 0 {
+			flag = os.O_RDWR
+		}
+		if !exists {
+			action = 2 // FILE_CREATED
+			flag = os.O_RDWR | os.O_CREATE
+		} else if disposition == fileOverwrite || disposition == fileOverwriteIf || disposition == fileSupersede {
+			action = 3 // FILE_OVERWRITTEN
+			flag = os.O_RDWR | os.O_TRUNC
+		}
+	}
+	if options&fileDeleteOnClose != 0 && exists && st.IsDir() {
+		if children, e := afero.ReadDir(fs, name); e != nil || len(children) > 0 {
+			return StatusDirectoryNotEmpty, nil
+		}
+	}
+	file, err := fs.OpenFile(name, flag, 0666)
+	if err != nil {
+		return statusFromError(err), nil
+	}
+	if st, err = file.Stat(); err != nil {
+		file.Close()
+		return statusFromError(err), nil
+	}
+	c.nextHandle++
+	id := c.nextHandle
+	c.handles[id] = &serverHandle{
+		name:          name,
+		file:          file,
+		isDir:         st.IsDir(),
+		deleteOnClose: options&fileDeleteOnClose != 0,
+	}
+
+	e := &encoder{}
+	e.u16(89)
+	e.u8(0)
+	e.u8(0)
+	e.u32(action)
+	encodeNetworkOpenInfo(e, st)
+	e.u64(id)
+	e.u64(id)
+	e.u32(0)
+	e.u32(0)
+	return StatusSuccess, e.b
+}
+
+// encodeNetworkOpenInfo writes times, sizes and attributes of a file, as found in CREATE, CLOSE
+// responses and FileNetworkOpenInformation.
+func encodeNetworkOpenInfo(e *encoder, st os.FileInfo) {
+	mtime := toFiletime(st.ModTime())
+	e.u64(mtime)
+	e.u64(mtime)
+	e.u64(mtime)
+	e.u64(mtime)
+	var attrs = attrNormal
+	var size uint64
+	if st.IsDir() {
+		attrs = attrDirectory
+	} else {
+		size = uint64(st.Size())
+	}
+	e.u64(size)
+	e.u64(size)
+	e.u32(attrs)
+	e.u32(0)
+}
+
+func (c *serverConn) close(body []byte) (Status, []byte) {
+	id := le.Uint64(body[16:])
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	delete(c.handles, id)
+	st, _ := h.file.Stat()
+	h.file.Close()
+	if h.deleteOnClose {
+		if e := c.srv.Fs.Remove(h.name); e != nil {
+			return statusFromError(e), nil
+		}
+	}
+	e := &encoder{}
+	e.u16(60)
+	e.u16(le.Uint16(body[2:]))
+	e.u32(0)
+	if le.Uint16(body[2:])&1 != 0 && st != nil {
+		encodeNetworkOpenInfo(e, st)
+		e.b = e.b[:len(e.b)-4]
+	} else {
+		e.zero(52)
+	}
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) read(body []byte) (Status, []byte) {
+	d := &decoder{b: body, off: 4}
+	length := d.u32()
+	offset := int64(d.u64())
+	d.skip(8)
+	id := d.u64()
+	if d.err != nil || length > maxChunkSize {
+		return StatusInvalidParameter, nil
+	}
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	if h.isDir {
+		return StatusInvalidParameter, nil
+	}
+	buf := make([]byte, length)
+	n, err := h.file.ReadAt(buf, offset)
+	if n == 0 && length > 0 {
+		if err == nil || err == io.EOF {
+			return StatusEndOfFile, nil
+		}
+		return statusFromError(err), nil
+	}
+	e := &encoder{}
+	e.u16(17)
+	e.u8(headerSize + 16)
+	e.u8(0)
+	e.u32(uint32(n))
+	e.u32(0)
+	e.u32(0)
+	e.bytes(buf[:n])
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) write(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 2}
+	off := int(d.u16())
+	length := int(d.u32())
+	offset := int64(d.u64())
+	d.skip(8)
+	id := d.u64()
+	data, err := slice(msg, off, length)
+	if d.err != nil || err != nil {
+		return StatusInvalidParameter, nil
+	}
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	n, err := h.file.WriteAt(data, offset)
+	if err != nil {
+		return statusFromError(err), nil
+	}
+	e := &encoder{}
+	e.u16(17)
+	e.u16(0)
+	e.u32(uint32(n))
+	e.u32(0)
+	e.u16(0)
+	e.u16(0)
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) queryDirectory(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 3}
+	flags := d.u8()
+	d.skip(4 + 8)
+	id := d.u64()
+	off, l := int(d.u16()), int(d.u16())
+	outLen := int(d.u32())
+	raw, err := slice(msg, off, l)
+	if d.err != nil || err != nil {
+		return StatusInvalidParameter, nil
+	}
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	if !h.isDir {
+		return StatusInvalidParameter, nil
+	}
+	if !h.dirListed || flags&queryDirectoryRestartScans != 0 {
+		infos, err := afero.ReadDir(c.srv.Fs, h.name)
+		if err != nil {
+			return statusFromError(err), nil
+		}
+		st, _ := h.file.Stat()
+		pattern := decodeUTF16(raw)
+		h.dirEntries = nil
+		for _, dot := range []string{".", ".."} {
+			if pattern == "*" && st != nil {
+				h.dirEntries = append(h.dirEntries, &renamedInfo{FileInfo: st, name: dot})
+			}
+		}
+		for _, info := range infos {
+			if pattern == "*" || strings.EqualFold(pattern, info.Name()) {
+				h.dirEntries = append(h.dirEntries, info)
+			}
+		}
+		h.dirPos = 0
+		h.dirListed = true
+	}
+	if h.dirPos >= len(h.dirEntries) {
+		return StatusNoMoreFiles, nil
+	}
+	out := &encoder{}
+	last := -1
+	for h.dirPos < len(h.dirEntries) {
+		info := h.dirEntries[h.dirPos]
+		name := encodeUTF16(info.Name())
+		entry := &encoder{}
+		entry.u32(0) // NextEntryOffset, updated when appending the next entry
+		entry.u32(0)
+		encodeNetworkOpenInfo(entry, info)
+		entry.b = entry.b[:len(entry.b)-4]
+		entry.u32(uint32(len(name)))
+		entry.bytes(name)
+		start := len(out.b)
+		if last > -1 {
+			start = (start + 7) &^ 7
+		}
+		if start+len(entry.b) > outLen {
+			break
+		}
+		if last > -1 {
+			out.align(8)
+			le.PutUint32(out.b[last:], uint32(start-last))
+		}
+		out.bytes(entry.b)
+		last = start
+		h.dirPos++
+	}
+	if last == -1 {
+		return StatusInvalidParameter, nil
+	}
+	e := &encoder{}
+	e.u16(9)
+	e.u16(headerSize + 8)
+	e.u32(uint32(len(out.b)))
+	e.bytes(out.b)
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) queryInfo(body []byte) (Status, []byte) {
+	d := &decoder{b: body, off: 2}
+	infoType, class := d.u8(), d.u8()
+	d.off = 32
+	id := d.u64()
+	if d.err != nil {
+		return StatusInvalidParameter, nil
+	}
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	if infoType != infoFile || class != classFileNetworkOpenInformation {
+		return StatusNotSupported, nil
+	}
+	st, err := h.file.Stat()
+	if err != nil {
+		return statusFromError(err), nil
+	}
+	info := &encoder{}
+	encodeNetworkOpenInfo(info, st)
+	e := &encoder{}
+	e.u16(9)
+	e.u16(headerSize + 8)
+	e.u32(uint32(len(info.b)))
+	e.bytes(info.b)
+	return StatusSuccess, e.b
+}
+
+func (c *serverConn) setInfo(msg []byte) (Status, []byte) {
+	d := &decoder{b: msg, off: headerSize + 2}
+	infoType, class := d.u8(), d.u8()
+	l := int(d.u32())
+	off := int(d.u16())
+	d.skip(2 + 4 + 8)
+	id := d.u64()
+	buf, err := slice(msg, off, l)
+	if d.err != nil || err != nil || infoType != infoFile {
+		return StatusInvalidParameter, nil
+	}
+	h, ok := c.handles[id]
+	if !ok {
+		return StatusFileClosed, nil
+	}
+	fs := c.srv.Fs
+	switch class {
+	case classFileBasicInformation:
+		if len(buf) < 36 {
+			return StatusInvalidParameter, nil
+		}
+		if mtime := le.Uint64(buf[16:]); mtime != 0 {
+			atime := le.Uint64(buf[8:])
+			if atime == 0 {
+				atime = mtime
+			}
+			if e := fs.Chtimes(h.name, fromFiletime(atime), fromFiletime(mtime)); e != nil {
+				return statusFromError(e), nil
+			}
+		}
+	case classFileRenameInformation:
+		if len(buf) < 20 {
+			return StatusInvalidParameter, nil
+		}
+		nameLen := int(le.Uint32(buf[16:]))
+		if 20+nameLen > len(buf) {
+			return StatusInvalidParameter, nil
+		}
+		target := "/" + sharePath(decodeUTF16(buf[20:20+nameLen]), "/")
+		if st, e := fs.Stat(target); e == nil {
+			if buf[0] == 0 {
+				return StatusObjectNameCollision, nil
+			}
+			if st.IsDir() {
+				return StatusAccessDenied, nil
+			}
+		}
+		if parent, e := fs.Stat(path.Dir(target)); e != nil || !parent.IsDir() {
+			return StatusObjectPathNotFound, nil
+		}
+		if e := fs.Rename(h.name, target); e != nil {
+			return statusFromError(e), nil
+		}
+		h.name = target
+	case classFileDispositionInformation:
+		if len(buf) < 1 {
+			return StatusInvalidParameter, nil
+		}
+		if buf[0] != 0 && h.isDir {
+			if children, e := afero.ReadDir(fs, h.name); e != nil || len(children) > 0 {
+				return StatusDirectoryNotEmpty, nil
+			}
+		}
+		h.deleteOnClose = buf[0] != 0
+	case classFileEndOfFileInformation:
+		if len(buf) < 8 {
+			return StatusInvalidParameter, nil
+		}
+		if e := h.file.Truncate(int64(le.Uint64(buf))); e != nil {
+			return statusFromError(e), nil
+		}
+	default:
+		return StatusNotSupported, nil
+	}
+	return StatusSuccess, []byte{2, 0}
+}
+
+// renamedInfo is used to list the "." and ".." entries of a directory
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r *renamedInfo) Name() string {
+	return r.name
+}
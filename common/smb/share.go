@@ -0,0 +1,678 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Share is a mounted SMB share, exposing an os-like API. Paths are relative to the share root
+// and may use either slashes or backslashes.
+type Share struct {
+	c      *conn
+	treeID uint32
+	name   string
+}
+
+// Umount disconnects from the share.
+func (s *Share) Umount() error {
+	e := &encoder{}
+	e.u16(4)
+	e.u16(0)
+	_, _, err := s.c.send(cmdTreeDisconnect, s.treeID, e.b)
+	return err
+}
+
+// Stat returns a FileInfo describing the named file or folder.
+func (s *Share) Stat(name string) (os.FileInfo, error) {
+	info, err := s.create(name, accessReadAttributes, fileOpen, 0)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	s.close(info.fileID)
+	return info.fileInfo(path.Base(sharePath(name, "/"))), nil
+}
+
+// ReadDir reads the named directory and returns a list of its entries.
+func (s *Share) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+// Open opens the named file or folder for reading.
+func (s *Share) Open(name string) (*File, error) {
+	return s.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file.
+func (s *Share) Create(name string) (*File, error) {
+	return s.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens a file with the os package flags semantics. Permissions are ignored.
+func (s *Share) OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
+	access := accessReadAttributes | accessSynchronize
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		access |= accessReadData | accessReadEA | accessReadControl
+	case os.O_WRONLY:
+		access |= accessWriteData | accessAppendData | accessWriteEA | accessWriteAttributes
+	case os.O_RDWR:
+		access |= accessReadData | accessReadEA | accessReadControl | accessWriteData | accessAppendData | accessWriteEA | accessWriteAttributes
+	}
+	disposition := fileOpen
+	switch {
+	case flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		disposition = fileCreate
+	case flag&os.O_CREATE != 0 && flag&os.O_TRUNC != 0:
+		disposition = fileOverwriteIf
+	case flag&os.O_CREATE != 0:
+		disposition = fileOpenIf
+	case flag&os.O_TRUNC != 0:
+		disposition = fileOverwrite
+	}
+	var options uint32
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		options = fileNonDirectoryFile
+	}
+	info, err := s.create(name, access, disposition, options)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	f := &File{
+		share: s,
+		name:  name,
+		id:    info.fileID,
+		isDir: info.attributes&attrDirectory != 0,
+	}
+	if flag&os.O_APPEND != 0 {
+		f.offset = int64(info.endOfFile)
+	}
+	return f, nil
+}
+
+// Mkdir creates a new directory. Permissions are ignored.
+func (s *Share) Mkdir(name string, perm os.FileMode) error {
+	info, err := s.create(name, accessReadAttributes, fileCreate, fileDirectoryFile)
+	if err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return s.close(info.fileID)
+}
+
+// MkdirAll creates a directory and all its missing parents.
+func (s *Share) MkdirAll(name string, perm os.FileMode) error {
+	p := sharePath(name, "/")
+	if p == "" {
+		return nil
+	}
+	if st, err := s.Stat(p); err == nil {
+		if st.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: StatusNotADirectory}
+	}
+	if parent := path.Dir(p); parent != "." {
+		if err := s.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := s.Mkdir(p, perm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Remove removes a file or an empty directory.
+func (s *Share) Remove(name string) error {
+	info, err := s.create(name, accessDelete|accessReadAttributes, fileOpen, fileDeleteOnClose)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	return pathError("remove", name, s.close(info.fileID))
+}
+
+// RemoveAll removes a path and its children. It does not fail if the path does not exist.
+func (s *Share) RemoveAll(name string) error {
+	st, err := s.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if st.IsDir() {
+		children, err := s.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, c := range children {
+			if err := s.RemoveAll(path.Join(sharePath(name, "/"), c.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Remove(name)
+}
+
+// Rename moves a file or folder, replacing the target if it is an existing file.
+func (s *Share) Rename(oldName, newName string) error {
+	info, err := s.create(oldName, accessDelete|accessReadAttributes|accessSynchronize, fileOpen, 0)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: osError(err)}
+	}
+	defer s.close(info.fileID)
+	target := encodeUTF16(sharePath(newName, "\\"))
+	e := &encoder{}
+	e.u8(1) // ReplaceIfExists
+	e.zero(7)
+	e.u64(0)
+	e.u32(uint32(len(target)))
+	e.bytes(target)
+	if err := s.setInfo(info.fileID, classFileRenameInformation, e.b); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: osError(err)}
+	}
+	return nil
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (s *Share) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	info, err := s.create(name, accessWriteAttributes|accessReadAttributes, fileOpen, 0)
+	if err != nil {
+		return pathError("chtimes", name, err)
+	}
+	defer s.close(info.fileID)
+	e := &encoder{}
+	e.u64(0)
+	e.u64(toFiletime(atime))
+	e.u64(toFiletime(mtime))
+	e.u64(0)
+	e.u32(0)
+	e.u32(0)
+	return pathError("chtimes", name, s.setInfo(info.fileID, classFileBasicInformation, e.b))
+}
+
+// sharePath cleans a path and converts it to the given separator, without leading separator.
+func sharePath(name, separator string) string {
+	p := path.Clean("/" + strings.Replace(name, "\\", "/", -1))
+	p = strings.TrimPrefix(p, "/")
+	if separator != "/" {
+		p = strings.Replace(p, "/", separator, -1)
+	}
+	return p
+}
+
+// createInfo is the decoded CREATE (or CLOSE/QUERY_INFO) response.
+type createInfo struct {
+	fileID     [16]byte
+	mtime      time.Time
+	endOfFile  uint64
+	attributes uint32
+}
+
+func (i *createInfo) fileInfo(name string) os.FileInfo {
+	return &fileStat{
+		name:  name,
+		size:  int64(i.endOfFile),
+		mtime: i.mtime,
+		attrs: i.attributes,
+	}
+}
+
+func (s *Share) create(name string, access, disposition, options uint32) (*createInfo, error) {
+	fileName := encodeUTF16(sharePath(name, "\\"))
+	e := &encoder{}
+	e.u16(57)
+	e.u8(0)  // SecurityFlags
+	e.u8(0)  // RequestedOplockLevel: none
+	e.u32(2) // Impersonation
+	e.u64(0) // SmbCreateFlags
+	e.u64(0) // Reserved
+	e.u32(access)
+	e.u32(attrNormal)
+	e.u32(shareAll)
+	e.u32(disposition)
+	e.u32(options)
+	e.u16(headerSize + 56)
+	e.u16(uint16(len(fileName)))
+	e.u32(0)
+	e.u32(0)
+	e.bytes(fileName)
+	if len(fileName) == 0 {
+		// Buffer must contain at least one byte
+		e.u8(0)
+	}
+	_, resp, err := s.c.send(cmdCreate, s.treeID, e.b)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: resp, off: headerSize}
+	if d.u16() != 89 {
+		return nil, errors.New("smb: invalid create response")
+	}
+	d.skip(2 + 4 + 8 + 8)
+	info := &createInfo{}
+	info.mtime = fromFiletime(d.u64())
+	d.skip(8 + 8)
+	info.endOfFile = d.u64()
+	info.attributes = d.u32()
+	d.skip(4)
+	copy(info.fileID[:], d.next(16))
+	return info, d.err
+}
+
+func (s *Share) close(id [16]byte) error {
+	e := &encoder{}
+	e.u16(24)
+	e.u16(0)
+	e.u32(0)
+	e.bytes(id[:])
+	_, _, err := s.c.send(cmdClose, s.treeID, e.b)
+	return err
+}
+
+func (s *Share) read(id [16]byte, p []byte, offset int64) (int, error) {
+	e := &encoder{}
+	e.u16(49)
+	e.u8(0x50) // Padding
+	e.u8(0)
+	e.u32(uint32(len(p)))
+	e.u64(uint64(offset))
+	e.bytes(id[:])
+	e.u32(0) // MinimumCount
+	e.u32(0)
+	e.u32(0)
+	e.u16(0)
+	e.u16(0)
+	e.u8(0)
+	_, resp, err := s.c.send(cmdRead, s.treeID, e.b)
+	if err != nil {
+		if err == StatusEndOfFile {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	d := &decoder{b: resp, off: headerSize}
+	d.skip(2)
+	off := int(d.u8())
+	d.skip(1)
+	l := int(d.u32())
+	if d.err != nil || off+l > len(resp) || l > len(p) {
+		return 0, errors.New("smb: invalid read response")
+	}
+	return copy(p, resp[off:off+l]), nil
+}
+
+func (s *Share) write(id [16]byte, p []byte, offset int64) (int, error) {
+	e := &encoder{}
+	e.u16(49)
+	e.u16(headerSize + 48)
+	e.u32(uint32(len(p)))
+	e.u64(uint64(offset))
+	e.bytes(id[:])
+	e.u32(0)
+	e.u32(0)
+	e.u16(0)
+	e.u16(0)
+	e.u32(0)
+	e.bytes(p)
+	_, resp, err := s.c.send(cmdWrite, s.treeID, e.b)
+	if err != nil {
+		return 0, err
+	}
+	d := &decoder{b: resp, off: headerSize + 4}
+	n := int(d.u32())
+	return n, d.err
+}
+
+func (s *Share) flush(id [16]byte) error {
+	e := &encoder{}
+	e.u16(24)
+	e.u16(0)
+	e.u32(0)
+	e.bytes(id[:])
+	_, _, err := s.c.send(cmdFlush, s.treeID, e.b)
+	return err
+}
+
+func (s *Share) queryDirectory(id [16]byte, restart bool) ([]os.FileInfo, error) {
+	pattern := encodeUTF16("*")
+	e := &encoder{}
+	e.u16(33)
+	e.u8(classFileDirectoryInformation)
+	if restart {
+		e.u8(queryDirectoryRestartScans)
+	} else {
+		e.u8(0)
+	}
+	e.u32(0)
+	e.bytes(id[:])
+	e.u16(headerSize + 32)
+	e.u16(uint16(len(pattern)))
+	e.u32(maxChunkSize)
+	e.bytes(pattern)
+	_, resp, err := s.c.send(cmdQueryDirectory, s.treeID, e.b)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: resp, off: headerSize + 2}
+	off, l := int(d.u16()), int(d.u32())
+	if d.err != nil {
+		return nil, d.err
+	}
+	buf, err := slice(resp, off, l)
+	if err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for len(buf) >= 64 {
+		next := int(le.Uint32(buf))
+		mtime := fromFiletime(le.Uint64(buf[24:]))
+		size := le.Uint64(buf[40:])
+		attrs := le.Uint32(buf[56:])
+		nameLen := int(le.Uint32(buf[60:]))
+		if 64+nameLen > len(buf) {
+			return nil, errors.New("smb: invalid directory entry")
+		}
+		name := decodeUTF16(buf[64 : 64+nameLen])
+		if name != "." && name != ".." {
+			infos = append(infos, &fileStat{name: name, size: int64(size), mtime: mtime, attrs: attrs})
+		}
+		if next == 0 || next > len(buf) {
+			break
+		}
+		buf = buf[next:]
+	}
+	return infos, nil
+}
+
+func (s *Share) queryInfo(id [16]byte) (*createInfo, error) {
+	e := &encoder{}
+	e.u16(41)
+	e.u8(infoFile)
+	e.u8(classFileNetworkOpenInformation)
+	e.u32(56)
+	e.u16(0)
+	e.u16(0)
+	e.u32(0)
+	e.u32(0)
+	e.u32(0)
+	e.bytes(id[:])
+	_, resp, err := s.c.send(cmdQueryInfo, s.treeID, e.b)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: resp, off: headerSize + 2}
+	off, l := int(d.u16()), int(d.u32())
+	buf, err := slice(resp, off, l)
+	if d.err != nil || err != nil || len(buf) < 56 {
+		return nil, errors.New("smb: invalid query info response")
+	}
+	return &createInfo{
+		fileID:     id,
+		mtime:      fromFiletime(le.Uint64(buf[16:])),
+		endOfFile:  le.Uint64(buf[40:]),
+		attributes: le.Uint32(buf[48:]),
+	}, nil
+}
+
+func (s *Share) setInfo(id [16]byte, class uint8, buf []byte) error {
+	e := &encoder{}
+	e.u16(33)
+	e.u8(infoFile)
+	e.u8(class)
+	e.u32(uint32(len(buf)))
+	e.u16(headerSize + 32)
+	e.u16(0)
+	e.u32(0)
+	e.bytes(id[:])
+	e.bytes(buf)
+	_, _, err := s.c.send(cmdSetInfo, s.treeID, e.b)
+	return err
+}
+
+// File is an open file or directory handle on a share.
+// It implements afero.File.
+type File struct {
+	share  *Share
+	name   string
+	id     [16]byte
+	isDir  bool
+	offset int64
+	closed bool
+
+	dirBuffer []os.FileInfo
+	dirRead   bool
+	dirEOF    bool
+}
+
+// Name returns the name of the file as passed to Open.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Close closes the handle.
+func (f *File) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return pathError("close", f.name, f.share.close(f.id))
+}
+
+// Read reads from the current offset.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt reads len(p) bytes starting at byte offset off.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.isDir {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: StatusFileIsADirectory}
+	}
+	var total int
+	for total < len(p) {
+		chunk := p[total:]
+		if len(chunk) > f.share.c.maxRead {
+			chunk = chunk[:f.share.c.maxRead]
+		}
+		n, err := f.share.read(f.id, chunk, off+int64(total))
+		total += n
+		if err != nil {
+			if err == io.EOF {
+				return total, io.EOF
+			}
+			return total, pathError("read", f.name, err)
+		}
+		if n == 0 {
+			return total, io.EOF
+		}
+	}
+	return total, nil
+}
+
+// Write writes at the current offset.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteString is a shortcut for Write([]byte(s)).
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// WriteAt writes len(p) bytes starting at byte offset off.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	var total int
+	for total < len(p) {
+		chunk := p[total:]
+		if len(chunk) > f.share.c.maxWrite {
+			chunk = chunk[:f.share.c.maxWrite]
+		}
+		n, err := f.share.write(f.id, chunk, off+int64(total))
+		total += n
+		if err != nil {
+			return total, pathError("write", f.name, err)
+		}
+		if n == 0 {
+			return total, io.ErrShortWrite
+		}
+	}
+	return total, nil
+}
+
+// Seek sets the offset for the next Read or Write.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		st, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += st.Size()
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: StatusInvalidParameter}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat returns information about the open file.
+func (f *File) Stat() (os.FileInfo, error) {
+	info, err := f.share.queryInfo(f.id)
+	if err != nil {
+		return nil, pathError("stat", f.name, err)
+	}
+	return info.fileInfo(path.Base(sharePath(f.name, "/"))), nil
+}
+
+// Sync flushes data to the server storage.
+func (f *File) Sync() error {
+	return pathError("sync", f.name, f.share.flush(f.id))
+}
+
+// Truncate changes the size of the file.
+func (f *File) Truncate(size int64) error {
+	e := &encoder{}
+	e.u64(uint64(size))
+	return pathError("truncate", f.name, f.share.setInfo(f.id, classFileEndOfFileInformation, e.b))
+}
+
+// Readdir reads the contents of the directory, with the os.File semantics.
+func (f *File) Readdir(count int) ([]os.FileInfo, error) {
+	for !f.dirEOF && (count <= 0 || len(f.dirBuffer) < count) {
+		infos, err := f.share.queryDirectory(f.id, !f.dirRead)
+		f.dirRead = true
+		if err == StatusNoMoreFiles {
+			f.dirEOF = true
+			break
+		} else if err != nil {
+			return nil, pathError("readdir", f.name, err)
+		}
+		f.dirBuffer = append(f.dirBuffer, infos...)
+	}
+	if count <= 0 {
+		infos := f.dirBuffer
+		f.dirBuffer = nil
+		return infos, nil
+	}
+	if len(f.dirBuffer) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.dirBuffer) {
+		count = len(f.dirBuffer)
+	}
+	infos := f.dirBuffer[:count]
+	f.dirBuffer = f.dirBuffer[count:]
+	return infos, nil
+}
+
+// Readdirnames is like Readdir but only returns names.
+func (f *File) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// fileStat implements os.FileInfo
+type fileStat struct {
+	name  string
+	size  int64
+	mtime time.Time
+	attrs uint32
+}
+
+func (s *fileStat) Name() string {
+	return s.name
+}
+
+func (s *fileStat) Size() int64 {
+	if s.IsDir() {
+		return 0
+	}
+	return s.size
+}
+
+func (s *fileStat) Mode() os.FileMode {
+	var m os.FileMode = 0666
+	if s.attrs&attrReadonly != 0 {
+		m = 0444
+	}
+	if s.IsDir() {
+		m |= os.ModeDir | 0111
+	}
+	return m
+}
+
+func (s *fileStat) ModTime() time.Time {
+	return s.mtime
+}
+
+func (s *fileStat) IsDir() bool {
+	return s.attrs&attrDirectory != 0
+}
+
+func (s *fileStat) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,360 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package smb provides a lightweight SMB2 client, used to access Windows file servers (or Samba)
+// without mounting them on the host.
+//
+// Only the subset of the protocol required for file management is implemented: dialects 2.0.2 and 2.1,
+// NTLMv2 authentication wrapped in SPNEGO, and message signing when the server requires it.
+// A Share exposes an os-like API, and NewFs wraps it as an afero.Fs.
+// The package also ships a minimal in-process Server that can be used as a stand-in in tests.
+package smb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Reads and writes are split in chunks that fit in one credit
+	maxChunkSize = 64 * 1024
+	// Number of credits asked on each request
+	creditsRequest = 64
+)
+
+// Dialer holds the credentials used to establish a Session.
+type Dialer struct {
+	User        string
+	Password    string
+	Domain      string
+	Workstation string
+	// Timeout is used for connecting, and as a deadline for each request/response roundtrip
+	Timeout time.Duration
+}
+
+// ParseUser splits a "DOMAIN\user" or "user@domain" login into user and domain.
+func ParseUser(login string) (user, domain string) {
+	if i := strings.Index(login, "\\"); i > -1 {
+		return login[i+1:], login[:i]
+	}
+	if i := strings.LastIndex(login, "@"); i > -1 {
+		return login[:i], login[i+1:]
+	}
+	return login, ""
+}
+
+// Dial connects to the server at addr (host or host:port, 445 by default) and authenticates.
+func (d *Dialer) Dial(addr string) (*Session, error) {
+	if _, _, e := net.SplitHostPort(addr); e != nil {
+		addr = net.JoinHostPort(addr, "445")
+	}
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	nc, e := net.DialTimeout("tcp", addr, timeout)
+	if e != nil {
+		return nil, e
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	s, e := d.NewSession(nc, host)
+	if e != nil {
+		nc.Close()
+		return nil, e
+	}
+	return s, nil
+}
+
+// NewSession negotiates and authenticates a session on an already established connection.
+func (d *Dialer) NewSession(nc net.Conn, serverName string) (*Session, error) {
+	c := &conn{nc: nc, timeout: d.Timeout}
+	if e := c.negotiate(); e != nil {
+		return nil, e
+	}
+	auth := &ntlmClient{
+		user:        d.User,
+		password:    d.Password,
+		domain:      d.Domain,
+		workstation: d.Workstation,
+	}
+	if e := c.sessionSetup(auth); e != nil {
+		return nil, e
+	}
+	return &Session{c: c, server: serverName}, nil
+}
+
+// Session is an authenticated connection to a server.
+type Session struct {
+	c      *conn
+	server string
+}
+
+// Mount connects to a share of the server.
+func (s *Session) Mount(shareName string) (*Share, error) {
+	shareName = strings.Trim(strings.Replace(shareName, "/", "\\", -1), "\\")
+	if !strings.HasPrefix(shareName, "\\\\") {
+		shareName = fmt.Sprintf("\\\\%s\\%s", s.server, shareName)
+	}
+	path := encodeUTF16(shareName)
+	e := &encoder{}
+	e.u16(9)
+	e.u16(0)
+	e.u16(headerSize + 8)
+	e.u16(uint16(len(path)))
+	e.bytes(path)
+	h, _, err := s.c.send(cmdTreeConnect, 0, e.b)
+	if err != nil {
+		return nil, pathError("mount", shareName, err)
+	}
+	return &Share{c: s.c, treeID: h.TreeID, name: shareName}, nil
+}
+
+// Logoff closes the session and the underlying connection.
+func (s *Session) Logoff() error {
+	e := &encoder{}
+	e.u16(4)
+	e.u16(0)
+	_, _, err := s.c.send(cmdLogoff, 0, e.b)
+	s.c.nc.Close()
+	return err
+}
+
+// conn serializes requests on a connection and keeps track of the negotiated parameters.
+type conn struct {
+	sync.Mutex
+	nc      net.Conn
+	timeout time.Duration
+
+	messageID   uint64
+	dialect     uint16
+	maxRead     int
+	maxWrite    int
+	sessionID   uint64
+	signingKey  []byte
+	signRequest bool
+	// broken is set when the transport failed, the connection cannot be used anymore
+	broken error
+}
+
+// send runs a request/response roundtrip, returning the response header and the full response message.
+// Response status other than success are returned as errors of type Status.
+func (c *conn) send(command uint16, treeID uint32, body []byte) (*header, []byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.broken != nil {
+		return nil, nil, c.broken
+	}
+	h := &header{
+		Command:   command,
+		Credits:   creditsRequest,
+		MessageID: c.messageID,
+		TreeID:    treeID,
+		SessionID: c.sessionID,
+	}
+	if c.dialect >= dialect210 {
+		h.CreditCharge = 1
+	}
+	c.messageID++
+	msg := make([]byte, headerSize+len(body))
+	copy(msg[headerSize:], body)
+	if c.signRequest && c.signingKey != nil && command != cmdSessionSetup {
+		h.Flags |= flagSigned
+		h.encode(msg)
+		sign(c.signingKey, msg)
+	} else {
+		h.encode(msg)
+	}
+
+	if c.timeout > 0 {
+		c.nc.SetDeadline(time.Now().Add(c.timeout))
+		defer c.nc.SetDeadline(time.Time{})
+	}
+	if e := writeFrame(c.nc, msg); e != nil {
+		return nil, nil, c.fail(e)
+	}
+	for {
+		resp, e := readFrame(c.nc)
+		if e != nil {
+			return nil, nil, c.fail(e)
+		}
+		rh, e := decodeHeader(resp)
+		if e != nil {
+			return nil, nil, c.fail(e)
+		}
+		if rh.MessageID != h.MessageID {
+			continue
+		}
+		// Interim response, the final one will follow
+		if rh.Flags&flagAsync != 0 && Status(rh.Status) == StatusPending {
+			continue
+		}
+		if rh.Flags&flagSigned != 0 && c.signingKey != nil && !verify(c.signingKey, resp) {
+			return nil, nil, errors.New("smb: invalid response signature")
+		}
+		if s := Status(rh.Status); s != StatusSuccess && s != StatusMoreProcessingRequired {
+			return rh, resp, s
+		}
+		return rh, resp, nil
+	}
+}
+
+// fail marks the connection as broken: once a frame is lost, requests and responses cannot be matched anymore.
+func (c *conn) fail(err error) error {
+	c.broken = err
+	c.nc.Close()
+	return err
+}
+
+func (c *conn) negotiate() error {
+	guid := make([]byte, 16)
+	rand.Read(guid)
+	e := &encoder{}
+	e.u16(36)
+	e.u16(2) // dialect count
+	e.u16(securitySigningEnabled)
+	e.u16(0)
+	e.u32(0) // capabilities
+	e.bytes(guid)
+	e.u64(0)
+	e.u16(dialect202)
+	e.u16(dialect210)
+	_, resp, err := c.send(cmdNegotiate, 0, e.b)
+	if err != nil {
+		return err
+	}
+	d := &decoder{b: resp, off: headerSize}
+	if d.u16() != 65 {
+		return errors.New("smb: invalid negotiate response")
+	}
+	securityMode := d.u16()
+	c.dialect = d.u16()
+	d.skip(2 + 16 + 4)
+	d.skip(4) // max transact
+	c.maxRead = int(d.u32())
+	c.maxWrite = int(d.u32())
+	if d.err != nil {
+		return d.err
+	}
+	if c.dialect != dialect202 && c.dialect != dialect210 {
+		return fmt.Errorf("smb: unsupported dialect 0x%04x", c.dialect)
+	}
+	if c.maxRead > maxChunkSize || c.maxRead <= 0 {
+		c.maxRead = maxChunkSize
+	}
+	if c.maxWrite > maxChunkSize || c.maxWrite <= 0 {
+		c.maxWrite = maxChunkSize
+	}
+	c.signRequest = securityMode&securitySigningRequired != 0
+	return nil
+}
+
+func (c *conn) sessionSetup(auth *ntlmClient) error {
+	token, err := encodeNegTokenInit(auth.negotiateMessage())
+	if err != nil {
+		return err
+	}
+	h, resp, err := c.send(cmdSessionSetup, 0, sessionSetupRequest(token))
+	if err != nil {
+		return err
+	}
+	if Status(h.Status) != StatusMoreProcessingRequired {
+		return errors.New("smb: unexpected session setup response")
+	}
+	c.sessionID = h.SessionID
+	challenge, err := decodeSessionSetupResponse(resp)
+	if err != nil {
+		return err
+	}
+	authenticate, err := auth.authenticateMessage(challenge)
+	if err != nil {
+		return err
+	}
+	if token, err = encodeNegTokenResp(negStateAcceptIncomplete, authenticate); err != nil {
+		return err
+	}
+	// Session key is known from now, the final response may be signed
+	c.signingKey = auth.sessionKey
+	if _, _, err = c.send(cmdSessionSetup, 0, sessionSetupRequest(token)); err != nil {
+		c.signingKey = nil
+		return err
+	}
+	return nil
+}
+
+func sessionSetupRequest(token []byte) []byte {
+	e := &encoder{}
+	e.u16(25)
+	e.u8(0)
+	e.u8(uint8(securitySigningEnabled))
+	e.u32(0)
+	e.u32(0)
+	e.u16(headerSize + 24)
+	e.u16(uint16(len(token)))
+	e.u64(0)
+	e.bytes(token)
+	return e.b
+}
+
+func decodeSessionSetupResponse(resp []byte) ([]byte, error) {
+	d := &decoder{b: resp, off: headerSize}
+	d.skip(4)
+	off, l := int(d.u16()), int(d.u16())
+	if d.err != nil {
+		return nil, d.err
+	}
+	token, e := slice(resp, off, l)
+	if e != nil {
+		return nil, e
+	}
+	return decodeSPNEGO(token)
+}
+
+// sign computes the SMB 2.x HMAC-SHA256 signature of a message and stores it in its header.
+func sign(key []byte, msg []byte) {
+	copy(msg[48:64], make([]byte, 16))
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	copy(msg[48:64], h.Sum(nil)[:16])
+}
+
+// verify checks the signature of a message.
+func verify(key []byte, msg []byte) bool {
+	if len(msg) < headerSize {
+		return false
+	}
+	sig := make([]byte, 16)
+	copy(sig, msg[48:64])
+	check := make([]byte, len(msg))
+	copy(check, msg)
+	sign(key, check)
+	return hmac.Equal(sig, check[48:64])
+}
+
+// le is a shortcut used by decoders reading fields at fixed positions
+var le = binary.LittleEndian
@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func startTestServer(t *testing.T, signing bool) (*Server, string) {
+	srv := &Server{
+		Fs:             afero.NewMemMapFs(),
+		Share:          "data",
+		User:           "admin",
+		Password:       "P@ssw0rd",
+		Domain:         "CELLS",
+		RequireSigning: signing,
+	}
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	go srv.Serve(l)
+	return srv, l.Addr().String()
+}
+
+func TestNTLM(t *testing.T) {
+
+	Convey("MD4 and NTOWFv2 match reference values", t, func() {
+		So(hex.EncodeToString(md4([]byte("abc"))), ShouldEqual, "a448017aaf21d8525fc10ae87aa6729d")
+		So(hex.EncodeToString(md4(nil)), ShouldEqual, "31d6cfe0d16ae931b73c59d7e0c089c0")
+		// [MS-NLMP] 4.2.4.1.1
+		So(hex.EncodeToString(ntowfv2("User", "Password", "Domain")), ShouldEqual, "0c868a403bfd7a93a3001ef22ef02e3f")
+	})
+
+	Convey("Client and server agree on the session key", t, func() {
+		client := &ntlmClient{user: "admin", password: "secret", domain: "CELLS"}
+		server := &ntlmServer{user: "Admin", password: "secret", domain: "CELLS"}
+		challenge, e := server.challengeMessage(client.negotiateMessage())
+		So(e, ShouldBeNil)
+		auth, e := client.authenticateMessage(challenge)
+		So(e, ShouldBeNil)
+		So(server.authenticate(auth), ShouldBeNil)
+		So(server.sessionKey, ShouldResemble, client.sessionKey)
+
+		wrong := &ntlmClient{user: "admin", password: "wrong", domain: "CELLS"}
+		challenge, _ = server.challengeMessage(wrong.negotiateMessage())
+		auth, _ = wrong.authenticateMessage(challenge)
+		So(server.authenticate(auth), ShouldNotBeNil)
+	})
+
+	Convey("SPNEGO tokens are properly wrapped and unwrapped", t, func() {
+		init, e := encodeNegTokenInit([]byte("NTLMSSP\x00init"))
+		So(e, ShouldBeNil)
+		token, e := decodeSPNEGO(init)
+		So(e, ShouldBeNil)
+		So(string(token), ShouldEqual, "NTLMSSP\x00init")
+		resp, e := encodeNegTokenResp(negStateAcceptIncomplete, []byte("NTLMSSP\x00resp"))
+		So(e, ShouldBeNil)
+		token, e = decodeSPNEGO(resp)
+		So(e, ShouldBeNil)
+		So(string(token), ShouldEqual, "NTLMSSP\x00resp")
+	})
+}
+
+func TestSession(t *testing.T) {
+
+	Convey("Authentication failures are reported", t, func() {
+		_, addr := startTestServer(t, false)
+		d := &Dialer{User: "admin", Password: "wrong"}
+		_, e := d.Dial(addr)
+		So(e, ShouldEqual, StatusLogonFailure)
+	})
+
+	Convey("Unknown shares cannot be mounted", t, func() {
+		_, addr := startTestServer(t, false)
+		d := &Dialer{User: "admin", Password: "P@ssw0rd"}
+		s, e := d.Dial(addr)
+		So(e, ShouldBeNil)
+		defer s.Logoff()
+		_, e = s.Mount("other")
+		So(os.IsNotExist(e), ShouldBeTrue)
+	})
+
+	for _, signing := range []bool{false, true} {
+
+		Convey("Files and folders can be managed on a share", t, func() {
+			srv, addr := startTestServer(t, signing)
+			d := &Dialer{User: "CELLS\\admin", Password: "P@ssw0rd", Timeout: 5 * time.Second}
+			d.User, d.Domain = ParseUser(d.User)
+			s, e := d.Dial(addr)
+			So(e, ShouldBeNil)
+			defer s.Logoff()
+			share, e := s.Mount("data")
+			So(e, ShouldBeNil)
+			defer share.Umount()
+
+			So(share.MkdirAll("/folder/sub", 0755), ShouldBeNil)
+			st, e := share.Stat("folder/sub")
+			So(e, ShouldBeNil)
+			So(st.IsDir(), ShouldBeTrue)
+			So(st.Name(), ShouldEqual, "sub")
+			So(os.IsExist(share.Mkdir("folder", 0755)), ShouldBeTrue)
+
+			// More than one chunk to check splitting of reads and writes
+			content := bytes.Repeat([]byte("0123456789"), 20000)
+			f, e := share.Create("folder/file.bin")
+			So(e, ShouldBeNil)
+			n, e := f.Write(content)
+			So(e, ShouldBeNil)
+			So(n, ShouldEqual, len(content))
+			So(f.Close(), ShouldBeNil)
+
+			stored, _ := afero.ReadFile(srv.Fs, "/folder/file.bin")
+			So(stored, ShouldResemble, content)
+
+			f, e = share.Open("folder/file.bin")
+			So(e, ShouldBeNil)
+			read, e := ioutil.ReadAll(f)
+			So(e, ShouldBeNil)
+			So(read, ShouldResemble, content)
+			pos, e := f.Seek(-10, io.SeekEnd)
+			So(e, ShouldBeNil)
+			So(pos, ShouldEqual, len(content)-10)
+			tail := make([]byte, 20)
+			n, e = f.Read(tail)
+			So(n, ShouldEqual, 10)
+			So(string(tail[:n]), ShouldEqual, "0123456789")
+			fst, e := f.Stat()
+			So(e, ShouldBeNil)
+			So(fst.Size(), ShouldEqual, len(content))
+			So(f.Close(), ShouldBeNil)
+
+			mtime := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+			So(share.Chtimes("folder/file.bin", mtime, mtime), ShouldBeNil)
+			st, _ = share.Stat("folder/file.bin")
+			So(st.ModTime().Equal(mtime), ShouldBeTrue)
+
+			infos, e := share.ReadDir("\\folder")
+			So(e, ShouldBeNil)
+			So(infos, ShouldHaveLength, 2)
+
+			So(share.Rename("folder/file.bin", "folder/sub/renamed.bin"), ShouldBeNil)
+			_, e = share.Stat("folder/file.bin")
+			So(os.IsNotExist(e), ShouldBeTrue)
+			st, e = share.Stat("folder/sub/renamed.bin")
+			So(e, ShouldBeNil)
+			So(st.Size(), ShouldEqual, len(content))
+
+			So(share.Remove("folder/sub"), ShouldNotBeNil)
+			So(share.RemoveAll("folder"), ShouldBeNil)
+			_, e = share.Stat("folder")
+			So(os.IsNotExist(e), ShouldBeTrue)
+			So(share.RemoveAll("folder"), ShouldBeNil)
+		})
+	}
+
+	Convey("Large folders are listed in several batches", t, func() {
+		srv, addr := startTestServer(t, false)
+		for i := 0; i < 1500; i++ {
+			afero.WriteFile(srv.Fs, "/big/file-with-a-rather-long-name-"+time.Duration(i).String(), []byte("x"), 0644)
+		}
+		d := &Dialer{User: "admin", Password: "P@ssw0rd"}
+		s, _ := d.Dial(addr)
+		defer s.Logoff()
+		share, _ := s.Mount("data")
+		fs := NewFs(share)
+		infos, e := afero.ReadDir(fs, "big")
+		So(e, ShouldBeNil)
+		So(infos, ShouldHaveLength, 1500)
+	})
+}
+
+func TestClient(t *testing.T) {
+
+	Convey("Client mounts the share again when the connection is lost", t, func() {
+		srv, addr := startTestServer(t, true)
+		afero.WriteFile(srv.Fs, "/file.txt", []byte("content"), 0644)
+		client := NewClient(addr, "data", "CELLS\\admin", "P@ssw0rd")
+		defer client.Close()
+		fs := NewClientFs(client)
+
+		st, e := fs.Stat("file.txt")
+		So(e, ShouldBeNil)
+		So(st.Size(), ShouldEqual, 7)
+		first, _ := client.Share()
+
+		srv.Disconnect()
+		st, e = fs.Stat("file.txt")
+		So(e, ShouldBeNil)
+		So(st.Size(), ShouldEqual, 7)
+		second, _ := client.Share()
+		So(second, ShouldNotEqual, first)
+
+		So(afero.WriteFile(fs, "other.txt", []byte("other"), 0644), ShouldBeNil)
+		stored, _ := afero.ReadFile(srv.Fs, "/other.txt")
+		So(string(stored), ShouldEqual, "other")
+	})
+
+	Convey("Errors on files are not considered as connection losses", t, func() {
+		_, addr := startTestServer(t, false)
+		client := NewClient(addr, "data", "admin", "P@ssw0rd")
+		defer client.Close()
+		share, e := client.Share()
+		So(e, ShouldBeNil)
+		_, e = NewClientFs(client).Stat("missing")
+		So(os.IsNotExist(e), ShouldBeTrue)
+		So(share.Lost(e), ShouldBeFalse)
+		current, _ := client.Share()
+		So(current, ShouldEqual, share)
+	})
+}
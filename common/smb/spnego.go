@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+)
+
+var (
+	oidSPNEGO  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 2}
+	oidNTLMSSP = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 2, 10}
+)
+
+const (
+	negStateAcceptCompleted  = 0
+	negStateAcceptIncomplete = 1
+	negStateReject           = 2
+)
+
+type negTokenInit struct {
+	MechTypes   []asn1.ObjectIdentifier `asn1:"explicit,optional,tag:0"`
+	ReqFlags    asn1.BitString          `asn1:"explicit,optional,tag:1"`
+	MechToken   []byte                  `asn1:"explicit,optional,tag:2"`
+	MechListMIC []byte                  `asn1:"explicit,optional,tag:3"`
+}
+
+type negTokenResp struct {
+	NegState      asn1.Enumerated       `asn1:"explicit,optional,tag:0"`
+	SupportedMech asn1.ObjectIdentifier `asn1:"explicit,optional,tag:1"`
+	ResponseToken []byte                `asn1:"explicit,optional,tag:2"`
+	MechListMIC   []byte                `asn1:"explicit,optional,tag:3"`
+}
+
+// encodeNegTokenInit wraps an NTLM token inside a GSS-API SPNEGO initial token.
+func encodeNegTokenInit(mechToken []byte) ([]byte, error) {
+	oid, e := asn1.Marshal(oidSPNEGO)
+	if e != nil {
+		return nil, e
+	}
+	init, e := asn1.MarshalWithParams(negTokenInit{
+		MechTypes: []asn1.ObjectIdentifier{oidNTLMSSP},
+		MechToken: mechToken,
+	}, "explicit,tag:0")
+	if e != nil {
+		return nil, e
+	}
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassApplication,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      append(oid, init...),
+	})
+}
+
+// encodeNegTokenResp wraps a token inside a SPNEGO response token.
+func encodeNegTokenResp(state asn1.Enumerated, responseToken []byte) ([]byte, error) {
+	resp := negTokenResp{
+		NegState:      state,
+		ResponseToken: responseToken,
+	}
+	if state == negStateAcceptIncomplete {
+		resp.SupportedMech = oidNTLMSSP
+	}
+	return asn1.MarshalWithParams(resp, "explicit,tag:1")
+}
+
+// decodeSPNEGO extracts the mechanism token from either an initial or a response SPNEGO token.
+// Raw NTLMSSP tokens are returned as is.
+func decodeSPNEGO(token []byte) ([]byte, error) {
+	if bytes.HasPrefix(token, ntlmSignature) {
+		return token, nil
+	}
+	var raw asn1.RawValue
+	if _, e := asn1.Unmarshal(token, &raw); e != nil {
+		return nil, e
+	}
+	switch {
+	case raw.Class == asn1.ClassApplication && raw.Tag == 0:
+		var oid asn1.ObjectIdentifier
+		rest, e := asn1.Unmarshal(raw.Bytes, &oid)
+		if e != nil {
+			return nil, e
+		}
+		if !oid.Equal(oidSPNEGO) {
+			return nil, errors.New("smb: unsupported GSS-API mechanism")
+		}
+		var init negTokenInit
+		if _, e := asn1.UnmarshalWithParams(rest, &init, "explicit,tag:0"); e != nil {
+			return nil, e
+		}
+		return init.MechToken, nil
+	case raw.Class == asn1.ClassContextSpecific && raw.Tag == 1:
+		var resp negTokenResp
+		if _, e := asn1.UnmarshalWithParams(token, &resp, "explicit,tag:1"); e != nil {
+			return nil, e
+		}
+		if resp.NegState == negStateReject {
+			return nil, errNTLMAuth
+		}
+		return resp.ResponseToken, nil
+	}
+	return nil, errors.New("smb: invalid SPNEGO token")
+}
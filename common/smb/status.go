@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package smb
+
+import (
+	"fmt"
+	"os"
+)
+
+// Status is an NTSTATUS code returned by the server.
+type Status uint32
+
+const (
+	StatusSuccess                Status = 0x00000000
+	StatusPending                Status = 0x00000103
+	StatusNoMoreFiles            Status = 0x80000006
+	StatusInvalidParameter       Status = 0xC000000D
+	StatusNoSuchFile             Status = 0xC000000F
+	StatusEndOfFile              Status = 0xC0000011
+	StatusMoreProcessingRequired Status = 0xC0000016
+	StatusAccessDenied           Status = 0xC0000022
+	StatusObjectNameInvalid      Status = 0xC0000033
+	StatusObjectNameNotFound     Status = 0xC0000034
+	StatusObjectNameCollision    Status = 0xC0000035
+	StatusObjectPathNotFound     Status = 0xC000003A
+	StatusSharingViolation       Status = 0xC0000043
+	StatusLogonFailure           Status = 0xC000006D
+	StatusFileIsADirectory       Status = 0xC00000BA
+	StatusNotSupported           Status = 0xC00000BB
+	StatusBadNetworkName         Status = 0xC00000CC
+	StatusDirectoryNotEmpty      Status = 0xC0000101
+	StatusNotADirectory          Status = 0xC0000103
+	StatusFileClosed             Status = 0xC0000128
+	StatusUserSessionDeleted     Status = 0xC0000203
+	StatusNetworkNameDeleted     Status = 0xC00000C9
+	StatusInvalidHandle          Status = 0xC0000008
+	StatusNetworkSessionExpired  Status = 0xC000035C
+	StatusRequestNotAccepted     Status = 0xC00000D0
+	StatusInsufficientResources  Status = 0xC000009A
+	StatusInternalError          Status = 0xC00000E5
+)
+
+var statusNames = map[Status]string{
+	StatusSuccess:                "STATUS_SUCCESS",
+	StatusPending:                "STATUS_PENDING",
+	StatusNoMoreFiles:            "STATUS_NO_MORE_FILES",
+	StatusInvalidParameter:       "STATUS_INVALID_PARAMETER",
+	StatusNoSuchFile:             "STATUS_NO_SUCH_FILE",
+	StatusEndOfFile:              "STATUS_END_OF_FILE",
+	StatusMoreProcessingRequired: "STATUS_MORE_PROCESSING_REQUIRED",
+	StatusAccessDenied:           "STATUS_ACCESS_DENIED",
+	StatusObjectNameInvalid:      "STATUS_OBJECT_NAME_INVALID",
+	StatusObjectNameNotFound:     "STATUS_OBJECT_NAME_NOT_FOUND",
+	StatusObjectNameCollision:    "STATUS_OBJECT_NAME_COLLISION",
+	StatusObjectPathNotFound:     "STATUS_OBJECT_PATH_NOT_FOUND",
+	StatusSharingViolation:       "STATUS_SHARING_VIOLATION",
+	StatusLogonFailure:           "STATUS_LOGON_FAILURE",
+	StatusFileIsADirectory:       "STATUS_FILE_IS_A_DIRECTORY",
+	StatusNotSupported:           "STATUS_NOT_SUPPORTED",
+	StatusBadNetworkName:         "STATUS_BAD_NETWORK_NAME",
+	StatusDirectoryNotEmpty:      "STATUS_DIRECTORY_NOT_EMPTY",
+	StatusNotADirectory:          "STATUS_NOT_A_DIRECTORY",
+	StatusFileClosed:             "STATUS_FILE_CLOSED",
+	StatusUserSessionDeleted:     "STATUS_USER_SESSION_DELETED",
+	StatusNetworkNameDeleted:     "STATUS_NETWORK_NAME_DELETED",
+	StatusInvalidHandle:          "STATUS_INVALID_HANDLE",
+	StatusNetworkSessionExpired:  "STATUS_NETWORK_SESSION_EXPIRED",
+	StatusRequestNotAccepted:     "STATUS_REQUEST_NOT_ACCEPTED",
+	StatusInsufficientResources:  "STATUS_INSUFFICIENT_RESOURCES",
+	StatusInternalError:          "STATUS_INTERNAL_ERROR",
+}
+
+// Error implements the error interface
+func (s Status) Error() string {
+	if n, ok := statusNames[s]; ok {
+		return "smb: " + n
+	}
+	return fmt.Sprintf("smb: status 0x%08X", uint32(s))
+}
+
+// pathError wraps a server status into an *os.PathError.
+func pathError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: name, Err: osError(err)}
+}
+
+// osError translates the most common statuses to the os package errors,
+// so that os.IsNotExist & co. can be used by callers.
+func osError(err error) error {
+	if s, ok := err.(Status); ok {
+		switch s {
+		case StatusObjectNameNotFound, StatusObjectPathNotFound, StatusNoSuchFile, StatusBadNetworkName:
+			return os.ErrNotExist
+		case StatusObjectNameCollision:
+			return os.ErrExist
+		case StatusAccessDenied:
+			return os.ErrPermission
+		}
+	}
+	return err
+}
+
+// statusFromError is the reverse operation, used by the server side.
+func statusFromError(err error) Status {
+	if err == nil {
+		return StatusSuccess
+	}
+	if s, ok := err.(Status); ok {
+		return s
+	}
+	switch {
+	case os.IsNotExist(err):
+		return StatusObjectNameNotFound
+	case os.IsExist(err):
+		return StatusObjectNameCollision
+	case os.IsPermission(err):
+		return StatusAccessDenied
+	}
+	return StatusInternalError
+}
@@ -36,6 +36,10 @@ var (
 	ErrNoRows = sql.ErrNoRows
 )
 
+// DriverQueries holds the variants of a query that cannot be written the same way for all drivers,
+// keyed by driver name. The query stored with an empty key is used for the other drivers.
+type DriverQueries map[string]string
+
 // DAO interface definition
 type DAO interface {
 	dao.DAO
@@ -95,8 +99,14 @@ func (h *Handler) Prepare(key string, query interface{}) error {
 		h.ifuncs[key] = v
 	case func(...string) string:
 		h.funcs[key] = v
+	case DriverQueries:
+		q, ok := v[h.Driver()]
+		if !ok {
+			q = v[""]
+		}
+		return h.Prepare(key, q)
 	case string:
-		v = h.replacer.Replace(v)
+		v = h.rebind(h.replacer.Replace(v))
 		stmt, err := h.DB().Prepare(v)
 		if err != nil {
 			return fmt.Errorf("Preparing statement : %v, %v", query, err)
@@ -116,7 +126,7 @@ func (h *Handler) GetStmt(key string, args ...interface{}) *sql.Stmt {
 	if v, ok := h.ifuncs[key]; ok {
 
 		query := v(args...)
-		query = h.replacer.Replace(query)
+		query = h.rebind(h.replacer.Replace(query))
 
 		stmt, err := h.DB().Prepare(query)
 		if err != nil {
@@ -131,7 +141,7 @@ func (h *Handler) GetStmt(key string, args ...interface{}) *sql.Stmt {
 			sargs = append(sargs, fmt.Sprintf("%v", s))
 		}
 		query := v(sargs...)
-		query = h.replacer.Replace(query)
+		query = h.rebind(h.replacer.Replace(query))
 
 		stmt, err := h.DB().Prepare(query)
 		if err != nil {
@@ -143,6 +153,41 @@ func (h *Handler) GetStmt(key string, args ...interface{}) *sql.Stmt {
 	return nil
 }
 
+// rebind converts the "?" bind variables used by the DAOs queries to the
+// positional "$n" form expected by the postgres driver
+func (h *Handler) rebind(query string) string {
+	if h.Driver() != "postgres" {
+		return query
+	}
+	return Rebind(query)
+}
+
+// Rebind replaces each "?" bind variable of a query by its "$n" positional equivalent,
+// leaving quoted strings and identifiers untouched
+func Rebind(query string) string {
+	var quote rune
+	var n int
+
+	out := &strings.Builder{}
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			fmt.Fprintf(out, "$%d", n)
+			continue
+		}
+		out.WriteRune(c)
+	}
+
+	return out.String()
+}
+
 func (h *Handler) UseExclusion() {
 }
 
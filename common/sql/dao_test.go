@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package sql
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRebind(t *testing.T) {
+
+	Convey("Bind variables are numbered", t, func() {
+		So(Rebind(`select * from t where a = ? and b = ?`), ShouldEqual, `select * from t where a = $1 and b = $2`)
+		So(Rebind(`insert into t (a, b) values (?, ?), (?, ?)`), ShouldEqual, `insert into t (a, b) values ($1, $2), ($3, $4)`)
+	})
+
+	Convey("Quoted question marks are left untouched", t, func() {
+		So(Rebind(`select '?' from t where a = ?`), ShouldEqual, `select '?' from t where a = $1`)
+		So(Rebind(`select "?" from t where a LIKE '%?%' and b = ?`), ShouldEqual, `select "?" from t where a LIKE '%?%' and b = $1`)
+	})
+
+	Convey("Queries without bind variables are unchanged", t, func() {
+		So(Rebind(`select count(uuid) from t`), ShouldEqual, `select count(uuid) from t`)
+	})
+
+}
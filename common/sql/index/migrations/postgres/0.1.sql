@@ -0,0 +1,51 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS %%PREFIX%%_tree (
+    uuid   VARCHAR(128)      NOT NULL,
+    level  SMALLINT          NOT NULL,
+
+    hash   BIGINT            NOT NULL,
+    mpath1 VARCHAR(255)      NOT NULL,
+    mpath2 VARCHAR(255)      NOT NULL,
+    mpath3 VARCHAR(255)      NOT NULL,
+    mpath4 VARCHAR(255)      NOT NULL,
+
+    rat    BYTEA             NOT NULL,
+
+    CONSTRAINT %%PREFIX%%_tree_pk PRIMARY KEY (uuid),
+    CONSTRAINT %%PREFIX%%_tree_u1 UNIQUE (hash)
+);
+
+CREATE INDEX IF NOT EXISTS %%PREFIX%%_tree_mpath1_idx ON %%PREFIX%%_tree (mpath1);
+CREATE INDEX IF NOT EXISTS %%PREFIX%%_tree_mpath2_idx ON %%PREFIX%%_tree (mpath2);
+CREATE INDEX IF NOT EXISTS %%PREFIX%%_tree_mpath3_idx ON %%PREFIX%%_tree (mpath3);
+CREATE INDEX IF NOT EXISTS %%PREFIX%%_tree_mpath4_idx ON %%PREFIX%%_tree (mpath4);
+
+CREATE TABLE IF NOT EXISTS %%PREFIX%%_nodes (
+    uuid     VARCHAR(128) NOT NULL,
+    name     VARCHAR(255) COLLATE "C" NOT NULL,
+    leaf     SMALLINT,
+    mtime    INT NOT NULL,
+    etag     VARCHAR(255),
+    size     BIGINT,
+    mode     VARCHAR(10),
+
+    CONSTRAINT %%PREFIX%%_nodes_pk PRIMARY KEY (uuid)
+);
+
+CREATE TABLE IF NOT EXISTS %%PREFIX%%_commits (
+    id       BIGSERIAL,
+    uuid     VARCHAR(128) NOT NULL,
+    etag     VARCHAR(255) NOT NULL,
+    mtime    INT NOT NULL,
+    size     BIGINT,
+    data     BYTEA NULL,
+
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS %%PREFIX%%_commits_uuid_idx ON %%PREFIX%%_commits (uuid);
+
+-- +migrate Down
+DROP TABLE %%PREFIX%%_tree;
+DROP TABLE %%PREFIX%%_nodes;
+DROP TABLE %%PREFIX%%_commits;
@@ -31,7 +31,7 @@ func TestSQL(t *testing.T) {
 		mpath := utils.NewMPath(100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 10000, 123456789, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000)
 
 		str := getMPathEquals("", []byte(mpath.String()))
-		So(str, ShouldEqual, `mpath2 LIKE "89.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000" and mpath1 LIKE "100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.10000.1234567"`)
+		So(str, ShouldEqual, `mpath2 LIKE '89.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000' and mpath1 LIKE '100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.10000.1234567'`)
 	})
 
 	Convey("Test getting a mpath like", t, func() {
@@ -39,12 +39,12 @@ func TestSQL(t *testing.T) {
 
 		str := getMPathLike("", []byte(mpath.String()))
 
-		So(str, ShouldEqual, `mpath2 LIKE "100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.%" and mpath1 LIKE "100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000."`)
+		So(str, ShouldEqual, `mpath2 LIKE '100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.%' and mpath1 LIKE '100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.'`)
 	})
 
 	Convey("Test getting mpathes in ", t, func() {
 		str := getMPathesIn("", "1", "1.1", "1.1.2", utils.NewMPath(100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000, 100000000000).String())
 
-		So(str, ShouldEqual, `(mpath1 LIKE "1") or (mpath1 LIKE "1.1") or (mpath1 LIKE "1.1.2") or (mpath2 LIKE "100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000" and mpath1 LIKE "100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.")`)
+		So(str, ShouldEqual, `(mpath1 LIKE '1') or (mpath1 LIKE '1.1') or (mpath1 LIKE '1.1.2') or (mpath2 LIKE '100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000' and mpath1 LIKE '100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.100000000000.')`)
 	})
 }
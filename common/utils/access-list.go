@@ -72,6 +72,7 @@ var (
 	ACL_DENY         = &idm.ACLAction{Name: "deny", Value: "1"}
 	ACL_POLICY       = &idm.ACLAction{Name: "policy"}
 	ACL_QUOTA        = &idm.ACLAction{Name: "quota"}
+	ACL_USER_QUOTA   = &idm.ACLAction{Name: "user_quota"}
 	ACL_CONTENT_LOCK = &idm.ACLAction{Name: "content_lock"}
 	// Not used yet
 	ACL_DELETE           = &idm.ACLAction{Name: "delete", Value: "1"}
@@ -91,6 +92,7 @@ func (f Bitmask) HasFlag(ctx context.Context, flag BitmaskFlag, ctxNode ...*tree
 		// We should first resolve the policy, given the ctx and the node
 		policyContext := make(map[string]string)
 		PolicyContextFromMetadata(policyContext, ctx)
+		PolicyContextFromClaims(policyContext, ctx)
 		if len(ctxNode) > 0 {
 			PolicyContextFromNode(policyContext, ctxNode[0])
 		}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ContentLock is the decoded value of an ACL_CONTENT_LOCK action. Locks set from the web interface
+// only carry the owner login, whereas locks set by external editors (WOPI, WebDAV) also carry
+// the lock token provided by the client and an expiration date. Details may store
+// additional client-specific data, like the depth of a WebDAV lock. Path is the path of the locked
+// node when the lock was set: as locked nodes cannot be moved, it allows finding locks below a folder.
+// Recursive locks also apply to all the nodes below the locked folder.
+type ContentLock struct {
+	Owner     string `json:"owner"`
+	Token     string `json:"token,omitempty"`
+	Expires   int64  `json:"expires,omitempty"`
+	Details   string `json:"details,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+// ParseContentLock decodes the value stored in an ACL_CONTENT_LOCK action.
+// Legacy values containing a simple user login are supported.
+func ParseContentLock(value string) *ContentLock {
+	lock := &ContentLock{}
+	if strings.HasPrefix(value, "{") {
+		if e := json.Unmarshal([]byte(value), lock); e == nil {
+			return lock
+		}
+	}
+	lock.Owner = value
+	return lock
+}
+
+// Value encodes the lock to be stored as an ACL_CONTENT_LOCK action value. Locks without
+// token nor expiration are stored as a simple login for compatibility.
+func (l *ContentLock) Value() string {
+	if l.Token == "" && l.Expires == 0 && l.Details == "" && l.Path == "" && !l.Recursive {
+		return l.Owner
+	}
+	data, _ := json.Marshal(l)
+	return string(data)
+}
+
+// IsExpired checks if an expiration date is set and is passed.
+func (l *ContentLock) IsExpired() bool {
+	return l.Expires > 0 && time.Now().Unix() > l.Expires
+}
+
+// Covers checks if the lock applies to the node at index path @nodePath: either the locked
+// node itself or, for recursive locks, a node below it.
+func (l *ContentLock) Covers(nodePath string) bool {
+	if l.Path == "" || nodePath == "" {
+		return false
+	}
+	lockPath := strings.TrimSuffix(l.Path, "/")
+	nodePath = strings.TrimSuffix(nodePath, "/")
+	if nodePath == lockPath {
+		return true
+	}
+	return l.Recursive && strings.HasPrefix(nodePath, lockPath+"/")
+}
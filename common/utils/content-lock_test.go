@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContentLock(t *testing.T) {
+
+	Convey("Test legacy lock value", t, func() {
+		lock := ParseContentLock("admin")
+		So(lock.Owner, ShouldEqual, "admin")
+		So(lock.Token, ShouldBeEmpty)
+		So(lock.IsExpired(), ShouldBeFalse)
+		So(lock.Value(), ShouldEqual, "admin")
+	})
+
+	Convey("Test token lock value", t, func() {
+		lock := &ContentLock{Owner: "admin", Token: "lock-id", Expires: time.Now().Add(time.Minute).Unix()}
+		parsed := ParseContentLock(lock.Value())
+		So(parsed, ShouldResemble, lock)
+		So(parsed.IsExpired(), ShouldBeFalse)
+
+		parsed.Expires = time.Now().Add(-time.Minute).Unix()
+		So(parsed.IsExpired(), ShouldBeTrue)
+	})
+
+	Convey("Test lock with path", t, func() {
+		lock := &ContentLock{Owner: "admin", Path: "pydiods1/folder/file.docx"}
+		So(lock.Value(), ShouldNotEqual, "admin")
+		parsed := ParseContentLock(lock.Value())
+		So(parsed, ShouldResemble, lock)
+		So(parsed.Value(), ShouldEqual, lock.Value())
+	})
+
+	Convey("Test lock coverage", t, func() {
+		lock := &ContentLock{Owner: "admin", Path: "pydiods1/folder"}
+		So(lock.Covers("pydiods1/folder"), ShouldBeTrue)
+		So(lock.Covers("pydiods1/folder/file.docx"), ShouldBeFalse)
+		So(lock.Covers("pydiods1/folder2"), ShouldBeFalse)
+
+		lock.Recursive = true
+		So(ParseContentLock(lock.Value()).Recursive, ShouldBeTrue)
+		So(lock.Covers("pydiods1/folder/file.docx"), ShouldBeTrue)
+		So(lock.Covers("pydiods1/folder/sub/file.docx"), ShouldBeTrue)
+		So(lock.Covers("pydiods1/folder2/file.docx"), ShouldBeFalse)
+		So(lock.Covers("pydiods1"), ShouldBeFalse)
+
+		So(ParseContentLock("admin").Covers("pydiods1/folder"), ShouldBeFalse)
+	})
+
+}
@@ -23,6 +23,8 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -138,10 +140,33 @@ func ValidateDataSourceConfig(newSource *object.DataSource) error {
 		} else {
 			os.Remove(touch)
 		}
+	} else if newSource.StorageType == object.StorageType_SMB {
+		if newSource.StorageConfiguration["smbServer"] == "" || newSource.StorageConfiguration["smbShare"] == "" {
+			return fmt.Errorf("Please provide both the SMB server address and the share name")
+		}
+		if strings.Trim(newSource.StorageConfiguration["folder"], "/") == "" {
+			return fmt.Errorf("Please provide a folder inside the share")
+		}
+		if newSource.ApiKey == "" {
+			return fmt.Errorf("Please provide a user to connect to the SMB share")
+		}
 	}
 	return nil
 }
 
+// SmbEndpointUrl builds the smb://server/share/base url used by an SMB MinioConfig
+func SmbEndpointUrl(server string, share string, base string) string {
+	u := &url.URL{
+		Scheme: "smb",
+		Host:   server,
+		Path:   "/" + strings.Trim(share, "/"),
+	}
+	if base = strings.Trim(base, "/"); base != "" {
+		u.Path += "/" + base
+	}
+	return u.String()
+}
+
 // FactorizeMinioServers tries to find exisiting MinioConfig that can be directly reused by the new source, or creates a new one
 func FactorizeMinioServers(existingConfigs map[string]*object.MinioConfig, newSource *object.DataSource) (config *object.MinioConfig) {
 
@@ -160,6 +185,24 @@ func FactorizeMinioServers(existingConfigs map[string]*object.MinioConfig, newSo
 				EndpointUrl: newSource.StorageConfiguration["customEndpoint"],
 			}
 		}
+	} else if newSource.StorageType == object.StorageType_SMB {
+		base, bucket := path.Split(strings.Trim(newSource.StorageConfiguration["folder"], "/"))
+		endpointUrl := SmbEndpointUrl(newSource.StorageConfiguration["smbServer"], newSource.StorageConfiguration["smbShare"], base)
+		if gateway := filterGatewaysWithKeys(existingConfigs, newSource.StorageType, newSource.ApiKey, endpointUrl); gateway != nil {
+			config = gateway
+			newSource.ApiKey = config.ApiKey
+			newSource.ApiSecret = config.ApiSecret
+		} else {
+			config = &object.MinioConfig{
+				Name:        createConfigName(existingConfigs, object.StorageType_SMB),
+				StorageType: object.StorageType_SMB,
+				ApiKey:      newSource.ApiKey,
+				ApiSecret:   newSource.ApiSecret,
+				RunningPort: newSource.ObjectsPort,
+				EndpointUrl: endpointUrl,
+			}
+		}
+		newSource.ObjectsBucket = bucket
 	} else {
 		base, bucket := filepath.Split(newSource.StorageConfiguration["folder"])
 		peerAddress := newSource.PeerAddress
@@ -190,11 +233,13 @@ func FactorizeMinioServers(existingConfigs map[string]*object.MinioConfig, newSo
 	return config
 }
 
-// createConfigName creates a new name for a minio config (local or gateway suffixed with an index)
+// createConfigName creates a new name for a minio config (local, gateway or smb suffixed with an index)
 func createConfigName(existingConfigs map[string]*object.MinioConfig, storageType object.StorageType) string {
 	base := "local"
 	if storageType == object.StorageType_S3 {
 		base = "gateway"
+	} else if storageType == object.StorageType_SMB {
+		base = "smb"
 	}
 	index := 1
 	label := fmt.Sprintf("%s%d", base, index)
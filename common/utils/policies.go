@@ -23,6 +23,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/micro/go-micro/metadata"
@@ -41,6 +42,12 @@ const (
 	PolicyNodeMetaSize      = "NodeMetaSize"
 	PolicyNodeMetaMTime     = "NodeMetaMTime"
 	PolicyNodeMeta_         = "NodeMeta:"
+	PolicyUserLogin         = "UserLogin"
+	PolicyUserProfile       = "UserProfile"
+	PolicyUserGroupPath     = "UserGroupPath"
+	PolicyUserRoles         = "UserRoles"
+	PolicyUserAttribute_    = "UserAttribute:"
+	PolicyJwtSecondFactor   = "JwtSecondFactor"
 )
 
 /* Helper methods to ease management of Ladon policies */
@@ -96,6 +103,42 @@ func PolicyContextFromMetadata(policyContext map[string]string, ctx context.Cont
 	}
 }
 
+// PolicyContextFromClaims extracts the user information found in the context claims and enriches the passed policyContext.
+func PolicyContextFromClaims(policyContext map[string]string, ctx context.Context) {
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok {
+		return
+	}
+	policyContext[PolicyUserLogin] = claims.Name
+	policyContext[PolicyUserProfile] = claims.Profile
+	policyContext[PolicyUserGroupPath] = claims.GroupPath
+	policyContext[PolicyUserRoles] = claims.Roles
+	policyContext[PolicyJwtSecondFactor] = strconv.FormatBool(claims.HasSecondFactor())
+}
+
+// PolicyContextFromUser adds the attributes and roles of the passed User to the policyContext,
+// without overriding the keys that are already set.
+func PolicyContextFromUser(policyContext map[string]string, user *idm.User) {
+	setIfEmpty := func(key, value string) {
+		if _, ok := policyContext[key]; !ok {
+			policyContext[key] = value
+		}
+	}
+	setIfEmpty(PolicyUserLogin, user.Login)
+	setIfEmpty(PolicyUserGroupPath, user.GroupPath)
+	if prof, ok := user.Attributes["profile"]; ok {
+		setIfEmpty(PolicyUserProfile, prof)
+	}
+	var roles []string
+	for _, r := range user.Roles {
+		roles = append(roles, r.Uuid)
+	}
+	setIfEmpty(PolicyUserRoles, strings.Join(roles, ","))
+	for k, v := range user.Attributes {
+		setIfEmpty(PolicyUserAttribute_+k, v)
+	}
+}
+
 // PolicyContextFromNode extracts metadata from the Node and enriches the passed policyContext.
 func PolicyContextFromNode(policyContext map[string]string, node *tree.Node) {
 	// TODO: add file extension
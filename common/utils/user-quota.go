@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package utils
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pydio/cells/common/proto/idm"
+)
+
+// UserQuotaScope is the workspace ID used by ACL_USER_QUOTA actions: a user quota applies to all the
+// data owned by the user, whatever the workspace or cell it is stored in.
+const UserQuotaScope = "PYDIO_REPO_SCOPE_ALL"
+
+// GetUserQuota loads the storage quota (in bytes) that applies to a user having the given ordered roles.
+// It returns 0 if no quota is defined.
+func GetUserQuota(ctx context.Context, orderedRoles []string) (int64, error) {
+	var roles []*idm.Role
+	for _, r := range orderedRoles {
+		if r != "" {
+			roles = append(roles, &idm.Role{Uuid: r})
+		}
+	}
+	return ResolveUserQuota(GetACLsForRoles(ctx, roles, ACL_USER_QUOTA), orderedRoles)
+}
+
+// ResolveUserQuota finds the quota value among a list of ACL_USER_QUOTA ACLs. Roles are applied in order,
+// so that users inherit the quota of their groups unless it is overridden by a more specific role.
+func ResolveUserQuota(acls []*idm.ACL, orderedRoles []string) (int64, error) {
+	roleValues := make(map[string]string)
+	for _, acl := range acls {
+		if acl.Action == nil || acl.Action.Name != ACL_USER_QUOTA.Name || acl.WorkspaceID != UserQuotaScope {
+			continue
+		}
+		if acl.Action.Value != "" {
+			roleValues[acl.RoleID] = acl.Action.Value
+		}
+	}
+	var quota int64
+	for _, r := range orderedRoles {
+		if val, ok := roleValues[r]; ok {
+			intVal, e := strconv.ParseInt(val, 10, 64)
+			if e != nil {
+				return 0, e
+			}
+			quota = intVal
+		}
+	}
+	return quota, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/idm"
+)
+
+func TestResolveUserQuota(t *testing.T) {
+
+	acls := []*idm.ACL{
+		{RoleID: "ROOT_GROUP", WorkspaceID: UserQuotaScope, Action: &idm.ACLAction{Name: "user_quota", Value: "1000"}},
+		{RoleID: "sub-group", WorkspaceID: UserQuotaScope, Action: &idm.ACLAction{Name: "user_quota", Value: "2000"}},
+		{RoleID: "user-role", WorkspaceID: "other-workspace", Action: &idm.ACLAction{Name: "user_quota", Value: "10"}},
+		{RoleID: "user-role", WorkspaceID: UserQuotaScope, Action: &idm.ACLAction{Name: "quota", Value: "20"}},
+	}
+
+	Convey("Test no quota", t, func() {
+		q, e := ResolveUserQuota(acls, []string{"other-role"})
+		So(e, ShouldBeNil)
+		So(q, ShouldEqual, 0)
+	})
+
+	Convey("Test quota inherited from groups", t, func() {
+		q, e := ResolveUserQuota(acls, []string{"ROOT_GROUP", "user-role"})
+		So(e, ShouldBeNil)
+		So(q, ShouldEqual, 1000)
+
+		q, e = ResolveUserQuota(acls, []string{"ROOT_GROUP", "sub-group", "user-role"})
+		So(e, ShouldBeNil)
+		So(q, ShouldEqual, 2000)
+	})
+
+	Convey("Test user role overrides groups", t, func() {
+		userAcls := append(acls, &idm.ACL{RoleID: "user-role", WorkspaceID: UserQuotaScope, Action: &idm.ACLAction{Name: "user_quota", Value: "500"}})
+		q, e := ResolveUserQuota(userAcls, []string{"ROOT_GROUP", "sub-group", "user-role"})
+		So(e, ShouldBeNil)
+		So(q, ShouldEqual, 500)
+	})
+
+	Convey("Test wrong value", t, func() {
+		_, e := ResolveUserQuota([]*idm.ACL{{RoleID: "r", WorkspaceID: UserQuotaScope, Action: &idm.ACLAction{Name: "user_quota", Value: "abc"}}}, []string{"r"})
+		So(e, ShouldNotBeNil)
+	})
+
+}
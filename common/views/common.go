@@ -63,6 +63,7 @@ type (
 	ctxUserAccessListKey struct{}
 	ctxAdminContextKey   struct{}
 	ctxBranchInfoKey     struct{}
+	ctxLockTokenKey      struct{}
 
 	LoadedSource struct {
 		object.DataSource
@@ -99,6 +100,8 @@ type (
 		srcEncryptionMaterial  encrypt.Materials
 		destEncryptionMaterial encrypt.Materials
 		SrcVersionId           string
+		// Progress is notified of the copied bytes, the same way minio-go progress hooks are.
+		Progress io.Reader
 	}
 
 	MultipartRequestData struct {
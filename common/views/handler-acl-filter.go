@@ -83,7 +83,9 @@ func (a *AclFilterHandler) ListNodes(ctx context.Context, in *tree.ListNodesRequ
 		return nil, err
 	}
 
-	if !accessList.CanRead(ctx, parents...) {
+	// In file-drop mode, write-only users can list folders: the FileDropFilter restricts the results
+	fileDrop := isFileDropContext(ctx) && accessList.CanWrite(ctx, parents...)
+	if !accessList.CanRead(ctx, parents...) && !fileDrop {
 		return nil, errors.Forbidden(VIEWS_LIBRARY_NAME, "Node is not readable")
 	}
 	log.Logger(ctx).Debug("Parent Ancestors", zap.Any("parents", parents), zap.Any("acl", accessList))
@@ -107,7 +109,7 @@ func (a *AclFilterHandler) ListNodes(ctx context.Context, in *tree.ListNodesRequ
 			// FILTER OUT NON READABLE NODES
 			newBranch := []*tree.Node{resp.Node}
 			newBranch = append(newBranch, parents...)
-			if !accessList.CanRead(ctx, newBranch...) {
+			if !accessList.CanRead(ctx, newBranch...) && !fileDrop {
 				continue
 			}
 			if accessList.CanRead(ctx, newBranch...) && !accessList.CanWrite(ctx, newBranch...) {
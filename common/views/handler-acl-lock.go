@@ -22,10 +22,15 @@ package views
 
 import (
 	"context"
+	"encoding/json"
 	"io"
+	"net/http"
+	"path"
+	"strings"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
+	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/errors"
 	"go.uber.org/zap"
 
@@ -43,44 +48,283 @@ type AclLockFilter struct {
 	AbstractHandler
 }
 
-// checkLock finds if there is a global lock registered in ACLs.
-func (a *AclLockFilter) checkLock(ctx context.Context, node *tree.Node) error {
-	if node.Uuid == "" {
-		return nil
+// WithContentLockToken stores in context the lock tokens provided by a client (e.g. an external editor).
+// Writes carrying the token of the current lock are allowed, whoever owns the lock.
+func WithContentLockToken(ctx context.Context, tokens ...string) context.Context {
+	return context.WithValue(ctx, ctxLockTokenKey{}, tokens)
+}
+
+// GetContentLock finds the global lock registered in ACLs for this node Uuid, if any.
+// Expired locks are ignored.
+func GetContentLock(ctx context.Context, nodeUuid string) (*utils.ContentLock, error) {
+	locks, err := ListContentLocks(ctx, nodeUuid)
+	if err != nil {
+		return nil, err
 	}
-	var userName string
-	if claims, ok := ctx.Value(claim.ContextKey).(claim.Claims); ok {
-		userName = claims.Name
+	return locks[nodeUuid], nil
+}
+
+// ListContentLocks finds the global locks registered in ACLs for these nodes Uuids, or all
+// registered locks if no Uuid is passed. Locks are returned by node Uuid, expired locks are ignored.
+func ListContentLocks(ctx context.Context, nodeUuids ...string) (map[string]*utils.ContentLock, error) {
+	acls, err := searchContentLocks(ctx, &idm.ACLSingleQuery{NodeIDs: nodeUuids})
+	if err != nil {
+		return nil, err
 	}
+	return parseContentLocks(acls), nil
+}
 
+// ListContentLocksBelow finds the global locks registered on nodes below the folder at index path @folderPath.
+// Locks store the path of their node and are searched by path prefix. Legacy locks without path are
+// matched against a single listing of the folder, only if there are any.
+func ListContentLocksBelow(ctx context.Context, folderPath string) (map[string]*utils.ContentLock, error) {
+	prefix := strings.TrimSuffix(folderPath, "/") + "/"
+	// Search the JSON-encoded path, candidates are then checked on the decoded value
+	encoded, _ := json.Marshal(prefix)
+	pathValue := `*"path":` + strings.TrimSuffix(string(encoded), `"`) + "*"
+	acls, err := searchContentLocks(ctx, &idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: utils.ACL_CONTENT_LOCK.Name, Value: pathValue}}})
+	if err != nil {
+		return nil, err
+	}
+	below := make(map[string]*utils.ContentLock)
+	for nodeUuid, lock := range parseContentLocks(acls) {
+		if strings.HasPrefix(lock.Path, prefix) {
+			below[nodeUuid] = lock
+		}
+	}
+	legacyAcls, err := searchContentLocks(ctx, &idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: utils.ACL_CONTENT_LOCK.Name, Value: "{*"}}, Not: true})
+	if err != nil {
+		return nil, err
+	}
+	legacy := parseContentLocks(legacyAcls)
+	if len(legacy) == 0 {
+		return below, nil
+	}
+	treeClient := tree.NewNodeProviderClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, defaults.NewClient())
+	stream, err := treeClient.ListNodes(ctx, &tree.ListNodesRequest{Node: &tree.Node{Path: folderPath}, Recursive: true})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	for {
+		rsp, e := stream.Recv()
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
+			break
+		} else if e != nil {
+			return nil, e
+		}
+		if rsp == nil {
+			continue
+		}
+		if lock, ok := legacy[rsp.Node.Uuid]; ok && strings.HasPrefix(rsp.Node.Path, prefix) {
+			below[rsp.Node.Uuid] = lock
+		}
+	}
+	return below, nil
+}
+
+// parseContentLocks decodes the locks stored in these ACLs, ignoring expired ones.
+func parseContentLocks(acls map[string]*idm.ACL) map[string]*utils.ContentLock {
+	locks := make(map[string]*utils.ContentLock)
+	for nodeUuid, acl := range acls {
+		if lock := utils.ParseContentLock(acl.Action.Value); !lock.IsExpired() {
+			locks[nodeUuid] = lock
+		}
+	}
+	return locks
+}
+
+// searchContentLocks loads the ACLs storing the locks matching @singleQ, including expired ones.
+// All matching ACLs are loaded, whatever their number.
+func searchContentLocks(ctx context.Context, singleQ *idm.ACLSingleQuery) (map[string]*idm.ACL, error) {
 	aclClient := idm.NewACLServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACL, defaults.NewClient())
-	// Look for "quota" ACLs on this node
-	singleQ := &idm.ACLSingleQuery{NodeIDs: []string{node.Uuid}, Actions: []*idm.ACLAction{{Name: utils.ACL_CONTENT_LOCK.Name}}}
+	if len(singleQ.Actions) == 0 {
+		singleQ.Actions = []*idm.ACLAction{{Name: utils.ACL_CONTENT_LOCK.Name}}
+	}
+	singleQ.IncludeInactive = true
 	log.Logger(ctx).Debug("SEARCHING FOR LOCKS IN ACLS", zap.Any("q", singleQ))
 	q, _ := ptypes.MarshalAny(singleQ)
-	stream, err := aclClient.SearchACL(ctx, &idm.SearchACLRequest{Query: &service.Query{SubQueries: []*any.Any{q}}})
+	stream, err := aclClient.SearchACL(ctx, &idm.SearchACLRequest{Query: &service.Query{SubQueries: []*any.Any{q}, Limit: -1}})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer stream.Close()
+	acls := make(map[string]*idm.ACL)
 	for {
 		rsp, e := stream.Recv()
-		if e != nil {
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
 			break
+		} else if e != nil {
+			return nil, e
 		}
 		if rsp == nil {
 			continue
 		}
-		acl := rsp.ACL
-		log.Logger(ctx).Debug("FOUND LOCK", acl.Zap())
-		if userName == "" || acl.Action.Value != userName {
-			return errors.Forbidden(VIEWS_LIBRARY_NAME, "This file is locked by another user")
+		log.Logger(ctx).Debug("FOUND LOCK", rsp.ACL.Zap())
+		acls[rsp.ACL.NodeID] = rsp.ACL
+	}
+	return acls, nil
+}
+
+// SetContentLock registers a global lock on this node Uuid with a single conditional ACL write. An existing
+// lock is only replaced if it has expired or if @replace accepts it. Concurrent requests thus cannot both
+// acquire the lock: the loser receives a Conflict error. The ACL expires with the lock, so that expired
+// locks are purged by the job cleaning expired ACLs.
+func SetContentLock(ctx context.Context, nodeUuid string, lock *utils.ContentLock, replace func(current *utils.ContentLock) bool) error {
+	acls, err := searchContentLocks(ctx, &idm.ACLSingleQuery{NodeIDs: []string{nodeUuid}})
+	if err != nil {
+		return err
+	}
+	if lock.Path == "" {
+		treeClient := tree.NewNodeProviderClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, defaults.NewClient())
+		if rsp, e := treeClient.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Uuid: nodeUuid}}); e == nil {
+			lock.Path = rsp.Node.Path
+		}
+	}
+	req := &idm.CreateACLRequest{ACL: &idm.ACL{
+		NodeID:    nodeUuid,
+		Action:    &idm.ACLAction{Name: utils.ACL_CONTENT_LOCK.Name, Value: lock.Value()},
+		ExpiresAt: int32(lock.Expires),
+	}}
+	current, exists := acls[nodeUuid]
+	if exists {
+		if l := utils.ParseContentLock(current.Action.Value); !l.IsExpired() && (replace == nil || !replace(l)) {
+			return errors.Conflict(VIEWS_LIBRARY_NAME, "This file is locked by another user")
+		}
+		req.ExpectedValue = current.Action.Value
+	}
+	aclClient := idm.NewACLServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACL, defaults.NewClient())
+	if _, err := aclClient.CreateACL(ctx, req); err != nil {
+		if !exists {
+			// Creation is unique per node: another request may have created a lock in the meantime
+			if l, e := GetContentLock(ctx, nodeUuid); e == nil && l != nil {
+				return errors.Conflict(VIEWS_LIBRARY_NAME, "This file is locked by another user")
+			}
 		}
-		break
+		return err
+	}
+	return nil
+}
+
+// DeleteContentLock removes the global lock registered on this node Uuid. If @current is not nil, the lock
+// is only removed if it was not changed in the meantime, otherwise a Conflict error is returned.
+func DeleteContentLock(ctx context.Context, nodeUuid string, current *utils.ContentLock) error {
+	action := &idm.ACLAction{Name: utils.ACL_CONTENT_LOCK.Name}
+	if current != nil {
+		action.Value = current.Value()
+	}
+	aclClient := idm.NewACLServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACL, defaults.NewClient())
+	q, _ := ptypes.MarshalAny(&idm.ACLSingleQuery{NodeIDs: []string{nodeUuid}, Actions: []*idm.ACLAction{action}})
+	rsp, err := aclClient.DeleteACL(ctx, &idm.DeleteACLRequest{Query: &service.Query{SubQueries: []*any.Any{q}}})
+	if err != nil {
+		return err
+	}
+	if current != nil && rsp.RowsDeleted == 0 {
+		return errors.Conflict(VIEWS_LIBRARY_NAME, "Lock has changed")
 	}
 	return nil
 }
 
+// checkLock finds if a global lock registered in ACLs applies to @node: a lock set on the node itself
+// or a recursive lock set on one of its parents. Nodes that do not exist yet are checked against the
+// locks of their closest existing parent and its ancestors.
+func (a *AclLockFilter) checkLock(ctx context.Context, node *tree.Node) error {
+	if node.Uuid == "" {
+		if rsp, err := a.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: node}); err == nil {
+			node = rsp.Node
+		}
+	}
+	existing := node
+	for existing.Uuid == "" {
+		parentPath := path.Dir(strings.TrimSuffix(existing.Path, "/"))
+		if parentPath == "." || parentPath == "/" || parentPath == existing.Path {
+			return nil
+		}
+		rsp, err := a.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: parentPath}})
+		if err != nil {
+			if errors.Parse(err.Error()).Code != http.StatusNotFound {
+				return err
+			}
+			existing = &tree.Node{Path: parentPath}
+			continue
+		}
+		existing = rsp.Node
+	}
+	parents, err := utils.BuildAncestorsList(ctx, a.clientsPool.GetTreeClient(), existing)
+	if err != nil {
+		return err
+	}
+	uuids := []string{existing.Uuid}
+	for _, parent := range parents {
+		uuids = append(uuids, parent.Uuid)
+	}
+	locks, err := ListContentLocks(ctx, uuids...)
+	if err != nil {
+		return err
+	}
+	for nodeUuid, lock := range locks {
+		if nodeUuid != node.Uuid && !lock.Recursive {
+			continue
+		}
+		if err := a.allowed(ctx, lock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLockedDescendants finds if a global lock is registered on a node below the folder @node.
+func (a *AclLockFilter) checkLockedDescendants(ctx context.Context, node *tree.Node) error {
+	if node.IsLeaf() || node.Path == "" {
+		return nil
+	}
+	locks, err := ListContentLocksBelow(ctx, node.Path)
+	if err != nil {
+		return err
+	}
+	for _, lock := range locks {
+		if err := a.allowed(ctx, lock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowed checks if the current request may modify a node locked by @lock. Locks holding a token only accept
+// requests carrying this token. Legacy locks without token accept requests from their owner.
+func (a *AclLockFilter) allowed(ctx context.Context, lock *utils.ContentLock) error {
+	if lock.Token != "" {
+		tokens, _ := ctx.Value(ctxLockTokenKey{}).([]string)
+		for _, token := range tokens {
+			if token == lock.Token {
+				return nil
+			}
+		}
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "This file is locked")
+	}
+	var userName string
+	if claims, ok := ctx.Value(claim.ContextKey).(claim.Claims); ok {
+		userName = claims.Name
+	}
+	if userName == "" || lock.Owner != userName {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "This file is locked by another user")
+	}
+	return nil
+}
+
+// checkMutation checks the node and, for folders, the files below it.
+func (a *AclLockFilter) checkMutation(ctx context.Context, node *tree.Node) error {
+	if node.Uuid == "" || node.Type == tree.NodeType_UNKNOWN {
+		if rsp, err := a.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: node}); err == nil {
+			node = rsp.Node
+		}
+	}
+	if err := a.checkLock(ctx, node); err != nil {
+		return err
+	}
+	return a.checkLockedDescendants(ctx, node)
+}
+
 // PutObject check locks before allowing Put operation.
 func (a *AclLockFilter) PutObject(ctx context.Context, node *tree.Node, reader io.Reader, requestData *PutRequestData) (int64, error) {
 	if branchInfo, ok := GetBranchInfo(ctx, "in"); ok && branchInfo.Binary {
@@ -102,8 +346,32 @@ func (a *AclLockFilter) MultipartCreate(ctx context.Context, target *tree.Node,
 	return a.next.MultipartCreate(ctx, target, requestData)
 }
 
-// CopyObject should check: quota on CopyObject operation? Can we copy an object on top of an existing node?
+// CopyObject checks that the target of the copy is not locked.
 func (a *AclLockFilter) CopyObject(ctx context.Context, from *tree.Node, to *tree.Node, requestData *CopyRequestData) (int64, error) {
-
+	if branchInfo, ok := GetBranchInfo(ctx, "to"); ok && branchInfo.Binary {
+		return a.next.CopyObject(ctx, from, to, requestData)
+	}
+	if err := a.checkLock(ctx, to); err != nil {
+		return 0, err
+	}
 	return a.next.CopyObject(ctx, from, to, requestData)
 }
+
+// UpdateNode checks that neither the moved node nor the target are locked.
+func (a *AclLockFilter) UpdateNode(ctx context.Context, in *tree.UpdateNodeRequest, opts ...client.CallOption) (*tree.UpdateNodeResponse, error) {
+	if err := a.checkMutation(ctx, in.From); err != nil {
+		return nil, err
+	}
+	if err := a.checkLock(ctx, in.To); err != nil {
+		return nil, err
+	}
+	return a.next.UpdateNode(ctx, in, opts...)
+}
+
+// DeleteNode checks that neither the deleted node nor the files below it are locked.
+func (a *AclLockFilter) DeleteNode(ctx context.Context, in *tree.DeleteNodeRequest, opts ...client.CallOption) (*tree.DeleteNodeResponse, error) {
+	if err := a.checkMutation(ctx, in.Node); err != nil {
+		return nil, err
+	}
+	return a.next.DeleteNode(ctx, in, opts...)
+}
@@ -25,10 +25,13 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/micro/go-micro/errors"
+	"github.com/patrickmn/go-cache"
 	"github.com/pydio/minio-go"
 	"go.uber.org/zap"
 
@@ -36,12 +39,17 @@ import (
 	"github.com/pydio/cells/common/auth/claim"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/quota"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
 	"github.com/pydio/cells/common/service/proto"
 	"github.com/pydio/cells/common/utils"
 )
 
+var (
+	userQuotasCache = cache.New(30*time.Second, 5*time.Minute)
+)
+
 type AclQuotaFilter struct {
 	AbstractHandler
 }
@@ -55,6 +63,9 @@ func (a *AclQuotaFilter) PutObject(ctx context.Context, node *tree.Node, reader
 		} else if maxQuota > 0 && currentUsage+requestData.Size > maxQuota {
 			return 0, errors.Forbidden(VIEWS_LIBRARY_NAME, "Quota is reached")
 		}
+		if err := a.CheckUserQuota(ctx, requestData.Size); err != nil {
+			return 0, err
+		}
 	}
 
 	return a.next.PutObject(ctx, node, reader, requestData)
@@ -69,6 +80,9 @@ func (a *AclQuotaFilter) MultipartPutObjectPart(ctx context.Context, target *tre
 		} else if maxQuota > 0 && currentUsage+requestData.Size > maxQuota {
 			return minio.ObjectPart{}, errors.Forbidden(VIEWS_LIBRARY_NAME, "Quota is reached")
 		}
+		if err := a.CheckUserQuota(ctx, requestData.Size); err != nil {
+			return minio.ObjectPart{}, err
+		}
 	}
 
 	return a.next.MultipartPutObjectPart(ctx, target, uploadID, partNumberMarker, reader, requestData)
@@ -83,11 +97,66 @@ func (a *AclQuotaFilter) CopyObject(ctx context.Context, from *tree.Node, to *tr
 		} else if maxQuota > 0 && currentUsage+from.Size > maxQuota {
 			return 0, errors.Forbidden(VIEWS_LIBRARY_NAME, "Quota is reached")
 		}
+		if err := a.CheckUserQuota(ctx, from.Size); err != nil {
+			return 0, err
+		}
 	}
 
 	return a.next.CopyObject(ctx, from, to, requestData)
 }
 
+// CheckUserQuota checks that the current user has enough space left in their own quota. This quota is set
+// on their roles for all workspaces and applies to all the data they own, whatever the workspace or cell.
+// Quota and usage are cached for a short time, so that successive uploads or parts do not look them up again.
+func (a *AclQuotaFilter) CheckUserQuota(ctx context.Context, size int64) error {
+
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		return nil
+	}
+	key := claims.Name + ":" + claims.Roles
+	if cached, ok := userQuotasCache.Get(key); ok {
+		return cached.(*userQuota).reserve(size)
+	}
+	uq := &userQuota{}
+	var err error
+	if uq.max, err = utils.GetUserQuota(ctx, strings.Split(claims.Roles, ",")); err != nil {
+		return err
+	}
+	if uq.max > 0 {
+		usageClient := quota.NewUsageServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_QUOTA, defaults.NewClient())
+		resp, err := usageClient.GetUsage(ctx, &quota.GetUsageRequest{Login: claims.Name})
+		if err != nil {
+			return err
+		}
+		uq.used = resp.GetUsage().GetBytes()
+		log.Logger(ctx).Debug("got user quota", zap.Int64("q", uq.max), zap.Int64("u", uq.used))
+	}
+	userQuotasCache.Set(key, uq, cache.DefaultExpiration)
+	return uq.reserve(size)
+}
+
+// userQuota is a cached user quota, with the usage known at load time increased by the accepted uploads.
+type userQuota struct {
+	sync.Mutex
+	max  int64
+	used int64
+}
+
+// reserve accounts the size to the usage if it fits in the quota.
+func (u *userQuota) reserve(size int64) error {
+	if u.max <= 0 {
+		return nil
+	}
+	u.Lock()
+	defer u.Unlock()
+	if u.used+size > u.max {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "User quota is reached")
+	}
+	u.used += size
+	return nil
+}
+
 func (a *AclQuotaFilter) ComputeQuota(ctx context.Context, workspace *idm.Workspace) (quota int64, usage int64, err error) {
 
 	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package views
+
+import (
+	"context"
+	"testing"
+
+	"github.com/patrickmn/go-cache"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/auth/claim"
+)
+
+func TestAclQuotaFilter_CheckUserQuota(t *testing.T) {
+
+	Convey("Test cached user quota", t, func() {
+		filter := &AclQuotaFilter{}
+		claims := claim.Claims{Name: "quota-user", Roles: "ROOT_GROUP,quota-user"}
+		ctx := context.WithValue(context.Background(), claim.ContextKey, claims)
+		userQuotasCache.Set("quota-user:ROOT_GROUP,quota-user", &userQuota{max: 100, used: 60}, cache.DefaultExpiration)
+		defer userQuotasCache.Delete("quota-user:ROOT_GROUP,quota-user")
+
+		// Accepted uploads are added to the cached usage
+		So(filter.CheckUserQuota(ctx, 30), ShouldBeNil)
+		So(filter.CheckUserQuota(ctx, 20), ShouldNotBeNil)
+		So(filter.CheckUserQuota(ctx, 10), ShouldBeNil)
+		So(filter.CheckUserQuota(ctx, 1), ShouldNotBeNil)
+
+		// Anonymous contexts are not checked
+		So(filter.CheckUserQuota(context.Background(), 1000), ShouldBeNil)
+	})
+
+	Convey("Test users without quota", t, func() {
+		unlimited := &userQuota{}
+		So(unlimited.reserve(1000000), ShouldBeNil)
+		So(unlimited.used, ShouldEqual, 0)
+	})
+}
@@ -41,6 +41,7 @@ type ArchiveHandler struct {
 // Override the response of GetObject if it is sent on a folder key : create an archive on-the-fly.
 func (a *ArchiveHandler) GetObject(ctx context.Context, node *tree.Node, requestData *GetRequestData) (io.ReadCloser, error) {
 
+	ctx = withLinkDownload(ctx)
 	originalPath := node.Path
 
 	if ok, format, archivePath, innerPath := a.isArchivePath(originalPath); ok && len(innerPath) > 0 {
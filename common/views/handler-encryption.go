@@ -30,20 +30,33 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/config"
 	"github.com/pydio/cells/common/crypto"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/encryption"
 	"github.com/pydio/cells/common/proto/object"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
 	"github.com/pydio/cells/idm/key"
 )
 
+// EncryptionKeyRotationConfig is the datasource configuration key holding the ID of the master key that
+// is replacing the current one. While it is set, new node keys are sealed with it.
+const EncryptionKeyRotationConfig = "EncryptionKeyRotation"
+
 //EncryptionHandler encryption node middleware
 type EncryptionHandler struct {
 	AbstractHandler
 }
 
+// nodeKeyTool describes who owns the node keys for the current request, and how they are sealed.
+type nodeKeyTool struct {
+	owner string
+	keyID string
+	tool  key.UserKeyTool
+}
+
 //GetObject Enriches request metadata for GetObject with Encryption Materials, if required by datasource
 func (e *EncryptionHandler) GetObject(ctx context.Context, node *tree.Node, requestData *GetRequestData) (io.ReadCloser, error) {
 
@@ -52,7 +65,7 @@ func (e *EncryptionHandler) GetObject(ctx context.Context, node *tree.Node, requ
 	}
 
 	info, ok := GetBranchInfo(ctx, "in")
-	if ok && info.EncryptionMode == object.EncryptionMode_MASTER {
+	if ok && info.EncryptionMode != object.EncryptionMode_CLEAR {
 		clone := node.Clone()
 		log.Logger(ctx).Debug("[HANDLER ENCRYPT] > Get Object", zap.String("UUID", node.Uuid), zap.String("Path", node.Path))
 
@@ -82,7 +95,7 @@ func (e *EncryptionHandler) GetObject(ctx context.Context, node *tree.Node, requ
 
 		clone.SetMeta(common.META_NAMESPACE_DATASOURCE_NAME, dsName)
 		var err error
-		requestData.EncryptionMaterial, err = e.retrieveEncryptionMaterials(ctx, clone, info.EncryptionKey)
+		requestData.EncryptionMaterial, err = e.retrieveEncryptionMaterials(ctx, clone, info.DataSource, false)
 		if err != nil {
 			return nil, err
 		}
@@ -101,7 +114,7 @@ func (e *EncryptionHandler) PutObject(ctx context.Context, node *tree.Node, read
 
 	info, ok := GetBranchInfo(ctx, "in")
 	var err error
-	if !ok || info.EncryptionMode != object.EncryptionMode_MASTER {
+	if !ok || info.EncryptionMode == object.EncryptionMode_CLEAR {
 		return e.next.PutObject(ctx, node, reader, requestData)
 	}
 
@@ -129,7 +142,7 @@ func (e *EncryptionHandler) PutObject(ctx context.Context, node *tree.Node, read
 
 	clone.SetMeta(common.META_NAMESPACE_DATASOURCE_NAME, dsName)
 
-	requestData.EncryptionMaterial, err = e.retrieveEncryptionMaterials(ctx, node, info.EncryptionKey)
+	requestData.EncryptionMaterial, err = e.retrieveEncryptionMaterials(ctx, clone, info.DataSource, true)
 	if err != nil {
 		return 0, err
 	}
@@ -139,7 +152,7 @@ func (e *EncryptionHandler) PutObject(ctx context.Context, node *tree.Node, read
 		log.Logger(ctx).Debug("PutObject failed", zap.Error(err))
 	} else if requestData.EncryptionMaterial != nil {
 		params := requestData.EncryptionMaterial.(*crypto.AESGCMMaterials).GetEncryptedParameters()
-		err = e.setNodeEncryptionParams(ctx, node, params)
+		err = e.setNodeEncryptionParams(ctx, clone, params)
 	}
 	return n, err
 }
@@ -147,7 +160,7 @@ func (e *EncryptionHandler) PutObject(ctx context.Context, node *tree.Node, read
 // CopyObject Enriches request metadata for CopyObject with Encryption Materials, if required by datasource
 func (e *EncryptionHandler) CopyObject(ctx context.Context, from *tree.Node, to *tree.Node, requestData *CopyRequestData) (int64, error) {
 	info, ok := GetBranchInfo(ctx, "in")
-	if !ok || info.EncryptionMode != object.EncryptionMode_MASTER {
+	if !ok || info.EncryptionMode == object.EncryptionMode_CLEAR {
 		return e.next.CopyObject(ctx, from, to, requestData)
 	}
 
@@ -178,7 +191,7 @@ func (e *EncryptionHandler) CopyObject(ctx context.Context, from *tree.Node, to
 
 	cloneFrom.SetMeta(common.META_NAMESPACE_DATASOURCE_NAME, dsName)
 	cloneTo.SetMeta(common.META_NAMESPACE_DATASOURCE_NAME, dsName)
-	err := e.copyEncryptionMaterials(ctx, from, to)
+	err := e.copyEncryptionMaterials(ctx, cloneFrom, cloneTo, info.DataSource)
 	if err != nil {
 		return 0, err
 	}
@@ -186,6 +199,79 @@ func (e *EncryptionHandler) CopyObject(ctx context.Context, from *tree.Node, to
 	return e.next.CopyObject(ctx, from, to, requestData)
 }
 
+// ShareNodeKeys gives @users access to the encryption key of @node, or of all files below @node if it is a folder.
+// Keys are shared by the current user, who must be able to open them. Datasources encrypted with the
+// master key do not need any sharing, as their keys are not bound to a user.
+func ShareNodeKeys(ctx context.Context, pool *ClientsPool, node *tree.Node, users []string) error {
+	e := &EncryptionHandler{}
+	return e.walkEncryptedFiles(ctx, pool, node, func(ds object.DataSource, file *tree.Node) error {
+		return e.shareEncryptionKey(ctx, file, ds, users)
+	})
+}
+
+// UnshareNodeKeys revokes the keys shared by the current user with @users on @node, or on all files below @node
+// if it is a folder. If @users is empty, all keys shared by the current user are revoked.
+func UnshareNodeKeys(ctx context.Context, pool *ClientsPool, node *tree.Node, users []string) error {
+	e := &EncryptionHandler{}
+	return e.walkEncryptedFiles(ctx, pool, node, func(ds object.DataSource, file *tree.Node) error {
+		kt, err := e.getNodeKeyTool(ctx, ds)
+		if err != nil {
+			return err
+		}
+		nodeKeyClient := encryption.NewNodeKeyManagerClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ENC_KEY, defaults.NewClient())
+		_, err = nodeKeyClient.DeleteNodeSharedKey(ctx, &encryption.DeleteNodeSharedKeyRequest{
+			NodeId:  file.Uuid,
+			OwnerId: kt.owner,
+			Users:   users,
+		})
+		return err
+	})
+}
+
+func (e *EncryptionHandler) walkEncryptedFiles(ctx context.Context, pool *ClientsPool, node *tree.Node, callback func(ds object.DataSource, file *tree.Node) error) error {
+
+	rsp, err := pool.GetTreeClient().ReadNode(ctx, &tree.ReadNodeRequest{Node: node})
+	if err != nil {
+		return err
+	}
+	root := rsp.Node
+
+	dsName := root.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME)
+	if dsName == "" {
+		return nil
+	}
+	source, err := pool.GetDataSourceInfo(dsName)
+	if err != nil {
+		return err
+	}
+	if source.EncryptionMode == object.EncryptionMode_CLEAR || source.EncryptionMode == object.EncryptionMode_MASTER {
+		return nil
+	}
+
+	if root.IsLeaf() {
+		return callback(source.DataSource, root)
+	}
+
+	stream, err := pool.GetTreeClient().ListNodes(ctx, &tree.ListNodesRequest{Node: root, Recursive: true})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for {
+		resp, er := stream.Recv()
+		if er != nil {
+			break
+		}
+		if resp == nil || !resp.Node.IsLeaf() || strings.HasSuffix(resp.Node.Path, common.PYDIO_SYNC_HIDDEN_FILE_META) {
+			continue
+		}
+		if err := callback(source.DataSource, resp.Node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *EncryptionHandler) setNodeEncryptionKey(ctx context.Context, nodeKey *encryption.NodeKey) error {
 	nodeKeyClient := encryption.NewNodeKeyManagerClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ENC_KEY, defaults.NewClient())
 	_, err := nodeKeyClient.SetNodeKey(ctx, &encryption.SetNodeKeyRequest{
@@ -204,6 +290,8 @@ func (e *EncryptionHandler) getNodeEncryptionKey(ctx context.Context, userID str
 		return nil, err
 	}
 	return &encryption.NodeKey{
+		NodeId:    nodeUUID,
+		UserId:    userID,
 		OwnerId:   rsp.OwnerId,
 		Data:      rsp.EncryptedKey,
 		BlockSize: rsp.BlockSize,
@@ -220,16 +308,85 @@ func (e *EncryptionHandler) setNodeEncryptionParams(ctx context.Context, node *t
 	return err
 }
 
-func (e *EncryptionHandler) retrieveEncryptionMaterials(ctx context.Context, node *tree.Node, encryptionKeyName string) (*crypto.AESGCMMaterials, error) {
+// getNodeKeyTool finds the owner of the node keys for the current request depending on the datasource encryption mode:
+//   - MASTER: keys belong to the datasource and are sealed with the master key
+//   - USER: keys belong to the current user and are sealed with a personal key protected by the master password
+//   - USER_PWD: keys belong to the current user and are sealed with a personal key protected by the user secret,
+//     which is derived from the user password each time it is changed
+func (e *EncryptionHandler) getNodeKeyTool(ctx context.Context, ds object.DataSource) (*nodeKeyTool, error) {
+
+	switch ds.EncryptionMode {
+	case object.EncryptionMode_MASTER:
+		tool, err := key.MasterKeyTool(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keyID, fallbackIDs := masterKeyIDs(ds)
+		return &nodeKeyTool{owner: fmt.Sprintf("ds:%s", ds.Name), keyID: keyID, tool: key.FallbackKeyTool(tool, fallbackIDs...)}, nil
+
+	case object.EncryptionMode_USER, object.EncryptionMode_USER_PWD:
+		userName, _ := utils.FindUserNameInContext(ctx)
+		if userName == "" {
+			return nil, errors.Forbidden("views.Handler.encryption", "cannot find user in context")
+		}
+		tool, err := userKeyTool(ctx, ds, userName)
+		if err != nil {
+			return nil, err
+		}
+		return &nodeKeyTool{owner: userName, keyID: userKeyID(ds), tool: tool}, nil
+	}
+
+	return nil, errors.BadRequest("views.Handler.encryption", "unsupported encryption mode %s", ds.EncryptionMode.String())
+}
+
+// masterKeyIDs finds the master key used to seal new node keys of datasource @ds, and the keys that may
+// still be used to open existing ones while a rotation is running. The configuration is read directly, as
+// the key of a running datasource can be replaced by a rotation job. Once the rotation is committed, the
+// previous key is retired and is not used anymore.
+func masterKeyIDs(ds object.DataSource) (keyID string, fallbackIDs []string) {
+	serviceName := common.SERVICE_GRPC_NAMESPACE_ + common.SERVICE_DATA_SYNC_ + ds.Name
+	current := config.Get("services", serviceName, "EncryptionKey").String(ds.EncryptionKey)
+	rotation := config.Get("services", serviceName, EncryptionKeyRotationConfig).String("")
+
+	if rotation == "" {
+		return current, nil
+	}
+	keyID = rotation
+	seen := map[string]bool{keyID: true}
+	for _, id := range []string{current, ds.EncryptionKey} {
+		if id != "" && !seen[id] {
+			fallbackIDs = append(fallbackIDs, id)
+			seen[id] = true
+		}
+	}
+	return
+}
+
+// userKeyID computes the ID of the personal key used by a user on datasource @ds. Keys protected by the
+// user secret are stored under a different ID to avoid mixing them with keys protected by the master password.
+func userKeyID(ds object.DataSource) string {
+	if ds.EncryptionMode == object.EncryptionMode_USER_PWD {
+		return ds.EncryptionKey + key.SecretProtectedSuffix
+	}
+	return ds.EncryptionKey
+}
 
-	dsName := node.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME)
-	tool, err := key.MasterKeyTool(ctx)
+// userKeyTool creates the tool sealing the personal keys of @user on datasource @ds.
+func userKeyTool(ctx context.Context, ds object.DataSource, user string) (key.UserKeyTool, error) {
+	if ds.EncryptionMode == object.EncryptionMode_USER_PWD {
+		return key.SecretUserKeyTool(ctx, user)
+	}
+	return key.ManagedUserKeyTool(ctx, user)
+}
+
+func (e *EncryptionHandler) retrieveEncryptionMaterials(ctx context.Context, node *tree.Node, ds object.DataSource, forWriting bool) (*crypto.AESGCMMaterials, error) {
+
+	kt, err := e.getNodeKeyTool(ctx, ds)
 	if err != nil {
 		return nil, err
 	}
 
-	var wsUser = fmt.Sprintf("ds:%s", dsName)
-	nodeKey, err := e.getNodeEncryptionKey(ctx, wsUser, node.Uuid)
+	nodeKey, err := e.getNodeEncryptionKey(ctx, kt.owner, node.Uuid)
 	if err != nil {
 		return nil, err
 	}
@@ -237,23 +394,49 @@ func (e *EncryptionHandler) retrieveEncryptionMaterials(ctx context.Context, nod
 	if nodeKey.Data == nil || len(nodeKey.Data) == 0 {
 		//if not found
 
+		if ds.EncryptionMode != object.EncryptionMode_MASTER {
+			if !forWriting {
+				return nil, errors.Forbidden("views.Handler.encryption", "no encryption key found for node %s", node.Uuid)
+			}
+			// Content is overwritten by a user who has no key: the content key of the owner is reused, so that
+			// the keys shared with other users stay valid
+			if encKey, owner, err := e.openOwnerEncryptionKey(ctx, node, ds); err != nil {
+				return nil, err
+			} else if encKey != nil {
+				sealedKey, err := kt.tool.GetEncrypted(ctx, kt.keyID, encKey)
+				if err != nil {
+					return nil, err
+				}
+				err = e.setNodeEncryptionKey(ctx, &encryption.NodeKey{
+					UserId:  kt.owner,
+					NodeId:  node.Uuid,
+					OwnerId: owner,
+					Data:    sealedKey,
+				})
+				if err != nil {
+					return nil, err
+				}
+				return crypto.NewAESGCMMaterials(encKey, nil), nil
+			}
+		}
+
 		//we generate a new key
 		encKey, err := crypto.RandomBytes(32)
 		if err != nil {
 			return nil, err
 		}
 
-		//we seal the key with the ws tool
-		sealedKey, err := tool.GetEncrypted(ctx, encryptionKeyName, encKey)
+		//we seal the key with the owner tool
+		sealedKey, err := kt.tool.GetEncrypted(ctx, kt.keyID, encKey)
 		if err != nil {
 			return nil, err
 		}
 
-		//we tell the data-key service to associate the sealed key to wsUser<->node
+		//we tell the data-key service to associate the sealed key to owner<->node
 		err = e.setNodeEncryptionKey(ctx, &encryption.NodeKey{
-			UserId:    wsUser,
+			UserId:    kt.owner,
 			NodeId:    node.Uuid,
-			OwnerId:   wsUser,
+			OwnerId:   kt.owner,
 			Data:      sealedKey,
 			Nonce:     nil,
 			BlockSize: 0,
@@ -265,7 +448,7 @@ func (e *EncryptionHandler) retrieveEncryptionMaterials(ctx context.Context, nod
 		return crypto.NewAESGCMMaterials(encKey, nil), nil
 
 	}
-	encKey, err := tool.GetDecrypted(ctx, encryptionKeyName, nodeKey.Data)
+	encKey, err := kt.tool.GetDecrypted(ctx, kt.keyID, nodeKey.Data)
 	if err != nil {
 		return nil, err
 	}
@@ -276,23 +459,108 @@ func (e *EncryptionHandler) retrieveEncryptionMaterials(ctx context.Context, nod
 	}), nil
 }
 
-func (e *EncryptionHandler) copyEncryptionMaterials(ctx context.Context, source *tree.Node, copy *tree.Node) error {
+// openOwnerEncryptionKey opens the content key of @node with the personal key of the user owning it. It returns
+// a nil key if the node has no content key yet.
+func (e *EncryptionHandler) openOwnerEncryptionKey(ctx context.Context, node *tree.Node, ds object.DataSource) ([]byte, string, error) {
+	ownerKey, err := e.getNodeEncryptionKey(ctx, "", node.Uuid)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(ownerKey.Data) == 0 {
+		return nil, "", nil
+	}
+	tool, err := userKeyTool(ctx, ds, ownerKey.OwnerId)
+	if err != nil {
+		return nil, "", err
+	}
+	encKey, err := tool.GetDecrypted(ctx, userKeyID(ds), ownerKey.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	return encKey, ownerKey.OwnerId, nil
+}
+
+func (e *EncryptionHandler) shareEncryptionKey(ctx context.Context, node *tree.Node, ds object.DataSource, users []string) error {
+
+	kt, err := e.getNodeKeyTool(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	nodeKey, err := e.getNodeEncryptionKey(ctx, kt.owner, node.Uuid)
+	if err != nil {
+		return err
+	}
+	if len(nodeKey.Data) == 0 {
+		log.Logger(ctx).Debug("no key to share for node", zap.String("node", node.Uuid), zap.String("user", kt.owner))
+		return nil
+	}
+
+	encKey, err := kt.tool.GetDecrypted(ctx, kt.keyID, nodeKey.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user == kt.owner {
+			continue
+		}
+
+		tool, err := userKeyTool(ctx, ds, user)
+		if err != nil {
+			return err
+		}
+		sealedKey, err := tool.GetEncrypted(ctx, kt.keyID, encKey)
+		if err != nil {
+			return err
+		}
+
+		err = e.setNodeEncryptionKey(ctx, &encryption.NodeKey{
+			NodeId:    node.Uuid,
+			UserId:    user,
+			OwnerId:   kt.owner,
+			Data:      sealedKey,
+			Nonce:     nodeKey.Nonce,
+			BlockSize: nodeKey.BlockSize,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *EncryptionHandler) copyEncryptionMaterials(ctx context.Context, source *tree.Node, copy *tree.Node, ds object.DataSource) error {
 	//does not handle cross-copy if ever exists somewhere in pydio
-	dsName := source.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME)
-	var wsUser = fmt.Sprintf("ds:%s", dsName)
+	kt, err := e.getNodeKeyTool(ctx, ds)
+	if err != nil {
+		return err
+	}
 
-	nodeKey, err := e.getNodeEncryptionKey(ctx, wsUser, source.Uuid)
+	nodeKey, err := e.getNodeEncryptionKey(ctx, kt.owner, source.Uuid)
 	if err != nil {
 		return err
 	}
 
+	data := nodeKey.Data
+	if ds.EncryptionMode != object.EncryptionMode_MASTER && nodeKey.OwnerId != kt.owner {
+		// Key was shared with the current user: the copy is owned by the current user and sealed with their own key
+		encKey, err := kt.tool.GetDecrypted(ctx, kt.keyID, nodeKey.Data)
+		if err != nil {
+			return err
+		}
+		if data, err = kt.tool.GetEncrypted(ctx, kt.keyID, encKey); err != nil {
+			return err
+		}
+	}
+
 	copyNodeKey := &encryption.NodeKey{
 		BlockSize: nodeKey.BlockSize,
-		Data:      nodeKey.Data,
+		Data:      data,
 		NodeId:    copy.Uuid,
 		Nonce:     nodeKey.Nonce,
-		OwnerId:   wsUser,
-		UserId:    wsUser,
+		OwnerId:   kt.owner,
+		UserId:    kt.owner,
 	}
 	return e.setNodeEncryptionKey(ctx, copyNodeKey)
 }
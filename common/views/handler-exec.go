@@ -33,6 +33,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/crypto"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/tree"
 )
@@ -155,12 +156,25 @@ func (e *Executor) GetObject(ctx context.Context, node *tree.Node, requestData *
 		if offset == 0 && end == 0 {
 			log.Logger(ctx).Debug("GET DATA WITH NO RANGE ")
 			reader, err = writer.GetEncryptedObject(info.ObjectsBucket, s3Path, requestData.EncryptionMaterial)
-		} else {
-			log.Logger(ctx).Info("Warning, passing a request Length on encrypted data is not supported yet", zap.Int64("offset", requestData.StartOffset), zap.Int64("end", end))
-			if err := headers.SetRange(requestData.StartOffset, end); err != nil {
-				return nil, err
+		} else if materials, ok := requestData.EncryptionMaterial.(*crypto.AESGCMMaterials); ok {
+			length := requestData.Length
+			if end == 0 {
+				length = -1
+			}
+			encOffset, encLength := materials.SetPlainRange(offset, length)
+			log.Logger(ctx).Debug("GET ENCRYPTED RANGE", zap.Int64("From", encOffset), zap.Int64("Length", encLength), node.Zap())
+			if encLength > 0 {
+				if err := headers.SetRange(encOffset, encOffset+encLength-1); err != nil {
+					return nil, err
+				}
+			} else if encOffset > 0 {
+				if err := headers.SetRange(encOffset, 0); err != nil {
+					return nil, err
+				}
 			}
 			reader, _, err = writer.GetObject(info.ObjectsBucket, s3Path, headers)
+		} else {
+			return nil, errors.BadRequest(VIEWS_LIBRARY_NAME, "Range requests are not supported by these encryption materials")
 		}
 	} else {
 		headers := minio.GetObjectOptions{}
@@ -266,6 +280,10 @@ func (e *Executor) CopyObject(ctx context.Context, from *tree.Node, to *tree.Nod
 		if err3 != nil {
 			return 0, err3
 		}
+		if requestData.Progress != nil {
+			// Server-side copy: notify all bytes once done
+			notifyProgress(requestData.Progress, oi.Size)
+		}
 		return oi.Size, nil
 
 	} else {
@@ -285,11 +303,15 @@ func (e *Executor) CopyObject(ctx context.Context, from *tree.Node, to *tree.Nod
 		if err != nil {
 			return 0, err
 		}
+		var source io.Reader = reader
+		if requestData.Progress != nil {
+			source = &progressReader{source: reader, progress: requestData.Progress}
+		}
 
 		if requestData.destEncryptionMaterial != nil {
-			return destClient.PutEncryptedObject(destBucket, toPath, reader, requestData.destEncryptionMaterial)
+			return destClient.PutEncryptedObject(destBucket, toPath, source, requestData.destEncryptionMaterial)
 		} else {
-			oi, err := destClient.PutObject(destBucket, toPath, reader, srcStat.Size, nil, nil, requestData.Metadata)
+			oi, err := destClient.PutObject(destBucket, toPath, source, srcStat.Size, nil, nil, requestData.Metadata)
 			if err != nil {
 				log.Logger(ctx).Error("CopyObject / Different Clients",
 					zap.Error(err),
@@ -423,3 +445,30 @@ func (e *Executor) buildS3Path(branchInfo BranchInfo, node *tree.Node) string {
 	return path
 
 }
+
+// progressReader notifies a progress hook of the bytes read from the source.
+type progressReader struct {
+	source   io.Reader
+	progress io.Reader
+}
+
+func (p *progressReader) Read(b []byte) (n int, err error) {
+	n, err = p.source.Read(b)
+	if n > 0 {
+		p.progress.Read(b[:n])
+	}
+	return
+}
+
+// notifyProgress sends size bytes to a progress hook, by chunks.
+func notifyProgress(progress io.Reader, size int64) {
+	buf := make([]byte, 32*1024)
+	for remaining := size; remaining > 0; {
+		chunk := int64(len(buf))
+		if remaining < chunk {
+			chunk = remaining
+		}
+		progress.Read(buf[:chunk])
+		remaining -= chunk
+	}
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/patrickmn/go-cache"
+	"github.com/pydio/minio-go"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/federation"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+// FederatedHandler mounts the shares received from remote servers as additional top-level
+// folders of the user. Requests on these folders are proxied to the remote server through WebDAV,
+// the rest is passed to the next handler.
+type FederatedHandler struct {
+	AbstractHandler
+	Store  federation.Store
+	mounts *cache.Cache
+}
+
+// NewFederatedHandler creates a FederatedHandler reading received shares from the docstore.
+func NewFederatedHandler() *FederatedHandler {
+	return &FederatedHandler{
+		Store:  federation.NewDocStore(),
+		mounts: cache.New(10*time.Second, time.Minute),
+	}
+}
+
+// ReadNode stats a node of a mounted share.
+func (f *FederatedHandler) ReadNode(ctx context.Context, in *tree.ReadNodeRequest, opts ...client.CallOption) (*tree.ReadNodeResponse, error) {
+	share, rel, err := f.resolve(ctx, in.Node)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.ReadNode(ctx, in, opts...)
+	}
+	n, err := share.DAV().Stat(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	return &tree.ReadNodeResponse{Success: true, Node: f.toNode(share, n)}, nil
+}
+
+// ListNodes appends mounted shares to the root listing, and lists the content of mounted shares.
+func (f *FederatedHandler) ListNodes(ctx context.Context, in *tree.ListNodesRequest, opts ...client.CallOption) (tree.NodeProvider_ListNodesClient, error) {
+	if strings.Trim(in.Node.GetPath(), "/") == "" && !in.Recursive {
+		return f.listRoot(ctx, in, opts...)
+	}
+	share, rel, err := f.resolve(ctx, in.Node)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.ListNodes(ctx, in, opts...)
+	}
+	dav := share.DAV()
+	children, err := dav.List(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	streamer := NewWrappingStreamer()
+	go func() {
+		defer streamer.Close()
+		queue := children
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			if in.FilterType == tree.NodeType_UNKNOWN || in.FilterType == n.Type {
+				streamer.Send(&tree.ListNodesResponse{Node: f.toNode(share, n)})
+			}
+			if in.Recursive && !n.IsLeaf() {
+				sub, e := dav.List(ctx, n.Path)
+				if e != nil {
+					streamer.SendError(e)
+					return
+				}
+				queue = append(queue, sub...)
+			}
+		}
+	}()
+	return streamer, nil
+}
+
+// CreateNode creates a folder or an empty file in a mounted share.
+func (f *FederatedHandler) CreateNode(ctx context.Context, in *tree.CreateNodeRequest, opts ...client.CallOption) (*tree.CreateNodeResponse, error) {
+	share, rel, err := f.resolveWritable(ctx, in.Node)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.CreateNode(ctx, in, opts...)
+	}
+	dav := share.DAV()
+	if in.Node.IsLeaf() {
+		_, err = dav.Put(ctx, rel, strings.NewReader(""), 0)
+	} else {
+		err = dav.Mkcol(ctx, rel)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err := dav.Stat(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	return &tree.CreateNodeResponse{Success: true, Node: f.toNode(share, n)}, nil
+}
+
+// UpdateNode moves a node inside a mounted share.
+func (f *FederatedHandler) UpdateNode(ctx context.Context, in *tree.UpdateNodeRequest, opts ...client.CallOption) (*tree.UpdateNodeResponse, error) {
+	share, from, to, err := f.resolvePair(ctx, in.From, in.To)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.UpdateNode(ctx, in, opts...)
+	}
+	dav := share.DAV()
+	if err := dav.Move(ctx, from, to); err != nil {
+		return nil, err
+	}
+	n, err := dav.Stat(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return &tree.UpdateNodeResponse{Success: true, Node: f.toNode(share, n)}, nil
+}
+
+// DeleteNode removes a node from a mounted share.
+func (f *FederatedHandler) DeleteNode(ctx context.Context, in *tree.DeleteNodeRequest, opts ...client.CallOption) (*tree.DeleteNodeResponse, error) {
+	share, rel, err := f.resolveWritable(ctx, in.Node)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.DeleteNode(ctx, in, opts...)
+	}
+	if rel == "" {
+		return nil, errors.Forbidden(common.SERVICE_GATEWAY_OCM, "Cannot delete the root of a federated share")
+	}
+	if err := share.DAV().Delete(ctx, rel); err != nil {
+		return nil, err
+	}
+	return &tree.DeleteNodeResponse{Success: true}, nil
+}
+
+// GetObject reads a file from a mounted share.
+func (f *FederatedHandler) GetObject(ctx context.Context, node *tree.Node, requestData *GetRequestData) (io.ReadCloser, error) {
+	share, rel, err := f.resolve(ctx, node)
+	if err != nil {
+		return nil, err
+	} else if share == nil {
+		return f.next.GetObject(ctx, node, requestData)
+	}
+	length := int64(-1)
+	if requestData.Length > 0 {
+		length = requestData.Length
+	}
+	return share.DAV().Get(ctx, rel, requestData.StartOffset, length)
+}
+
+// PutObject writes a file to a mounted share.
+func (f *FederatedHandler) PutObject(ctx context.Context, node *tree.Node, reader io.Reader, requestData *PutRequestData) (int64, error) {
+	share, rel, err := f.resolveWritable(ctx, node)
+	if err != nil {
+		return 0, err
+	} else if share == nil {
+		return f.next.PutObject(ctx, node, reader, requestData)
+	}
+	size := int64(-1)
+	if requestData != nil && requestData.Size > 0 {
+		size = requestData.Size
+	}
+	return share.DAV().Put(ctx, rel, reader, size)
+}
+
+// CopyObject copies a file inside a mounted share. Copies between a share and another location are not supported.
+func (f *FederatedHandler) CopyObject(ctx context.Context, from *tree.Node, to *tree.Node, requestData *CopyRequestData) (int64, error) {
+	share, src, dest, err := f.resolvePair(ctx, from, to)
+	if err != nil {
+		return 0, err
+	} else if share == nil {
+		return f.next.CopyObject(ctx, from, to, requestData)
+	}
+	dav := share.DAV()
+	if err := dav.Copy(ctx, src, dest); err != nil {
+		return 0, err
+	}
+	n, err := dav.Stat(ctx, dest)
+	if err != nil {
+		return 0, err
+	}
+	return n.Size, nil
+}
+
+// MultipartCreate is not supported on mounted shares.
+func (f *FederatedHandler) MultipartCreate(ctx context.Context, target *tree.Node, requestData *MultipartRequestData) (string, error) {
+	if share, _, err := f.resolve(ctx, target); err != nil {
+		return "", err
+	} else if share != nil {
+		return "", errors.BadRequest(common.SERVICE_GATEWAY_OCM, "Multipart uploads are not supported on federated shares")
+	}
+	return f.next.MultipartCreate(ctx, target, requestData)
+}
+
+// MultipartPutObjectPart is not supported on mounted shares.
+func (f *FederatedHandler) MultipartPutObjectPart(ctx context.Context, target *tree.Node, uploadID string, partNumberMarker int, reader io.Reader, requestData *PutRequestData) (minio.ObjectPart, error) {
+	if share, _, err := f.resolve(ctx, target); err != nil {
+		return minio.ObjectPart{}, err
+	} else if share != nil {
+		return minio.ObjectPart{}, errors.BadRequest(common.SERVICE_GATEWAY_OCM, "Multipart uploads are not supported on federated shares")
+	}
+	return f.next.MultipartPutObjectPart(ctx, target, uploadID, partNumberMarker, reader, requestData)
+}
+
+// MultipartComplete is not supported on mounted shares.
+func (f *FederatedHandler) MultipartComplete(ctx context.Context, target *tree.Node, uploadID string, uploadedParts []minio.CompletePart) (minio.ObjectInfo, error) {
+	if share, _, err := f.resolve(ctx, target); err != nil {
+		return minio.ObjectInfo{}, err
+	} else if share != nil {
+		return minio.ObjectInfo{}, errors.BadRequest(common.SERVICE_GATEWAY_OCM, "Multipart uploads are not supported on federated shares")
+	}
+	return f.next.MultipartComplete(ctx, target, uploadID, uploadedParts)
+}
+
+func (f *FederatedHandler) listRoot(ctx context.Context, in *tree.ListNodesRequest, opts ...client.CallOption) (tree.NodeProvider_ListNodesClient, error) {
+	shares, err := f.userShares(ctx)
+	if err != nil || len(shares) == 0 {
+		return f.next.ListNodes(ctx, in, opts...)
+	}
+	stream, err := f.next.ListNodes(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	streamer := NewWrappingStreamer()
+	go func() {
+		defer streamer.Close()
+		defer stream.Close()
+		for {
+			resp, e := stream.Recv()
+			if e != nil {
+				if e != io.EOF {
+					streamer.SendError(e)
+					return
+				}
+				break
+			}
+			streamer.Send(resp)
+		}
+		if in.FilterType == tree.NodeType_LEAF {
+			return
+		}
+		for _, share := range shares {
+			streamer.Send(&tree.ListNodesResponse{Node: f.toNode(share, &tree.Node{Type: tree.NodeType_COLLECTION})})
+		}
+	}()
+	return streamer, nil
+}
+
+// resolve finds the mounted share a node belongs to, and the path of the node relative to the share root.
+func (f *FederatedHandler) resolve(ctx context.Context, node *tree.Node) (*federation.ReceivedShare, string, error) {
+	p := strings.Trim(node.GetPath(), "/")
+	if p == "" {
+		return nil, "", nil
+	}
+	parts := strings.SplitN(p, "/", 2)
+	shares, err := f.userShares(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, share := range shares {
+		if share.Slug == parts[0] {
+			if len(parts) == 2 {
+				return share, parts[1], nil
+			}
+			return share, "", nil
+		}
+	}
+	return nil, "", nil
+}
+
+// resolveWritable is like resolve, but fails if the share is read-only.
+func (f *FederatedHandler) resolveWritable(ctx context.Context, node *tree.Node) (*federation.ReceivedShare, string, error) {
+	share, rel, err := f.resolve(ctx, node)
+	if err != nil || share == nil {
+		return share, rel, err
+	}
+	if !share.Writable() {
+		return nil, "", errors.Forbidden(common.SERVICE_GATEWAY_OCM, "Federated share %s is read-only", share.Name)
+	}
+	return share, rel, nil
+}
+
+// resolvePair resolves the source and target of a move or a copy, that must belong to the same writable share.
+func (f *FederatedHandler) resolvePair(ctx context.Context, from, to *tree.Node) (*federation.ReceivedShare, string, string, error) {
+	src, fromRel, err := f.resolve(ctx, from)
+	if err != nil {
+		return nil, "", "", err
+	}
+	dest, toRel, err := f.resolveWritable(ctx, to)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if src == nil && dest == nil {
+		return nil, "", "", nil
+	}
+	if src == nil || dest == nil || src.ID != dest.ID {
+		return nil, "", "", errors.BadRequest(common.SERVICE_GATEWAY_OCM, "Cannot move or copy data between a federated share and another location")
+	}
+	if fromRel == "" || toRel == "" {
+		return nil, "", "", errors.Forbidden(common.SERVICE_GATEWAY_OCM, "Cannot move or copy the root of a federated share")
+	}
+	return dest, fromRel, toRel, nil
+}
+
+// userShares lists the shares accepted by the current user, with a short cache.
+func (f *FederatedHandler) userShares(ctx context.Context) ([]*federation.ReceivedShare, error) {
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" || claims.Profile == common.PYDIO_PROFILE_SHARED {
+		return nil, nil
+	}
+	if f.mounts != nil {
+		if cached, ok := f.mounts.Get(claims.Name); ok {
+			return cached.([]*federation.ReceivedShare), nil
+		}
+	}
+	received, err := f.Store.ListShares(ctx, claims.Name)
+	if err != nil {
+		return nil, err
+	}
+	// Pending shares are only mounted once the user accepted them
+	var shares []*federation.ReceivedShare
+	for _, s := range received {
+		if s.Accepted {
+			shares = append(shares, s)
+		}
+	}
+	if f.mounts != nil {
+		f.mounts.Set(claims.Name, shares, cache.DefaultExpiration)
+	}
+	return shares, nil
+}
+
+// toNode transforms a node returned by the remote server into a node of the mount.
+func (f *FederatedHandler) toNode(share *federation.ReceivedShare, n *tree.Node) *tree.Node {
+	out := n.Clone()
+	if n.Path == "" {
+		out.Path = share.Slug
+		out.Uuid = share.ID
+		out.SetMeta("ocm_owner", share.Owner)
+		out.SetMeta("ocm_writable", share.Writable())
+	} else {
+		out.Path = share.Slug + "/" + n.Path
+		h := md5.Sum([]byte(share.ID + ":" + n.Path))
+		out.Uuid = fmt.Sprintf("ocm-%s", hex.EncodeToString(h[:]))
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/federation"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+func testFederatedHandler(permissions ...string) (*FederatedHandler, *HandlerMock, context.Context, func()) {
+	dav := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "provider-id" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	store := federation.NewMemoryStore()
+	store.PutShare(context.Background(), &federation.ReceivedShare{
+		ID:           "share-id",
+		Slug:         "remote-cell",
+		ShareWith:    "john",
+		Name:         "Remote Cell",
+		Owner:        "admin@remote",
+		ProviderID:   "provider-id",
+		URI:          server.URL,
+		SharedSecret: "secret",
+		Permissions:  permissions,
+		Accepted:     true,
+	})
+	// Pending shares are not mounted
+	store.PutShare(context.Background(), &federation.ReceivedShare{
+		ID:           "pending-id",
+		Slug:         "pending-cell",
+		ShareWith:    "john",
+		Name:         "Pending Cell",
+		Owner:        "admin@remote",
+		ProviderID:   "other-id",
+		URI:          server.URL,
+		SharedSecret: "other",
+		Permissions:  permissions,
+	})
+	h := &FederatedHandler{Store: store}
+	mock := NewHandlerMock()
+	h.SetNextHandler(mock)
+	ctx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{
+		Name:    "john",
+		Profile: common.PYDIO_PROFILE_STANDARD,
+	})
+	return h, mock, ctx, server.Close
+}
+
+func listFederatedNodes(h *FederatedHandler, ctx context.Context, p string, recursive bool) (paths []string, err error) {
+	streamer, err := h.ListNodes(ctx, &tree.ListNodesRequest{Node: &tree.Node{Path: p}, Recursive: recursive})
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+	for {
+		resp, e := streamer.Recv()
+		if e != nil {
+			if e != io.EOF {
+				err = e
+			}
+			break
+		}
+		paths = append(paths, resp.Node.Path)
+	}
+	return
+}
+
+func TestFederatedHandler(t *testing.T) {
+
+	Convey("Browse and modify a writable federated share", t, func() {
+		h, mock, ctx, closer := testFederatedHandler(federation.PermissionRead, federation.PermissionWrite)
+		defer closer()
+
+		paths, err := listFederatedNodes(h, ctx, "", false)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"remote-cell"})
+
+		resp, err := h.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: "remote-cell"}})
+		So(err, ShouldBeNil)
+		So(resp.Node.Uuid, ShouldEqual, "share-id")
+		So(resp.Node.IsLeaf(), ShouldBeFalse)
+
+		_, err = h.CreateNode(ctx, &tree.CreateNodeRequest{Node: &tree.Node{Path: "remote-cell/folder", Type: tree.NodeType_COLLECTION}})
+		So(err, ShouldBeNil)
+		written, err := h.PutObject(ctx, &tree.Node{Path: "remote-cell/folder/file.txt"}, bytes.NewBufferString("remote content"), &PutRequestData{Size: 14})
+		So(err, ShouldBeNil)
+		So(written, ShouldEqual, 14)
+		So(mock.Nodes["in"].Path, ShouldEqual, "")
+
+		paths, err = listFederatedNodes(h, ctx, "remote-cell", true)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"remote-cell/folder", "remote-cell/folder/file.txt"})
+
+		reader, err := h.GetObject(ctx, &tree.Node{Path: "remote-cell/folder/file.txt"}, &GetRequestData{StartOffset: 7, Length: 7})
+		So(err, ShouldBeNil)
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		So(string(data), ShouldEqual, "content")
+
+		size, err := h.CopyObject(ctx, &tree.Node{Path: "remote-cell/folder/file.txt"}, &tree.Node{Path: "remote-cell/copy.txt"}, &CopyRequestData{})
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, 14)
+
+		updated, err := h.UpdateNode(ctx, &tree.UpdateNodeRequest{From: &tree.Node{Path: "remote-cell/copy.txt"}, To: &tree.Node{Path: "remote-cell/moved.txt"}})
+		So(err, ShouldBeNil)
+		So(updated.Node.Path, ShouldEqual, "remote-cell/moved.txt")
+		So(updated.Node.Uuid, ShouldStartWith, "ocm-")
+
+		_, err = h.UpdateNode(ctx, &tree.UpdateNodeRequest{From: &tree.Node{Path: "remote-cell/moved.txt"}, To: &tree.Node{Path: "local/moved.txt"}})
+		So(err, ShouldNotBeNil)
+
+		_, err = h.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "remote-cell/folder"}})
+		So(err, ShouldBeNil)
+		paths, err = listFederatedNodes(h, ctx, "remote-cell", false)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"remote-cell/moved.txt"})
+
+		_, err = h.MultipartCreate(ctx, &tree.Node{Path: "remote-cell/big.bin"}, &MultipartRequestData{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Refuse writes on a read-only federated share", t, func() {
+		h, _, ctx, closer := testFederatedHandler(federation.PermissionRead)
+		defer closer()
+
+		_, err := h.PutObject(ctx, &tree.Node{Path: "remote-cell/file.txt"}, bytes.NewBufferString("content"), &PutRequestData{Size: 7})
+		So(err, ShouldNotBeNil)
+		_, err = h.CreateNode(ctx, &tree.CreateNodeRequest{Node: &tree.Node{Path: "remote-cell/folder"}})
+		So(err, ShouldNotBeNil)
+		paths, err := listFederatedNodes(h, ctx, "remote-cell", false)
+		So(err, ShouldBeNil)
+		So(paths, ShouldBeEmpty)
+	})
+
+	Convey("Pass other requests to the next handler", t, func() {
+		h, mock, ctx, closer := testFederatedHandler(federation.PermissionRead)
+		defer closer()
+
+		_, err := h.PutObject(ctx, &tree.Node{Path: "local/file.txt"}, bytes.NewBufferString("content"), &PutRequestData{Size: 7})
+		So(err, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "local/file.txt")
+
+		sharedCtx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{Name: "john", Profile: common.PYDIO_PROFILE_SHARED})
+		paths, err := listFederatedNodes(h, sharedCtx, "", false)
+		So(err, ShouldBeNil)
+		So(paths, ShouldBeEmpty)
+	})
+}
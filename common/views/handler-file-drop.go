@@ -0,0 +1,500 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/pydio/minio-go"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+const (
+	fileDropStoreID = "filedrop"
+
+	dropFolderUploader  = "Uploader"
+	dropFolderTimestamp = "Timestamp"
+)
+
+type ctxFileDropKey struct{}
+
+// FileDropFilter implements upload-only public links. Visitors can upload files, optionally into
+// a subfolder dedicated to their session, but they only see and modify what they uploaded themselves.
+// It must be placed before the AclFilterHandler, that lets write-only users list folders in this mode.
+type FileDropFilter struct {
+	AbstractHandler
+	linkResolver
+}
+
+// dropSession records the nodes uploaded by one visitor of a file-drop link.
+type dropSession struct {
+	ID         string   `json:"-"`
+	Repository string   `json:"REPOSITORY"`
+	Folder     string   `json:"FOLDER"`
+	Paths      []string `json:"PATHS"`
+}
+
+// visible checks if a node was uploaded during the session, or leads to such a node.
+func (s *dropSession) visible(nodePath string) bool {
+	nodePath = strings.TrimSuffix(nodePath, "/")
+	for _, p := range s.Paths {
+		if p == nodePath || strings.HasPrefix(p, nodePath+"/") || strings.HasPrefix(nodePath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// owns checks if a node was uploaded during the session, or is inside a folder created during the session.
+func (s *dropSession) owns(nodePath string) bool {
+	nodePath = strings.TrimSuffix(nodePath, "/")
+	for _, p := range s.Paths {
+		if p == nodePath || strings.HasPrefix(nodePath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isFileDropContext(ctx context.Context) bool {
+	_, ok := ctx.Value(ctxFileDropKey{}).(bool)
+	return ok
+}
+
+// ReadNode hides nodes that were not uploaded during the current session.
+func (f *FileDropFilter) ReadNode(ctx context.Context, in *tree.ReadNodeRequest, opts ...client.CallOption) (*tree.ReadNodeResponse, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return nil, err
+	} else if link == nil {
+		return f.next.ReadNode(ctx, in, opts...)
+	}
+	node, err := f.inFolder(ctx, in.Node, "in", link, session, false)
+	if err != nil {
+		return nil, err
+	}
+	if !f.isRoot(ctx, node, "in") && !session.visible(node.Path) {
+		return nil, errors.NotFound(VIEWS_LIBRARY_NAME, "Node not found")
+	}
+	r := *in
+	r.Node = node
+	return f.next.ReadNode(ctx, &r, opts...)
+}
+
+// ListNodes only sends nodes that were uploaded during the current session.
+func (f *FileDropFilter) ListNodes(ctx context.Context, in *tree.ListNodesRequest, opts ...client.CallOption) (tree.NodeProvider_ListNodesClient, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return nil, err
+	} else if link == nil || in.Ancestors {
+		return f.next.ListNodes(ctx, in, opts...)
+	}
+	node, err := f.inFolder(ctx, in.Node, "in", link, session, false)
+	if err != nil {
+		return nil, err
+	}
+	if !f.isRoot(ctx, node, "in") && !session.visible(node.Path) {
+		return nil, errors.Forbidden(VIEWS_LIBRARY_NAME, "Node is not readable")
+	}
+	r := *in
+	r.Node = node
+	stream, err := f.next.ListNodes(context.WithValue(ctx, ctxFileDropKey{}, true), &r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := NewWrappingStreamer()
+	go func() {
+		defer stream.Close()
+		defer s.Close()
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			if resp == nil || !session.visible(resp.Node.GetPath()) {
+				continue
+			}
+			s.Send(resp)
+		}
+	}()
+	return s, nil
+}
+
+// CreateNode records created folders in the session.
+func (f *FileDropFilter) CreateNode(ctx context.Context, in *tree.CreateNodeRequest, opts ...client.CallOption) (*tree.CreateNodeResponse, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return nil, err
+	} else if link == nil {
+		return f.next.CreateNode(ctx, in, opts...)
+	}
+	node, err := f.inFolder(ctx, in.Node, "in", link, session, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkTarget(ctx, node, session); err != nil {
+		return nil, err
+	}
+	r := *in
+	r.Node = node
+	resp, err := f.next.CreateNode(ctx, &r, opts...)
+	if err == nil {
+		err = f.record(ctx, session, node.Path)
+	}
+	return resp, err
+}
+
+// UpdateNode only allows moving nodes uploaded during the current session.
+func (f *FileDropFilter) UpdateNode(ctx context.Context, in *tree.UpdateNodeRequest, opts ...client.CallOption) (*tree.UpdateNodeResponse, error) {
+	link, session, err := f.resolveDrop(ctx, "from")
+	if err != nil {
+		return nil, err
+	} else if link == nil {
+		return f.next.UpdateNode(ctx, in, opts...)
+	}
+	from, err := f.inFolder(ctx, in.From, "from", link, session, false)
+	if err != nil {
+		return nil, err
+	}
+	to, err := f.inFolder(ctx, in.To, "to", link, session, true)
+	if err != nil {
+		return nil, err
+	}
+	if !session.owns(from.Path) {
+		return nil, errors.Forbidden(VIEWS_LIBRARY_NAME, "You can only move the files you have uploaded")
+	}
+	if err := f.checkTarget(ctx, to, session); err != nil {
+		return nil, err
+	}
+	r := *in
+	r.From = from
+	r.To = to
+	resp, err := f.next.UpdateNode(ctx, &r, opts...)
+	if err == nil {
+		err = f.record(ctx, session, to.Path)
+	}
+	return resp, err
+}
+
+// DeleteNode only allows deleting nodes uploaded during the current session.
+func (f *FileDropFilter) DeleteNode(ctx context.Context, in *tree.DeleteNodeRequest, opts ...client.CallOption) (*tree.DeleteNodeResponse, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return nil, err
+	} else if link == nil {
+		return f.next.DeleteNode(ctx, in, opts...)
+	}
+	node, err := f.inFolder(ctx, in.Node, "in", link, session, false)
+	if err != nil {
+		return nil, err
+	}
+	if !session.owns(node.Path) {
+		return nil, errors.Forbidden(VIEWS_LIBRARY_NAME, "You can only delete the files you have uploaded")
+	}
+	r := *in
+	r.Node = node
+	return f.next.DeleteNode(ctx, &r, opts...)
+}
+
+// PutObject moves the upload to the session folder if required, prevents overwriting
+// files uploaded by others and records the uploaded file in the session.
+func (f *FileDropFilter) PutObject(ctx context.Context, node *tree.Node, reader io.Reader, requestData *PutRequestData) (int64, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return 0, err
+	} else if link == nil {
+		return f.next.PutObject(ctx, node, reader, requestData)
+	}
+	target, err := f.inFolder(ctx, node, "in", link, session, true)
+	if err != nil {
+		return 0, err
+	}
+	if err := f.checkTarget(ctx, target, session); err != nil {
+		return 0, err
+	}
+	written, err := f.next.PutObject(ctx, target, reader, requestData)
+	if err == nil {
+		err = f.record(ctx, session, target.Path)
+	}
+	return written, err
+}
+
+// MultipartCreate moves the upload to the session folder if required, prevents overwriting
+// files uploaded by others and records the uploaded file in the session.
+func (f *FileDropFilter) MultipartCreate(ctx context.Context, target *tree.Node, requestData *MultipartRequestData) (string, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil {
+		return "", err
+	} else if link == nil {
+		return f.next.MultipartCreate(ctx, target, requestData)
+	}
+	node, err := f.inFolder(ctx, target, "in", link, session, true)
+	if err != nil {
+		return "", err
+	}
+	if err := f.checkTarget(ctx, node, session); err != nil {
+		return "", err
+	}
+	uploadID, err := f.next.MultipartCreate(ctx, node, requestData)
+	if err == nil {
+		err = f.record(ctx, session, node.Path)
+	}
+	return uploadID, err
+}
+
+// MultipartPutObjectPart moves the part to the session folder if required.
+func (f *FileDropFilter) MultipartPutObjectPart(ctx context.Context, target *tree.Node, uploadID string, partNumberMarker int, reader io.Reader, requestData *PutRequestData) (minio.ObjectPart, error) {
+	node, err := f.multipartTarget(ctx, target)
+	if err != nil {
+		return minio.ObjectPart{}, err
+	}
+	return f.next.MultipartPutObjectPart(ctx, node, uploadID, partNumberMarker, reader, requestData)
+}
+
+// MultipartComplete moves the target to the session folder if required.
+func (f *FileDropFilter) MultipartComplete(ctx context.Context, target *tree.Node, uploadID string, uploadedParts []minio.CompletePart) (minio.ObjectInfo, error) {
+	node, err := f.multipartTarget(ctx, target)
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	return f.next.MultipartComplete(ctx, node, uploadID, uploadedParts)
+}
+
+// MultipartAbort moves the target to the session folder if required.
+func (f *FileDropFilter) MultipartAbort(ctx context.Context, target *tree.Node, uploadID string, requestData *MultipartRequestData) error {
+	node, err := f.multipartTarget(ctx, target)
+	if err != nil {
+		return err
+	}
+	return f.next.MultipartAbort(ctx, node, uploadID, requestData)
+}
+
+// MultipartListObjectParts moves the target to the session folder if required.
+func (f *FileDropFilter) MultipartListObjectParts(ctx context.Context, target *tree.Node, uploadID string, partNumberMarker int, maxParts int) (minio.ListObjectPartsResult, error) {
+	node, err := f.multipartTarget(ctx, target)
+	if err != nil {
+		return minio.ListObjectPartsResult{}, err
+	}
+	return f.next.MultipartListObjectParts(ctx, node, uploadID, partNumberMarker, maxParts)
+}
+
+func (f *FileDropFilter) multipartTarget(ctx context.Context, target *tree.Node) (*tree.Node, error) {
+	link, session, err := f.resolveDrop(ctx, "in")
+	if err != nil || link == nil {
+		return target, err
+	}
+	return f.inFolder(ctx, target, "in", link, session, false)
+}
+
+// resolveDrop loads the current link if it is in file-drop mode, and the current visitor session.
+func (f *FileDropFilter) resolveDrop(ctx context.Context, identifier string) (*linkDocument, *dropSession, error) {
+	link, err := f.resolveLink(ctx, identifier)
+	if err != nil || link == nil || !link.FileDrop {
+		return nil, nil, err
+	}
+	visitor, err := dropVisitor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := f.loadSession(ctx, link.Hash+"-"+visitor)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.Repository = link.RepositoryId
+	return link, session, nil
+}
+
+// inFolder rewrites the node path inside the session folder, if the link requires one. When create
+// is true, the folder name is computed and stored in the session if it is not already known.
+func (f *FileDropFilter) inFolder(ctx context.Context, node *tree.Node, identifier string, link *linkDocument, session *dropSession, create bool) (*tree.Node, error) {
+	branchInfo, ok := GetBranchInfo(ctx, identifier)
+	if !ok || branchInfo.Root == nil || link.DropFolder == "" || node == nil {
+		return node, nil
+	}
+	root := branchInfo.Root
+	rel := strings.Trim(strings.TrimPrefix(node.Path, root.Path), "/")
+	if rel == "" {
+		return node, nil
+	}
+	if session.Folder == "" {
+		if !create {
+			return node, nil
+		}
+		visitor, err := dropVisitor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.assignFolder(ctx, session, dropFolderName(link.DropFolder, visitor, time.Now())); err != nil {
+			return nil, err
+		}
+	}
+	if rel == session.Folder || strings.HasPrefix(rel, session.Folder+"/") {
+		return node, nil
+	}
+	out := node.Clone()
+	out.Path = path.Join(root.Path, session.Folder, rel)
+	if dsPath := node.GetStringMeta(common.META_NAMESPACE_DATASOURCE_PATH); dsPath != "" {
+		out.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, path.Join(root.GetStringMeta(common.META_NAMESPACE_DATASOURCE_PATH), session.Folder, rel))
+	}
+	return out, nil
+}
+
+// checkTarget prevents overwriting nodes that were not uploaded during the current session.
+func (f *FileDropFilter) checkTarget(ctx context.Context, node *tree.Node, session *dropSession) error {
+	if session.owns(node.Path) {
+		return nil
+	}
+	if resp, e := f.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: node}); e == nil && resp.Node != nil {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "A file with the same name already exists, please rename it")
+	}
+	return nil
+}
+
+func (f *FileDropFilter) isRoot(ctx context.Context, node *tree.Node, identifier string) bool {
+	branchInfo, ok := GetBranchInfo(ctx, identifier)
+	if !ok {
+		return false
+	}
+	if branchInfo.Root != nil {
+		return strings.Trim(node.Path, "/") == strings.Trim(branchInfo.Root.Path, "/")
+	}
+	for _, r := range branchInfo.RootNodes {
+		if node.Uuid != "" && r == node.Uuid {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FileDropFilter) loadSession(ctx context.Context, id string) (*dropSession, error) {
+	resp, err := f.getDocStore().GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: fileDropStoreID, DocumentID: id})
+	if err != nil {
+		return nil, err
+	}
+	session := &dropSession{}
+	if resp.Document != nil {
+		if err := json.Unmarshal([]byte(resp.Document.Data), session); err != nil {
+			return nil, err
+		}
+	}
+	session.ID = id
+	return session, nil
+}
+
+// updateSession applies a change to the stored session, which is only replaced if it was not modified
+// in the meantime: otherwise the change is applied again to the new version. The session is then
+// refreshed with the stored values.
+func (f *FileDropFilter) updateSession(ctx context.Context, session *dropSession, change func(stored *dropSession) bool) error {
+	store := f.getDocStore()
+	for i := 0; i < linkUpdateRetries; i++ {
+		resp, err := store.GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: fileDropStoreID, DocumentID: session.ID})
+		if err != nil {
+			return err
+		}
+		stored := &dropSession{ID: session.ID}
+		var current string
+		if resp.Document != nil {
+			current = resp.Document.Data
+			if err := json.Unmarshal([]byte(current), stored); err != nil {
+				return err
+			}
+		}
+		if !change(stored) {
+			session.Folder, session.Paths = stored.Folder, stored.Paths
+			return nil
+		}
+		stored.Repository = session.Repository
+		data, _ := json.Marshal(stored)
+		meta, _ := json.Marshal(map[string]string{"REPOSITORY": stored.Repository})
+		_, err = store.PutDocument(ctx, &docstore.PutDocumentRequest{
+			StoreID:     fileDropStoreID,
+			DocumentID:  session.ID,
+			Document:    &docstore.Document{ID: session.ID, Data: string(data), IndexableMeta: string(meta)},
+			IfMatch:     true,
+			IfMatchData: current,
+		})
+		if err == nil {
+			session.Folder, session.Paths = stored.Folder, stored.Paths
+			return nil
+		} else if errors.Parse(err.Error()).Code != 409 {
+			return err
+		}
+	}
+	return errors.Conflict(VIEWS_LIBRARY_NAME, "Cannot update file drop session")
+}
+
+// assignFolder stores the session folder, unless one was concurrently assigned.
+func (f *FileDropFilter) assignFolder(ctx context.Context, session *dropSession, folder string) error {
+	return f.updateSession(ctx, session, func(stored *dropSession) bool {
+		if stored.Folder != "" {
+			return false
+		}
+		stored.Folder = folder
+		return true
+	})
+}
+
+// record adds a path to the nodes uploaded during the session.
+func (f *FileDropFilter) record(ctx context.Context, session *dropSession, nodePath string) error {
+	if nodePath == "" {
+		return nil
+	}
+	nodePath = strings.TrimSuffix(nodePath, "/")
+	return f.updateSession(ctx, session, func(stored *dropSession) bool {
+		for _, p := range stored.Paths {
+			if p == nodePath {
+				return false
+			}
+		}
+		stored.Paths = append(stored.Paths, nodePath)
+		return true
+	})
+}
+
+// dropVisitor reads the identifier of the current visitor. All visitors of a link share the same
+// hidden user and token claims, they are told apart by the visitor cookie set by the gateways.
+func dropVisitor(ctx context.Context) (string, error) {
+	if id := linkVisitor(ctx); id != "" {
+		return id, nil
+	}
+	return "", errors.Forbidden(VIEWS_LIBRARY_NAME, "Cannot identify the visitor of this link, please enable cookies")
+}
+
+// dropFolderName computes the name of the folder receiving the files of a visitor.
+func dropFolderName(mode string, visitor string, now time.Time) string {
+	switch mode {
+	case dropFolderUploader:
+		return "Upload " + strings.Replace(visitor, "-", "", -1)[0:8]
+	case dropFolderTimestamp:
+		return now.Format("2006-01-02 15h04m05")
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/metadata"
+	"github.com/patrickmn/go-cache"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+	servicecontext "github.com/pydio/cells/common/service/context"
+)
+
+func testFileDropFilter(dropFolder string) (*FileDropFilter, *HandlerMock, context.Context) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"PRELOG_USER": "link-user",
+		"REPOSITORY":  "link-ws",
+		"FILE_DROP":   true,
+		"DROP_FOLDER": dropFolder,
+	})
+	store := &docStoreMock{docs: map[string]*docstore.Document{
+		"link-hash": {ID: "link-hash", Data: string(raw), IndexableMeta: string(raw)},
+	}}
+	linkHashesCache = cache.New(30*time.Second, 5*time.Minute)
+	linkHashesCache.Set("link-ws", "link-hash", cache.DefaultExpiration)
+
+	h := &FileDropFilter{linkResolver: linkResolver{docStore: store}}
+	mock := NewHandlerMock()
+	mock.Nodes["ds/root/other.txt"] = &tree.Node{Path: "ds/root/other.txt"}
+	h.SetNextHandler(mock)
+
+	ctx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{
+		Name:        "link-user",
+		DisplayName: "John Doe",
+		Subject:     "subject",
+		IssuedAt:    1000,
+		Profile:     common.PYDIO_PROFILE_SHARED,
+	})
+	ctx = withDropVisitor(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e")
+	ctx = WithBranchInfo(ctx, "in", BranchInfo{
+		Workspace: idm.Workspace{UUID: "link-ws", Scope: idm.WorkspaceScope_LINK},
+		Root:      &tree.Node{Path: "ds/root"},
+	})
+	return h, mock, ctx
+}
+
+func withDropVisitor(ctx context.Context, visitor string) context.Context {
+	return metadata.NewContext(ctx, metadata.Metadata{servicecontext.HttpMetaVisitorId: visitor})
+}
+
+func listDropNodes(h *FileDropFilter, ctx context.Context, p string) (paths []string, err error) {
+	streamer, err := h.ListNodes(ctx, &tree.ListNodesRequest{Node: &tree.Node{Path: p}})
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+	for {
+		resp, e := streamer.Recv()
+		if e != nil {
+			break
+		}
+		paths = append(paths, resp.Node.Path)
+	}
+	return
+}
+
+func TestFileDropFilter(t *testing.T) {
+
+	Convey("Test session visibility", t, func() {
+		s := &dropSession{Paths: []string{"ds/root/folder/file.txt", "ds/root/created"}}
+		So(s.visible("ds/root/folder"), ShouldBeTrue)
+		So(s.visible("ds/root/folder/file.txt"), ShouldBeTrue)
+		So(s.visible("ds/root/created/sub/file.txt"), ShouldBeTrue)
+		So(s.visible("ds/root/folder/other.txt"), ShouldBeFalse)
+		So(s.owns("ds/root/folder"), ShouldBeFalse)
+		So(s.owns("ds/root/created/sub"), ShouldBeTrue)
+	})
+
+	Convey("Test folder names", t, func() {
+		now := time.Date(2018, 6, 12, 10, 30, 0, 0, time.UTC)
+		visitor := "0f8fad5b-d9cb-469f-a165-70867728950e"
+		So(dropFolderName(dropFolderTimestamp, visitor, now), ShouldEqual, "2018-06-12 10h30m00")
+		So(dropFolderName(dropFolderUploader, visitor, now), ShouldEqual, "Upload 0f8fad5b")
+		So(dropFolderName(dropFolderUploader, "7c9e6679-7425-40de-944b-e07fc1f90ae7", now), ShouldEqual, "Upload 7c9e6679")
+		So(dropFolderName("", visitor, now), ShouldBeEmpty)
+	})
+
+	Convey("Test upload and listing without folder", t, func() {
+		h, mock, ctx := testFileDropFilter("")
+
+		paths, err := listDropNodes(h, ctx, "ds/root")
+		So(err, ShouldBeNil)
+		So(paths, ShouldBeEmpty)
+
+		_, err = h.PutObject(ctx, &tree.Node{Path: "ds/root/other.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldNotBeNil)
+
+		_, err = h.PutObject(ctx, &tree.Node{Path: "ds/root/mine.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/root/mine.txt")
+		mock.Nodes["ds/root/mine.txt"] = mock.Nodes["in"]
+
+		paths, err = listDropNodes(h, ctx, "ds/root")
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"ds/root/mine.txt"})
+
+		_, err = h.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/root/other.txt"}})
+		So(err, ShouldNotBeNil)
+		_, err = h.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/root/mine.txt"}})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Test upload in uploader folder", t, func() {
+		h, mock, ctx := testFileDropFilter(dropFolderUploader)
+
+		_, err := h.PutObject(ctx, &tree.Node{Path: "ds/root/other.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/root/Upload 0f8fad5b/other.txt")
+
+		_, err = h.MultipartCreate(ctx, &tree.Node{Path: "ds/root/Upload 0f8fad5b/big.bin"}, &MultipartRequestData{})
+		So(err, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/root/Upload 0f8fad5b/big.bin")
+		mock.Nodes["ds/root/Upload 0f8fad5b/big.bin"] = mock.Nodes["in"]
+
+		paths, err := listDropNodes(h, ctx, "ds/root")
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"ds/root/Upload 0f8fad5b/big.bin"})
+
+		_, err = listDropNodes(h, ctx, "ds/root/another")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test visitors of the same link are isolated", t, func() {
+		h, mock, ctx := testFileDropFilter(dropFolderUploader)
+
+		_, err := h.PutObject(ctx, &tree.Node{Path: "ds/root/mine.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldBeNil)
+		mock.Nodes["ds/root/Upload 0f8fad5b/mine.txt"] = mock.Nodes["in"]
+
+		other := withDropVisitor(ctx, "7c9e6679-7425-40de-944b-e07fc1f90ae7")
+		_, err = h.PutObject(other, &tree.Node{Path: "ds/root/theirs.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/root/Upload 7c9e6679/theirs.txt")
+		mock.Nodes["ds/root/Upload 7c9e6679/theirs.txt"] = mock.Nodes["in"]
+
+		paths, err := listDropNodes(h, other, "ds/root")
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"ds/root/Upload 7c9e6679/theirs.txt"})
+		_, err = h.DeleteNode(other, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/root/Upload 0f8fad5b/mine.txt"}})
+		So(err, ShouldNotBeNil)
+
+		_, err = h.PutObject(withDropVisitor(ctx, ""), &tree.Node{Path: "ds/root/anonymous.txt"}, &bytes.Buffer{}, &PutRequestData{})
+		So(err, ShouldNotBeNil)
+	})
+
+}
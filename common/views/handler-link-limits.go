@@ -0,0 +1,392 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+	servicecontext "github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/service/defaults"
+)
+
+const (
+	linkDocStoreID = "share"
+	// Number of attempts to update a link document modified concurrently
+	linkUpdateRetries = 10
+)
+
+var (
+	// Workspace UUID => Link Hash, empty string for workspaces that are not bound to a public link
+	linkHashesCache = cache.New(30*time.Second, 5*time.Minute)
+	// Link Hash, visitor and object => true once a download of this object was counted for this visitor
+	linkVisitorDownloads = cache.New(24*time.Hour, time.Hour)
+)
+
+type linkDownloadKey struct{}
+
+// linkDownload is attached to the context of a request that may read many objects, like a folder
+// archive, so that it is counted as one download.
+type linkDownload struct {
+	sync.Mutex
+	counted bool
+}
+
+// withLinkDownload prepares the context to count all objects read during this request as one download.
+func withLinkDownload(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(linkDownloadKey{}).(*linkDownload); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, linkDownloadKey{}, &linkDownload{})
+}
+
+// LinkLimitsFilter enforces the limits attached to a public link (access window, maximum
+// number of downloads) when the hidden user of the link accesses data, whatever the gateway
+// that is used. Downloads are counted in the link document stored in the docstore.
+type LinkLimitsFilter struct {
+	AbstractHandler
+	linkResolver
+}
+
+// linkResolver loads the public link document bound to the current request from the docstore.
+type linkResolver struct {
+	docStore docstore.DocStoreClient
+}
+
+// linkDocument holds the subset of a public link document required to enforce its limits.
+type linkDocument struct {
+	Hash          string `json:"-"`
+	AccessStart   int64  `json:"ACCESS_START"`
+	ExpireTime    int64  `json:"EXPIRE_TIME"`
+	DownloadLimit int64  `json:"DOWNLOAD_LIMIT"`
+	DownloadCount int64  `json:"DOWNLOAD_COUNT"`
+	PreLogUser    string `json:"PRELOG_USER"`
+	PresetLogin   string `json:"PRESET_LOGIN"`
+	OwnerId       string `json:"OWNER_ID"`
+	RepositoryId  string `json:"REPOSITORY"`
+	FileDrop      bool   `json:"FILE_DROP"`
+	DropFolder    string `json:"DROP_FOLDER"`
+}
+
+// checkWindow verifies that the link is currently usable. Times are unix timestamps in seconds.
+func (l *linkDocument) checkWindow(now time.Time) error {
+	if l.AccessStart > 0 && now.Unix() < l.AccessStart {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "This link is not active yet")
+	}
+	if l.ExpireTime > 0 && now.Unix() > l.ExpireTime {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "This link has expired")
+	}
+	return nil
+}
+
+// checkDownloads verifies that the link still accepts a new download.
+func (l *linkDocument) checkDownloads() error {
+	if l.DownloadLimit > 0 && l.DownloadCount >= l.DownloadLimit {
+		return errors.Forbidden(VIEWS_LIBRARY_NAME, "Maximum number of downloads reached for this link")
+	}
+	return nil
+}
+
+// GetObject checks the link access window and download counter, then increments the counter once
+// the following handlers have successfully opened the object. An object is counted once per visitor
+// session, so that ranged reads of the same download are not counted again, and objects read for
+// the same archive are counted once. Requests that cannot be bound to a visitor are always counted.
+func (l *LinkLimitsFilter) GetObject(ctx context.Context, node *tree.Node, requestData *GetRequestData) (io.ReadCloser, error) {
+
+	link, err := l.resolveLink(ctx, "in")
+	if err != nil {
+		return nil, err
+	} else if link == nil {
+		return l.next.GetObject(ctx, node, requestData)
+	}
+	if e := link.checkWindow(time.Now()); e != nil {
+		l.audit(ctx, link, node, e)
+		return nil, e
+	}
+	var visitorKey string
+	if visitor := linkVisitor(ctx); visitor != "" {
+		objectId := node.Uuid
+		if objectId == "" {
+			objectId = node.Path
+		}
+		visitorKey = link.Hash + "/" + visitor + "/" + objectId
+		if _, counted := linkVisitorDownloads.Get(visitorKey); counted {
+			return l.next.GetObject(ctx, node, requestData)
+		}
+	}
+	download, _ := ctx.Value(linkDownloadKey{}).(*linkDownload)
+	if download != nil {
+		download.Lock()
+		defer download.Unlock()
+		if download.counted {
+			return l.next.GetObject(ctx, node, requestData)
+		}
+	}
+	if e := link.checkDownloads(); e != nil {
+		l.audit(ctx, link, node, e)
+		return nil, e
+	}
+	reader, e := l.next.GetObject(ctx, node, requestData)
+	if e != nil {
+		return reader, e
+	}
+	if e := l.incrementDownloads(ctx, link.Hash); e != nil {
+		reader.Close()
+		if errors.Parse(e.Error()).Code == 403 {
+			l.audit(ctx, link, node, e)
+			return nil, e
+		}
+		log.Logger(ctx).Error("Cannot update download counter for link", zap.String(common.KEY_LINK_HASH, link.Hash), zap.Error(e))
+		return nil, e
+	}
+	if download != nil {
+		download.counted = true
+	}
+	if visitorKey != "" {
+		linkVisitorDownloads.SetDefault(visitorKey, true)
+	}
+	l.audit(ctx, link, node, nil)
+
+	return reader, nil
+}
+
+// PutObject checks the link access window.
+func (l *LinkLimitsFilter) PutObject(ctx context.Context, node *tree.Node, reader io.Reader, requestData *PutRequestData) (int64, error) {
+	if err := l.checkAccess(ctx, "in", node); err != nil {
+		return 0, err
+	}
+	return l.next.PutObject(ctx, node, reader, requestData)
+}
+
+// MultipartCreate checks the link access window.
+func (l *LinkLimitsFilter) MultipartCreate(ctx context.Context, target *tree.Node, requestData *MultipartRequestData) (string, error) {
+	if err := l.checkAccess(ctx, "in", target); err != nil {
+		return "", err
+	}
+	return l.next.MultipartCreate(ctx, target, requestData)
+}
+
+// CopyObject checks the link access window on the target.
+func (l *LinkLimitsFilter) CopyObject(ctx context.Context, from *tree.Node, to *tree.Node, requestData *CopyRequestData) (int64, error) {
+	if err := l.checkAccess(ctx, "to", to); err != nil {
+		return 0, err
+	}
+	return l.next.CopyObject(ctx, from, to, requestData)
+}
+
+// ListNodes checks the link access window.
+func (l *LinkLimitsFilter) ListNodes(ctx context.Context, in *tree.ListNodesRequest, opts ...client.CallOption) (tree.NodeProvider_ListNodesClient, error) {
+	if err := l.checkAccess(ctx, "in", in.Node); err != nil {
+		return nil, err
+	}
+	return l.next.ListNodes(ctx, in, opts...)
+}
+
+// checkAccess resolves the current link, if any, and verifies its access window.
+func (l *LinkLimitsFilter) checkAccess(ctx context.Context, identifier string, node *tree.Node) error {
+	link, err := l.resolveLink(ctx, identifier)
+	if err != nil || link == nil {
+		return err
+	}
+	if e := link.checkWindow(time.Now()); e != nil {
+		l.audit(ctx, link, node, e)
+		return e
+	}
+	return nil
+}
+
+// linkVisitor reads the identifier of the current visitor from the visitor cookie set by the gateways,
+// if any. All visitors of a link share the same hidden user and token claims.
+func linkVisitor(ctx context.Context) string {
+	if meta, ok := metadata.FromContext(ctx); ok {
+		return meta[servicecontext.HttpMetaVisitorId]
+	}
+	return ""
+}
+
+// resolveLink finds the public link document attached to the current workspace, if the request
+// is performed by the hidden user of this link. It returns nil for all other requests.
+func (l *linkResolver) resolveLink(ctx context.Context, identifier string) (*linkDocument, error) {
+
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Profile != common.PYDIO_PROFILE_SHARED {
+		return nil, nil
+	}
+	branchInfo, ok := GetBranchInfo(ctx, identifier)
+	if !ok || branchInfo.Binary || branchInfo.Scope != idm.WorkspaceScope_LINK || branchInfo.UUID == "" {
+		return nil, nil
+	}
+
+	var hash string
+	if h, ok := linkHashesCache.Get(branchInfo.UUID); ok {
+		hash = h.(string)
+	} else {
+		var err error
+		if hash, err = l.findLinkHash(ctx, branchInfo.UUID); err != nil {
+			return nil, err
+		}
+		linkHashesCache.Set(branchInfo.UUID, hash, cache.DefaultExpiration)
+	}
+	if hash == "" {
+		return nil, nil
+	}
+
+	link, err := l.loadLink(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if link.PreLogUser != claims.Name && link.PresetLogin != claims.Name {
+		return nil, nil
+	}
+	return link, nil
+}
+
+// findLinkHash searches the docstore for the link bound to a given workspace.
+func (l *linkResolver) findLinkHash(ctx context.Context, workspaceId string) (string, error) {
+	streamer, err := l.getDocStore().ListDocuments(ctx, &docstore.ListDocumentsRequest{StoreID: linkDocStoreID, Query: &docstore.DocumentQuery{
+		MetaQuery: "+REPOSITORY:\"" + workspaceId + "\" +SHARE_TYPE:minisite",
+	}})
+	if err != nil {
+		return "", err
+	}
+	defer streamer.Close()
+	for {
+		resp, e := streamer.Recv()
+		if e != nil {
+			break
+		}
+		if resp.Document != nil {
+			return resp.Document.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// loadLink reads a link document from the docstore.
+func (l *linkResolver) loadLink(ctx context.Context, hash string) (*linkDocument, error) {
+	resp, err := l.getDocStore().GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: linkDocStoreID, DocumentID: hash})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Document == nil {
+		return nil, errors.NotFound(VIEWS_LIBRARY_NAME, "Cannot find link %s", hash)
+	}
+	link := &linkDocument{}
+	if err := json.Unmarshal([]byte(resp.Document.Data), link); err != nil {
+		return nil, err
+	}
+	link.Hash = hash
+	return link, nil
+}
+
+func (l *linkResolver) getDocStore() docstore.DocStoreClient {
+	if l.docStore != nil {
+		return l.docStore
+	}
+	return docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+}
+
+// incrementDownloads increases the DOWNLOAD_COUNT value of the link document, leaving all other values untouched.
+// The document is only replaced if it was not modified since it was read, so that concurrent downloads, possibly
+// served by other gateways, cannot exceed the limit: it returns a Forbidden error if the limit is reached.
+func (l *LinkLimitsFilter) incrementDownloads(ctx context.Context, hash string) error {
+	store := l.getDocStore()
+	for i := 0; i < linkUpdateRetries; i++ {
+		resp, err := store.GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: linkDocStoreID, DocumentID: hash})
+		if err != nil {
+			return err
+		}
+		if resp.Document == nil {
+			return errors.NotFound(VIEWS_LIBRARY_NAME, "Cannot find link %s", hash)
+		}
+		link := &linkDocument{}
+		if err := json.Unmarshal([]byte(resp.Document.Data), link); err != nil {
+			return err
+		}
+		if err := link.checkDownloads(); err != nil {
+			return err
+		}
+		data, err := incrementDownloadCount(resp.Document.Data)
+		if err != nil {
+			return err
+		}
+		doc := *resp.Document
+		doc.Data = data
+		doc.IndexableMeta = data
+		_, err = store.PutDocument(ctx, &docstore.PutDocumentRequest{StoreID: linkDocStoreID, DocumentID: hash, Document: &doc, IfMatch: true, IfMatchData: resp.Document.Data})
+		if err == nil || errors.Parse(err.Error()).Code != 409 {
+			return err
+		}
+	}
+	return errors.Conflict(VIEWS_LIBRARY_NAME, "Cannot update download counter for link %s", hash)
+}
+
+// audit logs a link access for the link owner. If err is not nil, the access has been refused.
+func (l *LinkLimitsFilter) audit(ctx context.Context, link *linkDocument, node *tree.Node, err error) {
+	msg := fmt.Sprintf("Link %s accessed: %s", link.Hash, node.GetPath())
+	if err != nil {
+		msg = fmt.Sprintf("Link %s access refused: %s", link.Hash, node.GetPath())
+	}
+	log.Auditer(ctx).Info(
+		msg,
+		log.GetAuditId(common.AUDIT_LINK_ACCESS),
+		zap.String(common.KEY_LINK_HASH, link.Hash),
+		zap.String(common.KEY_LINK_UUID, link.RepositoryId),
+		zap.String(common.KEY_LINK_OWNER, link.OwnerId),
+		node.ZapUuid(),
+		node.ZapPath(),
+		zap.Error(err), // empty if err == nil
+	)
+}
+
+// incrementDownloadCount increments the DOWNLOAD_COUNT key of a json-encoded link document.
+func incrementDownloadCount(data string) (string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return "", err
+	}
+	var count int64
+	if c, ok := values["DOWNLOAD_COUNT"].(float64); ok {
+		count = int64(c)
+	}
+	values["DOWNLOAD_COUNT"] = count + 1
+	out, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/patrickmn/go-cache"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+// docStoreMock only implements Get/Put on a map of documents.
+type docStoreMock struct {
+	sync.Mutex
+	docstore.DocStoreClient
+	docs map[string]*docstore.Document
+}
+
+func (d *docStoreMock) GetDocument(ctx context.Context, in *docstore.GetDocumentRequest, opts ...client.CallOption) (*docstore.GetDocumentResponse, error) {
+	d.Lock()
+	defer d.Unlock()
+	if doc, ok := d.docs[in.DocumentID]; ok {
+		c := *doc
+		return &docstore.GetDocumentResponse{Document: &c}, nil
+	}
+	return &docstore.GetDocumentResponse{}, nil
+}
+
+func (d *docStoreMock) PutDocument(ctx context.Context, in *docstore.PutDocumentRequest, opts ...client.CallOption) (*docstore.PutDocumentResponse, error) {
+	d.Lock()
+	defer d.Unlock()
+	if in.IfMatch {
+		var current string
+		if doc, ok := d.docs[in.DocumentID]; ok {
+			current = doc.Data
+		}
+		if current != in.IfMatchData || (in.IfMatchData == "" && d.docs[in.DocumentID] != nil) {
+			return nil, errors.Conflict("docstore", "Document was modified")
+		}
+	}
+	d.docs[in.DocumentID] = in.Document
+	return &docstore.PutDocumentResponse{Document: in.Document}, nil
+}
+
+func testLinkLimitsFilter(data map[string]interface{}) (*LinkLimitsFilter, *docStoreMock, context.Context) {
+	raw, _ := json.Marshal(data)
+	store := &docStoreMock{docs: map[string]*docstore.Document{
+		"link-hash": {ID: "link-hash", Data: string(raw), IndexableMeta: string(raw)},
+	}}
+	linkHashesCache = cache.New(30*time.Second, 5*time.Minute)
+	linkHashesCache.Set("link-ws", "link-hash", cache.DefaultExpiration)
+	linkVisitorDownloads = cache.New(24*time.Hour, time.Hour)
+
+	h := &LinkLimitsFilter{linkResolver: linkResolver{docStore: store}}
+	mock := NewHandlerMock()
+	mock.Nodes["file.txt"] = &tree.Node{Path: "file.txt"}
+	h.SetNextHandler(mock)
+
+	ctx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{Name: "link-user", Profile: common.PYDIO_PROFILE_SHARED})
+	ctx = WithBranchInfo(ctx, "in", BranchInfo{Workspace: idm.Workspace{UUID: "link-ws", Scope: idm.WorkspaceScope_LINK}})
+	return h, store, ctx
+}
+
+func TestLinkLimitsFilter_GetObject(t *testing.T) {
+
+	Convey("Test download counter is incremented and enforced", t, func() {
+		h, store, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRELOG_USER":    "link-user",
+			"OWNER_ID":       "admin",
+			"DOWNLOAD_LIMIT": 2,
+			"DOWNLOAD_COUNT": 0,
+			"TEMPLATE_NAME":  "pydio_unique_strip",
+		})
+		node := &tree.Node{Path: "file.txt"}
+
+		_, e := h.GetObject(ctx, node, &GetRequestData{})
+		So(e, ShouldBeNil)
+		_, e = h.GetObject(ctx, node, &GetRequestData{StartOffset: 10})
+		So(e, ShouldBeNil)
+		_, e = h.GetObject(ctx, node, &GetRequestData{StartOffset: 10})
+		So(e, ShouldNotBeNil)
+		_, e = h.GetObject(ctx, node, &GetRequestData{})
+		So(e, ShouldNotBeNil)
+
+		var values map[string]interface{}
+		json.Unmarshal([]byte(store.docs["link-hash"].Data), &values)
+		So(values["DOWNLOAD_COUNT"], ShouldEqual, 2)
+		So(values["TEMPLATE_NAME"], ShouldEqual, "pydio_unique_strip")
+		So(store.docs["link-hash"].IndexableMeta, ShouldEqual, store.docs["link-hash"].Data)
+	})
+
+	Convey("Test ranged reads of a visitor are counted once per object", t, func() {
+		h, store, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRELOG_USER":    "link-user",
+			"DOWNLOAD_LIMIT": 2,
+		})
+		h.next.(*HandlerMock).Nodes["other.txt"] = &tree.Node{Path: "other.txt"}
+		visitorCtx := withDropVisitor(ctx, "visitor-1")
+		node := &tree.Node{Path: "file.txt"}
+
+		for _, offset := range []int64{0, 1024, 2048, 0} {
+			_, e := h.GetObject(visitorCtx, node, &GetRequestData{StartOffset: offset, Length: 1024})
+			So(e, ShouldBeNil)
+		}
+		_, e := h.GetObject(visitorCtx, &tree.Node{Path: "other.txt"}, &GetRequestData{})
+		So(e, ShouldBeNil)
+
+		var values map[string]interface{}
+		json.Unmarshal([]byte(store.docs["link-hash"].Data), &values)
+		So(values["DOWNLOAD_COUNT"], ShouldEqual, 2)
+
+		// The limit is reached: other visitors cannot download, the first one can resume
+		_, e = h.GetObject(withDropVisitor(ctx, "visitor-2"), node, &GetRequestData{StartOffset: 1024})
+		So(e, ShouldNotBeNil)
+		_, e = h.GetObject(visitorCtx, node, &GetRequestData{StartOffset: 3072})
+		So(e, ShouldBeNil)
+	})
+
+	Convey("Test objects read for one archive are counted once", t, func() {
+		h, store, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRELOG_USER":    "link-user",
+			"DOWNLOAD_LIMIT": 1,
+		})
+		node := &tree.Node{Path: "file.txt"}
+
+		archiveCtx := withLinkDownload(ctx)
+		for i := 0; i < 3; i++ {
+			_, e := h.GetObject(archiveCtx, node, &GetRequestData{})
+			So(e, ShouldBeNil)
+		}
+		_, e := h.GetObject(withLinkDownload(ctx), node, &GetRequestData{})
+		So(e, ShouldNotBeNil)
+
+		var values map[string]interface{}
+		json.Unmarshal([]byte(store.docs["link-hash"].Data), &values)
+		So(values["DOWNLOAD_COUNT"], ShouldEqual, 1)
+	})
+
+	Convey("Test concurrent downloads cannot exceed the limit", t, func() {
+		h, store, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRELOG_USER":    "link-user",
+			"DOWNLOAD_LIMIT": 5,
+		})
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		var accepted int
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if e := h.incrementDownloads(ctx, "link-hash"); e == nil {
+					lock.Lock()
+					accepted++
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		So(accepted, ShouldBeLessThanOrEqualTo, 5)
+
+		var values map[string]interface{}
+		json.Unmarshal([]byte(store.docs["link-hash"].Data), &values)
+		So(values["DOWNLOAD_COUNT"], ShouldEqual, accepted)
+	})
+
+	Convey("Test access window is enforced", t, func() {
+		h, _, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRESET_LOGIN": "link-user",
+			"EXPIRE_TIME":  time.Now().Add(-time.Hour).Unix(),
+		})
+		_, e := h.GetObject(ctx, &tree.Node{Path: "file.txt"}, &GetRequestData{})
+		So(e, ShouldNotBeNil)
+
+		h, _, ctx = testLinkLimitsFilter(map[string]interface{}{
+			"PRESET_LOGIN": "link-user",
+			"ACCESS_START": time.Now().Add(time.Hour).Unix(),
+		})
+		_, e = h.ListNodes(ctx, &tree.ListNodesRequest{Node: &tree.Node{Path: "folder"}})
+		So(e, ShouldNotBeNil)
+	})
+
+	Convey("Test other users are not affected", t, func() {
+		h, _, ctx := testLinkLimitsFilter(map[string]interface{}{
+			"PRELOG_USER": "another-user",
+			"EXPIRE_TIME": time.Now().Add(-time.Hour).Unix(),
+		})
+		_, e := h.GetObject(ctx, &tree.Node{Path: "file.txt"}, &GetRequestData{})
+		So(e, ShouldBeNil)
+	})
+
+}
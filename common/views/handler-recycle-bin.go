@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/micro/go-micro/client"
+	"github.com/pborman/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/common/utils"
+)
+
+// workspaceRootAction is the name of the ACL action attaching a root node to a workspace.
+const workspaceRootAction = "workspace-path"
+
+type ctxPermanentDeleteKey struct{}
+
+// WithPermanentDelete flags the context so that the RecycleBinHandler lets the deletion
+// through instead of moving the node to the recycle bin.
+func WithPermanentDelete(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxPermanentDeleteKey{}, true)
+}
+
+// RecycleBinHandler turns DeleteNode requests into moves to the recycle bin of the
+// workspace (or datasource in admin views) the node belongs to. The original location
+// and the deletion time are stored as metadata on the node so that it can be restored
+// or purged later on.
+type RecycleBinHandler struct {
+	AbstractHandler
+}
+
+// DeleteNode moves the node to the recycle bin, unless it is already inside a recycle bin,
+// is a root or the context requires a permanent deletion.
+func (r *RecycleBinHandler) DeleteNode(ctx context.Context, in *tree.DeleteNodeRequest, opts ...client.CallOption) (*tree.DeleteNodeResponse, error) {
+
+	if permanent, ok := ctx.Value(ctxPermanentDeleteKey{}).(bool); ok && permanent {
+		return r.next.DeleteNode(ctx, in, opts...)
+	}
+	nodePath := strings.Trim(in.Node.Path, "/")
+	if path.Base(nodePath) == common.PYDIO_SYNC_HIDDEN_FILE_META {
+		return r.next.DeleteNode(ctx, in, opts...)
+	}
+
+	readResp, err := r.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: in.Node})
+	if err != nil {
+		return nil, err
+	}
+	source := readResp.Node
+	recycleRoot, ok := r.recycleRoot(ctx, nodePath, source)
+	if !ok || IsInRecycleBin(nodePath, recycleRoot) {
+		return r.next.DeleteNode(ctx, in, opts...)
+	}
+	restorePath, err := r.restorePath(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensureRecycleRoot(ctx, recycleRoot); err != nil {
+		return nil, err
+	}
+	target := recycleRoot + "/" + path.Base(nodePath)
+	if _, e := r.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: target}}); e == nil {
+		target = UniqueRecyclePath(target)
+	}
+
+	log.Logger(ctx).Debug("Moving node to recycle bin", zap.String("from", nodePath), zap.String("to", target))
+	if _, err := r.next.UpdateNode(ctx, &tree.UpdateNodeRequest{From: source, To: &tree.Node{Path: target, Type: source.Type}}, opts...); err != nil {
+		return nil, err
+	}
+
+	metaNode := &tree.Node{Uuid: source.Uuid}
+	metaNode.SetMeta(common.META_NAMESPACE_RECYCLE_RESTORE, restorePath)
+	metaNode.SetMeta(common.META_NAMESPACE_RECYCLE_TIME, time.Now().Unix())
+	metaClient := tree.NewNodeReceiverClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_META, defaults.NewClient())
+	if _, err := metaClient.UpdateNode(ctx, &tree.UpdateNodeRequest{From: metaNode, To: metaNode}); err != nil {
+		log.Logger(ctx).Error("Cannot store recycle bin metadata", zap.String("uuid", source.Uuid), zap.Error(err))
+		return nil, err
+	}
+
+	return &tree.DeleteNodeResponse{Success: true}, nil
+}
+
+// IsInRecycleBin checks if the path is the recycle bin at @recycleRoot or is inside it. Folders named after
+// the recycle bin elsewhere in the workspace are not recycle bins.
+func IsInRecycleBin(nodePath string, recycleRoot string) bool {
+	nodePath = strings.Trim(nodePath, "/")
+	recycleRoot = strings.Trim(recycleRoot, "/")
+	return nodePath == recycleRoot || strings.HasPrefix(nodePath, recycleRoot+"/")
+}
+
+// UniqueRecyclePath appends the current time and a random string to the node name, to avoid
+// clashing with an existing node when moving to or restoring from the recycle bin.
+func UniqueRecyclePath(nodePath string) string {
+	ext := path.Ext(nodePath)
+	return fmt.Sprintf("%s-%d-%s%s", strings.TrimSuffix(nodePath, ext), time.Now().Unix(), uuid.New()[0:8], ext)
+}
+
+// recycleRoot computes the path of the recycle bin holding the deleted node. It is located at
+// the root of the workspace the node belongs to (or of the workspace root node when the
+// workspace has many). In admin views, the closest parent that is a workspace root is used,
+// and the root of the datasource if there is none.
+func (r *RecycleBinHandler) recycleRoot(ctx context.Context, nodePath string, node *tree.Node) (string, bool) {
+
+	parts := strings.Split(nodePath, "/")
+	if admin, a := ctx.Value(ctxAdminContextKey{}).(bool); admin && a {
+		if len(parts) < 2 {
+			return "", false
+		}
+		ancestors, wsRoots := r.workspaceRoots(ctx, node)
+		return adminRecycleRoot(nodePath, ancestors, wsRoots)
+	}
+	accessList, ok := ctx.Value(ctxUserAccessListKey{}).(*utils.AccessList)
+	if !ok {
+		return "", false
+	}
+	for _, ws := range accessList.Workspaces {
+		if ws.Slug != parts[0] {
+			continue
+		}
+		if len(ws.RootNodes) > 1 {
+			if len(parts) < 3 {
+				return "", false
+			}
+			return parts[0] + "/" + parts[1] + "/" + common.PYDIO_RECYCLE_BIN_NAME, true
+		}
+		if len(parts) < 2 {
+			return "", false
+		}
+		return parts[0] + "/" + common.PYDIO_RECYCLE_BIN_NAME, true
+	}
+	return "", false
+}
+
+// adminRecycleRoot picks the recycle bin of the deepest ancestor being a workspace root, or falls
+// back to the datasource recycle bin.
+func adminRecycleRoot(nodePath string, ancestors []*tree.Node, wsRoots map[string]bool) (string, bool) {
+	parts := strings.Split(nodePath, "/")
+	if len(parts) < 2 {
+		return "", false
+	}
+	root := parts[0]
+	for _, ancestor := range ancestors {
+		ancestorPath := strings.Trim(ancestor.Path, "/")
+		if !wsRoots[ancestor.Uuid] || !strings.HasPrefix(nodePath, ancestorPath+"/") {
+			continue
+		}
+		if len(ancestorPath) > len(root) {
+			root = ancestorPath
+		}
+	}
+	return root + "/" + common.PYDIO_RECYCLE_BIN_NAME, true
+}
+
+// workspaceRoots loads the ancestors of the node and finds which of them are used as workspaces roots.
+func (r *RecycleBinHandler) workspaceRoots(ctx context.Context, node *tree.Node) (ancestors []*tree.Node, wsRoots map[string]bool) {
+
+	wsRoots = make(map[string]bool)
+	ancestors, err := utils.BuildAncestorsList(ctx, r.clientsPool.GetTreeClient(), &tree.Node{Uuid: node.Uuid})
+	if err != nil || len(ancestors) == 0 {
+		log.Logger(ctx).Debug("Cannot list ancestors, using datasource recycle bin", zap.String("uuid", node.Uuid), zap.Error(err))
+		return
+	}
+	var nodeIds []string
+	for _, ancestor := range ancestors {
+		nodeIds = append(nodeIds, ancestor.Uuid)
+	}
+	q1, _ := ptypes.MarshalAny(&idm.ACLSingleQuery{NodeIDs: nodeIds})
+	q2, _ := ptypes.MarshalAny(&idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: workspaceRootAction}}})
+	aclClient := idm.NewACLServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACL, defaults.NewClient())
+	stream, err := aclClient.SearchACL(ctx, &idm.SearchACLRequest{Query: &service.Query{
+		SubQueries: []*any.Any{q1, q2},
+		Operation:  service.OperationType_AND,
+	}})
+	if err != nil {
+		log.Logger(ctx).Error("Cannot search workspaces roots, using datasource recycle bin", zap.Error(err))
+		return
+	}
+	defer stream.Close()
+	for {
+		resp, e := stream.Recv()
+		if e != nil {
+			break
+		}
+		if resp.ACL.WorkspaceID != "" {
+			wsRoots[resp.ACL.NodeID] = true
+		}
+	}
+	return
+}
+
+// restorePath finds the full path of the node inside the tree, independently of the current view.
+func (r *RecycleBinHandler) restorePath(ctx context.Context, node *tree.Node) (string, error) {
+	resp, err := r.clientsPool.GetTreeClient().ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Uuid: node.Uuid}})
+	if err != nil {
+		return "", err
+	}
+	return resp.Node.Path, nil
+}
+
+// ensureRecycleRoot creates the recycle bin folder if it does not exist yet.
+func (r *RecycleBinHandler) ensureRecycleRoot(ctx context.Context, recycleRoot string) error {
+	if _, err := r.next.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: recycleRoot}}); err == nil {
+		return nil
+	}
+	_, err := r.next.CreateNode(ctx, &tree.CreateNodeRequest{Node: &tree.Node{Path: recycleRoot, Type: tree.NodeType_COLLECTION}})
+	return err
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package views
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/utils"
+)
+
+func TestRecycleBinHandler_recycleRoot(t *testing.T) {
+
+	handler := &RecycleBinHandler{}
+
+	Convey("Test recycle root in admin context", t, func() {
+		ancestors := []*tree.Node{
+			{Uuid: "ws-sub", Path: "ds/folder/sub"},
+			{Uuid: "folder", Path: "ds/folder"},
+			{Uuid: "ws-root", Path: "ds"},
+		}
+		root, ok := adminRecycleRoot("ds/folder/file.txt", ancestors[1:], map[string]bool{})
+		So(ok, ShouldBeTrue)
+		So(root, ShouldEqual, "ds/"+common.PYDIO_RECYCLE_BIN_NAME)
+
+		root, ok = adminRecycleRoot("ds/folder/sub/file.txt", ancestors, map[string]bool{"ws-root": true, "ws-sub": true})
+		So(ok, ShouldBeTrue)
+		So(root, ShouldEqual, "ds/folder/sub/"+common.PYDIO_RECYCLE_BIN_NAME)
+
+		root, ok = adminRecycleRoot("ds/folder/file.txt", ancestors[1:], map[string]bool{"folder": true})
+		So(ok, ShouldBeTrue)
+		So(root, ShouldEqual, "ds/folder/"+common.PYDIO_RECYCLE_BIN_NAME)
+
+		_, ok = adminRecycleRoot("ds", nil, map[string]bool{})
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test recycle root in user context", t, func() {
+		accessList := &utils.AccessList{Workspaces: map[string]*idm.Workspace{
+			"ws1": {UUID: "ws1", Slug: "single", RootNodes: []string{"root1"}},
+			"ws2": {UUID: "ws2", Slug: "multiple", RootNodes: []string{"root1", "root2"}},
+		}}
+		ctx := context.WithValue(context.Background(), ctxUserAccessListKey{}, accessList)
+
+		root, ok := handler.recycleRoot(ctx, "single/folder/file.txt", &tree.Node{})
+		So(ok, ShouldBeTrue)
+		So(root, ShouldEqual, "single/"+common.PYDIO_RECYCLE_BIN_NAME)
+
+		root, ok = handler.recycleRoot(ctx, "multiple/root2/file.txt", &tree.Node{})
+		So(ok, ShouldBeTrue)
+		So(root, ShouldEqual, "multiple/root2/"+common.PYDIO_RECYCLE_BIN_NAME)
+
+		_, ok = handler.recycleRoot(ctx, "multiple/root2", &tree.Node{})
+		So(ok, ShouldBeFalse)
+		_, ok = handler.recycleRoot(ctx, "unknown/file.txt", &tree.Node{})
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestRecycleBinPaths(t *testing.T) {
+
+	Convey("Test recycle bin detection", t, func() {
+		bin := "ds/" + common.PYDIO_RECYCLE_BIN_NAME
+		So(IsInRecycleBin(bin, bin), ShouldBeTrue)
+		So(IsInRecycleBin(bin+"/folder/file.txt", bin), ShouldBeTrue)
+		So(IsInRecycleBin("ds/"+common.PYDIO_RECYCLE_BIN_NAME+"-old/file.txt", bin), ShouldBeFalse)
+		So(IsInRecycleBin("ds/folder/"+common.PYDIO_RECYCLE_BIN_NAME+"/file.txt", bin), ShouldBeFalse)
+	})
+
+	Convey("Test unique names", t, func() {
+		first := UniqueRecyclePath("ds/folder/file.txt")
+		second := UniqueRecyclePath("ds/folder/file.txt")
+		So(first, ShouldStartWith, "ds/folder/file-")
+		So(first, ShouldEndWith, ".txt")
+		So(first, ShouldNotEqual, second)
+	})
+}
+
+func TestRecycleBinHandler_DeleteNode(t *testing.T) {
+
+	Convey("Test deletions passing through the recycle bin", t, func() {
+		handler := &RecycleBinHandler{}
+		mock := NewHandlerMock()
+		handler.SetNextHandler(mock)
+		ctx := context.WithValue(context.Background(), ctxAdminContextKey{}, true)
+
+		_, e := handler.DeleteNode(WithPermanentDelete(ctx), &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/file.txt"}})
+		So(e, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/file.txt")
+
+		accessList := &utils.AccessList{Workspaces: map[string]*idm.Workspace{
+			"ws1": {UUID: "ws1", Slug: "ws", RootNodes: []string{"root1"}},
+		}}
+		userCtx := context.WithValue(context.Background(), ctxUserAccessListKey{}, accessList)
+		trashed := "ws/" + common.PYDIO_RECYCLE_BIN_NAME + "/file.txt"
+		mock.Nodes[trashed] = &tree.Node{Path: trashed, Type: tree.NodeType_LEAF}
+		_, e = handler.DeleteNode(userCtx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: trashed}})
+		So(e, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, trashed)
+
+		_, e = handler.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/folder/" + common.PYDIO_SYNC_HIDDEN_FILE_META}})
+		So(e, ShouldBeNil)
+		So(mock.Nodes["in"].Path, ShouldEqual, "ds/folder/"+common.PYDIO_SYNC_HIDDEN_FILE_META)
+
+		_, e = handler.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: "ds/missing.txt"}})
+		So(e, ShouldNotBeNil)
+	})
+}
@@ -83,7 +83,12 @@ func (h *UuidNodeHandler) updateInputBranch(ctx context.Context, node *tree.Node
 		}
 	}
 
-	parents, err := utils.BuildAncestorsList(ctx, h.clientsPool.GetTreeClient(), node)
+	searchFunc := utils.BuildAncestorsList
+	if node.Uuid == "" {
+		// Node is not created yet (e.g. PutObject on a new path), find its existing parents
+		searchFunc = utils.BuildAncestorsListOrParent
+	}
+	parents, err := searchFunc(ctx, h.clientsPool.GetTreeClient(), node)
 	if err != nil {
 		return ctx, node, err
 	}
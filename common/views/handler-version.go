@@ -25,9 +25,11 @@ import (
 	"io"
 
 	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
 	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/chunks"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
@@ -82,6 +84,8 @@ func (v *VersionHandler) ListNodes(ctx context.Context, in *tree.ListNodesReques
 				vNode.Size = vResp.Version.Size
 				vNode.SetMeta("versionId", vResp.Version.Uuid)
 				vNode.SetMeta("versionDescription", vResp.Version.Description)
+				vNode.SetMeta("versionLabel", vResp.Version.Label)
+				vNode.SetMeta("versionPinned", vResp.Version.Pinned)
 				streamer.Send(&tree.ListNodesResponse{
 					Node: vNode,
 				})
@@ -148,6 +152,14 @@ func (v *VersionHandler) GetObject(ctx context.Context, node *tree.Node, request
 			Path: node.Uuid + "__" + requestData.VersionId,
 		}
 		node.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, node.Path)
+		// Deduplicated versions are reassembled from their chunks
+		store := chunks.NewStore(source.Client, source.ObjectsBucket)
+		if manifest, e := store.Manifest(node.Path); e != nil {
+			return nil, e
+		} else if manifest != nil {
+			log.Logger(ctx).Debug("GetObject With VersionId from chunks", zap.Any("node", node), zap.Int("chunks", len(manifest.Chunks)))
+			return store.Open(manifest, requestData.StartOffset, requestData.Length)
+		}
 		branchInfo := BranchInfo{LoadedSource: source}
 		ctx = WithBranchInfo(ctx, "in", branchInfo)
 		log.Logger(ctx).Debug("GetObject With VersionId", zap.Any("node", node))
@@ -185,6 +197,31 @@ func (v *VersionHandler) CopyObject(ctx context.Context, from *tree.Node, to *tr
 			Path: from.Uuid + "__" + requestData.SrcVersionId,
 		}
 		from.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, from.Path)
+		// Deduplicated versions are reassembled from their chunks and written to the target
+		store := chunks.NewStore(source.Client, source.ObjectsBucket)
+		if manifest, e := store.Manifest(from.Path); e != nil {
+			return 0, e
+		} else if manifest != nil {
+			destInfo, ok := GetBranchInfo(ctx, "to")
+			if !ok {
+				return 0, errors.InternalServerError(VIEWS_LIBRARY_NAME, "Cannot find Client for dest")
+			}
+			reader, e := store.Open(manifest, 0, -1)
+			if e != nil {
+				return 0, e
+			}
+			defer reader.Close()
+			var content io.Reader = reader
+			if requestData.Progress != nil {
+				content = &progressReader{source: reader, progress: requestData.Progress}
+			}
+			log.Logger(ctx).Debug("CopyObject With VersionId from chunks", zap.Any("from", from), zap.Any("to", to))
+			return v.next.PutObject(WithBranchInfo(ctx, "in", destInfo), to, content, &PutRequestData{
+				Size:               manifest.Size,
+				Metadata:           requestData.Metadata,
+				EncryptionMaterial: requestData.destEncryptionMaterial,
+			})
+		}
 		branchInfo := BranchInfo{LoadedSource: source}
 		ctx = WithBranchInfo(ctx, "from", branchInfo)
 		log.Logger(ctx).Debug("CopyObject With VersionId", zap.Any("from", from), zap.Any("branchInfo", branchInfo), zap.Any("to", to))
@@ -28,6 +28,7 @@ import (
 	"github.com/pydio/minio-go"
 
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/config"
 	"github.com/pydio/cells/common/proto/tree"
 )
 
@@ -39,6 +40,9 @@ type RouterOptions struct {
 	BrowseVirtualNodes bool
 	// AuditEvent flag turns audit logger ON for the corresponding router.
 	AuditEvent bool
+	// RecycleBin flag turns deletions into moves to the workspace recycle bin. User views
+	// always use it, unless the "recycleBin" option of the tree service is set to false.
+	RecycleBin bool
 }
 
 // NewStandardRouter returns a new configured instance of the default standard router.
@@ -56,6 +60,12 @@ func NewStandardRouter(options RouterOptions) *Router {
 		},
 	}
 	handlers = append(handlers, &ArchiveHandler{})
+	if options.RecycleBin || (!options.AdminView && config.Get("services", common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, "recycleBin").Bool(true)) {
+		handlers = append(handlers, &RecycleBinHandler{})
+	}
+	if !options.AdminView {
+		handlers = append(handlers, NewFederatedHandler())
+	}
 	handlers = append(handlers, NewPathWorkspaceHandler())
 	handlers = append(handlers, NewPathMultipleRootsHandler())
 	if !options.BrowseVirtualNodes && !options.AdminView {
@@ -71,7 +81,9 @@ func NewStandardRouter(options RouterOptions) *Router {
 		handlers = append(handlers, &HandlerAuditEvent{})
 	}
 	if !options.AdminView {
+		handlers = append(handlers, &FileDropFilter{})
 		handlers = append(handlers, &AclFilterHandler{})
+		handlers = append(handlers, &LinkLimitsFilter{})
 	}
 	if options.LogReadEvents {
 		handlers = append(handlers, &HandlerEventRead{})
@@ -104,7 +116,9 @@ func NewUuidRouter(options RouterOptions) *Router {
 	}
 
 	if !options.AdminView {
+		handlers = append(handlers, &FileDropFilter{})
 		handlers = append(handlers, &AclFilterHandler{})
+		handlers = append(handlers, &LinkLimitsFilter{})
 	}
 	handlers = append(handlers, &PutHandler{}) // adds a node precreation on PUT file request
 	if !options.AdminView {
@@ -75,6 +75,7 @@ const (
 	AUDIT_LINK_READ   = "76"
 	AUDIT_LINK_UPDATE = "77"
 	AUDIT_LINK_DELTE  = "78"
+	AUDIT_LINK_ACCESS = "79"
 
 	/* BACK END */
 
@@ -139,10 +140,12 @@ const (
 	KEY_TASK_ID = "TaskId"
 
 	// CELLS
-	KEY_CELL      = "Cell"
-	KEY_CELL_UUID = "CellUuid"
-	KEY_LINK      = "ShareLink"
-	KEY_LINK_UUID = "ShareLinkUuid"
+	KEY_CELL       = "Cell"
+	KEY_CELL_UUID  = "CellUuid"
+	KEY_LINK       = "ShareLink"
+	KEY_LINK_UUID  = "ShareLinkUuid"
+	KEY_LINK_HASH  = "ShareLinkHash"
+	KEY_LINK_OWNER = "ShareLinkOwner"
 
 	// CHAT
 	KEY_CHAT_ROOM          = "ChatRoom"
@@ -173,5 +176,6 @@ var (
 		AUDIT_NODE_DELETE:   "Delete Node",
 		AUDIT_OBJECT_GET:    "Get Object",
 		AUDIT_OBJECT_PUT:    "Put Object",
+		AUDIT_LINK_ACCESS:   "Access Link",
 	}
 )
@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS data_changes_archive (
+    seq BIGINT PRIMARY KEY,
+    node_id varchar(255),
+    type varchar(10) CHECK (type IN ('create', 'delete', 'path', 'content')),
+    source text,
+    target text
+);
+
+-- +migrate Down
+DROP TABLE data_changes_archive;
@@ -55,7 +55,7 @@ var (
 		"selectFromArchive": `SELECT seq, node_id, type, source, target FROM data_changes_archive WHERE seq > ? and (source LIKE ? or target LIKE ?) ORDER BY node_id,seq`,
 		"firstSeq":          `SELECT MIN(seq) FROM data_changes`,
 		"lastSeq":           `SELECT MAX(seq) FROM data_changes`,
-		"nodeById":          `SELECT node_id FROM data_changes WHERE node_id = ? LIMIT 0,1`,
+		"nodeById":          `SELECT node_id FROM data_changes WHERE node_id = ? LIMIT 1`,
 		"delete":            `DELETE FROM data_changes where seq <= ?`,
 		"archive":           `INSERT INTO data_changes_archive (seq, node_id, type, source, target) SELECT seq, node_id, type, source, target from data_changes where seq <= ?`,
 	}
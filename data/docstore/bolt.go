@@ -111,6 +111,36 @@ func (s *BoltStore) PutDocument(storeID string, doc *docstore.Document) error {
 
 }
 
+func (s *BoltStore) PutDocumentIfMatch(storeID string, doc *docstore.Document, ifMatchData string) error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		bucket, err := s.GetStore(tx, storeID, "write")
+		if err != nil {
+			return err
+		}
+		current := &docstore.Document{}
+		if data := bucket.Get([]byte(doc.ID)); data != nil {
+			if err := json.Unmarshal(data, current); err != nil {
+				return errors.InternalServerError(common.SERVICE_DOCSTORE, "Cannot deserialize document")
+			}
+			if ifMatchData == "" {
+				return errors.Conflict(common.SERVICE_DOCSTORE, "Document already exists")
+			}
+		}
+		if current.Data != ifMatchData {
+			return errors.Conflict(common.SERVICE_DOCSTORE, "Document was modified")
+		}
+		jsonData, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(doc.ID), jsonData)
+
+	})
+
+}
+
 func (s *BoltStore) GetDocument(storeID string, docId string) (*docstore.Document, error) {
 
 	j := &docstore.Document{}
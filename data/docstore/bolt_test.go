@@ -24,7 +24,10 @@ import (
 	"os"
 	"testing"
 
+	"github.com/micro/go-micro/errors"
 	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/docstore"
 )
 
 func TestNewBoltStore(t *testing.T) {
@@ -43,3 +46,32 @@ func TestNewBoltStore(t *testing.T) {
 
 	})
 }
+
+func TestPutDocumentIfMatch(t *testing.T) {
+
+	Convey("Test conditional document update", t, func() {
+
+		bs, e := NewBoltStore(newPath("bolt-test2.db"), true)
+		So(e, ShouldBeNil)
+		defer bs.Close()
+
+		e = bs.PutDocumentIfMatch("store", &docstore.Document{ID: "doc", Data: `{"count":1}`}, `{"count":0}`)
+		So(e, ShouldNotBeNil)
+		So(errors.Parse(e.Error()).Code, ShouldEqual, 409)
+
+		So(bs.PutDocumentIfMatch("store", &docstore.Document{ID: "doc", Data: `{"count":0}`}, ""), ShouldBeNil)
+		e = bs.PutDocumentIfMatch("store", &docstore.Document{ID: "doc", Data: `{"count":0}`}, "")
+		So(e, ShouldNotBeNil)
+		So(errors.Parse(e.Error()).Code, ShouldEqual, 409)
+		So(bs.PutDocumentIfMatch("store", &docstore.Document{ID: "doc", Data: `{"count":1}`}, `{"count":0}`), ShouldBeNil)
+
+		e = bs.PutDocumentIfMatch("store", &docstore.Document{ID: "doc", Data: `{"count":1}`}, `{"count":0}`)
+		So(e, ShouldNotBeNil)
+		So(errors.Parse(e.Error()).Code, ShouldEqual, 409)
+
+		doc, e := bs.GetDocument("store", "doc")
+		So(e, ShouldBeNil)
+		So(doc.Data, ShouldEqual, `{"count":1}`)
+
+	})
+}
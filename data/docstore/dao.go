@@ -32,6 +32,9 @@ import (
 
 type Store interface {
 	PutDocument(storeID string, doc *docstore.Document) error
+	// PutDocumentIfMatch stores a document only if its current Data is equal to ifMatchData, an empty
+	// ifMatchData meaning that the document must not exist yet. It returns a Conflict error otherwise.
+	PutDocumentIfMatch(storeID string, doc *docstore.Document, ifMatchData string) error
 	GetDocument(storeID string, docId string) (*docstore.Document, error)
 	DeleteDocument(storeID string, docID string) error
 	ListDocuments(storeID string, query *docstore.DocumentQuery) (chan *docstore.Document, chan bool, error)
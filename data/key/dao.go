@@ -34,6 +34,7 @@ type DAO interface {
 	DeleteNode(nodeUuid string) error
 	SetNodeKey(nodeUuid string, ownerId string, userId string, keyData []byte) error
 	GetNodeKey(node string, user string) (*encryption.NodeKey, error)
+	GetNodeOwnerKey(node string) (*encryption.NodeKey, error)
 	DeleteNodeKey(node string, user string) error
 	DeleteNodeSharedKey(node string, ownerId string, userId string) error
 	DeleteNodeAllSharedKey(node string, ownerId string) error
@@ -81,6 +81,16 @@ func TestSqlimpl_SetNodeKey3(t *testing.T) {
 	})
 }
 
+func TestSqlimpl_SetNodeKeyReplace(t *testing.T) {
+	convey.Convey("Replace node share key 2", t, func() {
+		err := mockDAO.SetNodeKey("node_id", "pydio", "user-2", []byte("new-key"))
+		convey.So(err, convey.ShouldBeNil)
+		k, err := mockDAO.GetNodeKey("node_id", "user-2")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(string(k.Data), convey.ShouldEqual, "new-key")
+	})
+}
+
 func TestSqlimpl_GetNodeKey(t *testing.T) {
 	convey.Convey("Get node key", t, func() {
 		k, err := mockDAO.GetNodeKey("node_id", "pydio")
@@ -89,6 +99,16 @@ func TestSqlimpl_GetNodeKey(t *testing.T) {
 	})
 }
 
+func TestSqlimpl_GetNodeOwnerKey(t *testing.T) {
+	convey.Convey("Get node owner key", t, func() {
+		k, err := mockDAO.GetNodeOwnerKey("node_id")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(k, convey.ShouldNotBeNil)
+		convey.So(k.UserId, convey.ShouldEqual, "pydio")
+		convey.So(k.OwnerId, convey.ShouldEqual, "pydio")
+	})
+}
+
 func TestSqlimpl_DeleteNodeSharedKey(t *testing.T) {
 	convey.Convey("Get node key", t, func() {
 		err := mockDAO.DeleteNodeSharedKey("node_id", "pydio", "user-1")
@@ -84,7 +84,13 @@ func (km *NodeKeyManagerHandler) GetNodeKey(ctx context.Context, req *encryption
 	}
 	keyDao := dao.(key.DAO)
 
-	r, err := keyDao.GetNodeKey(req.NodeId, req.UserId)
+	var r *encryption.NodeKey
+	var err error
+	if req.UserId == "" {
+		r, err = keyDao.GetNodeOwnerKey(req.NodeId)
+	} else {
+		r, err = keyDao.GetNodeKey(req.NodeId, req.UserId)
+	}
 	if err != nil {
 		return err
 	}
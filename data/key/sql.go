@@ -21,6 +21,7 @@
 package key
 
 import (
+	databasesql "database/sql"
 	"sync/atomic"
 
 	"github.com/gobuffalo/packr"
@@ -42,6 +43,7 @@ var (
 		"enc_node_keys_deleteShared":    `DELETE FROM enc_node_keys WHERE user_id<>owner_id AND node_id=? AND owner_id=? AND user_id=?`,
 		"enc_node_keys_deleteAllShared": `DELETE FROM enc_node_keys WHERE  user_id<>owner_id AND node_id=? AND owner_id=?`,
 		"selectNodeKey":                 `SELECT enc_nodes.node_id, user_id, owner_id, nonce, block_size, key_data FROM enc_node_keys, enc_nodes WHERE enc_nodes.node_id=enc_node_keys.node_id AND enc_node_keys.node_id=? AND user_id=?`,
+		"selectNodeOwnerKey":            `SELECT enc_nodes.node_id, user_id, owner_id, nonce, block_size, key_data FROM enc_node_keys, enc_nodes WHERE enc_nodes.node_id=enc_node_keys.node_id AND enc_node_keys.node_id=? AND user_id=owner_id`,
 	}
 	mu atomic.Value
 )
@@ -110,6 +112,11 @@ func (h *sqlimpl) DeleteNode(nodeUuid string) error {
 
 func (h *sqlimpl) SetNodeKey(nodeUuid string, ownerId string, userId string, keyData []byte) error {
 
+	// A user holds at most one key per node: replace any previous one
+	if _, err := h.GetStmt("enc_node_keys_delete").Exec(nodeUuid, userId); err != nil {
+		return err
+	}
+
 	_, err := h.GetStmt("enc_node_keys_insert").Exec(
 		nodeUuid,
 		ownerId,
@@ -128,6 +135,22 @@ func (h *sqlimpl) GetNodeKey(node string, user string) (*encryption.NodeKey, err
 	if err != nil {
 		return nil, errors.New("NodeKey", "cannot retrieve node key", 500)
 	}
+	return h.scanNodeKey(rows)
+}
+
+// GetNodeOwnerKey finds the key of the user owning the node content.
+func (h *sqlimpl) GetNodeOwnerKey(node string) (*encryption.NodeKey, error) {
+
+	rows, err := h.GetStmt("selectNodeOwnerKey").Query(
+		node,
+	)
+	if err != nil {
+		return nil, errors.New("NodeKey", "cannot retrieve node key", 500)
+	}
+	return h.scanNodeKey(rows)
+}
+
+func (h *sqlimpl) scanNodeKey(rows *databasesql.Rows) (*encryption.NodeKey, error) {
 	defer rows.Close()
 
 	var k encryption.NodeKey
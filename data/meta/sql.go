@@ -33,12 +33,15 @@ import (
 )
 
 var (
-	queries = map[string]string{
-		"upsert":     `INSERT INTO data_meta (node_id,namespace,data,author,timestamp,format) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE data=?,author=?,timestamp=?,format=?`,
-		"deleteNS":   `DELETE FROM data_meta WHERE namespace=?`,
+	queries = map[string]interface{}{
+		"upsert": sql.DriverQueries{
+			"":         `INSERT INTO data_meta (node_id,namespace,data,author,timestamp,format) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE data=?,author=?,timestamp=?,format=?`,
+			"postgres": `INSERT INTO data_meta (node_id,namespace,data,author,timestamp,format) VALUES (?,?,?,?,?,?) ON CONFLICT (node_id,namespace) DO UPDATE SET data=?,author=?,timestamp=?,format=?`,
+		},
+		"deleteNS":   `DELETE FROM data_meta WHERE node_id=? AND namespace=?`,
 		"deleteUuid": `DELETE FROM data_meta WHERE node_id=?`,
 		"select":     `SELECT * FROM data_meta WHERE node_id=?`,
-		"selectAll":  `SELECT * FROM data_meta LIMIT 0, 500`,
+		"selectAll":  `SELECT * FROM data_meta LIMIT 500`,
 	}
 	mu atomic.Value
 )
@@ -95,7 +98,7 @@ func (h *sqlimpl) SetMetadata(nodeId string, author string, metadata map[string]
 			ns := namespace
 			if data == "" {
 				// Delete namespace
-				h.GetStmt("deleteNS").Exec(ns)
+				h.GetStmt("deleteNS").Exec(nodeId, ns)
 			} else {
 				// Insert or update namespace
 				tStamp := time.Now().Unix()
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package quota
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/micro/go-micro/client"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+var (
+	recomputeUsagesActionName = "actions.quota.recompute"
+)
+
+// RecomputeUsagesAction asks the quota service to check its nodes against the tree and rebuild the users usages.
+type RecomputeUsagesAction struct {
+	Client quota.UsageServiceClient
+}
+
+// GetName returns this action unique identifier
+func (c *RecomputeUsagesAction) GetName() string {
+	return recomputeUsagesActionName
+}
+
+// Init passes parameters to the action
+func (c *RecomputeUsagesAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
+	if c.Client == nil {
+		c.Client = quota.NewUsageServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_QUOTA, defaults.NewClient())
+	}
+	return nil
+}
+
+// Run the actual action code
+func (c *RecomputeUsagesAction) Run(ctx context.Context, channels *actions.RunnableChannels, input jobs.ActionMessage) (jobs.ActionMessage, error) {
+
+	resp, err := c.Client.RecomputeUsages(ctx, &quota.RecomputeUsagesRequest{})
+	if err != nil {
+		return input.WithError(err), err
+	}
+
+	input.AppendOutput(&jobs.ActionOutput{
+		Success:    true,
+		StringBody: fmt.Sprintf("Checked %d files: %d updated, %d removed", resp.Checked, resp.Updated, resp.Removed),
+	})
+	return input, nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+package quota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/micro/go-micro/client"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/quota"
+)
+
+type usageClientMock struct {
+	quota.UsageServiceClient
+	calls int
+}
+
+func (u *usageClientMock) RecomputeUsages(ctx context.Context, in *quota.RecomputeUsagesRequest, opts ...client.CallOption) (*quota.RecomputeUsagesResponse, error) {
+	u.calls++
+	return &quota.RecomputeUsagesResponse{Checked: 3, Updated: 1, Removed: 1}, nil
+}
+
+func TestRecomputeUsagesAction(t *testing.T) {
+
+	convey.Convey("Test recompute action", t, func() {
+		mock := &usageClientMock{}
+		action := &RecomputeUsagesAction{Client: mock}
+		convey.So(action.GetName(), convey.ShouldEqual, recomputeUsagesActionName)
+		convey.So(action.Init(&jobs.Job{}, nil, &jobs.Action{}), convey.ShouldBeNil)
+
+		output, err := action.Run(context.Background(), nil, jobs.ActionMessage{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(mock.calls, convey.ShouldEqual, 1)
+		convey.So(output.GetLastOutput().StringBody, convey.ShouldEqual, "Checked 3 files: 1 updated, 1 removed")
+	})
+}
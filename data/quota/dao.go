@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package quota maintains the storage usage of each user, computed incrementally from the tree changes.
+package quota
+
+import (
+	"github.com/pydio/cells/common/dao"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/sql"
+)
+
+// Node is a file accounted to the usage of its owner.
+type Node struct {
+	ID    string
+	Owner string
+	Path  string
+	Size  int64
+}
+
+type DAO interface {
+	dao.DAO
+
+	SetNodeSize(nodeId string, owner string, nodePath string, size int64) error
+	MoveNode(nodeId string, fromPath string, toPath string) error
+	DeleteNode(nodeId string, nodePath string) error
+	ListNodes(afterId string, limit int32) ([]*Node, error)
+	RecomputeUsages() error
+	GetUsage(owner string) (*quota.UserUsage, error)
+	ListUsages(limit int32, offset int32) ([]*quota.UserUsage, error)
+}
+
+func NewDAO(o dao.DAO) dao.DAO {
+	switch v := o.(type) {
+	case sql.DAO:
+		return &sqlimpl{DAO: v}
+	}
+	return nil
+}
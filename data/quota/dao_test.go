@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package quota
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/sql"
+)
+
+var (
+	mockDAO DAO
+)
+
+func TestMain(m *testing.M) {
+	var options config.Map
+
+	sqlDAO := sql.NewDAO("sqlite3", "file::memory:?mode=memory&cache=shared", "test")
+	if sqlDAO == nil {
+		fmt.Print("Could not start test")
+		return
+	}
+
+	mockDAO = NewDAO(sqlDAO).(DAO)
+	if err := mockDAO.Init(options); err != nil {
+		fmt.Print("Could not start test ", err)
+		return
+	}
+
+	m.Run()
+}
+
+func TestSqlimpl_Usage(t *testing.T) {
+
+	convey.Convey("Test unknown user", t, func() {
+		usage, err := mockDAO.GetUsage("unknown")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Login, convey.ShouldEqual, "unknown")
+		convey.So(usage.Bytes, convey.ShouldEqual, 0)
+		convey.So(usage.Files, convey.ShouldEqual, 0)
+	})
+
+	convey.Convey("Test nodes creation", t, func() {
+		convey.So(mockDAO.SetNodeSize("node1", "user1", "ds/node1", 100), convey.ShouldBeNil)
+		convey.So(mockDAO.SetNodeSize("node2", "user1", "ds/folder/node2", 50), convey.ShouldBeNil)
+		convey.So(mockDAO.SetNodeSize("node3", "user2", "ds/folder/sub/node3", 500), convey.ShouldBeNil)
+		// Unknown node without owner is ignored
+		convey.So(mockDAO.SetNodeSize("node4", "", "ds/node4", 1000), convey.ShouldBeNil)
+
+		usage, err := mockDAO.GetUsage("user1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 150)
+		convey.So(usage.Files, convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Test nodes update", t, func() {
+		// Size delta is applied to the original owner
+		convey.So(mockDAO.SetNodeSize("node1", "user2", "ds/node1", 300), convey.ShouldBeNil)
+
+		usage, err := mockDAO.GetUsage("user1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 350)
+		convey.So(usage.Files, convey.ShouldEqual, 2)
+
+		usage, err = mockDAO.GetUsage("user2")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 500)
+		convey.So(usage.Files, convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Test list usages", t, func() {
+		usages, err := mockDAO.ListUsages(10, 0)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usages, convey.ShouldHaveLength, 2)
+		convey.So(usages[0].Login, convey.ShouldEqual, "user2")
+		convey.So(usages[1].Login, convey.ShouldEqual, "user1")
+
+		usages, err = mockDAO.ListUsages(1, 1)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usages, convey.ShouldHaveLength, 1)
+		convey.So(usages[0].Login, convey.ShouldEqual, "user1")
+	})
+
+	convey.Convey("Test nodes deletion", t, func() {
+		convey.So(mockDAO.DeleteNode("node1", ""), convey.ShouldBeNil)
+		convey.So(mockDAO.DeleteNode("unknown-node", ""), convey.ShouldBeNil)
+
+		usage, err := mockDAO.GetUsage("user1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 50)
+		convey.So(usage.Files, convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Test folder move", t, func() {
+		convey.So(mockDAO.MoveNode("folder", "ds/folder", "ds/moved"), convey.ShouldBeNil)
+		nodes, err := mockDAO.ListNodes("", 10)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(nodes, convey.ShouldHaveLength, 2)
+		convey.So(nodes[0].Path, convey.ShouldEqual, "ds/moved/node2")
+		convey.So(nodes[1].Path, convey.ShouldEqual, "ds/moved/sub/node3")
+
+		nodes, err = mockDAO.ListNodes("node2", 10)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(nodes, convey.ShouldHaveLength, 1)
+		convey.So(nodes[0].ID, convey.ShouldEqual, "node3")
+	})
+
+	convey.Convey("Test recursive folder deletion", t, func() {
+		convey.So(mockDAO.DeleteNode("sub", "ds/moved/sub"), convey.ShouldBeNil)
+		usage, err := mockDAO.GetUsage("user2")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 0)
+		convey.So(usage.Files, convey.ShouldEqual, 0)
+
+		usage, err = mockDAO.GetUsage("user1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usage.Bytes, convey.ShouldEqual, 50)
+	})
+
+	convey.Convey("Test usages recomputation", t, func() {
+		convey.So(mockDAO.SetNodeSize("node2", "", "ds/moved/node2", 80), convey.ShouldBeNil)
+		convey.So(mockDAO.RecomputeUsages(), convey.ShouldBeNil)
+		usages, err := mockDAO.ListUsages(10, 0)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(usages, convey.ShouldHaveLength, 1)
+		convey.So(usages[0].Login, convey.ShouldEqual, "user1")
+		convey.So(usages[0].Bytes, convey.ShouldEqual, 80)
+		convey.So(usages[0].Files, convey.ShouldEqual, 1)
+	})
+
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package grpc
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/service/defaults"
+	quota2 "github.com/pydio/cells/data/quota"
+)
+
+const recomputePageSize = 500
+
+// Handler implements the UsageService and maintains the usage counters from the tree events.
+type Handler struct {
+	// TreeClient reads the current state of the nodes when recomputing usages
+	TreeClient tree.NodeProviderClient
+}
+
+// HandleTreeChanges updates the usage of the files owners. New files are accounted to the user
+// who created them, subsequent content updates are accounted to the same user. Deleting or moving
+// a folder applies to all the known files below it.
+func (h *Handler) HandleTreeChanges(ctx context.Context, msg *tree.NodeChangeEvent) error {
+
+	dao, e := h.getDAO(ctx)
+	if e != nil {
+		return e
+	}
+
+	switch msg.Type {
+	case tree.NodeChangeEvent_CREATE, tree.NodeChangeEvent_UPDATE_CONTENT:
+		target := msg.Target
+		if target == nil || !target.IsLeaf() || path.Base(target.Path) == common.PYDIO_SYNC_HIDDEN_FILE_META {
+			return nil
+		}
+		author := eventAuthor(ctx)
+		if author == common.PYDIO_SYSTEM_USERNAME {
+			// Ignore events triggered by initial sync
+			author = ""
+		}
+		if err := dao.SetNodeSize(target.Uuid, author, target.Path, target.Size); err != nil {
+			log.Logger(ctx).Error("Cannot update usage", target.Zap(), zap.Error(err))
+			return err
+		}
+	case tree.NodeChangeEvent_UPDATE_PATH:
+		if msg.Source == nil || msg.Target == nil || msg.Source.Path == "" || msg.Target.Path == "" {
+			return nil
+		}
+		if err := dao.MoveNode(msg.Target.Uuid, msg.Source.Path, msg.Target.Path); err != nil {
+			log.Logger(ctx).Error("Cannot update usage", msg.Target.Zap(), zap.Error(err))
+			return err
+		}
+	case tree.NodeChangeEvent_DELETE:
+		if msg.Source == nil || msg.Source.Uuid == "" {
+			return nil
+		}
+		var folderPath string
+		if !msg.Source.IsLeaf() {
+			folderPath = msg.Source.Path
+		}
+		if err := dao.DeleteNode(msg.Source.Uuid, folderPath); err != nil {
+			log.Logger(ctx).Error("Cannot update usage", msg.Source.Zap(), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// RecomputeUsages checks all the known nodes against the tree, to catch up with missed events: nodes
+// that do not exist anymore are removed and sizes and paths are updated. Usages are then rebuilt
+// from the nodes.
+func (h *Handler) RecomputeUsages(ctx context.Context, req *quota.RecomputeUsagesRequest, rsp *quota.RecomputeUsagesResponse) error {
+	dao, e := h.getDAO(ctx)
+	if e != nil {
+		return e
+	}
+	treeClient := h.TreeClient
+	if treeClient == nil {
+		treeClient = tree.NewNodeProviderClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, defaults.NewClient())
+	}
+
+	var after string
+	for {
+		nodes, err := dao.ListNodes(after, recomputePageSize)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			rsp.Checked++
+			resp, er := treeClient.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Uuid: n.ID}})
+			if er != nil {
+				if errors.Parse(er.Error()).Code != 404 {
+					return er
+				}
+				if err := dao.DeleteNode(n.ID, ""); err != nil {
+					return err
+				}
+				rsp.Removed++
+				continue
+			}
+			if resp.Node.Size != n.Size || resp.Node.Path != n.Path {
+				if err := dao.SetNodeSize(n.ID, n.Owner, resp.Node.Path, resp.Node.Size); err != nil {
+					return err
+				}
+				rsp.Updated++
+			}
+		}
+		if len(nodes) < recomputePageSize {
+			break
+		}
+		after = nodes[len(nodes)-1].ID
+	}
+
+	log.Logger(ctx).Info("Recomputed users usages", zap.Int64("checked", rsp.Checked), zap.Int64("updated", rsp.Updated), zap.Int64("removed", rsp.Removed))
+	return dao.RecomputeUsages()
+}
+
+// GetUsage returns the storage used by a given user.
+func (h *Handler) GetUsage(ctx context.Context, req *quota.GetUsageRequest, rsp *quota.GetUsageResponse) error {
+	dao, e := h.getDAO(ctx)
+	if e != nil {
+		return e
+	}
+	if req.Login == "" {
+		return errors.BadRequest(common.SERVICE_QUOTA, "Please provide a user login")
+	}
+	usage, e := dao.GetUsage(req.Login)
+	if e != nil {
+		return e
+	}
+	rsp.Usage = usage
+	return nil
+}
+
+// ListUsages lists users by descending storage usage.
+func (h *Handler) ListUsages(ctx context.Context, req *quota.ListUsagesRequest, rsp *quota.ListUsagesResponse) error {
+	dao, e := h.getDAO(ctx)
+	if e != nil {
+		return e
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	usages, e := dao.ListUsages(limit, req.Offset)
+	if e != nil {
+		return e
+	}
+	rsp.Usages = usages
+	return nil
+}
+
+func (h *Handler) getDAO(ctx context.Context) (quota2.DAO, error) {
+	dao, ok := servicecontext.GetDAO(ctx).(quota2.DAO)
+	if !ok {
+		return nil, errors.InternalServerError(common.SERVICE_QUOTA, "No DAO found Wrong initialization")
+	}
+	return dao, nil
+}
+
+// eventAuthor finds the user who triggered the event in the context metadata.
+func eventAuthor(ctx context.Context) string {
+	meta, ok := metadata.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if user, exists := meta[common.PYDIO_CONTEXT_USER_KEY]; exists {
+		return user
+	}
+	return meta[strings.ToLower(common.PYDIO_CONTEXT_USER_KEY)]
+}
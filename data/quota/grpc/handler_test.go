@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	. "github.com/smartystreets/goconvey/convey"
+
+	// SQLite Driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/sql"
+	quota2 "github.com/pydio/cells/data/quota"
+)
+
+var (
+	ctx     context.Context
+	options config.Map
+)
+
+func TestMain(m *testing.M) {
+
+	dao := sql.NewDAO("sqlite3", "file::memory:?mode=memory&cache=shared", "")
+	if dao == nil {
+		fmt.Print("Could not start test")
+		return
+	}
+
+	mockDAO := quota2.NewDAO(dao).(quota2.DAO)
+	if err := mockDAO.Init(options); err != nil {
+		fmt.Print("Could not start test ", err)
+		return
+	}
+
+	ctx = servicecontext.WithDAO(context.Background(), mockDAO)
+
+	m.Run()
+}
+
+func userContext(login string) context.Context {
+	return metadata.NewContext(ctx, map[string]string{common.PYDIO_CONTEXT_USER_KEY: login})
+}
+
+func TestHandler_HandleTreeChanges(t *testing.T) {
+
+	h := &Handler{}
+
+	Convey("Test events are accounted to their author", t, func() {
+		So(h.HandleTreeChanges(userContext("user1"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_CREATE,
+			Target: &tree.Node{Uuid: "file1", Path: "ws/file1", Type: tree.NodeType_LEAF, Size: 100},
+		}), ShouldBeNil)
+		So(h.HandleTreeChanges(userContext("user1"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_CREATE,
+			Target: &tree.Node{Uuid: "folder1", Path: "ws/folder1", Type: tree.NodeType_COLLECTION, Size: 1000},
+		}), ShouldBeNil)
+		So(h.HandleTreeChanges(userContext("user1"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_CREATE,
+			Target: &tree.Node{Uuid: "hidden1", Path: "ws/folder1/.pydio", Type: tree.NodeType_LEAF, Size: 36},
+		}), ShouldBeNil)
+		So(h.HandleTreeChanges(userContext(common.PYDIO_SYSTEM_USERNAME), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_CREATE,
+			Target: &tree.Node{Uuid: "synced1", Path: "ws/synced1", Type: tree.NodeType_LEAF, Size: 1000},
+		}), ShouldBeNil)
+		// Content updated by another user is still accounted to the creator
+		So(h.HandleTreeChanges(userContext("user2"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_UPDATE_CONTENT,
+			Target: &tree.Node{Uuid: "file1", Path: "ws/file1", Type: tree.NodeType_LEAF, Size: 150},
+		}), ShouldBeNil)
+
+		rsp := &quota.GetUsageResponse{}
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{Login: "user1"}, rsp), ShouldBeNil)
+		So(rsp.Usage.Bytes, ShouldEqual, 150)
+		So(rsp.Usage.Files, ShouldEqual, 1)
+
+		list := &quota.ListUsagesResponse{}
+		So(h.ListUsages(ctx, &quota.ListUsagesRequest{}, list), ShouldBeNil)
+		So(list.Usages, ShouldHaveLength, 1)
+	})
+
+	Convey("Test deletion", t, func() {
+		So(h.HandleTreeChanges(userContext("user2"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_DELETE,
+			Source: &tree.Node{Uuid: "file1", Path: "ws/file1"},
+		}), ShouldBeNil)
+
+		rsp := &quota.GetUsageResponse{}
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{Login: "user1"}, rsp), ShouldBeNil)
+		So(rsp.Usage.Bytes, ShouldEqual, 0)
+		So(rsp.Usage.Files, ShouldEqual, 0)
+	})
+
+	Convey("Test folder move and deletion", t, func() {
+		So(h.HandleTreeChanges(userContext("user3"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_CREATE,
+			Target: &tree.Node{Uuid: "file3", Path: "ws/folder3/file3", Type: tree.NodeType_LEAF, Size: 30},
+		}), ShouldBeNil)
+		So(h.HandleTreeChanges(userContext("user3"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_UPDATE_PATH,
+			Source: &tree.Node{Uuid: "folder3", Path: "ws/folder3", Type: tree.NodeType_COLLECTION},
+			Target: &tree.Node{Uuid: "folder3", Path: "ws/renamed3", Type: tree.NodeType_COLLECTION},
+		}), ShouldBeNil)
+		// Deleting the folder at its new location removes its children
+		So(h.HandleTreeChanges(userContext("user3"), &tree.NodeChangeEvent{
+			Type:   tree.NodeChangeEvent_DELETE,
+			Source: &tree.Node{Uuid: "folder3", Path: "ws/renamed3", Type: tree.NodeType_COLLECTION},
+		}), ShouldBeNil)
+
+		rsp := &quota.GetUsageResponse{}
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{Login: "user3"}, rsp), ShouldBeNil)
+		So(rsp.Usage.Bytes, ShouldEqual, 0)
+		So(rsp.Usage.Files, ShouldEqual, 0)
+	})
+
+	Convey("Test usages recomputation from the tree", t, func() {
+		for _, n := range []*tree.Node{
+			{Uuid: "file4", Path: "ws/file4", Type: tree.NodeType_LEAF, Size: 40},
+			{Uuid: "file5", Path: "ws/file5", Type: tree.NodeType_LEAF, Size: 50},
+			{Uuid: "file6", Path: "ws/file6", Type: tree.NodeType_LEAF, Size: 60},
+		} {
+			So(h.HandleTreeChanges(userContext("user4"), &tree.NodeChangeEvent{Type: tree.NodeChangeEvent_CREATE, Target: n}), ShouldBeNil)
+		}
+		// file5 was resized and moved, file6 was deleted without the service being notified
+		h.TreeClient = &treeMock{nodes: map[string]*tree.Node{
+			"file4": {Uuid: "file4", Path: "ws/file4", Type: tree.NodeType_LEAF, Size: 40},
+			"file5": {Uuid: "file5", Path: "ws/other/file5", Type: tree.NodeType_LEAF, Size: 500},
+		}}
+		defer func() { h.TreeClient = nil }()
+
+		rsp := &quota.RecomputeUsagesResponse{}
+		So(h.RecomputeUsages(ctx, &quota.RecomputeUsagesRequest{}, rsp), ShouldBeNil)
+		So(rsp.Checked, ShouldEqual, 3)
+		So(rsp.Updated, ShouldEqual, 1)
+		So(rsp.Removed, ShouldEqual, 1)
+
+		usage := &quota.GetUsageResponse{}
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{Login: "user4"}, usage), ShouldBeNil)
+		So(usage.Usage.Bytes, ShouldEqual, 540)
+		So(usage.Usage.Files, ShouldEqual, 2)
+
+		// Tree errors do not remove anything
+		h.TreeClient = &treeMock{err: errors.InternalServerError(common.SERVICE_TREE, "unavailable")}
+		So(h.RecomputeUsages(ctx, &quota.RecomputeUsagesRequest{}, &quota.RecomputeUsagesResponse{}), ShouldNotBeNil)
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{Login: "user4"}, usage), ShouldBeNil)
+		So(usage.Usage.Files, ShouldEqual, 2)
+	})
+
+	Convey("Test missing login", t, func() {
+		So(h.GetUsage(ctx, &quota.GetUsageRequest{}, &quota.GetUsageResponse{}), ShouldNotBeNil)
+	})
+
+}
+
+// treeMock reads nodes by Uuid.
+type treeMock struct {
+	tree.NodeProviderClient
+	nodes map[string]*tree.Node
+	err   error
+}
+
+func (t *treeMock) ReadNode(ctx context.Context, in *tree.ReadNodeRequest, opts ...client.CallOption) (*tree.ReadNodeResponse, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if n, ok := t.nodes[in.Node.Uuid]; ok {
+		return &tree.ReadNodeResponse{Node: n}, nil
+	}
+	return nil, errors.NotFound(common.SERVICE_TREE, "Node not found")
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package grpc provides a pydio GRPC service maintaining users storage usage
+package grpc
+
+import (
+	"github.com/micro/go-micro"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/service"
+	quota2 "github.com/pydio/cells/data/quota"
+)
+
+func init() {
+	service.NewService(
+		service.Name(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_QUOTA),
+		service.Tag(common.SERVICE_TAG_DATA),
+		service.Description("Users storage usage accounting"),
+		service.WithStorage(quota2.NewDAO, "data_quota"),
+		service.WithMicro(func(m micro.Service) error {
+			h := &Handler{}
+			quota.RegisterUsageServiceHandler(m.Options().Server, h)
+			if err := m.Options().Server.Subscribe(m.Options().Server.NewSubscriber(common.TOPIC_TREE_CHANGES, h.HandleTreeChanges)); err != nil {
+				return err
+			}
+			return nil
+		}),
+	)
+}
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE data_quota_nodes ADD COLUMN path VARCHAR(2000) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE data_quota_nodes DROP COLUMN path;
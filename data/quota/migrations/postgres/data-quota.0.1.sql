@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS data_quota_nodes (
+    node_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS data_quota_usage (
+    owner VARCHAR(255) NOT NULL PRIMARY KEY,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    files BIGINT NOT NULL DEFAULT 0
+);
+
+-- +migrate Down
+DROP TABLE data_quota_usage;
+DROP TABLE data_quota_nodes;
@@ -0,0 +1,4 @@
+-- +migrate Up
+ALTER TABLE data_quota_nodes ADD COLUMN path VARCHAR(2000) NOT NULL DEFAULT '';
+
+-- +migrate Down
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package rest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/micro/go-micro/errors"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/proto/rest"
+	"github.com/pydio/cells/common/service"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
+)
+
+// Handler for REST interface to users storage usage
+type Handler struct{}
+
+// SwaggerTags list the names of the service tags declared in the swagger json implemented by this service
+func (h *Handler) SwaggerTags() []string {
+	return []string{"QuotaService"}
+}
+
+// Filter returns a function to filter the swagger path
+func (h *Handler) Filter() func(string) string {
+	return nil
+}
+
+// GetUserUsage sends the storage used by the current user, along with the quota applying to them.
+func (h *Handler) GetUserUsage(req *restful.Request, rsp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, rsp, errors.Forbidden(common.SERVICE_QUOTA, "Cannot find current user"))
+		return
+	}
+
+	usageClient := quota.NewUsageServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_QUOTA, defaults.NewClient())
+	resp, e := usageClient.GetUsage(ctx, &quota.GetUsageRequest{Login: claims.Name})
+	if e != nil {
+		service.RestError500(req, rsp, e)
+		return
+	}
+	maxQuota, e := utils.GetUserQuota(ctx, strings.Split(claims.Roles, ","))
+	if e != nil {
+		service.RestError500(req, rsp, e)
+		return
+	}
+
+	rsp.WriteEntity(&rest.UserUsage{
+		Login: claims.Name,
+		Bytes: resp.GetUsage().GetBytes(),
+		Files: resp.GetUsage().GetFiles(),
+		Quota: maxQuota,
+	})
+}
+
+// ListUserUsages sends the users having the largest storage usage. Access is restricted to admins by the default policies.
+func (h *Handler) ListUserUsages(req *restful.Request, rsp *restful.Response) {
+
+	ctx := req.Request.Context()
+	request := &quota.ListUsagesRequest{}
+	if l, e := strconv.ParseInt(req.QueryParameter("Limit"), 10, 32); e == nil {
+		request.Limit = int32(l)
+	}
+	if o, e := strconv.ParseInt(req.QueryParameter("Offset"), 10, 32); e == nil {
+		request.Offset = int32(o)
+	}
+
+	usageClient := quota.NewUsageServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_QUOTA, defaults.NewClient())
+	resp, e := usageClient.ListUsages(ctx, request)
+	if e != nil {
+		service.RestError500(req, rsp, e)
+		return
+	}
+
+	collection := &rest.UserUsageCollection{}
+	for _, u := range resp.GetUsages() {
+		collection.Usages = append(collection.Usages, &rest.UserUsage{
+			Login: u.Login,
+			Bytes: u.Bytes,
+			Files: u.Files,
+		})
+	}
+	rsp.WriteEntity(collection)
+}
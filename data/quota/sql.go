@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package quota
+
+import (
+	databasesql "database/sql"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gobuffalo/packr"
+	migrate "github.com/rubenv/sql-migrate"
+
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/proto/quota"
+	"github.com/pydio/cells/common/sql"
+)
+
+var (
+	queries = map[string]interface{}{
+		"nodeSelect":      `SELECT node_id, owner, path, size FROM data_quota_nodes WHERE node_id=?`,
+		"nodeSelectBelow": `SELECT node_id, owner, path, size FROM data_quota_nodes WHERE SUBSTR(path, 1, ?)=?`,
+		"nodeList":        `SELECT node_id, owner, path, size FROM data_quota_nodes WHERE node_id>? ORDER BY node_id LIMIT ?`,
+		"nodeInsert":      `INSERT INTO data_quota_nodes (node_id,owner,path,size) VALUES (?,?,?,?)`,
+		"nodeUpdate":      `UPDATE data_quota_nodes SET path=?, size=? WHERE node_id=?`,
+		"nodeDelete":      `DELETE FROM data_quota_nodes WHERE node_id=?`,
+		"usageSelect":     `SELECT owner, bytes, files FROM data_quota_usage WHERE owner=?`,
+		"usageInsert":     `INSERT INTO data_quota_usage (owner,bytes,files) VALUES (?,?,?)`,
+		"usageUpdate":     `UPDATE data_quota_usage SET bytes=bytes+?, files=files+? WHERE owner=?`,
+		"usageList":       `SELECT owner, bytes, files FROM data_quota_usage ORDER BY bytes DESC, owner LIMIT ? OFFSET ?`,
+		"usageClear":      `DELETE FROM data_quota_usage`,
+		"usageRecompute":  `INSERT INTO data_quota_usage (owner,bytes,files) SELECT owner, SUM(size), COUNT(*) FROM data_quota_nodes GROUP BY owner`,
+	}
+)
+
+type sqlimpl struct {
+	sql.DAO
+	// Serializes the read-then-write sequences, as events for a same node can be received concurrently
+	mu sync.Mutex
+}
+
+// Init handler for the SQL DAO
+func (s *sqlimpl) Init(options config.Map) error {
+
+	// super
+	s.DAO.Init(options)
+
+	// Doing the database migrations
+	migrations := &sql.PackrMigrationSource{
+		Box:         packr.NewBox("../../data/quota/migrations"),
+		Dir:         s.Driver(),
+		TablePrefix: s.Prefix(),
+	}
+
+	_, err := sql.ExecMigration(s.DB(), s.Driver(), migrations, migrate.Up, "data_quota_")
+	if err != nil {
+		return err
+	}
+
+	// Preparing the db statements
+	if options.Bool("prepare", true) {
+		for key, query := range queries {
+			if err := s.Prepare(key, query); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetNodeSize registers the size and path of a node. If the node is already known, the difference with the
+// previous size is applied to the usage of its original owner, otherwise the node is accounted to the given owner.
+func (s *sqlimpl) SetNodeSize(nodeId string, owner string, nodePath string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	known, err := s.selectNode(nodeId)
+	if err != nil {
+		return err
+	}
+	if known != nil {
+		if known.Size == size && known.Path == nodePath {
+			return nil
+		}
+		if _, err := s.GetStmt("nodeUpdate").Exec(nodePath, size, nodeId); err != nil {
+			return err
+		}
+		return s.addUsage(known.Owner, size-known.Size, 0)
+	}
+
+	if owner == "" {
+		return nil
+	}
+	if _, err := s.GetStmt("nodeInsert").Exec(nodeId, owner, nodePath, size); err != nil {
+		return err
+	}
+	return s.addUsage(owner, size, 1)
+}
+
+// MoveNode updates the path of a node, and the paths of all known nodes below it when it is a folder.
+func (s *sqlimpl) MoveNode(nodeId string, fromPath string, toPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes, err := s.selectBelow(fromPath)
+	if err != nil {
+		return err
+	}
+	if known, err := s.selectNode(nodeId); err != nil {
+		return err
+	} else if known != nil {
+		known.Path = toPath
+		nodes = append(nodes, known)
+	}
+	for _, n := range nodes {
+		if strings.HasPrefix(n.Path, fromPath+"/") {
+			n.Path = toPath + strings.TrimPrefix(n.Path, fromPath)
+		}
+		if _, err := s.GetStmt("nodeUpdate").Exec(n.Path, n.Size, n.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNode removes a node, and all known nodes below it when it is a folder, then subtracts their
+// sizes from the usage of their owners.
+func (s *sqlimpl) DeleteNode(nodeId string, nodePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nodes []*Node
+	if nodePath != "" {
+		below, err := s.selectBelow(nodePath)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, below...)
+	}
+	if known, err := s.selectNode(nodeId); err != nil {
+		return err
+	} else if known != nil {
+		nodes = append(nodes, known)
+	}
+	for _, n := range nodes {
+		if _, err := s.GetStmt("nodeDelete").Exec(n.ID); err != nil {
+			return err
+		}
+		if err := s.addUsage(n.Owner, -n.Size, -1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListNodes lists the known nodes by pages, sorted by ID.
+func (s *sqlimpl) ListNodes(afterId string, limit int32) ([]*Node, error) {
+	rows, err := s.GetStmt("nodeList").Query(afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNodes(rows)
+}
+
+// RecomputeUsages rebuilds all the users usages from the known nodes.
+func (s *sqlimpl) RecomputeUsages() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.DB().Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Stmt(s.GetStmt("usageClear")).Exec(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Stmt(s.GetStmt("usageRecompute")).Exec(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUsage returns the usage of a given user. An empty usage is returned if nothing is known for this user.
+func (s *sqlimpl) GetUsage(owner string) (*quota.UserUsage, error) {
+	rows, err := s.GetStmt("usageSelect").Query(owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := &quota.UserUsage{Login: owner}
+	if rows.Next() {
+		if err := rows.Scan(&usage.Login, &usage.Bytes, &usage.Files); err != nil {
+			return nil, err
+		}
+	}
+	return usage, rows.Err()
+}
+
+// ListUsages lists users usages, sorted by used bytes in descending order.
+func (s *sqlimpl) ListUsages(limit int32, offset int32) ([]*quota.UserUsage, error) {
+	rows, err := s.GetStmt("usageList").Query(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usages []*quota.UserUsage
+	for rows.Next() {
+		usage := &quota.UserUsage{}
+		if err := rows.Scan(&usage.Login, &usage.Bytes, &usage.Files); err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+	return usages, rows.Err()
+}
+
+func (s *sqlimpl) selectNode(nodeId string) (*Node, error) {
+	rows, err := s.GetStmt("nodeSelect").Query(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(rows)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// selectBelow finds the nodes whose path starts with the given folder path.
+func (s *sqlimpl) selectBelow(folderPath string) ([]*Node, error) {
+	prefix := folderPath + "/"
+	rows, err := s.GetStmt("nodeSelectBelow").Query(utf8.RuneCountInString(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	return scanNodes(rows)
+}
+
+func scanNodes(rows *databasesql.Rows) ([]*Node, error) {
+	defer rows.Close()
+	var nodes []*Node
+	for rows.Next() {
+		n := &Node{}
+		if err := rows.Scan(&n.ID, &n.Owner, &n.Path, &n.Size); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+func (s *sqlimpl) addUsage(owner string, bytes int64, files int64) error {
+	res, err := s.GetStmt("usageUpdate").Exec(bytes, files, owner)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected > 0 {
+		return nil
+	}
+	_, err = s.GetStmt("usageInsert").Exec(owner, bytes, files)
+	return err
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package content
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	DefaultMemoryCacheSize = 1000
+)
+
+var (
+	cacheBucket = []byte("TextContent")
+)
+
+// Cache stores the text extracted from a node content, keyed by node Uuid and
+// valid as long as the node ETag did not change.
+type Cache interface {
+	// Get returns the text previously extracted for this node, if its ETag still matches.
+	Get(uuid string, etag string) (string, bool)
+	// Set stores the text extracted for a given version of this node.
+	Set(uuid string, etag string, text string) error
+	// Delete removes any entry for this node.
+	Delete(uuid string) error
+	// Close releases the cache resources.
+	Close() error
+}
+
+type cacheEntry struct {
+	ETag string `json:"etag"`
+	Text string `json:"text"`
+}
+
+// MemoryCache is a Cache kept in memory, mainly used for testing. It keeps at most
+// a fixed number of entries, evicting the least recently used ones.
+type MemoryCache struct {
+	sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type memoryEntry struct {
+	uuid string
+	cacheEntry
+}
+
+// NewMemoryCache creates an empty in-memory cache. Its size defaults to DefaultMemoryCacheSize entries.
+func NewMemoryCache(maxEntries ...int) *MemoryCache {
+	size := DefaultMemoryCacheSize
+	if len(maxEntries) > 0 && maxEntries[0] > 0 {
+		size = maxEntries[0]
+	}
+	return &MemoryCache{maxEntries: size, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (m *MemoryCache) Get(uuid string, etag string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+	if el, ok := m.entries[uuid]; ok && el.Value.(*memoryEntry).ETag == etag {
+		m.lru.MoveToFront(el)
+		return el.Value.(*memoryEntry).Text, true
+	}
+	return "", false
+}
+
+func (m *MemoryCache) Set(uuid string, etag string, text string) error {
+	m.Lock()
+	defer m.Unlock()
+	entry := &memoryEntry{uuid: uuid, cacheEntry: cacheEntry{ETag: etag, Text: text}}
+	if el, ok := m.entries[uuid]; ok {
+		el.Value = entry
+		m.lru.MoveToFront(el)
+		return nil
+	}
+	m.entries[uuid] = m.lru.PushFront(entry)
+	for m.lru.Len() > m.maxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).uuid)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Delete(uuid string) error {
+	m.Lock()
+	defer m.Unlock()
+	if el, ok := m.entries[uuid]; ok {
+		m.lru.Remove(el)
+		delete(m.entries, uuid)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Close() error {
+	return nil
+}
+
+// BoltCache is a Cache persisted in a bolt file, so that extracted
+// text survives service restarts and index resyncs.
+type BoltCache struct {
+	// Internal DB
+	db *bolt.DB
+	// For Testing purpose : delete file after closing
+	DeleteOnClose bool
+	// Path to the DB file
+	DbPath string
+}
+
+// NewBoltCache opens or creates a bolt file to store extracted texts.
+func NewBoltCache(fileName string, deleteOnClose ...bool) (*BoltCache, error) {
+
+	bc := &BoltCache{
+		DbPath: fileName,
+	}
+	if len(deleteOnClose) > 0 && deleteOnClose[0] {
+		bc.DeleteOnClose = true
+	}
+	options := bolt.DefaultOptions
+	options.Timeout = 5 * time.Second
+	db, err := bolt.Open(fileName, 0644, options)
+	if err != nil {
+		return nil, err
+	}
+	bc.db = db
+	e2 := db.Update(func(tx *bolt.Tx) error {
+		_, e := tx.CreateBucketIfNotExists(cacheBucket)
+		return e
+	})
+	return bc, e2
+
+}
+
+func (b *BoltCache) Get(uuid string, etag string) (string, bool) {
+	var entry cacheEntry
+	var found bool
+	b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(cacheBucket).Get([]byte(uuid))
+		if data == nil {
+			return nil
+		}
+		if e := json.Unmarshal(data, &entry); e == nil && entry.ETag == etag {
+			found = true
+		}
+		return nil
+	})
+	return entry.Text, found
+}
+
+func (b *BoltCache) Set(uuid string, etag string, text string) error {
+	data, err := json.Marshal(&cacheEntry{ETag: etag, Text: text})
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(cacheBucket).Put([]byte(uuid), data)
+	})
+}
+
+func (b *BoltCache) Delete(uuid string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(cacheBucket).Delete([]byte(uuid))
+	})
+}
+
+func (b *BoltCache) Close() error {
+	err := b.db.Close()
+	if b.DeleteOnClose {
+		os.Remove(b.DbPath)
+	}
+	return err
+}
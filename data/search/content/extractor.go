@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package content provides a queued stage extracting text from files, to be fed to the search engines.
+//
+// Extraction runs in its own pool of workers, so that indexing metadata is never blocked by reading
+// and converting files. Extracted texts are cached by node ETag : a file is only read again when its
+// content actually changed.
+package content
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/sajari/docconv"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+)
+
+const (
+	DefaultWorkers   = 2
+	DefaultQueueSize = 1000
+	DefaultMaxSize   = int64(50 * 1024 * 1024)
+)
+
+var (
+	// DefaultMimeTypes lists the types that can be converted to text without external tools.
+	DefaultMimeTypes = []string{
+		"text/plain",
+		"text/html",
+		"text/xml",
+		"application/pdf",
+		"application/rtf",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.oasis.opendocument.text",
+		"application/vnd.apple.pages",
+	}
+)
+
+// Reader opens the content of a node.
+type Reader func(ctx context.Context, node *tree.Node) (io.ReadCloser, error)
+
+// Callback is called once the text of a node has been extracted.
+type Callback func(ctx context.Context, node *tree.Node, text string)
+
+// Options configure an Extractor. Zero values are replaced by defaults.
+type Options struct {
+	// Number of concurrent extractions
+	Workers int
+	// Maximum number of nodes waiting for extraction, nodes pushed when it is reached are dropped
+	QueueSize int
+	// Files bigger than this size (in bytes) are not extracted
+	MaxSize int64
+	// Accepted mime types, detected from the file extension. Wildcards like "text/*" are supported
+	MimeTypes []string
+	// Cache for extracted texts, in memory by default
+	Cache Cache
+	// Reader for files contents, using an admin router by default
+	Reader Reader
+}
+
+type job struct {
+	ctx      context.Context
+	node     *tree.Node
+	callback Callback
+}
+
+// Extractor queues nodes and extracts their text in the background.
+type Extractor struct {
+	opts Options
+
+	sync.Mutex
+	pending map[string]*job
+	running map[string]*job
+	queue   chan string
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewExtractor creates an Extractor and starts its workers.
+func NewExtractor(opts Options) *Extractor {
+
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if len(opts.MimeTypes) == 0 {
+		opts.MimeTypes = DefaultMimeTypes
+	}
+	if opts.Cache == nil {
+		opts.Cache = NewMemoryCache()
+	}
+	if opts.Reader == nil {
+		opts.Reader = routerReader()
+	}
+	e := &Extractor{
+		opts:    opts,
+		pending: make(map[string]*job),
+		running: make(map[string]*job),
+		queue:   make(chan string, opts.QueueSize),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		e.wg.Add(1)
+		go e.work()
+	}
+	return e
+
+}
+
+// Accept checks if the content of this node should be extracted at all.
+func (e *Extractor) Accept(node *tree.Node) bool {
+
+	if !node.IsLeaf() || node.Etag == "" || node.Etag == common.NODE_FLAG_ETAG_TEMPORARY {
+		return false
+	}
+	if node.Size > e.opts.MaxSize {
+		return false
+	}
+	mime := docconv.MimeTypeByExtension(nodeName(node))
+	for _, accepted := range e.opts.MimeTypes {
+		if accepted == mime || (strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mime, strings.TrimSuffix(accepted, "*"))) {
+			return true
+		}
+	}
+	return false
+
+}
+
+// Cached returns the text already extracted for the current ETag of this node.
+func (e *Extractor) Cached(node *tree.Node) (string, bool) {
+
+	return e.opts.Cache.Get(node.Uuid, node.Etag)
+
+}
+
+// Push queues a node for extraction. If the same node is already waiting, it is
+// replaced by this newer version, and an extraction running for an older version
+// is discarded. Push never blocks: it returns false if the node is not accepted
+// or if the queue is full.
+func (e *Extractor) Push(ctx context.Context, node *tree.Node, callback Callback) bool {
+
+	if !e.Accept(node) {
+		e.cancel(node.Uuid)
+		return false
+	}
+	j := &job{ctx: ctx, node: proto.Clone(node).(*tree.Node), callback: callback}
+	e.Lock()
+	defer e.Unlock()
+	delete(e.running, node.Uuid)
+	if _, ok := e.pending[node.Uuid]; ok {
+		e.pending[node.Uuid] = j
+		return true
+	}
+	select {
+	case <-e.done:
+		return false
+	default:
+	}
+	select {
+	case e.queue <- node.Uuid:
+		e.pending[node.Uuid] = j
+		return true
+	default:
+		log.Logger(ctx).Warn("[CONTENT] Extraction queue is full, dropping node", node.Zap())
+		return false
+	}
+
+}
+
+// Forget removes any cached text for this node and cancels its queued or
+// running extraction, typically when it is deleted.
+func (e *Extractor) Forget(uuid string) error {
+
+	e.cancel(uuid)
+	return e.opts.Cache.Delete(uuid)
+
+}
+
+// cancel drops the queued job for this node and discards the result of a running one.
+func (e *Extractor) cancel(uuid string) {
+	e.Lock()
+	defer e.Unlock()
+	delete(e.pending, uuid)
+	delete(e.running, uuid)
+}
+
+// Close stops the workers and closes the cache. Pending nodes are dropped.
+func (e *Extractor) Close() error {
+
+	close(e.done)
+	e.wg.Wait()
+	return e.opts.Cache.Close()
+
+}
+
+func (e *Extractor) work() {
+
+	defer e.wg.Done()
+	for {
+		select {
+		case uuid := <-e.queue:
+			e.Lock()
+			j, ok := e.pending[uuid]
+			delete(e.pending, uuid)
+			if ok {
+				e.running[uuid] = j
+			}
+			e.Unlock()
+			if !ok {
+				continue
+			}
+			text, cached := e.Cached(j.node)
+			if !cached {
+				var err error
+				if text, err = e.extract(j.ctx, j.node); err != nil {
+					log.Logger(j.ctx).Debug("[CONTENT] Cannot extract text from file", j.node.Zap(), zap.Error(err))
+					e.finish(j)
+					continue
+				}
+				if err := e.opts.Cache.Set(j.node.Uuid, j.node.Etag, text); err != nil {
+					log.Logger(j.ctx).Error("[CONTENT] Cannot store extracted text", j.node.Zap(), zap.Error(err))
+				}
+			}
+			if e.finish(j) {
+				j.callback(j.ctx, j.node, text)
+			}
+		case <-e.done:
+			return
+		}
+	}
+
+}
+
+// finish checks that the job was neither cancelled nor replaced by a newer version while it was running.
+func (e *Extractor) finish(j *job) bool {
+	e.Lock()
+	defer e.Unlock()
+	if e.running[j.node.Uuid] != j {
+		return false
+	}
+	delete(e.running, j.node.Uuid)
+	return true
+}
+
+func (e *Extractor) extract(ctx context.Context, node *tree.Node) (string, error) {
+
+	reader, err := e.opts.Reader(ctx, node)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	convertResp, err := docconv.Convert(reader, docconv.MimeTypeByExtension(nodeName(node)), true)
+	if err != nil {
+		return "", err
+	}
+	log.Logger(ctx).Debug("[CONTENT] Extracted text from file", node.Zap(), zap.Uint32("msecs", convertResp.MSecs))
+	return convertResp.Body, nil
+
+}
+
+func nodeName(node *tree.Node) string {
+	if name := node.GetStringMeta("name"); name != "" {
+		return name
+	}
+	return path.Base(node.Path)
+}
+
+// routerReader reads files through a single admin router, created on first use.
+func routerReader() Reader {
+	var once sync.Once
+	var router *views.Router
+	return func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+		once.Do(func() {
+			router = views.NewStandardRouter(views.RouterOptions{AdminView: true, WatchRegistry: false})
+		})
+		return router.GetObject(ctx, proto.Clone(node).(*tree.Node), &views.GetRequestData{Length: -1})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package content
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+func testNode(uuid string, name string, etag string) *tree.Node {
+	node := &tree.Node{
+		Uuid: uuid,
+		Path: "/path/to/" + name,
+		Type: tree.NodeType_LEAF,
+		Size: 12,
+		Etag: etag,
+	}
+	node.SetMeta("name", name)
+	return node
+}
+
+func countingReader(count *int32) Reader {
+	return func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+		atomic.AddInt32(count, 1)
+		return ioutil.NopCloser(strings.NewReader("content for " + node.Etag)), nil
+	}
+}
+
+func waitText(texts chan string) string {
+	select {
+	case t := <-texts:
+		return t
+	case <-time.After(5 * time.Second):
+		return "timeout"
+	}
+}
+
+func TestExtractorFilters(t *testing.T) {
+
+	Convey("Test nodes accepted for extraction", t, func() {
+
+		e := NewExtractor(Options{MaxSize: 100, Reader: countingReader(new(int32))})
+		defer e.Close()
+
+		So(e.Accept(testNode("1", "file.txt", "etag")), ShouldBeTrue)
+		So(e.Accept(testNode("1", "file.pdf", "etag")), ShouldBeTrue)
+		So(e.Accept(testNode("1", "file.bin", "etag")), ShouldBeFalse)
+		So(e.Accept(testNode("1", "image.jpg", "etag")), ShouldBeFalse)
+		So(e.Accept(testNode("1", "file.txt", "")), ShouldBeFalse)
+		So(e.Accept(testNode("1", "file.txt", common.NODE_FLAG_ETAG_TEMPORARY)), ShouldBeFalse)
+
+		big := testNode("1", "file.txt", "etag")
+		big.Size = 101
+		So(e.Accept(big), ShouldBeFalse)
+
+		folder := testNode("1", "folder", "etag")
+		folder.Type = tree.NodeType_COLLECTION
+		So(e.Accept(folder), ShouldBeFalse)
+
+	})
+
+	Convey("Test mime types wildcards", t, func() {
+
+		e := NewExtractor(Options{MimeTypes: []string{"text/*"}, Reader: countingReader(new(int32))})
+		defer e.Close()
+
+		So(e.Accept(testNode("1", "file.txt", "etag")), ShouldBeTrue)
+		So(e.Accept(testNode("1", "file.html", "etag")), ShouldBeTrue)
+		So(e.Accept(testNode("1", "file.pdf", "etag")), ShouldBeFalse)
+
+	})
+}
+
+func TestExtractorQueue(t *testing.T) {
+
+	Convey("Test text is extracted once per ETag", t, func() {
+
+		var count int32
+		e := NewExtractor(Options{Reader: countingReader(&count)})
+		defer e.Close()
+
+		texts := make(chan string, 1)
+		callback := func(ctx context.Context, node *tree.Node, text string) {
+			texts <- text
+		}
+
+		node := testNode("uuid", "file.txt", "etag1")
+		So(e.Push(context.Background(), node, callback), ShouldBeTrue)
+		So(waitText(texts), ShouldEqual, "content for etag1")
+		So(atomic.LoadInt32(&count), ShouldEqual, 1)
+
+		text, ok := e.Cached(node)
+		So(ok, ShouldBeTrue)
+		So(text, ShouldEqual, "content for etag1")
+
+		// Same ETag is served from cache
+		So(e.Push(context.Background(), node, callback), ShouldBeTrue)
+		So(waitText(texts), ShouldEqual, "content for etag1")
+		So(atomic.LoadInt32(&count), ShouldEqual, 1)
+
+		// New ETag is extracted again
+		node.Etag = "etag2"
+		_, ok = e.Cached(node)
+		So(ok, ShouldBeFalse)
+		So(e.Push(context.Background(), node, callback), ShouldBeTrue)
+		So(waitText(texts), ShouldEqual, "content for etag2")
+		So(atomic.LoadInt32(&count), ShouldEqual, 2)
+
+		So(e.Forget("uuid"), ShouldBeNil)
+		_, ok = e.Cached(node)
+		So(ok, ShouldBeFalse)
+
+		So(e.Push(context.Background(), testNode("other", "file.bin", "etag"), callback), ShouldBeFalse)
+
+	})
+
+	Convey("Test push does not block when the queue is full", t, func() {
+
+		release := make(chan struct{})
+		e := NewExtractor(Options{Workers: 1, QueueSize: 1, Reader: func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+			<-release
+			return ioutil.NopCloser(strings.NewReader("content")), nil
+		}})
+		defer e.Close()
+		defer close(release)
+
+		callback := func(ctx context.Context, node *tree.Node, text string) {}
+		So(e.Push(context.Background(), testNode("1", "file.txt", "etag"), callback), ShouldBeTrue)
+		// Wait for the worker to pick the first node
+		for i := 0; i < 50; i++ {
+			e.Lock()
+			_, running := e.running["1"]
+			e.Unlock()
+			if running {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(e.Push(context.Background(), testNode("2", "file.txt", "etag"), callback), ShouldBeTrue)
+		So(e.Push(context.Background(), testNode("3", "file.txt", "etag"), callback), ShouldBeFalse)
+		// Replacing a waiting node does not need room in the queue
+		So(e.Push(context.Background(), testNode("2", "file.txt", "etag2"), callback), ShouldBeTrue)
+
+	})
+
+	Convey("Test forgotten nodes are not sent to the callback", t, func() {
+
+		release := make(chan struct{})
+		e := NewExtractor(Options{Workers: 1, Reader: func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+			<-release
+			return ioutil.NopCloser(strings.NewReader("content for " + node.Etag)), nil
+		}})
+		defer e.Close()
+
+		texts := make(chan string, 2)
+		callback := func(ctx context.Context, node *tree.Node, text string) {
+			texts <- node.Uuid + ":" + text
+		}
+		So(e.Push(context.Background(), testNode("running", "file.txt", "etag"), callback), ShouldBeTrue)
+		So(e.Push(context.Background(), testNode("queued", "file.txt", "etag"), callback), ShouldBeTrue)
+		So(e.Push(context.Background(), testNode("kept", "file.txt", "etag"), callback), ShouldBeTrue)
+		So(e.Forget("running"), ShouldBeNil)
+		So(e.Forget("queued"), ShouldBeNil)
+		close(release)
+
+		So(waitText(texts), ShouldEqual, "kept:content for etag")
+		select {
+		case t := <-texts:
+			So(t, ShouldBeEmpty)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+
+	Convey("Test memory cache is bounded", t, func() {
+
+		cache := NewMemoryCache(2)
+		So(cache.Set("1", "etag", "one"), ShouldBeNil)
+		So(cache.Set("2", "etag", "two"), ShouldBeNil)
+		_, ok := cache.Get("1", "etag")
+		So(ok, ShouldBeTrue)
+		So(cache.Set("3", "etag", "three"), ShouldBeNil)
+
+		_, ok = cache.Get("2", "etag")
+		So(ok, ShouldBeFalse)
+		text, ok := cache.Get("1", "etag")
+		So(ok, ShouldBeTrue)
+		So(text, ShouldEqual, "one")
+		_, ok = cache.Get("3", "other")
+		So(ok, ShouldBeFalse)
+
+		So(cache.Delete("1"), ShouldBeNil)
+		_, ok = cache.Get("1", "etag")
+		So(ok, ShouldBeFalse)
+
+	})
+}
+
+func TestBoltCache(t *testing.T) {
+
+	Convey("Test bolt cache", t, func() {
+
+		tmpDir, _ := ioutil.TempDir("", "content")
+		defer os.RemoveAll(tmpDir)
+
+		cache, err := NewBoltCache(filepath.Join(tmpDir, "content.db"))
+		So(err, ShouldBeNil)
+		So(cache.Set("uuid", "etag", "some text"), ShouldBeNil)
+		So(cache.Close(), ShouldBeNil)
+
+		cache, err = NewBoltCache(filepath.Join(tmpDir, "content.db"), true)
+		So(err, ShouldBeNil)
+		defer cache.Close()
+
+		text, ok := cache.Get("uuid", "etag")
+		So(ok, ShouldBeTrue)
+		So(text, ShouldEqual, "some text")
+
+		_, ok = cache.Get("uuid", "other")
+		So(ok, ShouldBeFalse)
+
+		So(cache.Delete("uuid"), ShouldBeNil)
+		_, ok = cache.Get("uuid", "etag")
+		So(ok, ShouldBeFalse)
+
+	})
+}
@@ -32,13 +32,11 @@ import (
 	"github.com/blevesearch/bleve"
 	_ "github.com/blevesearch/bleve/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/search/query"
-	"github.com/sajari/docconv"
 	"go.uber.org/zap"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/tree"
-	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/data/search/content"
 )
 
 var (
@@ -46,12 +44,14 @@ var (
 )
 
 type BleveServer struct {
-	Router       views.Handler
 	Engine       bleve.Index
 	IndexContent bool
+	Extractor    *content.Extractor
 }
 
-func NewBleveEngine(indexContent bool) (*BleveServer, error) {
+// NewBleveEngine opens or creates the index at BleveIndexPath. When indexContent is true, files text
+// is extracted by the passed Extractor, or by a default one if none is passed.
+func NewBleveEngine(indexContent bool, extractor ...*content.Extractor) (*BleveServer, error) {
 
 	if BleveIndexPath == "" {
 		return nil, fmt.Errorf("please setup BleveIndexPath before opening engine")
@@ -93,10 +93,11 @@ func NewBleveEngine(indexContent bool) (*BleveServer, error) {
 		geoPosition := bleve.NewGeoPointFieldMapping()
 		nodeMapping.AddFieldMappingsAt("GeoPoint", geoPosition)
 
-		// Text Content
+		// Text Content, stored with term vectors for highlighting
 		textContent := bleve.NewTextFieldMapping()
 		textContent.Analyzer = "en" // See detect_lang in the blevesearch/blevex package?
-		textContent.Store = false
+		textContent.Store = true
+		textContent.IncludeTermVectors = true
 		textContent.IncludeInAll = false
 		nodeMapping.AddFieldMappingsAt("TextContent", textContent)
 
@@ -105,10 +106,18 @@ func NewBleveEngine(indexContent bool) (*BleveServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &BleveServer{
+	server := &BleveServer{
 		Engine:       index,
 		IndexContent: indexContent,
-	}, nil
+	}
+	if indexContent {
+		if len(extractor) > 0 && extractor[0] != nil {
+			server.Extractor = extractor[0]
+		} else {
+			server.Extractor = content.NewExtractor(content.Options{})
+		}
+	}
+	return server, nil
 
 }
 
@@ -142,22 +151,10 @@ func (s *BleveServer) MakeIndexableNode(ctx context.Context, node *tree.Node) *I
 	}
 	indexNode.GetMeta("GeoLocation", &indexNode.GeoPoint)
 
-	if s.IndexContent && indexNode.IsLeaf() {
-		if s.Router == nil {
-			s.Router = views.NewStandardRouter(views.RouterOptions{AdminView: false, WatchRegistry: false})
-		}
-		s.Router = views.NewStandardRouter(views.RouterOptions{AdminView: true, WatchRegistry: false})
-		reader, err := s.Router.GetObject(ctx, proto.Clone(node).(*tree.Node), &views.GetRequestData{Length: -1})
-		//reader, err := node.ReadFile(ctx)
-		if err == nil {
-			convertResp, er := docconv.Convert(reader, docconv.MimeTypeByExtension(basename), true)
-			if er == nil {
-				// Todo : do something with convertResp.Meta?
-				log.Logger(ctx).Debug("[BLEVE] Indexing content body for file")
-				indexNode.TextContent = convertResp.Body
-			}
-		} else {
-			log.Logger(ctx).Debug("[BLEVE] Index content: error while trying to read file for content indexation")
+	if s.IndexContent && indexNode.IsLeaf() && s.Extractor != nil {
+		// Only use text already extracted for this ETag, extraction itself is queued by IndexNode
+		if text, ok := s.Extractor.Cached(node); ok {
+			indexNode.TextContent = text
 		}
 	}
 	indexNode.MetaStore = nil
@@ -166,10 +163,15 @@ func (s *BleveServer) MakeIndexableNode(ctx context.Context, node *tree.Node) *I
 
 func (s *BleveServer) Close() error {
 
+	if s.Extractor != nil {
+		s.Extractor.Close()
+	}
 	return s.Engine.Close()
 
 }
 
+// IndexNode indexes the node metadata right away. If its text content is not known yet
+// for the current ETag, the node is queued for extraction and indexed again once it is done.
 func (s *BleveServer) IndexNode(c context.Context, n *tree.Node) error {
 
 	indexNode := s.MakeIndexableNode(c, n)
@@ -180,11 +182,55 @@ func (s *BleveServer) IndexNode(c context.Context, n *tree.Node) error {
 	if err != nil {
 		return err
 	}
+	if s.IndexContent && s.Extractor != nil && n.IsLeaf() {
+		if _, ok := s.Extractor.Cached(n); !ok {
+			s.Extractor.Push(c, n, s.indexExtracted)
+		}
+	}
 	return nil
 }
 
+// indexExtracted is called by the extractor once the text content is available. The node
+// is indexed again only if it was not deleted, moved or modified in the meantime.
+func (s *BleveServer) indexExtracted(c context.Context, n *tree.Node, text string) {
+
+	if !s.isIndexed(n) {
+		log.Logger(c).Debug("Node changed during extraction, ignoring extracted content", n.Zap())
+		return
+	}
+	indexNode := s.MakeIndexableNode(c, n)
+	indexNode.TextContent = text
+	if err := s.Engine.Index(n.GetUuid(), indexNode); err != nil {
+		log.Logger(c).Error("Cannot index extracted content", n.Zap(), zap.Error(err))
+	}
+
+}
+
+// isIndexed checks that the document currently indexed for this node has the same path and ETag.
+func (s *BleveServer) isIndexed(n *tree.Node) bool {
+
+	doc, err := s.Engine.Document(n.GetUuid())
+	if err != nil || doc == nil {
+		return false
+	}
+	var p, etag string
+	for _, f := range doc.Fields {
+		switch f.Name() {
+		case "Path":
+			p = string(f.Value())
+		case "Etag":
+			etag = string(f.Value())
+		}
+	}
+	return p == n.GetPath() && etag == n.GetEtag()
+
+}
+
 func (s *BleveServer) DeleteNode(c context.Context, n *tree.Node) error {
 
+	if s.Extractor != nil {
+		s.Extractor.Forget(n.GetUuid())
+	}
 	return s.Engine.Delete(n.GetUuid())
 
 }
@@ -206,8 +252,14 @@ func (s *BleveServer) ClearIndex(ctx context.Context) error {
 	return nil
 }
 
-func (s *BleveServer) SearchNodes(c context.Context, queryObject *tree.Query, from int32, size int32, resultChan chan *tree.Node, doneChan chan bool) error {
+// SearchNodes sends a response for each hit, with highlighted fragments of the text content if required.
+// If facets are requested, they are sent in a last response without Node.
+func (s *BleveServer) SearchNodes(c context.Context, request *tree.SearchRequest, resultChan chan *tree.SearchResponse, doneChan chan bool) error {
 
+	queryObject := request.GetQuery()
+	if queryObject == nil {
+		queryObject = &tree.Query{}
+	}
 	boolean := bleve.NewBooleanQuery()
 	// FileName
 	if len(queryObject.GetFileName()) > 0 {
@@ -249,6 +301,15 @@ func (s *BleveServer) SearchNodes(c context.Context, queryObject *tree.Query, fr
 		}
 		boolean.AddMust(subQ)
 	}
+	// Exclude SubTrees, including their root
+	for _, pref := range queryObject.ExcludedPathPrefix {
+		pref = strings.TrimSuffix(pref, "/")
+		root := bleve.NewTermQuery(pref)
+		root.SetField("Path")
+		below := bleve.NewPrefixQuery(pref + "/")
+		below.SetField("Path")
+		boolean.AddMustNot(root, below)
+	}
 	// Limit to a given node type
 	if queryObject.Type > 0 {
 		nodeType := "file"
@@ -290,10 +351,31 @@ func (s *BleveServer) SearchNodes(c context.Context, queryObject *tree.Query, fr
 
 	log.Logger(c).Info("SearchObjects", zap.Any("query", boolean))
 	searchRequest := bleve.NewSearchRequest(boolean)
-	if size > 0 {
-		searchRequest.Size = int(size)
+	if request.Size > 0 {
+		searchRequest.Size = int(request.Size)
+	}
+	searchRequest.From = int(request.From)
+	if len(request.SortFields) > 0 {
+		var order []string
+		for _, sortField := range request.SortFields {
+			field := sortField.Field
+			if field == "score" {
+				field = "_score"
+			}
+			if sortField.Descending {
+				field = "-" + field
+			}
+			order = append(order, field)
+		}
+		searchRequest.SortBy(order)
+	}
+	if request.Highlight && s.IndexContent {
+		searchRequest.Highlight = bleve.NewHighlight()
+		searchRequest.Highlight.AddField("TextContent")
+	}
+	for _, facet := range request.Facets {
+		searchRequest.AddFacet(facet.Name, makeFacetRequest(facet))
 	}
-	searchRequest.From = int(from)
 	searchResult, err := s.Engine.SearchInContext(c, searchRequest)
 	if err != nil {
 		doneChan <- true
@@ -331,10 +413,72 @@ func (s *BleveServer) SearchNodes(c context.Context, queryObject *tree.Query, fr
 
 		log.Logger(c).Info("SearchObjects", zap.Any("node", node))
 
-		resultChan <- node
+		resultChan <- &tree.SearchResponse{Node: node, Highlights: hit.Fragments["TextContent"]}
+	}
+	if len(searchResult.Facets) > 0 {
+		resultChan <- &tree.SearchResponse{Facets: makeFacets(request.Facets, searchResult)}
 	}
 
 	doneChan <- true
 	return nil
 
 }
+
+func makeFacetRequest(facet *tree.SearchFacetRequest) *bleve.FacetRequest {
+
+	size := int(facet.Size)
+	if size <= 0 {
+		size = 10
+	}
+	request := bleve.NewFacetRequest(facet.Field, size)
+	for _, r := range facet.NumericRanges {
+		var min, max *float64
+		if r.Min != 0 {
+			min = &r.Min
+		}
+		if r.Max != 0 {
+			max = &r.Max
+		}
+		request.AddNumericRange(r.Label, min, max)
+	}
+	for _, r := range facet.DateRanges {
+		var start, end time.Time
+		if r.Start > 0 {
+			start = time.Unix(r.Start, 0)
+		}
+		if r.End > 0 {
+			end = time.Unix(r.End, 0)
+		}
+		request.AddDateTimeRange(r.Label, start, end)
+	}
+	return request
+
+}
+
+// makeFacets converts results in the order of the requests.
+func makeFacets(requests []*tree.SearchFacetRequest, searchResult *bleve.SearchResult) (facets []*tree.SearchFacet) {
+
+	for _, facetRequest := range requests {
+		result, ok := searchResult.Facets[facetRequest.Name]
+		if !ok {
+			continue
+		}
+		facet := &tree.SearchFacet{
+			Name:  facetRequest.Name,
+			Field: result.Field,
+			Total: int32(result.Total),
+		}
+		for _, t := range result.Terms {
+			facet.Values = append(facet.Values, &tree.SearchFacetValue{Term: t.Term, Count: int32(t.Count)})
+		}
+		for _, r := range result.NumericRanges {
+			facet.Values = append(facet.Values, &tree.SearchFacetValue{Term: r.Name, Count: int32(r.Count)})
+		}
+		for _, r := range result.DateRanges {
+			facet.Values = append(facet.Values, &tree.SearchFacetValue{Term: r.Name, Count: int32(r.Count)})
+		}
+		facets = append(facets, facet)
+	}
+	return
+
+}
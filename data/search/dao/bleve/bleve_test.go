@@ -22,17 +22,22 @@ package bleve
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/data/search/content"
 )
 
 func getTmpIndex(createNodes bool) (s *BleveServer, dir string) {
@@ -84,18 +89,31 @@ func getTmpIndex(createNodes bool) (s *BleveServer, dir string) {
 
 func search(ctx context.Context, index *BleveServer, queryObject *tree.Query) ([]*tree.Node, error) {
 
-	resultsChan := make(chan *tree.Node)
-	doneChan := make(chan bool)
+	responses, e := searchResponses(ctx, index, &tree.SearchRequest{Query: queryObject, Size: 10})
 	results := []*tree.Node{}
+	for _, r := range responses {
+		if r.Node != nil {
+			results = append(results, r.Node)
+		}
+	}
+	return results, e
+
+}
+
+func searchResponses(ctx context.Context, index *BleveServer, request *tree.SearchRequest) ([]*tree.SearchResponse, error) {
+
+	resultsChan := make(chan *tree.SearchResponse)
+	doneChan := make(chan bool)
+	results := []*tree.SearchResponse{}
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		for {
 			select {
-			case node := <-resultsChan:
-				if node != nil {
-					results = append(results, node)
+			case response := <-resultsChan:
+				if response != nil {
+					results = append(results, response)
 				}
 			case <-doneChan:
 				return
@@ -103,7 +121,7 @@ func search(ctx context.Context, index *BleveServer, queryObject *tree.Query) ([
 		}
 	}()
 
-	e := index.SearchNodes(ctx, queryObject, 0, 10, resultsChan, doneChan)
+	e := index.SearchNodes(ctx, request, resultsChan, doneChan)
 	wg.Wait()
 	return results, e
 
@@ -428,6 +446,26 @@ func TestSearchNode(t *testing.T) {
 		So(e, ShouldBeNil)
 		So(results, ShouldHaveLength, 0)
 
+		queryObject = &tree.Query{
+			FileName:           "node",
+			PathPrefix:         []string{"/path"},
+			ExcludedPathPrefix: []string{"/path/to"},
+		}
+
+		results, e = search(ctx, server, queryObject)
+		So(e, ShouldBeNil)
+		So(results, ShouldHaveLength, 0)
+
+		queryObject = &tree.Query{
+			FileName:           "node",
+			PathPrefix:         []string{"/path"},
+			ExcludedPathPrefix: []string{"/path/t"},
+		}
+
+		results, e = search(ctx, server, queryObject)
+		So(e, ShouldBeNil)
+		So(results, ShouldHaveLength, 1)
+
 	})
 
 	Convey("Search Node by GeoLocation", t, func() {
@@ -531,3 +569,182 @@ func TestClearIndex(t *testing.T) {
 	})
 
 }
+
+func TestIndexContent(t *testing.T) {
+
+	Convey("Index Node Content in background", t, func() {
+
+		tmpDir, _ := ioutil.TempDir("", "bleve")
+		BleveIndexPath = filepath.Join(tmpDir, "pydio")
+		var reads int32
+		extractor := content.NewExtractor(content.Options{
+			Reader: func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+				atomic.AddInt32(&reads, 1)
+				return ioutil.NopCloser(strings.NewReader("the quick brown fox")), nil
+			},
+		})
+		server, _ := NewBleveEngine(true, extractor)
+		defer func() {
+			server.Close()
+			e := os.RemoveAll(tmpDir)
+			if e != nil {
+				log.Println(e)
+			}
+		}()
+
+		ctx := context.Background()
+		node := &tree.Node{
+			Uuid:  "docID1",
+			Path:  "/path/to/node.txt",
+			MTime: time.Now().Unix(),
+			Type:  1,
+			Size:  19,
+			Etag:  "etag1",
+		}
+		node.SetMeta("name", "node.txt")
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+
+		queryObject := &tree.Query{FreeString: "TextContent:fox"}
+		var results []*tree.Node
+		for i := 0; i < 50; i++ {
+			results, _ = search(ctx, server, queryObject)
+			if len(results) > 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		So(results, ShouldHaveLength, 1)
+		So(atomic.LoadInt32(&reads), ShouldEqual, 1)
+
+		// Metadata change keeps content without reading file again
+		node.SetMeta("FreeMeta", "FreeMetaValue")
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+		results, _ = search(ctx, server, queryObject)
+		So(results, ShouldHaveLength, 1)
+		So(atomic.LoadInt32(&reads), ShouldEqual, 1)
+
+	})
+
+	Convey("Content extracted for a deleted node is not indexed", t, func() {
+
+		tmpDir, _ := ioutil.TempDir("", "bleve")
+		BleveIndexPath = filepath.Join(tmpDir, "pydio")
+		release := make(chan struct{})
+		extractor := content.NewExtractor(content.Options{
+			Reader: func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+				<-release
+				return ioutil.NopCloser(strings.NewReader("the quick brown fox")), nil
+			},
+		})
+		server, _ := NewBleveEngine(true, extractor)
+		defer func() {
+			server.Close()
+			os.RemoveAll(tmpDir)
+		}()
+
+		ctx := context.Background()
+		node := &tree.Node{
+			Uuid: "docID1",
+			Path: "/path/to/node.txt",
+			Type: 1,
+			Size: 19,
+			Etag: "etag1",
+		}
+		node.SetMeta("name", "node.txt")
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+		So(server.isIndexed(node), ShouldBeTrue)
+		moved := proto.Clone(node).(*tree.Node)
+		moved.Path = "/path/to/moved.txt"
+		So(server.isIndexed(moved), ShouldBeFalse)
+
+		So(server.DeleteNode(ctx, node), ShouldBeNil)
+		So(server.isIndexed(node), ShouldBeFalse)
+		server.indexExtracted(ctx, node, "the quick brown fox")
+		close(release)
+		time.Sleep(200 * time.Millisecond)
+		doc, _ := server.Engine.Document("docID1")
+		So(doc, ShouldBeNil)
+
+	})
+
+}
+
+func TestSearchFacetsSortHighlight(t *testing.T) {
+
+	Convey("Search with facets, sort orders and highlights", t, func() {
+
+		tmpDir, _ := ioutil.TempDir("", "bleve")
+		BleveIndexPath = filepath.Join(tmpDir, "pydio")
+		extractor := content.NewExtractor(content.Options{
+			Reader: func(ctx context.Context, node *tree.Node) (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("the quick brown fox jumps over the lazy dog")), nil
+			},
+		})
+		server, _ := NewBleveEngine(true, extractor)
+		defer func() {
+			server.Close()
+			e := os.RemoveAll(tmpDir)
+			if e != nil {
+				log.Println(e)
+			}
+		}()
+
+		ctx := context.Background()
+		for i, name := range []string{"a.pdf", "b.pdf", "c.txt"} {
+			node := &tree.Node{
+				Uuid:  "docID" + name,
+				Path:  "/path/to/" + name,
+				MTime: time.Now().Unix(),
+				Type:  1,
+				Size:  int64(100 * (i + 1)),
+				Etag:  "etag",
+			}
+			node.SetMeta("name", name)
+			So(server.IndexNode(ctx, node), ShouldBeNil)
+		}
+
+		responses, e := searchResponses(ctx, server, &tree.SearchRequest{
+			Query:      &tree.Query{Type: tree.NodeType_LEAF},
+			SortFields: []*tree.SearchSortField{{Field: "Size", Descending: true}},
+			Facets: []*tree.SearchFacetRequest{
+				{Name: "types", Field: "Extension"},
+				{Name: "sizes", Field: "Size", NumericRanges: []*tree.SearchFacetRange{
+					{Label: "small", Max: 150},
+					{Label: "big", Min: 150},
+				}},
+			},
+		})
+		So(e, ShouldBeNil)
+		So(responses, ShouldHaveLength, 4)
+		So(responses[0].Node.GetStringMeta("name"), ShouldEqual, "c.txt")
+		So(responses[1].Node.GetStringMeta("name"), ShouldEqual, "b.pdf")
+		So(responses[2].Node.GetStringMeta("name"), ShouldEqual, "a.pdf")
+
+		facets := responses[3].Facets
+		So(responses[3].Node, ShouldBeNil)
+		So(facets, ShouldHaveLength, 2)
+		So(facets[0].Name, ShouldEqual, "types")
+		So(facets[0].Total, ShouldEqual, 3)
+		So(facets[0].Values, ShouldResemble, []*tree.SearchFacetValue{{Term: ".pdf", Count: 2}, {Term: ".txt", Count: 1}})
+		So(facets[1].Name, ShouldEqual, "sizes")
+		So(facets[1].Values, ShouldHaveLength, 2)
+
+		var highlights []string
+		for i := 0; i < 50; i++ {
+			responses, _ = searchResponses(ctx, server, &tree.SearchRequest{
+				Query:     &tree.Query{FreeString: "TextContent:fox"},
+				Highlight: true,
+			})
+			// Only the text file content can be converted
+			if len(responses) == 1 {
+				highlights = responses[0].Highlights
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		So(highlights, ShouldHaveLength, 1)
+		So(highlights[0], ShouldContainSubstring, "<mark>fox</mark>")
+
+	})
+
+}
@@ -0,0 +1,411 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package elastic implements the search engine on top of the Elasticsearch (or OpenSearch) HTTP API.
+//
+// Contrary to bleve, the index is stored in an external cluster, and can be shared between several
+// instances of the search service.
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/data/search/content"
+)
+
+const (
+	DefaultIndexName = "cells-nodes"
+)
+
+// indexMapping mirrors the field mappings of the bleve index.
+var indexMapping = map[string]interface{}{
+	"settings": map[string]interface{}{
+		"analysis": map[string]interface{}{
+			"normalizer": map[string]interface{}{
+				"lowercase": map[string]interface{}{
+					"type":   "custom",
+					"filter": []string{"lowercase"},
+				},
+			},
+		},
+	},
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"Uuid":        map[string]interface{}{"type": "keyword"},
+			"Path":        map[string]interface{}{"type": "keyword"},
+			"Basename":    map[string]interface{}{"type": "keyword", "normalizer": "lowercase"},
+			"NodeType":    map[string]interface{}{"type": "keyword"},
+			"Extension":   map[string]interface{}{"type": "keyword"},
+			"Etag":        map[string]interface{}{"type": "keyword"},
+			"Size":        map[string]interface{}{"type": "long"},
+			"ModifTime":   map[string]interface{}{"type": "date", "format": "epoch_second"},
+			"GeoPoint":    map[string]interface{}{"type": "geo_point"},
+			"TextContent": map[string]interface{}{"type": "text", "analyzer": "english"},
+			"Meta":        map[string]interface{}{"type": "object", "dynamic": true},
+		},
+	},
+}
+
+// ElasticServer is a SearchEngine storing nodes in an Elasticsearch index.
+type ElasticServer struct {
+	// Base URL of the cluster, e.g. http://localhost:9200
+	URL string
+	// Name of the index
+	Index string
+	// Optional credentials for basic authentication
+	Username string
+	Password string
+
+	Client       *http.Client
+	IndexContent bool
+	Extractor    *content.Extractor
+}
+
+// IndexableNode is the document sent to the index.
+type IndexableNode struct {
+	Uuid        string
+	Path        string
+	Basename    string
+	NodeType    string
+	Extension   string
+	Etag        string
+	Size        int64
+	ModifTime   int64
+	GeoPoint    map[string]interface{} `json:",omitempty"`
+	Meta        map[string]interface{} `json:",omitempty"`
+	TextContent string                 `json:",omitempty"`
+}
+
+// NewElasticEngine prepares a client for the given cluster and index, Init must be called before use. When
+// indexContent is true, files text is extracted by the passed Extractor, or by a default one if none is passed.
+func NewElasticEngine(serverURL string, index string, indexContent bool, extractor ...*content.Extractor) (*ElasticServer, error) {
+
+	if serverURL == "" {
+		return nil, fmt.Errorf("please provide the URL of the search cluster")
+	}
+	if index == "" {
+		index = DefaultIndexName
+	}
+	server := &ElasticServer{
+		URL:          strings.TrimRight(serverURL, "/"),
+		Index:        index,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		IndexContent: indexContent,
+	}
+	if indexContent {
+		if len(extractor) > 0 && extractor[0] != nil {
+			server.Extractor = extractor[0]
+		} else {
+			server.Extractor = content.NewExtractor(content.Options{})
+		}
+	}
+	return server, nil
+
+}
+
+// Init creates the index with its mapping if it does not exist yet.
+func (s *ElasticServer) Init(ctx context.Context) error {
+
+	status, _, err := s.call(ctx, http.MethodHead, "", nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+	status, body, err := s.call(ctx, http.MethodPut, "", indexMapping)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("cannot create index %s: %s", s.Index, string(body))
+	}
+	return nil
+
+}
+
+func (s *ElasticServer) MakeIndexableNode(ctx context.Context, node *tree.Node) *IndexableNode {
+
+	indexNode := &IndexableNode{
+		Uuid:      node.Uuid,
+		Path:      node.Path,
+		Etag:      node.Etag,
+		Size:      node.Size,
+		ModifTime: node.MTime,
+		Meta:      node.AllMetaDeserialized(),
+	}
+	indexNode.Basename = node.GetStringMeta("name")
+	if node.IsLeaf() {
+		indexNode.NodeType = "file"
+		indexNode.Extension = filepath.Ext(indexNode.Basename)
+	} else {
+		indexNode.NodeType = "folder"
+	}
+	var geo map[string]interface{}
+	if node.GetMeta("GeoLocation", &geo); geo != nil {
+		if lat, ok := geo["lat"]; ok {
+			if lon, ok := geo["lon"]; ok {
+				indexNode.GeoPoint = map[string]interface{}{"lat": lat, "lon": lon}
+			}
+		}
+	}
+	if s.IndexContent && node.IsLeaf() && s.Extractor != nil {
+		// Only use text already extracted for this ETag, extraction itself is queued by IndexNode
+		if text, ok := s.Extractor.Cached(node); ok {
+			indexNode.TextContent = text
+		}
+	}
+	return indexNode
+
+}
+
+// IndexNode indexes the node metadata right away. If its text content is not known yet
+// for the current ETag, the node is queued for extraction and indexed again once it is done.
+func (s *ElasticServer) IndexNode(c context.Context, n *tree.Node) error {
+
+	if n.GetUuid() == "" {
+		return fmt.Errorf("cannot index a node without uuid")
+	}
+	if err := s.putDocument(c, s.MakeIndexableNode(c, n)); err != nil {
+		return err
+	}
+	if s.IndexContent && s.Extractor != nil && n.IsLeaf() {
+		if _, ok := s.Extractor.Cached(n); !ok {
+			s.Extractor.Push(c, n, s.indexExtracted)
+		}
+	}
+	return nil
+
+}
+
+// indexExtracted is called by the extractor once the text content is available. The node
+// is indexed again only if it was not deleted, moved or modified in the meantime.
+func (s *ElasticServer) indexExtracted(c context.Context, n *tree.Node, text string) {
+
+	if !s.isIndexed(c, n) {
+		log.Logger(c).Debug("Node changed during extraction, ignoring extracted content", n.Zap())
+		return
+	}
+	indexNode := s.MakeIndexableNode(c, n)
+	indexNode.TextContent = text
+	if err := s.putDocument(c, indexNode); err != nil {
+		log.Logger(c).Error("Cannot index extracted content", n.Zap(), zap.Error(err))
+	}
+
+}
+
+// isIndexed checks that the document currently indexed for this node has the same path and ETag.
+func (s *ElasticServer) isIndexed(c context.Context, n *tree.Node) bool {
+
+	status, body, err := s.call(c, http.MethodGet, "/_doc/"+url.PathEscape(n.GetUuid()), nil)
+	if err != nil || status != http.StatusOK {
+		return false
+	}
+	var doc struct {
+		Found  bool
+		Source IndexableNode `json:"_source"`
+	}
+	if e := json.Unmarshal(body, &doc); e != nil || !doc.Found {
+		return false
+	}
+	return doc.Source.Path == n.GetPath() && doc.Source.Etag == n.GetEtag()
+
+}
+
+func (s *ElasticServer) putDocument(c context.Context, indexNode *IndexableNode) error {
+
+	status, body, err := s.call(c, http.MethodPut, "/_doc/"+url.PathEscape(indexNode.Uuid), indexNode)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("cannot index node %s: %s", indexNode.Uuid, string(body))
+	}
+	return nil
+
+}
+
+func (s *ElasticServer) DeleteNode(c context.Context, n *tree.Node) error {
+
+	if s.Extractor != nil {
+		s.Extractor.Forget(n.GetUuid())
+	}
+	status, body, err := s.call(c, http.MethodDelete, "/_doc/"+url.PathEscape(n.GetUuid()), nil)
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return fmt.Errorf("cannot delete node %s: %s", n.GetUuid(), string(body))
+	}
+	return nil
+
+}
+
+func (s *ElasticServer) ClearIndex(ctx context.Context) error {
+
+	status, body, err := s.call(ctx, http.MethodPost, "/_delete_by_query", map[string]interface{}{
+		"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+	})
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("cannot clear index: %s", string(body))
+	}
+	return nil
+
+}
+
+func (s *ElasticServer) Close() error {
+
+	if s.Extractor != nil {
+		return s.Extractor.Close()
+	}
+	return nil
+
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID        string              `json:"_id"`
+			Source    IndexableNode       `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key      interface{} `json:"key"`
+			DocCount int32       `json:"doc_count"`
+		} `json:"buckets"`
+		SumOtherDocCount int32 `json:"sum_other_doc_count"`
+	} `json:"aggregations"`
+}
+
+// SearchNodes sends a response for each hit, with highlighted fragments of the text content if required.
+// If facets are requested, they are sent in a last response without Node.
+func (s *ElasticServer) SearchNodes(c context.Context, request *tree.SearchRequest, resultChan chan *tree.SearchResponse, doneChan chan bool) error {
+
+	queryObject := request.GetQuery()
+	if queryObject == nil {
+		queryObject = &tree.Query{}
+	}
+	body := BuildSearchBody(queryObject, request)
+	log.Logger(c).Debug("SearchObjects", zap.Any("query", body))
+
+	status, data, err := s.call(c, http.MethodPost, "/_search", body)
+	if err == nil && status >= 300 {
+		err = fmt.Errorf("search failed: %s", string(data))
+	}
+	var result searchResponse
+	if err == nil {
+		err = json.Unmarshal(data, &result)
+	}
+	if err != nil {
+		doneChan <- true
+		return err
+	}
+	for _, hit := range result.Hits.Hits {
+		node := &tree.Node{
+			Uuid: hit.Source.Uuid,
+			Path: hit.Source.Path,
+		}
+		if node.Uuid == "" {
+			node.Uuid = hit.ID
+		}
+		if hit.Source.NodeType == "file" {
+			node.Type = tree.NodeType_LEAF
+		} else if hit.Source.NodeType == "folder" {
+			node.Type = tree.NodeType_COLLECTION
+		}
+		node.SetMeta("name", hit.Source.Basename)
+		resultChan <- &tree.SearchResponse{Node: node, Highlights: hit.Highlight["TextContent"]}
+	}
+	if len(request.Facets) > 0 {
+		var facets []*tree.SearchFacet
+		for _, facetRequest := range request.Facets {
+			agg, ok := result.Aggregations[facetRequest.Name]
+			if !ok {
+				continue
+			}
+			facet := &tree.SearchFacet{
+				Name:  facetRequest.Name,
+				Field: facetRequest.Field,
+				Total: agg.SumOtherDocCount,
+			}
+			for _, bucket := range agg.Buckets {
+				facet.Values = append(facet.Values, &tree.SearchFacetValue{Term: fmt.Sprintf("%v", bucket.Key), Count: bucket.DocCount})
+				facet.Total += bucket.DocCount
+			}
+			facets = append(facets, facet)
+		}
+		resultChan <- &tree.SearchResponse{Facets: facets}
+	}
+
+	doneChan <- true
+	return nil
+
+}
+
+// call sends a JSON request to the index and returns the response status and body.
+func (s *ElasticServer) call(ctx context.Context, method string, path string, payload interface{}) (int, []byte, error) {
+
+	var reader io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.URL+"/"+url.PathEscape(s.Index)+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+
+}
@@ -0,0 +1,383 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+// fakeCluster is a minimal in-process implementation of the index API: it stores documents
+// and returns all of them for any search, recording the last search request.
+type fakeCluster struct {
+	sync.Mutex
+	index      string
+	created    bool
+	docs       map[string]json.RawMessage
+	lastSearch map[string]interface{}
+	user       string
+	// Canned responses for highlights and aggregations
+	highlights   map[string]interface{}
+	aggregations map[string]interface{}
+}
+
+func newFakeCluster(index string) (*fakeCluster, *httptest.Server) {
+	f := &fakeCluster{index: index, docs: make(map[string]json.RawMessage)}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.user, _, _ = r.BasicAuth()
+	body, _ := ioutil.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != f.index {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodHead:
+		if !f.created {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		f.created = true
+		w.Write([]byte(`{"acknowledged":true}`))
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodPut:
+		f.docs[parts[2]] = body
+		w.Write([]byte(`{"result":"created"}`))
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodGet:
+		doc, ok := f.docs[parts[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"found":false}`))
+			return
+		}
+		data, _ := json.Marshal(map[string]interface{}{"found": true, "_source": doc})
+		w.Write(data)
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+		if _, ok := f.docs[parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.docs, parts[2])
+		w.Write([]byte(`{"result":"deleted"}`))
+	case len(parts) == 2 && parts[1] == "_delete_by_query":
+		f.docs = make(map[string]json.RawMessage)
+		w.Write([]byte(`{}`))
+	case len(parts) == 2 && parts[1] == "_search":
+		f.lastSearch = map[string]interface{}{}
+		json.Unmarshal(body, &f.lastSearch)
+		var hits []map[string]interface{}
+		for id, doc := range f.docs {
+			hit := map[string]interface{}{"_id": id, "_source": doc}
+			if hl, ok := f.highlights[id]; ok {
+				hit["highlight"] = hl
+			}
+			hits = append(hits, hit)
+		}
+		data, _ := json.Marshal(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}, "aggregations": f.aggregations})
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func search(ctx context.Context, server *ElasticServer, queryObject *tree.Query) ([]*tree.Node, error) {
+
+	responses, e := searchResponses(ctx, server, &tree.SearchRequest{Query: queryObject, Size: 10})
+	results := []*tree.Node{}
+	for _, r := range responses {
+		if r.Node != nil {
+			results = append(results, r.Node)
+		}
+	}
+	return results, e
+
+}
+
+func searchResponses(ctx context.Context, server *ElasticServer, request *tree.SearchRequest) ([]*tree.SearchResponse, error) {
+
+	resultsChan := make(chan *tree.SearchResponse)
+	doneChan := make(chan bool)
+	results := []*tree.SearchResponse{}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case response := <-resultsChan:
+				if response != nil {
+					results = append(results, response)
+				}
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+
+	e := server.SearchNodes(ctx, request, resultsChan, doneChan)
+	wg.Wait()
+	return results, e
+
+}
+
+func TestElasticServer(t *testing.T) {
+
+	Convey("Test index, search and delete nodes", t, func() {
+
+		fake, httpServer := newFakeCluster("test-index")
+		defer httpServer.Close()
+
+		server, err := NewElasticEngine(httpServer.URL, "test-index", false)
+		So(err, ShouldBeNil)
+		server.Username = "admin"
+		server.Password = "secret"
+		ctx := context.Background()
+		So(server.Init(ctx), ShouldBeNil)
+		So(fake.created, ShouldBeTrue)
+		So(fake.user, ShouldEqual, "admin")
+		// Second init finds the existing index
+		So(server.Init(ctx), ShouldBeNil)
+
+		node := &tree.Node{
+			Uuid:  "docID1",
+			Path:  "/path/to/node.txt",
+			MTime: time.Now().Unix(),
+			Type:  tree.NodeType_LEAF,
+			Size:  24,
+		}
+		node.SetMeta("name", "node.txt")
+		node.SetMeta("GeoLocation", map[string]float64{"lat": 47.1, "lon": 8.3})
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+		So(server.IndexNode(ctx, &tree.Node{Path: "/no/uuid"}), ShouldNotBeNil)
+
+		var doc map[string]interface{}
+		So(json.Unmarshal(fake.docs["docID1"], &doc), ShouldBeNil)
+		So(doc["Basename"], ShouldEqual, "node.txt")
+		So(doc["NodeType"], ShouldEqual, "file")
+		So(doc["Extension"], ShouldEqual, ".txt")
+		So(doc["GeoPoint"], ShouldResemble, map[string]interface{}{"lat": 47.1, "lon": 8.3})
+
+		results, err := search(ctx, server, &tree.Query{FileName: "node"})
+		So(err, ShouldBeNil)
+		So(results, ShouldHaveLength, 1)
+		So(results[0].Uuid, ShouldEqual, "docID1")
+		So(results[0].Type, ShouldEqual, tree.NodeType_LEAF)
+		So(results[0].GetStringMeta("name"), ShouldEqual, "node.txt")
+		So(fake.lastSearch["size"], ShouldEqual, 10)
+
+		// Extracted content is only indexed for the current version of the node
+		So(server.isIndexed(ctx, node), ShouldBeTrue)
+		moved := proto.Clone(node).(*tree.Node)
+		moved.Path = "/path/to/moved.txt"
+		So(server.isIndexed(ctx, moved), ShouldBeFalse)
+
+		So(server.DeleteNode(ctx, node), ShouldBeNil)
+		So(server.isIndexed(ctx, node), ShouldBeFalse)
+		So(fake.docs, ShouldBeEmpty)
+		So(server.DeleteNode(ctx, node), ShouldBeNil)
+
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+		So(server.ClearIndex(ctx), ShouldBeNil)
+		So(fake.docs, ShouldBeEmpty)
+
+	})
+
+	Convey("Test search errors are returned", t, func() {
+
+		_, httpServer := newFakeCluster("other-index")
+		defer httpServer.Close()
+
+		server, _ := NewElasticEngine(httpServer.URL, "test-index", false)
+		_, err := search(context.Background(), server, &tree.Query{})
+		So(err, ShouldNotBeNil)
+
+	})
+}
+
+func TestBuildQuery(t *testing.T) {
+
+	Convey("Test empty query matches all", t, func() {
+
+		So(BuildQuery(&tree.Query{}), ShouldResemble, map[string]interface{}{"match_all": map[string]interface{}{}})
+
+	})
+
+	Convey("Test query clauses", t, func() {
+
+		q := BuildQuery(&tree.Query{
+			FileName:   "Node",
+			MinSize:    10,
+			MaxDate:    2000,
+			PathPrefix: []string{"/a", "/b"},
+			Type:       tree.NodeType_COLLECTION,
+			Extension:  ".txt",
+			FreeString: "+Meta.FreeMeta:value",
+		})
+		must := q["bool"].(map[string]interface{})["must"].([]interface{})
+		So(must, ShouldHaveLength, 7)
+		So(must[0], ShouldResemble, map[string]interface{}{"wildcard": map[string]interface{}{"Basename": "*node*"}})
+		So(must[1], ShouldResemble, map[string]interface{}{"range": map[string]interface{}{"Size": map[string]interface{}{"gte": int64(10)}}})
+		So(must[2], ShouldResemble, map[string]interface{}{"range": map[string]interface{}{"ModifTime": map[string]interface{}{"gte": int64(0), "lte": int64(2000)}}})
+		So(must[3], ShouldResemble, map[string]interface{}{"bool": map[string]interface{}{
+			"should": []interface{}{
+				map[string]interface{}{"prefix": map[string]interface{}{"Path": "/a"}},
+				map[string]interface{}{"prefix": map[string]interface{}{"Path": "/b"}},
+			},
+			"minimum_should_match": 1,
+		}})
+		So(must[4], ShouldResemble, map[string]interface{}{"term": map[string]interface{}{"NodeType": "folder"}})
+		So(must[5], ShouldResemble, map[string]interface{}{"term": map[string]interface{}{"Extension": ".txt"}})
+		So(must[6], ShouldResemble, map[string]interface{}{"query_string": map[string]interface{}{"query": "+Meta.FreeMeta:value"}})
+
+	})
+
+	Convey("Test excluded subtrees", t, func() {
+
+		q := BuildQuery(&tree.Query{
+			PathPrefix:         []string{"/a"},
+			ExcludedPathPrefix: []string{"/a/denied/"},
+		})
+		boolQuery := q["bool"].(map[string]interface{})
+		So(boolQuery["must"], ShouldHaveLength, 1)
+		So(boolQuery["must_not"], ShouldResemble, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"Path": "/a/denied"}},
+			map[string]interface{}{"prefix": map[string]interface{}{"Path": "/a/denied/"}},
+		})
+
+	})
+
+	Convey("Test geo queries", t, func() {
+
+		q := BuildQuery(&tree.Query{GeoQuery: &tree.GeoQuery{
+			Center:   &tree.GeoPoint{Lat: 47.1, Lon: 8.3},
+			Distance: "2km",
+		}})
+		must := q["bool"].(map[string]interface{})["must"].([]interface{})
+		So(must[0], ShouldResemble, map[string]interface{}{"geo_distance": map[string]interface{}{
+			"distance": "2000.000000m",
+			"GeoPoint": map[string]interface{}{"lat": 47.1, "lon": 8.3},
+		}})
+
+		q = BuildQuery(&tree.Query{GeoQuery: &tree.GeoQuery{
+			TopLeft:     &tree.GeoPoint{Lat: 48, Lon: 8},
+			BottomRight: &tree.GeoPoint{Lat: 47, Lon: 9},
+		}})
+		must = q["bool"].(map[string]interface{})["must"].([]interface{})
+		So(must[0], ShouldResemble, map[string]interface{}{"geo_bounding_box": map[string]interface{}{
+			"GeoPoint": map[string]interface{}{
+				"top_left":     map[string]interface{}{"lat": float64(48), "lon": float64(8)},
+				"bottom_right": map[string]interface{}{"lat": float64(47), "lon": float64(9)},
+			},
+		}})
+
+	})
+}
+
+func TestSearchFacetsSortHighlight(t *testing.T) {
+
+	Convey("Test search body with sort, highlight and facets", t, func() {
+
+		body := BuildSearchBody(&tree.Query{}, &tree.SearchRequest{
+			Size:       20,
+			SortFields: []*tree.SearchSortField{{Field: "Size", Descending: true}, {Field: "score"}},
+			Highlight:  true,
+			Facets: []*tree.SearchFacetRequest{
+				{Name: "types", Field: "Extension"},
+				{Name: "owners", Field: "Meta.owner", Size: 5},
+				{Name: "sizes", Field: "Size", NumericRanges: []*tree.SearchFacetRange{{Label: "small", Max: 1024}}},
+				{Name: "dates", Field: "ModifTime", DateRanges: []*tree.SearchFacetRange{{Label: "recent", Start: 1000}}},
+			},
+		})
+		So(body["size"], ShouldEqual, 20)
+		So(body["sort"], ShouldResemble, []interface{}{
+			map[string]interface{}{"Size": map[string]interface{}{"order": "desc"}},
+			map[string]interface{}{"_score": map[string]interface{}{"order": "asc"}},
+		})
+		So(body["highlight"], ShouldResemble, map[string]interface{}{
+			"fields": map[string]interface{}{"TextContent": map[string]interface{}{}},
+		})
+		aggs := body["aggs"].(map[string]interface{})
+		So(aggs["types"], ShouldResemble, map[string]interface{}{"terms": map[string]interface{}{"field": "Extension", "size": int32(10)}})
+		So(aggs["owners"], ShouldResemble, map[string]interface{}{"terms": map[string]interface{}{"field": "Meta.owner.keyword", "size": int32(5)}})
+		So(aggs["sizes"], ShouldResemble, map[string]interface{}{"range": map[string]interface{}{
+			"field":  "Size",
+			"ranges": []interface{}{map[string]interface{}{"key": "small", "to": float64(1024)}},
+		}})
+		So(aggs["dates"], ShouldResemble, map[string]interface{}{"date_range": map[string]interface{}{
+			"field":  "ModifTime",
+			"format": "epoch_second",
+			"ranges": []interface{}{map[string]interface{}{"key": "recent", "from": "1000"}},
+		}})
+
+	})
+
+	Convey("Test highlights and facets are read from the response", t, func() {
+
+		fake, httpServer := newFakeCluster("test-index")
+		defer httpServer.Close()
+		server, _ := NewElasticEngine(httpServer.URL, "test-index", false)
+		ctx := context.Background()
+
+		node := &tree.Node{Uuid: "docID1", Path: "/path/to/node.txt", Type: tree.NodeType_LEAF}
+		node.SetMeta("name", "node.txt")
+		So(server.IndexNode(ctx, node), ShouldBeNil)
+
+		fake.highlights = map[string]interface{}{"docID1": map[string]interface{}{"TextContent": []string{"the <em>fox</em>"}}}
+		fake.aggregations = map[string]interface{}{
+			"types": map[string]interface{}{
+				"buckets":             []interface{}{map[string]interface{}{"key": ".pdf", "doc_count": 2}, map[string]interface{}{"key": ".txt", "doc_count": 1}},
+				"sum_other_doc_count": 3,
+			},
+		}
+		responses, err := searchResponses(ctx, server, &tree.SearchRequest{
+			Query:     &tree.Query{FreeString: "fox"},
+			Highlight: true,
+			Facets:    []*tree.SearchFacetRequest{{Name: "types", Field: "Extension"}},
+		})
+		So(err, ShouldBeNil)
+		So(responses, ShouldHaveLength, 2)
+		So(responses[0].Highlights, ShouldResemble, []string{"the <em>fox</em>"})
+		So(responses[1].Node, ShouldBeNil)
+		So(responses[1].Facets, ShouldResemble, []*tree.SearchFacet{{
+			Name:   "types",
+			Field:  "Extension",
+			Total:  6,
+			Values: []*tree.SearchFacetValue{{Term: ".pdf", Count: 2}, {Term: ".txt", Count: 1}},
+		}})
+
+	})
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package elastic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/geo"
+
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+// BuildQuery translates a tree.Query into an Elasticsearch bool query, with the same
+// semantics as the bleve implementation.
+func BuildQuery(queryObject *tree.Query) map[string]interface{} {
+
+	var must []interface{}
+	// FileName
+	if len(queryObject.GetFileName()) > 0 {
+		must = append(must, map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				"Basename": "*" + strings.Trim(strings.ToLower(queryObject.GetFileName()), "*") + "*",
+			},
+		})
+	}
+	// File Size Range
+	if queryObject.MinSize > 0 || queryObject.MaxSize > 0 {
+		sizeRange := map[string]interface{}{"gte": queryObject.MinSize}
+		if queryObject.MaxSize > 0 {
+			sizeRange["lte"] = queryObject.MaxSize
+		}
+		must = append(must, map[string]interface{}{"range": map[string]interface{}{"Size": sizeRange}})
+	}
+	// Date Range
+	if queryObject.MinDate > 0 || queryObject.MaxDate > 0 {
+		dateRange := map[string]interface{}{"gte": queryObject.MinDate}
+		if queryObject.MaxDate > 0 {
+			dateRange["lte"] = queryObject.MaxDate
+		} else {
+			dateRange["lte"] = "now"
+		}
+		must = append(must, map[string]interface{}{"range": map[string]interface{}{"ModifTime": dateRange}})
+	}
+	// Limit to a SubTree
+	if len(queryObject.PathPrefix) > 0 {
+		var should []interface{}
+		for _, pref := range queryObject.PathPrefix {
+			should = append(should, map[string]interface{}{"prefix": map[string]interface{}{"Path": pref}})
+		}
+		must = append(must, map[string]interface{}{
+			"bool": map[string]interface{}{"should": should, "minimum_should_match": 1},
+		})
+	}
+	// Exclude SubTrees, including their root
+	var mustNot []interface{}
+	for _, pref := range queryObject.ExcludedPathPrefix {
+		pref = strings.TrimSuffix(pref, "/")
+		mustNot = append(mustNot,
+			map[string]interface{}{"term": map[string]interface{}{"Path": pref}},
+			map[string]interface{}{"prefix": map[string]interface{}{"Path": pref + "/"}},
+		)
+	}
+	// Limit to a given node type
+	if queryObject.Type > 0 {
+		nodeType := "file"
+		if queryObject.Type == tree.NodeType_COLLECTION {
+			nodeType = "folder"
+		}
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"NodeType": nodeType}})
+	}
+
+	if len(queryObject.Extension) > 0 {
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"Extension": queryObject.Extension}})
+	}
+
+	if len(queryObject.FreeString) > 0 {
+		must = append(must, map[string]interface{}{"query_string": map[string]interface{}{"query": queryObject.FreeString}})
+	}
+
+	if geoQuery := queryObject.GeoQuery; geoQuery != nil {
+		if geoQuery.Center != nil && len(geoQuery.Distance) > 0 {
+			distance := geoQuery.Distance
+			// Distances are expressed in the bleve format, convert them to meters
+			if meters, e := geo.ParseDistance(distance); e == nil {
+				distance = fmt.Sprintf("%fm", meters)
+			}
+			must = append(must, map[string]interface{}{
+				"geo_distance": map[string]interface{}{
+					"distance": distance,
+					"GeoPoint": geoPoint(geoQuery.Center),
+				},
+			})
+		} else if geoQuery.TopLeft != nil && geoQuery.BottomRight != nil {
+			must = append(must, map[string]interface{}{
+				"geo_bounding_box": map[string]interface{}{
+					"GeoPoint": map[string]interface{}{
+						"top_left":     geoPoint(geoQuery.TopLeft),
+						"bottom_right": geoPoint(geoQuery.BottomRight),
+					},
+				},
+			})
+		}
+	}
+
+	if len(must) == 0 && len(mustNot) == 0 {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	boolQuery := map[string]interface{}{}
+	if len(must) > 0 {
+		boolQuery["must"] = must
+	}
+	if len(mustNot) > 0 {
+		boolQuery["must_not"] = mustNot
+	}
+	return map[string]interface{}{"bool": boolQuery}
+
+}
+
+func geoPoint(point *tree.GeoPoint) map[string]interface{} {
+	return map[string]interface{}{"lat": point.Lat, "lon": point.Lon}
+}
+
+// BuildSearchBody builds the full search request, with paging, sort orders, highlighting and aggregations for facets.
+func BuildSearchBody(queryObject *tree.Query, request *tree.SearchRequest) map[string]interface{} {
+
+	body := map[string]interface{}{
+		"query": BuildQuery(queryObject),
+		"from":  request.From,
+	}
+	if request.Size > 0 {
+		body["size"] = request.Size
+	}
+	if len(request.SortFields) > 0 {
+		var sort []interface{}
+		for _, sortField := range request.SortFields {
+			field := sortField.Field
+			if field == "score" {
+				field = "_score"
+			}
+			order := "asc"
+			if sortField.Descending {
+				order = "desc"
+			}
+			sort = append(sort, map[string]interface{}{field: map[string]interface{}{"order": order}})
+		}
+		body["sort"] = sort
+	}
+	if request.Highlight {
+		body["highlight"] = map[string]interface{}{
+			"fields": map[string]interface{}{"TextContent": map[string]interface{}{}},
+		}
+	}
+	if len(request.Facets) > 0 {
+		aggs := map[string]interface{}{}
+		for _, facet := range request.Facets {
+			aggs[facet.Name] = buildAggregation(facet)
+		}
+		body["aggs"] = aggs
+	}
+	return body
+
+}
+
+func buildAggregation(facet *tree.SearchFacetRequest) map[string]interface{} {
+
+	field := facet.Field
+	if len(facet.NumericRanges) > 0 {
+		var ranges []interface{}
+		for _, r := range facet.NumericRanges {
+			rg := map[string]interface{}{"key": r.Label}
+			if r.Min != 0 {
+				rg["from"] = r.Min
+			}
+			if r.Max != 0 {
+				rg["to"] = r.Max
+			}
+			ranges = append(ranges, rg)
+		}
+		return map[string]interface{}{"range": map[string]interface{}{"field": field, "ranges": ranges}}
+	}
+	if len(facet.DateRanges) > 0 {
+		var ranges []interface{}
+		for _, r := range facet.DateRanges {
+			rg := map[string]interface{}{"key": r.Label}
+			if r.Start > 0 {
+				rg["from"] = fmt.Sprintf("%d", r.Start)
+			}
+			if r.End > 0 {
+				rg["to"] = fmt.Sprintf("%d", r.End)
+			}
+			ranges = append(ranges, rg)
+		}
+		return map[string]interface{}{"date_range": map[string]interface{}{"field": field, "format": "epoch_second", "ranges": ranges}}
+	}
+	// Metadata strings are dynamically mapped as text, with a keyword sub-field usable for aggregations
+	if strings.HasPrefix(field, "Meta.") {
+		field += ".keyword"
+	}
+	size := facet.Size
+	if size <= 0 {
+		size = 10
+	}
+	return map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": size}}
+
+}
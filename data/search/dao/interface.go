@@ -18,7 +18,7 @@
  * The latest code can be found at <https://pydio.com>.
  */
 
-// Package dao abstract the indexation engine and provides a bleve-based implementation.
+// Package dao abstract the indexation engine and provides bleve-based and elasticsearch-based implementations.
 package dao
 
 import (
@@ -30,7 +30,9 @@ import (
 type SearchEngine interface {
 	IndexNode(context.Context, *tree.Node) error
 	DeleteNode(context.Context, *tree.Node) error
-	SearchNodes(context.Context, *tree.Query, int32, int32, chan *tree.Node, chan bool) error
+	// SearchNodes sends one response per hit on the results channel, then a last response carrying the
+	// facets if any were requested. The done channel is triggered once all results are sent.
+	SearchNodes(context.Context, *tree.SearchRequest, chan *tree.SearchResponse, chan bool) error
 	ClearIndex(ctx context.Context) error
 	Close() error
 }
@@ -37,12 +37,12 @@ func (s *StubEngine) DeleteNode(context.Context, *tree.Node) error {
 	return nil
 }
 
-func (s *StubEngine) SearchNodes(c context.Context, queryObject *tree.Query, from int32, size int32, resultChan chan *tree.Node, doneChan chan bool) error {
+func (s *StubEngine) SearchNodes(c context.Context, request *tree.SearchRequest, resultChan chan *tree.SearchResponse, doneChan chan bool) error {
 
-	resultChan <- &tree.Node{
+	resultChan <- &tree.SearchResponse{Node: &tree.Node{
 		Uuid: "DocID1",
 		Path: "/path/to/node.txt",
-	}
+	}}
 
 	doneChan <- true
 
@@ -32,8 +32,12 @@ import (
 	"github.com/pydio/cells/common/proto/sync"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service"
+	"github.com/pydio/cells/common/service/context"
 	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/data/search/content"
+	"github.com/pydio/cells/data/search/dao"
 	"github.com/pydio/cells/data/search/dao/bleve"
+	"github.com/pydio/cells/data/search/dao/elastic"
 )
 
 var (
@@ -47,20 +51,46 @@ func init() {
 		service.Description("Search Engine"),
 		service.RouterDependencies(),
 		service.WithMicro(func(m micro.Service) error {
-			var indexContent bool
-
-			var cfg config.Map //TODO
-			if indexConf := cfg.Get("indexContent"); indexConf != nil {
-				indexContent = cfg.Get("indexContent").(bool)
-			}
+			cfg := servicecontext.GetConfig(m.Options().Context)
+			indexContent := cfg.Bool("indexContent", false)
 			dir, _ := config.ServiceDataDir(Name)
+			// Bleve index is stored locally, unless engine is set to "elasticsearch" to use an external cluster
 			bleve.BleveIndexPath = filepath.Join(dir, "searchengine.bleve")
-			bleveEngine, err := bleve.NewBleveEngine(indexContent)
-			if err != nil {
-				return err
+			var extractor *content.Extractor
+			if indexContent {
+				cache, err := content.NewBoltCache(filepath.Join(dir, "searchengine-content.db"))
+				if err != nil {
+					return err
+				}
+				extractor = content.NewExtractor(content.Options{
+					Workers:   cfg.Int("contentWorkers", content.DefaultWorkers),
+					QueueSize: cfg.Int("contentQueueSize", content.DefaultQueueSize),
+					MaxSize:   cfg.Int64("contentMaxSize", content.DefaultMaxSize),
+					MimeTypes: cfg.StringArray("contentMimeTypes"),
+					Cache:     cache,
+				})
+			}
+			var engine dao.SearchEngine
+			if cfg.String("engine") == "elasticsearch" {
+				elasticEngine, err := elastic.NewElasticEngine(cfg.String("elasticUrl"), cfg.String("elasticIndex"), indexContent, extractor)
+				if err != nil {
+					return err
+				}
+				elasticEngine.Username = cfg.String("elasticUser")
+				elasticEngine.Password = cfg.String("elasticPassword")
+				if err := elasticEngine.Init(m.Options().Context); err != nil {
+					return err
+				}
+				engine = elasticEngine
+			} else {
+				bleveEngine, err := bleve.NewBleveEngine(indexContent, extractor)
+				if err != nil {
+					return err
+				}
+				engine = bleveEngine
 			}
 			server := &SearchServer{
-				Engine:     bleveEngine,
+				Engine:     engine,
 				TreeClient: tree.NewNodeProviderClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, defaults.NewClient()),
 			}
 
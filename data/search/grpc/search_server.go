@@ -114,8 +114,9 @@ func (s *SearchServer) processEvent(ctx context.Context, e *tree.NodeChangeEvent
 
 func (s *SearchServer) Search(ctx context.Context, req *tree.SearchRequest, streamer tree.Searcher_SearchStream) error {
 
-	resultsChan := make(chan *tree.Node)
-	doneChan := make(chan bool)
+	resultsChan := make(chan *tree.SearchResponse)
+	// Buffered, so that the engine never blocks on completion once results consumption has stopped
+	doneChan := make(chan bool, 1)
 	defer close(resultsChan)
 	defer close(doneChan)
 
@@ -123,10 +124,18 @@ func (s *SearchServer) Search(ctx context.Context, req *tree.SearchRequest, stre
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+	loop:
 		for {
 			select {
-			case node := <-resultsChan:
-				if node != nil {
+			case result := <-resultsChan:
+				if result == nil {
+					break loop
+				}
+				node := result.Node
+				if node == nil {
+					// Facets results
+					streamer.Send(result)
+				} else {
 
 					log.Logger(ctx).Info("Search", zap.String("uuid", node.Uuid))
 
@@ -135,7 +144,7 @@ func (s *SearchServer) Search(ctx context.Context, req *tree.SearchRequest, stre
 							Uuid: node.Uuid,
 						}})
 						if e == nil {
-							streamer.Send(&tree.SearchResponse{Node: response.Node})
+							streamer.Send(&tree.SearchResponse{Node: response.Node, Highlights: result.Highlights})
 						} else if errors.Parse(e.Error()).Code == 404 {
 
 							log.Logger(ctx).Error("Found node that does not exists, send event to make sure all is sync'ed.", zap.String("uuid", node.Uuid))
@@ -148,7 +157,7 @@ func (s *SearchServer) Search(ctx context.Context, req *tree.SearchRequest, stre
 						}
 					} else {
 						log.Logger(ctx).Info("No Details needed, sending back %v", zap.String("uuid", node.Uuid))
-						streamer.Send(&tree.SearchResponse{Node: node})
+						streamer.Send(result)
 					}
 
 				}
@@ -158,7 +167,7 @@ func (s *SearchServer) Search(ctx context.Context, req *tree.SearchRequest, stre
 		}
 	}()
 
-	err := s.Engine.SearchNodes(ctx, req.GetQuery(), req.GetFrom(), req.GetSize(), resultsChan, doneChan)
+	err := s.Engine.SearchNodes(ctx, req, resultsChan, doneChan)
 	if err != nil {
 		return err
 	}
@@ -22,9 +22,12 @@
 package rest
 
 import (
+	"context"
+	"net/http"
 	"strings"
 
 	"github.com/emicklei/go-restful"
+	"github.com/micro/go-micro/errors"
 	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
@@ -33,6 +36,7 @@ import (
 	"github.com/pydio/cells/common/proto/rest"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
 	"github.com/pydio/cells/common/views"
 )
 
@@ -65,6 +69,32 @@ func (s *Handler) getClient() tree.SearcherClient {
 	return s.client
 }
 
+// deniedPaths finds the index paths of the nodes that the access list does not let the user read, like
+// denied or write-only folders below the workspaces roots. They are excluded from the engine query,
+// so that neither results nor facets reveal their content.
+func (s *Handler) deniedPaths(ctx context.Context, accessList *utils.AccessList) ([]string, error) {
+	var paths []string
+	treeClient := tree.NewNodeProviderClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_TREE, defaults.NewClient())
+	for nodeId, mask := range accessList.GetNodesBitmasks() {
+		node := &tree.Node{Uuid: nodeId}
+		denied := mask.HasFlag(ctx, utils.FLAG_DENY, node)
+		if !denied && (mask.BitmaskFlag&(utils.FLAG_READ|utils.FLAG_WRITE|utils.FLAG_POLICY) == 0 || mask.HasFlag(ctx, utils.FLAG_READ, node)) {
+			continue
+		}
+		rsp, err := treeClient.ReadNode(ctx, &tree.ReadNodeRequest{Node: node})
+		if err != nil {
+			if errors.Parse(err.Error()).Code == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		paths = append(paths, rsp.Node.Path)
+	}
+	return paths, nil
+}
+
+// Nodes searches the index below the workspaces of the current user, excluding the nodes that the user
+// cannot read, and returns the results along with the facets computed by the engine.
 func (s *Handler) Nodes(req *restful.Request, rsp *restful.Response) {
 
 	ctx := req.Request.Context()
@@ -83,6 +113,7 @@ func (s *Handler) Nodes(req *restful.Request, rsp *restful.Response) {
 	router := s.getRouter()
 
 	var nodes []*tree.Node
+	var facets []*tree.SearchFacet
 	prefixes := []string{}
 	nodesPrefixes := map[string]string{}
 	var passedPrefix string
@@ -133,6 +164,11 @@ func (s *Handler) Nodes(req *restful.Request, rsp *restful.Response) {
 			nodesPrefixes[rootNode.Path] = p
 			query.PathPrefix = append(query.PathPrefix, rootNode.Path)
 		}
+		if accessList, e := views.AccessListFromContext(ctx); e == nil {
+			if query.ExcludedPathPrefix, e = s.deniedPaths(ctx, accessList); e != nil {
+				return e
+			}
+		}
 
 		sClient, err := s.getClient().Search(ctx, &searchRequest)
 		if err != nil {
@@ -148,7 +184,15 @@ func (s *Handler) Nodes(req *restful.Request, rsp *restful.Response) {
 			} else if rErr != nil {
 				return err
 			}
+			if resp.Node == nil {
+				// Facets are sent in a last response without node
+				facets = append(facets, resp.Facets...)
+				continue
+			}
 			respNode := resp.Node
+			if len(resp.Highlights) > 0 {
+				respNode.SetMeta("search_highlights", resp.Highlights)
+			}
 			for r, p := range nodesPrefixes {
 				if strings.HasPrefix(respNode.Path, r) {
 					log.Logger(ctx).Debug("Response", zap.String("node", respNode.Path))
@@ -181,6 +225,7 @@ func (s *Handler) Nodes(req *restful.Request, rsp *restful.Response) {
 	result := &rest.SearchResults{
 		Results: nodes,
 		Total:   int32(len(nodes)),
+		Facets:  facets,
 	}
 	rsp.WriteEntity(result)
 
@@ -23,15 +23,22 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	minio "github.com/pydio/minio-srv/cmd"
+	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/object"
 	"github.com/pydio/cells/data/source/objects"
 )
 
+var (
+	// SmbRetryInterval is the delay before starting again an SMB gateway that failed
+	SmbRetryInterval = 30 * time.Second
+)
+
 // ObjectHandler definition
 type ObjectHandler struct {
 	Config *object.MinioConfig
@@ -40,6 +47,10 @@ type ObjectHandler struct {
 // StartMinioServer handler
 func (o *ObjectHandler) StartMinioServer(ctx context.Context, minioServiceName string) error {
 
+	if o.Config.StorageType == object.StorageType_SMB {
+		return o.StartSmbGateway(ctx, minioServiceName)
+	}
+
 	var gateway, folderName, customEndpoint string
 	if o.Config.StorageType == object.StorageType_S3 {
 		gateway = "s3"
@@ -91,6 +102,38 @@ func (o *ObjectHandler) StartMinioServer(ctx context.Context, minioServiceName s
 
 }
 
+// StartSmbGateway serves the SMB share described by the EndpointUrl with the minio gateway, using the
+// same keys as the share credentials. The gateway is restarted if it fails, until ctx is done.
+func (o *ObjectHandler) StartSmbGateway(ctx context.Context, minioServiceName string) error {
+
+	if o.Config.ApiKey == "" {
+		return errors.New("missing accessKey to start minio service")
+	}
+	configFolder, e := objects.CreateMinioConfigFile(minioServiceName, o.Config.ApiKey, o.Config.ApiSecret)
+	if e != nil {
+		return e
+	}
+	port := o.Config.RunningPort
+	if port == 0 {
+		port = 9000
+	}
+
+	log.Logger(ctx).Info("Starting SMB objects service " + minioServiceName)
+	for {
+		e := minio.NewSmbGateway(ctx, fmt.Sprintf(":%d", port), configFolder, o.Config.EndpointUrl)
+		if e == nil || ctx.Err() != nil {
+			return e
+		}
+		log.Logger(ctx).Error("SMB objects service "+minioServiceName+" stopped, restarting", zap.Error(e))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(SmbRetryInterval):
+		}
+	}
+
+}
+
 // GetHttpURL of handler
 func (o *ObjectHandler) GetMinioConfig(ctx context.Context, req *object.GetMinioConfigRequest, resp *object.GetMinioConfigResponse) error {
 
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	minioclient "github.com/pydio/minio-go"
+	minio "github.com/pydio/minio-srv/cmd"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+
+	"github.com/pydio/cells/common/smb"
+)
+
+func TestSmbGateway(t *testing.T) {
+
+	Convey("SMB shares are served with the minio gateway", t, func() {
+
+		fs := afero.NewMemMapFs()
+		fs.MkdirAll("/base/bucket1/folder", 0755)
+		afero.WriteFile(fs, "/base/bucket1/folder/existing.txt", []byte("written on the share"), 0644)
+		server := &smb.Server{Fs: fs, Share: "data", User: "admin", Password: "P@ssw0rd", Domain: "CELLS"}
+		sl, e := net.Listen("tcp", "127.0.0.1:0")
+		So(e, ShouldBeNil)
+		defer sl.Close()
+		go server.Serve(sl)
+
+		configDir, _ := ioutil.TempDir("", "smb-gateway")
+		defer os.RemoveAll(configDir)
+		conf := minio.CreateEmptyMinioConfig()
+		conf.Credential.AccessKey = "CELLS\\admin"
+		conf.Credential.SecretKey = "P@ssw0rd"
+		data, _ := json.Marshal(conf)
+		So(ioutil.WriteFile(filepath.Join(configDir, "config.json"), data, 0600), ShouldBeNil)
+
+		gl, _ := net.Listen("tcp", "127.0.0.1:0")
+		gatewayAddr := gl.Addr().String()
+		gl.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- minio.NewSmbGateway(ctx, gatewayAddr, configDir, fmt.Sprintf("smb://%s/data/base", sl.Addr().String()))
+		}()
+		core, e := minioclient.NewCore(gatewayAddr, "CELLS\\admin", "P@ssw0rd", false)
+		So(e, ShouldBeNil)
+		// Same user agent as the sync clients, identified as system by the objects handlers
+		core.SetAppInfo("pydio.sync.client.s3", "1.0")
+		var buckets []minioclient.BucketInfo
+		for i := 0; i < 50; i++ {
+			if buckets, e = core.ListBuckets(); e == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		So(e, ShouldBeNil)
+		So(buckets, ShouldHaveLength, 1)
+		So(buckets[0].Name, ShouldEqual, "bucket1")
+
+		// Files created directly on the share
+		info, e := core.StatObject("bucket1", "folder/existing.txt", minioclient.StatObjectOptions{})
+		So(e, ShouldBeNil)
+		So(info.Size, ShouldEqual, 20)
+		So(info.ETag, ShouldEqual, "7db1c6e42ce06c1d8597b8b30fb070c2")
+
+		// Put, ranged get and list
+		_, e = core.PutObject("bucket1", "folder/new.txt", bytes.NewReader([]byte("new content")), 11, nil, nil, map[string]string{"X-Amz-Meta-Pydio-Node-Uuid": "uuid"})
+		So(e, ShouldBeNil)
+		stored, _ := afero.ReadFile(fs, "/base/bucket1/folder/new.txt")
+		So(string(stored), ShouldEqual, "new content")
+		info, e = core.StatObject("bucket1", "folder/new.txt", minioclient.StatObjectOptions{})
+		So(e, ShouldBeNil)
+		So(info.Metadata.Get("X-Amz-Meta-Pydio-Node-Uuid"), ShouldEqual, "uuid")
+		opts := minioclient.GetObjectOptions{}
+		opts.SetRange(4, 6)
+		reader, _, e := core.GetObject("bucket1", "folder/new.txt", opts)
+		So(e, ShouldBeNil)
+		read, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		So(string(read), ShouldEqual, "con")
+
+		result, e := core.ListObjects("bucket1", "", "", "", 1000)
+		So(e, ShouldBeNil)
+		So(result.Contents, ShouldHaveLength, 2)
+		So(result.Contents[0].Key, ShouldEqual, "folder/existing.txt")
+		So(result.Contents[1].Key, ShouldEqual, "folder/new.txt")
+		result, e = core.ListObjects("bucket1", "", "", "/", 1000)
+		So(e, ShouldBeNil)
+		So(result.Contents, ShouldHaveLength, 0)
+		So(result.CommonPrefixes, ShouldHaveLength, 1)
+		So(result.CommonPrefixes[0].Prefix, ShouldEqual, "folder/")
+
+		// Copy and delete
+		_, e = core.CopyObject("bucket1", "folder/new.txt", "bucket1", "copy/new.txt", map[string]string{})
+		So(e, ShouldBeNil)
+		stored, _ = afero.ReadFile(fs, "/base/bucket1/copy/new.txt")
+		So(string(stored), ShouldEqual, "new content")
+		So(core.RemoveObject("bucket1", "copy/new.txt"), ShouldBeNil)
+		_, e = fs.Stat("/base/bucket1/copy")
+		So(os.IsNotExist(e), ShouldBeTrue)
+
+		// Multipart upload
+		uploadID, e := core.NewMultipartUpload("bucket1", "big.bin", minioclient.PutObjectOptions{})
+		So(e, ShouldBeNil)
+		part1 := bytes.Repeat([]byte("a"), 5*1024*1024)
+		p1, e := core.PutObjectPart("bucket1", "big.bin", uploadID, 1, bytes.NewReader(part1), int64(len(part1)), nil, nil)
+		So(e, ShouldBeNil)
+		p2, e := core.PutObjectPart("bucket1", "big.bin", uploadID, 2, bytes.NewReader([]byte("end")), 3, nil, nil)
+		So(e, ShouldBeNil)
+		So(core.CompleteMultipartUpload("bucket1", "big.bin", uploadID, []minioclient.CompletePart{
+			{PartNumber: 1, ETag: p1.ETag},
+			{PartNumber: 2, ETag: p2.ETag},
+		}), ShouldBeNil)
+		st, e := fs.Stat("/base/bucket1/big.bin")
+		So(e, ShouldBeNil)
+		So(st.Size(), ShouldEqual, len(part1)+3)
+		uploads, _ := afero.ReadDir(fs, "/base/.minio.sys/multipart")
+		So(uploads, ShouldHaveLength, 0)
+
+		// Connection to the share is established again after a server restart
+		server.Disconnect()
+		info, e = core.StatObject("bucket1", "folder/new.txt", minioclient.StatObjectOptions{})
+		So(e, ShouldBeNil)
+		So(info.Size, ShouldEqual, 11)
+
+		// Gateway stops with the context
+		cancel()
+		select {
+		case e = <-done:
+			So(e, ShouldBeNil)
+		case <-time.After(10 * time.Second):
+			So("gateway did not stop", ShouldBeEmpty)
+		}
+		_, e = net.DialTimeout("tcp", gatewayAddr, time.Second)
+		So(e, ShouldNotBeNil)
+	})
+}
@@ -97,6 +97,18 @@ func parseUrl(urlArg string, otherArgs []string) (endpoint common.Endpoint, e er
 			}
 			return client, nil
 		}
+	} else if u.Scheme == "smb" {
+		parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+		share := parts[0]
+		var rootPath string
+		if len(parts) > 1 {
+			rootPath = parts[1]
+		}
+		if u.User == nil {
+			return nil, errors.New("Please provide user and password in URL")
+		}
+		password, _ := u.User.Password()
+		return endpoints.NewSMBClient(u.Host, share, u.User.Username(), password, rootPath)
 	} else {
 		return nil, errors.New("Unsupported scheme " + u.Scheme)
 	}
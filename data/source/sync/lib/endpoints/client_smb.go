@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package endpoints
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	servicescommon "github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/smb"
+	"github.com/pydio/cells/data/source/sync/lib/common"
+)
+
+var (
+	// SMBPollInterval is the default frequency at which SMB shares are scanned for changes
+	SMBPollInterval = 10 * time.Second
+)
+
+// SMBClient implementation of an endpoint on a remote SMB/CIFS share, that does not
+// need to be mounted on the host.
+// Implements all Sync interfaces (PathSyncTarget, PathSyncSource, DataSyncTarget and DataSyncSource)
+// by reusing the FSClient on top of an afero.Fs backed by the share. As SMB servers do not send native
+// FS events, changes are detected by periodically scanning the tree.
+type SMBClient struct {
+	FSClient
+	PollInterval time.Duration
+
+	client *smb.Client
+}
+
+// smbSnapshotEntry stores the stat info used to detect changes between two scans
+type smbSnapshotEntry struct {
+	folder bool
+	size   int64
+	mtime  time.Time
+}
+
+// NewSMBClient connects to a share and creates an SMBClient rooted on rootPath inside this share.
+// User can be passed in the "DOMAIN\user" form. The share is mounted again if the connection is lost.
+func NewSMBClient(address string, shareName string, user string, password string, rootPath string) (*SMBClient, error) {
+	client := smb.NewClient(address, shareName, user, password)
+	if e := client.Connect(); e != nil {
+		return nil, e
+	}
+	c, e := newSMBClient(smb.NewClientFs(client), rootPath)
+	if e != nil {
+		client.Close()
+		return nil, e
+	}
+	c.client = client
+	return c, nil
+}
+
+// NewSMBClientFromShare creates an SMBClient using an already mounted share.
+func NewSMBClientFromShare(share *smb.Share, rootPath string) (*SMBClient, error) {
+	return newSMBClient(smb.NewFs(share), rootPath)
+}
+
+func newSMBClient(fs afero.Fs, rootPath string) (*SMBClient, error) {
+	c := &SMBClient{
+		PollInterval: SMBPollInterval,
+	}
+	rootPath = "/" + strings.Trim(strings.Replace(rootPath, "\\", "/", -1), "/")
+	c.RootPath = rootPath
+	if rootPath != "/" {
+		fs = afero.NewBasePathFs(fs, rootPath)
+	}
+	c.FS = fs
+	log.Print("Initiating SMB Client with root ", rootPath)
+	if _, e := c.FS.Stat("/"); e != nil {
+		return nil, errors.New("Cannot stat root folder " + rootPath + " on SMB share!")
+	}
+	return c, nil
+}
+
+func (c *SMBClient) GetEndpointInfo() common.EndpointInfo {
+
+	return common.EndpointInfo{
+		RequiresFoldersRescan: true,
+		RequiresNormalization: false,
+	}
+
+}
+
+func (c *SMBClient) GetWriterOn(path string, targetSize int64) (out io.WriteCloser, err error) {
+
+	return c.FS.OpenFile(c.denormalize(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+
+}
+
+// Close unmounts the share and closes the session, if they were opened by this client.
+func (c *SMBClient) Close() error {
+	if c.client != nil {
+		return c.client.Close()
+	}
+	return nil
+}
+
+// Watch periodically scans the tree under recursivePath and sends events for
+// created, modified and deleted nodes.
+func (c *SMBClient) Watch(recursivePath string) (*common.WatchObject, error) {
+
+	eventChan := make(chan common.EventInfo)
+	errorChan := make(chan error)
+	doneChan := make(chan bool)
+
+	previous, e := c.snapshot(recursivePath)
+	if e != nil {
+		return nil, e
+	}
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = SMBPollInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer func() {
+			ticker.Stop()
+			log.Println("Closing event channel for SMB " + c.RootPath)
+			close(eventChan)
+			close(errorChan)
+		}()
+		for {
+			select {
+			case <-doneChan:
+				return
+			case <-ticker.C:
+				current, err := c.snapshot(recursivePath)
+				if err != nil {
+					select {
+					case errorChan <- err:
+					case <-doneChan:
+						return
+					}
+					continue
+				}
+				for _, eventInfo := range c.diffSnapshots(previous, current) {
+					select {
+					case eventChan <- eventInfo:
+					case <-doneChan:
+						return
+					}
+				}
+				previous = current
+			}
+		}
+	}()
+
+	return &common.WatchObject{
+		EventInfoChan: eventChan,
+		ErrorChan:     errorChan,
+		DoneChan:      doneChan,
+	}, nil
+}
+
+// snapshot lists the tree under recursivePath without computing hashes.
+func (c *SMBClient) snapshot(recursivePath string) (map[string]smbSnapshotEntry, error) {
+	entries := make(map[string]smbSnapshotEntry)
+	err := afero.Walk(c.FS, c.denormalize(recursivePath), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(path) == 0 || path == "/" || common.IsIgnoredFile(path) || strings.HasSuffix(path, servicescommon.PYDIO_SYNC_HIDDEN_FILE_META) {
+			return nil
+		}
+		entries[c.normalize(path)] = smbSnapshotEntry{
+			folder: info.IsDir(),
+			size:   info.Size(),
+			mtime:  info.ModTime(),
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// diffSnapshots transforms the differences between two scans into events, parents
+// being always sent before their children.
+func (c *SMBClient) diffSnapshots(previous, current map[string]smbSnapshotEntry) (events []common.EventInfo) {
+	var created, removed []string
+	for path, entry := range current {
+		if old, ok := previous[path]; !ok || old.folder != entry.folder {
+			created = append(created, path)
+		} else if !entry.folder && (old.size != entry.size || !old.mtime.Equal(entry.mtime)) {
+			created = append(created, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sortByDepth(created)
+	sortByDepth(removed)
+	for _, path := range removed {
+		// Children of a removed folder are removed with their parent
+		if _, parentRemoved := previous[common.DirWithInternalSeparator(path)]; parentRemoved && !inMap(current, common.DirWithInternalSeparator(path)) {
+			continue
+		}
+		events = append(events, common.EventInfo{
+			Time:           now(),
+			Path:           path,
+			Type:           common.EventRemove,
+			PathSyncSource: c,
+		})
+	}
+	for _, path := range created {
+		entry := current[path]
+		events = append(events, common.EventInfo{
+			Time:           now(),
+			Size:           entry.size,
+			Folder:         entry.folder,
+			Path:           path,
+			Type:           common.EventCreate,
+			PathSyncSource: c,
+		})
+	}
+	return
+}
+
+func inMap(m map[string]smbSnapshotEntry, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// sortByDepth sorts paths so that parents come before their children
+func sortByDepth(paths []string) {
+	depth := func(p string) int {
+		return strings.Count(p, common.InternalPathSeparator)
+	}
+	for i := 1; i < len(paths); i++ {
+		for j := i; j > 0 && (depth(paths[j]) < depth(paths[j-1]) || depth(paths[j]) == depth(paths[j-1]) && paths[j] < paths[j-1]); j-- {
+			paths[j], paths[j-1] = paths[j-1], paths[j]
+		}
+	}
+}
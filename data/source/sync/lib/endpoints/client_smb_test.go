@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package endpoints
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/smb"
+	"github.com/pydio/cells/data/source/sync/lib/common"
+)
+
+func smbMockedClient(t *testing.T) (*SMBClient, afero.Fs, func()) {
+
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/root/folder/subfolder", 0777)
+	afero.WriteFile(fs, "/root/file", []byte("my-content"), 0777)
+	afero.WriteFile(fs, "/root/folder/subfolder/file1.txt", []byte("my-content"), 0777)
+
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	server := &smb.Server{Fs: fs, Share: "data", User: "user", Password: "password", Domain: "WORKGROUP"}
+	go server.Serve(l)
+
+	client, e := NewSMBClient(l.Addr().String(), "data", "WORKGROUP\\user", "password", "/root")
+	if e != nil {
+		l.Close()
+		t.Fatal(e)
+	}
+	return client, fs, func() {
+		client.Close()
+		l.Close()
+	}
+
+}
+
+func TestSMBClient(t *testing.T) {
+
+	Convey("Test wrong credentials", t, func() {
+		l, e := net.Listen("tcp", "127.0.0.1:0")
+		So(e, ShouldBeNil)
+		defer l.Close()
+		go (&smb.Server{Fs: afero.NewMemMapFs(), Share: "data", User: "user", Password: "password"}).Serve(l)
+		_, e = NewSMBClient(l.Addr().String(), "data", "user", "wrong", "")
+		So(e, ShouldNotBeNil)
+	})
+
+	Convey("Test LoadNode and Walk", t, func() {
+		c, _, closer := smbMockedClient(t)
+		defer closer()
+
+		node, e := c.LoadNode(context.Background(), "file")
+		So(e, ShouldBeNil)
+		So(node.Type, ShouldEqual, tree.NodeType_LEAF)
+		So(node.Size, ShouldEqual, 10)
+		So(node.Etag, ShouldNotBeEmpty)
+
+		var paths []string
+		e = c.Walk(func(path string, node *tree.Node, err error) {
+			if err == nil && !strings.HasSuffix(path, ".pydio") {
+				paths = append(paths, path)
+			}
+		})
+		So(e, ShouldBeNil)
+		So(paths, ShouldContain, "file")
+		So(paths, ShouldContain, "folder/subfolder")
+		So(paths, ShouldContain, "folder/subfolder/file1.txt")
+	})
+
+	Convey("Test writing, moving and deleting", t, func() {
+		c, fs, closer := smbMockedClient(t)
+		defer closer()
+
+		e := c.CreateNode(context.Background(), &tree.Node{Path: "new-folder", Type: tree.NodeType_COLLECTION, Uuid: "folder-uuid"}, false)
+		So(e, ShouldBeNil)
+		folder, e := c.LoadNode(context.Background(), "new-folder")
+		So(e, ShouldBeNil)
+		So(folder.Uuid, ShouldEqual, "folder-uuid")
+
+		// Overwriting an existing file must truncate it
+		w, e := c.GetWriterOn("file", 3)
+		So(e, ShouldBeNil)
+		_, e = w.Write([]byte("new"))
+		So(e, ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+		r, e := c.GetReaderOn("file")
+		So(e, ShouldBeNil)
+		data, _ := ioutil.ReadAll(r)
+		r.Close()
+		So(string(data), ShouldEqual, "new")
+
+		So(c.MoveNode(context.Background(), "file", "new-folder/file"), ShouldBeNil)
+		exists, _ := afero.Exists(fs, "/root/new-folder/file")
+		So(exists, ShouldBeTrue)
+
+		So(c.DeleteNode(context.Background(), "new-folder"), ShouldBeNil)
+		exists, _ = afero.Exists(fs, "/root/new-folder")
+		So(exists, ShouldBeFalse)
+	})
+
+	Convey("Test Watch", t, func() {
+		c, fs, closer := smbMockedClient(t)
+		defer closer()
+		c.PollInterval = 50 * time.Millisecond
+
+		w, e := c.Watch("")
+		So(e, ShouldBeNil)
+		defer close(w.DoneChan)
+
+		fs.MkdirAll("/root/watched", 0777)
+		afero.WriteFile(fs, "/root/watched/created.txt", []byte("content"), 0777)
+		fs.Remove("/root/file")
+
+		events := make(map[string]common.EventInfo)
+		timeout := time.After(5 * time.Second)
+		for len(events) < 3 {
+			select {
+			case ev := <-w.EventInfoChan:
+				events[ev.Path] = ev
+			case <-timeout:
+				t.Fatal("Watch did not send expected events")
+			}
+		}
+		So(events["watched"].Type, ShouldEqual, common.EventCreate)
+		So(events["watched"].Folder, ShouldBeTrue)
+		So(events["watched/created.txt"].Type, ShouldEqual, common.EventCreate)
+		So(events["watched/created.txt"].Size, ShouldEqual, 7)
+		So(events["file"].Type, ShouldEqual, common.EventRemove)
+	})
+
+}
@@ -325,7 +325,7 @@ func (s *TreeServer) ListNodesWithLimit(ctx context.Context, req *tree.ListNodes
 			Recursive: req.Recursive,
 			//Limit:      req.Limit,
 			WithCommits: req.WithCommits,
-			//FilterType: req.FilterType,
+			FilterType:  req.FilterType,
 		}
 
 		stream, err := ds.reader.ListNodes(ctx, req)
@@ -22,11 +22,13 @@ package versions
 
 import (
 	"context"
+	"time"
 
 	"github.com/micro/go-micro/client"
 	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/chunks"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/jobs"
 	"github.com/pydio/cells/common/proto/tree"
@@ -75,7 +77,16 @@ func (c *PruneVersionsAction) Run(ctx context.Context, channels *actions.Runnabl
 	versionClient := tree.NewNodeVersionerClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_VERSIONS, defaults.NewClient())
 	if response, err := versionClient.PruneVersions(ctx, &tree.PruneVersionsRequest{AllDeletedNodes: true}); err == nil {
 		log.Logger(ctx).Debug("Client responded", zap.Any("resp", response))
+		store := chunks.NewStore(source.Client, source.ObjectsBucket)
 		for _, versionFileId := range response.DeletedVersions {
+			if manifest, e := store.Manifest(versionFileId); e == nil && manifest != nil {
+				if e := store.Delete(versionFileId); e != nil {
+					log.Logger(ctx).Error("Error while trying to remove version manifest", zap.String("fileId", versionFileId), zap.Error(e))
+				} else {
+					log.Logger(ctx).Info("[Prune Versions Task] Removed manifest from versions bucket", zap.String("fileId", versionFileId))
+				}
+				continue
+			}
 			err := source.Client.RemoveObject(source.ObjectsBucket, versionFileId)
 			if err != nil {
 				log.Logger(ctx).Error("Error while trying to remove file", zap.String("fileId", versionFileId), zap.Error(err))
@@ -83,6 +94,12 @@ func (c *PruneVersionsAction) Run(ctx context.Context, channels *actions.Runnabl
 				log.Logger(ctx).Info("[Prune Versions Task] Removed file from versions bucket", zap.String("fileId", versionFileId))
 			}
 		}
+		// Remove chunks that are not used by any version anymore. Recent chunks are kept, as versions may be being written.
+		removed, err := store.Collect(time.Now().Add(-1 * time.Hour))
+		if err != nil {
+			return input.WithError(err), err
+		}
+		log.Logger(ctx).Info("[Prune Versions Task] Removed unused chunks from versions bucket", zap.Int("count", len(removed)))
 	} else {
 		return input.WithError(err), err
 	}
@@ -29,8 +29,10 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/chunks"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/object"
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
 	"github.com/pydio/cells/common/utils"
@@ -73,13 +75,8 @@ func (c *VersionAction) Run(ctx context.Context, channels *actions.RunnableChann
 		return input.WithIgnore(), nil // Ignore
 	}
 	T := lang.Bundle().GetTranslationFunc(utils.GetDefaultLanguage())
-	var hasPolicy bool
-	if nodeSource, e := c.Pool.GetDataSourceInfo(node.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME)); e == nil {
-		if nodeSource.VersioningPolicyName != "" {
-			hasPolicy = true
-		}
-	}
-	if !hasPolicy {
+	nodeSource, e := c.Pool.GetDataSourceInfo(node.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME))
+	if e != nil || nodeSource.VersioningPolicyName == "" {
 		return input.WithIgnore(), nil
 	}
 
@@ -114,7 +111,15 @@ func (c *VersionAction) Run(ctx context.Context, channels *actions.RunnableChann
 	}
 	targetNode.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, targetNode.Path)
 	sourceNode := proto.Clone(node).(*tree.Node)
-	written, err := c.Handler.CopyObject(ctx, sourceNode, targetNode, &views.CopyRequestData{})
+	var written int64
+	if source.EncryptionMode == object.EncryptionMode_CLEAR && nodeSource.EncryptionMode == object.EncryptionMode_CLEAR {
+		// Store content as deduplicated chunks
+		written, err = c.storeChunks(ctx, source, sourceNode, targetNode.Path, resp.Version)
+	} else {
+		// Chunks are addressed and stored by their clear content: keep full copies of encrypted
+		// files, or on encrypted versions stores
+		written, err = c.Handler.CopyObject(ctx, sourceNode, targetNode, &views.CopyRequestData{})
+	}
 
 	output := input
 	output.AppendOutput(&jobs.ActionOutput{
@@ -131,9 +136,20 @@ func (c *VersionAction) Run(ctx context.Context, channels *actions.RunnableChann
 			Success:    true,
 			StringBody: T("Job.Version.StatusMeta", resp.Version),
 		})
+		store := chunks.NewStore(source.Client, source.ObjectsBucket)
 		for _, version := range response.PruneVersions {
+			versionPath := node.Uuid + "__" + version.Uuid
+			if manifest, e := store.Manifest(versionPath); e != nil {
+				return input.WithError(e), e
+			} else if manifest != nil {
+				// Chunks are shared, they are collected by the prune job
+				if errDel := store.Delete(versionPath); errDel != nil {
+					return input.WithError(errDel), errDel
+				}
+				continue
+			}
 			ctx = views.WithBranchInfo(ctx, "in", views.BranchInfo{LoadedSource: source})
-			deleteNode := &tree.Node{Path: node.Uuid + "__" + version.Uuid}
+			deleteNode := &tree.Node{Path: versionPath}
 			deleteNode.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, deleteNode.Path)
 			_, errDel := c.Handler.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: deleteNode})
 			if errDel != nil {
@@ -152,3 +168,25 @@ func (c *VersionAction) Run(ctx context.Context, channels *actions.RunnableChann
 
 	return output, nil
 }
+
+// storeChunks reads the node content and writes it as a manifest of deduplicated chunks. It returns the
+// size of the version, and sets the number of bytes actually stored and the chunks used on the version.
+// Chunks hold clear content: it must only be used for clear datasources and versions stores.
+func (c *VersionAction) storeChunks(ctx context.Context, source views.LoadedSource, node *tree.Node, versionPath string, version *tree.ChangeLog) (int64, error) {
+	reader, err := c.Handler.GetObject(ctx, node, &views.GetRequestData{StartOffset: 0, Length: -1})
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	manifest, stored, err := chunks.NewStore(source.Client, source.ObjectsBucket).Write(versionPath, reader)
+	if err != nil {
+		return 0, err
+	}
+	version.StoredSize = stored
+	version.Chunks = make(map[string]int64, len(manifest.Chunks))
+	for _, c := range manifest.Chunks {
+		version.Chunks[c.Hash] = c.Size
+	}
+	log.Logger(ctx).Debug("[VERSIONING] Stored chunks", zap.Int64("size", manifest.Size), zap.Int64("stored", stored), zap.Int("chunks", len(manifest.Chunks)))
+	return manifest.Size, nil
+}
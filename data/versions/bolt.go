@@ -179,6 +179,40 @@ func (b *BoltStore) StoreVersion(nodeUuid string, log *tree.ChangeLog) error {
 	})
 }
 
+// UpdateVersion replaces an existing version in the node bucket, keeping its position.
+func (b *BoltStore) UpdateVersion(nodeUuid string, log *tree.ChangeLog) error {
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+
+		bucket := tx.Bucket(bucketName)
+		if bucket == nil {
+			return errors.NotFound(common.SERVICE_VERSIONS, "bucket not found")
+		}
+		nodeBucket := bucket.Bucket([]byte(nodeUuid))
+		if nodeBucket == nil {
+			return errors.NotFound(common.SERVICE_VERSIONS, "no versions found for node %s", nodeUuid)
+		}
+		var key []byte
+		c := nodeBucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			vers := &tree.ChangeLog{}
+			if e := proto.Unmarshal(v, vers); e == nil && vers.Uuid == log.Uuid {
+				key = k
+				break
+			}
+		}
+		if key == nil {
+			return errors.NotFound(common.SERVICE_VERSIONS, "version %s not found", log.Uuid)
+		}
+		newValue, e := proto.Marshal(log)
+		if e != nil {
+			return e
+		}
+		return nodeBucket.Put(key, newValue)
+
+	})
+}
+
 // GetVersion retrieves a specific version from the node bucket.
 func (b *BoltStore) GetVersion(nodeUuid string, versionId string) (*tree.ChangeLog, error) {
 
@@ -163,4 +163,33 @@ func TestBoltStore_CRUD(t *testing.T) {
 
 	})
 
+	Convey("Test UpdateVersion", t, func() {
+
+		p := filepath.Join(os.TempDir(), "bolt-test3.db")
+		bs, e := NewBoltStore(p, true)
+		So(e, ShouldBeNil)
+		defer bs.Close()
+		defer os.Remove(p)
+
+		e = bs.StoreVersion("uuid", &tree.ChangeLog{Uuid: "version1", Data: []byte("etag1")})
+		So(e, ShouldBeNil)
+		e = bs.StoreVersion("uuid", &tree.ChangeLog{Uuid: "version2", Data: []byte("etag2")})
+		So(e, ShouldBeNil)
+
+		e = bs.UpdateVersion("uuid", &tree.ChangeLog{Uuid: "version1", Data: []byte("etag1"), Label: "signed contract", Description: "Signed by both parties", Pinned: true})
+		So(e, ShouldBeNil)
+
+		specific, e := bs.GetVersion("uuid", "version1")
+		So(specific, ShouldResemble, &tree.ChangeLog{Uuid: "version1", Data: []byte("etag1"), Label: "signed contract", Description: "Signed by both parties", Pinned: true})
+
+		last, e := bs.GetLastVersion("uuid")
+		So(last.Uuid, ShouldEqual, "version2")
+
+		e = bs.UpdateVersion("uuid", &tree.ChangeLog{Uuid: "wrongVersion"})
+		So(e, ShouldNotBeNil)
+		e = bs.UpdateVersion("noid", &tree.ChangeLog{Uuid: "version1"})
+		So(e, ShouldNotBeNil)
+
+	})
+
 }
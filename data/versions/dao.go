@@ -30,6 +30,7 @@ type DAO interface {
 	GetVersions(nodeUuid string) (chan *tree.ChangeLog, chan bool)
 	GetVersion(nodeUuid string, versionId string) (*tree.ChangeLog, error)
 	StoreVersion(nodeUuid string, log *tree.ChangeLog) error
+	UpdateVersion(nodeUuid string, log *tree.ChangeLog) error
 	DeleteVersionsForNode(nodeUuid string, versions ...*tree.ChangeLog) error
 	ListAllVersionedNodesUuids() (chan string, chan bool, chan error)
 }
@@ -41,13 +41,16 @@ import (
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/defaults"
 	"github.com/pydio/cells/common/utils"
+	"github.com/pydio/cells/common/views"
 	"github.com/pydio/cells/data/versions"
 )
 
 var policiesCache *cache.Cache
 
 type Handler struct {
-	db versions.DAO
+	db         versions.DAO
+	router     *views.Router
+	routerOnce sync.Once
 }
 
 func (h *Handler) buildVersionDescription(ctx context.Context, version *tree.ChangeLog) string {
@@ -83,7 +86,9 @@ func (h *Handler) ListVersions(ctx context.Context, request *tree.ListVersionsRe
 	for {
 		select {
 		case l := <-logs:
-			l.Description = h.buildVersionDescription(ctx, l)
+			if l.Description == "" {
+				l.Description = h.buildVersionDescription(ctx, l)
+			}
 			resp := &tree.ListVersionsResponse{Version: l}
 			e := versionsStream.Send(resp)
 			log.Logger(ctx).Debug("[VERSION] Sending version ", zap.Any("resp", resp), zap.Error(e))
@@ -231,6 +236,67 @@ func (h *Handler) PruneVersions(ctx context.Context, request *tree.PruneVersions
 	return nil
 }
 
+// UpdateVersion sets the label, the description and the pinned flag of an existing version. An empty
+// description falls back to the one generated from the activity that created the version.
+func (h *Handler) UpdateVersion(ctx context.Context, request *tree.UpdateVersionRequest, resp *tree.UpdateVersionResponse) error {
+
+	if request.Node == nil || request.Node.Uuid == "" || request.VersionId == "" {
+		return errors.BadRequest(common.SERVICE_VERSIONS, "Please provide a node Uuid and a VersionId")
+	}
+	v, e := h.db.GetVersion(request.Node.Uuid, request.VersionId)
+	if e != nil {
+		return e
+	}
+	if v.Uuid == "" {
+		return errors.NotFound(common.SERVICE_VERSIONS, "Cannot find version %s", request.VersionId)
+	}
+	v.Label = request.Label
+	v.Description = request.Description
+	v.Pinned = request.Pinned
+	if e := h.db.UpdateVersion(request.Node.Uuid, v); e != nil {
+		return e
+	}
+	log.Logger(ctx).Info("Updated version for node", request.Node.ZapUuid(), zap.String("version", v.Uuid), zap.Bool("pinned", v.Pinned))
+	resp.Version = v
+	return nil
+}
+
+// RestoreVersion copies the content of a version back to its node, with the permissions of the current user.
+// The previous content is not lost, as the modification is itself recorded as a new version.
+func (h *Handler) RestoreVersion(ctx context.Context, request *tree.RestoreVersionRequest, resp *tree.RestoreVersionResponse) error {
+
+	if request.Node == nil || request.Node.Uuid == "" || request.VersionId == "" {
+		return errors.BadRequest(common.SERVICE_VERSIONS, "Please provide a node Uuid and a VersionId")
+	}
+	v, e := h.db.GetVersion(request.Node.Uuid, request.VersionId)
+	if e != nil {
+		return e
+	}
+	if v.Uuid == "" {
+		return errors.NotFound(common.SERVICE_VERSIONS, "Cannot find version %s", request.VersionId)
+	}
+	router := h.getRouter()
+	node := &tree.Node{Uuid: request.Node.Uuid}
+	log.Logger(ctx).Info("Restoring version", node.ZapUuid(), zap.String("version", v.Uuid))
+	if _, e := router.CopyObject(ctx, node, node.Clone(), &views.CopyRequestData{SrcVersionId: v.Uuid}); e != nil {
+		return e
+	}
+	restored, e := router.ReadNode(ctx, &tree.ReadNodeRequest{Node: node})
+	if e != nil {
+		return e
+	}
+	resp.Node = restored.Node
+	resp.Version = v
+	return nil
+}
+
+func (h *Handler) getRouter() *views.Router {
+	h.routerOnce.Do(func() {
+		h.router = views.NewUuidRouter(views.RouterOptions{WatchRegistry: true, AuditEvent: true})
+	})
+	return h.router
+}
+
 func (h *Handler) findPolicyForNode(ctx context.Context, node *tree.Node) *tree.VersioningPolicy {
 
 	if policiesCache == nil {
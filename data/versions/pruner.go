@@ -84,53 +84,103 @@ func (p *pruningPeriod) String() string {
 		p.start, p.end, p.max, len(p.records))
 }
 
-// Prune decides which versions to delete for this period.
+// Prune decides which versions to delete for this period. Pinned versions are never removed
+// and do not count in the period maximum.
 func (p *pruningPeriod) Prune() (toBeRemoved []*tree.ChangeLog) {
-	var newRecords []*tree.ChangeLog
 	if p.max == -1 {
 		return
-	} else if p.max == 0 {
-		toBeRemoved = append(toBeRemoved, p.records...)
-	} else if len(p.records) > int(p.max) {
-		distances := recordsToDistances(p.records)
+	}
+	pinned, records := splitPinned(p.records)
+	newRecords := records
+	if p.max == 0 {
+		toBeRemoved = append(toBeRemoved, records...)
+		newRecords = nil
+	} else if len(records) > int(p.max) {
+		newRecords = nil
+		distances := recordsToDistances(records)
 		sort.Sort(byDistances(distances))
 		for k, dLog := range distances {
-			if k < len(p.records)-int(p.max) {
+			if k < len(records)-int(p.max) {
 				toBeRemoved = append(toBeRemoved, &dLog.ChangeLog)
 			} else {
 				newRecords = append(newRecords, &dLog.ChangeLog)
 			}
 		}
 	}
-	p.records = newRecords
+	p.records = append(pinned, newRecords...)
 	return toBeRemoved
 }
 
 // PruneAllWithMaxSize checks overall size and removes older versions. It should be called after pruning by periods.
+// Pinned versions are always kept, but their size is accounted first: once they reach the maximum size, all
+// other versions are removed.
 func PruneAllWithMaxSize(periods []*pruningPeriod, maxSize int64) (toBeRemoved []*tree.ChangeLog, remaining []*tree.ChangeLog) {
 	var allRecords []*tree.ChangeLog
 	for _, p := range periods {
 		allRecords = append(allRecords, p.records...)
 	}
+	pinned, allRecords := splitPinned(allRecords)
 	sort.Sort(byTime(allRecords))
 	var totalSize int64
-	breakAt := -1
-	for k, record := range allRecords {
-		totalSize += record.Size
-		if totalSize >= maxSize {
-			breakAt = k
-			break
-		}
+	seen := make(map[string]bool)
+	for _, record := range pinned {
+		totalSize += storedSize(record, seen)
 	}
-	if breakAt != -1 && breakAt < len(allRecords)-1 {
-		remaining = allRecords[0 : breakAt+1]
-		toBeRemoved = allRecords[breakAt+1:]
+	if len(pinned) > 0 && totalSize >= maxSize {
+		// Pinned versions already use all the space
+		toBeRemoved = allRecords
 	} else {
-		remaining = allRecords
+		breakAt := -1
+		for k, record := range allRecords {
+			totalSize += storedSize(record, seen)
+			if totalSize >= maxSize {
+				breakAt = k
+				break
+			}
+		}
+		if breakAt != -1 && breakAt < len(allRecords)-1 {
+			remaining = allRecords[0 : breakAt+1]
+			toBeRemoved = allRecords[breakAt+1:]
+		} else {
+			remaining = allRecords
+		}
+	}
+	if len(pinned) > 0 {
+		remaining = append(append([]*tree.ChangeLog{}, remaining...), pinned...)
+		sort.Sort(byTime(remaining))
+	}
+	return
+}
+
+// splitPinned separates pinned versions from the others, preserving their order.
+func splitPinned(records []*tree.ChangeLog) (pinned []*tree.ChangeLog, others []*tree.ChangeLog) {
+	for _, r := range records {
+		if r.Pinned {
+			pinned = append(pinned, r)
+		} else {
+			others = append(others, r)
+		}
 	}
 	return
 }
 
+// storedSize returns the size added to the versions store by a version, given the chunks already used
+// by newer versions. Deduplicated versions only account for the chunks not seen yet, which are
+// added to seen.
+func storedSize(record *tree.ChangeLog, seen map[string]bool) int64 {
+	if len(record.Chunks) == 0 {
+		return record.Size
+	}
+	var size int64
+	for hash, s := range record.Chunks {
+		if !seen[hash] {
+			seen[hash] = true
+			size += s
+		}
+	}
+	return size
+}
+
 // recordsToDistances transforms a slice of ChangeLog to an ordered slice of distancedLog.
 func recordsToDistances(records []*tree.ChangeLog) (distances []*distancedLog) {
 	sort.Sort(byTime(records))
@@ -141,6 +141,32 @@ func TestByDistances(t *testing.T) {
 		So(pruneTo3Period.records, ShouldHaveLength, 3)
 
 	})
+
+	Convey("Test Pruning By Distances With Pinned Versions", t, func() {
+		changes := generateChanges("1s", "1s450ms", "10s", "11s", "13s", "4m", "3d", "6d")
+		changes[3].Pinned = true
+
+		pruneAllPeriod := &pruningPeriod{
+			records: changes,
+			max:     0,
+		}
+		toPrune := pruneAllPeriod.Prune()
+		So(toPrune, ShouldHaveLength, len(changes)-1)
+		So(pruneAllPeriod.records, ShouldHaveLength, 1)
+		So(pruneAllPeriod.records[0].Uuid, ShouldEqual, "id-4")
+
+		pruneTo3Period := &pruningPeriod{
+			records: changes,
+			max:     3,
+		}
+		toPrune = pruneTo3Period.Prune()
+		So(toPrune, ShouldHaveLength, len(changes)-4)
+		for _, r := range toPrune {
+			So(r.Uuid, ShouldNotEqual, "id-4")
+		}
+		So(pruneTo3Period.records, ShouldHaveLength, 4)
+
+	})
 }
 func TestByMaxSize(t *testing.T) {
 
@@ -178,6 +204,46 @@ func TestByMaxSize(t *testing.T) {
 		So(toPrune, ShouldHaveLength, 7)
 		So(remaining, ShouldHaveLength, 1)
 
+		// Deduplicated versions count the chunks they share once
+		for i, c := range changes {
+			c.Chunks = map[string]int64{"shared": 18, fmt.Sprintf("own-%d", i): 2}
+		}
+		toPrune, remaining = PruneAllWithMaxSize([]*pruningPeriod{noPrunePeriod}, 60)
+		So(toPrune, ShouldHaveLength, 0)
+		So(remaining, ShouldHaveLength, 8)
+
+		// Shared chunks still count once the version that first stored them is pruned
+		toPrune, remaining = PruneAllWithMaxSize([]*pruningPeriod{noPrunePeriod}, 30)
+		So(toPrune, ShouldHaveLength, 2)
+		So(remaining, ShouldHaveLength, 6)
+		So(toPrune[1].Uuid, ShouldEqual, "id-8")
+
+	})
+
+	Convey("Test Pruning With Max Size And Pinned Versions", t, func() {
+
+		changes := generateChanges("1s", "1s450ms", "10s", "11s", "13s", "4m", "3d", "6d")
+		changes[6].Pinned = true
+		noPrunePeriod := &pruningPeriod{
+			records: changes,
+			max:     -1,
+		}
+
+		// Pinned versions are kept and count in the size
+		toPrune, remaining := PruneAllWithMaxSize([]*pruningPeriod{noPrunePeriod}, 60)
+		So(toPrune, ShouldHaveLength, 5)
+		So(remaining, ShouldHaveLength, 3)
+		So(remaining[2].Uuid, ShouldEqual, "id-7")
+		for _, r := range toPrune {
+			So(r.Pinned, ShouldBeFalse)
+		}
+
+		// Once pinned versions use all the space, only them are kept
+		toPrune, remaining = PruneAllWithMaxSize([]*pruningPeriod{noPrunePeriod}, 10)
+		So(toPrune, ShouldHaveLength, 7)
+		So(remaining, ShouldHaveLength, 1)
+		So(remaining[0].Uuid, ShouldEqual, "id-7")
+
 	})
 
 }
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package rest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/micro/go-micro/errors"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/rest"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/views"
+)
+
+// Handler for REST interface to files versions
+type Handler struct {
+	router *views.Router
+}
+
+// SwaggerTags list the names of the service tags declared in the swagger json implemented by this service
+func (h *Handler) SwaggerTags() []string {
+	return []string{"VersionService"}
+}
+
+// Filter returns a function to filter the swagger path
+func (h *Handler) Filter() func(string) string {
+	return func(path string) string {
+		return strings.Replace(path, "{NodePath}", "{NodePath:*}", 1)
+	}
+}
+
+// ListVersions sends the versions of a file, most recent first.
+func (h *Handler) ListVersions(req *restful.Request, rsp *restful.Response) {
+
+	var request rest.ListNodeVersionsRequest
+	if err := req.ReadEntity(&request); err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	request.NodePath = req.PathParameter("NodePath")
+	ctx := req.Request.Context()
+
+	node, err := h.readNode(ctx, request.NodePath)
+	if err != nil {
+		service.RestError404(req, rsp, err)
+		return
+	}
+	stream, err := h.getVersionClient().ListVersions(ctx, &tree.ListVersionsRequest{Node: node})
+	if err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	defer stream.Close()
+	collection := &rest.NodeVersionsCollection{}
+	for {
+		r, e := stream.Recv()
+		if e != nil {
+			break
+		}
+		if r == nil || r.Version == nil {
+			continue
+		}
+		collection.Versions = append(collection.Versions, r.Version)
+	}
+	rsp.WriteEntity(collection)
+}
+
+// RestoreVersion copies the content of a version back to the file. The previous content is not lost,
+// as the modification is itself recorded as a new version on top of the existing ones.
+func (h *Handler) RestoreVersion(req *restful.Request, rsp *restful.Response) {
+
+	var request rest.RestoreVersionRequest
+	if err := req.ReadEntity(&request); err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	request.NodePath = req.PathParameter("NodePath")
+	ctx := req.Request.Context()
+
+	node, err := h.writableNode(ctx, request.NodePath)
+	if err != nil {
+		h.writeError(req, rsp, err)
+		return
+	}
+	if request.VersionId == "" {
+		rsp.WriteError(400, errors.BadRequest(common.SERVICE_VERSIONS, "Please provide a VersionId"))
+		return
+	}
+	if _, err := h.getVersionClient().RestoreVersion(ctx, &tree.RestoreVersionRequest{Node: node, VersionId: request.VersionId}); err != nil {
+		h.writeError(req, rsp, err)
+		return
+	}
+	restored, err := h.readNode(ctx, request.NodePath)
+	if err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	rsp.WriteEntity(&rest.RestoreVersionResponse{Node: restored.WithoutReservedMetas()})
+}
+
+// UpdateVersion sets the label and description of a version and whether it is pinned, in which case it is never pruned.
+func (h *Handler) UpdateVersion(req *restful.Request, rsp *restful.Response) {
+
+	var request rest.UpdateVersionRequest
+	if err := req.ReadEntity(&request); err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	request.NodePath = req.PathParameter("NodePath")
+	ctx := req.Request.Context()
+
+	node, err := h.writableNode(ctx, request.NodePath)
+	if err != nil {
+		h.writeError(req, rsp, err)
+		return
+	}
+	resp, err := h.getVersionClient().UpdateVersion(ctx, &tree.UpdateVersionRequest{
+		Node:        node,
+		VersionId:   request.VersionId,
+		Label:       request.Label,
+		Description: request.Description,
+		Pinned:      request.Pinned,
+	})
+	if err != nil {
+		h.writeError(req, rsp, err)
+		return
+	}
+	rsp.WriteEntity(resp.Version)
+}
+
+func (h *Handler) getRouter() *views.Router {
+	if h.router == nil {
+		h.router = views.NewStandardRouter(views.RouterOptions{WatchRegistry: true, AuditEvent: true})
+	}
+	return h.router
+}
+
+func (h *Handler) getVersionClient() tree.NodeVersionerClient {
+	return tree.NewNodeVersionerClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_VERSIONS, defaults.NewClient())
+}
+
+// readNode loads a node by its path through the router, which checks it is readable by the current user.
+func (h *Handler) readNode(ctx context.Context, nodePath string) (*tree.Node, error) {
+	resp, err := h.getRouter().ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: nodePath}})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Node.IsLeaf() {
+		return nil, errors.BadRequest(common.SERVICE_VERSIONS, "Versions are only available for files")
+	}
+	return resp.Node, nil
+}
+
+// writableNode loads a node and checks that the current user has write access on it.
+func (h *Handler) writableNode(ctx context.Context, nodePath string) (*tree.Node, error) {
+	node, err := h.readNode(ctx, nodePath)
+	if err != nil {
+		return nil, err
+	}
+	router := h.getRouter()
+	var writable bool
+	err = router.WrapCallback(func(inputFilter views.NodeFilter, outputFilter views.NodeFilter) error {
+		c, n, e := inputFilter(ctx, node.Clone(), "in")
+		if e != nil {
+			return e
+		}
+		accessList, e := views.AccessListFromContext(c)
+		if e != nil {
+			return e
+		}
+		_, parents, e := views.AncestorsListFromContext(c, n, "in", router.GetClientsPool(), false)
+		if e != nil {
+			return e
+		}
+		writable = accessList.CanWrite(c, parents...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !writable {
+		return nil, errors.Forbidden(common.SERVICE_VERSIONS, "Node is not writeable")
+	}
+	return node, nil
+}
+
+// writeError sends the error with the status matching its code.
+func (h *Handler) writeError(req *restful.Request, rsp *restful.Response, err error) {
+	switch errors.Parse(err.Error()).Code {
+	case 400:
+		rsp.WriteError(400, err)
+	case 403:
+		service.RestError403(req, rsp, err)
+	case 404:
+		service.RestError404(req, rsp, err)
+	default:
+		service.RestError500(req, rsp, err)
+	}
+}
@@ -50,6 +50,20 @@ func logRequest(handler http.Handler) http.Handler {
 	})
 }
 
+// withLockSystem attaches a LockSystem bound to the request context to the webdav handler,
+// as locks are resolved through the Router on behalf of the current user. Lock tokens submitted
+// by the client are passed along, so that the Router lets it write on the nodes it has locked.
+func withLockSystem(dav *webdav.Handler, fs *FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tokens := submittedLockTokens(r); len(tokens) > 0 {
+			r = r.WithContext(views.WithContentLockToken(r.Context(), tokens...))
+		}
+		h := *dav
+		h.LockSystem = NewLockSystem(r.Context(), fs, r.Method)
+		h.ServeHTTP(w, r)
+	})
+}
+
 func startHttpServer(ctx context.Context, port int) {
 
 	router := views.NewStandardRouter(views.RouterOptions{WatchRegistry: true, AuditEvent: true})
@@ -63,7 +77,6 @@ func startHttpServer(ctx context.Context, port int) {
 
 	dav := &webdav.Handler{
 		FileSystem: fs,
-		LockSystem: webdav.NewMemLS(),
 		Logger: func(r *http.Request, err error) {
 			switch r.Method {
 			case "COPY", "MOVE": // add relevant destination param when loggin an error
@@ -86,6 +99,6 @@ func startHttpServer(ctx context.Context, port int) {
 		},
 	}
 
-	handler := basicAuthenticator.Wrap(logRequest(dav))
+	handler := basicAuthenticator.Wrap(logRequest(withLockSystem(dav, fs)))
 	http.ListenAndServe(fmt.Sprintf(":%d", port), handler)
 }
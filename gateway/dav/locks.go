@@ -0,0 +1,373 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/pborman/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/utils"
+	"github.com/pydio/cells/common/views"
+)
+
+const lockTokenPrefix = "opaquelocktoken:"
+
+var ifLockTokenRegexp = regexp.MustCompile(`(?i)(not\s+)?<(` + lockTokenPrefix + `[^>]+)>`)
+
+// LockSystem implements the webdav.LockSystem interface by storing locks as content locks
+// in the ACL service. Locks are thus shared between gateways, survive restarts and are
+// honored by other access paths through the AclLockFilter. Lock tokens carry the Uuid of the
+// locked node, so that a lock is found from its token without scanning all locks.
+// A LockSystem is bound to a request context, as nodes are resolved through the Router.
+type LockSystem struct {
+	ctx context.Context
+	fs  *FileSystem
+	// persist is false for the temporary locks that the webdav handler creates
+	// for requests without If header: those are only checked, never stored.
+	persist   bool
+	temporary map[string]bool
+}
+
+// lockDetails are the WebDAV specific data stored in the content lock Details.
+type lockDetails struct {
+	Root      string        `json:"root"`
+	ZeroDepth bool          `json:"zeroDepth"`
+	OwnerXML  string        `json:"ownerXml,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// NewLockSystem creates a LockSystem for the current request. Locks are stored only
+// for LOCK requests.
+func NewLockSystem(ctx context.Context, fs *FileSystem, method string) *LockSystem {
+	return &LockSystem{
+		ctx:       ctx,
+		fs:        fs,
+		persist:   method == "LOCK",
+		temporary: make(map[string]bool),
+	}
+}
+
+// Confirm checks that the caller holds a lock on the named resources, given the conditions
+// of the If header.
+func (l *LockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	for _, name := range []string{name0, name1} {
+		if name == "" {
+			continue
+		}
+		chain, exists, err := l.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		locks, err := views.ListContentLocks(l.ctx, chain...)
+		if err != nil {
+			return nil, err
+		}
+		var confirmed bool
+		for nodeUuid, lock := range locks {
+			if lock.Token == "" || !covers(nodeUuid, lock, chain, exists) {
+				continue
+			}
+			for _, c := range conditions {
+				if !c.Not && c.Token == lock.Token {
+					confirmed = true
+				}
+			}
+		}
+		if !confirmed {
+			return nil, webdav.ErrConfirmationFailed
+		}
+	}
+	return func() {}, nil
+}
+
+// Create checks that the resource is not locked by someone else and creates a new lock.
+// Resources that do not exist yet are created empty.
+func (l *LockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	chain, exists, err := l.resolve(details.Root)
+	if err != nil {
+		return "", err
+	}
+	locks, err := views.ListContentLocks(l.ctx, chain...)
+	if err != nil {
+		return "", err
+	}
+	for nodeUuid, lock := range locks {
+		if covers(nodeUuid, lock, chain, exists) && l.conflicts(lock) {
+			return "", webdav.ErrLocked
+		}
+	}
+	if exists && !details.ZeroDepth {
+		// Check that no resource below is locked
+		if e := l.checkDescendants(chain[0]); e != nil {
+			return "", e
+		}
+	}
+
+	if !l.persist {
+		token := newLockToken("")
+		l.temporary[token] = true
+		return token, nil
+	}
+
+	var nodeUuid string
+	if exists {
+		nodeUuid = chain[0]
+	} else {
+		f, e := l.fs.OpenFile(l.ctx, details.Root, os.O_RDWR|os.O_CREATE, 0666)
+		if e != nil {
+			return "", e
+		}
+		nodeUuid = f.(*File).node.Uuid
+		f.Close()
+	}
+	token := newLockToken(nodeUuid)
+
+	d, _ := json.Marshal(&lockDetails{
+		Root:      details.Root,
+		ZeroDepth: details.ZeroDepth,
+		OwnerXML:  details.OwnerXML,
+		Duration:  details.Duration,
+	})
+	lock := &utils.ContentLock{
+		Owner:     l.userName(),
+		Token:     token,
+		Details:   string(d),
+		Recursive: !details.ZeroDepth,
+	}
+	setExpiration(now, lock, details.Duration)
+	replace := func(current *utils.ContentLock) bool {
+		return !l.conflicts(current)
+	}
+	if e := views.SetContentLock(l.ctx, nodeUuid, lock, replace); e != nil {
+		if errors.Parse(e.Error()).Code == http.StatusConflict {
+			return "", webdav.ErrLocked
+		}
+		return "", e
+	}
+	log.Logger(l.ctx).Debug("Created DAV lock", zap.String("root", details.Root), zap.String("token", token))
+	return token, nil
+}
+
+// Refresh updates the expiration of the lock with the given token.
+func (l *LockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	nodeUuid, lock, err := l.findByToken(token)
+	if err != nil {
+		return webdav.LockDetails{}, err
+	}
+	details := parseLockDetails(lock)
+	details.Duration = duration
+	d, _ := json.Marshal(details)
+	lock.Details = string(d)
+	setExpiration(now, lock, duration)
+	replace := func(current *utils.ContentLock) bool {
+		return current.Token == token
+	}
+	if e := views.SetContentLock(l.ctx, nodeUuid, lock, replace); e != nil {
+		if errors.Parse(e.Error()).Code == http.StatusConflict {
+			return webdav.LockDetails{}, webdav.ErrNoSuchLock
+		}
+		return webdav.LockDetails{}, e
+	}
+	return webdav.LockDetails{
+		Root:      details.Root,
+		Duration:  details.Duration,
+		OwnerXML:  details.OwnerXML,
+		ZeroDepth: details.ZeroDepth,
+	}, nil
+}
+
+// Unlock removes the lock with the given token.
+func (l *LockSystem) Unlock(now time.Time, token string) error {
+	if l.temporary[token] {
+		delete(l.temporary, token)
+		return nil
+	}
+	nodeUuid, lock, err := l.findByToken(token)
+	if err != nil {
+		return err
+	}
+	if lock.Owner != l.userName() {
+		return webdav.ErrForbidden
+	}
+	if e := views.DeleteContentLock(l.ctx, nodeUuid, lock); e != nil {
+		if errors.Parse(e.Error()).Code == http.StatusConflict {
+			return webdav.ErrNoSuchLock
+		}
+		return e
+	}
+	return nil
+}
+
+// resolve finds the Uuids of the named resource and of its ancestors. If the resource does
+// not exist, the chain starts with its first existing parent.
+func (l *LockSystem) resolve(name string) (chain []string, exists bool, err error) {
+	current, e := clearName(name)
+	if e != nil {
+		return nil, false, e
+	}
+	exists = true
+	var resp *tree.ReadNodeResponse
+	for {
+		if current == "/" || current == "." {
+			// Virtual root listing workspaces, cannot be locked
+			return nil, false, nil
+		}
+		if resp, e = l.fs.Router.ReadNode(l.ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: current}}); e == nil {
+			break
+		}
+		exists = false
+		current = path.Dir(current)
+	}
+	ancestors, err := utils.BuildAncestorsList(l.ctx, l.fs.Router.GetClientsPool().GetTreeClient(), &tree.Node{Uuid: resp.Node.Uuid})
+	if err != nil {
+		return nil, false, err
+	}
+	for _, a := range ancestors {
+		chain = append(chain, a.Uuid)
+	}
+	return chain, exists, nil
+}
+
+// checkDescendants verifies that no lock is set on a resource below nodeUuid.
+func (l *LockSystem) checkDescendants(nodeUuid string) error {
+	rsp, err := l.fs.Router.GetClientsPool().GetTreeClient().ReadNode(l.ctx, &tree.ReadNodeRequest{Node: &tree.Node{Uuid: nodeUuid}})
+	if err != nil {
+		return err
+	}
+	locks, err := views.ListContentLocksBelow(l.ctx, rsp.Node.Path)
+	if err != nil {
+		return err
+	}
+	for _, lock := range locks {
+		if l.conflicts(lock) {
+			return webdav.ErrLocked
+		}
+	}
+	return nil
+}
+
+// findByToken looks up a stored lock by its token.
+func (l *LockSystem) findByToken(token string) (string, *utils.ContentLock, error) {
+	nodeUuid := tokenNodeUuid(token)
+	if nodeUuid == "" {
+		return "", nil, webdav.ErrNoSuchLock
+	}
+	lock, err := views.GetContentLock(l.ctx, nodeUuid)
+	if err != nil {
+		return "", nil, err
+	}
+	if lock == nil || lock.Token != token {
+		return "", nil, webdav.ErrNoSuchLock
+	}
+	return nodeUuid, lock, nil
+}
+
+// newLockToken generates a unique lock token for the node nodeUuid. The node Uuid is
+// stored as the token extension path, as allowed by RFC 4918 for opaquelocktoken URIs.
+func newLockToken(nodeUuid string) string {
+	token := lockTokenPrefix + uuid.New()
+	if nodeUuid != "" {
+		token += "/" + nodeUuid
+	}
+	return token
+}
+
+// submittedLockTokens reads the lock tokens of the If header of a request, ignoring negated conditions.
+func submittedLockTokens(r *http.Request) (tokens []string) {
+	for _, m := range ifLockTokenRegexp.FindAllStringSubmatch(r.Header.Get("If"), -1) {
+		if m[1] == "" {
+			tokens = append(tokens, m[2])
+		}
+	}
+	return
+}
+
+// tokenNodeUuid reads the node Uuid from a token generated by newLockToken.
+func tokenNodeUuid(token string) string {
+	if !strings.HasPrefix(token, lockTokenPrefix) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, lockTokenPrefix), "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// conflicts tells whether a lock prevents the current user from locking a resource. Simple
+// locks set by the user from the web interface do not, as the AclLockFilter lets the owner write.
+func (l *LockSystem) conflicts(lock *utils.ContentLock) bool {
+	return lock.Token != "" || lock.Owner != l.userName()
+}
+
+func (l *LockSystem) userName() string {
+	if claims, ok := l.ctx.Value(claim.ContextKey).(claim.Claims); ok {
+		return claims.Name
+	}
+	return ""
+}
+
+// covers tells whether a lock set on nodeUuid applies to the resource described by chain.
+// Locks on ancestors only apply if they have an infinite depth.
+func covers(nodeUuid string, lock *utils.ContentLock, chain []string, exists bool) bool {
+	for i, c := range chain {
+		if c != nodeUuid {
+			continue
+		}
+		if i == 0 && exists {
+			return true
+		}
+		return !parseLockDetails(lock).ZeroDepth
+	}
+	return false
+}
+
+// parseLockDetails reads WebDAV details from a content lock. Locks set by other
+// means apply to the locked resource only.
+func parseLockDetails(lock *utils.ContentLock) *lockDetails {
+	details := &lockDetails{ZeroDepth: true}
+	if lock.Details != "" {
+		json.Unmarshal([]byte(lock.Details), details)
+	}
+	return details
+}
+
+func setExpiration(now time.Time, lock *utils.ContentLock, duration time.Duration) {
+	if duration >= 0 {
+		lock.Expires = now.Add(duration).Unix()
+	} else {
+		lock.Expires = 0
+	}
+}
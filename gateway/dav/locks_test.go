@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dav
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/utils"
+)
+
+func TestLockCoverage(t *testing.T) {
+
+	Convey("Simple locks only cover the locked node", t, func() {
+		lock := utils.ParseContentLock("admin")
+		So(covers("file", lock, []string{"file", "folder", "ROOT"}, true), ShouldBeTrue)
+		So(covers("folder", lock, []string{"file", "folder", "ROOT"}, true), ShouldBeFalse)
+		So(covers("folder", lock, []string{"folder", "ROOT"}, false), ShouldBeFalse)
+	})
+
+	Convey("DAV locks with infinite depth cover children", t, func() {
+		lock := &utils.ContentLock{Owner: "admin", Token: "token", Details: `{"root":"/ws/folder","zeroDepth":false}`}
+		So(covers("folder", lock, []string{"file", "folder", "ROOT"}, true), ShouldBeTrue)
+		So(covers("folder", lock, []string{"folder", "ROOT"}, false), ShouldBeTrue)
+		So(covers("other", lock, []string{"file", "folder", "ROOT"}, true), ShouldBeFalse)
+
+		lock.Details = `{"root":"/ws/folder","zeroDepth":true}`
+		So(covers("folder", lock, []string{"file", "folder", "ROOT"}, true), ShouldBeFalse)
+	})
+
+	Convey("Lock expiration", t, func() {
+		now := time.Now()
+		lock := &utils.ContentLock{}
+		setExpiration(now, lock, time.Hour)
+		So(lock.Expires, ShouldEqual, now.Add(time.Hour).Unix())
+		setExpiration(now, lock, -1)
+		So(lock.Expires, ShouldEqual, 0)
+	})
+
+	Convey("Lock tokens carry the node Uuid", t, func() {
+		token := newLockToken("node-uuid")
+		So(token, ShouldStartWith, lockTokenPrefix)
+		So(tokenNodeUuid(token), ShouldEqual, "node-uuid")
+		So(newLockToken("node-uuid"), ShouldNotEqual, token)
+
+		So(tokenNodeUuid(newLockToken("")), ShouldBeEmpty)
+		So(tokenNodeUuid("opaquelocktoken:legacy-token"), ShouldBeEmpty)
+		So(tokenNodeUuid("urn:uuid:other/node-uuid"), ShouldBeEmpty)
+	})
+
+	Convey("Lock tokens are read from the If header", t, func() {
+		r := httptest.NewRequest("PUT", "/ws/file", nil)
+		r.Header.Set("If", `</ws/file> (<opaquelocktoken:a/node> ["etag"]) (Not <opaquelocktoken:b>) (<opaquelocktoken:c>)`)
+		So(submittedLockTokens(r), ShouldResemble, []string{"opaquelocktoken:a/node", "opaquelocktoken:c"})
+
+		So(submittedLockTokens(httptest.NewRequest("PUT", "/ws/file", nil)), ShouldBeEmpty)
+	})
+
+}
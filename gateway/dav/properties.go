@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/views"
+)
+
+// deadProperty is the serialized form of a webdav.Property stored in the node metadata.
+type deadProperty struct {
+	Space    string `json:"space"`
+	Local    string `json:"local"`
+	Lang     string `json:"lang,omitempty"`
+	InnerXML string `json:"xml"`
+}
+
+// ETag uses the node hash computed by the storage.
+func (fi *FileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.node.Etag == "" || fi.node.Etag == common.NODE_FLAG_ETAG_TEMPORARY {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.node.Etag + `"`, nil
+}
+
+// ContentType finds the mime type from the node extension, avoiding to read the file content.
+func (fi *FileInfo) ContentType(ctx context.Context) (string, error) {
+	if !fi.node.IsLeaf() {
+		return "httpd/unix-directory", nil
+	}
+	if mimeType := mime.TypeByExtension(path.Ext(fi.Name())); mimeType != "" {
+		return mimeType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// DeadProps returns the custom properties stored in the node metadata.
+func (f *File) DeadProps() (map[xml.Name]webdav.Property, error) {
+	props := make(map[xml.Name]webdav.Property)
+	for _, p := range f.loadDeadProps() {
+		name := xml.Name{Space: p.Space, Local: p.Local}
+		props[name] = webdav.Property{XMLName: name, Lang: p.Lang, InnerXML: []byte(p.InnerXML)}
+	}
+	return props, nil
+}
+
+// Patch sets or removes custom properties and stores them in the node metadata.
+func (f *File) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	status := http.StatusOK
+	if f.node.GetStringMeta(common.META_FLAG_READONLY) == "true" {
+		status = http.StatusForbidden
+	}
+	stat := webdav.Propstat{Status: status}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			stat.Props = append(stat.Props, webdav.Property{XMLName: p.XMLName})
+		}
+	}
+	if status != http.StatusOK {
+		return []webdav.Propstat{stat}, nil
+	}
+
+	props := f.loadDeadProps()
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			props = removeDeadProp(props, p.XMLName)
+			if !patch.Remove {
+				props = append(props, &deadProperty{
+					Space:    p.XMLName.Space,
+					Local:    p.XMLName.Local,
+					Lang:     p.Lang,
+					InnerXML: string(p.InnerXML),
+				})
+			}
+		}
+	}
+	if e := f.storeDeadProps(props); e != nil {
+		log.Logger(f.ctx).Error("cannot store dav properties", f.node.Zap(), zap.Error(e))
+		return nil, e
+	}
+	return []webdav.Propstat{stat}, nil
+}
+
+func (f *File) loadDeadProps() []*deadProperty {
+	var props []*deadProperty
+	if f.node.HasMetaKey(common.META_NAMESPACE_DAV_PROPERTIES) {
+		f.node.GetMeta(common.META_NAMESPACE_DAV_PROPERTIES, &props)
+	}
+	return props
+}
+
+// storeDeadProps sends the properties to the meta service. As removing a namespace is
+// not supported per node, an empty list is stored when all properties are removed.
+func (f *File) storeDeadProps(props []*deadProperty) error {
+	if props == nil {
+		props = []*deadProperty{}
+	}
+	node := f.node.Clone()
+	node.SetMeta(common.META_NAMESPACE_DAV_PROPERTIES, props)
+	return f.fs.Router.WrapCallback(func(inputFilter views.NodeFilter, outputFilter views.NodeFilter) error {
+		ctx, filtered, err := inputFilter(f.ctx, node, "in")
+		if err != nil {
+			return err
+		}
+		cli := tree.NewNodeReceiverClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_META, defaults.NewClient())
+		if _, er := cli.UpdateNode(ctx, &tree.UpdateNodeRequest{From: filtered, To: filtered}); er != nil {
+			return er
+		}
+		f.node.SetMeta(common.META_NAMESPACE_DAV_PROPERTIES, props)
+		return nil
+	})
+}
+
+func removeDeadProp(props []*deadProperty, name xml.Name) []*deadProperty {
+	var out []*deadProperty
+	for _, p := range props {
+		if p.Space != name.Space || p.Local != name.Local {
+			out = append(out, p)
+		}
+	}
+	return out
+}
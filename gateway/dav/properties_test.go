@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/tree"
+)
+
+func TestProperties(t *testing.T) {
+
+	Convey("Live properties from node", t, func() {
+		fi := &FileInfo{node: &tree.Node{Path: "folder/file.pdf", Etag: "abcdef", Type: tree.NodeType_LEAF}}
+		etag, e := fi.ETag(context.Background())
+		So(e, ShouldBeNil)
+		So(etag, ShouldEqual, `"abcdef"`)
+		ct, _ := fi.ContentType(context.Background())
+		So(ct, ShouldEqual, "application/pdf")
+
+		fi.node.Etag = common.NODE_FLAG_ETAG_TEMPORARY
+		_, e = fi.ETag(context.Background())
+		So(e, ShouldEqual, webdav.ErrNotImplemented)
+	})
+
+	Convey("Dead properties from node meta", t, func() {
+		node := &tree.Node{Path: "folder/file.pdf"}
+		node.SetMeta(common.META_NAMESPACE_DAV_PROPERTIES, []*deadProperty{
+			{Space: "urn:test", Local: "color", InnerXML: "red"},
+		})
+		f := &File{node: node, ctx: context.Background()}
+		props, e := f.DeadProps()
+		So(e, ShouldBeNil)
+		So(props, ShouldHaveLength, 1)
+		So(string(props[xml.Name{Space: "urn:test", Local: "color"}].InnerXML), ShouldEqual, "red")
+
+		So(removeDeadProp(f.loadDeadProps(), xml.Name{Space: "urn:test", Local: "color"}), ShouldBeEmpty)
+	})
+
+	Convey("Read-only nodes cannot be patched", t, func() {
+		node := &tree.Node{Path: "folder/file.pdf"}
+		node.SetMeta(common.META_FLAG_READONLY, "true")
+		f := &File{node: node, ctx: context.Background()}
+		stats, e := f.Patch([]webdav.Proppatch{{Props: []webdav.Property{{XMLName: xml.Name{Space: "urn:test", Local: "color"}}}}})
+		So(e, ShouldBeNil)
+		So(stats, ShouldHaveLength, 1)
+		So(stats[0].Status, ShouldEqual, http.StatusForbidden)
+	})
+
+}
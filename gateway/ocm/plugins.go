@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+// Package ocm receives the shares offered by remote servers, so that they can be mounted by local users.
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/federation"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/service"
+	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/utils"
+)
+
+func init() {
+	service.NewService(
+		service.Name(common.SERVICE_REST_NAMESPACE_+common.SERVICE_GATEWAY_OCM),
+		service.Tag(common.SERVICE_TAG_GATEWAY),
+		service.Dependency(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, []string{}),
+		service.Dependency(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER, []string{}),
+		service.Description("Federated sharing endpoint, receiving shares from remote servers"),
+		service.WithGeneric(func(ctx context.Context, cancel context.CancelFunc) (service.Runner, service.Checker, service.Stopper, error) {
+
+			conf := servicecontext.GetConfig(ctx)
+			port := conf.Int("port", 5016)
+
+			provider := "Pydio Cells"
+			if u, e := url.Parse(config.Get("defaults", "url").String("")); e == nil && u.Host != "" {
+				provider = u.Host
+			}
+			receiver := &federation.Receiver{
+				Store:          federation.NewDocStore(),
+				EndPoint:       config.Get("defaults", "url").String("") + "/ocm",
+				Provider:       provider,
+				UserExists:     userExists,
+				TrustedServers: conf.StringArray("trustedServers"),
+			}
+			// Remote servers call the protocol endpoints anonymously, local users authenticate
+			// to manage the shares they received.
+			srv := &http.Server{
+				Addr:    fmt.Sprintf(":%d", port),
+				Handler: service.JWTHttpWrapper(receiver),
+			}
+
+			log.Logger(ctx).Debug(fmt.Sprintf("Starting OCM Server on port %d", port))
+
+			return service.RunnerFunc(func() error {
+					if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+						log.Logger(ctx).Error("OCM server stopped", zap.Error(err))
+						return err
+					}
+					return nil
+				}), service.CheckerFunc(func() error {
+					return nil
+				}), service.StopperFunc(func() error {
+					sCtx, sCancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer sCancel()
+					return srv.Shutdown(sCtx)
+				}), nil
+		}),
+	)
+}
+
+// userExists checks that a share recipient is a visible local user.
+func userExists(ctx context.Context, login string) (bool, error) {
+	user, err := utils.SearchUniqueUser(ctx, login, "")
+	if err != nil {
+		if errors.Parse(err.Error()).Code == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return user.Attributes["hidden"] != "true", nil
+}
@@ -49,6 +49,12 @@ type File struct {
 	UserFriendlyName string
 	UserCanWrite     bool
 	PydioPath        string
+
+	SupportsLocks              bool
+	SupportsGetLock            bool
+	SupportsExtendedLockLength bool
+	SupportsUpdate             bool
+	UserCanNotWriteRelative    bool
 }
 
 func getNodeInfos(w http.ResponseWriter, r *http.Request) {
@@ -108,12 +114,27 @@ func uploadStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the lock provided by the client against the current one. An unlocked
+	// file can only be written if it is empty.
+	lockID := r.Header.Get("X-WOPI-Lock")
+	current, err := views.GetContentLock(r.Context(), n.Uuid)
+	if err != nil {
+		log.Logger(r.Context()).Error("cannot load lock", n.Zap(), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if (current != nil && current.Token != lockID) || (current == nil && n.GetSize() > 0) {
+		lockMismatch(w, r, current, "File is not locked with this lock ID")
+		return
+	}
+
 	var size int64
 	if h, ok := r.Header["Content-Length"]; ok && len(h) > 0 {
 		size, _ = strconv.ParseInt(h[0], 10, 64)
 	}
 
-	written, err := viewsRouter.PutObject(r.Context(), n, r.Body, &views.PutRequestData{
+	ctx := views.WithContentLockToken(r.Context(), lockID)
+	written, err := viewsRouter.PutObject(ctx, n, r.Body, &views.PutRequestData{
 		Size: size,
 	})
 	if err != nil {
@@ -138,6 +159,11 @@ func buildFileFromNode(ctx context.Context, n *tree.Node) *File {
 		Size:         n.GetSize(),
 		Version:      fmt.Sprintf("%d", n.GetModTime().Unix()),
 		PydioPath:    n.Path,
+
+		SupportsLocks:              true,
+		SupportsGetLock:            true,
+		SupportsExtendedLockLength: true,
+		SupportsUpdate:             true,
 	}
 
 	// Find user info in claims, if any
@@ -153,6 +179,7 @@ func buildFileFromNode(ctx context.Context, n *tree.Node) *File {
 			} else {
 				f.UserCanWrite = true
 			}
+			f.UserCanNotWriteRelative = !f.UserCanWrite
 		}
 	} else {
 		log.Logger(ctx).Debug("No Claims Found", zap.Any("ctx", ctx))
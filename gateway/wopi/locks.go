@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package wopi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/utils"
+	"github.com/pydio/cells/common/views"
+)
+
+const (
+	// lockDuration is the WOPI lock expiration, as required by the specification.
+	lockDuration = 30 * time.Minute
+)
+
+// fileOperation dispatches POST requests on a file to the relevant operation
+// depending on the X-WOPI-Override header.
+func fileOperation(w http.ResponseWriter, r *http.Request) {
+	switch r.Header.Get("X-WOPI-Override") {
+	case "LOCK":
+		if r.Header.Get("X-WOPI-OldLock") != "" {
+			unlockAndRelock(w, r)
+		} else {
+			lock(w, r)
+		}
+	case "GET_LOCK":
+		getLock(w, r)
+	case "REFRESH_LOCK":
+		refreshLock(w, r)
+	case "UNLOCK":
+		unlock(w, r)
+	case "PUT_RELATIVE":
+		putRelativeFile(w, r)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// lock locks a file for editing by the WOPI client application instance that requested the lock.
+// If the file is already locked with the same lock ID, the lock is refreshed.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/Lock.html
+func lock(w http.ResponseWriter, r *http.Request) {
+	withLock(w, r, func(n *tree.Node, current *utils.ContentLock) {
+		lockID := r.Header.Get("X-WOPI-Lock")
+		if current != nil && current.Token != lockID {
+			lockMismatch(w, r, current, "File is locked by another editor")
+			return
+		}
+		setLock(w, r, n, lockID, lockID)
+	})
+}
+
+// getLock retrieves the lock ID currently set on a file.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/GetLock.html
+func getLock(w http.ResponseWriter, r *http.Request) {
+	withLock(w, r, func(n *tree.Node, current *utils.ContentLock) {
+		if current != nil {
+			w.Header().Set("X-WOPI-Lock", current.Token)
+		} else {
+			w.Header().Set("X-WOPI-Lock", "")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// refreshLock resets the expiration of an existing lock.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/RefreshLock.html
+func refreshLock(w http.ResponseWriter, r *http.Request) {
+	withLock(w, r, func(n *tree.Node, current *utils.ContentLock) {
+		lockID := r.Header.Get("X-WOPI-Lock")
+		if current == nil || current.Token != lockID {
+			lockMismatch(w, r, current, "File is not locked with this lock ID")
+			return
+		}
+		setLock(w, r, n, lockID, lockID)
+	})
+}
+
+// unlock releases the lock on a file.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/Unlock.html
+func unlock(w http.ResponseWriter, r *http.Request) {
+	withLock(w, r, func(n *tree.Node, current *utils.ContentLock) {
+		if current == nil || current.Token != r.Header.Get("X-WOPI-Lock") {
+			lockMismatch(w, r, current, "File is not locked with this lock ID")
+			return
+		}
+		if e := views.DeleteContentLock(r.Context(), n.Uuid, current); e != nil {
+			if errors.Parse(e.Error()).Code == http.StatusConflict {
+				reloadAndMismatch(w, r, n, "File is not locked with this lock ID")
+				return
+			}
+			log.Logger(r.Context()).Error("cannot delete lock", n.Zap(), zap.Error(e))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// unlockAndRelock releases a lock and immediately sets a new one with a different lock ID.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/UnlockAndRelock.html
+func unlockAndRelock(w http.ResponseWriter, r *http.Request) {
+	withLock(w, r, func(n *tree.Node, current *utils.ContentLock) {
+		if current == nil || current.Token != r.Header.Get("X-WOPI-OldLock") {
+			lockMismatch(w, r, current, "File is not locked with this lock ID")
+			return
+		}
+		setLock(w, r, n, r.Header.Get("X-WOPI-Lock"), r.Header.Get("X-WOPI-OldLock"))
+	})
+}
+
+// withLock loads the node and its current lock and passes them to the callback. Lock updates
+// are conditional writes, thus concurrent requests on the same lock are detected whatever the
+// gateway instance that serves them.
+func withLock(w http.ResponseWriter, r *http.Request, callback func(n *tree.Node, current *utils.ContentLock)) {
+	n, err := findNodeFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	current, err := views.GetContentLock(r.Context(), n.Uuid)
+	if err != nil {
+		log.Logger(r.Context()).Error("cannot load lock", n.Zap(), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	callback(n, current)
+}
+
+// setLock stores a lock owned by the current user with a fresh expiration date. An existing
+// lock is only replaced if its lock ID is still @expectedID.
+func setLock(w http.ResponseWriter, r *http.Request, n *tree.Node, lockID string, expectedID string) {
+	if lockID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var userName string
+	if claims, ok := r.Context().Value(claim.ContextKey).(claim.Claims); ok {
+		userName = claims.Name
+	}
+	l := &utils.ContentLock{
+		Owner:   userName,
+		Token:   lockID,
+		Expires: time.Now().Add(lockDuration).Unix(),
+	}
+	replace := func(current *utils.ContentLock) bool {
+		return current.Token == expectedID
+	}
+	if e := views.SetContentLock(r.Context(), n.Uuid, l, replace); e != nil {
+		if errors.Parse(e.Error()).Code == http.StatusConflict {
+			reloadAndMismatch(w, r, n, "File is locked by another editor")
+			return
+		}
+		log.Logger(r.Context()).Error("cannot set lock", n.Zap(), zap.Error(e))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// reloadAndMismatch reports a lock that was changed by a concurrent request.
+func reloadAndMismatch(w http.ResponseWriter, r *http.Request, n *tree.Node, reason string) {
+	current, err := views.GetContentLock(r.Context(), n.Uuid)
+	if err != nil {
+		log.Logger(r.Context()).Error("cannot load lock", n.Zap(), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	lockMismatch(w, r, current, reason)
+}
+
+// lockMismatch sends a 409 Conflict response with the current lock ID. Locks that were not set
+// by a WOPI client (e.g. from the web interface) are reported with an empty lock ID.
+func lockMismatch(w http.ResponseWriter, r *http.Request, current *utils.ContentLock, reason string) {
+	log.Logger(r.Context()).Debug("WOPI lock mismatch", zap.String("reason", reason), zap.Any("current", current))
+	if current != nil {
+		w.Header().Set("X-WOPI-Lock", current.Token)
+	} else {
+		w.Header().Set("X-WOPI-Lock", "")
+	}
+	w.Header().Set("X-WOPI-LockFailureReason", reason)
+	w.WriteHeader(http.StatusConflict)
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package wopi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+)
+
+// RelativeFile is the response sent back to the WOPI client after a PutRelativeFile operation.
+type RelativeFile struct {
+	Name string
+	Url  string
+}
+
+// putRelativeFile creates a new file next to the current one, typically for "Save As" operations.
+// See https://wopi.readthedocs.io/projects/wopirest/en/latest/files/PutRelativeFile.html
+func putRelativeFile(w http.ResponseWriter, r *http.Request) {
+	log.Logger(r.Context()).Debug("WOPI BACKEND - PutRelativeFile")
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	ctx := r.Context()
+
+	n, err := findNodeFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	suggested, e1 := decodeUTF7(r.Header.Get("X-WOPI-SuggestedTarget"))
+	relative, e2 := decodeUTF7(r.Header.Get("X-WOPI-RelativeTarget"))
+	if e1 != nil || e2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if (suggested == "") == (relative == "") {
+		// Exactly one of the two headers must be provided
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	dir := path.Dir(n.Path)
+	exists := func(name string) (*tree.Node, bool) {
+		resp, e := viewsRouter.GetClientsPool().GetTreeClient().ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: path.Join(dir, name)}})
+		if e != nil || resp.Node == nil {
+			return nil, false
+		}
+		return resp.Node, true
+	}
+
+	var target *tree.Node
+	if suggested != "" {
+		name := availableName(suggestedTargetName(n.GetStringMeta("name"), suggested), func(name string) bool {
+			_, ok := exists(name)
+			return ok
+		})
+		target = newRelativeNode(n, name)
+	} else {
+		if relative != path.Base(relative) || relative == "." || relative == ".." {
+			w.Header().Set("X-WOPI-InvalidFileNameError", "Invalid file name")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if existing, ok := exists(relative); ok {
+			if r.Header.Get("X-WOPI-OverwriteRelativeTarget") != "true" {
+				w.Header().Set("X-WOPI-ValidRelativeTarget", availableName(relative, func(name string) bool {
+					_, ok := exists(name)
+					return ok
+				}))
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if current, e := views.GetContentLock(ctx, existing.Uuid); e != nil || current != nil {
+				lockMismatch(w, r, current, "Target file is locked")
+				return
+			}
+			resp, e := viewsRouter.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Uuid: existing.Uuid}})
+			if e != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			target = resp.Node
+		} else {
+			target = newRelativeNode(n, relative)
+		}
+	}
+
+	var size int64
+	if h := r.Header.Get("X-WOPI-Size"); h != "" {
+		size, _ = strconv.ParseInt(h, 10, 64)
+	} else {
+		size = r.ContentLength
+	}
+	written, err := viewsRouter.PutObject(ctx, target, r.Body, &views.PutRequestData{Size: size})
+	if err != nil {
+		log.Logger(ctx).Error("cannot put relative object", zap.Int64("already written data Length", written), zap.Error(err))
+		if written == 0 {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	log.Logger(ctx).Debug("uploaded relative node", target.Zap(), zap.Int64("Data Length", written))
+
+	data, _ := json.Marshal(&RelativeFile{
+		Name: path.Base(target.Path),
+		Url:  relativeFileUrl(r, target.Uuid),
+	})
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// newRelativeNode prepares a node for a new file in the same folder as n.
+func newRelativeNode(n *tree.Node, name string) *tree.Node {
+	target := &tree.Node{
+		Path: path.Join(path.Dir(n.Path), name),
+		Type: tree.NodeType_LEAF,
+	}
+	target.SetMeta(common.META_NAMESPACE_DATASOURCE_NAME, n.GetStringMeta(common.META_NAMESPACE_DATASOURCE_NAME))
+	target.SetMeta(common.META_NAMESPACE_DATASOURCE_PATH, path.Join(path.Dir(n.GetStringMeta(common.META_NAMESPACE_DATASOURCE_PATH)), name))
+	return target
+}
+
+// relativeFileUrl builds the WOPI url of a file, reusing the access token of the current request.
+func relativeFileUrl(r *http.Request, uuid string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     "/wopi/files/" + uuid,
+		RawQuery: url.Values{"access_token": []string{r.URL.Query().Get("access_token")}}.Encode(),
+	}
+	return u.String()
+}
+
+// suggestedTargetName computes the new file name from the X-WOPI-SuggestedTarget header:
+// a value starting with a dot is an extension replacing the one of the original file.
+func suggestedTargetName(original, suggested string) string {
+	if strings.HasPrefix(suggested, ".") {
+		return strings.TrimSuffix(original, path.Ext(original)) + suggested
+	}
+	return suggested
+}
+
+// availableName appends an increasing suffix to the name until it does not exist.
+func availableName(name string, exists func(name string) bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; exists(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	return candidate
+}
+
+// decodeUTF7 decodes a string encoded with UTF-7 (RFC 2152), as used by WOPI headers containing file names.
+func decodeUTF7(s string) (string, error) {
+	if !strings.Contains(s, "+") {
+		return s, nil
+	}
+	out := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '+' {
+			out.WriteByte(s[i])
+			continue
+		}
+		// Find end of the base64 section
+		j := i + 1
+		for j < len(s) && isModifiedBase64(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			// "+-" encodes a plus sign
+			out.WriteByte('+')
+		} else {
+			data, e := base64.RawStdEncoding.DecodeString(s[i+1 : j])
+			if e != nil || len(data)%2 != 0 {
+				return "", fmt.Errorf("invalid UTF-7 sequence in %s", s)
+			}
+			units := make([]uint16, len(data)/2)
+			for k := range units {
+				units[k] = uint16(data[2*k])<<8 | uint16(data[2*k+1])
+			}
+			out.WriteString(string(utf16.Decode(units)))
+		}
+		i = j
+		if j < len(s) && s[j] != '-' {
+			// Character terminating the sequence is not absorbed
+			i = j - 1
+		}
+	}
+	return out.String(), nil
+}
+
+func isModifiedBase64(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '+' || c == '/'
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package wopi
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRelativeTargets(t *testing.T) {
+
+	Convey("Decode UTF-7 headers", t, func() {
+		s, e := decodeUTF7("simple name.docx")
+		So(e, ShouldBeNil)
+		So(s, ShouldEqual, "simple name.docx")
+
+		s, e = decodeUTF7("1+-1.docx")
+		So(e, ShouldBeNil)
+		So(s, ShouldEqual, "1+1.docx")
+
+		s, e = decodeUTF7("+AOk-t+AOk.docx")
+		So(e, ShouldBeNil)
+		So(s, ShouldEqual, "été.docx")
+
+		_, e = decodeUTF7("+AO.docx")
+		So(e, ShouldNotBeNil)
+	})
+
+	Convey("Compute suggested names", t, func() {
+		So(suggestedTargetName("report.docx", ".pdf"), ShouldEqual, "report.pdf")
+		So(suggestedTargetName("report.docx", "other.odt"), ShouldEqual, "other.odt")
+
+		existing := map[string]bool{"report.pdf": true, "report-1.pdf": true}
+		exists := func(name string) bool { return existing[name] }
+		So(availableName("report.pdf", exists), ShouldEqual, "report-2.pdf")
+		So(availableName("other.pdf", exists), ShouldEqual, "other.pdf")
+	})
+
+}
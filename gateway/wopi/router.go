@@ -80,6 +80,17 @@ var myRoutes = routes{
 		getNodeInfos,
 	},
 
+	// Lock, Unlock, RefreshLock, UnlockAndRelock, GetLock and PutRelativeFile operations
+	// share the same endpoint and are distinguished by the X-WOPI-Override header.
+	// Locks are stored centrally, so that they are also honored by other gateways.
+	// See https://wopi.readthedocs.io/projects/wopirest/en/latest/endpoints.html#files-endpoint
+	route{
+		"FileOperation",
+		"POST",
+		"/wopi/files/{uuid}",
+		fileOperation,
+	},
+
 	route{
 		"Download",
 		"GET",
@@ -31,6 +31,7 @@ type DAO interface {
 	dao.DAO
 
 	Add(interface{}) error
+	Replace(in interface{}, expectedValue string) (bool, error)
 	Del(sql.Enquirer) (numRows int64, e error)
 	Search(sql.Enquirer, *[]interface{}) error
 }
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
@@ -196,3 +197,112 @@ func TestQueryBuilder(t *testing.T) {
 		//So(s, ShouldEqual, `((action_name='read' OR action_name='write')) AND (role_id in (select id from idm_acl_roles where uuid in ("role1","role2"))) AND (node_id in (select id from idm_acl_nodes where uuid in ("node1")))`)
 	})
 }
+
+func TestValidityWindow(t *testing.T) {
+
+	now := int32(time.Now().Unix())
+	searchQuery := func(q *idm.ACLSingleQuery) *service.Query {
+		q.RoleIDs = []string{"temporary-role"}
+		qAny, _ := ptypes.MarshalAny(q)
+		return &service.Query{SubQueries: []*any.Any{qAny}}
+	}
+
+	Convey("Add time-limited ACLs", t, func() {
+		So(mockDAO.Add(&idm.ACL{RoleID: "temporary-role", WorkspaceID: "temporary-ws", NodeID: "permanent", Action: &idm.ACLAction{Name: "read", Value: "1"}}), ShouldBeNil)
+		So(mockDAO.Add(&idm.ACL{RoleID: "temporary-role", WorkspaceID: "temporary-ws", NodeID: "current", Action: &idm.ACLAction{Name: "read", Value: "1"}, NotBefore: now - 60, ExpiresAt: now + 3600}), ShouldBeNil)
+		So(mockDAO.Add(&idm.ACL{RoleID: "temporary-role", WorkspaceID: "temporary-ws", NodeID: "future", Action: &idm.ACLAction{Name: "read", Value: "1"}, NotBefore: now + 3600}), ShouldBeNil)
+		So(mockDAO.Add(&idm.ACL{RoleID: "temporary-role", WorkspaceID: "temporary-ws", NodeID: "expired", Action: &idm.ACLAction{Name: "read", Value: "1"}, ExpiresAt: now - 60}), ShouldBeNil)
+	})
+
+	Convey("Search only finds valid ACLs", t, func() {
+		acls := new([]interface{})
+		So(mockDAO.Search(searchQuery(&idm.ACLSingleQuery{}), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 2)
+		for _, a := range *acls {
+			So(a.(*idm.ACL).NodeID, ShouldBeIn, []string{"permanent", "current"})
+		}
+	})
+
+	Convey("Search inactive ACLs", t, func() {
+		acls := new([]interface{})
+		So(mockDAO.Search(searchQuery(&idm.ACLSingleQuery{IncludeInactive: true}), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 4)
+
+		acls = new([]interface{})
+		So(mockDAO.Search(searchQuery(&idm.ACLSingleQuery{ExpiredBefore: now}), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 1)
+		So((*acls)[0].(*idm.ACL).NodeID, ShouldEqual, "expired")
+		So((*acls)[0].(*idm.ACL).ExpiresAt, ShouldEqual, now-60)
+	})
+
+	Convey("Delete expired ACLs", t, func() {
+		num, err := mockDAO.Del(searchQuery(&idm.ACLSingleQuery{ExpiredBefore: now}))
+		So(err, ShouldBeNil)
+		So(num, ShouldEqual, 1)
+
+		acls := new([]interface{})
+		So(mockDAO.Search(searchQuery(&idm.ACLSingleQuery{IncludeInactive: true}), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 3)
+	})
+}
+
+func TestReplace(t *testing.T) {
+
+	Convey("Replace an ACL value only if it did not change", t, func() {
+		acl := &idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user1"}}
+		So(mockDAO.Add(acl), ShouldBeNil)
+
+		// A second ACL for the same node and action cannot be created
+		So(mockDAO.Add(&idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user2"}}), ShouldNotBeNil)
+
+		replaced, err := mockDAO.Replace(&idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user2"}}, "other")
+		So(err, ShouldBeNil)
+		So(replaced, ShouldBeFalse)
+
+		replaced, err = mockDAO.Replace(&idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user2"}}, "user1")
+		So(err, ShouldBeNil)
+		So(replaced, ShouldBeTrue)
+
+		// The stored value is now user2
+		replaced, err = mockDAO.Replace(&idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user3"}}, "user1")
+		So(err, ShouldBeNil)
+		So(replaced, ShouldBeFalse)
+		replaced, err = mockDAO.Replace(&idm.ACL{NodeID: "locked-node", Action: &idm.ACLAction{Name: "content_lock", Value: "user3"}}, "user2")
+		So(err, ShouldBeNil)
+		So(replaced, ShouldBeTrue)
+	})
+}
+
+func TestSearchLocks(t *testing.T) {
+
+	lockQuery := func(q *idm.ACLSingleQuery, limit int64) *service.Query {
+		qAny, _ := ptypes.MarshalAny(q)
+		return &service.Query{SubQueries: []*any.Any{qAny}, Limit: limit}
+	}
+
+	Convey("Search locks by value, excluding values or without limit", t, func() {
+		for i := 0; i < 110; i++ {
+			So(mockDAO.Add(&idm.ACL{NodeID: fmt.Sprintf("json-lock-%d", i), Action: &idm.ACLAction{Name: "content_lock", Value: fmt.Sprintf(`{"owner":"user","path":"root/folder/file%d"}`, i)}}), ShouldBeNil)
+		}
+		So(mockDAO.Add(&idm.ACL{NodeID: "legacy-lock", Action: &idm.ACLAction{Name: "content_lock", Value: "user"}}), ShouldBeNil)
+
+		acls := new([]interface{})
+		So(mockDAO.Search(lockQuery(&idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: "content_lock", Value: `*"path":"root/folder/*`}}}, 0), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 100)
+
+		acls = new([]interface{})
+		So(mockDAO.Search(lockQuery(&idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: "content_lock", Value: `*"path":"root/folder/*`}}}, -1), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 110)
+
+		acls = new([]interface{})
+		So(mockDAO.Search(lockQuery(&idm.ACLSingleQuery{Actions: []*idm.ACLAction{{Name: "content_lock", Value: "{*"}}, Not: true}, -1), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 2)
+		for _, a := range *acls {
+			So(a.(*idm.ACL).NodeID, ShouldBeIn, []string{"legacy-lock", "locked-node"})
+		}
+
+		acls = new([]interface{})
+		So(mockDAO.Search(lockQuery(&idm.ACLSingleQuery{NodeIDs: []string{"legacy-lock", "locked-node"}, Actions: []*idm.ACLAction{{Name: "content_lock"}}, Not: true}, -1), acls), ShouldBeNil)
+		So(*acls, ShouldHaveLength, 110)
+	})
+}
@@ -23,11 +23,14 @@ package grpc
 import (
 	"context"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/errors"
 	"github.com/pydio/cells/common"
 	"github.com/pydio/cells/common/proto/idm"
 	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/service/proto"
 	"github.com/pydio/cells/idm/acl"
 )
 
@@ -40,7 +43,13 @@ func (h *Handler) CreateACL(ctx context.Context, req *idm.CreateACLRequest, resp
 
 	dao := servicecontext.GetDAO(ctx).(acl.DAO)
 
-	if err := dao.Add(req.ACL); err != nil {
+	if req.ExpectedValue != "" {
+		if replaced, err := dao.Replace(req.ACL, req.ExpectedValue); err != nil {
+			return err
+		} else if !replaced {
+			return errors.Conflict(common.SERVICE_ACL, "ACL value has changed")
+		}
+	} else if err := dao.Add(req.ACL); err != nil {
 		return err
 	}
 
@@ -58,7 +67,7 @@ func (h *Handler) DeleteACL(ctx context.Context, req *idm.DeleteACLRequest, resp
 	dao := servicecontext.GetDAO(ctx).(acl.DAO)
 
 	acls := new([]interface{})
-	if err := dao.Search(req.Query, acls); err != nil {
+	if err := dao.Search(withInactive(req.Query), acls); err != nil {
 		return err
 	}
 
@@ -130,3 +139,23 @@ func (h *Handler) StreamACL(ctx context.Context, streamer idm.ACLService_StreamA
 
 	return nil
 }
+
+// withInactive modifies the ACLs sub-queries of a query so that they also
+// match the ACLs that are not yet or no longer valid.
+func withInactive(query *service.Query) *service.Query {
+	if query == nil {
+		return nil
+	}
+	q := proto.Clone(query).(*service.Query)
+	for i, subQ := range q.SubQueries {
+		single := new(idm.ACLSingleQuery)
+		if err := ptypes.UnmarshalAny(subQ, single); err != nil {
+			continue
+		}
+		single.IncludeInactive = true
+		if a, err := ptypes.MarshalAny(single); err == nil {
+			q.SubQueries[i] = a
+		}
+	}
+	return q
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/pydio/cells/common/proto/tree"
 	"github.com/pydio/cells/common/service/context"
 	"github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/common/utils"
 	"github.com/pydio/cells/idm/acl"
 )
 
@@ -59,7 +60,9 @@ func (h *Handler) ReadNodeStream(ctx context.Context, stream tree.NodeProviderSt
 		dao.Search(&service.Query{SubQueries: []*any.Any{q}}, acls)
 		for _, in := range *acls {
 			val, _ := in.(*idm.ACL)
-			node.SetMeta("content_lock", val.Action.Value)
+			if lock := utils.ParseContentLock(val.Action.Value); !lock.IsExpired() {
+				node.SetMeta("content_lock", lock.Owner)
+			}
 			break
 		}
 		stream.Send(&tree.ReadNodeResponse{Node: node})
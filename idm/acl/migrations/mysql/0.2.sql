@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE idm_acls ADD COLUMN not_before INT NOT NULL DEFAULT 0, ADD COLUMN expires_at INT NOT NULL DEFAULT 0;
+CREATE INDEX idm_acls_expires_at ON idm_acls (expires_at);
+
+-- +migrate Down
+DROP INDEX idm_acls_expires_at ON idm_acls;
+ALTER TABLE idm_acls DROP COLUMN not_before, DROP COLUMN expires_at;
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE idm_acls ADD COLUMN not_before INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE idm_acls ADD COLUMN expires_at INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idm_acls_expires_at ON idm_acls (expires_at);
+
+-- +migrate Down
+DROP INDEX idm_acls_expires_at;
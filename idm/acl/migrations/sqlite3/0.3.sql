@@ -0,0 +1,26 @@
+-- +migrate Up
+-- Duplicates created by concurrent inserts are merged in the entry with the lowest id before adding the unique
+-- indexes. ACLs already existing on the surviving entry are kept, the ones of the duplicates are dropped.
+UPDATE OR IGNORE idm_acls SET node_id = (SELECT MIN(m.id) FROM idm_acl_nodes m WHERE m.uuid = (SELECT n.uuid FROM idm_acl_nodes n WHERE n.id = idm_acls.node_id))
+    WHERE node_id IN (SELECT n.id FROM idm_acl_nodes n WHERE EXISTS (SELECT 1 FROM idm_acl_nodes m WHERE m.uuid = n.uuid AND m.id < n.id));
+DELETE FROM idm_acls WHERE node_id IN (SELECT n.id FROM idm_acl_nodes n WHERE EXISTS (SELECT 1 FROM idm_acl_nodes m WHERE m.uuid = n.uuid AND m.id < n.id));
+DELETE FROM idm_acl_nodes WHERE EXISTS (SELECT 1 FROM idm_acl_nodes m WHERE m.uuid = idm_acl_nodes.uuid AND m.id < idm_acl_nodes.id);
+
+UPDATE OR IGNORE idm_acls SET role_id = (SELECT MIN(m.id) FROM idm_acl_roles m WHERE m.uuid = (SELECT r.uuid FROM idm_acl_roles r WHERE r.id = idm_acls.role_id))
+    WHERE role_id IN (SELECT r.id FROM idm_acl_roles r WHERE EXISTS (SELECT 1 FROM idm_acl_roles m WHERE m.uuid = r.uuid AND m.id < r.id));
+DELETE FROM idm_acls WHERE role_id IN (SELECT r.id FROM idm_acl_roles r WHERE EXISTS (SELECT 1 FROM idm_acl_roles m WHERE m.uuid = r.uuid AND m.id < r.id));
+DELETE FROM idm_acl_roles WHERE EXISTS (SELECT 1 FROM idm_acl_roles m WHERE m.uuid = idm_acl_roles.uuid AND m.id < idm_acl_roles.id);
+
+UPDATE OR IGNORE idm_acls SET workspace_id = (SELECT MIN(m.id) FROM idm_acl_workspaces m WHERE m.name = (SELECT w.name FROM idm_acl_workspaces w WHERE w.id = idm_acls.workspace_id))
+    WHERE workspace_id IN (SELECT w.id FROM idm_acl_workspaces w WHERE EXISTS (SELECT 1 FROM idm_acl_workspaces m WHERE m.name = w.name AND m.id < w.id));
+DELETE FROM idm_acls WHERE workspace_id IN (SELECT w.id FROM idm_acl_workspaces w WHERE EXISTS (SELECT 1 FROM idm_acl_workspaces m WHERE m.name = w.name AND m.id < w.id));
+DELETE FROM idm_acl_workspaces WHERE EXISTS (SELECT 1 FROM idm_acl_workspaces m WHERE m.name = idm_acl_workspaces.name AND m.id < idm_acl_workspaces.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idm_acl_nodes_uuid ON idm_acl_nodes (uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idm_acl_roles_uuid ON idm_acl_roles (uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idm_acl_workspaces_name ON idm_acl_workspaces (name);
+
+-- +migrate Down
+DROP INDEX idm_acl_nodes_uuid;
+DROP INDEX idm_acl_roles_uuid;
+DROP INDEX idm_acl_workspaces_name;
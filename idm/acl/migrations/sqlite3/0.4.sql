@@ -0,0 +1,9 @@
+-- +migrate Up
+INSERT OR IGNORE INTO idm_acl_workspaces (id, name) VALUES (-1, '');
+INSERT OR IGNORE INTO idm_acl_nodes (id, uuid) VALUES (-1, '');
+INSERT OR IGNORE INTO idm_acl_roles (id, uuid) VALUES (-1, '');
+
+-- +migrate Down
+DELETE FROM idm_acl_workspaces WHERE id = -1;
+DELETE FROM idm_acl_nodes WHERE id = -1;
+DELETE FROM idm_acl_roles WHERE id = -1;
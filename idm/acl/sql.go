@@ -24,6 +24,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/packr"
 	"github.com/golang/protobuf/ptypes"
@@ -39,11 +41,23 @@ import (
 )
 
 var (
-	queries = map[string]string{
-		"AddACL":          `insert into idm_acls (action_name, action_value, role_id, workspace_id, node_id) values (?, ?, ?, ?, ?)`,
-		"AddACLNode":      `insert into idm_acl_nodes (uuid) values (?)`,
-		"AddACLRole":      `insert into idm_acl_roles (uuid) values (?)`,
-		"AddACLWorkspace": `insert into idm_acl_workspaces (name) values (?)`,
+	queries = map[string]interface{}{
+		"AddACL": sql.DriverQueries{
+			"":         `insert into idm_acls (action_name, action_value, role_id, workspace_id, node_id, not_before, expires_at) values (?, ?, ?, ?, ?, ?, ?)`,
+			"postgres": `insert into idm_acls (action_name, action_value, role_id, workspace_id, node_id, not_before, expires_at) values (?, ?, ?, ?, ?, ?, ?) returning id`,
+		},
+		"AddACLNode": sql.DriverQueries{
+			"":         `insert into idm_acl_nodes (uuid) values (?)`,
+			"postgres": `insert into idm_acl_nodes (uuid) values (?) returning id`,
+		},
+		"AddACLRole": sql.DriverQueries{
+			"":         `insert into idm_acl_roles (uuid) values (?)`,
+			"postgres": `insert into idm_acl_roles (uuid) values (?) returning id`,
+		},
+		"AddACLWorkspace": sql.DriverQueries{
+			"":         `insert into idm_acl_workspaces (name) values (?)`,
+			"postgres": `insert into idm_acl_workspaces (name) values (?) returning id`,
+		},
 		"GetACLNode":      `select id from idm_acl_nodes where uuid = ?`,
 		"GetACLRole":      `select id from idm_acl_roles where uuid = ?`,
 		"GetACLWorkspace": `select id from idm_acl_workspaces where name = ?`,
@@ -127,12 +141,7 @@ func (dao *sqlimpl) Add(in interface{}) error {
 	}
 	log.Logger(context.Background()).Debug("AddACL",
 		zap.String("r", roleID), zap.String("w", workspaceID), zap.String("n", nodeID), zap.Any("value", val))
-	res, err := dao.GetStmt("AddACL").Exec(val.Action.Name, val.Action.Value, roleID, workspaceID, nodeID)
-	if err != nil {
-		return err
-	}
-
-	id, err := res.LastInsertId()
+	id, err := dao.insert("AddACL", val.Action.Name, val.Action.Value, roleID, workspaceID, nodeID, val.NotBefore, val.ExpiresAt)
 	if err != nil {
 		return err
 	}
@@ -142,6 +151,49 @@ func (dao *sqlimpl) Add(in interface{}) error {
 	return nil
 }
 
+// Replace updates the value of an existing ACL with a single conditional statement: it is only
+// updated if its current value is still expectedValue.
+func (dao *sqlimpl) Replace(in interface{}, expectedValue string) (bool, error) {
+
+	val, ok := in.(*idm.ACL)
+	if !ok {
+		return false, errors.New("Wrong type")
+	}
+
+	if val.Action == nil {
+		return false, errors.New("Missing action value")
+	}
+
+	where := []string{"action_name = ?", "action_value = ?"}
+	args := []interface{}{val.Action.Value, val.NotBefore, val.ExpiresAt, val.Action.Name, expectedValue}
+	for _, ref := range []struct{ column, table, key, value string }{
+		{"node_id", "idm_acl_nodes", "uuid", val.NodeID},
+		{"role_id", "idm_acl_roles", "uuid", val.RoleID},
+		{"workspace_id", "idm_acl_workspaces", "name", val.WorkspaceID},
+	} {
+		if ref.value == "" {
+			where = append(where, ref.column+" = -1")
+		} else {
+			where = append(where, fmt.Sprintf("%s in (select id from %s where %s = ?)", ref.column, ref.table, ref.key))
+			args = append(args, ref.value)
+		}
+	}
+
+	queryString := "update idm_acls set action_value = ?, not_before = ?, expires_at = ? where " + strings.Join(where, " and ")
+	if dao.Driver() == "postgres" {
+		queryString = sql.Rebind(queryString)
+	}
+	res, err := dao.DB().Exec(queryString, args...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
 // Search in the mysql DB
 func (dao *sqlimpl) Search(query sql.Enquirer, acls *[]interface{}) error {
 
@@ -158,18 +210,28 @@ func (dao *sqlimpl) Search(query sql.Enquirer, acls *[]interface{}) error {
 		expressions = append(expressions, whereExpression)
 	}
 
+	// Filter out ACLs that are not yet or no longer valid
+	if !includeInactive(query) {
+		now := time.Now().Unix()
+		expressions = append(expressions,
+			goqu.I("a.not_before").Lte(now),
+			goqu.Or(goqu.I("a.expires_at").Eq(0), goqu.I("a.expires_at").Gt(now)),
+		)
+	}
+
 	offset, limit := int64(0), int64(100)
 	if query.GetOffset() > 0 {
 		offset = query.GetOffset()
 	}
-	if query.GetLimit() > 0 {
+	if query.GetLimit() != 0 {
+		// A negative limit lists all matching ACLs
 		limit = query.GetLimit()
 	}
 
 	dataset := db.From(goqu.I("idm_acls").As("a"),
 		goqu.I("idm_acl_nodes").As("n"), goqu.I("idm_acl_workspaces").As("w"), goqu.I("idm_acl_roles").As("r"))
 
-	dataset = dataset.Select(goqu.I("a.id"), goqu.I("n.uuid"), goqu.I("a.action_name"), goqu.I("a.action_value"), goqu.I("r.uuid"), goqu.I("w.name"))
+	dataset = dataset.Select(goqu.I("a.id"), goqu.I("n.uuid"), goqu.I("a.action_name"), goqu.I("a.action_value"), goqu.I("r.uuid"), goqu.I("w.name"), goqu.I("a.not_before"), goqu.I("a.expires_at"))
 	dataset = dataset.Offset(uint(offset))
 	if limit > -1 {
 		dataset = dataset.Limit(uint(limit))
@@ -198,6 +260,8 @@ func (dao *sqlimpl) Search(query sql.Enquirer, acls *[]interface{}) error {
 			&action.Value,
 			&val.RoleID,
 			&val.WorkspaceID,
+			&val.NotBefore,
+			&val.ExpiresAt,
 		)
 
 		val.Action = action
@@ -238,21 +302,9 @@ func (dao *sqlimpl) Del(query sql.Enquirer) (int64, error) {
 
 func (dao *sqlimpl) addWorkspace(uuid string) (string, error) {
 
-	res, err := dao.GetStmt("AddACLWorkspace").Exec(uuid)
+	newID, err := dao.insert("AddACLWorkspace", uuid)
 	if err == nil {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return "", err
-		}
-
-		if rows > 0 {
-			id, err := res.LastInsertId()
-			if err != nil {
-				return "", err
-			}
-
-			return fmt.Sprintf("%d", id), nil
-		}
+		return fmt.Sprintf("%d", newID), nil
 	}
 
 	row := dao.GetStmt("GetACLWorkspace").QueryRow(uuid)
@@ -268,21 +320,9 @@ func (dao *sqlimpl) addWorkspace(uuid string) (string, error) {
 
 func (dao *sqlimpl) addNode(uuid string) (string, error) {
 
-	res, err := dao.GetStmt("AddACLNode").Exec(uuid)
+	newID, err := dao.insert("AddACLNode", uuid)
 	if err == nil {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return "", err
-		}
-
-		if rows > 0 {
-			id, err := res.LastInsertId()
-			if err != nil {
-				return "", err
-			}
-
-			return fmt.Sprintf("%d", id), nil
-		}
+		return fmt.Sprintf("%d", newID), nil
 	}
 
 	// Checking we didn't have a duplicate
@@ -299,21 +339,9 @@ func (dao *sqlimpl) addNode(uuid string) (string, error) {
 
 func (dao *sqlimpl) addRole(uuid string) (string, error) {
 
-	res, err := dao.GetStmt("AddACLRole").Exec(uuid)
+	newID, err := dao.insert("AddACLRole", uuid)
 	if err == nil {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return "", err
-		}
-
-		if rows > 0 {
-			id, err := res.LastInsertId()
-			if err != nil {
-				return "", err
-			}
-
-			return fmt.Sprintf("%d", id), nil
-		}
+		return fmt.Sprintf("%d", newID), nil
 	}
 
 	// Checking we didn't have a duplicate
@@ -328,6 +356,31 @@ func (dao *sqlimpl) addRole(uuid string) (string, error) {
 	return id, nil
 }
 
+// insert runs one of the insert statements and returns the id of the new row.
+// As postgres does not support LastInsertId, its statements return the id instead.
+func (dao *sqlimpl) insert(key string, args ...interface{}) (int64, error) {
+
+	var id int64
+
+	if dao.Driver() == "postgres" {
+		err := dao.GetStmt(key).QueryRow(args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := dao.GetStmt(key).Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if rows == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	return res.LastInsertId()
+}
+
 type queryConverter idm.ACLSingleQuery
 
 func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression, bool) {
@@ -348,7 +401,11 @@ func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression,
 		if err != nil {
 			return nil, true
 		}
-		expressions = append(expressions, goqu.I("role_id").In(goqu.L(str)))
+		if q.Not {
+			expressions = append(expressions, goqu.I("role_id").NotIn(goqu.L(str)))
+		} else {
+			expressions = append(expressions, goqu.I("role_id").In(goqu.L(str)))
+		}
 	}
 
 	if len(q.WorkspaceIDs) > 0 {
@@ -358,7 +415,11 @@ func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression,
 		if err != nil {
 			return nil, true
 		}
-		expressions = append(expressions, goqu.I("workspace_id").In(goqu.L(str)))
+		if q.Not {
+			expressions = append(expressions, goqu.I("workspace_id").NotIn(goqu.L(str)))
+		} else {
+			expressions = append(expressions, goqu.I("workspace_id").In(goqu.L(str)))
+		}
 	}
 
 	if len(q.NodeIDs) > 0 {
@@ -369,7 +430,11 @@ func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression,
 		if err != nil {
 			return nil, true
 		}
-		expressions = append(expressions, goqu.I("node_id").In(goqu.L(str)))
+		if q.Not {
+			expressions = append(expressions, goqu.I("node_id").NotIn(goqu.L(str)))
+		} else {
+			expressions = append(expressions, goqu.I("node_id").In(goqu.L(str)))
+		}
 	}
 
 	// Special case for Actions
@@ -393,7 +458,7 @@ func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression,
 
 			actionAndExpression = append(actionAndExpression, sql.GetExpressionForString(false, "action_name", actName))
 			if len(actValues) > 0 {
-				actionAndExpression = append(actionAndExpression, sql.GetExpressionForString(false, "action_value", actValues...))
+				actionAndExpression = append(actionAndExpression, sql.GetExpressionForString(q.Not, "action_value", actValues...))
 				orExpression = append(orExpression, goqu.And(actionAndExpression...))
 			} else {
 				orExpression = append(orExpression, actionAndExpression...)
@@ -402,9 +467,24 @@ func (c *queryConverter) Convert(val *any.Any, driver string) (goqu.Expression,
 		expressions = append(expressions, goqu.Or(orExpression...))
 	}
 
+	if q.ExpiredBefore > 0 {
+		expressions = append(expressions, goqu.I("expires_at").Gt(0), goqu.I("expires_at").Lt(q.ExpiredBefore))
+	}
+
 	return goqu.And(expressions...), true
 }
 
+// includeInactive checks if one of the sub-queries asks for ACLs outside of their validity window.
+func includeInactive(query sql.Enquirer) bool {
+	for _, subQ := range query.GetSubQueries() {
+		q := new(idm.ACLSingleQuery)
+		if err := ptypes.UnmarshalAny(subQ, q); err == nil && (q.IncludeInactive || q.ExpiredBefore > 0) {
+			return true
+		}
+	}
+	return false
+}
+
 // Internal helper functions
 
 func quote(v string) string {
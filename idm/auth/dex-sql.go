@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/coreos/dex/storage"
@@ -22,7 +23,14 @@ func (s *dexSql) Init(options config.Map) error {
 	// super
 	s.DAO.Init(options)
 
-	// Do NOT prepare the stmts now
+	// Do NOT prepare the stmts now, tables are created by dex: they are prepared on first use
+	s.Prepare("deleteOfflineSession", func(...string) string {
+		return `DELETE FROM dex_offline_session WHERE user_id=? AND conn_id=?`
+	})
+	s.Prepare("deleteRefreshToken", func(...string) string {
+		return `DELETE FROM dex_refresh_token WHERE claims_user_id=? AND nonce=?`
+	})
+
 	return nil
 }
 
@@ -82,13 +90,13 @@ func (s *dexSql) DexPruneOfflineSessions(c Config) (pruned int64, e error) {
 // DexDeleteOfflineSessions Delete offline session and refresh token based on uuid / session uuid (nonce)
 func (s *dexSql) DexDeleteOfflineSessions(c Config, userUuid string, sessionUuid string) error {
 
-	offline, e := s.DB().Prepare(`DELETE FROM dex_offline_session WHERE user_id=? AND conn_id=?`)
-	if e != nil {
-		return e
+	offline := s.GetStmt("deleteOfflineSession")
+	if offline == nil {
+		return fmt.Errorf("cannot prepare offline session deletion")
 	}
-	refresh, e := s.DB().Prepare(`DELETE FROM dex_refresh_token WHERE claims_user_id=? AND nonce=?`)
-	if e != nil {
-		return e
+	refresh := s.GetStmt("deleteRefreshToken")
+	if refresh == nil {
+		return fmt.Errorf("cannot prepare refresh token deletion")
 	}
 
 	// This session needs deletion. Delete offline session and refresh_token
@@ -41,6 +41,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
+	"github.com/pydio/cells/common/auth/dex"
 	"github.com/pydio/cells/common/service"
 	"github.com/pydio/cells/common/service/context"
 	"github.com/pydio/cells/idm/auth"
@@ -135,8 +136,8 @@ func serve(c auth.Config, pydioSrvContext context.Context, pydioLogger *zap.Logg
 		s = storage.WithStaticPasswords(s, passwords, logger)
 	}
 
-	storageConnectors := make([]storage.Connector, len(c.StaticConnectors))
-	for i, c := range c.StaticConnectors {
+	var storageConnectors []storage.Connector
+	for _, c := range c.StaticConnectors {
 		if c.ID == "" || c.Name == "" || c.Type == "" {
 			return fmt.Errorf("invalid config: ID, Type and Name fields are required for a connector")
 		}
@@ -150,7 +151,20 @@ func serve(c auth.Config, pydioSrvContext context.Context, pydioLogger *zap.Logg
 		if err != nil {
 			return fmt.Errorf("failed to initialize storage connectors: %v", err)
 		}
-		storageConnectors[i] = conn
+		storageConnectors = append(storageConnectors, conn)
+
+		// Redirect-based sub-connectors of the pydio wrapper are exposed as separate connectors
+		if wrapper, ok := c.Config.(*dex.WrapperConfig); ok {
+			for _, ext := range wrapper.ExternalConnectors() {
+				logger.Infof("config connector: %s-%s", c.ID, ext.Name)
+				storageConnectors = append(storageConnectors, storage.Connector{
+					ID:     c.ID + "-" + ext.Name,
+					Type:   dex.ExternalConnectorTypePrefix + ext.Type,
+					Name:   ext.Name,
+					Config: ext.Config,
+				})
+			}
+		}
 
 	}
 
@@ -23,6 +23,7 @@ package rest
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/emicklei/go-restful"
@@ -30,9 +31,11 @@ import (
 	"github.com/pborman/uuid"
 
 	"github.com/pydio/cells/common"
+	commonauth "github.com/pydio/cells/common/auth"
 	"github.com/pydio/cells/common/auth/claim"
 	"github.com/pydio/cells/common/proto/auth"
 	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/encryption"
 	"github.com/pydio/cells/common/proto/idm"
 	"github.com/pydio/cells/common/proto/mailer"
 	"github.com/pydio/cells/common/proto/rest"
@@ -95,6 +98,219 @@ func (a *TokenHandler) Revoke(req *restful.Request, resp *restful.Response) {
 
 }
 
+// CreateAppPassword generates a new application password for the current user. The password
+// is only sent back in this response.
+func (a *TokenHandler) CreateAppPassword(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	var input rest.CreateAppPasswordRequest
+	if e := req.ReadEntity(&input); e != nil {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "Cannot decode input request"))
+		return
+	}
+	if input.Label == "" {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "Please provide a label for this password"))
+		return
+	}
+
+	key, password, e := commonauth.CreateAppPassword(ctx, claims.Name, input.Label)
+	if e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+	output := appPasswordFromKey(key)
+	output.Password = password
+	resp.WriteEntity(output)
+
+}
+
+// ListAppPasswords lists the application passwords of the current user, without their value.
+func (a *TokenHandler) ListAppPasswords(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	keys, e := commonauth.ListAppPasswords(ctx, claims.Name)
+	if e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+	output := &rest.AppPasswordCollection{}
+	for _, k := range keys {
+		output.AppPasswords = append(output.AppPasswords, appPasswordFromKey(k))
+	}
+	resp.WriteEntity(output)
+
+}
+
+// RevokeAppPassword deletes an application password of the current user.
+func (a *TokenHandler) RevokeAppPassword(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	id := req.PathParameter("Id")
+	if id == "" {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "Please provide a password Id"))
+		return
+	}
+	if e := commonauth.DeleteAppPassword(ctx, claims.Name, id); e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+
+	resp.WriteEntity(&rest.RevokeResponse{Success: true, Message: "Application password successfully revoked"})
+
+}
+
+// GetSecondFactor tells whether the current user has enrolled a second factor, and whether policies require it.
+func (a *TokenHandler) GetSecondFactor(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	sf, e := commonauth.LoadSecondFactor(ctx, claims.Name)
+	if e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+	output := &rest.SecondFactorStatus{}
+	if sf != nil && sf.Confirmed {
+		output.Enrolled = true
+		output.RecoveryCodesLeft = int32(len(sf.RecoveryCodes))
+	}
+	if u, e := utils.SearchUniqueUser(ctx, claims.Name, ""); e == nil && u != nil {
+		output.Required = commonauth.SecondFactorRequired(ctx, u)
+	}
+	resp.WriteEntity(output)
+
+}
+
+// EnrollSecondFactor generates a new TOTP secret for the current user. It must be confirmed
+// with a first code before being used at login.
+func (a *TokenHandler) EnrollSecondFactor(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	secret, uri, e := commonauth.EnrollSecondFactor(ctx, claims.Name)
+	if e == commonauth.ErrSecondFactorEnrolled {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "%s", e.Error()))
+		return
+	} else if e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+	resp.WriteEntity(&rest.SecondFactorEnrollment{Secret: secret, KeyURI: uri})
+
+}
+
+// ConfirmSecondFactor activates the pending enrollment of the current user and sends back
+// the recovery codes. They cannot be retrieved afterward.
+func (a *TokenHandler) ConfirmSecondFactor(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	var input rest.ConfirmSecondFactorRequest
+	if e := req.ReadEntity(&input); e != nil {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "Cannot decode input request"))
+		return
+	}
+	codes, e := commonauth.ConfirmSecondFactor(ctx, claims.Name, input.Code)
+	switch e {
+	case nil:
+	case commonauth.ErrSecondFactorNotEnrolled, commonauth.ErrSecondFactorEnrolled, commonauth.ErrInvalidSecondFactor:
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "%s", e.Error()))
+		return
+	case commonauth.ErrSecondFactorLocked:
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "%s", e.Error()))
+		return
+	default:
+		service.RestError500(req, resp, e)
+		return
+	}
+	resp.WriteEntity(&rest.SecondFactorRecoveryCodes{RecoveryCodes: codes})
+
+}
+
+// DisableSecondFactor removes the second factor of the current user, who must provide a valid code.
+// Admins can reset the second factor of another user by passing its login.
+func (a *TokenHandler) DisableSecondFactor(req *restful.Request, resp *restful.Response) {
+
+	ctx := req.Request.Context()
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "invalid token"))
+		return
+	}
+
+	var input rest.DisableSecondFactorRequest
+	if e := req.ReadEntity(&input); e != nil {
+		service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "Cannot decode input request"))
+		return
+	}
+	if input.Login != "" && input.Login != claims.Name {
+		if claims.Profile != common.PYDIO_PROFILE_ADMIN {
+			service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "only admins can reset the second factor of another user"))
+			return
+		}
+	} else {
+		input.Login = claims.Name
+		if e := commonauth.VerifySecondFactor(ctx, claims.Name, input.Code); e == commonauth.ErrInvalidSecondFactor || e == commonauth.ErrSecondFactorNotEnrolled {
+			service.RestError400(req, resp, errors.BadRequest(common.SERVICE_AUTH, "%s", e.Error()))
+			return
+		} else if e == commonauth.ErrSecondFactorLocked {
+			service.RestError403(req, resp, errors.Forbidden(common.SERVICE_AUTH, "%s", e.Error()))
+			return
+		} else if e != nil {
+			service.RestError500(req, resp, e)
+			return
+		}
+	}
+	if e := commonauth.DisableSecondFactor(ctx, input.Login); e != nil {
+		service.RestError500(req, resp, e)
+		return
+	}
+
+	resp.WriteEntity(&rest.RevokeResponse{Success: true, Message: "Second authentication factor successfully removed"})
+
+}
+
+func appPasswordFromKey(key *encryption.Key) *rest.AppPassword {
+	return &rest.AppPassword{
+		Id:           strings.TrimPrefix(key.ID, commonauth.AppPasswordKeyPrefix),
+		Label:        key.Label,
+		CreationDate: key.CreationDate,
+	}
+}
+
 type ResetToken struct {
 	UserLogin  string `json:"user_login"`
 	Expiration int32  `json:"expiration"`
@@ -30,6 +30,7 @@ import (
 // DAO is a protocol for user key storing
 type DAO interface {
 	SaveKey(key *encryption.Key) error
+	CreateKey(key *encryption.Key) error
 	GetKey(owner string, KeyID string) (*encryption.Key, error)
 	ListKeys(owner string) ([]*encryption.Key, error)
 	DeleteKey(owner string, keyID string) error
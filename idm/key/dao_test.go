@@ -132,3 +132,25 @@ func TestDAODelete(t *testing.T) {
 		convey.So(len(k), convey.ShouldEqual, 0)
 	})
 }
+
+func TestDAOCreate(t *testing.T) {
+	convey.Convey("Test CREATE key only once", t, func() {
+		dao := GetDAO(t).(*sqlimpl)
+		k := &encryption.Key{
+			Owner:   "pydio",
+			ID:      "created",
+			Label:   "Created",
+			Content: base64.StdEncoding.EncodeToString([]byte("first")),
+		}
+		convey.So(dao.CreateKey(k), convey.ShouldBeNil)
+
+		k.Content = base64.StdEncoding.EncodeToString([]byte("second"))
+		convey.So(dao.CreateKey(k), convey.ShouldNotBeNil)
+
+		stored, err := dao.GetKey("pydio", "created")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(stored.Content, convey.ShouldEqual, base64.StdEncoding.EncodeToString([]byte("first")))
+
+		convey.So(dao.DeleteKey("pydio", "created"), convey.ShouldBeNil)
+	})
+}
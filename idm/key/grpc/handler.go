@@ -70,6 +70,14 @@ func (ukm *userKeyStore) AddKey(ctx context.Context, req *enc.AddKeyRequest, rsp
 		return err
 	}
 
+	if req.CreateOnly {
+		if err := dao.CreateKey(req.Key); err != nil {
+			return errors.Conflict(common.SERVICE_USER_KEY, "key %s already exists for %s", req.Key.ID, req.Key.Owner)
+		}
+		rsp.Success = true
+		return nil
+	}
+
 	return dao.SaveKey(req.Key)
 }
 
@@ -91,6 +99,53 @@ func (ukm *userKeyStore) GetKey(ctx context.Context, req *enc.GetKeyRequest, rsp
 	return open(rsp.Key, []byte(req.StrPassword))
 }
 
+// checkOwner verifies that a user request is performed by the keys owner or by an admin.
+// Requests without claims are internal calls (scheduler, authentication) and are allowed.
+func checkOwner(ctx context.Context, owner string) error {
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok {
+		return nil
+	}
+	if claims.Profile == common.PYDIO_PROFILE_ADMIN || claims.Name == common.PYDIO_SYSTEM_USERNAME {
+		return nil
+	}
+	if claims.Name == "" || claims.Name != owner {
+		return errors.Forbidden(common.SERVICE_USER_KEY, "cannot access keys of another user")
+	}
+	return nil
+}
+
+// ListUserKeys lists the keys of a given owner. Contents are returned sealed.
+func (ukm *userKeyStore) ListUserKeys(ctx context.Context, req *enc.ListUserKeysRequest, rsp *enc.ListUserKeysResponse) error {
+	if err := checkOwner(ctx, req.Owner); err != nil {
+		return err
+	}
+	dao, err := ukm.getDAO(ctx)
+	if err != nil {
+		return err
+	}
+
+	rsp.Keys, err = dao.ListKeys(req.Owner)
+	return err
+}
+
+// DeleteUserKey removes a key of a given owner.
+func (ukm *userKeyStore) DeleteUserKey(ctx context.Context, req *enc.DeleteUserKeyRequest, rsp *enc.DeleteUserKeyResponse) error {
+	if err := checkOwner(ctx, req.Owner); err != nil {
+		return err
+	}
+	dao, err := ukm.getDAO(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := dao.DeleteKey(req.Owner, req.KeyID); err != nil {
+		return err
+	}
+	rsp.Success = true
+	return nil
+}
+
 func (ukm *userKeyStore) AdminListKeys(ctx context.Context, req *enc.AdminListKeysRequest, rsp *enc.AdminListKeysResponse) error {
 
 	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
@@ -20,6 +20,31 @@
 
 package grpc
 
-import _ "github.com/smartystreets/goconvey/convey"
+import (
+	"context"
+	"testing"
 
-// TODO add tests or remove this file.
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+)
+
+func TestCheckOwner(t *testing.T) {
+
+	Convey("Users can only access their own keys", t, func() {
+		userCtx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{Name: "user", Profile: common.PYDIO_PROFILE_STANDARD})
+		So(checkOwner(userCtx, "user"), ShouldBeNil)
+		So(checkOwner(userCtx, "other"), ShouldNotBeNil)
+		So(checkOwner(userCtx, common.PYDIO_SYSTEM_USERNAME), ShouldNotBeNil)
+	})
+
+	Convey("Admins and internal calls can access all keys", t, func() {
+		adminCtx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{Name: "admin", Profile: common.PYDIO_PROFILE_ADMIN})
+		So(checkOwner(adminCtx, "user"), ShouldBeNil)
+		systemCtx := context.WithValue(context.Background(), claim.ContextKey, claim.Claims{Name: common.PYDIO_SYSTEM_USERNAME})
+		So(checkOwner(systemCtx, "user"), ShouldBeNil)
+		So(checkOwner(context.Background(), common.PYDIO_SYSTEM_USERNAME), ShouldBeNil)
+	})
+
+}
@@ -42,6 +42,11 @@ func init() {
 				return err
 			}
 			encryption.RegisterUserKeyStoreHandler(m.Options().Server, h)
+
+			// Renew user secrets on password changes
+			if err := m.Options().Server.Subscribe(m.Options().Server.NewSubscriber(common.TOPIC_IDM_EVENT, &UserSecretRenewer{})); err != nil {
+				return err
+			}
 			return nil
 		}),
 	)
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package grpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/crypto"
+	"github.com/pydio/cells/common/log"
+	enc "github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/idm/key"
+)
+
+// UserSecretRenewer listens to password changes to seal the user keys again with the secret derived from the new password.
+type UserSecretRenewer struct{}
+
+// Handle renews the user secret when the event carries a password change.
+func (r *UserSecretRenewer) Handle(ctx context.Context, msg *idm.ChangeEvent) error {
+	if msg.Type != idm.ChangeEventType_UPDATE || msg.User == nil || msg.Attributes[common.IDM_EVENT_ATTRIBUTE_PASSWORD_CHANGED] == "" {
+		return nil
+	}
+	dao, ok := servicecontext.GetDAO(ctx).(key.DAO)
+	if !ok {
+		return nil
+	}
+	master, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return err
+	}
+	if err := renewUserSecret(dao, msg.User.Login, master); err != nil {
+		log.Logger(ctx).Error("cannot renew user secret after password change", zap.String("user", msg.User.Login), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// renewUserSecret replaces the secret of @user, protected by the @master password, by the latest pending secret
+// stored by key.StoreUserSecret, and seals again all the keys protected by the previous ones.
+// Pending secrets are only deleted once all keys are sealed again, so that an interrupted renewal is resumed
+// by the next one without losing any key.
+func renewUserSecret(dao key.DAO, user string, master []byte) error {
+
+	keys, err := dao.ListKeys(user)
+	if err != nil {
+		return err
+	}
+	var pending []*enc.Key
+	for _, k := range keys {
+		if strings.HasPrefix(k.ID, key.PendingUserSecretPrefix) {
+			if err := open(k, master); err != nil {
+				return err
+			}
+			pending = append(pending, k)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].CreationDate != pending[j].CreationDate {
+			return pending[i].CreationDate < pending[j].CreationDate
+		}
+		return pending[i].ID < pending[j].ID
+	})
+	next := pending[len(pending)-1]
+	previous := append([]*enc.Key{}, pending[:len(pending)-1]...)
+
+	secret, err := dao.GetKey(user, key.UserSecretKeyID)
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		if err := open(secret, master); err != nil {
+			return err
+		}
+		previous = append(previous, secret)
+
+		for _, k := range keys {
+			if !strings.HasSuffix(k.ID, key.SecretProtectedSuffix) {
+				continue
+			}
+			if opened(k, []byte(next.Content)) {
+				// Already sealed with the new secret by an interrupted renewal
+				continue
+			}
+			for _, p := range previous {
+				if err := open(k, []byte(p.Content)); err == nil {
+					if err := seal(k, []byte(next.Content)); err != nil {
+						return err
+					}
+					if err := dao.SaveKey(k); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+	} else {
+		secret = &enc.Key{Owner: user, ID: key.UserSecretKeyID, Label: next.Label}
+	}
+
+	secret.Content = next.Content
+	secret.CreationDate = next.CreationDate
+	if err := seal(secret, master); err != nil {
+		return err
+	}
+	if err := dao.SaveKey(secret); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if err := dao.DeleteKey(user, p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// opened checks whether @k can be opened with @password, without modifying it.
+func opened(k *enc.Key, password []byte) bool {
+	c := *k
+	return open(&c, password) == nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package grpc
+
+import (
+	"encoding/base64"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/sql"
+	"github.com/pydio/cells/idm/key"
+)
+
+func TestRenewUserSecret(t *testing.T) {
+
+	Convey("Renewing a user secret seals the user keys again with the secret derived from the password", t, func() {
+
+		d := key.NewDAO(sql.NewDAO("sqlite3", "file::memory:?mode=memory&cache=shared", "idm_key_secret_test"))
+		So(d.Init(config.Map{}), ShouldBeNil)
+		dao := d.(key.DAO)
+		master := []byte("master-password")
+
+		pendingKey := func(id string, password string, date int32) []byte {
+			derived, err := key.DeriveUserSecret(password, []byte("salt-"+id))
+			So(err, ShouldBeNil)
+			k := &encryption.Key{Owner: "user", ID: key.PendingUserSecretPrefix + id, Label: "User secret", Content: base64.StdEncoding.EncodeToString(derived), CreationDate: date}
+			content := []byte(k.Content)
+			So(seal(k, master), ShouldBeNil)
+			So(dao.SaveKey(k), ShouldBeNil)
+			return content
+		}
+
+		// First password: the pending secret becomes the user secret
+		firstSecret := pendingKey("1", "first-password", 1)
+		So(renewUserSecret(dao, "user", master), ShouldBeNil)
+		secret, err := dao.GetKey("user", key.UserSecretKeyID)
+		So(err, ShouldBeNil)
+		So(open(secret, master), ShouldBeNil)
+		So(secret.Content, ShouldEqual, string(firstSecret))
+
+		plain := base64.StdEncoding.EncodeToString([]byte("personal-key-content"))
+		userKey := &encryption.Key{Owner: "user", ID: "ds-key" + key.SecretProtectedSuffix, Label: "Key", Content: plain}
+		So(seal(userKey, firstSecret), ShouldBeNil)
+		So(dao.SaveKey(userKey), ShouldBeNil)
+
+		// Two password changes before the renewal: the latest secret is used
+		pendingKey("2", "second-password", 2)
+		thirdSecret := pendingKey("3", "third-password", 3)
+		So(renewUserSecret(dao, "user", master), ShouldBeNil)
+
+		renewed, err := dao.GetKey("user", key.UserSecretKeyID)
+		So(err, ShouldBeNil)
+		So(open(renewed, master), ShouldBeNil)
+		So(renewed.Content, ShouldEqual, string(thirdSecret))
+
+		stored, err := dao.GetKey("user", "ds-key"+key.SecretProtectedSuffix)
+		So(err, ShouldBeNil)
+		So(opened(stored, firstSecret), ShouldBeFalse)
+		So(open(stored, thirdSecret), ShouldBeNil)
+		So(stored.Content, ShouldEqual, plain)
+
+		keys, err := dao.ListKeys("user")
+		So(err, ShouldBeNil)
+		So(keys, ShouldHaveLength, 2)
+	})
+}
@@ -96,6 +96,22 @@ func (dao *sqlimpl) SaveKey(key *encryption.Key) error {
 	return err
 }
 
+// CreateKey only inserts a new key: it fails if a key already exists for this owner and ID.
+func (dao *sqlimpl) CreateKey(key *encryption.Key) error {
+	var bytes = []byte{}
+	var err error
+
+	if key.Info != nil {
+		bytes, err = proto.Marshal(key.Info)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = dao.GetStmt("insert").Exec(key.Owner, key.ID, key.Label, key.Content, key.CreationDate, bytes)
+	return err
+}
+
 func (dao *sqlimpl) GetKey(owner string, KeyID string) (*encryption.Key, error) {
 	getStmt := dao.GetStmt("get")
 	rows, err := getStmt.Query(owner, KeyID)
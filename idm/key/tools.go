@@ -23,7 +23,12 @@ package key
 import (
 	"context"
 	"encoding/base64"
+	"strconv"
 	"sync"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"golang.org/x/crypto/scrypt"
 
 	"github.com/pydio/cells/common"
 	"github.com/pydio/cells/common/proto/encryption"
@@ -53,19 +58,197 @@ func MasterKeyTool(ctx context.Context) (UserKeyTool, error) {
 	return kt, err
 }
 
+// CreateMasterKey generates a new system key with ID @keyID, protected by the master password.
+// It does nothing if the key already exists.
+func CreateMasterKey(ctx context.Context, keyID string, label string) error {
+	bytes, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return err
+	}
+
+	client := encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+	rsp, err := client.GetKey(ctx, &encryption.GetKeyRequest{Owner: common.PYDIO_SYSTEM_USERNAME, KeyID: keyID, StrPassword: string(bytes)})
+	if err != nil {
+		return err
+	}
+	if rsp.Key != nil {
+		return nil
+	}
+
+	kt := &userKeyTool{user: common.PYDIO_SYSTEM_USERNAME, password: bytes, keys: make(map[string][]byte)}
+	_, err = kt.createKey(ctx, client, keyID, label)
+	return err
+}
+
+// FallbackKeyTool wraps a keytool to encrypt with the requested key, but try each of the @fallbackIDs keys
+// when decryption fails. It is used while data is being migrated from one key to another.
+func FallbackKeyTool(tool UserKeyTool, fallbackIDs ...string) UserKeyTool {
+	return &fallbackKeyTool{UserKeyTool: tool, fallbackIDs: fallbackIDs}
+}
+
+type fallbackKeyTool struct {
+	UserKeyTool
+	fallbackIDs []string
+}
+
+func (f *fallbackKeyTool) GetDecrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	plain, err := f.UserKeyTool.GetDecrypted(ctx, keyID, data)
+	if err == nil {
+		return plain, nil
+	}
+	for _, id := range f.fallbackIDs {
+		if id == keyID {
+			continue
+		}
+		if p, e := f.UserKeyTool.GetDecrypted(ctx, id, data); e == nil {
+			return p, nil
+		}
+	}
+	return nil, err
+}
+
 // GetUserKeyTool creates a keytool based on specified @user and @pass
 func GetUserKeyTool(user string, pass []byte) UserKeyTool {
 	return &userKeyTool{
 		password: pass,
 		user:     user,
+		keys:     make(map[string][]byte),
+	}
+}
+
+// ManagedUserKeyTool creates a keytool for @user whose keys are protected by the master password.
+// Keys are generated in the user key store on first use.
+func ManagedUserKeyTool(ctx context.Context, user string) (UserKeyTool, error) {
+	bytes, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return nil, err
+	}
+	return &userKeyTool{
+		password:        bytes,
+		user:            user,
+		keys:            make(map[string][]byte),
+		createIfMissing: true,
+	}, nil
+}
+
+// SecretProtectedSuffix ends the ID of the user keys that are protected by the user secret.
+const SecretProtectedSuffix = ".pwd"
+
+// UserSecretKeyID is the ID of the key holding the server-side secret of a user. It is protected by the
+// master password and is used in turn to protect the user keys of password-protected datasources.
+const UserSecretKeyID = "user-secret"
+
+// PendingUserSecretPrefix starts the IDs of the secrets derived from a new user password, that are not yet
+// used to protect the user keys.
+const PendingUserSecretPrefix = UserSecretKeyID + ".next."
+
+// DeriveUserSecret derives a user secret from the user @password with scrypt.
+func DeriveUserSecret(password string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(password), salt, 32768, 8, 1, 32)
+}
+
+// StoreUserSecret derives a new secret from the @password of @user and stores it as pending, protected by
+// the master password. The key service then seals the user keys again with it and makes it the user secret.
+func StoreUserSecret(ctx context.Context, user string, password string) error {
+	master, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return err
+	}
+	if len(master) == 0 {
+		return errors.InternalServerError(common.SERVICE_USER_KEY, "cannot find master password to protect the secret of %s", user)
+	}
+	salt, err := crypto.RandomBytes(16)
+	if err != nil {
+		return err
+	}
+	secret, err := DeriveUserSecret(password, salt)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	client := encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+	_, err = client.AddKey(ctx, &encryption.AddKeyRequest{
+		StrPassword: string(master),
+		CreateOnly:  true,
+		Key: &encryption.Key{
+			Owner:        user,
+			ID:           PendingUserSecretPrefix + strconv.FormatInt(now.UnixNano(), 10),
+			Label:        "User secret",
+			Content:      base64.StdEncoding.EncodeToString(secret),
+			CreationDate: int32(now.Unix()),
+		},
+	})
+	return err
+}
+
+// StoreManagedSecret stores @secret as the key @keyID of @owner in the user key store, protected by the
+// master password, so that other services only keep a reference to it.
+func StoreManagedSecret(ctx context.Context, owner string, keyID string, label string, secret []byte) error {
+	master, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return err
+	}
+	if len(master) == 0 {
+		return errors.InternalServerError(common.SERVICE_USER_KEY, "cannot find master password to protect secret %s", keyID)
+	}
+	client := encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+	_, err = client.AddKey(ctx, &encryption.AddKeyRequest{
+		StrPassword: string(master),
+		Key: &encryption.Key{
+			Owner:        owner,
+			ID:           keyID,
+			Label:        label,
+			Content:      base64.StdEncoding.EncodeToString(secret),
+			CreationDate: int32(time.Now().Unix()),
+		},
+	})
+	return err
+}
+
+// ManagedSecret loads a secret stored with StoreManagedSecret.
+func ManagedSecret(ctx context.Context, owner string, keyID string) ([]byte, error) {
+	master, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return nil, err
+	}
+	tool := &userKeyTool{user: owner, password: master, keys: make(map[string][]byte)}
+	return tool.keyByID(ctx, keyID)
+}
+
+// SecretUserKeyTool creates a keytool for @user whose keys are protected by the user secret. The secret is
+// derived from the user password each time it is set, and held by the server, so that keys can be opened
+// whatever the way the user is authenticated, and shared with other users. Keys are sealed again with the
+// new secret on password change. The keys, and the secret of users who did not set their password since,
+// are generated in the user key store on first use.
+func SecretUserKeyTool(ctx context.Context, user string) (UserKeyTool, error) {
+	master, err := crypto.GetKeyringPassword(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, common.KEYRING_MASTER_KEY, false)
+	if err != nil {
+		return nil, err
+	}
+	secretTool := &userKeyTool{
+		password:        master,
+		user:            user,
+		keys:            make(map[string][]byte),
+		createIfMissing: true,
+	}
+	secret, err := secretTool.keyByID(ctx, UserSecretKeyID)
+	if err != nil {
+		return nil, err
 	}
+	return &userKeyTool{
+		password:        []byte(base64.StdEncoding.EncodeToString(secret)),
+		user:            user,
+		keys:            make(map[string][]byte),
+		createIfMissing: true,
+	}, nil
 }
 
 type userKeyTool struct {
 	sync.Mutex
-	user     string
-	password []byte
-	keys     map[string][]byte
+	user            string
+	password        []byte
+	keys            map[string][]byte
+	createIfMissing bool
 }
 
 func (kt *userKeyTool) keyByID(ctx context.Context, id string) ([]byte, error) {
@@ -79,6 +262,13 @@ func (kt *userKeyTool) keyByID(ctx context.Context, id string) ([]byte, error) {
 		return nil, err
 	}
 
+	if rsp.Key == nil {
+		if !kt.createIfMissing {
+			return nil, errors.NotFound(common.SERVICE_USER_KEY, "cannot find key %s for user %s", id, kt.user)
+		}
+		return kt.createKey(ctx, client, id, id)
+	}
+
 	bytes, err := base64.StdEncoding.DecodeString(rsp.Key.Content)
 	if err != nil {
 		return nil, err
@@ -89,6 +279,38 @@ func (kt *userKeyTool) keyByID(ctx context.Context, id string) ([]byte, error) {
 	return bytes, nil
 }
 
+func (kt *userKeyTool) createKey(ctx context.Context, client encryption.UserKeyStoreClient, id string, label string) ([]byte, error) {
+	bytes, err := crypto.RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.AddKey(ctx, &encryption.AddKeyRequest{
+		StrPassword: string(kt.password),
+		CreateOnly:  true,
+		Key: &encryption.Key{
+			Owner:        kt.user,
+			ID:           id,
+			Label:        label,
+			Content:      base64.StdEncoding.EncodeToString(bytes),
+			CreationDate: int32(time.Now().Unix()),
+		},
+	})
+	if err != nil {
+		// The key may have been created concurrently by another request: use that one instead
+		rsp, gErr := client.GetKey(ctx, &encryption.GetKeyRequest{Owner: kt.user, KeyID: id, StrPassword: string(kt.password)})
+		if gErr != nil || rsp.Key == nil {
+			return nil, err
+		}
+		if bytes, err = base64.StdEncoding.DecodeString(rsp.Key.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	kt.keys[id] = bytes
+	return bytes, nil
+}
+
 func (kt *userKeyTool) GetEncrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
 	kt.Lock()
 	defer kt.Unlock()
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package key
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type prefixKeyTool struct{}
+
+func (k *prefixKeyTool) GetEncrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	return append([]byte(keyID+":"), data...), nil
+}
+
+func (k *prefixKeyTool) GetDecrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(keyID+":")) {
+		return nil, fmt.Errorf("wrong key")
+	}
+	return data[len(keyID)+1:], nil
+}
+
+func TestFallbackKeyTool(t *testing.T) {
+
+	Convey("Test fallback key tool", t, func() {
+		ctx := context.Background()
+		tool := FallbackKeyTool(&prefixKeyTool{}, "old-key")
+
+		sealed, err := tool.GetEncrypted(ctx, "new-key", []byte("data"))
+		So(err, ShouldBeNil)
+		So(string(sealed), ShouldEqual, "new-key:data")
+
+		plain, err := tool.GetDecrypted(ctx, "new-key", []byte("old-key:data"))
+		So(err, ShouldBeNil)
+		So(string(plain), ShouldEqual, "data")
+
+		_, err = tool.GetDecrypted(ctx, "new-key", []byte("other-key:data"))
+		So(err, ShouldNotBeNil)
+	})
+}
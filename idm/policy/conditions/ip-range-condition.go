@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package conditions
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/ory/ladon"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common/log"
+)
+
+// IPRangeCondition is a condition which is fulfilled if the remote address belongs to one of
+// the comma separated IP ranges defined by the Matches string, for instance "192.168.0.0/16, 10.0.0.1"
+type IPRangeCondition struct {
+	Matches string `json:"matches"`
+}
+
+// Fulfills returns true if the given value is an IP address, with or without a port, within one of the defined ranges.
+// The value is expected to be the client address resolved from the trusted proxies (see servicecontext.ClientAddress).
+// If it still is a forwarding chain, only the last address is used, as the previous ones can be forged by the client.
+func (c *IPRangeCondition) Fulfills(value interface{}, _ *ladon.Request) bool {
+
+	s, ok := value.(string)
+	if !ok {
+		log.Logger(context.Background()).Error("passed value must be a string", zap.Any("input param", value))
+		return false
+	}
+
+	ip := parseRemoteAddress(s)
+	if ip == nil {
+		log.Logger(context.Background()).Debug("cannot parse passed value as an IP address", zap.String("input param", s))
+		return false
+	}
+
+	for _, r := range strings.Split(c.Matches, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if !strings.Contains(r, "/") {
+			if single := net.ParseIP(r); single != nil && single.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			log.Logger(context.Background()).Error("cannot parse IP range", zap.String("Matches predicate", c.Matches), zap.Error(err))
+			continue
+		}
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetName returns the condition's name.
+func (c *IPRangeCondition) GetName() string {
+	return "IPRangeCondition"
+}
+
+// parseRemoteAddress extracts the last IP of a list like "client, proxy1, proxy2", stripping the port if any.
+func parseRemoteAddress(s string) net.IP {
+	hops := strings.Split(s, ",")
+	s = strings.TrimSpace(hops[len(hops)-1])
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
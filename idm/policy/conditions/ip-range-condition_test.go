@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pydio/cells/common/service/context"
+)
+
+func TestIPRangeCondition(t *testing.T) {
+
+	Convey("Canonical IP range tests", t, func() {
+
+		for _, c := range []struct {
+			matches string
+			value   interface{}
+			pass    bool
+		}{
+			{matches: "192.168.0.0/16", value: "192.168.1.12", pass: true},
+			{matches: "192.168.0.0/16", value: "192.168.1.12:54321", pass: true},
+			{matches: "192.168.0.0/16", value: "192.169.1.12", pass: false},
+			{matches: "10.0.0.0/8, 192.168.0.0/16", value: "192.168.1.12", pass: true},
+			{matches: "10.0.0.1", value: "10.0.0.1", pass: true},
+			{matches: "10.0.0.1", value: "10.0.0.2", pass: false},
+			{matches: "10.0.0.0/8", value: "172.16.0.1, 10.1.2.3", pass: true},
+			{matches: "10.0.0.0/8", value: "10.1.2.3, 172.16.0.1", pass: false},
+			{matches: "2001:db8::/32", value: "[2001:db8::1]:8080", pass: true},
+			{matches: "10.0.0.0/8", value: "not-an-ip", pass: false},
+			{matches: "10.0.0.0/8", value: 12, pass: false},
+			{matches: "invalid", value: "10.0.0.1", pass: false},
+		} {
+			condition := &IPRangeCondition{
+				Matches: c.matches,
+			}
+			So(condition.Fulfills(c.value, new(ladon.Request)), ShouldEqual, c.pass)
+		}
+	})
+}
+
+func TestIPRangePolicy(t *testing.T) {
+
+	Convey("Test office network or second factor", t, func() {
+
+		warden := &ladon.Ladon{Manager: memory.NewMemoryManager()}
+		require.Nil(t, warden.Manager.Create(&ladon.DefaultPolicy{
+			ID:        "office-network",
+			Subjects:  []string{"max"},
+			Resources: []string{"resource1"},
+			Actions:   []string{"download"},
+			Effect:    ladon.AllowAccess,
+			Conditions: ladon.Conditions{
+				servicecontext.HttpMetaRemoteAddress: &IPRangeCondition{Matches: "192.168.0.0/16"},
+			},
+		}))
+		require.Nil(t, warden.Manager.Create(&ladon.DefaultPolicy{
+			ID:        "second-factor",
+			Subjects:  []string{"max"},
+			Resources: []string{"resource1"},
+			Actions:   []string{"download"},
+			Effect:    ladon.AllowAccess,
+			Conditions: ladon.Conditions{
+				"JwtSecondFactor": &SecondFactorCondition{Value: true},
+			},
+		}))
+
+		request := func(ip string, mfa string) *ladon.Request {
+			return &ladon.Request{
+				Subject:  "max",
+				Resource: "resource1",
+				Action:   "download",
+				Context: ladon.Context{
+					servicecontext.HttpMetaRemoteAddress: ip,
+					"JwtSecondFactor":                    mfa,
+				},
+			}
+		}
+
+		So(warden.IsAllowed(request("192.168.0.10", "false")), ShouldBeNil)
+		So(warden.IsAllowed(request("82.10.2.3", "true")), ShouldBeNil)
+		So(warden.IsAllowed(request("82.10.2.3", "false")), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package conditions
+
+import (
+	"strconv"
+
+	"github.com/ory/ladon"
+)
+
+// SecondFactorCondition is a condition which is fulfilled if the user authentication state
+// (with or without a second factor) equals the expected Value. It is evaluated against the
+// JwtSecondFactor key of the request context, computed from the authentication methods of the JWT.
+type SecondFactorCondition struct {
+	Value bool `json:"value"`
+}
+
+// Fulfills returns true if the given value is a boolean or a string representation of a boolean
+// equal to the expected value. A missing value is considered as false.
+func (c *SecondFactorCondition) Fulfills(value interface{}, _ *ladon.Request) bool {
+	var has bool
+	switch v := value.(type) {
+	case bool:
+		has = v
+	case string:
+		has, _ = strconv.ParseBool(v)
+	}
+	return has == c.Value
+}
+
+// GetName returns the condition's name.
+func (c *SecondFactorCondition) GetName() string {
+	return "SecondFactorCondition"
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package conditions
+
+import (
+	"strings"
+
+	"github.com/ory/ladon"
+)
+
+// UserRolesCondition is a condition which is fulfilled if the user has at least one of the
+// comma separated role UUIDs defined by the Matches string. It is evaluated against the
+// UserRoles key of the request context.
+type UserRolesCondition struct {
+	Matches string `json:"matches"`
+}
+
+// Fulfills returns true if the given value is a comma separated list of roles containing
+// one of the expected roles.
+func (c *UserRolesCondition) Fulfills(value interface{}, _ *ladon.Request) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return intersects(splitList(c.Matches), splitList(s), false)
+}
+
+// GetName returns the condition's name.
+func (c *UserRolesCondition) GetName() string {
+	return "UserRolesCondition"
+}
+
+// UserAttributeCondition is a condition which is fulfilled if the value of a user attribute
+// equals (case-insensitively) one of the comma separated values defined by the Matches string.
+// It is evaluated against the "UserAttribute:<name>" keys of the request context, e.g. "UserAttribute:department".
+type UserAttributeCondition struct {
+	Matches string `json:"matches"`
+}
+
+// Fulfills returns true if the given value is a string equal to one of the expected values.
+func (c *UserAttributeCondition) Fulfills(value interface{}, _ *ladon.Request) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	return intersects(splitList(c.Matches), []string{strings.TrimSpace(s)}, true)
+}
+
+// GetName returns the condition's name.
+func (c *UserAttributeCondition) GetName() string {
+	return "UserAttributeCondition"
+}
+
+func splitList(s string) (values []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return
+}
+
+func intersects(expected []string, values []string, ignoreCase bool) bool {
+	for _, e := range expected {
+		for _, v := range values {
+			if e == v || (ignoreCase && strings.EqualFold(e, v)) {
+				return true
+			}
+		}
+	}
+	return false
+}
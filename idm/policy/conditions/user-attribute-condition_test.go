@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/ory/ladon"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUserAttributeConditions(t *testing.T) {
+
+	Convey("Canonical user roles tests", t, func() {
+
+		for _, c := range []struct {
+			matches string
+			value   interface{}
+			pass    bool
+		}{
+			{matches: "ADMINS", value: "ROOT_GROUP,ADMINS,user-uuid", pass: true},
+			{matches: "ADMINS, EDITORS", value: "ROOT_GROUP,EDITORS", pass: true},
+			{matches: "ADMINS", value: "ROOT_GROUP,admins", pass: false},
+			{matches: "ADMINS", value: "", pass: false},
+			{matches: "ADMINS", value: nil, pass: false},
+		} {
+			condition := &UserRolesCondition{
+				Matches: c.matches,
+			}
+			So(condition.Fulfills(c.value, new(ladon.Request)), ShouldEqual, c.pass)
+		}
+	})
+
+	Convey("Canonical user attribute tests", t, func() {
+
+		for _, c := range []struct {
+			matches string
+			value   interface{}
+			pass    bool
+		}{
+			{matches: "sales", value: "Sales", pass: true},
+			{matches: "sales, marketing", value: "marketing", pass: true},
+			{matches: "sales", value: "engineering", pass: false},
+			{matches: "sales", value: nil, pass: false},
+		} {
+			condition := &UserAttributeCondition{
+				Matches: c.matches,
+			}
+			So(condition.Fulfills(c.value, new(ladon.Request)), ShouldEqual, c.pass)
+		}
+	})
+
+	Convey("Canonical second factor tests", t, func() {
+
+		So((&SecondFactorCondition{Value: true}).Fulfills("true", new(ladon.Request)), ShouldBeTrue)
+		So((&SecondFactorCondition{Value: true}).Fulfills(true, new(ladon.Request)), ShouldBeTrue)
+		So((&SecondFactorCondition{Value: true}).Fulfills("false", new(ladon.Request)), ShouldBeFalse)
+		So((&SecondFactorCondition{Value: true}).Fulfills(nil, new(ladon.Request)), ShouldBeFalse)
+		So((&SecondFactorCondition{Value: false}).Fulfills(nil, new(ladon.Request)), ShouldBeTrue)
+	})
+}
@@ -156,6 +156,12 @@ var (
 						"rest:/activity<.+>",
 						"rest:/changes",
 						"rest:/changes<.+>",
+						"rest:/auth/token/app-passwords",
+						"rest:/auth/token/app-passwords<.+>",
+						"rest:/quota/usage",
+						"rest:/auth/token/second-factor",
+						"rest:/auth/token/second-factor<.+>",
+						"rest:/versions<.+>",
 					},
 					Actions: []string{"GET", "POST", "DELETE", "PUT", "PATCH"},
 					Effect:  ladon.AllowAccess,
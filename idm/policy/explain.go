@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ory/ladon"
+
+	"github.com/pydio/cells/common/proto/idm"
+)
+
+// Explain evaluates the request against the policies of the passed groups, the same way the policy
+// engine does, and details which policies matched the request and how each of their conditions evaluated.
+// If dryRun is not nil, it replaces the group with the same Uuid (or is added to the list).
+func Explain(groups []*idm.PolicyGroup, request *idm.PolicyEngineRequest, dryRun *idm.PolicyGroup) (*idm.PolicyExplainResponse, error) {
+
+	groups = append([]*idm.PolicyGroup{}, groups...)
+	if dryRun != nil {
+		var replaced bool
+		for i, g := range groups {
+			if g.Uuid == dryRun.Uuid {
+				groups[i] = dryRun
+				replaced = true
+			}
+		}
+		if !replaced {
+			groups = append(groups, dryRun)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Uuid < groups[j].Uuid
+	})
+
+	ladonContext := ladon.Context{}
+	for k, v := range request.Context {
+		ladonContext[k] = v
+	}
+
+	response := &idm.PolicyExplainResponse{
+		Decision: &idm.PolicyEngineResponse{},
+		Context:  request.Context,
+	}
+
+	for _, subject := range request.Subjects {
+		ladonRequest := &ladon.Request{
+			Subject:  subject,
+			Resource: request.Resource,
+			Action:   request.Action,
+			Context:  ladonContext,
+		}
+		for _, group := range groups {
+			for _, p := range group.Policies {
+				lp := ProtoToLadonPolicy(p)
+				if match, err := policyMatches(lp, ladonRequest); err != nil {
+					return nil, err
+				} else if !match {
+					continue
+				}
+				explanation := &idm.PolicyExplanation{
+					GroupUuid: group.Uuid,
+					GroupName: group.Name,
+					Subject:   subject,
+					Policy:    p,
+					Applied:   true,
+				}
+				var keys []string
+				for key := range lp.GetConditions() {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					condition := lp.GetConditions()[key]
+					value := ladonRequest.Context[key]
+					fulfilled := condition.Fulfills(value, ladonRequest)
+					evaluation := &idm.PolicyConditionEvaluation{
+						Key:       key,
+						Type:      condition.GetName(),
+						Fulfilled: fulfilled,
+					}
+					if value != nil {
+						evaluation.Value = fmt.Sprintf("%v", value)
+					}
+					explanation.Conditions = append(explanation.Conditions, evaluation)
+					if !fulfilled {
+						explanation.Applied = false
+					}
+				}
+				if explanation.Applied {
+					if lp.AllowAccess() {
+						response.Decision.Allowed = true
+					} else {
+						response.Decision.ExplicitDeny = true
+					}
+				}
+				response.Explanations = append(response.Explanations, explanation)
+			}
+		}
+	}
+
+	if response.Decision.ExplicitDeny {
+		response.Decision.Allowed = false
+	} else if !response.Decision.Allowed {
+		response.Decision.DefaultDeny = true
+	}
+
+	return response, nil
+}
+
+// policyMatches checks the actions, subjects and resources of the policy against the request.
+func policyMatches(p ladon.Policy, r *ladon.Request) (bool, error) {
+	for _, check := range []struct {
+		haystack []string
+		needle   string
+	}{
+		{p.GetActions(), r.Action},
+		{p.GetSubjects(), r.Subject},
+		{p.GetResources(), r.Resource},
+	} {
+		if match, err := ladon.DefaultMatcher.Matches(p, check.haystack, check.needle); err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
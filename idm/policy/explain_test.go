@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package policy
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/service/context"
+)
+
+func TestExplain(t *testing.T) {
+
+	groups := []*idm.PolicyGroup{
+		{
+			Uuid: "group-1",
+			Name: "Downloads",
+			Policies: []*idm.Policy{
+				{
+					Id:        "office-network",
+					Subjects:  []string{"profile:standard"},
+					Resources: []string{"rest:/download<.+>"},
+					Actions:   []string{"GET"},
+					Effect:    idm.PolicyEffect_allow,
+					Conditions: map[string]*idm.PolicyCondition{
+						servicecontext.HttpMetaRemoteAddress: {
+							Type:        "IPRangeCondition",
+							JsonOptions: "{\"matches\":\"192.168.0.0/16\"}",
+						},
+					},
+				},
+				{
+					Id:        "other-action",
+					Subjects:  []string{"profile:standard"},
+					Resources: []string{"rest:/download<.+>"},
+					Actions:   []string{"PUT"},
+					Effect:    idm.PolicyEffect_allow,
+				},
+			},
+		},
+	}
+
+	request := func(ip string) *idm.PolicyEngineRequest {
+		return &idm.PolicyEngineRequest{
+			Subjects: []string{"user:max", "profile:standard"},
+			Resource: "rest:/download/file",
+			Action:   "GET",
+			Context:  map[string]string{servicecontext.HttpMetaRemoteAddress: ip},
+		}
+	}
+
+	Convey("Test explain matching policies and conditions", t, func() {
+
+		resp, err := Explain(groups, request("192.168.1.1"), nil)
+		So(err, ShouldBeNil)
+		So(resp.Decision.Allowed, ShouldBeTrue)
+		So(resp.Explanations, ShouldHaveLength, 1)
+		So(resp.Explanations[0].GroupUuid, ShouldEqual, "group-1")
+		So(resp.Explanations[0].Subject, ShouldEqual, "profile:standard")
+		So(resp.Explanations[0].Policy.Id, ShouldEqual, "office-network")
+		So(resp.Explanations[0].Applied, ShouldBeTrue)
+		So(resp.Explanations[0].Conditions, ShouldHaveLength, 1)
+		So(resp.Explanations[0].Conditions[0].Type, ShouldEqual, "IPRangeCondition")
+		So(resp.Explanations[0].Conditions[0].Value, ShouldEqual, "192.168.1.1")
+		So(resp.Explanations[0].Conditions[0].Fulfilled, ShouldBeTrue)
+
+		resp, err = Explain(groups, request("82.10.2.3"), nil)
+		So(err, ShouldBeNil)
+		So(resp.Decision.Allowed, ShouldBeFalse)
+		So(resp.Decision.DefaultDeny, ShouldBeTrue)
+		So(resp.Explanations, ShouldHaveLength, 1)
+		So(resp.Explanations[0].Applied, ShouldBeFalse)
+		So(resp.Explanations[0].Conditions[0].Fulfilled, ShouldBeFalse)
+
+	})
+
+	Convey("Test explain with a dry-run group", t, func() {
+
+		dryRun := &idm.PolicyGroup{
+			Uuid: "group-2",
+			Name: "Deny max",
+			Policies: []*idm.Policy{
+				{
+					Id:        "deny-max",
+					Subjects:  []string{"user:max"},
+					Resources: []string{"rest:<.+>"},
+					Actions:   []string{"GET"},
+					Effect:    idm.PolicyEffect_deny,
+				},
+			},
+		}
+		resp, err := Explain(groups, request("192.168.1.1"), dryRun)
+		So(err, ShouldBeNil)
+		So(resp.Decision.Allowed, ShouldBeFalse)
+		So(resp.Decision.ExplicitDeny, ShouldBeTrue)
+		So(resp.Explanations, ShouldHaveLength, 2)
+		So(groups, ShouldHaveLength, 1)
+
+		// Replacing the existing group removes the allow rule
+		dryRun.Uuid = "group-1"
+		resp, err = Explain(groups, request("192.168.1.1"), dryRun)
+		So(err, ShouldBeNil)
+		So(resp.Decision.ExplicitDeny, ShouldBeTrue)
+		So(resp.Explanations, ShouldHaveLength, 1)
+		So(groups[0].Name, ShouldEqual, "Downloads")
+
+	})
+}
@@ -24,25 +24,65 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-micro/errors"
 	"github.com/ory/ladon"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
 
 	"github.com/pydio/cells/common"
 	"github.com/pydio/cells/common/log"
 	"github.com/pydio/cells/common/proto/idm"
 	"github.com/pydio/cells/common/service/context"
+	"github.com/pydio/cells/common/utils"
 	"github.com/pydio/cells/idm/policy"
 )
 
+var (
+	// usersCache keeps recently loaded users to avoid querying the user service on each request
+	usersCache = cache.New(time.Second*60, time.Second*120)
+	// loadUser is used to feed the request context with the user attributes
+	loadUser = func(ctx context.Context, login string) (*idm.User, error) {
+		return utils.SearchUniqueUser(ctx, login, "")
+	}
+)
+
 type Handler struct {
 }
 
+// enrichContextWithUser adds the attributes and roles of the user found in the request context, if any.
+func (h *Handler) enrichContextWithUser(ctx context.Context, reqContext map[string]string) {
+	login, ok := reqContext[utils.PolicyUserLogin]
+	if !ok || login == "" {
+		return
+	}
+	var user *idm.User
+	if u, found := usersCache.Get(login); found {
+		user = u.(*idm.User)
+	} else if u, err := loadUser(ctx, login); err == nil {
+		user = u
+		usersCache.Set(login, user, cache.DefaultExpiration)
+	} else {
+		log.Logger(ctx).Debug("Cannot load user for policy context", zap.String("login", login), zap.Error(err))
+		return
+	}
+	utils.PolicyContextFromUser(reqContext, user)
+}
+
 func (h *Handler) IsAllowed(ctx context.Context, request *idm.PolicyEngineRequest, response *idm.PolicyEngineResponse) error {
 
 	dao := servicecontext.GetDAO(ctx).(policy.DAO)
 
-	reqContext := make(map[string]interface{})
+	policyContext := make(map[string]string, len(request.Context))
 	for k, v := range request.Context {
+		policyContext[k] = v
+	}
+	h.enrichContextWithUser(ctx, policyContext)
+
+	reqContext := make(map[string]interface{})
+	for k, v := range policyContext {
 		reqContext[k] = v
 	}
 	var allowed bool
@@ -82,6 +122,33 @@ func (h *Handler) IsAllowed(ctx context.Context, request *idm.PolicyEngineReques
 	return nil
 }
 
+func (h *Handler) ExplainPolicy(ctx context.Context, request *idm.PolicyExplainRequest, response *idm.PolicyExplainResponse) error {
+
+	if request.Request == nil {
+		return errors.BadRequest(common.SERVICE_POLICY, "Please provide a request to explain")
+	}
+	dao := servicecontext.GetDAO(ctx).(policy.DAO)
+
+	groups, err := dao.ListPolicyGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	engineRequest := proto.Clone(request.Request).(*idm.PolicyEngineRequest)
+	if engineRequest.Context == nil {
+		engineRequest.Context = make(map[string]string)
+	}
+	h.enrichContextWithUser(ctx, engineRequest.Context)
+
+	explained, err := policy.Explain(groups, engineRequest, request.DryRunGroup)
+	if err != nil {
+		return err
+	}
+	*response = *explained
+
+	return nil
+}
+
 func (h *Handler) ListPolicyGroups(ctx context.Context, request *idm.ListPolicyGroupsRequest, response *idm.ListPolicyGroupsResponse) error {
 
 	dao := servicecontext.GetDAO(ctx).(policy.DAO)
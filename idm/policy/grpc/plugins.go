@@ -53,6 +53,22 @@ func init() {
 				TargetVersion: service.ValidVersion("1.0.1"),
 				Up:            Upgrade101,
 			},
+			{
+				TargetVersion: service.ValidVersion("1.0.2"),
+				Up:            Upgrade102,
+			},
+			{
+				TargetVersion: service.ValidVersion("1.0.3"),
+				Up:            Upgrade103,
+			},
+			{
+				TargetVersion: service.ValidVersion("1.0.4"),
+				Up:            Upgrade104,
+			},
+			{
+				TargetVersion: service.ValidVersion("1.0.5"),
+				Up:            Upgrade105,
+			},
 		}),
 		service.WithMicro(func(m micro.Service) error {
 			handler := new(Handler)
@@ -132,3 +148,72 @@ func Upgrade101(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Upgrade102 gives standard users access to their application passwords.
+func Upgrade102(ctx context.Context) error {
+	return addUserDefaultResources(ctx, "rest:/auth/token/app-passwords", "rest:/auth/token/app-passwords<.+>")
+}
+
+// Upgrade103 gives standard users access to their own storage usage.
+func Upgrade103(ctx context.Context) error {
+	return addUserDefaultResources(ctx, "rest:/quota/usage")
+}
+
+// Upgrade104 gives standard users access to their second authentication factor.
+func Upgrade104(ctx context.Context) error {
+	return addUserDefaultResources(ctx, "rest:/auth/token/second-factor", "rest:/auth/token/second-factor<.+>")
+}
+
+// Upgrade105 gives standard users access to the versions of their files.
+func Upgrade105(ctx context.Context) error {
+	return addUserDefaultResources(ctx, "rest:/versions<.+>")
+}
+
+// addUserDefaultResources appends resources to the user-default-policy, if they are not already there.
+func addUserDefaultResources(ctx context.Context, resources ...string) error {
+	dao := servicecontext.GetDAO(ctx).(policy.DAO)
+	if dao == nil {
+		return fmt.Errorf("cannot find DAO for policies initialization")
+	}
+	groups, e := dao.ListPolicyGroups(ctx)
+	if e != nil {
+		return e
+	}
+	for _, group := range groups {
+		if group.Uuid != "rest-apis-default-accesses" {
+			continue
+		}
+		var updated bool
+		for _, p := range group.Policies {
+			if p.Id != "user-default-policy" {
+				continue
+			}
+			for _, resource := range resources {
+				if !hasResource(p, resource) {
+					p.Resources = append(p.Resources, resource)
+					updated = true
+				}
+			}
+		}
+		if !updated {
+			continue
+		}
+		if _, er := dao.StorePolicyGroup(ctx, group); er != nil {
+			log.Logger(ctx).Error("Could not update policy group "+group.Uuid, zap.Error(er))
+		} else {
+			log.Logger(ctx).Info("Updating policy group " + group.Uuid)
+		}
+	}
+	return nil
+}
+
+// hasResource checks if a policy already applies to a given resource, as defaults are
+// inserted with their latest resources on first run.
+func hasResource(p *idm.Policy, resource string) bool {
+	for _, r := range p.Resources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
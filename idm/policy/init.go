@@ -50,4 +50,20 @@ func init() {
 		return new(conditions.DateAfterCondition)
 	}
 
+	ladon.ConditionFactories[new(conditions.IPRangeCondition).GetName()] = func() ladon.Condition {
+		return new(conditions.IPRangeCondition)
+	}
+
+	ladon.ConditionFactories[new(conditions.UserRolesCondition).GetName()] = func() ladon.Condition {
+		return new(conditions.UserRolesCondition)
+	}
+
+	ladon.ConditionFactories[new(conditions.UserAttributeCondition).GetName()] = func() ladon.Condition {
+		return new(conditions.UserAttributeCondition)
+	}
+
+	ladon.ConditionFactories[new(conditions.SecondFactorCondition).GetName()] = func() ladon.Condition {
+		return new(conditions.SecondFactorCondition)
+	}
+
 }
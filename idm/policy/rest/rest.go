@@ -71,3 +71,23 @@ func (h *PolicyHandler) ListPolicies(req *restful.Request, rsp *restful.Response
 
 	rsp.WriteEntity(response)
 }
+
+// ExplainPolicy evaluates a request against the security policies and details the decision.
+func (h *PolicyHandler) ExplainPolicy(req *restful.Request, rsp *restful.Response) {
+
+	var input idm.PolicyExplainRequest
+	if err := req.ReadEntity(&input); err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+	ctx := req.Request.Context()
+	log.Logger(ctx).Debug("Received Policy.Explain API request")
+
+	response, err := h.getClient().ExplainPolicy(ctx, &input)
+	if err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+
+	rsp.WriteEntity(response)
+}
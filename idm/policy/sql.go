@@ -45,8 +45,11 @@ type sqlimpl struct {
 }
 
 var (
-	queries = map[string]string{
-		"upsertPolicyGroup": `INSERT INTO idm_policy_group (uuid,name,description,owner_uuid,resource_group,last_updated) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE name=?,description=?,owner_uuid=?,resource_group=?,last_updated=?`,
+	queries = map[string]interface{}{
+		"upsertPolicyGroup": sql.DriverQueries{
+			"":         `INSERT INTO idm_policy_group (uuid,name,description,owner_uuid,resource_group,last_updated) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE name=?,description=?,owner_uuid=?,resource_group=?,last_updated=?`,
+			"postgres": `INSERT INTO idm_policy_group (uuid,name,description,owner_uuid,resource_group,last_updated) VALUES (?,?,?,?,?,?) ON CONFLICT (uuid) DO UPDATE SET name=?,description=?,owner_uuid=?,resource_group=?,last_updated=?`,
+		},
 		"deletePolicyGroup": `DELETE FROM idm_policy_group WHERE uuid=?`,
 		"insertRelPolicy":   `INSERT INTO idm_policy_rel (group_uuid,policy_id) VALUES (?,?)`,
 		"deleteRelPolicies": `DELETE FROM idm_policy_rel WHERE group_uuid=?`,
@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS idm_roles (
+    uuid            VARCHAR(255) NOT NULL,
+    label           VARCHAR(500) NOT NULL,
+    team_role       SMALLINT,
+    group_role      SMALLINT,
+    user_role       SMALLINT,
+    last_updated    INT,
+    auto_applies    VARCHAR(500),
+    UNIQUE (uuid)
+);
+
+-- +migrate Down
+DROP TABLE idm_roles;
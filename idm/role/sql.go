@@ -120,18 +120,18 @@ func (s *sqlimpl) Add(role *idm.Role) (*idm.Role, bool, error) {
 		_, err = s.GetStmt("AddRole").Exec(
 			role.Uuid,
 			role.Label,
-			role.IsTeam,
-			role.GroupRole,
-			role.UserRole,
+			boolToInt(role.IsTeam),
+			boolToInt(role.GroupRole),
+			boolToInt(role.UserRole),
 			role.LastUpdated,
 			strings.Join(role.AutoApplies, ","),
 		)
 	} else {
 		_, err = s.GetStmt("UpdateRole").Exec(
 			role.Label,
-			role.IsTeam,
-			role.GroupRole,
-			role.UserRole,
+			boolToInt(role.IsTeam),
+			boolToInt(role.GroupRole),
+			boolToInt(role.UserRole),
 			role.LastUpdated,
 			strings.Join(role.AutoApplies, ","),
 			role.Uuid,
@@ -144,6 +144,14 @@ func (s *sqlimpl) Add(role *idm.Role) (*idm.Role, bool, error) {
 
 }
 
+// boolToInt stores flags as 0/1, the representation shared by all supported drivers
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (s *sqlimpl) Count(query sql.Enquirer) (int32, error) {
 
 	queryString, err := s.buildSearchQuery(query, true, false)
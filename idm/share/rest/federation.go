@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package rest
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/auth/claim"
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/crypto"
+	"github.com/pydio/cells/common/federation"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/rest"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/defaults"
+	service2 "github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/common/utils"
+	"github.com/pydio/cells/idm/key"
+)
+
+const (
+	outgoingStoreID = "ocm-outgoing"
+	// outgoingSecretKeyID is the ID of the key holding the shared secret, in the key store of the hidden user
+	outgoingSecretKeyID = "ocm-shared-secret"
+)
+
+// OutgoingShare records a Cell shared with a user of a remote server. The remote server accesses the Cell
+// through WebDAV with a dedicated hidden user: its login is used as the share provider ID, and its password
+// as the shared secret. The secret is kept in the user key store, the document only references it.
+type OutgoingShare struct {
+	ID          string   `json:"-"`
+	WorkspaceId string   `json:"WORKSPACE"`
+	Address     string   `json:"ADDRESS"`
+	UserUuid    string   `json:"USER_UUID"`
+	SecretRef   string   `json:"SECRET_REF"`
+	Permissions []string `json:"PERMISSIONS"`
+
+	secret  string
+	changed bool
+}
+
+// newOutgoingSecret generates a random shared secret. The password complexity suffix
+// is appended as it is also used as the password of the hidden user.
+func newOutgoingSecret() (string, error) {
+	random, err := crypto.RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random) + PasswordComplexitySuffix, nil
+}
+
+// Secret loads the shared secret of the share from the user key store.
+func (s *OutgoingShare) Secret(ctx context.Context) (string, error) {
+	if s.secret == "" {
+		secret, err := key.ManagedSecret(ctx, s.ID, s.SecretRef)
+		if err != nil {
+			return "", err
+		}
+		s.secret = string(secret)
+	}
+	return s.secret, nil
+}
+
+// RemoteUserPermissions translates the ACL actions granted to a remote user into share permissions.
+func RemoteUserPermissions(actions []*idm.ACLAction) []string {
+	var read, write bool
+	for _, a := range actions {
+		if a.Name == utils.ACL_READ.Name {
+			read = true
+		} else if a.Name == utils.ACL_WRITE.Name {
+			write = true
+		}
+	}
+	var perms []string
+	if read || !write {
+		perms = append(perms, federation.PermissionRead)
+	}
+	if write {
+		perms = append(perms, federation.PermissionWrite)
+	}
+	return perms
+}
+
+// RemoteUserActions is the reverse of RemoteUserPermissions.
+func RemoteUserActions(permissions []string) (actions []*idm.ACLAction) {
+	for _, p := range permissions {
+		switch p {
+		case federation.PermissionRead:
+			actions = append(actions, utils.ACL_READ)
+		case federation.PermissionWrite:
+			actions = append(actions, utils.ACL_WRITE)
+		}
+	}
+	return
+}
+
+// ListOutgoingShares loads the remote users of a Cell.
+func (h *SharesHandler) ListOutgoingShares(ctx context.Context, workspaceId string) (shares []*OutgoingShare, err error) {
+	store := docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+	stream, err := store.ListDocuments(ctx, &docstore.ListDocumentsRequest{StoreID: outgoingStoreID, Query: &docstore.DocumentQuery{
+		MetaQuery: "+WORKSPACE:\"" + workspaceId + "\"",
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	for {
+		resp, e := stream.Recv()
+		if e != nil {
+			break
+		}
+		share := &OutgoingShare{}
+		if e := json.Unmarshal([]byte(resp.Document.Data), share); e != nil {
+			continue
+		}
+		share.ID = resp.Document.ID
+		shares = append(shares, share)
+	}
+	return
+}
+
+// PrepareRemoteUsers compares the remote users of a Cell with the current ones. New users are validated
+// by contacting their server, and a hidden user is created for each of them.
+func (h *SharesHandler) PrepareRemoteUsers(ctx context.Context, workspaceId string, remoteUsers []*rest.CellRemoteUser) (target []*OutgoingShare, removed []*OutgoingShare, err error) {
+	current, err := h.ListOutgoingShares(ctx, workspaceId)
+	if err != nil {
+		return nil, nil, err
+	}
+	byAddress := make(map[string]*OutgoingShare, len(current))
+	for _, s := range current {
+		byAddress[s.Address] = s
+	}
+	client := federation.NewClient()
+	kept := make(map[string]bool)
+	for _, remote := range remoteUsers {
+		address := strings.TrimSpace(remote.Address)
+		_, server, e := federation.ParseAddress(address)
+		if e != nil {
+			return nil, nil, e
+		}
+		perms := RemoteUserPermissions(remote.Actions)
+		if share, ok := byAddress[address]; ok {
+			share.changed = strings.Join(share.Permissions, ",") != strings.Join(perms, ",")
+			share.Permissions = perms
+			kept[share.ID] = true
+			target = append(target, share)
+			continue
+		}
+		if _, e := client.Discover(ctx, server); e != nil {
+			return nil, nil, fmt.Errorf("cannot share with %s: %s", address, e.Error())
+		}
+		secret, e := newOutgoingSecret()
+		if e != nil {
+			return nil, nil, e
+		}
+		user, e := h.GetOrCreateHiddenUser(ctx, &rest.ShareLink{}, true, secret)
+		if e != nil {
+			return nil, nil, e
+		}
+		if e := key.StoreManagedSecret(ctx, user.Login, outgoingSecretKeyID, "Shared secret for "+address, []byte(secret)); e != nil {
+			return nil, nil, e
+		}
+		share := &OutgoingShare{
+			ID:          user.Login,
+			WorkspaceId: workspaceId,
+			Address:     address,
+			UserUuid:    user.Uuid,
+			SecretRef:   outgoingSecretKeyID,
+			Permissions: perms,
+			secret:      secret,
+			changed:     true,
+		}
+		if e := h.storeOutgoingShare(ctx, share); e != nil {
+			return nil, nil, e
+		}
+		byAddress[address] = share
+		kept[share.ID] = true
+		target = append(target, share)
+	}
+	for _, s := range current {
+		if !kept[s.ID] {
+			removed = append(removed, s)
+		}
+	}
+	return
+}
+
+// RemoteUsersAcls computes the ACLs granting remote users access to the Cell root nodes.
+func (h *SharesHandler) RemoteUsersAcls(workspaceId string, rootNodes []*tree.Node, shares []*OutgoingShare) (acls []*idm.ACL) {
+	for _, node := range rootNodes {
+		for _, share := range shares {
+			for _, action := range RemoteUserActions(share.Permissions) {
+				acls = append(acls, &idm.ACL{
+					NodeID:      node.Uuid,
+					RoleID:      share.UserUuid,
+					WorkspaceID: workspaceId,
+					Action:      action,
+				})
+			}
+		}
+	}
+	return
+}
+
+// SendOutgoingShares notifies the servers of remote users of new or modified shares.
+func (h *SharesHandler) SendOutgoingShares(ctx context.Context, workspace *idm.Workspace, shares []*OutgoingShare) error {
+	claims, _ := ctx.Value(claim.ContextKey).(claim.Claims)
+	baseUrl := strings.TrimSuffix(config.Get("defaults", "url").String(""), "/")
+	owner := claims.Name + "@" + baseUrl
+	client := federation.NewClient()
+	for _, share := range shares {
+		if !share.changed {
+			continue
+		}
+		secret, e := share.Secret(ctx)
+		if e != nil {
+			return fmt.Errorf("cannot load shared secret for %s: %s", share.Address, e.Error())
+		}
+		e = client.SendShare(ctx, share.Address, &federation.Share{
+			Name:              workspace.Label,
+			Description:       workspace.Description,
+			ProviderID:        share.ID,
+			Owner:             owner,
+			Sender:            owner,
+			OwnerDisplayName:  claims.DisplayName,
+			SenderDisplayName: claims.DisplayName,
+			Protocol: federation.Protocol{
+				Name: federation.ProtocolWebDAV,
+				WebDAV: &federation.WebDAVOptions{
+					URI:          baseUrl + "/dav/" + workspace.Slug,
+					SharedSecret: secret,
+					Permissions:  share.Permissions,
+				},
+			},
+		})
+		if e != nil {
+			return fmt.Errorf("cannot share with %s: %s", share.Address, e.Error())
+		}
+		if e := h.storeOutgoingShare(ctx, share); e != nil {
+			return e
+		}
+		log.Auditer(ctx).Info(
+			fmt.Sprintf("Cell %s has been shared with remote user %s", workspace.Label, share.Address),
+			log.GetAuditId(common.AUDIT_CELL_UPDATE),
+			zap.String(common.KEY_CELL_UUID, workspace.UUID),
+		)
+	}
+	return nil
+}
+
+// RevokeOutgoingShares notifies the servers of remote users that they lost access to a Cell,
+// and deletes the associated hidden users.
+func (h *SharesHandler) RevokeOutgoingShares(ctx context.Context, shares []*OutgoingShare) {
+	client := federation.NewClient()
+	store := docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+	uClient := idm.NewUserServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER, defaults.NewClient())
+	roleClient := idm.NewRoleServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ROLE, defaults.NewClient())
+	keyClient := encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+	for _, share := range shares {
+		// Access is revoked anyway as the hidden user is deleted
+		if secret, e := share.Secret(ctx); e != nil {
+			log.Logger(ctx).Error("Cannot load shared secret to notify remote server of unshare", zap.String("address", share.Address), zap.Error(e))
+		} else if e := client.SendNotification(ctx, share.Address, &federation.Notification{
+			NotificationType: federation.NotificationUnshare,
+			ProviderID:       share.ID,
+			Notification:     map[string]string{"sharedSecret": secret},
+		}); e != nil {
+			log.Logger(ctx).Error("Cannot notify remote server of unshare", zap.String("address", share.Address), zap.Error(e))
+		}
+		keyClient.DeleteUserKey(ctx, &encryption.DeleteUserKeyRequest{Owner: share.ID, KeyID: share.SecretRef})
+		uQ, _ := ptypes.MarshalAny(&idm.UserSingleQuery{Login: share.ID})
+		if _, e := uClient.DeleteUser(ctx, &idm.DeleteUserRequest{Query: &service2.Query{SubQueries: []*any.Any{uQ}}}); e != nil {
+			log.Logger(ctx).Error("Cannot delete hidden user for remote share", zap.String("login", share.ID), zap.Error(e))
+		}
+		rQ, _ := ptypes.MarshalAny(&idm.RoleSingleQuery{Uuid: []string{share.UserUuid}})
+		roleClient.DeleteRole(ctx, &idm.DeleteRoleRequest{Query: &service2.Query{SubQueries: []*any.Any{rQ}}})
+		store.DeleteDocuments(ctx, &docstore.DeleteDocumentsRequest{StoreID: outgoingStoreID, DocumentID: share.ID})
+	}
+}
+
+func (h *SharesHandler) storeOutgoingShare(ctx context.Context, share *OutgoingShare) error {
+	data, _ := json.Marshal(share)
+	meta, _ := json.Marshal(map[string]string{"WORKSPACE": share.WorkspaceId, "ADDRESS": share.Address})
+	store := docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+	_, err := store.PutDocument(ctx, &docstore.PutDocumentRequest{StoreID: outgoingStoreID, DocumentID: share.ID, Document: &docstore.Document{
+		ID:            share.ID,
+		Data:          string(data),
+		IndexableMeta: string(meta),
+	}})
+	return err
+}
@@ -46,6 +46,7 @@ type TargetUserEntry struct {
 // HashDocument is a Json Marshallable representation of a document, compatible with legacy.
 type HashDocument struct {
 	ShareType             string                      `json:"SHARE_TYPE"`
+	AccessStart           int64                       `json:"ACCESS_START"`
 	ExpireTime            int64                       `json:"EXPIRE_TIME"`
 	ShortFormUrl          string                      `json:"SHORT_FORM_URL"`
 	RepositoryId          string                      `json:"REPOSITORY"`
@@ -62,6 +63,23 @@ type HashDocument struct {
 	RestrictToTargetUsers bool                        `json:"RESTRICT_TO_TARGET_USERS"`
 	OwnerId               string                      `json:"OWNER_ID"`
 	PreUserUuid           string                      `json:"USER_UUID"`
+	FileDrop              bool                        `json:"FILE_DROP"`
+	DropFolder            string                      `json:"DROP_FOLDER"`
+}
+
+// IsFileDrop checks if the permissions define an upload-only link: visitors can only
+// drop files and see what they uploaded themselves.
+func IsFileDrop(permissions []rest.ShareLinkAccessType) bool {
+	var upload bool
+	for _, perm := range permissions {
+		if perm == rest.ShareLinkAccessType_Preview || perm == rest.ShareLinkAccessType_Download {
+			return false
+		}
+		if perm == rest.ShareLinkAccessType_Upload {
+			upload = true
+		}
+	}
+	return upload
 }
 
 func StoreHashDocument(ctx context.Context, link *rest.ShareLink, updateHash ...string) error {
@@ -73,6 +91,7 @@ func StoreHashDocument(ctx context.Context, link *rest.ShareLink, updateHash ...
 		OwnerId:       claims.Name,
 		TemplateName:  link.ViewTemplateName,
 		RepositoryId:  link.Uuid,
+		AccessStart:   link.AccessStart,
 		ExpireTime:    link.AccessEnd,
 		DownloadLimit: link.MaxDownloads,
 		ShareType:     "minisite",
@@ -101,6 +120,22 @@ func StoreHashDocument(ctx context.Context, link *rest.ShareLink, updateHash ...
 		}
 	}
 	hashDoc.DownloadDisabled = !DownloadEnabled
+	if IsFileDrop(link.Permissions) {
+		hashDoc.FileDrop = true
+		if link.DropFolder != rest.ShareLinkDropFolder_NoFolder {
+			hashDoc.DropFolder = link.DropFolder.String()
+		}
+	}
+
+	// Preserve current downloads counter when updating an existing link
+	if link.LinkHash != "" {
+		if resp, e := store.GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: "share", DocumentID: link.LinkHash}); e == nil && resp.Document != nil {
+			var existing *HashDocument
+			if e := json.Unmarshal([]byte(resp.Document.Data), &existing); e == nil {
+				hashDoc.DownloadCount = existing.DownloadCount
+			}
+		}
+	}
 
 	hashDocMarshaled, _ := json.Marshal(hashDoc)
 	var removeHash string
@@ -152,6 +187,7 @@ func LoadHashDocumentData(ctx context.Context, shareLink *rest.ShareLink, acls [
 	var linkData *HashDocument
 	if err := json.Unmarshal([]byte(linkDoc.Data), &linkData); err == nil {
 		shareLink.ViewTemplateName = linkData.TemplateName
+		shareLink.AccessStart = linkData.AccessStart
 		shareLink.AccessEnd = linkData.ExpireTime
 		shareLink.MaxDownloads = linkData.DownloadLimit
 		shareLink.CurrentDownloads = linkData.DownloadCount
@@ -162,6 +198,9 @@ func LoadHashDocumentData(ctx context.Context, shareLink *rest.ShareLink, acls [
 			shareLink.UserLogin = linkData.PreLogUser
 		}
 		shareLink.UserUuid = linkData.PreUserUuid
+		if linkData.DropFolder != "" {
+			shareLink.DropFolder = rest.ShareLinkDropFolder(rest.ShareLinkDropFolder_value[linkData.DropFolder])
+		}
 		if linkData.TargetUsers != nil && len(linkData.TargetUsers) > 0 {
 			shareLink.TargetUsers = make(map[string]*rest.ShareLinkTargetUser)
 			for id, t := range linkData.TargetUsers {
@@ -203,6 +242,10 @@ func DeleteHashDocument(ctx context.Context, shareId string) error {
 	if !resp.Success || resp.DeletionCount == 0 {
 		return errors.NotFound(common.SERVICE_SHARE, "Could not delete hash associated to this workspace "+shareId)
 	}
+	// Clean visitors sessions of file-drop links
+	store.DeleteDocuments(ctx, &docstore.DeleteDocumentsRequest{StoreID: "filedrop", Query: &docstore.DocumentQuery{
+		MetaQuery: "+REPOSITORY:\"" + shareId + "\"",
+	}})
 	return nil
 
 }
@@ -26,6 +26,7 @@ package rest
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/pydio/cells/common"
 	"github.com/pydio/cells/common/auth/claim"
 	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/activity"
 	"github.com/pydio/cells/common/proto/idm"
 	"github.com/pydio/cells/common/proto/rest"
 	"github.com/pydio/cells/common/proto/tree"
@@ -103,6 +105,13 @@ func (h *SharesHandler) PutCell(req *restful.Request, rsp *restful.Response) {
 		return
 	}
 
+	// Validate remote users and prepare their hidden users before touching ACLs
+	remoteShares, revokedShares, err := h.PrepareRemoteUsers(ctx, workspace.UUID, shareRequest.Room.RemoteUsers)
+	if err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+
 	// Now set ACLs on Workspace
 	claims := ctx.Value(claim.ContextKey).(claim.Claims)
 	userId, _ := claims.DecodeUserUuid()
@@ -161,6 +170,7 @@ func (h *SharesHandler) PutCell(req *restful.Request, rsp *restful.Response) {
 			})
 		}
 	}
+	targetAcls = append(targetAcls, h.RemoteUsersAcls(workspace.UUID, shareRequest.Room.RootNodes, remoteShares)...)
 
 	log.Logger(ctx).Debug("Share ACLS", zap.Any("current", currentAcls), zap.Any("target", targetAcls))
 	add, remove := h.DiffAcls(ctx, currentAcls, targetAcls)
@@ -185,6 +195,9 @@ func (h *SharesHandler) PutCell(req *restful.Request, rsp *restful.Response) {
 		}
 	}
 
+	addRoles, removeRoles := h.DiffReadRoles(ctx, currentAcls, targetAcls)
+	h.UpdateEncryptionKeys(ctx, shareRequest.Room.RootNodes, addRoles, removeRoles)
+
 	log.Logger(ctx).Debug("Share Policies", zap.Any("before", workspace.Policies))
 	h.UpdatePoliciesFromAcls(ctx, workspace, currentAcls, targetAcls)
 
@@ -196,6 +209,12 @@ func (h *SharesHandler) PutCell(req *restful.Request, rsp *restful.Response) {
 		return
 	}
 
+	h.RevokeOutgoingShares(ctx, revokedShares)
+	if err := h.SendOutgoingShares(ctx, workspace, remoteShares); err != nil {
+		service.RestError500(req, rsp, err)
+		return
+	}
+
 	// Put an Audit log if this cell has been newly created
 	if wsCreated {
 		log.Auditer(ctx).Info(
@@ -259,6 +278,19 @@ func (h *SharesHandler) DeleteCell(req *restful.Request, rsp *restful.Response)
 
 	currWsLabel := ws.Label
 
+	if acls, roots, e := h.CommonAclsForWorkspace(ctx, id); e == nil {
+		var rootNodes []*tree.Node
+		for _, n := range h.LoadDetectedRootNodes(ctx, roots) {
+			rootNodes = append(rootNodes, n)
+		}
+		_, removeRoles := h.DiffReadRoles(ctx, acls, nil)
+		h.UpdateEncryptionKeys(ctx, rootNodes, nil, removeRoles)
+	}
+
+	if remoteShares, e := h.ListOutgoingShares(ctx, id); e == nil {
+		h.RevokeOutgoingShares(ctx, remoteShares)
+	}
+
 	log.Logger(ctx).Debug("Delete share room", zap.Any("workspaceId", id))
 	// This will load the workspace and its root, and eventually remove the Room root totally
 	if err := h.DeleteWorkspace(ctx, idm.WorkspaceScope_ROOM, id); err != nil {
@@ -383,6 +415,10 @@ func (h *SharesHandler) PutShareLink(req *restful.Request, rsp *restful.Response
 		service.RestError500(req, rsp, err)
 		return
 	}
+	if IsFileDrop(link.Permissions) {
+		h.SubscribeOwnerToUploads(ctx, link.RootNodes)
+		track("SubscribeOwnerToUploads")
+	}
 	if create {
 		log.Auditer(ctx).Info(
 			fmt.Sprintf("ShareLink %s has been created", link.Label),
@@ -488,6 +524,21 @@ func (h *SharesHandler) WorkspaceToCellObject(ctx context.Context, workspace *id
 	log.Logger(ctx).Debug("Detected Roots for object", zap.Any("roots", detectedRoots))
 	roomAcls := h.AclsToCellAcls(ctx, acls)
 
+	// Hidden users of remote shares are exposed as RemoteUsers
+	remoteShares, err := h.ListOutgoingShares(ctx, workspace.UUID)
+	if err != nil {
+		return nil, err
+	}
+	var remoteUsers []*rest.CellRemoteUser
+	for _, share := range remoteShares {
+		delete(roomAcls, share.UserUuid)
+		remoteUsers = append(remoteUsers, &rest.CellRemoteUser{
+			Address:    share.Address,
+			Actions:    RemoteUserActions(share.Permissions),
+			ProviderId: share.ID,
+		})
+	}
+
 	log.Logger(ctx).Debug("Computed roomAcls before load", zap.Any("roomAcls", roomAcls))
 	if err := h.LoadCellAclsObjects(ctx, roomAcls); err != nil {
 		log.Logger(ctx).Error("Error on loadRomAclsObjects", zap.Error(err))
@@ -507,6 +558,7 @@ func (h *SharesHandler) WorkspaceToCellObject(ctx context.Context, workspace *id
 		ACLs:                    roomAcls,
 		Policies:                workspace.Policies,
 		PoliciesContextEditable: h.IsContextEditable(ctx, workspace.UUID, workspace.Policies),
+		RemoteUsers:             remoteUsers,
 	}, nil
 }
 
@@ -748,6 +800,98 @@ func (h *SharesHandler) DiffReadRoles(ctx context.Context, initial []*idm.ACL, n
 	return
 }
 
+// UpdateEncryptionKeys shares or revokes the encryption keys of the root nodes for users added to or removed
+// from the read roles. It is only effective on datasources encrypted with per-user keys.
+func (h *SharesHandler) UpdateEncryptionKeys(ctx context.Context, rootNodes []*tree.Node, addRoles []string, removeRoles []string) {
+
+	addUsers := h.UserLoginsForRoles(ctx, addRoles)
+	removeUsers := h.UserLoginsForRoles(ctx, removeRoles)
+	if len(addUsers) == 0 && len(removeUsers) == 0 {
+		return
+	}
+
+	pool := views.NewClientsPool(false)
+	for _, node := range rootNodes {
+		if len(addUsers) > 0 {
+			if e := views.ShareNodeKeys(ctx, pool, node, addUsers); e != nil {
+				log.Logger(ctx).Error("Share: cannot share encryption keys", zap.String("node", node.Uuid), zap.Error(e))
+			}
+		}
+		if len(removeUsers) > 0 {
+			if e := views.UnshareNodeKeys(ctx, pool, node, removeUsers); e != nil {
+				log.Logger(ctx).Error("Share: cannot revoke encryption keys", zap.String("node", node.Uuid), zap.Error(e))
+			}
+		}
+	}
+}
+
+// UserLoginsForRoles finds the logins of the users whose personal role is in roleIds, of the users having one of
+// the team roles in roleIds, and of the members of the groups whose role is in roleIds.
+func (h *SharesHandler) UserLoginsForRoles(ctx context.Context, roleIds []string) (logins []string) {
+
+	if len(roleIds) == 0 {
+		return
+	}
+	var queries []*any.Any
+	for _, roleId := range roleIds {
+		q, _ := ptypes.MarshalAny(&idm.UserSingleQuery{Uuid: roleId})
+		queries = append(queries, q)
+		t, _ := ptypes.MarshalAny(&idm.UserSingleQuery{HasRole: roleId, NodeType: idm.NodeType_USER})
+		queries = append(queries, t)
+	}
+	seen := make(map[string]bool)
+	var groupPaths []string
+	e := h.searchUsers(ctx, queries, func(u *idm.User) {
+		if u.IsGroup {
+			groupPaths = append(groupPaths, u.GroupPath)
+		} else if !seen[u.Login] {
+			seen[u.Login] = true
+			logins = append(logins, u.Login)
+		}
+	})
+	if e != nil {
+		log.Logger(ctx).Error("Share: cannot load users for roles", zap.Error(e))
+		return
+	}
+	if len(groupPaths) == 0 {
+		return
+	}
+
+	var memberQueries []*any.Any
+	for _, groupPath := range groupPaths {
+		q, _ := ptypes.MarshalAny(&idm.UserSingleQuery{GroupPath: groupPath, Recursive: true, NodeType: idm.NodeType_USER})
+		memberQueries = append(memberQueries, q)
+	}
+	e = h.searchUsers(ctx, memberQueries, func(u *idm.User) {
+		if !u.IsGroup && !seen[u.Login] {
+			seen[u.Login] = true
+			logins = append(logins, u.Login)
+		}
+	})
+	if e != nil {
+		log.Logger(ctx).Error("Share: cannot load group members for roles", zap.Error(e))
+	}
+	return
+}
+
+func (h *SharesHandler) searchUsers(ctx context.Context, queries []*any.Any, callback func(u *idm.User)) error {
+	uClient := idm.NewUserServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER, defaults.NewClient())
+	stream, e := uClient.SearchUser(ctx, &idm.SearchUserRequest{Query: &service2.Query{SubQueries: queries, Operation: service2.OperationType_OR}})
+	if e != nil {
+		return e
+	}
+	defer stream.Close()
+	for {
+		rsp, e := stream.Recv()
+		if e == io.EOF {
+			return nil
+		} else if e != nil {
+			return e
+		}
+		callback(rsp.User)
+	}
+}
+
 // UpdatePoliciesFromAcls recomputes the required policies from acl changes.
 func (h *SharesHandler) UpdatePoliciesFromAcls(ctx context.Context, workspace *idm.Workspace, initial []*idm.ACL, target []*idm.ACL) bool {
 
@@ -1171,9 +1315,54 @@ func (h *SharesHandler) UpdateACLsForHiddenUser(ctx context.Context, roleId stri
 		}
 	}
 
+	if HasRead {
+		h.UpdateEncryptionKeys(ctx, rootNodes, []string{roleId}, nil)
+	}
+
 	return nil
 }
 
+// SubscribeOwnerToUploads makes sure the link owner follows changes on the link root nodes,
+// so that files dropped by visitors of an upload-only link are posted to their activity inbox.
+func (h *SharesHandler) SubscribeOwnerToUploads(ctx context.Context, rootNodes []*tree.Node) {
+
+	claims, ok := ctx.Value(claim.ContextKey).(claim.Claims)
+	if !ok || claims.Name == "" {
+		return
+	}
+	cli := activity.NewActivityServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACTIVITY, defaults.NewClient())
+	for _, node := range rootNodes {
+		events := []string{"change"}
+		// Keep events from an existing subscription
+		if streamer, e := cli.SearchSubscriptions(ctx, &activity.SearchSubscriptionsRequest{
+			UserIds:     []string{claims.Name},
+			ObjectTypes: []activity.OwnerType{activity.OwnerType_NODE},
+			ObjectIds:   []string{node.Uuid},
+		}); e == nil {
+			for {
+				resp, er := streamer.Recv()
+				if er != nil {
+					break
+				}
+				for _, ev := range resp.GetSubscription().GetEvents() {
+					if ev != "change" {
+						events = append(events, ev)
+					}
+				}
+			}
+			streamer.Close()
+		}
+		if _, e := cli.Subscribe(ctx, &activity.SubscribeRequest{Subscription: &activity.Subscription{
+			UserId:     claims.Name,
+			ObjectType: activity.OwnerType_NODE,
+			ObjectId:   node.Uuid,
+			Events:     events,
+		}}); e != nil {
+			log.Logger(ctx).Error("Cannot subscribe link owner to root node", zap.String(common.KEY_NODE_UUID, node.Uuid), zap.Error(e))
+		}
+	}
+}
+
 // GetTemplateACLsForMinisite loads actions and parameter acls from specific template roles.
 func (h *SharesHandler) GetTemplateACLsForMinisite(ctx context.Context, roleId string, permissions []rest.ShareLinkAccessType, aclClient idm.ACLServiceClient) (acls []*idm.ACL, err error) {
 
@@ -22,9 +22,13 @@ package rest
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
+	"github.com/pydio/cells/common/federation"
 	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/utils"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -377,3 +381,51 @@ func TestSharesHandler_AclsToRoomAcls(t *testing.T) {
 
 	})
 }
+
+func TestSharesHandler_RemoteUsersAcls(t *testing.T) {
+
+	Convey("Test Remote Users Permissions", t, func() {
+
+		So(RemoteUserPermissions([]*idm.ACLAction{utils.ACL_READ, utils.ACL_WRITE}), ShouldResemble, []string{federation.PermissionRead, federation.PermissionWrite})
+		So(RemoteUserPermissions([]*idm.ACLAction{utils.ACL_WRITE}), ShouldResemble, []string{federation.PermissionWrite})
+		So(RemoteUserPermissions(nil), ShouldResemble, []string{federation.PermissionRead})
+		So(RemoteUserActions([]string{federation.PermissionRead, "unknown"}), ShouldResemble, []*idm.ACLAction{utils.ACL_READ})
+
+		h := &SharesHandler{}
+		acls := h.RemoteUsersAcls("ws", []*tree.Node{{Uuid: "node1"}, {Uuid: "node2"}}, []*OutgoingShare{
+			{ID: "remote1", UserUuid: "user1", Permissions: []string{federation.PermissionRead, federation.PermissionWrite}},
+			{ID: "remote2", UserUuid: "user2", Permissions: []string{federation.PermissionRead}},
+		})
+		So(acls, ShouldHaveLength, 6)
+		So(acls[0].RoleID, ShouldEqual, "user1")
+		So(acls[0].NodeID, ShouldEqual, "node1")
+		So(acls[0].WorkspaceID, ShouldEqual, "ws")
+		So(acls[5].RoleID, ShouldEqual, "user2")
+		So(acls[5].NodeID, ShouldEqual, "node2")
+
+	})
+}
+
+func TestOutgoingShareSecret(t *testing.T) {
+
+	Convey("Test shared secrets are random and not stored in share documents", t, func() {
+
+		first, e := newOutgoingSecret()
+		So(e, ShouldBeNil)
+		second, e := newOutgoingSecret()
+		So(e, ShouldBeNil)
+		So(first, ShouldNotEqual, second)
+		So(first, ShouldHaveLength, 64+len(PasswordComplexitySuffix))
+		So(first, ShouldEndWith, PasswordComplexitySuffix)
+
+		share := &OutgoingShare{ID: "remote1", SecretRef: outgoingSecretKeyID, secret: first}
+		data, _ := json.Marshal(share)
+		So(string(data), ShouldNotContainSubstring, first)
+		So(string(data), ShouldContainSubstring, outgoingSecretKeyID)
+
+		loaded, e := share.Secret(context.Background())
+		So(e, ShouldBeNil)
+		So(loaded, ShouldEqual, first)
+
+	})
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/pydio/cells/common/registry"
 	"github.com/pydio/cells/common/service/context"
 	"github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/idm/key"
 	"github.com/pydio/cells/idm/user"
 )
 
@@ -85,6 +86,8 @@ func (h *Handler) CreateUser(ctx context.Context, req *idm.CreateUserRequest, re
 		return fmt.Errorf("no DAO found, wrong initialization")
 	}
 	dao := servicecontext.GetDAO(ctx).(user.DAO)
+	password := req.User.Password
+	passwordChanged := !req.User.IsGroup && password != ""
 
 	// Create or update user
 	newUser, update, err := dao.Add(req.User)
@@ -105,10 +108,19 @@ func (h *Handler) CreateUser(ctx context.Context, req *idm.CreateUserRequest, re
 	}
 
 	// Propagate creation event
-	client.Publish(ctx, client.NewPublication(common.TOPIC_IDM_EVENT, &idm.ChangeEvent{
+	event := &idm.ChangeEvent{
 		Type: idm.ChangeEventType_UPDATE,
 		User: out,
-	}))
+	}
+	if passwordChanged {
+		// Derive the secret protecting password-encrypted data from the new password, the key service
+		// seals the user keys again with it when receiving the event.
+		if err := key.StoreUserSecret(ctx, out.Login, password); err != nil {
+			log.Logger(ctx).Error("cannot store user secret derived from password", out.ZapLogin(), zap.Error(err))
+		}
+		event.Attributes = map[string]string{common.IDM_EVENT_ATTRIBUTE_PASSWORD_CHANGED: "true"}
+	}
+	client.Publish(ctx, client.NewPublication(common.TOPIC_IDM_EVENT, event))
 	if update {
 		if out.IsGroup {
 			log.Auditer(ctx).Info(
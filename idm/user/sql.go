@@ -50,12 +50,18 @@ const (
 )
 
 var (
-	queries = map[string]string{
-		"AddAttribute":     `replace into idm_user_attributes (uuid, name, value) values (?, ?, ?)`,
+	queries = map[string]interface{}{
+		"AddAttribute": sql.DriverQueries{
+			"":         `replace into idm_user_attributes (uuid, name, value) values (?, ?, ?)`,
+			"postgres": `insert into idm_user_attributes (uuid, name, value) values (?, ?, ?) on conflict (uuid, name) do update set value = excluded.value`,
+		},
 		"GetAttributes":    `select name, value from idm_user_attributes where uuid = ?`,
 		"DeleteAttribute":  `delete from idm_user_attributes where uuid = ? and name = ?`,
 		"DeleteAttributes": `delete from idm_user_attributes where uuid = ?`,
-		"AddRole":          `replace into idm_user_roles (uuid, role) values (?, ?)`,
+		"AddRole": sql.DriverQueries{
+			"":         `replace into idm_user_roles (uuid, role) values (?, ?)`,
+			"postgres": `insert into idm_user_roles (uuid, role) values (?, ?) on conflict (uuid, role) do nothing`,
+		},
 		"GetRoles":         `select role from idm_user_roles where uuid = ?`,
 		"DeleteRole":       `delete from idm_user_roles where uuid = ? and role = ?`,
 		"DeleteRoles":      `delete from idm_user_roles where uuid = ?`,
@@ -530,7 +536,7 @@ func getMPathEquals(tableAlias string, mpath []byte) string {
 	for {
 		var cnt int
 		cnt = (len(mpath) - 1) / indexLen
-		res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
+		res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
 
 		if idx := cnt * indexLen; idx == 0 {
 			break
@@ -558,10 +564,10 @@ func getMPathLike(tableAlias string, mpath []byte) string {
 		cnt = (len(mpath) - 1) / indexLen
 
 		if !done {
-			res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
+			res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
 			done = true
 		} else {
-			res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
+			res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
 		}
 
 		if idx := cnt * indexLen; idx == 0 {
@@ -590,11 +596,11 @@ func getMPathEqualsOrLike(tableAlias string, mpath []byte) string {
 		cnt = (len(mpath) - 1) / indexLen
 
 		if !done {
-			res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):len(mpath)-2]))
-			res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
+			res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):len(mpath)-2]))
+			res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
 			done = true
 		} else {
-			res = append(res, fmt.Sprintf(`%smpath%d LIKE "%s"`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
+			res = append(res, fmt.Sprintf(`%smpath%d LIKE '%s'`, tableAlias, cnt+1, mpath[(cnt*indexLen):]))
 		}
 
 		if idx := cnt * indexLen; idx == 0 {
@@ -40,8 +40,11 @@ import (
 )
 
 var (
-	queries = map[string]string{
-		"AddWorkspace":            `replace into idm_workspaces (uuid, label, description, attributes, slug, scope, last_updated) values (?, ?, ?, ?, ?, ?, ?)`,
+	queries = map[string]interface{}{
+		"AddWorkspace": sql.DriverQueries{
+			"":         `replace into idm_workspaces (uuid, label, description, attributes, slug, scope, last_updated) values (?, ?, ?, ?, ?, ?, ?)`,
+			"postgres": `insert into idm_workspaces (uuid, label, description, attributes, slug, scope, last_updated) values (?, ?, ?, ?, ?, ?, ?) on conflict (uuid) do update set label = excluded.label, description = excluded.description, attributes = excluded.attributes, slug = excluded.slug, scope = excluded.scope, last_updated = excluded.last_updated`,
+		},
 		"GetWorkspace":            `select uuid from idm_workspaces where uuid = ?`,
 		"ExistsWorkspace":         `select count(uuid) from idm_workspaces where uuid = ?`,
 		"ExistsWorkspaceWithSlug": `select count(uuid) from idm_workspaces where slug = ?`,
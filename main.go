@@ -48,12 +48,15 @@ import (
 	_ "github.com/pydio/cells/data/key/grpc"
 	_ "github.com/pydio/cells/data/meta/grpc"
 	_ "github.com/pydio/cells/data/meta/rest"
+	_ "github.com/pydio/cells/data/quota/grpc"
+	_ "github.com/pydio/cells/data/quota/rest"
 	_ "github.com/pydio/cells/data/source/index/grpc"
 	_ "github.com/pydio/cells/data/source/objects/grpc"
 	_ "github.com/pydio/cells/data/source/sync/grpc"
 	_ "github.com/pydio/cells/data/tree/grpc"
 	_ "github.com/pydio/cells/data/tree/rest"
 	_ "github.com/pydio/cells/data/versions/grpc"
+	_ "github.com/pydio/cells/data/versions/rest"
 
 	_ "github.com/pydio/cells/discovery/config/grpc"
 	_ "github.com/pydio/cells/discovery/config/rest"
@@ -61,6 +64,7 @@ import (
 	_ "github.com/pydio/cells/gateway/data"
 	_ "github.com/pydio/cells/gateway/dav"
 	_ "github.com/pydio/cells/gateway/micro"
+	_ "github.com/pydio/cells/gateway/ocm"
 	_ "github.com/pydio/cells/gateway/proxy"
 	_ "github.com/pydio/cells/gateway/websocket/api"
 	_ "github.com/pydio/cells/gateway/wopi"
@@ -95,6 +99,8 @@ import (
 	_ "github.com/pydio/cells/scheduler/actions/archive"
 	_ "github.com/pydio/cells/scheduler/actions/changes"
 	_ "github.com/pydio/cells/scheduler/actions/cmd"
+	_ "github.com/pydio/cells/scheduler/actions/encryption"
+	_ "github.com/pydio/cells/scheduler/actions/idm"
 	_ "github.com/pydio/cells/scheduler/actions/images"
 	_ "github.com/pydio/cells/scheduler/actions/scheduler"
 	_ "github.com/pydio/cells/scheduler/actions/tree"
@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/config"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/object"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/service/defaults"
+	"github.com/pydio/cells/common/utils"
+	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/idm/key"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+var (
+	rotateActionName = "actions.encryption.rotate"
+)
+
+// RotateAction re-wraps all node keys of a MASTER-encrypted datasource with a new master key.
+// While running, the new key is published in the datasource configuration so that both keys can
+// be used to read data. Keys already wrapped with the new key are skipped, thus an interrupted
+// rotation is resumed by simply running the action again. Once all keys are rotated, the previous
+// master key is retired and deleted from the key store.
+type RotateAction struct {
+	Router         views.Handler
+	NodeKeyClient  encryption.NodeKeyManagerClient
+	KeyStoreClient encryption.UserKeyStoreClient
+	KeyTool        key.UserKeyTool
+
+	dsName   string
+	newKeyID string
+	oldKeyID string
+}
+
+// GetName returns this action unique identifier
+func (r *RotateAction) GetName() string {
+	return rotateActionName
+}
+
+// Implement ControllableAction
+func (r *RotateAction) CanPause() bool {
+	return true
+}
+
+// Implement ControllableAction
+func (r *RotateAction) CanStop() bool {
+	return true
+}
+
+// ProvidesProgress implements ProgressProviderAction interface method
+func (r *RotateAction) ProvidesProgress() bool {
+	return true
+}
+
+// Init passes parameters to the action
+func (r *RotateAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
+
+	if action.Parameters == nil || action.Parameters["dsName"] == "" {
+		return errors.BadRequest(common.SERVICE_JOBS, "Could not find dsName parameter for key rotation action")
+	}
+	r.dsName = action.Parameters["dsName"]
+	r.newKeyID = action.Parameters["keyId"]
+
+	if r.Router == nil {
+		r.Router = views.NewStandardRouter(views.RouterOptions{AdminView: true})
+	}
+	return nil
+}
+
+// Run the actual action code
+func (r *RotateAction) Run(ctx context.Context, channels *actions.RunnableChannels, input jobs.ActionMessage) (jobs.ActionMessage, error) {
+
+	if r.NodeKeyClient == nil {
+		r.NodeKeyClient = encryption.NewNodeKeyManagerClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ENC_KEY, defaults.NewClient())
+	}
+	if r.KeyStoreClient == nil {
+		r.KeyStoreClient = encryption.NewUserKeyStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_USER_KEY, defaults.NewClient())
+	}
+
+	if err := r.prepare(ctx); err != nil {
+		log.Logger(ctx).Error("Cannot prepare key rotation", zap.String("datasource", r.dsName), zap.Error(err))
+		return input.WithError(err), err
+	}
+
+	channels.StatusMsg <- fmt.Sprintf("Listing files of datasource %s", r.dsName)
+	uuids, err := r.listFiles(ctx)
+	if err != nil {
+		return input.WithError(err), err
+	}
+
+	owner := fmt.Sprintf("ds:%s", r.dsName)
+	var rotated, failed int
+	total := len(uuids)
+
+	for i, uuid := range uuids {
+		select {
+		case <-channels.Pause:
+			<-channels.BlockUntilResume()
+		case <-channels.Stop:
+			msg := fmt.Sprintf("Key rotation interrupted after %d/%d files, run it again to resume", i, total)
+			channels.StatusMsg <- msg
+			output := input
+			output.AppendOutput(&jobs.ActionOutput{StringBody: msg})
+			return output, nil
+		default:
+		}
+
+		done, err := r.rewrapNodeKey(ctx, owner, uuid)
+		if err != nil {
+			log.Logger(ctx).Error("Cannot rotate node key", zap.String("uuid", uuid), zap.Error(err))
+			failed++
+		} else if done {
+			rotated++
+		}
+		channels.Progress <- float32(i+1) / float32(total)
+	}
+
+	if failed > 0 {
+		err := fmt.Errorf("failed rotating %d keys of datasource %s, run the action again to resume", failed, r.dsName)
+		return input.WithError(err), err
+	}
+
+	if err := r.commit(); err != nil {
+		return input.WithError(err), err
+	}
+	if err := r.retire(ctx); err != nil {
+		log.Logger(ctx).Error("Cannot delete retired master key", zap.String("key", r.oldKeyID), zap.Error(err))
+		return input.WithError(err), err
+	}
+
+	msg := fmt.Sprintf("Datasource %s is now encrypted with key %s (%d node keys rotated)", r.dsName, r.newKeyID, rotated)
+	log.Logger(ctx).Info(msg)
+	channels.StatusMsg <- msg
+	output := input
+	output.AppendOutput(&jobs.ActionOutput{StringBody: msg})
+	return output, nil
+}
+
+// prepare checks the datasource, creates the new master key and publishes it as the rotation key.
+func (r *RotateAction) prepare(ctx context.Context) error {
+
+	var ds object.DataSource
+	if err := config.Get("services", r.serviceName()).Scan(&ds); err != nil {
+		return err
+	}
+	if ds.EncryptionMode != object.EncryptionMode_MASTER {
+		return fmt.Errorf("datasource %s is not encrypted with a master key", r.dsName)
+	}
+	r.oldKeyID = ds.EncryptionKey
+
+	if pending := config.Get("services", r.serviceName(), views.EncryptionKeyRotationConfig).String(""); pending != "" {
+		if r.newKeyID != "" && r.newKeyID != pending {
+			return fmt.Errorf("a rotation to key %s is already pending on datasource %s", pending, r.dsName)
+		}
+		r.newKeyID = pending
+	}
+	if r.newKeyID == "" {
+		r.newKeyID = fmt.Sprintf("%s-%d", r.dsName, time.Now().Unix())
+	}
+	if r.newKeyID == r.oldKeyID {
+		return fmt.Errorf("datasource %s is already encrypted with key %s", r.dsName, r.newKeyID)
+	}
+
+	if r.KeyTool == nil {
+		if err := key.CreateMasterKey(ctx, r.newKeyID, fmt.Sprintf("Rotated key for %s", r.dsName)); err != nil {
+			return err
+		}
+		tool, err := key.MasterKeyTool(ctx)
+		if err != nil {
+			return err
+		}
+		r.KeyTool = tool
+	}
+
+	config.Set(r.newKeyID, "services", r.serviceName(), views.EncryptionKeyRotationConfig)
+	return config.Save(common.PYDIO_SYSTEM_USERNAME, fmt.Sprintf("Start rotating encryption key of datasource %s", r.dsName))
+}
+
+// commit makes the new key the datasource encryption key.
+func (r *RotateAction) commit() error {
+	config.Set(r.newKeyID, "services", r.serviceName(), "EncryptionKey")
+	config.Del("services", r.serviceName(), views.EncryptionKeyRotationConfig)
+	return config.Save(common.PYDIO_SYSTEM_USERNAME, fmt.Sprintf("Rotated encryption key of datasource %s", r.dsName))
+}
+
+// retire deletes the previous master key from the key store, as no node key of the datasource is sealed with
+// it anymore. It is kept if another datasource still uses it.
+func (r *RotateAction) retire(ctx context.Context) error {
+	for name, ds := range utils.ListSourcesFromConfig() {
+		if name == r.dsName {
+			continue
+		}
+		rotation := config.Get("services", common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DATA_SYNC_+name, views.EncryptionKeyRotationConfig).String("")
+		if ds.EncryptionKey == r.oldKeyID || rotation == r.oldKeyID {
+			log.Logger(ctx).Info("Previous master key is still used by another datasource", zap.String("key", r.oldKeyID), zap.String("datasource", name))
+			return nil
+		}
+	}
+	_, err := r.KeyStoreClient.DeleteUserKey(ctx, &encryption.DeleteUserKeyRequest{
+		Owner: common.PYDIO_SYSTEM_USERNAME,
+		KeyID: r.oldKeyID,
+	})
+	return err
+}
+
+func (r *RotateAction) listFiles(ctx context.Context) (uuids []string, err error) {
+
+	streamer, err := r.Router.ListNodes(ctx, &tree.ListNodesRequest{
+		Node:      &tree.Node{Path: r.dsName},
+		Recursive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+	for {
+		resp, e := streamer.Recv()
+		if e != nil {
+			break
+		}
+		if resp == nil || !resp.Node.IsLeaf() || strings.HasSuffix(resp.Node.Path, common.PYDIO_SYNC_HIDDEN_FILE_META) {
+			continue
+		}
+		uuids = append(uuids, resp.Node.Uuid)
+	}
+	return
+}
+
+// rewrapNodeKey seals the key of node @uuid with the new master key. It returns false if the node has no key
+// or if its key is already sealed with the new master key.
+func (r *RotateAction) rewrapNodeKey(ctx context.Context, owner string, uuid string) (bool, error) {
+
+	rsp, err := r.NodeKeyClient.GetNodeKey(ctx, &encryption.GetNodeKeyRequest{NodeId: uuid, UserId: owner})
+	if err != nil {
+		return false, err
+	}
+	if len(rsp.EncryptedKey) == 0 {
+		return false, nil
+	}
+
+	if _, e := r.KeyTool.GetDecrypted(ctx, r.newKeyID, rsp.EncryptedKey); e == nil {
+		return false, nil
+	}
+	plain, err := r.KeyTool.GetDecrypted(ctx, r.oldKeyID, rsp.EncryptedKey)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := r.KeyTool.GetEncrypted(ctx, r.newKeyID, plain)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.NodeKeyClient.SetNodeKey(ctx, &encryption.SetNodeKeyRequest{
+		Key: &encryption.NodeKey{
+			NodeId:    uuid,
+			UserId:    owner,
+			OwnerId:   rsp.OwnerId,
+			Data:      sealed,
+			Nonce:     rsp.Nonce,
+			BlockSize: rsp.BlockSize,
+		},
+	})
+	return err == nil, err
+}
+
+func (r *RotateAction) serviceName() string {
+	return common.SERVICE_GRPC_NAMESPACE_ + common.SERVICE_DATA_SYNC_ + r.dsName
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/client"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/encryption"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/views"
+)
+
+func init() {
+	// Ignore client pool for unit tests
+	views.IsUnitTestEnv = true
+}
+
+// keyToolMock prefixes data with the key ID instead of really encrypting it
+type keyToolMock struct{}
+
+func (k *keyToolMock) GetEncrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	return append([]byte(keyID+":"), data...), nil
+}
+
+func (k *keyToolMock) GetDecrypted(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(keyID+":")) {
+		return nil, fmt.Errorf("wrong key")
+	}
+	return data[len(keyID)+1:], nil
+}
+
+type nodeKeyClientMock struct {
+	keys map[string]*encryption.NodeKey
+}
+
+func (m *nodeKeyClientMock) DeleteNode(ctx context.Context, in *encryption.DeleteNodeRequest, opts ...client.CallOption) (*encryption.DeleteNodeResponse, error) {
+	return &encryption.DeleteNodeResponse{}, nil
+}
+
+func (m *nodeKeyClientMock) SetNodeParams(ctx context.Context, in *encryption.SetNodeParamsRequest, opts ...client.CallOption) (*encryption.SetNodeParamsResponse, error) {
+	return &encryption.SetNodeParamsResponse{}, nil
+}
+
+func (m *nodeKeyClientMock) GetNodeKey(ctx context.Context, in *encryption.GetNodeKeyRequest, opts ...client.CallOption) (*encryption.GetNodeKeyResponse, error) {
+	rsp := &encryption.GetNodeKeyResponse{}
+	if k, ok := m.keys[in.NodeId+in.UserId]; ok {
+		rsp.OwnerId = k.OwnerId
+		rsp.EncryptedKey = k.Data
+		rsp.Nonce = k.Nonce
+		rsp.BlockSize = k.BlockSize
+	}
+	return rsp, nil
+}
+
+func (m *nodeKeyClientMock) SetNodeKey(ctx context.Context, in *encryption.SetNodeKeyRequest, opts ...client.CallOption) (*encryption.SetNodeKeyResponse, error) {
+	m.keys[in.Key.NodeId+in.Key.UserId] = in.Key
+	return &encryption.SetNodeKeyResponse{}, nil
+}
+
+func (m *nodeKeyClientMock) DeleteNodeKey(ctx context.Context, in *encryption.DeleteNodeKeyRequest, opts ...client.CallOption) (*encryption.DeleteNodeKeyResponse, error) {
+	return &encryption.DeleteNodeKeyResponse{}, nil
+}
+
+func (m *nodeKeyClientMock) DeleteNodeSharedKey(ctx context.Context, in *encryption.DeleteNodeSharedKeyRequest, opts ...client.CallOption) (*encryption.DeleteNodeSharedKeyResponse, error) {
+	return &encryption.DeleteNodeSharedKeyResponse{}, nil
+}
+
+func TestRotateAction_GetName(t *testing.T) {
+	Convey("Test GetName", t, func() {
+		action := &RotateAction{}
+		So(action.GetName(), ShouldEqual, rotateActionName)
+	})
+}
+
+func TestRotateAction_Init(t *testing.T) {
+
+	Convey("Init without datasource", t, func() {
+		action := &RotateAction{}
+		err := action.Init(&jobs.Job{}, nil, &jobs.Action{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Init with parameters", t, func() {
+		action := &RotateAction{}
+		err := action.Init(&jobs.Job{}, nil, &jobs.Action{
+			Parameters: map[string]string{"dsName": "pydiods1", "keyId": "new-key"},
+		})
+		So(err, ShouldBeNil)
+		So(action.dsName, ShouldEqual, "pydiods1")
+		So(action.newKeyID, ShouldEqual, "new-key")
+		So(action.Router, ShouldNotBeNil)
+	})
+}
+
+func TestRotateAction_RewrapNodeKey(t *testing.T) {
+
+	Convey("Rewrap node keys", t, func() {
+
+		ctx := context.Background()
+		tool := &keyToolMock{}
+		sealed, _ := tool.GetEncrypted(ctx, "old-key", []byte("secret"))
+		keyClient := &nodeKeyClientMock{keys: map[string]*encryption.NodeKey{
+			"node1ds:pydiods1": {NodeId: "node1", UserId: "ds:pydiods1", OwnerId: "ds:pydiods1", Data: sealed, BlockSize: 4096},
+		}}
+		action := &RotateAction{
+			NodeKeyClient: keyClient,
+			KeyTool:       tool,
+			dsName:        "pydiods1",
+			oldKeyID:      "old-key",
+			newKeyID:      "new-key",
+		}
+
+		done, err := action.rewrapNodeKey(ctx, "ds:pydiods1", "node1")
+		So(err, ShouldBeNil)
+		So(done, ShouldBeTrue)
+		k := keyClient.keys["node1ds:pydiods1"]
+		So(k.BlockSize, ShouldEqual, 4096)
+		plain, err := tool.GetDecrypted(ctx, "new-key", k.Data)
+		So(err, ShouldBeNil)
+		So(string(plain), ShouldEqual, "secret")
+
+		// Running again must not wrap the key twice
+		done, err = action.rewrapNodeKey(ctx, "ds:pydiods1", "node1")
+		So(err, ShouldBeNil)
+		So(done, ShouldBeFalse)
+
+		// Nodes without key are ignored
+		done, err = action.rewrapNodeKey(ctx, "ds:pydiods1", "node2")
+		So(err, ShouldBeNil)
+		So(done, ShouldBeFalse)
+	})
+}
+
+type keyStoreClientMock struct {
+	encryption.UserKeyStoreClient
+	deleted []string
+}
+
+func (m *keyStoreClientMock) DeleteUserKey(ctx context.Context, in *encryption.DeleteUserKeyRequest, opts ...client.CallOption) (*encryption.DeleteUserKeyResponse, error) {
+	m.deleted = append(m.deleted, in.Owner+"/"+in.KeyID)
+	return &encryption.DeleteUserKeyResponse{Success: true}, nil
+}
+
+func TestRotateAction_Retire(t *testing.T) {
+
+	Convey("Retire previous master key", t, func() {
+
+		storeClient := &keyStoreClientMock{}
+		action := &RotateAction{
+			KeyStoreClient: storeClient,
+			dsName:         "pydiods1",
+			oldKeyID:       "old-key",
+			newKeyID:       "new-key",
+		}
+		So(action.retire(context.Background()), ShouldBeNil)
+		So(storeClient.deleted, ShouldResemble, []string{common.PYDIO_SYSTEM_USERNAME + "/old-key"})
+	})
+}
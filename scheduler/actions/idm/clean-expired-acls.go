@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package idm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/micro/go-micro/client"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/service/defaults"
+	service "github.com/pydio/cells/common/service/proto"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+var (
+	cleanExpiredACLsActionName = "actions.idm.clean-expired-acls"
+)
+
+// CleanExpiredACLsAction deletes the ACLs whose validity window is over.
+type CleanExpiredACLsAction struct {
+	Client idm.ACLServiceClient
+}
+
+// GetName returns this action unique identifier
+func (c *CleanExpiredACLsAction) GetName() string {
+	return cleanExpiredACLsActionName
+}
+
+// Init passes parameters to the action
+func (c *CleanExpiredACLsAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
+	if c.Client == nil {
+		c.Client = idm.NewACLServiceClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_ACL, defaults.NewClient())
+	}
+	return nil
+}
+
+// Run the actual action code
+func (c *CleanExpiredACLsAction) Run(ctx context.Context, channels *actions.RunnableChannels, input jobs.ActionMessage) (jobs.ActionMessage, error) {
+
+	q, _ := ptypes.MarshalAny(&idm.ACLSingleQuery{
+		ExpiredBefore: int32(time.Now().Unix()),
+	})
+	resp, err := c.Client.DeleteACL(ctx, &idm.DeleteACLRequest{
+		Query: &service.Query{SubQueries: []*any.Any{q}},
+	})
+	if err != nil {
+		return input.WithError(err), err
+	}
+
+	input.AppendOutput(&jobs.ActionOutput{
+		Success:    true,
+		StringBody: fmt.Sprintf("Deleted %d expired ACLs", resp.RowsDeleted),
+	})
+	return input, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package idm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/micro/go-micro/client"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common/proto/idm"
+	"github.com/pydio/cells/common/proto/jobs"
+)
+
+type aclClientMock struct {
+	idm.ACLServiceClient
+	deleteRequests []*idm.DeleteACLRequest
+}
+
+func (m *aclClientMock) DeleteACL(ctx context.Context, in *idm.DeleteACLRequest, opts ...client.CallOption) (*idm.DeleteACLResponse, error) {
+	m.deleteRequests = append(m.deleteRequests, in)
+	return &idm.DeleteACLResponse{RowsDeleted: 2}, nil
+}
+
+func TestCleanExpiredACLsAction_GetName(t *testing.T) {
+	Convey("Test GetName", t, func() {
+		action := &CleanExpiredACLsAction{}
+		So(action.GetName(), ShouldEqual, cleanExpiredACLsActionName)
+	})
+}
+
+func TestCleanExpiredACLsAction_Run(t *testing.T) {
+
+	Convey("Test Run deletes expired ACLs", t, func() {
+		mock := &aclClientMock{}
+		action := &CleanExpiredACLsAction{Client: mock}
+		So(action.Init(&jobs.Job{}, nil, &jobs.Action{}), ShouldBeNil)
+
+		output, err := action.Run(context.Background(), nil, jobs.ActionMessage{})
+		So(err, ShouldBeNil)
+		So(output.GetLastOutput().StringBody, ShouldEqual, "Deleted 2 expired ACLs")
+
+		So(mock.deleteRequests, ShouldHaveLength, 1)
+		So(mock.deleteRequests[0].Query.SubQueries, ShouldHaveLength, 1)
+		q := &idm.ACLSingleQuery{}
+		So(ptypes.UnmarshalAny(mock.deleteRequests[0].Query.SubQueries[0], q), ShouldBeNil)
+		So(q.ExpiredBefore, ShouldBeGreaterThan, 0)
+		So(q.ExpiredBefore, ShouldBeLessThanOrEqualTo, int32(time.Now().Unix()))
+	})
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package tree
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/docstore"
+	"github.com/pydio/cells/common/service/defaults"
+)
+
+const (
+	checkpointsStoreID = "copyMoveCheckpoints"
+	// checkpointBatchSize is the number of processed children after which the checkpoint is saved.
+	checkpointBatchSize = 50
+	// checkpointInterval is the maximum time between two saves of the checkpoint.
+	checkpointInterval = 10 * time.Second
+)
+
+// CopyMoveCheckpoint records the children already processed by a recursive copy or move,
+// so that a failed or interrupted task can skip them when it is run again.
+type CopyMoveCheckpoint struct {
+	Processed map[string]bool `json:"processed"`
+	BytesDone int64           `json:"bytesDone"`
+}
+
+// CheckpointStore persists CopyMoveCheckpoint by ID.
+type CheckpointStore interface {
+	Load(ctx context.Context, id string) (*CopyMoveCheckpoint, error)
+	Save(ctx context.Context, id string, checkpoint *CopyMoveCheckpoint) error
+	Delete(ctx context.Context, id string) error
+}
+
+// checkpointID builds a stable identifier for a given operation, source node and target, so
+// that any task copying or moving the same node to the same place resumes from the checkpoint,
+// whatever the job that triggered it.
+func checkpointID(move bool, sourceUuid string, source string, target string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v:%s:%s:%s", move, sourceUuid, source, target))))
+}
+
+// checkpointSaver batches the saves of a checkpoint, as storing it after each child would
+// cost a docstore write per file.
+type checkpointSaver struct {
+	store      CheckpointStore
+	id         string
+	checkpoint *CopyMoveCheckpoint
+	pending    int
+	lastSave   time.Time
+}
+
+// processed records a child and saves the checkpoint once enough children were processed
+// or enough time elapsed since the last save.
+func (s *checkpointSaver) processed(ctx context.Context, relativePath string, size int64) {
+	s.checkpoint.Processed[relativePath] = true
+	s.checkpoint.BytesDone += size
+	s.pending++
+	if s.pending >= checkpointBatchSize || time.Since(s.lastSave) >= checkpointInterval {
+		s.flush(ctx)
+	}
+}
+
+// flush saves the checkpoint if some children were processed since the last save.
+func (s *checkpointSaver) flush(ctx context.Context) {
+	if s.pending == 0 {
+		return
+	}
+	if e := s.store.Save(ctx, s.id, s.checkpoint); e != nil {
+		log.Logger(ctx).Error("Cannot save copy/move checkpoint", zap.Error(e))
+		return
+	}
+	s.pending = 0
+	s.lastSave = time.Now()
+}
+
+// docStoreCheckpoints stores checkpoints as JSON documents in the docstore service.
+type docStoreCheckpoints struct{}
+
+// Load finds a checkpoint or returns an empty one.
+func (d *docStoreCheckpoints) Load(ctx context.Context, id string) (*CopyMoveCheckpoint, error) {
+	checkpoint := &CopyMoveCheckpoint{Processed: make(map[string]bool)}
+	resp, err := d.client().GetDocument(ctx, &docstore.GetDocumentRequest{StoreID: checkpointsStoreID, DocumentID: id})
+	if err != nil || resp.Document == nil {
+		return checkpoint, nil
+	}
+	if err := json.Unmarshal([]byte(resp.Document.Data), checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Processed == nil {
+		checkpoint.Processed = make(map[string]bool)
+	}
+	return checkpoint, nil
+}
+
+// Save stores the checkpoint.
+func (d *docStoreCheckpoints) Save(ctx context.Context, id string, checkpoint *CopyMoveCheckpoint) error {
+	data, _ := json.Marshal(checkpoint)
+	_, err := d.client().PutDocument(ctx, &docstore.PutDocumentRequest{
+		StoreID:    checkpointsStoreID,
+		DocumentID: id,
+		Document: &docstore.Document{
+			ID:    id,
+			Owner: common.PYDIO_SYSTEM_USERNAME,
+			Data:  string(data),
+		},
+	})
+	return err
+}
+
+// Delete removes the checkpoint.
+func (d *docStoreCheckpoints) Delete(ctx context.Context, id string) error {
+	_, err := d.client().DeleteDocuments(ctx, &docstore.DeleteDocumentsRequest{StoreID: checkpointsStoreID, DocumentID: id})
+	return err
+}
+
+func (d *docStoreCheckpoints) client() docstore.DocStoreClient {
+	return docstore.NewDocStoreClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_DOCSTORE, defaults.NewClient())
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/errors"
@@ -42,6 +43,7 @@ import (
 
 type CopyMoveAction struct {
 	Client            views.Handler
+	Checkpoints       CheckpointStore
 	Move              bool
 	Recursive         bool
 	TargetPlaceholder string
@@ -57,12 +59,30 @@ func (c *CopyMoveAction) GetName() string {
 	return copyMoveActionName
 }
 
+// Implement ControllableAction
+func (c *CopyMoveAction) CanPause() bool {
+	return true
+}
+
+// Implement ControllableAction
+func (c *CopyMoveAction) CanStop() bool {
+	return true
+}
+
+// ProvidesProgress implements ProgressProviderAction interface method
+func (c *CopyMoveAction) ProvidesProgress() bool {
+	return true
+}
+
 // Init passes parameters to the action
 func (c *CopyMoveAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
 
 	if c.Client == nil {
 		c.Client = views.NewStandardRouter(views.RouterOptions{AdminView: true})
 	}
+	if c.Checkpoints == nil {
+		c.Checkpoints = &docStoreCheckpoints{}
+	}
 
 	if action.Parameters == nil {
 		return errors.InternalServerError(common.SERVICE_JOBS, "Could not find parameters for CopyMove action")
@@ -113,6 +133,7 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 	sourceNode = readR.Node
 	output := input
 	childrenMoved := 0
+	progress := &copyProgress{channels: channels}
 
 	session := uuid.NewUUID().String()
 
@@ -143,13 +164,54 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 			children = append(children, child.Node)
 		}
 
+		// Skip children already processed by a previous run of this task
+		cpID := checkpointID(c.Move, sourceNode.Uuid, prefixPathSrc, prefixPathTarget)
+		checkpoint, cpErr := c.Checkpoints.Load(ctx, cpID)
+		if cpErr != nil {
+			log.Logger(ctx).Error("Cannot load copy/move checkpoint, starting over", zap.Error(cpErr))
+			checkpoint = &CopyMoveCheckpoint{Processed: make(map[string]bool)}
+		}
+		var remaining []*tree.Node
+		progress.done = checkpoint.BytesDone
+		progress.total = checkpoint.BytesDone
+		for _, childNode := range children {
+			if checkpoint.Processed[strings.TrimPrefix(childNode.Path, prefixPathSrc+"/")] {
+				continue
+			}
+			remaining = append(remaining, childNode)
+			if childNode.IsLeaf() {
+				progress.total += childNode.Size
+			}
+		}
+		if len(remaining) < len(children) {
+			log.Logger(ctx).Info(fmt.Sprintf("Resuming copy/move, skipping %v children already processed", len(children)-len(remaining)))
+		}
+		children = remaining
+		saver := &checkpointSaver{store: c.Checkpoints, id: cpID, checkpoint: checkpoint, lastSave: time.Now()}
+
 		if len(children) > 0 {
 			log.Logger(ctx).Info(fmt.Sprintf("There are %v children to move", len(children)), zap.Any("c", children))
 		}
 		total := len(children)
+		stopping := false
 
 		for idx, childNode := range children {
 
+			select {
+			case <-channels.Pause:
+				<-channels.BlockUntilResume()
+			case <-channels.Stop:
+				stopping = true
+			default:
+			}
+			// A move already sent operations to the indexation session: process one more child
+			// to close it, otherwise the datasource would keep waiting for the session end.
+			if stopping && (!c.Move || childrenMoved == 0) {
+				saver.flush(ctx)
+				err := errInterrupted(childrenMoved, total)
+				return output.WithError(err), err
+			}
+
 			childPath := childNode.Path
 			relativePath := strings.TrimPrefix(childPath, prefixPathSrc+"/")
 			targetPath := prefixPathTarget + "/" + relativePath
@@ -178,9 +240,10 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 						meta["X-Amz-Metadata-Directive"] = "REPLACE"
 					}
 					meta["X-Pydio-Session"] = session
-					_, e := c.Client.CopyObject(ctx, childNode, &tree.Node{Path: targetPath}, &views.CopyRequestData{Metadata: meta})
+					_, e := c.Client.CopyObject(ctx, childNode, &tree.Node{Path: targetPath}, &views.CopyRequestData{Metadata: meta, Progress: progress})
 					if e != nil {
 						log.Logger(ctx).Info("-- Copy ERROR", zap.Error(e), zap.Any("from", childNode.Path), zap.Any("to", targetPath))
+						saver.flush(ctx)
 						return output.WithError(e), e
 					}
 					log.Logger(ctx).Info("-- Copy Success: " + childNode.Path)
@@ -195,6 +258,7 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 					_, e := c.Client.CreateNode(ctx, &tree.CreateNodeRequest{Node: folderNode, IndexationSession: session})
 					if e != nil {
 						log.Logger(ctx).Info("-- Create Folder ERROR", zap.Error(e), zap.Any("from", childNode.Path), zap.Any("to", targetPath))
+						saver.flush(ctx)
 						return output.WithError(e), e
 					}
 					log.Logger(ctx).Info("-- Create Folder Success " + childNode.Path)
@@ -206,13 +270,14 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 			if c.Move {
 
 				// If we're sending the last Delete here - then we close the session at the same time
-				if idx == len(children)-1 {
+				if idx == len(children)-1 || stopping {
 					session = "close-" + session
 				}
 
 				_, moveErr := c.Client.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: childNode, IndexationSession: session})
 				if moveErr != nil {
 					log.Logger(ctx).Info("-- Delete Error")
+					saver.flush(ctx)
 					return output.WithError(moveErr), moveErr
 				}
 				log.Logger(ctx).Info("-- Delete Success " + childNode.Path)
@@ -225,8 +290,23 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 				})
 			}
 			childrenMoved++
-			channels.Progress <- float32(childrenMoved) / float32(total)
+			var size int64
+			if childNode.IsLeaf() {
+				size = childNode.Size
+			}
+			saver.processed(ctx, relativePath, size)
+			progress.done = checkpoint.BytesDone
+			progress.notify(childrenMoved, total)
+
+			if stopping {
+				saver.flush(ctx)
+				err := errInterrupted(childrenMoved, total)
+				return output.WithError(err), err
+			}
+		}
 
+		if e := c.Checkpoints.Delete(ctx, cpID); e != nil {
+			log.Logger(ctx).Error("Cannot delete copy/move checkpoint", zap.Error(e))
 		}
 
 	}
@@ -237,7 +317,8 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 
 	// Now Copy/Move initial node
 	if sourceNode.IsLeaf() {
-		_, e := c.Client.CopyObject(ctx, sourceNode, targetNode, &views.CopyRequestData{})
+		progress.total = sourceNode.Size
+		_, e := c.Client.CopyObject(ctx, sourceNode, targetNode, &views.CopyRequestData{Progress: progress})
 		if e != nil {
 			return output.WithError(e), e
 		}
@@ -291,3 +372,49 @@ func (c *CopyMoveAction) Run(ctx context.Context, channels *actions.RunnableChan
 	log.Logger(ctx).Info("Should now exit copy/move action")
 	return output, nil
 }
+
+// errInterrupted reports a task stopped before all children were processed.
+func errInterrupted(processed int, total int) error {
+	return errors.New(common.SERVICE_JOBS, fmt.Sprintf("Copy/Move interrupted after %d/%d children, run it again to resume", processed, total), 499)
+}
+
+// copyProgress is a progress hook for CopyObject requests: it counts the bytes copied
+// and forwards the progress ratio to the task channels.
+type copyProgress struct {
+	channels *actions.RunnableChannels
+	done     int64
+	total    int64
+}
+
+// Read implements the minio-go progress hook.
+func (p *copyProgress) Read(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if p.total > 0 {
+		p.send(float32(p.done) / float32(p.total))
+	}
+	return len(b), nil
+}
+
+// notify sends the progress after a child was processed, falling back to the number
+// of children when there are no bytes to copy.
+func (p *copyProgress) notify(processed int, count int) {
+	if p.total > 0 {
+		p.send(float32(p.done) / float32(p.total))
+	} else if count > 0 {
+		p.send(float32(processed) / float32(count))
+	}
+}
+
+// send does not block if the progress is not read yet, as a more recent value will follow.
+func (p *copyProgress) send(ratio float32) {
+	if p.channels == nil || p.channels.Progress == nil {
+		return
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	select {
+	case p.channels.Progress <- ratio:
+	default:
+	}
+}
@@ -22,8 +22,11 @@ package tree
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/micro/go-micro/client"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/pydio/cells/common/proto/jobs"
@@ -225,3 +228,166 @@ func TestCopyMoveAction_RunMove(t *testing.T) {
 
 	})
 }
+
+type memoryCheckpoints map[string]*CopyMoveCheckpoint
+
+func (m memoryCheckpoints) Load(ctx context.Context, id string) (*CopyMoveCheckpoint, error) {
+	if cp, ok := m[id]; ok {
+		return cp, nil
+	}
+	return &CopyMoveCheckpoint{Processed: make(map[string]bool)}, nil
+}
+
+func (m memoryCheckpoints) Save(ctx context.Context, id string, checkpoint *CopyMoveCheckpoint) error {
+	m[id] = checkpoint
+	return nil
+}
+
+func (m memoryCheckpoints) Delete(ctx context.Context, id string) error {
+	delete(m, id)
+	return nil
+}
+
+func TestCopyMoveAction_RunResume(t *testing.T) {
+
+	Convey("", t, func() {
+
+		checkpoints := memoryCheckpoints{}
+		action := &CopyMoveAction{Checkpoints: checkpoints}
+		job := &jobs.Job{ID: "copy-job"}
+		mock := &views.HandlerMock{
+			Nodes: map[string]*tree.Node{
+				"path/folder":       {Uuid: "folder-uuid", Path: "path/folder", Type: tree.NodeType_COLLECTION},
+				"path/folder/a.txt": {Path: "path/folder/a.txt", Type: tree.NodeType_LEAF, Size: 10},
+				"path/folder/b.txt": {Path: "path/folder/b.txt", Type: tree.NodeType_LEAF, Size: 20},
+			},
+		}
+		action.Client = mock
+		action.Init(job, nil, &jobs.Action{
+			Parameters: map[string]string{
+				"target":    "target/folder",
+				"type":      "copy",
+				"recursive": "true",
+			},
+		})
+		cpID := checkpointID(false, "folder-uuid", "path/folder", "target/folder")
+		checkpoints[cpID] = &CopyMoveCheckpoint{Processed: map[string]bool{"a.txt": true}, BytesDone: 10}
+
+		status := make(chan string, 10)
+		progress := make(chan float32, 10)
+		output, err := action.Run(context.Background(), &actions.RunnableChannels{StatusMsg: status, Progress: progress}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: "path/folder"}},
+		})
+		close(status)
+		close(progress)
+
+		So(err, ShouldBeNil)
+		So(output.GetLastOutput().Success, ShouldBeTrue)
+		// Only b.txt was copied
+		So(mock.Nodes["from"].Path, ShouldEqual, "path/folder/b.txt")
+		So(mock.Nodes["to"].Path, ShouldEqual, "target/folder/b.txt")
+		// Checkpoint is removed once done
+		So(checkpoints, ShouldBeEmpty)
+
+		var last float32
+		for p := range progress {
+			last = p
+		}
+		So(last, ShouldEqual, 1)
+
+	})
+}
+
+// sessionRecorder records the indexation sessions of DeleteNode requests and triggers
+// a callback after each of them.
+type sessionRecorder struct {
+	*views.HandlerMock
+	sessions []string
+	onDelete func()
+}
+
+func (r *sessionRecorder) DeleteNode(ctx context.Context, in *tree.DeleteNodeRequest, opts ...client.CallOption) (*tree.DeleteNodeResponse, error) {
+	r.sessions = append(r.sessions, in.IndexationSession)
+	if r.onDelete != nil {
+		r.onDelete()
+	}
+	return r.HandlerMock.DeleteNode(ctx, in, opts...)
+}
+
+func TestCopyMoveAction_RunStop(t *testing.T) {
+
+	nodes := func() map[string]*tree.Node {
+		return map[string]*tree.Node{
+			"path/folder":       {Uuid: "folder-uuid", Path: "path/folder", Type: tree.NodeType_COLLECTION},
+			"path/folder/a.txt": {Path: "path/folder/a.txt", Type: tree.NodeType_LEAF, Size: 10},
+			"path/folder/b.txt": {Path: "path/folder/b.txt", Type: tree.NodeType_LEAF, Size: 20},
+			"path/folder/c.txt": {Path: "path/folder/c.txt", Type: tree.NodeType_LEAF, Size: 30},
+		}
+	}
+
+	Convey("Stopping a copy returns an error and keeps the checkpoint", t, func() {
+
+		checkpoints := memoryCheckpoints{}
+		action := &CopyMoveAction{Checkpoints: checkpoints, Client: &views.HandlerMock{Nodes: nodes()}}
+		action.Init(&jobs.Job{}, nil, &jobs.Action{
+			Parameters: map[string]string{"target": "target/folder", "type": "copy", "recursive": "true"},
+		})
+		stop := make(chan interface{}, 1)
+		stop <- true
+		_, err := action.Run(context.Background(), &actions.RunnableChannels{Stop: stop, StatusMsg: make(chan string, 10), Progress: make(chan float32, 10)}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: "path/folder"}},
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "interrupted after 0/3")
+	})
+
+	Convey("Stopping a move closes the indexation session", t, func() {
+
+		checkpoints := memoryCheckpoints{}
+		stop := make(chan interface{}, 1)
+		recorder := &sessionRecorder{HandlerMock: &views.HandlerMock{Nodes: nodes()}}
+		recorder.onDelete = func() {
+			if len(recorder.sessions) == 1 {
+				stop <- true
+			}
+		}
+		action := &CopyMoveAction{Checkpoints: checkpoints, Client: recorder}
+		action.Init(&jobs.Job{}, nil, &jobs.Action{
+			Parameters: map[string]string{"target": "target/folder", "type": "move", "recursive": "true"},
+		})
+		_, err := action.Run(context.Background(), &actions.RunnableChannels{Stop: stop, StatusMsg: make(chan string, 10), Progress: make(chan float32, 10)}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: "path/folder"}},
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "interrupted after 2/3")
+		So(recorder.sessions, ShouldHaveLength, 2)
+		So(recorder.sessions[0], ShouldNotStartWith, "close-")
+		So(recorder.sessions[1], ShouldStartWith, "close-")
+
+		cp := checkpoints[checkpointID(true, "folder-uuid", "path/folder", "target/folder")]
+		So(cp, ShouldNotBeNil)
+		So(cp.Processed, ShouldHaveLength, 2)
+	})
+}
+
+func TestCheckpointSaver(t *testing.T) {
+
+	Convey("Checkpoints are saved by batches", t, func() {
+		checkpoints := memoryCheckpoints{}
+		saver := &checkpointSaver{store: checkpoints, id: "id", checkpoint: &CopyMoveCheckpoint{Processed: make(map[string]bool)}, lastSave: time.Now()}
+		for i := 0; i < checkpointBatchSize-1; i++ {
+			saver.processed(context.Background(), fmt.Sprintf("file-%d", i), 1)
+		}
+		So(checkpoints, ShouldBeEmpty)
+		saver.processed(context.Background(), "last", 1)
+		So(checkpoints["id"].Processed, ShouldHaveLength, checkpointBatchSize)
+		So(checkpoints["id"].BytesDone, ShouldEqual, checkpointBatchSize)
+
+		delete(checkpoints, "id")
+		saver.flush(context.Background())
+		So(checkpoints, ShouldBeEmpty)
+		saver.processed(context.Background(), "other", 1)
+		saver.flush(context.Background())
+		So(checkpoints["id"].Processed, ShouldHaveLength, checkpointBatchSize+1)
+	})
+}
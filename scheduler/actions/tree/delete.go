@@ -22,6 +22,7 @@ package tree
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/micro/go-micro/client"
 
@@ -33,6 +34,8 @@ import (
 
 type DeleteAction struct {
 	Client views.Handler
+	// Permanent bypasses the recycle bin
+	Permanent bool
 }
 
 var (
@@ -47,7 +50,10 @@ func (c *DeleteAction) GetName() string {
 // Init passes parameters to the action
 func (c *DeleteAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
 
-	c.Client = views.NewStandardRouter(views.RouterOptions{AdminView: true})
+	c.Client = views.NewStandardRouter(views.RouterOptions{AdminView: true, RecycleBin: true})
+	if permanent, ok := action.Parameters["permanent"]; ok {
+		c.Permanent, _ = strconv.ParseBool(permanent)
+	}
 
 	return nil
 }
@@ -59,6 +65,9 @@ func (c *DeleteAction) Run(ctx context.Context, channels *actions.RunnableChanne
 		return input.WithIgnore(), nil // Ignore
 	}
 
+	if c.Permanent {
+		ctx = views.WithPermanentDelete(ctx)
+	}
 	_, err := c.Client.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: input.Nodes[0]})
 	if err != nil {
 		return input.WithError(err), err
@@ -43,4 +43,12 @@ func init() {
 		return &SnapshotAction{}
 	})
 
+	manager.Register(restoreActionName, func() actions.ConcreteAction {
+		return &RestoreAction{}
+	})
+
+	manager.Register(recyclePurgeActionName, func() actions.ConcreteAction {
+		return &RecyclePurgeAction{}
+	})
+
 }
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package tree
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+type RecyclePurgeAction struct {
+	Client        views.Handler
+	SearchClient  tree.SearcherClient
+	RetentionDays int
+}
+
+var (
+	recyclePurgeActionName = "actions.tree.recycle.purge"
+	recycleBinsPageSize    = int32(100)
+)
+
+// GetName returns this action unique identifier
+func (c *RecyclePurgeAction) GetName() string {
+	return recyclePurgeActionName
+}
+
+// Init passes parameters to the action
+func (c *RecyclePurgeAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
+
+	if c.Client == nil {
+		c.Client = views.NewStandardRouter(views.RouterOptions{AdminView: true})
+	}
+	if c.SearchClient == nil {
+		c.SearchClient = tree.NewSearcherClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_SEARCH, cl)
+	}
+	c.RetentionDays = 30
+	if days, ok := action.Parameters["retentionDays"]; ok {
+		if d, e := strconv.Atoi(days); e == nil && d >= 0 {
+			c.RetentionDays = d
+		}
+	}
+
+	return nil
+}
+
+// Run finds all recycle bins (or uses the input nodes if any) and permanently deletes
+// the items that were trashed before the retention period.
+func (c *RecyclePurgeAction) Run(ctx context.Context, channels *actions.RunnableChannels, input jobs.ActionMessage) (jobs.ActionMessage, error) {
+
+	bins := input.Nodes
+	if len(bins) == 0 {
+		var err error
+		if bins, err = c.findRecycleBins(ctx); err != nil {
+			return input.WithError(err), err
+		}
+	}
+
+	threshold := time.Now().Add(-time.Duration(c.RetentionDays) * 24 * time.Hour).Unix()
+	purged := 0
+	for _, bin := range bins {
+		items, err := c.listChildren(ctx, bin, false)
+		if err != nil {
+			log.Logger(ctx).Error("Cannot list recycle bin", zap.String("path", bin.Path), zap.Error(err))
+			continue
+		}
+		for _, item := range items {
+			if path.Base(item.Path) == common.PYDIO_SYNC_HIDDEN_FILE_META {
+				continue
+			}
+			if c.trashedAt(ctx, item) > threshold {
+				continue
+			}
+			if channels != nil {
+				channels.StatusMsg <- "Purging " + item.Path
+			}
+			if err := c.deleteRecursively(ctx, item); err != nil {
+				log.Logger(ctx).Error("Cannot purge recycle bin item", zap.String("path", item.Path), zap.Error(err))
+				continue
+			}
+			purged++
+		}
+	}
+
+	output := input.WithNodes(bins...)
+	output.AppendOutput(&jobs.ActionOutput{
+		Success:    true,
+		StringBody: fmt.Sprintf("Purged %d items from %d recycle bins", purged, len(bins)),
+	})
+
+	return output, nil
+}
+
+// findRecycleBins queries the search engine for the folders named after the recycle bin,
+// by pages of recycleBinsPageSize results, instead of walking the whole tree.
+func (c *RecyclePurgeAction) findRecycleBins(ctx context.Context) (bins []*tree.Node, e error) {
+
+	for from := int32(0); ; from += recycleBinsPageSize {
+		streamer, err := c.SearchClient.Search(ctx, &tree.SearchRequest{
+			Query: &tree.Query{FileName: common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+			From:  from,
+			Size:  recycleBinsPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var count int32
+		for {
+			resp, er := streamer.Recv()
+			if er != nil {
+				break
+			}
+			if resp == nil || resp.Node == nil {
+				continue
+			}
+			count++
+			folder := strings.Trim(resp.Node.Path, "/")
+			if path.Base(folder) == common.PYDIO_RECYCLE_BIN_NAME && !strings.Contains("/"+path.Dir(folder)+"/", "/"+common.PYDIO_RECYCLE_BIN_NAME+"/") {
+				bins = append(bins, resp.Node)
+			}
+		}
+		streamer.Close()
+		if count < recycleBinsPageSize {
+			break
+		}
+	}
+	return bins, nil
+}
+
+// trashedAt reads the time at which the item was moved to the recycle bin, or its
+// modification time if it was trashed by another mean.
+func (c *RecyclePurgeAction) trashedAt(ctx context.Context, item *tree.Node) int64 {
+	if resp, e := c.Client.ReadNode(ctx, &tree.ReadNodeRequest{Node: item}); e == nil && resp.Node != nil {
+		item = resp.Node
+	}
+	var t int64
+	if item.GetMeta(common.META_NAMESPACE_RECYCLE_TIME, &t); t > 0 {
+		return t
+	}
+	return item.MTime
+}
+
+// deleteRecursively removes a node and, for folders, all their children including the hidden .pydio files.
+func (c *RecyclePurgeAction) deleteRecursively(ctx context.Context, node *tree.Node) error {
+
+	if node.IsLeaf() {
+		_, err := c.Client.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: node})
+		return err
+	}
+	children, err := c.listChildren(ctx, node, true)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if !child.IsLeaf() {
+			child.Path += "/" + common.PYDIO_SYNC_HIDDEN_FILE_META
+		}
+		if _, err := c.Client.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: child}); err != nil {
+			return err
+		}
+	}
+	_, err = c.Client.DeleteNode(ctx, &tree.DeleteNodeRequest{Node: &tree.Node{Path: strings.TrimRight(node.Path, "/") + "/" + common.PYDIO_SYNC_HIDDEN_FILE_META}})
+	return err
+}
+
+func (c *RecyclePurgeAction) listChildren(ctx context.Context, node *tree.Node, recursive bool) (children []*tree.Node, e error) {
+	streamer, err := c.Client.ListNodes(ctx, &tree.ListNodesRequest{Node: node, Recursive: recursive})
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+	for {
+		resp, er := streamer.Recv()
+		if er != nil {
+			break
+		}
+		if resp == nil {
+			continue
+		}
+		children = append(children, resp.Node)
+	}
+	return children, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package tree
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+type searcherMock struct {
+	nodes []*tree.Node
+	calls int
+}
+
+func (s *searcherMock) Search(ctx context.Context, in *tree.SearchRequest, opts ...client.CallOption) (tree.Searcher_SearchClient, error) {
+	s.calls++
+	page := &searchStreamMock{}
+	for i := int(in.From); i < len(s.nodes) && i < int(in.From+in.Size); i++ {
+		page.nodes = append(page.nodes, s.nodes[i])
+	}
+	return page, nil
+}
+
+type searchStreamMock struct {
+	nodes []*tree.Node
+}
+
+func (s *searchStreamMock) SendMsg(interface{}) error { return nil }
+func (s *searchStreamMock) RecvMsg(interface{}) error { return nil }
+func (s *searchStreamMock) Close() error              { return nil }
+func (s *searchStreamMock) Recv() (*tree.SearchResponse, error) {
+	if len(s.nodes) == 0 {
+		return nil, io.EOF
+	}
+	n := s.nodes[0]
+	s.nodes = s.nodes[1:]
+	return &tree.SearchResponse{Node: n}, nil
+}
+
+func TestRecyclePurgeAction_GetName(t *testing.T) {
+	Convey("Test GetName", t, func() {
+		purgeAction := &RecyclePurgeAction{}
+		So(purgeAction.GetName(), ShouldEqual, recyclePurgeActionName)
+	})
+}
+
+func TestRecyclePurgeAction_Init(t *testing.T) {
+	Convey("", t, func() {
+		purgeAction := &RecyclePurgeAction{Client: views.NewHandlerMock()}
+		job := &jobs.Job{}
+		purgeAction.Init(job, nil, &jobs.Action{})
+		So(purgeAction.RetentionDays, ShouldEqual, 30)
+
+		purgeAction.Init(job, nil, &jobs.Action{
+			Parameters: map[string]string{
+				"retentionDays": "7",
+			},
+		})
+		So(purgeAction.RetentionDays, ShouldEqual, 7)
+	})
+}
+
+func TestRecyclePurgeAction_Run(t *testing.T) {
+	Convey("", t, func() {
+		mock := views.NewHandlerMock()
+		oldNode := &tree.Node{Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME + "/old.txt", Type: tree.NodeType_LEAF, MTime: time.Now().Add(-60 * 24 * time.Hour).Unix()}
+		newNode := &tree.Node{Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME + "/new.txt", Type: tree.NodeType_LEAF, MTime: time.Now().Unix()}
+		trashedNode := &tree.Node{Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME + "/trashed.txt", Type: tree.NodeType_LEAF, MTime: time.Now().Unix()}
+		trashedNode.SetMeta(common.META_NAMESPACE_RECYCLE_TIME, time.Now().Add(-40*24*time.Hour).Unix())
+		mock.Nodes[oldNode.Path] = oldNode
+		mock.Nodes[newNode.Path] = newNode
+		mock.Nodes[trashedNode.Path] = trashedNode
+
+		purgeAction := &RecyclePurgeAction{Client: mock}
+		purgeAction.Init(&jobs.Job{}, nil, &jobs.Action{})
+		status := make(chan string, 10)
+		progress := make(chan float32, 10)
+
+		output, err := purgeAction.Run(context.Background(), &actions.RunnableChannels{StatusMsg: status, Progress: progress}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME}},
+		})
+		close(status)
+		close(progress)
+
+		So(err, ShouldBeNil)
+		So(output.GetLastOutput().Success, ShouldBeTrue)
+		So(output.GetLastOutput().StringBody, ShouldEqual, "Purged 2 items from 1 recycle bins")
+	})
+}
+
+func TestRecyclePurgeAction_findRecycleBins(t *testing.T) {
+	Convey("Test recycle bins are found from the search engine", t, func() {
+		pageSize := recycleBinsPageSize
+		recycleBinsPageSize = 2
+		defer func() { recycleBinsPageSize = pageSize }()
+
+		searcher := &searcherMock{nodes: []*tree.Node{
+			{Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+			{Path: "ds/ws/" + common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+			{Path: "ds/ws/old_" + common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+			{Path: "ds/ws/" + common.PYDIO_RECYCLE_BIN_NAME + "/folder/" + common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+			{Path: "personal/user/" + common.PYDIO_RECYCLE_BIN_NAME, Type: tree.NodeType_COLLECTION},
+		}}
+		purgeAction := &RecyclePurgeAction{Client: views.NewHandlerMock(), SearchClient: searcher}
+		bins, err := purgeAction.findRecycleBins(context.Background())
+		So(err, ShouldBeNil)
+		So(searcher.calls, ShouldEqual, 3)
+		So(bins, ShouldHaveLength, 3)
+		So(bins[0].Path, ShouldEqual, "ds/"+common.PYDIO_RECYCLE_BIN_NAME)
+		So(bins[1].Path, ShouldEqual, "ds/ws/"+common.PYDIO_RECYCLE_BIN_NAME)
+		So(bins[2].Path, ShouldEqual, "personal/user/"+common.PYDIO_RECYCLE_BIN_NAME)
+	})
+}
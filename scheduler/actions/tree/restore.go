@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package tree
+
+import (
+	"context"
+	"path"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"go.uber.org/zap"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/log"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+type RestoreAction struct {
+	Client     views.Handler
+	MetaClient tree.NodeReceiverClient
+}
+
+var (
+	restoreActionName = "actions.tree.restore"
+)
+
+// GetName returns this action unique identifier
+func (c *RestoreAction) GetName() string {
+	return restoreActionName
+}
+
+// Init passes parameters to the action
+func (c *RestoreAction) Init(job *jobs.Job, cl client.Client, action *jobs.Action) error {
+
+	c.Client = views.NewStandardRouter(views.RouterOptions{AdminView: true})
+	c.MetaClient = tree.NewNodeReceiverClient(common.SERVICE_GRPC_NAMESPACE_+common.SERVICE_META, cl)
+
+	return nil
+}
+
+// Run moves the input node from the recycle bin back to its original location
+func (c *RestoreAction) Run(ctx context.Context, channels *actions.RunnableChannels, input jobs.ActionMessage) (jobs.ActionMessage, error) {
+
+	if len(input.Nodes) == 0 {
+		return input.WithIgnore(), nil // Ignore
+	}
+
+	readResp, err := c.Client.ReadNode(ctx, &tree.ReadNodeRequest{Node: input.Nodes[0]})
+	if err != nil {
+		return input.WithError(err), err
+	}
+	source := readResp.Node
+	restorePath := source.GetStringMeta(common.META_NAMESPACE_RECYCLE_RESTORE)
+	if restorePath == "" {
+		err := errors.BadRequest(common.SERVICE_JOBS, "Node %s has no original location to be restored to", source.Path)
+		return input.WithError(err), err
+	}
+
+	parent := path.Dir(restorePath)
+	if _, e := c.Client.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: parent}}); e != nil {
+		if _, e := c.Client.CreateNode(ctx, &tree.CreateNodeRequest{Node: &tree.Node{Path: parent, Type: tree.NodeType_COLLECTION}}); e != nil {
+			return input.WithError(e), e
+		}
+	}
+	if _, e := c.Client.ReadNode(ctx, &tree.ReadNodeRequest{Node: &tree.Node{Path: restorePath}}); e == nil {
+		restorePath = views.UniqueRecyclePath(restorePath)
+	}
+
+	log.Logger(ctx).Info("Restoring node from recycle bin", zap.String("from", source.Path), zap.String("to", restorePath))
+	if _, err := c.Client.UpdateNode(ctx, &tree.UpdateNodeRequest{From: source, To: &tree.Node{Path: restorePath, Type: source.Type}}); err != nil {
+		return input.WithError(err), err
+	}
+
+	// Empty values remove the namespaces
+	metaNode := &tree.Node{Uuid: source.Uuid, MetaStore: map[string]string{
+		common.META_NAMESPACE_RECYCLE_RESTORE: "",
+		common.META_NAMESPACE_RECYCLE_TIME:    "",
+	}}
+	if _, err := c.MetaClient.UpdateNode(ctx, &tree.UpdateNodeRequest{From: metaNode, To: metaNode}); err != nil {
+		log.Logger(ctx).Error("Cannot clear recycle bin metadata", zap.String("uuid", source.Uuid), zap.Error(err))
+	}
+
+	output := input.WithNode(&tree.Node{Uuid: source.Uuid, Path: restorePath, Type: source.Type})
+	output.AppendOutput(&jobs.ActionOutput{
+		Success:    true,
+		StringBody: "Restored node to " + restorePath,
+	})
+
+	return output, nil
+}
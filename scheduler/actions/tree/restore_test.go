@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package tree
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/pydio/cells/common"
+	"github.com/pydio/cells/common/proto/jobs"
+	"github.com/pydio/cells/common/proto/tree"
+	"github.com/pydio/cells/common/views"
+	"github.com/pydio/cells/scheduler/actions"
+)
+
+func TestRestoreAction_GetName(t *testing.T) {
+	Convey("Test GetName", t, func() {
+		restoreAction := &RestoreAction{}
+		So(restoreAction.GetName(), ShouldEqual, restoreActionName)
+	})
+}
+
+func TestRestoreAction_Run(t *testing.T) {
+	Convey("", t, func() {
+		restoreAction := &RestoreAction{}
+		restoreAction.Init(&jobs.Job{}, nil, &jobs.Action{})
+		mock := views.NewHandlerMock()
+		metaMock := views.NewHandlerMock()
+		restoreAction.Client = mock
+		restoreAction.MetaClient = metaMock
+
+		trashed := &tree.Node{Uuid: "uuid", Path: "ds/" + common.PYDIO_RECYCLE_BIN_NAME + "/file.txt", Type: tree.NodeType_LEAF}
+		trashed.SetMeta(common.META_NAMESPACE_RECYCLE_RESTORE, "ds/folder/file.txt")
+		mock.Nodes[trashed.Path] = trashed
+		mock.Nodes["ds/folder"] = &tree.Node{Path: "ds/folder", Type: tree.NodeType_COLLECTION}
+		status := make(chan string)
+		progress := make(chan float32)
+
+		ignored, _ := restoreAction.Run(context.Background(), &actions.RunnableChannels{StatusMsg: status, Progress: progress}, jobs.ActionMessage{
+			Nodes: []*tree.Node{},
+		})
+		So(ignored.GetLastOutput(), ShouldResemble, &jobs.ActionOutput{Ignored: true})
+
+		output, err := restoreAction.Run(context.Background(), &actions.RunnableChannels{StatusMsg: status, Progress: progress}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: trashed.Path}},
+		})
+		close(status)
+		close(progress)
+
+		So(err, ShouldBeNil)
+		So(output.Nodes, ShouldHaveLength, 1)
+		So(output.Nodes[0].Path, ShouldEqual, "ds/folder/file.txt")
+		So(mock.Nodes["from"].Uuid, ShouldEqual, "uuid")
+		So(mock.Nodes["to"].Path, ShouldEqual, "ds/folder/file.txt")
+		So(metaMock.Nodes["to"].MetaStore, ShouldContainKey, common.META_NAMESPACE_RECYCLE_RESTORE)
+
+		_, err = restoreAction.Run(context.Background(), &actions.RunnableChannels{}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: "ds/folder"}},
+		})
+		So(err, ShouldNotBeNil)
+
+		// Restoring over an existing node picks a unique name
+		mock.Nodes["ds/folder/file.txt"] = &tree.Node{Path: "ds/folder/file.txt", Type: tree.NodeType_LEAF}
+		output, err = restoreAction.Run(context.Background(), &actions.RunnableChannels{}, jobs.ActionMessage{
+			Nodes: []*tree.Node{{Path: trashed.Path}},
+		})
+		So(err, ShouldBeNil)
+		So(output.Nodes[0].Path, ShouldStartWith, "ds/folder/file-")
+		So(output.Nodes[0].Path, ShouldEndWith, ".txt")
+	})
+}
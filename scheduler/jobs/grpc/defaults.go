@@ -126,6 +126,54 @@ func getDefaultJobs() []*jobs.Job {
 		},
 	}
 
+	recyclePurgeJob := &jobs.Job{
+		ID:             "recycle-bin-purge-job",
+		Owner:          common.PYDIO_SYSTEM_USERNAME,
+		Label:          "Jobs.Default.RecycleBinPurge",
+		MaxConcurrency: 1,
+		Schedule: &jobs.Schedule{
+			Iso8601Schedule: "R/2012-06-04T02:00:00.828696-07:03/P1D",
+		},
+		Actions: []*jobs.Action{
+			{
+				ID: "actions.tree.recycle.purge",
+				Parameters: map[string]string{
+					"retentionDays": "30",
+				},
+			},
+		},
+	}
+
+	expiredAclsJob := &jobs.Job{
+		ID:             "clean-expired-acls-job",
+		Owner:          common.PYDIO_SYSTEM_USERNAME,
+		Label:          "Jobs.Default.CleanExpiredACLs",
+		MaxConcurrency: 1,
+		Schedule: &jobs.Schedule{
+			Iso8601Schedule: "R/2012-06-04T19:25:16.828696-07:03/PT1H",
+		},
+		Actions: []*jobs.Action{
+			{
+				ID: "actions.idm.clean-expired-acls",
+			},
+		},
+	}
+
+	recomputeQuotaJob := &jobs.Job{
+		ID:             "recompute-quota-usages-job",
+		Owner:          common.PYDIO_SYSTEM_USERNAME,
+		Label:          "Jobs.Default.RecomputeQuotaUsages",
+		MaxConcurrency: 1,
+		Schedule: &jobs.Schedule{
+			Iso8601Schedule: "R/2012-06-04T19:25:16.828696-07:03/PT24H",
+		},
+		Actions: []*jobs.Action{
+			{
+				ID: "actions.quota.recompute",
+			},
+		},
+	}
+
 	fakeLongJob := &jobs.Job{
 		ID:             "fake-long-job",
 		Owner:          common.PYDIO_SYSTEM_USERNAME,
@@ -182,6 +230,9 @@ func getDefaultJobs() []*jobs.Job {
 		cleanThumbsJob,
 		stuckTasksJob,
 		archiveChangesJob,
+		recyclePurgeJob,
+		expiredAclsJob,
+		recomputeQuotaJob,
 		// Testing Jobs
 		fakeLongJob,
 		fakeRPCJob,